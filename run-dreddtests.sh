@@ -26,7 +26,7 @@ tac ../hri-api-spec/management.swagger.yml | sed "1,8d" | tac > tmp && mv tmp ..
 
 #Initialize the Management API
 mkdir -p ../test/logs
-../src/hri -config-path=../test/spec/test_config/valid_config.yml -tls-enabled=false -kafka-properties=security.protocol:sasl_ssl,sasl.mechanism:PLAIN,sasl.username:token,sasl.password:$KAFKA_PASSWORD,ssl.endpoint.identification.algorithm:https > ../test/logs/dredd-output.txt &
+../src/hri -config-path=../test/spec/test_config/valid_config.yml -tls-enabled=false -openapi-validation=true -kafka-properties=security.protocol:sasl_ssl,sasl.mechanism:PLAIN,sasl.username:token,sasl.password:$KAFKA_PASSWORD,ssl.endpoint.identification.algorithm:https > ../test/logs/dredd-output.txt &
 sleep 1
 
 dredd -r xunit -o ../dreddtests.xml management.swagger.yml ${HRI_URL/https/http} --sorted --language=ruby --hookfiles=../test/spec/dredd_hooks.rb --hooks-worker-connect-timeout=5000
@@ -0,0 +1,73 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+	"time"
+)
+
+// used by unit tests to get a known key
+var newApiKey = auth.NewApiKey
+
+// Create generates a new API key with the requested scopes and tenants. Only the key's hash is stored, so the key is
+// only returned in this response.
+func Create(ctx context.Context, requestId string, request model.CreateApiKey, actor string, client *elasticsearch.Client) (int, interface{}) {
+	prefix := "apikeys/Create"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	logger.Debugln("Start API Key Create")
+
+	key, keyId, err := newApiKey()
+	if err != nil {
+		logger.Errorln(err.Error())
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error())
+	}
+
+	apiKey := model.ApiKey{
+		KeyId:       keyId,
+		Name:        request.Name,
+		Hash:        auth.HashApiKey(key),
+		Scopes:      request.Scopes,
+		Tenants:     request.Tenants,
+		CreatedBy:   actor,
+		CreatedDate: time.Now().UTC().Format(elastic.DateTimeFormat),
+	}
+	if elasticErr := elastic.AddApiKey(ctx, apiKey, client); elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not create API key [%s]", request.Name))
+	}
+
+	logger.Infof("API key [%s] named [%s] was created by [%s]", keyId, request.Name, actor)
+	body := apiKeyInfo(apiKey)
+	body[param.ApiKey] = key
+	return http.StatusCreated, body
+}
+
+// apiKeyInfo returns everything about the key except its hash
+func apiKeyInfo(apiKey model.ApiKey) map[string]interface{} {
+	info := map[string]interface{}{
+		"id":          apiKey.KeyId,
+		"name":        apiKey.Name,
+		"scopes":      apiKey.Scopes,
+		"tenants":     apiKey.Tenants,
+		"createdBy":   apiKey.CreatedBy,
+		"createdDate": apiKey.CreatedDate,
+	}
+	if apiKey.RevokedDate != "" {
+		info["revokedBy"] = apiKey.RevokedBy
+		info["revokedDate"] = apiKey.RevokedDate
+	}
+	return info
+}
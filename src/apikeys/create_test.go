@@ -0,0 +1,92 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	defer func() { newApiKey = auth.NewApiKey }()
+
+	requestId := "request_id_1"
+	key := "hri_" + keyId + "_secret"
+	keyPath := "/" + elastic.ApiKeysIndex + "/_doc/" + keyId
+	request := model.CreateApiKey{Name: "nightly-ingest", Scopes: []string{auth.HriIntegrator}, Tenants: []string{"tenant1"}}
+
+	testCases := []struct {
+		name         string
+		keyErr       error
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "success",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				RequestQuery: "op_type=create&refresh=true",
+				RequestBody: `{"name":"nightly-ingest","hash":"` + auth.HashApiKey(key) + `","scopes":\["hri_data_integrator"\],` +
+					`"tenants":\["tenant1"\],"createdBy":"admin","createdDate":"` + test.DatePattern + `"}`,
+				ResponseBody: `{"_id":"0123456789abcdef","result":"created"}`,
+			}),
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "key generation error",
+			keyErr:       errors.New("unable to generate an API key: no entropy"),
+			transport:    test.NewFakeTransport(t),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, "unable to generate an API key: no entropy"),
+		},
+		{
+			name: "elastic error",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not create API key [nightly-ingest]: [500] elasticsearch client error: connection refused"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newApiKey = func() (string, string, error) {
+				if tc.keyErr != nil {
+					return "", "", tc.keyErr
+				}
+				return key, keyId, nil
+			}
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := Create(context.Background(), requestId, request, "admin", client)
+			assert.Equal(t, tc.expectedCode, code)
+			if tc.expectedBody != nil {
+				assert.Equal(t, tc.expectedBody, body)
+			} else {
+				info := body.(map[string]interface{})
+				assert.Equal(t, key, info["key"])
+				assert.Equal(t, keyId, info["id"])
+				assert.Equal(t, "admin", info["createdBy"])
+				assert.NotContains(t, info, "hash")
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
@@ -0,0 +1,33 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package apikeys
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+)
+
+// Get returns all the API keys, including the revoked ones, without their hashes
+func Get(ctx context.Context, requestId string, client *elasticsearch.Client) (int, interface{}) {
+	prefix := "apikeys/Get"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	logger.Debugln("Start API Keys Get")
+
+	apiKeys, elasticErr := elastic.GetApiKeys(ctx, client)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			"Could not retrieve API keys")
+	}
+
+	results := make([]map[string]interface{}, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		results = append(results, apiKeyInfo(apiKey))
+	}
+	return http.StatusOK, map[string]interface{}{"results": results}
+}
@@ -0,0 +1,85 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	searchPath := "/" + elastic.ApiKeysIndex + "/_search"
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "success",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				ResponseBody: `{"hits":{"hits":[
+					{"_id":"key1","_source":{"name":"first","hash":"hash1","scopes":["hri_consumer"],"createdBy":"admin","createdDate":"2021-02-24T18:08:36Z"}},
+					{"_id":"key2","_source":{"name":"second","hash":"hash2","scopes":["hri_consumer"],"createdBy":"admin","createdDate":"2021-02-25T18:08:36Z","revokedBy":"admin","revokedDate":"2021-02-26T18:08:36Z"}}
+				]}}`,
+			}),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{"results": []map[string]interface{}{
+				{
+					"id":          "key1",
+					"name":        "first",
+					"scopes":      []string{"hri_consumer"},
+					"tenants":     []string(nil),
+					"createdBy":   "admin",
+					"createdDate": "2021-02-24T18:08:36Z",
+				},
+				{
+					"id":          "key2",
+					"name":        "second",
+					"scopes":      []string{"hri_consumer"},
+					"tenants":     []string(nil),
+					"createdBy":   "admin",
+					"createdDate": "2021-02-25T18:08:36Z",
+					"revokedBy":   "admin",
+					"revokedDate": "2021-02-26T18:08:36Z",
+				},
+			}},
+		},
+		{
+			name: "elastic error",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not retrieve API keys: [500] elasticsearch client error: connection refused"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := Get(context.Background(), requestId, client)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, body)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	msgElasticErr       string = "error getting Elastic client: %s"
+	msgApiKeyNotAllowed string = "API keys can't be used to manage API keys"
+)
+
+type Handler interface {
+	Create(echo.Context) error
+	Get(echo.Context) error
+	Revoke(echo.Context) error
+}
+
+type theHandler struct {
+	config       config.Config
+	jwtValidator auth.Validator // not set when auth is disabled
+	create       func(context.Context, string, model.CreateApiKey, string, *elasticsearch.Client) (int, interface{})
+	get          func(context.Context, string, *elasticsearch.Client) (int, interface{})
+	revoke       func(context.Context, string, model.RevokeApiKey, string, *elasticsearch.Client) (int, interface{})
+}
+
+// NewHandler This struct is designed to make unit testing easier. It has function references for the calls to backend
+// logic and other classes that reach out to external services like JWT token validation.
+func NewHandler(config config.Config) Handler {
+	var jwtValidator auth.Validator
+	if !config.AuthDisabled {
+		jwtValidator = auth.NewValidator(config)
+	}
+	return &theHandler{
+		config:       config,
+		jwtValidator: jwtValidator,
+		create:       Create,
+		get:          Get,
+		revoke:       Revoke,
+	}
+}
+
+func (h *theHandler) Create(c echo.Context) error {
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	prefix := "apikeys/handler/create"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	// bind & validate request body
+	var request model.CreateApiKey
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	actor, errResp := h.getActor(c, requestId)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		msg := fmt.Sprintf(msgElasticErr, err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
+	}
+
+	return c.JSON(h.create(c.Request().Context(), requestId, request, actor, esClient))
+}
+
+func (h *theHandler) Get(c echo.Context) error {
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	prefix := "apikeys/handler/get"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	if _, errResp := h.getActor(c, requestId); errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		msg := fmt.Sprintf(msgElasticErr, err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
+	}
+
+	return c.JSON(h.get(c.Request().Context(), requestId, esClient))
+}
+
+func (h *theHandler) Revoke(c echo.Context) error {
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	prefix := "apikeys/handler/revoke"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	// bind & validate request body
+	var request model.RevokeApiKey
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	actor, errResp := h.getActor(c, requestId)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		msg := fmt.Sprintf(msgElasticErr, err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
+	}
+
+	return c.JSON(h.revoke(c.Request().Context(), requestId, request, actor, esClient))
+}
+
+// getActor returns the subject of the caller, who must have the hri_admin scope. API keys can't be managed with an
+// API key, so a leaked key can't be used to create new ones. When auth is disabled, the caller is a fake admin.
+func (h *theHandler) getActor(c echo.Context, requestId string) (string, *response.ErrorDetailResponse) {
+	if h.config.AuthDisabled {
+		return auth.NoAuthFakeAdmin, nil
+	}
+	if c.Request().Header.Get(auth.ApiKeyHeader) != "" && c.Request().Header.Get(echo.HeaderAuthorization) == "" {
+		logger := logwrapper.GetMyLogger(requestId, "apikeys/handler/getActor")
+		logger.Errorln(msgApiKeyNotAllowed)
+		return "", response.NewErrorDetailResponse(http.StatusForbidden, requestId, msgApiKeyNotAllowed)
+	}
+
+	claims, errResp := h.jwtValidator.GetValidatedAdminClaims(requestId, c.Request())
+	if errResp != nil {
+		return "", errResp
+	}
+	if claims.Subject == "" {
+		logger := logwrapper.GetMyLogger(requestId, "apikeys/handler/getActor")
+		logger.Errorln(auth.MsgSubClaimRequiredInJwt)
+		return "", response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, auth.MsgSubClaimRequiredInJwt)
+	}
+	return claims.Subject, nil
+}
@@ -0,0 +1,282 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apikeys
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const keyId = "0123456789abcdef"
+
+func TestNewHandler(t *testing.T) {
+	testConfig := config.Config{ElasticUrl: "https://fake-elastic.com"}
+
+	handler := NewHandler(testConfig).(*theHandler)
+	assert.Equal(t, testConfig, handler.config)
+	assert.NotNil(t, handler.jwtValidator)
+	// This asserts that they are the same function by memory address
+	assert.Equal(t, reflect.ValueOf(Create), reflect.ValueOf(handler.create))
+	assert.Equal(t, reflect.ValueOf(Get), reflect.ValueOf(handler.get))
+	assert.Equal(t, reflect.ValueOf(Revoke), reflect.ValueOf(handler.revoke))
+
+	testConfig.AuthDisabled = true
+	handler = NewHandler(testConfig).(*theHandler)
+	assert.Nil(t, handler.jwtValidator)
+}
+
+// Fake for the auth.Validator interface; just returns the desired values
+type fakeAuthValidator struct {
+	claims  auth.HriClaims
+	errResp *response.ErrorDetailResponse
+}
+
+func (f fakeAuthValidator) GetValidatedClaims(_ string, _ *http.Request, _ string) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func (f fakeAuthValidator) GetValidatedAdminClaims(_ string, _ *http.Request) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func Test_theHandler_Create(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	adminValidator := fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin, Subject: "admin"}}
+
+	testCases := []struct {
+		name         string
+		config       config.Config
+		validator    auth.Validator
+		headers      map[string]string
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "success",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    adminValidator,
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_data_integrator"],"tenants":["tenant1"]}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"actor":"admin","name":"nightly-ingest","scopes":["hri_data_integrator"],"tenants":["tenant1"]}` + "\n",
+		},
+		{
+			name:         "auth disabled",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com", AuthDisabled: true},
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer"]}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"actor":"NoAuthUnkAdmin","name":"nightly-ingest","scopes":["hri_consumer"],"tenants":null}` + "\n",
+		},
+		{
+			name:         "missing scopes",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    adminValidator,
+			requestBody:  `{"name":"nightly-ingest"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"","errorDescription":"invalid request arguments:\n- scopes (json field in request body) is a required field"}` + "\n",
+		},
+		{
+			name:         "invalid tenant",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    adminValidator,
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer"],"tenants":["Tenant!"]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"","errorDescription":"invalid request arguments:\n- tenants (json field in request body)[0] may only contain lower-case alpha-numeric chars and the following 2 special chars: '-', '_'"}` + "\n",
+		},
+		{
+			name:         "invalid scope",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    adminValidator,
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer","tenant_other"]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"","errorDescription":"invalid request arguments:\n- scopes (json field in request body)[1] must be one of [hri_data_integrator hri_consumer hri_internal hri_admin]"}` + "\n",
+		},
+		{
+			name:         "api key caller",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    adminValidator,
+			headers:      map[string]string{auth.ApiKeyHeader: "hri_0123456789abcdef_secret"},
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer"]}`,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"errorEventId":"","errorDescription":"API keys can't be used to manage API keys"}` + "\n",
+		},
+		{
+			name:   "not an admin",
+			config: config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator: fakeAuthValidator{
+				errResp: response.NewErrorDetailResponse(http.StatusForbidden, "", "Unauthorized admin access"),
+			},
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer"]}`,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"errorEventId":"","errorDescription":"Unauthorized admin access"}` + "\n",
+		},
+		{
+			name:         "missing subject",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin}},
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer"]}`,
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"errorEventId":"","errorDescription":"JWT access token 'sub' claim must be populated."}` + "\n",
+		},
+		{
+			name:         "bad elastic url",
+			config:       config.Config{ElasticUrl: "https:// a bad url.com"},
+			validator:    adminValidator,
+			requestBody:  `{"name":"nightly-ingest","scopes":["hri_consumer"]}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"errorEventId":"","errorDescription":"error getting Elastic client: cannot create client: cannot parse url: parse \"https:// a bad url.com\": invalid character \" \" in host name"}` + "\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := theHandler{
+				config:       tc.config,
+				jwtValidator: tc.validator,
+				create: func(_ context.Context, _ string, request model.CreateApiKey, actor string, _ *elasticsearch.Client) (int, interface{}) {
+					return http.StatusCreated, map[string]interface{}{
+						"name":    request.Name,
+						"scopes":  request.Scopes,
+						"tenants": request.Tenants,
+						"actor":   actor,
+					}
+				},
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/hri/apikeys", strings.NewReader(tc.requestBody))
+			for name, value := range tc.headers {
+				request.Header.Set(name, value)
+			}
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+
+			if assert.NoError(t, handler.Create(context)) {
+				assert.Equal(t, tc.expectedCode, recorder.Code)
+				assert.Equal(t, tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_theHandler_Get(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	testCases := []struct {
+		name         string
+		validator    auth.Validator
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "success",
+			validator:    fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin, Subject: "admin"}},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"results":[]}` + "\n",
+		},
+		{
+			name: "not an admin",
+			validator: fakeAuthValidator{
+				errResp: response.NewErrorDetailResponse(http.StatusForbidden, "", "Unauthorized admin access"),
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"errorEventId":"","errorDescription":"Unauthorized admin access"}` + "\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := theHandler{
+				config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+				jwtValidator: tc.validator,
+				get: func(_ context.Context, _ string, _ *elasticsearch.Client) (int, interface{}) {
+					return http.StatusOK, map[string]interface{}{"results": []interface{}{}}
+				},
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/hri/apikeys", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+
+			if assert.NoError(t, handler.Get(context)) {
+				assert.Equal(t, tc.expectedCode, recorder.Code)
+				assert.Equal(t, tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_theHandler_Revoke(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	testCases := []struct {
+		name         string
+		keyId        string
+		validator    auth.Validator
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "success",
+			keyId:        keyId,
+			validator:    fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin, Subject: "admin"}},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"actor":"admin","id":"0123456789abcdef"}` + "\n",
+		},
+		{
+			name:         "missing key id",
+			validator:    fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin, Subject: "admin"}},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"","errorDescription":"invalid request arguments:\n- keyId (url path parameter) is a required field"}` + "\n",
+		},
+		{
+			name:  "not an admin",
+			keyId: keyId,
+			validator: fakeAuthValidator{
+				errResp: response.NewErrorDetailResponse(http.StatusForbidden, "", "Unauthorized admin access"),
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"errorEventId":"","errorDescription":"Unauthorized admin access"}` + "\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := theHandler{
+				config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+				jwtValidator: tc.validator,
+				revoke: func(_ context.Context, _ string, request model.RevokeApiKey, actor string, _ *elasticsearch.Client) (int, interface{}) {
+					return http.StatusOK, map[string]interface{}{"id": request.KeyId, "actor": actor}
+				},
+			}
+
+			request := httptest.NewRequest(http.MethodPut, "/", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			context.SetPath("/hri/apikeys/:keyId/action/revoke")
+			context.SetParamNames("keyId")
+			context.SetParamValues(tc.keyId)
+
+			if assert.NoError(t, handler.Revoke(context)) {
+				assert.Equal(t, tc.expectedCode, recorder.Code)
+				assert.Equal(t, tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
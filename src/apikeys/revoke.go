@@ -0,0 +1,57 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package apikeys
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+	"time"
+)
+
+const (
+	msgApiKeyNotFound       = "API key [%s] was not found"
+	msgApiKeyAlreadyRevoked = "API key [%s] is already revoked"
+)
+
+// Revoke revokes the API key, which can't be used anymore. Its document is kept as a record of who used and revoked it.
+func Revoke(ctx context.Context, requestId string, request model.RevokeApiKey, actor string, client *elasticsearch.Client) (int, interface{}) {
+	prefix := "apikeys/Revoke"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	logger.Debugln("Start API Key Revoke")
+
+	keyId := request.KeyId
+	apiKey, elasticErr := elastic.GetApiKey(ctx, keyId, client)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not revoke API key [%s]", keyId))
+	}
+	if apiKey == nil {
+		msg := fmt.Sprintf(msgApiKeyNotFound, keyId)
+		logger.Errorln(msg)
+		return http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+	}
+	if apiKey.RevokedDate != "" {
+		msg := fmt.Sprintf(msgApiKeyAlreadyRevoked, keyId)
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+
+	apiKey.RevokedBy = actor
+	apiKey.RevokedDate = time.Now().UTC().Format(elastic.DateTimeFormat)
+	if elasticErr := elastic.RevokeApiKey(ctx, keyId, apiKey.RevokedBy, apiKey.RevokedDate, client); elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not revoke API key [%s]", keyId))
+	}
+
+	logger.Infof("API key [%s] was revoked by [%s]", keyId, actor)
+	return http.StatusOK, apiKeyInfo(*apiKey)
+}
@@ -0,0 +1,104 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestRevoke(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	keyPath := "/" + elastic.ApiKeysIndex + "/_doc/" + keyId
+	updatePath := keyPath + "/_update"
+	request := model.RevokeApiKey{KeyId: keyId}
+	activeKeyCall := test.ElasticCall{
+		ResponseBody: `{"_id":"0123456789abcdef","found":true,"_source":{"name":"nightly-ingest","hash":"hash","scopes":["hri_consumer"],"createdBy":"admin","createdDate":"2021-02-24T18:08:36Z"}}`,
+	}
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "success",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, activeKeyCall).AddCall(updatePath, test.ElasticCall{
+				RequestQuery: "refresh=true",
+				RequestBody:  `{"doc":{"revokedBy":"security-admin","revokedDate":"` + test.DatePattern + `"}}`,
+				ResponseBody: `{"_id":"0123456789abcdef","result":"updated"}`,
+			}),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "not found",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusNotFound,
+				ResponseBody:       `{"_id":"0123456789abcdef","found":false}`,
+			}),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgApiKeyNotFound, keyId)),
+		},
+		{
+			name: "already revoked",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseBody: `{"_id":"0123456789abcdef","found":true,"_source":{"name":"nightly-ingest","hash":"hash","revokedBy":"admin","revokedDate":"2021-02-26T18:08:36Z"}}`,
+			}),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgApiKeyAlreadyRevoked, keyId)),
+		},
+		{
+			name: "get error",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not revoke API key [0123456789abcdef]: [500] elasticsearch client error: connection refused"),
+		},
+		{
+			name: "update error",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, activeKeyCall).AddCall(updatePath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not revoke API key [0123456789abcdef]: [500] elasticsearch client error: connection refused"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := Revoke(context.Background(), requestId, request, "security-admin", client)
+			assert.Equal(t, tc.expectedCode, code)
+			if tc.expectedBody != nil {
+				assert.Equal(t, tc.expectedBody, body)
+			} else {
+				info := body.(map[string]interface{})
+				assert.Equal(t, keyId, info["id"])
+				assert.Equal(t, "security-admin", info["revokedBy"])
+				assert.NotEmpty(t, info["revokedDate"])
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
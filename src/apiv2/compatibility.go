@@ -0,0 +1,52 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// V1Compatibility keeps the v1 routes answering authorization failures with a 401, like they did before they were
+// fixed to be 403s. A 403 is only sent by v2, v1 never sent one.
+func V1Compatibility() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if IsV2(c.Request().URL.Path) {
+				return next(c)
+			}
+			res := c.Response()
+			res.Writer = &v1StatusWriter{ResponseWriter: res.Writer}
+			err := next(c)
+			// for the middleware that reports the status
+			if res.Status == http.StatusForbidden {
+				res.Status = http.StatusUnauthorized
+			}
+			if httpErr, ok := err.(*echo.HTTPError); ok && httpErr.Code == http.StatusForbidden {
+				return echo.NewHTTPError(http.StatusUnauthorized, httpErr.Message).SetInternal(httpErr.Internal)
+			}
+			return err
+		}
+	}
+}
+
+type v1StatusWriter struct {
+	http.ResponseWriter
+}
+
+func (w *v1StatusWriter) WriteHeader(code int) {
+	if code == http.StatusForbidden {
+		code = http.StatusUnauthorized
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *v1StatusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
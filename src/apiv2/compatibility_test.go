@@ -0,0 +1,76 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestV1Compatibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		handler      echo.HandlerFunc
+		expectedCode int
+	}{
+		{
+			name:         "v1 forbidden",
+			path:         "/hri/tenants/tenant1/batches",
+			handler:      respond(http.StatusForbidden, response.NewErrorDetail(requestId, "Unauthorized tenant access")),
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "v1 forbidden error",
+			path:         "/hri/tenants/tenant1/batches",
+			handler:      func(c echo.Context) error { return echo.ErrForbidden },
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "v1 success",
+			path:         "/hri/tenants/tenant1/batches",
+			handler:      respond(http.StatusOK, map[string]interface{}{}),
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "v2 forbidden",
+			path:         "/hri/v2/tenants/tenant1/batches",
+			handler:      respond(http.StatusForbidden, response.NewErrorDetail(requestId, "Unauthorized tenant access")),
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			var status int
+			e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					err := next(c)
+					// like the request logger and the metrics
+					if err != nil {
+						c.Error(err)
+					}
+					status = c.Response().Status
+					return nil
+				}
+			})
+			e.Use(V1Compatibility())
+			e.GET("/hri/tenants/:tenantId/batches", tc.handler)
+			e.GET("/hri/v2/tenants/:tenantId/batches", tc.handler)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedCode, status)
+		})
+	}
+}
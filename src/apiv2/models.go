@@ -0,0 +1,141 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+)
+
+// The response bodies of the v2 routes. The v1 handlers' bodies are decoded into them, which drops the fields v2
+// removed, like the batches' deprecated recordCount, and gives the numbers their type.
+
+type TenantRef struct {
+	Id string `json:"id"`
+}
+
+type TenantList struct {
+	Results []TenantRef `json:"results"`
+}
+
+// Tenant has the statistics of the tenant's index
+type Tenant struct {
+	Index     string `json:"index"`
+	Health    string `json:"health"`
+	Status    string `json:"status"`
+	DocsCount string `json:"docs.count"`
+}
+
+type TenantId struct {
+	TenantId string `json:"tenantId"`
+}
+
+type TenantConfig struct {
+	TenantId string `json:"tenantId"`
+	model.TenantConfig
+}
+
+type TenantStatus struct {
+	TenantId    string `json:"tenantId"`
+	Status      string `json:"status"`
+	SuspendDate string `json:"suspendDate,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+type TenantDeletion struct {
+	TenantId           string   `json:"tenantId"`
+	Index              string   `json:"index"`
+	DocCount           int      `json:"docCount"`
+	NonTerminalBatches int      `json:"nonTerminalBatches"`
+	LegalHold          bool     `json:"legalHold"`
+	LegalHoldBatches   int      `json:"legalHoldBatches"`
+	Topics             []string `json:"topics"`
+}
+
+type PurgeResult struct {
+	TenantId      string `json:"tenantId"`
+	OlderThanDays int    `json:"olderThanDays"`
+	Archived      int    `json:"archived"`
+}
+
+type ImportCounts struct {
+	Created  int `json:"created"`
+	Replaced int `json:"replaced"`
+	Skipped  int `json:"skipped"`
+}
+
+type ImportResult struct {
+	TenantId string        `json:"tenantId"`
+	Config   string        `json:"config,omitempty"`
+	Streams  *ImportCounts `json:"streams,omitempty"`
+	Batches  *ImportCounts `json:"batches,omitempty"`
+}
+
+type BatchId struct {
+	Id string `json:"id"`
+}
+
+type Batch struct {
+	Id                  string                 `json:"id"`
+	Name                string                 `json:"name"`
+	IntegratorId        string                 `json:"integratorId,omitempty"`
+	Topic               string                 `json:"topic"`
+	DataType            string                 `json:"dataType"`
+	Status              string                 `json:"status"`
+	StartDate           string                 `json:"startDate"`
+	EndDate             string                 `json:"endDate,omitempty"`
+	ExpectedRecordCount *int                   `json:"expectedRecordCount,omitempty"`
+	ActualRecordCount   *int                   `json:"actualRecordCount,omitempty"`
+	InvalidRecordCount  *int                   `json:"invalidRecordCount,omitempty"`
+	InvalidThreshold    int                    `json:"invalidThreshold"`
+	FailureMessage      string                 `json:"failureMessage,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	Archived            bool                   `json:"archived,omitempty"`
+	LegalHold           *model.LegalHold       `json:"legalHold,omitempty"`
+}
+
+// BatchList's total is an integer, v1 returns Elastic's float
+type BatchList struct {
+	Total   int     `json:"total"`
+	Results []Batch `json:"results"`
+}
+
+type LegalHoldResult struct {
+	TenantId  string           `json:"tenantId"`
+	Id        string           `json:"id,omitempty"`
+	LegalHold *model.LegalHold `json:"legalHold,omitempty"`
+}
+
+type ApiKey struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Key         string   `json:"key,omitempty"`
+	Scopes      []string `json:"scopes"`
+	Tenants     []string `json:"tenants"`
+	CreatedBy   string   `json:"createdBy"`
+	CreatedDate string   `json:"createdDate"`
+	RevokedBy   string   `json:"revokedBy,omitempty"`
+	RevokedDate string   `json:"revokedDate,omitempty"`
+}
+
+type ApiKeyList struct {
+	Results []ApiKey `json:"results"`
+}
+
+type StreamId struct {
+	Id string `json:"id"`
+}
+
+type StreamList struct {
+	Results []StreamId `json:"results"`
+}
+
+type StreamLag struct {
+	Id      string                   `json:"id"`
+	Topics  []string                 `json:"topics"`
+	Results []kafka.ConsumerGroupLag `json:"results"`
+}
@@ -0,0 +1,64 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// ErrorHandler answers the errors of v2 requests with problems, e.g. the 404 of a path that isn't a route. The
+// errors of the other requests are handled by next.
+func ErrorHandler(next echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed || !IsV2(c.Request().URL.Path) {
+			next(err, c)
+			return
+		}
+		// like Echo, the internal errors' messages aren't sent
+		status, detail := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			status, detail = httpErr.Code, fmt.Sprint(httpErr.Message)
+		}
+		if err := Problem(c, status, detail); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
+
+// Problem sends a problem with the status and detail
+func Problem(c echo.Context, status int, detail string) error {
+	return c.Blob(status, response.MIMEApplicationProblemJSON, problemBody(c, status, detail))
+}
+
+// problemBody returns the JSON of the problem, and sets its content type
+func problemBody(c echo.Context, status int, detail string) []byte {
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	data, _ := json.Marshal(response.NewProblem(status, requestId, detail, c.Request().URL.Path))
+	c.Response().Header().Set(echo.HeaderContentType, response.MIMEApplicationProblemJSON)
+	return data
+}
+
+// errorDescription returns the description of a v1 error body, which is an ErrorDetail, or Echo's message
+func errorDescription(body []byte) string {
+	var v1Error struct {
+		ErrorDescription string      `json:"errorDescription"`
+		Message          interface{} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &v1Error); err == nil {
+		if v1Error.ErrorDescription != "" {
+			return v1Error.ErrorDescription
+		} else if v1Error.Message != nil {
+			return fmt.Sprint(v1Error.Message)
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,125 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		path                string
+		err                 error
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "not found",
+			path:                "/hri/v2/missing",
+			err:                 echo.ErrNotFound,
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Not Found","status":404,"detail":"Not Found","instance":"/hri/v2/missing","errorEventId":"requestId"}`,
+		},
+		{
+			name:                "http error",
+			path:                "/hri/v2/tenants",
+			err:                 echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded"),
+			expectedCode:        http.StatusTooManyRequests,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"rate limit exceeded","instance":"/hri/v2/tenants","errorEventId":"requestId"}`,
+		},
+		{
+			name:                "internal error",
+			path:                "/hri/v2/tenants",
+			err:                 errors.New("connection refused"),
+			expectedCode:        http.StatusInternalServerError,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Internal Server Error","instance":"/hri/v2/tenants","errorEventId":"requestId"}`,
+		},
+		{
+			name:                "v1 error",
+			path:                "/hri/missing",
+			err:                 echo.ErrNotFound,
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: echo.MIMEApplicationJSONCharsetUTF8,
+			expectedBody:        `{"message":"Not Found"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, tc.path, nil), rec)
+			c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+
+			ErrorHandler(e.DefaultHTTPErrorHandler)(tc.err, c)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+			assert.JSONEq(t, tc.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestErrorHandlerCommitted(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/hri/v2/tenants", nil), rec)
+	c.NoContent(http.StatusOK)
+
+	called := false
+	ErrorHandler(func(err error, c echo.Context) {
+		called = true
+	})(echo.ErrNotFound, c)
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestErrorDescription(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "error detail",
+			body:     `{"errorEventId":"requestId","errorDescription":"Unauthorized tenant access"}`,
+			expected: "Unauthorized tenant access",
+		},
+		{
+			name:     "echo message",
+			body:     `{"message":"missing or malformed jwt"}`,
+			expected: "missing or malformed jwt",
+		},
+		{
+			name:     "not JSON",
+			body:     "Service Unavailable\n",
+			expected: "Service Unavailable",
+		},
+		{
+			name:     "JSON without a description",
+			body:     `{"error":"unknown"}`,
+			expected: `{"error":"unknown"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, errorDescription([]byte(tc.body)))
+		})
+	}
+}
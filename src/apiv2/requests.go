@@ -0,0 +1,56 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	msgRecordCountRemoved          = "recordCount was removed in v2, use expectedRecordCount"
+	msgExpectedRecordCountRequired = "expectedRecordCount is required"
+)
+
+// SendComplete requires expectedRecordCount, the v1 handler accepts the deprecated recordCount in its place
+func SendComplete(handler echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+		var logger = logwrapper.GetMyLogger(requestId, "apiv2/sendComplete")
+
+		request := c.Request()
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			logger.Errorln(err.Error())
+			return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+		}
+		// the handler binds the body
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// a body that isn't a JSON object is rejected by the handler
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) == nil {
+			msg := ""
+			if _, ok := fields[param.RecordCount]; ok {
+				msg = msgRecordCountRemoved
+			} else if _, ok := fields[param.ExpectedRecordCount]; !ok {
+				msg = msgExpectedRecordCountRequired
+			}
+			if msg != "" {
+				logger.Errorln(msg)
+				return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
+			}
+		}
+		return handler(c)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"bytes"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendComplete(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		expectedCode     int
+		expectedBody     string
+		handlerNotCalled bool
+	}{
+		{
+			name:         "expected record count",
+			body:         `{"expectedRecordCount":10}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:             "deprecated record count",
+			body:             `{"recordCount":10}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"recordCount was removed in v2, use expectedRecordCount"}`,
+			handlerNotCalled: true,
+		},
+		{
+			name:             "both record counts",
+			body:             `{"expectedRecordCount":10,"recordCount":10}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"recordCount was removed in v2, use expectedRecordCount"}`,
+			handlerNotCalled: true,
+		},
+		{
+			name:             "missing record count",
+			body:             `{"metadata":{}}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"expectedRecordCount is required"}`,
+			handlerNotCalled: true,
+		},
+		{
+			name:         "not an object",
+			body:         `[]`,
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	logwrapper.Initialize("error", new(bytes.Buffer))
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPut, "/hri/v2/tenants/tenant1/batches/batch1/action/sendComplete",
+				strings.NewReader(tc.body)), rec)
+			c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+
+			var handlerBody string
+			err := SendComplete(func(c echo.Context) error {
+				body, _ := ioutil.ReadAll(c.Request().Body)
+				handlerBody = string(body)
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			if tc.handlerNotCalled {
+				assert.Empty(t, handlerBody)
+				assert.JSONEq(t, tc.expectedBody, rec.Body.String())
+			} else {
+				// the handler reads the whole body
+				assert.Equal(t, tc.body, handlerBody)
+			}
+		})
+	}
+}
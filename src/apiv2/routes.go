@@ -0,0 +1,144 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Prefix of the v2 routes. The v1 routes under /hri keep their behavior.
+const Prefix = "/hri/v2"
+
+const msgConversionErr = "unable to convert the response to the v2 API: %s"
+
+// Routes registers the v2 routes. They're served by the v1 handlers, adapted to send typed bodies and to answer the
+// errors with RFC 7807 problems.
+type Routes struct {
+	group *echo.Group
+}
+
+func NewRoutes(e *echo.Echo) *Routes {
+	return &Routes{group: e.Group(Prefix)}
+}
+
+// GET registers a route whose successful responses are converted to the type of body. With a nil body, they're
+// sent as they are, e.g. the tenant exports.
+func (r *Routes) GET(path string, handler echo.HandlerFunc, body interface{}) *echo.Route {
+	return r.group.GET(path, Adapt(handler, body))
+}
+
+func (r *Routes) POST(path string, handler echo.HandlerFunc, body interface{}) *echo.Route {
+	return r.group.POST(path, Adapt(handler, body))
+}
+
+func (r *Routes) PUT(path string, handler echo.HandlerFunc, body interface{}) *echo.Route {
+	return r.group.PUT(path, Adapt(handler, body))
+}
+
+func (r *Routes) DELETE(path string, handler echo.HandlerFunc, body interface{}) *echo.Route {
+	return r.group.DELETE(path, Adapt(handler, body))
+}
+
+// IsV2 returns whether the request path is one of the v2 API
+func IsV2(path string) bool {
+	return path == Prefix || strings.HasPrefix(path, Prefix+"/")
+}
+
+// Adapt converts the responses of a v1 handler. Error responses become problems, and the successful JSON bodies are
+// decoded into the type of body, then sent again.
+func Adapt(handler echo.HandlerFunc, body interface{}) echo.HandlerFunc {
+	var bodyType reflect.Type
+	if body != nil {
+		bodyType = reflect.TypeOf(body)
+	}
+	return func(c echo.Context) error {
+		res := c.Response()
+		writer := &capturingWriter{ResponseWriter: res.Writer, convert: bodyType != nil}
+		res.Writer = writer
+		err := handler(c)
+		res.Writer = writer.ResponseWriter
+
+		if !writer.capturing {
+			// sent as it is, or the error handler answers the error
+			return err
+		}
+		if writer.status >= http.StatusBadRequest {
+			return write(c, writer.status, problemBody(c, writer.status, errorDescription(writer.body.Bytes())))
+		}
+		if writer.body.Len() == 0 {
+			return write(c, writer.status, nil)
+		}
+
+		typed := reflect.New(bodyType).Interface()
+		data, convertErr := convert(writer.body.Bytes(), typed)
+		if convertErr != nil {
+			requestId := res.Header().Get(echo.HeaderXRequestID)
+			msg := fmt.Sprintf(msgConversionErr, convertErr.Error())
+			logwrapper.GetMyLogger(requestId, "apiv2/adapt").Errorln(msg)
+			return write(c, http.StatusInternalServerError, problemBody(c, http.StatusInternalServerError, msg))
+		}
+		res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+		return write(c, writer.status, data)
+	}
+}
+
+func convert(body []byte, typed interface{}) ([]byte, error) {
+	if err := json.Unmarshal(body, typed); err != nil {
+		return nil, err
+	}
+	return json.Marshal(typed)
+}
+
+// write sends the converted response. The handler already committed Echo's response, so it's written to the
+// underlying writer, and the status is updated for the middleware.
+func write(c echo.Context, status int, data []byte) error {
+	res := c.Response()
+	res.Header().Del(echo.HeaderContentLength)
+	res.Writer.WriteHeader(status)
+	res.Status = status
+	n, err := res.Writer.Write(data)
+	res.Size = int64(n)
+	return err
+}
+
+// capturingWriter keeps the bodies the adapter converts instead of sending them: the errors, and the successful
+// responses when they're converted. The others are streamed.
+type capturingWriter struct {
+	http.ResponseWriter
+	convert   bool
+	status    int
+	capturing bool
+	body      bytes.Buffer
+}
+
+func (w *capturingWriter) WriteHeader(code int) {
+	w.status = code
+	w.capturing = code >= http.StatusBadRequest || (w.convert && code < http.StatusMultipleChoices)
+	if !w.capturing {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	if w.capturing {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok && !w.capturing {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package apiv2
+
+import (
+	"bytes"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const requestId = "requestId"
+
+func TestAdapt(t *testing.T) {
+	tests := []struct {
+		name                string
+		handler             echo.HandlerFunc
+		body                interface{}
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+		expectedLog         string
+	}{
+		{
+			name: "batches are typed",
+			handler: respond(http.StatusOK, map[string]interface{}{"total": 1.0, "results": []interface{}{
+				map[string]interface{}{"id": "batch1", "name": "batch1", "status": "started", "expectedRecordCount": 10,
+					"recordCount": 10, "invalidThreshold": -1},
+			}}),
+			body:                BatchList{},
+			expectedCode:        http.StatusOK,
+			expectedContentType: echo.MIMEApplicationJSONCharsetUTF8,
+			expectedBody:        `{"total":1,"results":[{"id":"batch1","name":"batch1","topic":"","dataType":"","status":"started","startDate":"","expectedRecordCount":10,"invalidThreshold":-1}]}`,
+		},
+		{
+			name:                "created",
+			handler:             respond(http.StatusCreated, map[string]interface{}{"id": "batch1"}),
+			body:                BatchId{},
+			expectedCode:        http.StatusCreated,
+			expectedContentType: echo.MIMEApplicationJSONCharsetUTF8,
+			expectedBody:        `{"id":"batch1"}`,
+		},
+		{
+			name: "without a body",
+			handler: func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			},
+			body:         BatchId{},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "sent as it is without a type",
+			handler: func(c echo.Context) error {
+				return c.Blob(http.StatusOK, "application/x-ndjson", []byte("{\"recordCount\":1}\n"))
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        `{"recordCount":1}`,
+		},
+		{
+			name:                "error detail",
+			handler:             respond(http.StatusForbidden, response.NewErrorDetail(requestId, "Unauthorized tenant access")),
+			body:                BatchId{},
+			expectedCode:        http.StatusForbidden,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Forbidden","status":403,"detail":"Unauthorized tenant access","instance":"/hri/v2/tenants","errorEventId":"requestId"}`,
+		},
+		{
+			name:                "error without a type",
+			handler:             respond(http.StatusNotFound, response.NewErrorDetail(requestId, "not found")),
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","instance":"/hri/v2/tenants","errorEventId":"requestId"}`,
+		},
+		{
+			name:                "body that doesn't convert",
+			handler:             respond(http.StatusOK, map[string]interface{}{"total": "one"}),
+			body:                BatchList{},
+			expectedCode:        http.StatusInternalServerError,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"unable to convert the response to the v2 API: json: cannot unmarshal string into Go struct field BatchList.total of type int","instance":"/hri/v2/tenants","errorEventId":"requestId"}`,
+			expectedLog:         "unable to convert the response to the v2 API",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := new(bytes.Buffer)
+			logwrapper.Initialize("info", logs)
+
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/hri/v2/tenants", nil), rec)
+			c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+
+			err := Adapt(tc.handler, tc.body)(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedCode, c.Response().Status)
+			assert.Equal(t, tc.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, tc.expectedBody, string(bytes.TrimSpace(rec.Body.Bytes())))
+			if tc.expectedLog == "" {
+				assert.Empty(t, logs.String())
+			} else {
+				assert.Contains(t, logs.String(), tc.expectedLog)
+			}
+		})
+	}
+}
+
+func TestAdaptReturnsErrors(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/hri/v2/tenants", nil), rec)
+
+	err := Adapt(func(c echo.Context) error {
+		return echo.ErrUnauthorized
+	}, TenantList{})(c)
+
+	// answered by the error handler
+	assert.Equal(t, echo.ErrUnauthorized, err)
+	assert.False(t, c.Response().Committed)
+}
+
+func TestRoutes(t *testing.T) {
+	e := echo.New()
+	routes := NewRoutes(e)
+	handler := respond(http.StatusOK, map[string]interface{}{})
+
+	tests := []struct {
+		method string
+		route  *echo.Route
+	}{
+		{method: http.MethodGet, route: routes.GET("/tenants", handler, TenantList{})},
+		{method: http.MethodPost, route: routes.POST("/tenants/:tenantId", handler, TenantId{})},
+		{method: http.MethodPut, route: routes.PUT("/tenants/:tenantId/config", handler, TenantConfig{})},
+		{method: http.MethodDelete, route: routes.DELETE("/tenants/:tenantId", handler, nil)},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.method, tc.route.Method)
+		assert.True(t, IsV2(tc.route.Path), tc.route.Path)
+	}
+	assert.Equal(t, len(tests), len(e.Routes()))
+}
+
+func TestIsV2(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "/hri/v2", expected: true},
+		{path: "/hri/v2/tenants", expected: true},
+		{path: "/hri/tenants", expected: false},
+		{path: "/hri/v2tenants", expected: false},
+		{path: "/alive", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsV2(tc.path))
+		})
+	}
+}
+
+func respond(code int, body interface{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(code, body)
+	}
+}
@@ -9,14 +9,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/param/esparam"
-	"strings"
 )
 
 const (
-	inputSuffix           string = ".in"
-	notificationSuffix    string = ".notification"
 	statusErrPrefix       string = "Error extracting Batch Status: %s"
 	statusErrMissingField string = "'status' field missing"
 	statusErrInvalidValue string = "Invalid 'status' value: "
@@ -66,8 +64,9 @@ func NormalizeBatchRecordCountValues(batch map[string]interface{}) map[string]in
 
 // InputTopicToNotificationTopic extracts a Notification topic from an inputTopic.
 // Notification topic will be inferred from inputTopic using the following logic:
+// If inputTopic follows the topic naming convention, then its topic type will be replaced with the notification type,
 // If inputTopic ends with the ".in" suffix, then the suffix will be replaced with ".notification",
-// If inputTopic does not end with the ".in" suffix, then ".notification" will just be appended to inputTopic
+// Otherwise ".notification" will just be appended to inputTopic
 func InputTopicToNotificationTopic(inputTopic string) string {
-	return strings.TrimSuffix(inputTopic, inputSuffix) + notificationSuffix
+	return eventstreams.GetTopicNaming().NotificationTopicName(inputTopic)
 }
@@ -0,0 +1,105 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/elastic/go-elasticsearch/v7"
+	"strings"
+)
+
+const (
+	allBatchIndices = "*-batches"
+	// upper bounds of the aggregation buckets, well above the number of tenants and batch statuses
+	maxCountedTenants  = 10000
+	maxCountedStatuses = 100
+)
+
+// NewBatchCounter returns a metrics.BatchCounter that counts the batches in Elasticsearch
+func NewBatchCounter(config config.Config) metrics.BatchCounter {
+	return func() (map[string]map[string]int, error) {
+		esClient, err := elastic.ClientFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return CountByStatus(esClient)
+	}
+}
+
+// CountByStatus returns the number of batches of every tenant by status, using a single aggregation over all the
+// tenants' batch indices
+func CountByStatus(esClient *elasticsearch.Client) (map[string]map[string]int, error) {
+	query := map[string]interface{}{
+		"aggs": map[string]interface{}{
+			"tenants": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "_index", "size": maxCountedTenants},
+				"aggs": map[string]interface{}{
+					"statuses": map[string]interface{}{
+						"terms": map[string]interface{}{"field": param.Status, "size": maxCountedStatuses},
+					},
+				},
+			},
+		},
+	}
+	buf, err := elastic.EncodeQueryBody(query)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := esClient.Search(
+		esClient.Search.WithContext(context.Background()),
+		esClient.Search.WithIndex(allBatchIndices),
+		esClient.Search.WithBody(buf),
+		esClient.Search.WithSize(0),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return nil, elasticErr
+	}
+
+	tenantBuckets, err := aggregationBuckets(body, "aggregations", "tenants")
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]map[string]int, len(tenantBuckets))
+	for _, tenantBucket := range tenantBuckets {
+		index, _ := tenantBucket["key"].(string)
+		statusBuckets, err := aggregationBuckets(tenantBucket, "statuses")
+		if err != nil {
+			return nil, err
+		}
+
+		statuses := make(map[string]int, len(statusBuckets))
+		for _, statusBucket := range statusBuckets {
+			batchStatus, _ := statusBucket["key"].(string)
+			docCount, _ := statusBucket["doc_count"].(float64)
+			statuses[batchStatus] = int(docCount)
+		}
+		counts[strings.TrimSuffix(index, "-batches")] = statuses
+	}
+	return counts, nil
+}
+
+// aggregationBuckets returns the buckets of the aggregation at the path
+func aggregationBuckets(body map[string]interface{}, path ...string) ([]map[string]interface{}, error) {
+	aggregation, err := param.ExtractValues(body, path...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the batch counts in the Elastic response: %w", err)
+	}
+	rawBuckets, _ := aggregation["buckets"].([]interface{})
+	buckets := make([]map[string]interface{}, 0, len(rawBuckets))
+	for _, rawBucket := range rawBuckets {
+		if bucket, isMap := rawBucket.(map[string]interface{}); isMap {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets, nil
+}
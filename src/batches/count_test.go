@@ -0,0 +1,92 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestCountByStatus(t *testing.T) {
+	const (
+		countPath = "/*-batches/_search"
+		countBody = `{"aggs":{"tenants":{"aggs":{"statuses":{"terms":{"field":"status","size":100}}},"terms":{"field":"_index","size":10000}}}}` + "\n"
+	)
+
+	testCases := []struct {
+		name           string
+		transport      *test.FakeTransport
+		expectedCounts map[string]map[string]int
+		expectedErr    string
+	}{
+		{
+			name: "counts batches",
+			transport: test.NewFakeTransport(t).AddCall(countPath, test.ElasticCall{
+				RequestQuery: "size=0",
+				RequestBody:  countBody,
+				ResponseBody: `{"aggregations":{"tenants":{"buckets":[
+					{"key":"tenant1-batches","doc_count":3,"statuses":{"buckets":[{"key":"started","doc_count":2},{"key":"completed","doc_count":1}]}},
+					{"key":"tenant2-batches","doc_count":1,"statuses":{"buckets":[{"key":"failed","doc_count":1}]}}]}}}`,
+			}),
+			expectedCounts: map[string]map[string]int{
+				"tenant1": {"started": 2, "completed": 1},
+				"tenant2": {"failed": 1},
+			},
+		},
+		{
+			name: "no batches",
+			transport: test.NewFakeTransport(t).AddCall(countPath, test.ElasticCall{
+				ResponseBody: `{"aggregations":{"tenants":{"buckets":[]}}}`,
+			}),
+			expectedCounts: map[string]map[string]int{},
+		},
+		{
+			name: "missing aggregation",
+			transport: test.NewFakeTransport(t).AddCall(countPath, test.ElasticCall{
+				ResponseBody: `{"hits":{"hits":[]}}`,
+			}),
+			expectedErr: "error parsing the batch counts in the Elastic response: " +
+				"error extracting the aggregations section of the JSON",
+		},
+		{
+			name: "elastic error",
+			transport: test.NewFakeTransport(t).AddCall(countPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErr: "elasticsearch client error: connection refused",
+		},
+		{
+			name: "bad response",
+			transport: test.NewFakeTransport(t).AddCall(countPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusBadRequest,
+				ResponseBody:       `{"error":{"type":"illegal_argument_exception","reason":"bad aggregation"}}`,
+			}),
+			expectedErr: "illegal_argument_exception: bad aggregation",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			esClient, err := elastic.ClientFromTransport(tc.transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counts, err := CountByStatus(esClient)
+			tc.transport.VerifyCalls()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedCounts, counts)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package batches
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
@@ -14,17 +15,32 @@ import (
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/param/esparam"
+	"github.com/Alvearie/hri-mgmt-api/common/ratelimit"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const (
+	msgDataTypeNotAllowed string = "dataType [%s] is not allowed for tenant [%s]; allowed dataTypes are: %s"
+	msgActiveBatchQuota   string = "Tenant [%s] has reached its quota of %d active batches"
+
+	msgIntegratorStartedBatchQuota string = "Integrator [%s] has reached its quota of %d started batches"
+	msgDailyBatchQuota             string = "Tenant [%s] has reached its quota of %d batches per day"
+)
+
+// the active and started batch quotas free up as batches are completed, which usually takes a few minutes
+const openBatchQuotaRetryAfter = 5 * time.Minute
+
 func Create(
+	ctx context.Context,
 	requestId string,
 	batch model.CreateBatch,
 	claims auth.HriClaims,
@@ -32,14 +48,14 @@ func Create(
 	kafkaWriter kafka.Writer) (int, interface{}) {
 
 	prefix := "batches/create"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, batch.TenantId)
 	logger.Debugln("Start Batch Create")
 
-	// validate that caller has sufficient permissions
-	if !claims.HasScope(auth.HriIntegrator) {
+	// validate that the authorization policy allows the caller to create batches
+	if !auth.Authorize(claims, batch.TenantId, auth.ActionCreateBatch).Allowed {
 		msg := fmt.Sprintf(auth.MsgIntegratorRoleRequired, "create")
 		logger.Errorln(msg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, msg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, msg)
 	}
 
 	// validate that the Subject claim (integrator ID) is not missing
@@ -50,10 +66,11 @@ func Create(
 	}
 
 	var subject = claims.Subject
-	return create(requestId, batch, subject, esClient, kafkaWriter, logger)
+	return create(ctx, requestId, batch, subject, esClient, kafkaWriter, logger)
 }
 
 func CreateNoAuth(
+	ctx context.Context,
 	requestId string,
 	batch model.CreateBatch,
 	_ auth.HriClaims,
@@ -61,14 +78,15 @@ func CreateNoAuth(
 	kafkaWriter kafka.Writer) (int, interface{}) {
 
 	var prefix = "batches/create_no_auth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, batch.TenantId)
 	logger.Debugln("Start Batch Create (Without Auth)")
 
 	var integratorId = auth.NoAuthFakeIntegrator
-	return create(requestId, batch, integratorId, esClient, kafkaWriter, logger)
+	return create(ctx, requestId, batch, integratorId, esClient, kafkaWriter, logger)
 }
 
 func create(
+	ctx context.Context,
 	requestId string,
 	batch model.CreateBatch,
 	integratorId string,
@@ -76,6 +94,15 @@ func create(
 	kafkaWriter kafka.Writer,
 	logger logrus.FieldLogger) (int, interface{}) {
 
+	tenantConfig, errResp := getActiveTenantConfig(ctx, requestId, batch.TenantId, "Batch creation", esClient, logger)
+	if errResp != nil {
+		return errResp.Code, errResp.Body
+	}
+	batch.TenantConfig = tenantConfig
+	if code, errBody := checkTenantConfig(ctx, requestId, batch, integratorId, esClient, logger); errBody != nil {
+		return code, errBody
+	}
+
 	batchInfo := buildBatchInfo(batch, integratorId)
 	jsonBatchInfo, err := json.Marshal(batchInfo)
 	if err != nil {
@@ -88,6 +115,7 @@ func create(
 	indexRes, err := esClient.Index(
 		elastic.IndexFromTenantId(batch.TenantId),
 		bytes.NewReader(jsonBatchInfo),
+		esClient.Index.WithContext(ctx),
 	)
 
 	// parse the response
@@ -104,13 +132,14 @@ func create(
 	logger.Debugf("Sending Batch Info to Notification Topic")
 
 	notificationTopic := InputTopicToNotificationTopic(batch.Topic)
-	err = kafkaWriter.Write(notificationTopic, batchId, batchInfo)
+	err = kafkaWriter.Write(ctx, notificationTopic, batchId, batchInfo)
 	if err != nil {
+		metrics.NotificationPublishFailed(metrics.BatchNotification)
 		logger.Errorf("Unable to publish to topic [%s] about new batch [%s]. %s",
 			notificationTopic, batchId, err.Error())
 
 		// cleanup the elastic document
-		esClient.Delete(elastic.IndexFromTenantId(batch.TenantId), batchId)
+		esClient.Delete(elastic.IndexFromTenantId(batch.TenantId), batchId, esClient.Delete.WithContext(ctx))
 		return http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error())
 	}
 
@@ -133,7 +162,11 @@ func buildBatchInfo(batch model.CreateBatch, integrator string) map[string]inter
 	}
 
 	if batch.InvalidThreshold == 0 {
-		info[param.InvalidThreshold] = -1
+		if batch.TenantConfig.DefaultInvalidThreshold != nil {
+			info[param.InvalidThreshold] = *batch.TenantConfig.DefaultInvalidThreshold
+		} else {
+			info[param.InvalidThreshold] = -1
+		}
 	}
 
 	if batch.Metadata != nil {
@@ -142,3 +175,130 @@ func buildBatchInfo(batch model.CreateBatch, integrator string) map[string]inter
 
 	return info
 }
+
+// checkTenantConfig rejects batches with a dataType the tenant doesn't allow, or that would exceed one of the tenant's
+// quotas: its active batches, the integrator's started batches, or the batches created today. A quota is rejected
+// with a ratelimit.Rejection, which says when to retry: after a fixed backoff for the open batches, at the next UTC
+// midnight for the batches per day.
+func checkTenantConfig(ctx context.Context, requestId string, batch model.CreateBatch, integratorId string,
+	esClient *elasticsearch.Client, logger logrus.FieldLogger) (int, interface{}) {
+
+	tenantConfig := batch.TenantConfig
+	if len(tenantConfig.AllowedDataTypes) > 0 && !contains(tenantConfig.AllowedDataTypes, batch.DataType) {
+		msg := fmt.Sprintf(msgDataTypeNotAllowed, batch.DataType, batch.TenantId, strings.Join(tenantConfig.AllowedDataTypes, ", "))
+		logger.Errorln(msg)
+		return http.StatusBadRequest, response.NewErrorDetail(requestId, msg)
+	}
+
+	quota := tenantConfig.Quota
+	if quota == nil {
+		return http.StatusOK, nil
+	}
+	quotaChecks := []struct {
+		name       string
+		max        *int
+		filter     []map[string]interface{}
+		msg        string
+		retryAfter time.Duration
+	}{
+		{
+			name: metrics.QuotaActiveBatches,
+			max:  quota.MaxActiveBatches,
+			filter: []map[string]interface{}{
+				{"terms": map[string]interface{}{param.Status: []string{status.Started.String(), status.SendCompleted.String()}}},
+			},
+			msg:        fmt.Sprintf(msgActiveBatchQuota, batch.TenantId, intValue(quota.MaxActiveBatches)),
+			retryAfter: openBatchQuotaRetryAfter,
+		},
+		{
+			name: metrics.QuotaStartedBatchesPerIntegrator,
+			max:  quota.MaxStartedBatchesPerIntegrator,
+			filter: []map[string]interface{}{
+				{"term": map[string]interface{}{param.Status: status.Started.String()}},
+				{"term": map[string]interface{}{param.IntegratorId: integratorId}},
+			},
+			msg:        fmt.Sprintf(msgIntegratorStartedBatchQuota, integratorId, intValue(quota.MaxStartedBatchesPerIntegrator)),
+			retryAfter: openBatchQuotaRetryAfter,
+		},
+		{
+			name: metrics.QuotaBatchesPerDay,
+			max:  quota.MaxBatchesPerDay,
+			filter: []map[string]interface{}{
+				{"range": map[string]interface{}{param.StartDate: map[string]interface{}{"gte": "now/d"}}},
+			},
+			msg:        fmt.Sprintf(msgDailyBatchQuota, batch.TenantId, intValue(quota.MaxBatchesPerDay)),
+			retryAfter: untilNextUtcDay(time.Now()),
+		},
+	}
+
+	for _, check := range quotaChecks {
+		if check.max == nil {
+			continue
+		}
+		count, errDetail := countBatches(ctx, requestId, batch.TenantId, check.filter, esClient, logger)
+		if errDetail != nil {
+			return http.StatusInternalServerError, errDetail
+		}
+		if count >= *check.max {
+			metrics.QuotaExceeded(check.name, batch.TenantId)
+			logger.Errorln(check.msg)
+			return http.StatusTooManyRequests, &ratelimit.Rejection{
+				RetryAfter: check.retryAfter,
+				Detail:     response.NewErrorDetail(requestId, check.msg),
+			}
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// countBatches returns the number of the tenant's batches that match all the filters
+func countBatches(ctx context.Context, requestId string, tenantId string, filter []map[string]interface{},
+	esClient *elasticsearch.Client, logger logrus.FieldLogger) (int, *response.ErrorDetail) {
+
+	var query map[string]interface{}
+	if len(filter) == 1 {
+		query = map[string]interface{}{"query": filter[0]}
+	} else {
+		query = map[string]interface{}{"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filter}}}
+	}
+	buf, err := elastic.EncodeQueryBody(query)
+	if err != nil {
+		msg := fmt.Sprintf("Error encoding Elastic query: %s", err.Error())
+		logger.Errorln(msg)
+		return 0, response.NewErrorDetail(requestId, msg)
+	}
+
+	res, err := esClient.Count(
+		esClient.Count.WithContext(ctx),
+		esClient.Count.WithIndex(elastic.IndexFromTenantId(tenantId)),
+		esClient.Count.WithBody(buf),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return 0, elasticErr.LogAndBuildErrorDetail(requestId, logger, "Batch creation failed")
+	}
+	return int(body["count"].(float64)), nil
+}
+
+// untilNextUtcDay returns the time left until the next UTC midnight, when the batches per day are counted again
+func untilNextUtcDay(now time.Time) time.Duration {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
+
+func intValue(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
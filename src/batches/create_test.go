@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,12 +17,14 @@ import (
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/param/esparam"
+	"github.com/Alvearie/hri-mgmt-api/common/ratelimit"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/Alvearie/hri-mgmt-api/common/test"
 	"net/http"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCreate(t *testing.T) {
@@ -34,7 +37,7 @@ func TestCreate(t *testing.T) {
 	batchName := "monkeeName"
 	batchDataType := "pikachu"
 	topicBase := "batchFunTopic"
-	inputTopic := topicBase + inputSuffix
+	inputTopic := topicBase + ".in"
 	batchMetadata := map[string]interface{}{"batchContact": "Samuel L. Jackson", "finalRecordCount": 200}
 	batchInvalidThreshold := 10
 
@@ -47,6 +50,10 @@ func TestCreate(t *testing.T) {
 		Metadata:         batchMetadata,
 	}
 
+	tenantInvalidThreshold := 7
+	validBatchNoThreshold := validBatch
+	validBatchNoThreshold.InvalidThreshold = 0
+
 	validClaims := auth.HriClaims{Scope: auth.HriIntegrator, Subject: integratorId}
 	var elasticErrMsg = "elasticErrMsg"
 
@@ -76,6 +83,32 @@ func TestCreate(t *testing.T) {
 		t.Fatal("Unable to marshal expected elastic Index request body")
 	}
 
+	tenantDefaultsKafkaMetadata := map[string]interface{}{}
+	for key, value := range validBatchKafkaMetadata {
+		tenantDefaultsKafkaMetadata[key] = value
+	}
+	tenantDefaultsKafkaMetadata[param.InvalidThreshold] = tenantInvalidThreshold
+
+	elasticIndexRequestBodyTenantDefaults, err := json.Marshal(map[string]interface{}{
+		param.Name:             batchName,
+		param.IntegratorId:     integratorId,
+		param.Topic:            inputTopic,
+		param.DataType:         batchDataType,
+		param.Status:           status.Started.String(),
+		param.StartDate:        test.DatePattern,
+		param.Metadata:         batchMetadata,
+		param.InvalidThreshold: tenantInvalidThreshold,
+	})
+	if err != nil {
+		t.Fatal("Unable to marshal expected elastic Index request body")
+	}
+
+	tenantConfigPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	noTenantConfig := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+	}
+
 	testCases := []struct {
 		name         string
 		requestId    string
@@ -93,7 +126,7 @@ func TestCreate(t *testing.T) {
 			batch:        validBatch,
 			claims:       auth.HriClaims{Scope: auth.HriConsumer, Subject: integratorId},
 			transport:    test.NewFakeTransport(t),
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(auth.MsgIntegratorRoleRequired, "create")),
 		},
 		{
@@ -111,6 +144,8 @@ func TestCreate(t *testing.T) {
 			batch:     validBatch,
 			claims:    validClaims,
 			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf("/%s-batches/_doc", tenantId),
 				test.ElasticCall{
 					RequestBody: string(elasticIndexRequestBody),
@@ -129,6 +164,8 @@ func TestCreate(t *testing.T) {
 			batch:     validBatch,
 			claims:    validClaims,
 			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf("/%s-batches/_doc", tenantId),
 				test.ElasticCall{
 					RequestBody:  string(elasticIndexRequestBody),
@@ -143,12 +180,184 @@ func TestCreate(t *testing.T) {
 			expectedBody: response.NewErrorDetail(requestId, "Unable to write to Kafka"),
 			kafkaValue:   validBatchKafkaMetadata,
 		},
+		{
+			name:      "tenant-config-error",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseErr: errors.New(elasticErrMsg),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				fmt.Sprintf("Batch creation failed: [500] elasticsearch client error: %s", elasticErrMsg),
+			),
+		},
+		{
+			name:      "tenant-suspended",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: `{"found":true,"_source":{"suspension":{"suspendDate":"2021-02-24T18:08:36Z"}}}`,
+				},
+			),
+			expectedCode: http.StatusLocked,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantSuspended, "Batch creation", tenantId)),
+		},
+		{
+			name:      "data-type-not-allowed",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: `{"found":true,"_source":{"allowedDataTypes":["claims","members"]}}`,
+				},
+			),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: response.NewErrorDetail(requestId,
+				fmt.Sprintf(msgDataTypeNotAllowed, batchDataType, tenantId, "claims, members"),
+			),
+		},
+		{
+			name:      "active-batch-quota-reached",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: `{"found":true,"_source":{"quota":{"maxActiveBatches":2}}}`,
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_count", tenantId),
+				test.ElasticCall{
+					RequestBody:  `{"query":{"terms":{"status":\["started","sendCompleted"\]}}}`,
+					ResponseBody: `{"count":2}`,
+				},
+			),
+			expectedCode: http.StatusTooManyRequests,
+			expectedBody: &ratelimit.Rejection{
+				RetryAfter: openBatchQuotaRetryAfter,
+				Detail:     response.NewErrorDetail(requestId, fmt.Sprintf(msgActiveBatchQuota, tenantId, 2)),
+			},
+		},
+		{
+			name:      "integrator-started-batch-quota-reached",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: `{"found":true,"_source":{"quota":{"maxActiveBatches":5,"maxStartedBatchesPerIntegrator":1}}}`,
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_count", tenantId),
+				test.ElasticCall{
+					RequestBody:  `{"query":{"terms":{"status":\["started","sendCompleted"\]}}}`,
+					ResponseBody: `{"count":3}`,
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_count", tenantId),
+				test.ElasticCall{
+					RequestBody: fmt.Sprintf(`{"query":{"bool":{"filter":\[{"term":{"status":"started"}},{"term":{"integratorId":"%s"}}\]}}}`,
+						integratorId),
+					ResponseBody: `{"count":1}`,
+				},
+			),
+			expectedCode: http.StatusTooManyRequests,
+			expectedBody: &ratelimit.Rejection{
+				RetryAfter: openBatchQuotaRetryAfter,
+				Detail:     response.NewErrorDetail(requestId, fmt.Sprintf(msgIntegratorStartedBatchQuota, integratorId, 1)),
+			},
+		},
+		{
+			name:      "daily-batch-quota-reached",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: `{"found":true,"_source":{"quota":{"maxBatchesPerDay":100}}}`,
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_count", tenantId),
+				test.ElasticCall{
+					RequestBody:  `{"query":{"range":{"startDate":{"gte":"now/d"}}}}`,
+					ResponseBody: `{"count":100}`,
+				},
+			),
+			expectedCode: http.StatusTooManyRequests,
+			// the retry delay until the next UTC midnight is checked separately, it depends on when the test runs
+			expectedBody: &ratelimit.Rejection{
+				Detail: response.NewErrorDetail(requestId, fmt.Sprintf(msgDailyBatchQuota, tenantId, 100)),
+			},
+		},
+		{
+			name:      "quota-count-error",
+			requestId: requestId,
+			batch:     validBatch,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: `{"found":true,"_source":{"quota":{"maxBatchesPerDay":100}}}`,
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_count", tenantId),
+				test.ElasticCall{
+					ResponseErr: errors.New(elasticErrMsg),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				fmt.Sprintf("Batch creation failed: [500] elasticsearch client error: %s", elasticErrMsg),
+			),
+		},
+		{
+			name:      "tenant-defaults-applied",
+			requestId: requestId,
+			batch:     validBatchNoThreshold,
+			claims:    validClaims,
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"found":true,"_source":{"defaultInvalidThreshold":%d,"allowedDataTypes":["%s"],"quota":{"maxActiveBatches":2}}}`,
+						tenantInvalidThreshold, batchDataType),
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_count", tenantId),
+				test.ElasticCall{
+					ResponseBody: `{"count":1}`,
+				},
+			).AddCall(
+				fmt.Sprintf("/%s-batches/_doc", tenantId),
+				test.ElasticCall{
+					RequestBody:  string(elasticIndexRequestBodyTenantDefaults),
+					ResponseBody: fmt.Sprintf(`{"%s": "%s"}`, esparam.EsDocId, batchId),
+				},
+			),
+			expectedCode: http.StatusCreated,
+			expectedBody: map[string]interface{}{param.BatchId: batchId},
+			kafkaValue:   tenantDefaultsKafkaMetadata,
+		},
 		{
 			name:      "happy-path-good-request",
 			requestId: requestId,
 			batch:     validBatch,
 			claims:    validClaims,
 			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf("/%s-batches/_doc", tenantId),
 				test.ElasticCall{
 					RequestBody:  string(elasticIndexRequestBody),
@@ -169,14 +378,20 @@ func TestCreate(t *testing.T) {
 
 		writer := test.FakeWriter{
 			T:             t,
-			ExpectedTopic: topicBase + notificationSuffix,
+			ExpectedTopic: topicBase + ".notification",
 			ExpectedKey:   batchId,
 			ExpectedValue: tc.kafkaValue,
 			Error:         tc.writerError,
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := Create(tc.requestId, tc.batch, tc.claims, client, writer)
+			actualCode, actualBody := Create(context.Background(), tc.requestId, tc.batch, tc.claims, client, writer)
+			if rejection, ok := actualBody.(*ratelimit.Rejection); ok && rejection.RetryAfter != openBatchQuotaRetryAfter {
+				if rejection.RetryAfter <= 0 || rejection.RetryAfter > 24*time.Hour {
+					t.Errorf("Batches-Create() retry after %v, expected until the next UTC midnight", rejection.RetryAfter)
+				}
+				rejection.RetryAfter = 0
+			}
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
 				//notify/print error event as test result
 				t.Errorf("Batches-Create()\n   actual: %v,%v\n expected: %v,%v", actualCode, actualBody, tc.expectedCode, tc.expectedBody)
@@ -196,7 +411,7 @@ func TestCreateNoAuth(t *testing.T) {
 	batchDataType := "Snorkel"
 	topicBase := "batchSadTopic"
 	integratorId := auth.NoAuthFakeIntegrator
-	inputTopic := topicBase + inputSuffix
+	inputTopic := topicBase + ".in"
 	batchMetadata := map[string]interface{}{"batchContact": "Sergio Leone", "finalRecordCount": 200}
 	batchInvalidThreshold := 5
 
@@ -235,6 +450,12 @@ func TestCreateNoAuth(t *testing.T) {
 		t.Fatal("Unable to marshal expected elastic Index request body")
 	}
 
+	tenantConfigPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	noTenantConfig := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+	}
+
 	testCases := []struct {
 		name         string
 		requestId    string
@@ -250,6 +471,8 @@ func TestCreateNoAuth(t *testing.T) {
 			requestId: requestId,
 			batch:     validBatch,
 			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf("/%s-batches/_doc", tenantId),
 				test.ElasticCall{
 					RequestBody:  string(elasticIndexRequestBody),
@@ -270,14 +493,14 @@ func TestCreateNoAuth(t *testing.T) {
 
 		kWriter := test.FakeWriter{
 			T:             t,
-			ExpectedTopic: topicBase + notificationSuffix,
+			ExpectedTopic: topicBase + ".notification",
 			ExpectedKey:   batchId,
 			ExpectedValue: tc.kafkaValue,
 			Error:         tc.writerError,
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := CreateNoAuth(tc.requestId, tc.batch, auth.HriClaims{}, eClient, kWriter)
+			actualCode, actualBody := CreateNoAuth(context.Background(), tc.requestId, tc.batch, auth.HriClaims{}, eClient, kWriter)
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
 				//print error event as test result
 				t.Errorf("Batches-CreateNoAuth()\n  actual: %v,%v\n expected: %v,%v",
@@ -293,7 +516,7 @@ func TestBuildBatchInfo(t *testing.T) {
 	batchName := "monkeeBatch"
 	batchDataType := "porcipine"
 	topicBase := "batchFunTopic"
-	inputTopic := topicBase + inputSuffix
+	inputTopic := topicBase + ".in"
 	validBatchMetadata := map[string]interface{}{"batchContact": "The_Village_People", "finalRecordCount": 18}
 	batchInvalidThreshold := 42
 
@@ -419,3 +642,35 @@ func batchInfoNotEqual(expectedBatchInfo map[string]interface{},
 	}
 	return false
 }
+
+func TestUntilNextUtcDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected time.Duration
+	}{
+		{
+			name:     "evening",
+			now:      time.Date(2021, 2, 24, 18, 8, 36, 0, time.UTC),
+			expected: 5*time.Hour + 51*time.Minute + 24*time.Second,
+		},
+		{
+			name:     "midnight",
+			now:      time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC),
+			expected: 24 * time.Hour,
+		},
+		{
+			name:     "end of the month in another zone",
+			now:      time.Date(2021, 2, 28, 18, 30, 0, 0, time.FixedZone("EST", -5*60*60)),
+			expected: 30 * time.Minute,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := untilNextUtcDay(tc.now); actual != tc.expected {
+				t.Errorf("untilNextUtcDay() = %v, expected %v", actual, tc.expected)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
@@ -22,6 +23,7 @@ import (
 )
 
 func Fail(
+	ctx context.Context,
 	requestId string,
 	request *model.FailRequest,
 	claims auth.HriClaims,
@@ -30,33 +32,33 @@ func Fail(
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/fail"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 	logger.Debugln("Start Batch Fail")
 
-	// Only Integrators can call fail
-	if !claims.HasScope(auth.HriInternal) {
+	// By default, only the internal HRI components can call fail
+	if !auth.Authorize(claims, request.TenantId, auth.ActionFail).Allowed {
 		msg := fmt.Sprintf(auth.MsgInternalRoleRequired, "failed")
 		logger.Errorln(msg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, msg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, msg)
 	}
 
-	return fail(requestId, request, logger, esClient, writer, currentStatus)
+	return fail(ctx, requestId, request, logger, esClient, writer, currentStatus)
 }
 
-func FailNoAuth(requestId string, request *model.FailRequest,
+func FailNoAuth(ctx context.Context, requestId string, request *model.FailRequest,
 	_ auth.HriClaims,
 	esClient *elasticsearch.Client,
 	writer kafka.Writer,
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/FailNoAuth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 	logger.Debugln("Start Batch Fail (No Auth)")
 
-	return fail(requestId, request, logger, esClient, writer, currentStatus)
+	return fail(ctx, requestId, request, logger, esClient, writer, currentStatus)
 }
 
-func fail(requestId string, request *model.FailRequest,
+func fail(ctx context.Context, requestId string, request *model.FailRequest,
 	logger logrus.FieldLogger,
 	esClient *elasticsearch.Client,
 	writer kafka.Writer,
@@ -64,7 +66,7 @@ func fail(requestId string, request *model.FailRequest,
 
 	updateRequest := getFailUpdateScript(request)
 
-	origBatch, errResp := updateStatus(requestId, request.TenantId, request.BatchId, updateRequest, esClient, writer, currentStatus)
+	origBatch, errResp := updateStatus(ctx, requestId, request.TenantId, request.BatchId, updateRequest, esClient, writer, currentStatus)
 	if errResp != nil {
 		return errResp.Code, errResp.Body
 	}
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -129,10 +130,10 @@ func TestUpdateStatus_Fail(t *testing.T) {
 			expectedResponse:     nil,
 		},
 		{
-			name:             "401 unauthorized when internal scope is missing",
+			name:             "403 forbidden when internal scope is missing",
 			request:          getTestFailRequest(int(batchActualRecordCount), int(batchInvalidRecordCount), batchFailureMessage),
 			claims:           auth.HriClaims{},
-			expectedCode:     http.StatusUnauthorized,
+			expectedCode:     http.StatusForbidden,
 			expectedResponse: response.NewErrorDetail(requestId, "Must have hri_internal role to mark a batch as failed"),
 		},
 		{
@@ -201,7 +202,7 @@ func TestUpdateStatus_Fail(t *testing.T) {
 				Error:         tt.writerError,
 			}
 
-			code, result := Fail(requestId, tt.request, tt.claims, esClient, writer, currentStatus)
+			code, result := Fail(context.Background(), requestId, tt.request, tt.claims, esClient, writer, currentStatus)
 
 			if tt.ft != nil {
 				tt.ft.VerifyCalls()
@@ -299,7 +300,7 @@ func TestFailNoAuth(t *testing.T) {
 			}
 
 			var emptyClaims = auth.HriClaims{}
-			code, result := FailNoAuth(requestId, tt.request, emptyClaims, esClient, writer, currentStatus)
+			code, result := FailNoAuth(context.Background(), requestId, tt.request, emptyClaims, esClient, writer, currentStatus)
 
 			tt.ft.VerifyCalls()
 			if code != tt.expectedCode {
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
@@ -23,32 +24,35 @@ import (
 const defaultSize = 10
 const defaultFrom = 0
 
-func Get(requestId string, params model.GetBatch, claims auth.HriClaims, client *elasticsearch.Client) (int, interface{}) {
+func Get(ctx context.Context, requestId string, params model.GetBatch, claims auth.HriClaims, client *elasticsearch.Client,
+	store archive.Store) (int, interface{}) {
 	prefix := "batches/get"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, params.TenantId)
 	logger.Debugln("Start Batch Get")
 
-	// Data Integrators and Consumers can use this endpoint, so either scope allows access
-	if !claims.HasScope(auth.HriConsumer) && !claims.HasScope(auth.HriIntegrator) {
+	// By default, Data Integrators and Consumers can use this endpoint, so either scope allows access
+	if !auth.Authorize(claims, params.TenantId, auth.ActionGetBatches).Allowed {
 		errMsg := auth.MsgAccessTokenMissingScopes
 		logger.Errorln(errMsg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, errMsg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, errMsg)
 	}
 
-	return get(requestId, params, false, &claims, client, logger)
+	return get(ctx, requestId, params, false, &claims, client, store, logger)
 }
 
-func GetNoAuth(requestId string, params model.GetBatch, _ auth.HriClaims, client *elasticsearch.Client) (int, interface{}) {
+func GetNoAuth(ctx context.Context, requestId string, params model.GetBatch, _ auth.HriClaims, client *elasticsearch.Client,
+	store archive.Store) (int, interface{}) {
 	prefix := "batches/getNoAuth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, params.TenantId)
 	logger.Debugln("Start Batch Get (No Auth)")
 
 	var noAuthFlag = true
-	return get(requestId, params, noAuthFlag, nil, client, logger)
+	return get(ctx, requestId, params, noAuthFlag, nil, client, store, logger)
 }
 
-func get(requestId string, params model.GetBatch, noAuthFlag bool, claims *auth.HriClaims,
-	client *elasticsearch.Client, logger logrus.FieldLogger) (int, interface{}) {
+// store is only used when params.IncludeArchived is set
+func get(ctx context.Context, requestId string, params model.GetBatch, noAuthFlag bool, claims *auth.HriClaims,
+	client *elasticsearch.Client, store archive.Store, logger logrus.FieldLogger) (int, interface{}) {
 
 	var buf *bytes.Buffer
 	var err error
@@ -71,7 +75,7 @@ func get(requestId string, params model.GetBatch, noAuthFlag bool, claims *auth.
 	size, from := getClientSearchParams(params)
 	// Perform the search request.
 	res, err := client.Search(
-		client.Search.WithContext(context.Background()),
+		client.Search.WithContext(ctx),
 		client.Search.WithIndex(index),
 		client.Search.WithBody(buf),
 		client.Search.WithSize(size),
@@ -92,12 +96,42 @@ func get(requestId string, params model.GetBatch, noAuthFlag bool, claims *auth.
 		hits[i] = EsDocToBatch(entry.(map[string]interface{}))
 	}
 
+	total := body["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)
+
+	if params.IncludeArchived {
+		archived, err := getArchivedBatches(params, claims, store, logger)
+		if err != nil {
+			msg := fmt.Sprintf("Get archived batches failed: %s", err.Error())
+			logger.Errorln(msg)
+			return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+		}
+		hits = appendArchivedPage(hits, archived, int(total), size, from)
+		total += float64(len(archived))
+	}
+
 	return http.StatusOK, map[string]interface{}{
-		"total":   body["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64),
+		"total":   total,
 		"results": hits,
 	}
 }
 
+// appendArchivedPage fills the rest of the requested page with archived batches, which are listed after all the
+// batches in Elastic
+func appendArchivedPage(hits []interface{}, archived []interface{}, liveTotal int, size int, from int) []interface{} {
+	start := from - liveTotal
+	if start < 0 {
+		start = 0
+	}
+	end := start + size - len(hits)
+	if end > len(archived) {
+		end = len(archived)
+	}
+	if start >= end {
+		return hits
+	}
+	return append(hits, archived[start:end]...)
+}
+
 func buildQuery(params model.GetBatch, claims *auth.HriClaims) map[string]interface{} {
 	noAuthFlag := false // (default -> Auth Enabled)
 	if claims == nil {
@@ -123,10 +157,10 @@ func buildQuery(params model.GetBatch, claims *auth.HriClaims) map[string]interf
 		appendClause(&clauses, "range", param.StartDate, rangeClauses)
 	}
 
-	// If only the HriIntegrator role is present, filter results to batches it owns
-	if !noAuthFlag && !claims.HasScope(auth.HriConsumer) &&
-		claims.HasScope(auth.HriIntegrator) {
-		appendClause(&clauses, "term", param.IntegratorId, claims.Subject)
+	// Apply the row filters of the authorization policy, e.g. Integrators only see the batches they own
+	if !noAuthFlag {
+		decision := auth.Authorize(*claims, params.TenantId, auth.ActionGetBatches)
+		clauses = append(clauses, decision.QueryClauses()...)
 	}
 
 	if len(clauses) == 0 {
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
@@ -21,42 +22,42 @@ import (
 const msgMissingStatusElem = "Error: Elastic Search Result body does Not have the expected '_source' Element"
 const msgDocNotFound string = "The document for tenantId: %s with document (batch) ID: %s was not found"
 
-func GetById(requestId string, batch model.GetByIdBatch, claims auth.HriClaims, client *elasticsearch.Client) (int, interface{}) {
+func GetById(ctx context.Context, requestId string, batch model.GetByIdBatch, claims auth.HriClaims, client *elasticsearch.Client) (int, interface{}) {
 	prefix := "batches/getById"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, batch.TenantId, batch.BatchId)
 	logger.Debugln("Start Batch GetById")
 
-	if !claims.HasScope(auth.HriIntegrator) && !claims.HasScope(auth.HriConsumer) {
+	if !auth.Authorize(claims, batch.TenantId, auth.ActionGetBatch).Allowed {
 		errMsg := auth.MsgAccessTokenMissingScopes
 		logger.Errorln(errMsg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, errMsg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, errMsg)
 	}
 
 	logger.Debugf("params_tenantID: %v, batchID: %v", batch.TenantId, batch.BatchId)
 
 	var noAuthFlag = false
-	return getById(requestId, batch, noAuthFlag, logger, &claims, client)
+	return getById(ctx, requestId, batch, noAuthFlag, logger, &claims, client)
 }
 
-func GetByIdNoAuth(requestId string, params model.GetByIdBatch,
+func GetByIdNoAuth(ctx context.Context, requestId string, params model.GetByIdBatch,
 	_ auth.HriClaims, client *elasticsearch.Client) (int, interface{}) {
 
 	prefix := "batches/GetByIdNoAuth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, params.TenantId, params.BatchId)
 	logger.Debugln("Start Batch GetById (No Auth)")
 
 	var noAuthFlag = true
-	return getById(requestId, params, noAuthFlag, logger, nil, client)
+	return getById(ctx, requestId, params, noAuthFlag, logger, nil, client)
 }
 
-func getById(requestId string, batch model.GetByIdBatch,
+func getById(ctx context.Context, requestId string, batch model.GetByIdBatch,
 	noAuthFlag bool, logger logrus.FieldLogger,
 	claims *auth.HriClaims, client *elasticsearch.Client) (int, interface{}) {
 
 	index := elastic.IndexFromTenantId(batch.TenantId)
 	logger.Debugf("index: %v", index)
 
-	res, err := client.Get(index, batch.BatchId)
+	res, err := client.Get(index, batch.BatchId, client.Get.WithContext(ctx))
 
 	resultBody, elasticErr := elastic.DecodeBody(res, err)
 	if elasticErr != nil {
@@ -71,7 +72,7 @@ func getById(requestId string, batch model.GetByIdBatch,
 	}
 
 	if !noAuthFlag {
-		errDetailResponse := checkBatchAuthorization(requestId, claims, resultBody)
+		errDetailResponse := checkBatchAuthorization(requestId, batch.TenantId, claims, resultBody)
 		if errDetailResponse != nil {
 			return errDetailResponse.Code, errDetailResponse.Body
 		}
@@ -101,27 +102,30 @@ func noDocInResultBody(resultBody map[string]interface{}) bool {
 	return ok && !found
 }
 
-// Data Integrators and Consumers can call this endpoint, but the behavior is slightly different. Consumers can see
-// all Batches, but Data Integrators are only allowed to see Batches they created.
-func checkBatchAuthorization(requestId string, claims *auth.HriClaims, resultBody map[string]interface{}) *response.ErrorDetailResponse {
-	if claims.HasScope(auth.HriConsumer) { //= Always Authorized
+// Data Integrators and Consumers can call this endpoint, but the behavior is slightly different. By default, Consumers
+// can see all Batches, but Data Integrators are only allowed to see Batches they created. The authorization policy
+// decides, with its row filters.
+func checkBatchAuthorization(requestId string, tenantId string, claims *auth.HriClaims,
+	resultBody map[string]interface{}) *response.ErrorDetailResponse {
+
+	decision := auth.Authorize(*claims, tenantId, auth.ActionGetBatch)
+	if !decision.Allowed { //No Scope was provided -> Unauthorized - we should never reach here
+		errMsg := auth.MsgAccessTokenMissingScopes
+		return response.NewErrorDetailResponse(http.StatusForbidden, requestId, errMsg)
+	}
+	if !decision.Filtered() { //= Always Authorized
 		return nil // return nil Error for Authorized
 	}
 
-	if claims.HasScope(auth.HriIntegrator) {
-		if sourceBody, ok := resultBody["_source"].(map[string]interface{}); ok {
-			integratorId := sourceBody[param.IntegratorId]
-			//if claims.Subject from the token does NOT match the previously saved batch.IntegratorId, user NOT Authorized
-			if claims.Subject != integratorId {
-				errMsg := fmt.Sprintf(auth.MsgIntegratorSubClaimNoMatch, claims.Subject, integratorId)
-				return response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, errMsg)
-			}
-		} else { //_source elem does Not exist - Internal Server Error
-			return response.NewErrorDetailResponse(http.StatusInternalServerError, requestId, msgMissingStatusElem)
-		}
-	} else { //No Scope was provided -> Unauthorized - we should never reach here
-		errMsg := auth.MsgAccessTokenMissingScopes
-		return response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, errMsg)
+	sourceBody, ok := resultBody["_source"].(map[string]interface{})
+	if !ok { //_source elem does Not exist - Internal Server Error
+		return response.NewErrorDetailResponse(http.StatusInternalServerError, requestId, msgMissingStatusElem)
+	}
+	//if the batch doesn't pass the row filters, e.g. claims.Subject from the token does NOT match the previously saved
+	//batch.IntegratorId, user NOT Authorized
+	if !decision.Permits(sourceBody) {
+		errMsg := fmt.Sprintf(auth.MsgIntegratorSubClaimNoMatch, claims.Subject, sourceBody[param.IntegratorId])
+		return response.NewErrorDetailResponse(http.StatusForbidden, requestId, errMsg)
 	}
 
 	return nil //Default Return: we are Authorized  => nil error
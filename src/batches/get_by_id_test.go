@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
@@ -94,7 +95,7 @@ func TestGetById(t *testing.T) {
 			batchId:      test.ValidBatchId,
 			claims:       auth.HriClaims{},
 			transport:    test.NewFakeTransport(t),
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: response.NewErrorDetail(requestId, auth.MsgAccessTokenMissingScopes),
 		},
 		{
@@ -194,7 +195,7 @@ func TestGetById(t *testing.T) {
 					}`, test.ValidTenantId, test.ValidBatchId),
 				},
 			),
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: response.NewErrorDetail(requestId, "The token's sub claim (clientId): no_match_integrator does not match the data integratorId: dataIntegrator1"),
 		},
 		{
@@ -240,7 +241,7 @@ func TestGetById(t *testing.T) {
 			t.Error(err)
 		}
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := GetById(requestId, getTestGetByIdBatch(tc.tenantId, tc.batchId), tc.claims, client)
+			actualCode, actualBody := GetById(context.Background(), requestId, getTestGetByIdBatch(tc.tenantId, tc.batchId), tc.claims, client)
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
 				t.Errorf("GetById()\n   actual: %v,%v\n expected: %v,%v", actualCode, actualBody, tc.expectedCode, tc.expectedBody)
 			}
@@ -321,7 +322,7 @@ func TestGetByIdNoAuth(t *testing.T) {
 
 		var emptyClaims = auth.HriClaims{}
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := GetByIdNoAuth(requestId, getTestGetByIdBatch(tc.tenantId, tc.batchId), emptyClaims, client)
+			actualCode, actualBody := GetByIdNoAuth(context.Background(), requestId, getTestGetByIdBatch(tc.tenantId, tc.batchId), emptyClaims, client)
 
 			tc.transport.VerifyCalls()
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
@@ -409,7 +410,7 @@ func TestCheckBatchAuthorization(t *testing.T) {
 				},
 			},
 			expectedErrDetail: response.NewErrorDetailResponse(
-				http.StatusUnauthorized, requestId, auth.MsgAccessTokenMissingScopes),
+				http.StatusForbidden, requestId, auth.MsgAccessTokenMissingScopes),
 		},
 		{
 			name:              "consumer_role_returns_authorized",
@@ -436,7 +437,7 @@ func TestCheckBatchAuthorization(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actualErrDetail := checkBatchAuthorization(requestId, &tc.claims, tc.resultBody)
+			actualErrDetail := checkBatchAuthorization(requestId, test.ValidTenantId, &tc.claims, tc.resultBody)
 			if !reflect.DeepEqual(tc.expectedErrDetail, actualErrDetail) {
 				t.Errorf("GetById() = %v, expected %v", actualErrDetail, tc.expectedErrDetail)
 			}
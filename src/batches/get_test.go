@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"errors"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
@@ -151,7 +152,7 @@ func TestGet(t *testing.T) {
 			name:         "missing scopes no role set in Claim",
 			claims:       auth.HriClaims{},
 			transport:    test.NewFakeTransport(t),
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: response.NewErrorDetail(requestId, auth.MsgAccessTokenMissingScopes),
 		},
 		{
@@ -245,7 +246,7 @@ func TestGet(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			actualCode, actualBody := Get(requestId, tt.params, tt.claims, client)
+			actualCode, actualBody := Get(context.Background(), requestId, tt.params, tt.claims, client, nil)
 
 			tt.transport.VerifyCalls()
 			if actualCode != tt.expectedCode || !reflect.DeepEqual(tt.expectedBody, actualBody) {
@@ -373,7 +374,7 @@ func TestGetNoAuth(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			actualCode, actualBody := GetNoAuth(requestId, tt.params, emptyClaims, client)
+			actualCode, actualBody := GetNoAuth(context.Background(), requestId, tt.params, emptyClaims, client, nil)
 			if actualCode != tt.expectedCode || !reflect.DeepEqual(tt.expectedBody, actualBody) {
 				t.Errorf("GetNoAuth()\n   actual: %v,%v\n expected: %v,%v", actualCode, actualBody, tt.expectedCode, tt.expectedBody)
 			}
@@ -7,14 +7,17 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/ratelimit"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/labstack/echo/v4"
@@ -24,6 +27,7 @@ import (
 
 const msgElasticErr string = "error getting Elastic client: %s"
 const msgGetByIdErr string = "error getting current Batch Status: %s"
+const msgArchiveErr string = "archived batches are not available: %s"
 
 type Handler interface {
 	Create(echo.Context) error
@@ -38,14 +42,15 @@ type Handler interface {
 type theHandler struct {
 	config             config.Config
 	jwtValidator       auth.Validator
-	create             func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{})
-	get                func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{})
-	getById            func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{})
-	getByIdNoAuth      func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{})
-	sendComplete       func(string, *model.SendCompleteRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
-	terminate          func(string, *model.TerminateRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
-	processingComplete func(string, *model.ProcessingCompleteRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
-	fail               func(string, *model.FailRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
+	limiter            *ratelimit.Limiter // throttles creating and searching batches
+	create             func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{})
+	get                func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{})
+	getById            func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{})
+	getByIdNoAuth      func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{})
+	sendComplete       func(context.Context, string, *model.SendCompleteRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
+	terminate          func(context.Context, string, *model.TerminateRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
+	processingComplete func(context.Context, string, *model.ProcessingCompleteRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
+	fail               func(context.Context, string, *model.FailRequest, auth.HriClaims, *elasticsearch.Client, kafka.Writer, status.BatchStatus) (int, interface{})
 }
 
 // NewHandler This struct is designed to make unit testing easier. It has function references for the calls to backend
@@ -56,6 +61,7 @@ func NewHandler(config config.Config) Handler {
 	if config.AuthDisabled {
 		newHandler = &theHandler{
 			config:             config,
+			limiter:            ratelimit.NewLimiter(config),
 			create:             CreateNoAuth,
 			get:                GetNoAuth,
 			getById:            GetByIdNoAuth,
@@ -69,7 +75,8 @@ func NewHandler(config config.Config) Handler {
 	} else {
 		newHandler = &theHandler{
 			config:       config,
-			jwtValidator: auth.NewValidator(config.OidcIssuer, config.JwtAudienceId),
+			jwtValidator: auth.NewValidator(config),
+			limiter:      ratelimit.NewLimiter(config),
 
 			// The Elastic Client & Kafka Writer creation don't have method references, because they do not reach out to the
 			// service until they're used. So, we don't need to mock it for unit testing.
@@ -88,6 +95,7 @@ func NewHandler(config config.Config) Handler {
 
 func (h *theHandler) Create(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/create"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -102,6 +110,26 @@ func (h *theHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
 	}
 
+	// the caller is authorized and limited before the clients are created, so throttled requests stay cheap
+	claims := auth.HriClaims{}
+	clientKey := ""
+	if h.config.AuthDisabled == false { //Auth Enabled
+		//JWT claims validation
+		var errResp *response.ErrorDetailResponse
+		claims, errResp = h.jwtValidator.GetValidatedClaims(requestId,
+			c.Request(), batch.TenantId)
+		if errResp != nil {
+			return c.JSON(errResp.Code, errResp.Body)
+		}
+		clientKey = ratelimit.ClientKey(c.Request(), claims)
+	} else {
+		// without authorization the clients can't be told apart, so only the tenant is limited
+		logger.Debugln("Auth Disabled - calling CreateNoAuth()")
+	}
+	if rejection := h.limiter.Check(ctx, requestId, clientKey, batch.TenantId); rejection != nil {
+		return rejection.Respond(c)
+	}
+
 	esClient, err := elastic.ClientFromConfig(h.config)
 	if err != nil {
 		msg := fmt.Sprintf(msgElasticErr, err.Error())
@@ -116,23 +144,22 @@ func (h *theHandler) Create(c echo.Context) error {
 	}
 	defer kafkaWriter.Close()
 
-	if h.config.AuthDisabled == false { //Auth Enabled
-		//JWT claims validation
-		claims, errResp := h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), batch.TenantId)
-		if errResp != nil {
-			return c.JSON(errResp.Code, errResp.Body)
-		}
+	code, body := h.create(ctx, requestId, batch, claims, esClient, kafkaWriter)
+	return respondToCreate(c, code, body)
+}
 
-		return c.JSON(h.create(requestId, batch, claims, esClient, kafkaWriter))
-	} else {
-		logger.Debugln("Auth Disabled - calling CreateNoAuth()")
-		return c.JSON(h.create(requestId, batch, auth.HriClaims{}, esClient, kafkaWriter))
+// respondToCreate sends the result of a batch creation. A batch rejected by one of the tenant's quotas is sent with
+// the Retry-After header.
+func respondToCreate(c echo.Context, code int, body interface{}) error {
+	if rejection, ok := body.(*ratelimit.Rejection); ok {
+		return rejection.Respond(c)
 	}
+	return c.JSON(code, body)
 }
 
 func (h *theHandler) GetById(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/getById"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -157,21 +184,22 @@ func (h *theHandler) GetById(c echo.Context) error {
 	if h.config.AuthDisabled == false { //Auth Enabled
 		//JWT claims validation
 		claims, errResp := h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), request.TenantId)
+			c.Request(), request.TenantId)
 		if errResp != nil {
 			return c.JSON(errResp.Code, response.NewErrorDetail(requestId, errResp.Body.ErrorDescription))
 		}
 
-		return c.JSON(h.getById(requestId, request, claims, esClient))
+		return c.JSON(h.getById(ctx, requestId, request, claims, esClient))
 	} else {
 		logger.Debugln("Auth Disabled - calling GetByIdNoAuth()")
 		var emptyClaims = auth.HriClaims{}
-		return c.JSON(h.getById(requestId, request, emptyClaims, esClient))
+		return c.JSON(h.getById(ctx, requestId, request, emptyClaims, esClient))
 	}
 }
 
 func (h *theHandler) Get(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/get"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -186,6 +214,25 @@ func (h *theHandler) Get(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
 	}
 
+	// the caller is authorized and limited before the clients are created, so throttled requests stay cheap
+	claims := auth.HriClaims{}
+	clientKey := ""
+	if h.config.AuthDisabled == false { //Auth Enabled
+		//JWT claims validation
+		var errResp *response.ErrorDetailResponse
+		claims, errResp = h.jwtValidator.GetValidatedClaims(requestId,
+			c.Request(), request.TenantId)
+		if errResp != nil {
+			return c.JSON(errResp.Code, response.NewErrorDetail(requestId, errResp.Body.ErrorDescription))
+		}
+		clientKey = ratelimit.ClientKey(c.Request(), claims)
+	} else {
+		logger.Debugln("Auth Disabled - calling GetNoAuth()")
+	}
+	if rejection := h.limiter.Check(ctx, requestId, clientKey, request.TenantId); rejection != nil {
+		return rejection.Respond(c)
+	}
+
 	esClient, err := elastic.ClientFromConfig(h.config)
 	if err != nil {
 		msg := fmt.Sprintf(msgElasticErr, err.Error())
@@ -193,24 +240,22 @@ func (h *theHandler) Get(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
 	}
 
-	if h.config.AuthDisabled == false { //Auth Enabled
-		//JWT claims validation
-		claims, errResp := h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), request.TenantId)
-		if errResp != nil {
-			return c.JSON(errResp.Code, response.NewErrorDetail(requestId, errResp.Body.ErrorDescription))
+	var store archive.Store
+	if request.IncludeArchived {
+		store, err = archive.NewStoreFromConfig(h.config)
+		if err != nil {
+			msg := fmt.Sprintf(msgArchiveErr, err.Error())
+			logger.Errorln(msg)
+			return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
 		}
-
-		return c.JSON(h.get(requestId, request, claims, esClient))
-	} else {
-		logger.Debugln("Auth Disabled - calling GetNoAuth()")
-		var emptyClaims = auth.HriClaims{}
-		return c.JSON(h.get(requestId, request, emptyClaims, esClient))
 	}
+
+	return c.JSON(h.get(ctx, requestId, request, claims, esClient, store))
 }
 
 func (h *theHandler) SendComplete(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/sendComplete"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -252,7 +297,7 @@ func (h *theHandler) SendComplete(c echo.Context) error {
 	if h.config.AuthDisabled == false { //Auth Enabled
 		//JWT claims validation
 		claims, errResp = h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), request.TenantId)
+			c.Request(), request.TenantId)
 		if errResp != nil {
 			return c.JSON(errResp.Code, errResp.Body)
 		}
@@ -261,12 +306,12 @@ func (h *theHandler) SendComplete(c echo.Context) error {
 		logger.Debugln("Auth Disabled - call SendCompleteNoAuth()")
 	}
 
-	currentStatus, getStatusErr := getCurrentBatchStatus(h, requestId, getBatchRequest, esClient, logger)
+	currentStatus, getStatusErr := getCurrentBatchStatus(ctx, h, requestId, getBatchRequest, esClient, logger)
 	if getStatusErr != nil {
 		return c.JSON(getStatusErr.Code, getStatusErr.Body)
 	}
 
-	code, body = h.sendComplete(requestId, &request, claims, esClient, kafkaWriter, currentStatus)
+	code, body = h.sendComplete(ctx, requestId, &request, claims, esClient, kafkaWriter, currentStatus)
 
 	if body != nil {
 		return c.JSON(code, body)
@@ -277,6 +322,7 @@ func (h *theHandler) SendComplete(c echo.Context) error {
 
 func (h *theHandler) Terminate(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/terminate"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -316,7 +362,7 @@ func (h *theHandler) Terminate(c echo.Context) error {
 	if h.config.AuthDisabled == false { //Auth Enabled
 		//do JWT claims validation
 		claims, errResp = h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), request.TenantId)
+			c.Request(), request.TenantId)
 		if errResp != nil {
 			return c.JSON(errResp.Code, errResp.Body)
 		}
@@ -326,11 +372,11 @@ func (h *theHandler) Terminate(c echo.Context) error {
 		logger.Debugln("Auth Disabled - call TerminateNoAuth()")
 	}
 
-	currentStatus, getStatusErr := getCurrentBatchStatus(h, requestId, getBatchRequest, esClient, logger)
+	currentStatus, getStatusErr := getCurrentBatchStatus(ctx, h, requestId, getBatchRequest, esClient, logger)
 	if getStatusErr != nil {
 		return c.JSON(getStatusErr.Code, getStatusErr.Body)
 	}
-	code, body = h.terminate(requestId, &request, claims, esClient, kafkaWriter, currentStatus)
+	code, body = h.terminate(ctx, requestId, &request, claims, esClient, kafkaWriter, currentStatus)
 
 	if body != nil {
 		return c.JSON(code, body)
@@ -341,6 +387,7 @@ func (h *theHandler) Terminate(c echo.Context) error {
 
 func (h *theHandler) ProcessingComplete(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/processingComplete"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -380,7 +427,7 @@ func (h *theHandler) ProcessingComplete(c echo.Context) error {
 	if h.config.AuthDisabled == false { //Auth Enabled
 		//JWT claims validation
 		claims, errResp = h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), request.TenantId)
+			c.Request(), request.TenantId)
 		if errResp != nil {
 			return c.JSON(errResp.Code, errResp.Body)
 		}
@@ -390,12 +437,12 @@ func (h *theHandler) ProcessingComplete(c echo.Context) error {
 		logger.Debugln("Auth Disabled - call ProcessingCompleteNoAuth()")
 	}
 
-	currentStatus, getStatusErr := getCurrentBatchStatus(h, requestId, getBatchRequest, esClient, logger)
+	currentStatus, getStatusErr := getCurrentBatchStatus(ctx, h, requestId, getBatchRequest, esClient, logger)
 	if getStatusErr != nil {
 		return c.JSON(getStatusErr.Code, getStatusErr.Body)
 	}
 
-	code, body = h.processingComplete(requestId, &request, claims, esClient, kafkaWriter, currentStatus)
+	code, body = h.processingComplete(ctx, requestId, &request, claims, esClient, kafkaWriter, currentStatus)
 
 	if body != nil {
 		return c.JSON(code, body)
@@ -406,6 +453,7 @@ func (h *theHandler) ProcessingComplete(c echo.Context) error {
 
 func (h *theHandler) Fail(c echo.Context) error {
 	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	ctx := c.Request().Context()
 	prefix := "batches/handler/fail"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -445,7 +493,7 @@ func (h *theHandler) Fail(c echo.Context) error {
 	if h.config.AuthDisabled == false { //Auth Enabled
 		//JWT claims validation
 		claims, errResp = h.jwtValidator.GetValidatedClaims(requestId,
-			c.Request().Header.Get(echo.HeaderAuthorization), request.TenantId)
+			c.Request(), request.TenantId)
 		if errResp != nil {
 			return c.JSON(errResp.Code, errResp.Body)
 		}
@@ -455,12 +503,12 @@ func (h *theHandler) Fail(c echo.Context) error {
 		logger.Debugln("Auth Disabled - call FailNoAuth()")
 	}
 
-	currentStatus, getStatusErr := getCurrentBatchStatus(h, requestId, getBatchRequest, esClient, logger)
+	currentStatus, getStatusErr := getCurrentBatchStatus(ctx, h, requestId, getBatchRequest, esClient, logger)
 	if getStatusErr != nil {
 		return c.JSON(getStatusErr.Code, getStatusErr.Body)
 	}
 
-	code, body = h.fail(requestId, &request, claims, esClient, kafkaWriter, currentStatus)
+	code, body = h.fail(ctx, requestId, &request, claims, esClient, kafkaWriter, currentStatus)
 
 	if body != nil {
 		return c.JSON(code, body)
@@ -471,10 +519,10 @@ func (h *theHandler) Fail(c echo.Context) error {
 
 //get the Current Batch Status --> Need current batch Status for potential "revert Status operation" in updateStatus()
 //Note: this call will Always use the empty claims (NoAuth) option for calling GetById()
-func getCurrentBatchStatus(h *theHandler, requestId string, getBatchRequest model.GetByIdBatch, esClient *elasticsearch.Client, logger logrus.FieldLogger) (status.BatchStatus, *response.ErrorDetailResponse) {
+func getCurrentBatchStatus(ctx context.Context, h *theHandler, requestId string, getBatchRequest model.GetByIdBatch, esClient *elasticsearch.Client, logger logrus.FieldLogger) (status.BatchStatus, *response.ErrorDetailResponse) {
 
 	var claims = auth.HriClaims{} //Always use the empty claims (NoAuth) option
-	getByIdCode, getByIdBody := h.getByIdNoAuth(requestId, getBatchRequest, claims, esClient)
+	getByIdCode, getByIdBody := h.getByIdNoAuth(ctx, requestId, getBatchRequest, claims, esClient)
 	if getByIdCode != http.StatusOK { //error getting current Batch Info
 		var errDetail = getByIdBody.(*response.ErrorDetail)
 		newErrMsg := fmt.Sprintf(msgGetByIdErr, errDetail.ErrorDescription)
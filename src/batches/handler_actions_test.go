@@ -7,6 +7,7 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
@@ -37,7 +38,7 @@ type fakeAction struct {
 	body            interface{}
 }
 
-func (fake fakeAction) sendComplete(_ string, request *model.SendCompleteRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
+func (fake fakeAction) sendComplete(_ context.Context, _ string, request *model.SendCompleteRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
 	if !reflect.DeepEqual(fake.expectedRequest, request) {
 		fake.t.Errorf("Request is not equal expected:\n\tExpected: %v\n\tActual:   %v", fake.expectedRequest, request)
 	}
@@ -47,7 +48,7 @@ func (fake fakeAction) sendComplete(_ string, request *model.SendCompleteRequest
 	return fake.code, fake.body
 }
 
-func (fake fakeAction) terminate(_ string, request *model.TerminateRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
+func (fake fakeAction) terminate(_ context.Context, _ string, request *model.TerminateRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
 	if !reflect.DeepEqual(fake.expectedRequest, request) {
 		fake.t.Errorf("Request is not equal expected:\n\tExpected: %v\n\tActual:   %v", fake.expectedRequest, request)
 	}
@@ -57,7 +58,7 @@ func (fake fakeAction) terminate(_ string, request *model.TerminateRequest, _ au
 	return fake.code, fake.body
 }
 
-func (fake fakeAction) processingComplete(_ string, request *model.ProcessingCompleteRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
+func (fake fakeAction) processingComplete(_ context.Context, _ string, request *model.ProcessingCompleteRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
 	if !reflect.DeepEqual(fake.expectedRequest, request) {
 		fake.t.Errorf("Request is not equal expected:\n\tExpected: %v\n\tActual:   %v", fake.expectedRequest, request)
 	}
@@ -67,7 +68,7 @@ func (fake fakeAction) processingComplete(_ string, request *model.ProcessingCom
 	return fake.code, fake.body
 }
 
-func (fake fakeAction) fail(_ string, request *model.FailRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
+func (fake fakeAction) fail(_ context.Context, _ string, request *model.FailRequest, _ auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer, currentStatus status.BatchStatus) (int, interface{}) {
 	if !reflect.DeepEqual(fake.expectedRequest, request) {
 		fake.t.Errorf("Request is not equal expected:\n\tExpected: %v\n\tActual:   %v", fake.expectedRequest, request)
 	}
@@ -83,7 +84,7 @@ const defaultBatchId = test.ValidBatchId
 const defaultBatchName = "monkeeBatch2"
 const defaultBatchDataType = "claims"
 const defaultTopicBase = "awesomeTopic"
-const defaultInputTopic = topicBase + inputSuffix
+const defaultInputTopic = topicBase + ".in"
 const defaultBatchStatus = status.Started //Started
 
 func Test_theHandler_SendComplete(t *testing.T) {
@@ -97,7 +98,7 @@ func Test_theHandler_SendComplete(t *testing.T) {
 	batchName := defaultBatchName
 	batchDataType := defaultBatchDataType
 	topicBase := defaultTopicBase
-	inputTopic := topicBase + inputSuffix
+	inputTopic := topicBase + ".in"
 	defaultStatus := status.Started //Started
 
 	returnBatch := map[string]interface{}{
@@ -214,17 +215,17 @@ func Test_theHandler_SendComplete(t *testing.T) {
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"invalid request arguments:\n- expectedRecordCount (json field in request body) must be 0 or greater"}`, requestId) + "\n",
 		},
 		{
-			name:     "401 unauthorized failure",
+			name:     "403 forbidden failure",
 			tenantId: test.ValidTenantId,
 			batchId:  test.ValidBatchId,
 			handler: theHandler{
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "missing tenant scope"),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, requestId, "missing tenant scope"),
 				},
 			},
 			requestBody:  `{"expectedRecordCount": 100}`,
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"missing tenant scope"}`, requestId) + "\n",
 		},
 		{
@@ -288,17 +289,17 @@ func Test_theHandler_SendComplete(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.requestBody))
-			context, recorder := test.PrepareHeadersContextRecorder(request, e)
-			context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/sendComplete")
-			context.SetParamNames(param.TenantId, param.BatchId)
-			context.SetParamValues(tt.tenantId, tt.batchId)
-			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+			ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+			ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/sendComplete")
+			ctx.SetParamNames(param.TenantId, param.BatchId)
+			ctx.SetParamValues(tt.tenantId, tt.batchId)
+			ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 			//Check for call to getById to retrieve Batch info (status)
 			var expectGetByIdCall = tt.expectedGetByIdCode != 0
 			getByIdCalled := false
 			if expectGetByIdCall {
-				tt.handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+				tt.handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 					getByIdCalled = true
 					if !reflect.DeepEqual(requestBatch, tt.expectedGetByIdRequest) {
 						t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tt.expectedGetByIdRequest, requestBatch))
@@ -308,7 +309,7 @@ func Test_theHandler_SendComplete(t *testing.T) {
 				}
 			}
 
-			if assert.NoError(t, tt.handler.SendComplete(context)) {
+			if assert.NoError(t, tt.handler.SendComplete(ctx)) {
 				assert.Equal(t, tt.expectedCode, recorder.Code)
 				assert.Equal(t, tt.expectedBody, recorder.Body.String())
 				if expectGetByIdCall && !getByIdCalled {
@@ -355,17 +356,17 @@ func Test_theHandler_SendCompleteNoAuth(t *testing.T) {
 	e := test.GetTestServer()
 	t.Run(testName, func(t *testing.T) {
 		request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(requestBody))
-		context, recorder := test.PrepareHeadersContextRecorder(request, e)
-		context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/sendComplete")
-		context.SetParamNames(param.TenantId, param.BatchId)
-		context.SetParamValues(test.ValidTenantId, test.ValidBatchId)
-		context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+		ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+		ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/sendComplete")
+		ctx.SetParamNames(param.TenantId, param.BatchId)
+		ctx.SetParamValues(test.ValidTenantId, test.ValidBatchId)
+		ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 		//Check for call to getById to retrieve Batch info (status)
 		var expectGetByIdCall = expectedGetByIdCode != 0
 		getByIdCalled := false
 		if expectGetByIdCall {
-			handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+			handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 				getByIdCalled = true
 				if !reflect.DeepEqual(requestBatch, expectedGetByIdRequest) {
 					t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", expectedGetByIdRequest, requestBatch))
@@ -375,7 +376,7 @@ func Test_theHandler_SendCompleteNoAuth(t *testing.T) {
 			}
 		}
 
-		if assert.NoError(t, handler.SendComplete(context)) {
+		if assert.NoError(t, handler.SendComplete(ctx)) {
 			assert.Equal(t, expectedRespCode, recorder.Code)
 			assert.Equal(t, expectedRespBody, recorder.Body.String())
 			if expectGetByIdCall && !getByIdCalled {
@@ -481,16 +482,16 @@ func Test_theHandler_Terminate(t *testing.T) {
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"invalid request arguments:\n- id (url path parameter) is a required field\n- tenantId (url path parameter) is a required field"}`, requestId) + "\n",
 		},
 		{
-			name:     "401 unauthorized failure",
+			name:     "403 forbidden failure",
 			tenantId: test.ValidTenantId,
 			batchId:  test.ValidBatchId,
 			handler: theHandler{
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "missing tenant scope"),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, requestId, "missing tenant scope"),
 				},
 			},
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"missing tenant scope"}`, requestId) + "\n",
 		},
 		{
@@ -551,17 +552,17 @@ func Test_theHandler_Terminate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.requestBody))
-			context, recorder := test.PrepareHeadersContextRecorder(request, e)
-			context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/terminate")
-			context.SetParamNames(param.TenantId, param.BatchId)
-			context.SetParamValues(tt.tenantId, tt.batchId)
-			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+			ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+			ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/terminate")
+			ctx.SetParamNames(param.TenantId, param.BatchId)
+			ctx.SetParamValues(tt.tenantId, tt.batchId)
+			ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 			//Check for call to getById to retrieve Batch info (status)
 			var expectGetByIdCall = tt.expectedGetByIdCode != 0
 			getByIdCalled := false
 			if expectGetByIdCall {
-				tt.handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+				tt.handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 					getByIdCalled = true
 					if !reflect.DeepEqual(requestBatch, tt.expectedGetByIdRequest) {
 						t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tt.expectedGetByIdRequest, requestBatch))
@@ -571,7 +572,7 @@ func Test_theHandler_Terminate(t *testing.T) {
 				}
 			}
 
-			if assert.NoError(t, tt.handler.Terminate(context)) {
+			if assert.NoError(t, tt.handler.Terminate(ctx)) {
 				assert.Equal(t, tt.expectedCode, recorder.Code)
 				assert.Equal(t, tt.expectedBody, recorder.Body.String())
 				if expectGetByIdCall && !getByIdCalled {
@@ -620,17 +621,17 @@ func Test_theHandler_TerminateNoAuth(t *testing.T) {
 	e := test.GetTestServer()
 	t.Run(testName, func(t *testing.T) {
 		request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(requestBody))
-		context, recorder := test.PrepareHeadersContextRecorder(request, e)
-		context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/terminate")
-		context.SetParamNames(param.TenantId, param.BatchId)
-		context.SetParamValues(test.ValidTenantId, test.ValidBatchId)
-		context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+		ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+		ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/terminate")
+		ctx.SetParamNames(param.TenantId, param.BatchId)
+		ctx.SetParamValues(test.ValidTenantId, test.ValidBatchId)
+		ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 		//Check for call to getById to retrieve Batch info (status)
 		var expectGetByIdCall = expectedGetByIdCode != 0
 		getByIdCalled := false
 		if expectGetByIdCall {
-			handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+			handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 				getByIdCalled = true
 				if !reflect.DeepEqual(requestBatch, expectedGetByIdRequest) {
 					t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", expectedGetByIdRequest, requestBatch))
@@ -639,7 +640,7 @@ func Test_theHandler_TerminateNoAuth(t *testing.T) {
 			}
 		}
 
-		if assert.NoError(t, handler.Terminate(context)) {
+		if assert.NoError(t, handler.Terminate(ctx)) {
 			assert.Equal(t, returnCode, recorder.Code)
 			assert.Equal(t, responseBody, recorder.Body.String())
 			if expectGetByIdCall && !getByIdCalled {
@@ -777,17 +778,17 @@ func Test_theHandler_ProcessingComplete(t *testing.T) {
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"invalid request arguments:\n- invalidRecordCount (json field in request body) is a required field"}`, requestId) + "\n",
 		},
 		{
-			name:     "401 unauthorized failure",
+			name:     "403 forbidden failure",
 			tenantId: test.ValidTenantId,
 			batchId:  test.ValidBatchId,
 			handler: theHandler{
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "missing tenant scope"),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, requestId, "missing tenant scope"),
 				},
 			},
 			requestBody:  `{"actualRecordCount":100,"invalidRecordCount":10}`,
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"missing tenant scope"}`, requestId) + "\n",
 		},
 		{
@@ -851,17 +852,17 @@ func Test_theHandler_ProcessingComplete(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.requestBody))
-			context, recorder := test.PrepareHeadersContextRecorder(request, e)
-			context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/processingComplete")
-			context.SetParamNames(param.TenantId, param.BatchId)
-			context.SetParamValues(tt.tenantId, tt.batchId)
-			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+			ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+			ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/processingComplete")
+			ctx.SetParamNames(param.TenantId, param.BatchId)
+			ctx.SetParamValues(tt.tenantId, tt.batchId)
+			ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 			//Check for call to getById to retrieve Batch info (status)
 			var expectGetByIdCall = tt.expectedGetByIdCode != 0
 			getByIdCalled := false
 			if expectGetByIdCall {
-				tt.handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+				tt.handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 					getByIdCalled = true
 					if !reflect.DeepEqual(requestBatch, tt.expectedGetByIdRequest) {
 						t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tt.expectedGetByIdRequest, requestBatch))
@@ -870,7 +871,7 @@ func Test_theHandler_ProcessingComplete(t *testing.T) {
 				}
 			}
 
-			if assert.NoError(t, tt.handler.ProcessingComplete(context)) {
+			if assert.NoError(t, tt.handler.ProcessingComplete(ctx)) {
 				assert.Equal(t, tt.expectedCode, recorder.Code)
 				assert.Equal(t, tt.expectedBody, recorder.Body.String())
 				if expectGetByIdCall && !getByIdCalled {
@@ -918,17 +919,17 @@ func Test_theHandler_ProcessingCompleteNoAuth(t *testing.T) {
 	e := test.GetTestServer()
 	t.Run(testName, func(t *testing.T) {
 		request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(requestBody))
-		context, recorder := test.PrepareHeadersContextRecorder(request, e)
-		context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/processingComplete")
-		context.SetParamNames(param.TenantId, param.BatchId)
-		context.SetParamValues(test.ValidTenantId, test.ValidBatchId)
-		context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+		ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+		ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/processingComplete")
+		ctx.SetParamNames(param.TenantId, param.BatchId)
+		ctx.SetParamValues(test.ValidTenantId, test.ValidBatchId)
+		ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 		//Check for call to getById to retrieve Batch info (status)
 		var expectGetByIdCall = expectedGetByIdCode != 0
 		getByIdCalled := false
 		if expectGetByIdCall {
-			handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+			handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 				getByIdCalled = true
 				if !reflect.DeepEqual(requestBatch, expectedGetByIdRequest) {
 					t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", expectedGetByIdRequest, requestBatch))
@@ -937,7 +938,7 @@ func Test_theHandler_ProcessingCompleteNoAuth(t *testing.T) {
 			}
 		}
 
-		if assert.NoError(t, handler.ProcessingComplete(context)) {
+		if assert.NoError(t, handler.ProcessingComplete(ctx)) {
 			assert.Equal(t, responseCode, recorder.Code)
 			assert.Equal(t, responseBody, recorder.Body.String())
 
@@ -1028,17 +1029,17 @@ func Test_theHandler_Fail(t *testing.T) {
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"invalid request arguments:\n- failureMessage (json field in request body) is a required field"}`, requestId) + "\n",
 		},
 		{
-			name:     "401 unauthorized failure",
+			name:     "403 forbidden failure",
 			tenantId: test.ValidTenantId,
 			batchId:  test.ValidBatchId,
 			handler: theHandler{
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "missing tenant scope"),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, requestId, "missing tenant scope"),
 				},
 			},
 			requestBody:  `{"actualRecordCount":100,"invalidRecordCount":10,"failureMessage":"a bad batch"}`,
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: fmt.Sprintf(`{"errorEventId":"%s","errorDescription":"missing tenant scope"}`, requestId) + "\n",
 		},
 		{
@@ -1102,17 +1103,17 @@ func Test_theHandler_Fail(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.requestBody))
-			context, recorder := test.PrepareHeadersContextRecorder(request, e)
-			context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/fail")
-			context.SetParamNames(param.TenantId, param.BatchId)
-			context.SetParamValues(tt.tenantId, tt.batchId)
-			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+			ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+			ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/fail")
+			ctx.SetParamNames(param.TenantId, param.BatchId)
+			ctx.SetParamValues(tt.tenantId, tt.batchId)
+			ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 			//Check for call to getById to retrieve Batch info (status)
 			var expectGetByIdCall = tt.expectedGetByIdCode != 0
 			getByIdCalled := false
 			if expectGetByIdCall {
-				tt.handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+				tt.handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 					getByIdCalled = true
 					if !reflect.DeepEqual(requestBatch, tt.expectedGetByIdRequest) {
 						t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tt.expectedGetByIdRequest, requestBatch))
@@ -1121,7 +1122,7 @@ func Test_theHandler_Fail(t *testing.T) {
 				}
 			}
 
-			if assert.NoError(t, tt.handler.Fail(context)) {
+			if assert.NoError(t, tt.handler.Fail(ctx)) {
 				assert.Equal(t, tt.expectedCode, recorder.Code)
 				assert.Equal(t, tt.expectedBody, recorder.Body.String())
 				if expectGetByIdCall && !getByIdCalled {
@@ -1169,17 +1170,17 @@ func Test_theHandler_FailNoAuth(t *testing.T) {
 	e := test.GetTestServer()
 	t.Run(testName, func(t *testing.T) {
 		request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(requestBody))
-		context, recorder := test.PrepareHeadersContextRecorder(request, e)
-		context.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/fail")
-		context.SetParamNames(param.TenantId, param.BatchId)
-		context.SetParamValues(test.ValidTenantId, test.ValidBatchId)
-		context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+		ctx, recorder := test.PrepareHeadersContextRecorder(request, e)
+		ctx.SetPath("/hri/tenant/:tenantId/batches/:batchId/action/fail")
+		ctx.SetParamNames(param.TenantId, param.BatchId)
+		ctx.SetParamValues(test.ValidTenantId, test.ValidBatchId)
+		ctx.Response().Header().Add(echo.HeaderXRequestID, requestId)
 
 		//Check for call to getById to retrieve Batch info (status)
 		var expectGetByIdCall = expectedGetByIdCode != 0
 		getByIdCalled := false
 		if expectGetByIdCall {
-			handler.getByIdNoAuth = func(_ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
+			handler.getByIdNoAuth = func(_ context.Context, _ string, requestBatch model.GetByIdBatch, _ auth.HriClaims, esClient *elasticsearch.Client) (int, interface{}) {
 				getByIdCalled = true
 				if !reflect.DeepEqual(requestBatch, expectedGetByIdRequest) {
 					t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", expectedGetByIdRequest, requestBatch))
@@ -1188,7 +1189,7 @@ func Test_theHandler_FailNoAuth(t *testing.T) {
 			}
 		}
 
-		if assert.NoError(t, handler.Fail(context)) {
+		if assert.NoError(t, handler.Fail(ctx)) {
 			assert.Equal(t, code, recorder.Code)
 			assert.Equal(t, responseBody, recorder.Body.String())
 			if expectGetByIdCall && !getByIdCalled {
@@ -1246,7 +1247,7 @@ func Test_theHandler_getCurrentBatchStatus(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getByIdNoAuth: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getByIdNoAuth: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusOK, defaultGetByIdResult
 				},
 			},
@@ -1261,7 +1262,7 @@ func Test_theHandler_getCurrentBatchStatus(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getByIdNoAuth: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getByIdNoAuth: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusNotFound, response.NewErrorDetail("", "The document for tenantId: testTenant with document (batch) ID: funbatch1 was not found")
 				},
 			},
@@ -1276,7 +1277,7 @@ func Test_theHandler_getCurrentBatchStatus(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getByIdNoAuth: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getByIdNoAuth: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusOK, badGetByIdResult
 				},
 			},
@@ -1292,7 +1293,7 @@ func Test_theHandler_getCurrentBatchStatus(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			batchStatus, errDetail := getCurrentBatchStatus(&tt.handler, requestId, getBatchRequest, esClient, logger)
+			batchStatus, errDetail := getCurrentBatchStatus(context.Background(), &tt.handler, requestId, getBatchRequest, esClient, logger)
 
 			assert.Equal(t, tt.expectedBatchStatus, batchStatus)
 			if batchStatus == status.Unknown || tt.expectedErrResponse != nil {
@@ -7,17 +7,21 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/ratelimit"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/Alvearie/hri-mgmt-api/common/test"
 	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -39,6 +43,7 @@ func TestNewHandler(t *testing.T) {
 	handler := NewHandler(config).(*theHandler)
 	assert.Equal(t, config, handler.config)
 	assert.NotNil(t, handler.jwtValidator)
+	assert.NotNil(t, handler.limiter)
 	// This asserts that they are the same function by memory address;
 	assert.Equal(t, reflect.ValueOf(Create), reflect.ValueOf(handler.create))
 	assert.Equal(t, reflect.ValueOf(GetById), reflect.ValueOf(handler.getById))
@@ -57,6 +62,7 @@ func TestNewHandlerNoAuthFunctions(t *testing.T) {
 	handler := NewHandler(config).(*theHandler)
 	assert.Equal(t, config, handler.config)
 	assert.Nil(t, handler.jwtValidator)
+	assert.NotNil(t, handler.limiter)
 
 	assert.Equal(t, reflect.ValueOf(CreateNoAuth), reflect.ValueOf(handler.create))
 	assert.Equal(t, reflect.ValueOf(GetNoAuth), reflect.ValueOf(handler.get))
@@ -74,7 +80,11 @@ type fakeAuthValidator struct {
 	errResp *response.ErrorDetailResponse
 }
 
-func (f fakeAuthValidator) GetValidatedClaims(_ string, _ string, _ string) (auth.HriClaims, *response.ErrorDetailResponse) {
+func (f fakeAuthValidator) GetValidatedClaims(_ string, _ *http.Request, _ string) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func (f fakeAuthValidator) GetValidatedAdminClaims(_ string, _ *http.Request) (auth.HriClaims, *response.ErrorDetailResponse) {
 	return f.claims, f.errResp
 }
 
@@ -155,7 +165,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusCreated, map[string]interface{}{"batchId": "1234-unique-id"}
 				},
 			},
@@ -172,7 +182,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{Subject: integratorId + "中文"},
 					errResp: nil,
 				},
-				create: func(_ string, create model.CreateBatch, claims auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer) (int, interface{}) {
+				create: func(_ context.Context, _ string, create model.CreateBatch, claims auth.HriClaims, _ *elasticsearch.Client, _ kafka.Writer) (int, interface{}) {
 					assert.Equal(t, batchName+"中文", create.Name)
 					assert.Equal(t, integratorId+"中文", claims.Subject)
 					assert.Equal(t, topic+"中文", create.Topic)
@@ -194,7 +204,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -211,7 +221,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -228,7 +238,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -245,7 +255,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -262,7 +272,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -279,7 +289,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusCreated, map[string]interface{}{"batchId": "1234-unique-id"}
 				},
 			},
@@ -294,13 +304,13 @@ func Test_theHandler_Create(t *testing.T) {
 				config: testConfig,
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "Unauthorized tenant access. Tenant '"+unauthorizedTenantId+"' is not included in the authorized scopes."),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, requestId, "Unauthorized tenant access. Tenant '"+unauthorizedTenantId+"' is not included in the authorized scopes."),
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			tenant:       unauthorizedTenantId,
 			requestBody:  validReqBody,
 			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Unauthorized tenant access. Tenant '" + unauthorizedTenantId + "' is not included in the authorized scopes.\"}\n",
@@ -313,7 +323,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -330,7 +340,7 @@ func Test_theHandler_Create(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+				create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -388,6 +398,84 @@ func Test_theHandler_Create(t *testing.T) {
 	}
 }
 
+func Test_theHandler_CreateRateLimited(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	var testConfig = createDefaultTestConfig()
+	// slow enough that the bucket doesn't refill while the first request closes its Kafka writer
+	testConfig.RateLimitClientRps = 0.01
+	testConfig.RateLimitClientBurst = 1
+	validTenantId := "tenant_33-z"
+	requestBody := fmt.Sprintf(`{"name": "%s", "topic": "%s", "dataType": "%s"}`, batchName, topic, batchDataType)
+
+	creates := 0
+	handler := theHandler{
+		config: testConfig,
+		jwtValidator: fakeAuthValidator{
+			claims: auth.HriClaims{Subject: integratorId},
+		},
+		limiter: ratelimit.NewLimiterWithQuotas(testConfig, func(context.Context, string) (*model.TenantQuota, error) {
+			return nil, nil
+		}),
+		create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+			creates++
+			return http.StatusCreated, map[string]interface{}{"batchId": "1234-unique-id"}
+		},
+	}
+
+	e := test.GetTestServer()
+	codes := []int{}
+	var recorder *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(requestBody))
+		var context echo.Context
+		context, recorder = test.PrepareHeadersContextRecorder(request, e)
+		context.SetPath("/hri/tenant/:" + param.TenantId + "/batches")
+		context.SetParamNames(param.TenantId)
+		context.SetParamValues(validTenantId)
+		if assert.NoError(t, handler.Create(context)) {
+			codes = append(codes, recorder.Code)
+		}
+	}
+
+	assert.Equal(t, []int{http.StatusCreated, http.StatusTooManyRequests}, codes)
+	assert.Equal(t, 1, creates)
+	assert.Regexp(t, "^(9[0-9]|100)$", recorder.Header().Get(ratelimit.RetryAfterHeader))
+	assert.Contains(t, recorder.Body.String(), "Client [sub:integratorId] exceeded its rate limit, retry after ")
+}
+
+func Test_theHandler_CreateQuotaRejected(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	var testConfig = createDefaultTestConfig()
+	requestBody := fmt.Sprintf(`{"name": "%s", "topic": "%s", "dataType": "%s"}`, batchName, topic, batchDataType)
+	detail := response.NewErrorDetail(requestId, fmt.Sprintf(msgActiveBatchQuota, "tenant_33-z", 2))
+
+	handler := theHandler{
+		config: testConfig,
+		jwtValidator: fakeAuthValidator{
+			claims: auth.HriClaims{Subject: integratorId},
+		},
+		limiter: ratelimit.NewLimiterWithQuotas(testConfig, func(context.Context, string) (*model.TenantQuota, error) {
+			return nil, nil
+		}),
+		create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+			return http.StatusTooManyRequests, &ratelimit.Rejection{RetryAfter: openBatchQuotaRetryAfter, Detail: detail}
+		},
+	}
+
+	e := test.GetTestServer()
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(requestBody))
+	context, recorder := test.PrepareHeadersContextRecorder(request, e)
+	context.SetPath("/hri/tenant/:" + param.TenantId + "/batches")
+	context.SetParamNames(param.TenantId)
+	context.SetParamValues("tenant_33-z")
+
+	if assert.NoError(t, handler.Create(context)) {
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		assert.Equal(t, "300", recorder.Header().Get(ratelimit.RetryAfterHeader))
+		assert.Contains(t, recorder.Body.String(), "Tenant [tenant_33-z] has reached its quota of 2 active batches")
+	}
+}
+
 func Test_theHandler_CreateNoAuth(t *testing.T) {
 	var testConfig = createDefaultTestConfig()
 	testConfig.AuthDisabled = true
@@ -407,7 +495,7 @@ func Test_theHandler_CreateNoAuth(t *testing.T) {
 
 	handler := theHandler{
 		config: testConfig,
-		create: func(string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
+		create: func(context.Context, string, model.CreateBatch, auth.HriClaims, *elasticsearch.Client, kafka.Writer) (int, interface{}) {
 			return http.StatusCreated, map[string]interface{}{"batchId": "batch-675-unique-id"}
 		},
 	}
@@ -451,7 +539,7 @@ func Test_myHandler_GetById(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getById: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getById: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusOK, map[string]interface{}{"id": "batch7j3", "name": "monkeyBatch", "status": "started", "startDate": "2019-12-13", "dataType": "claims", "topic": "ingest-test", "recordCount": float64(1), "expectedRecordCount": float64(1)}
 				},
 			},
@@ -468,7 +556,7 @@ func Test_myHandler_GetById(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getById: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getById: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusNotFound, response.NewErrorDetail("", "The document for tenantId: BAD-93TENant-1 with document (batch) ID: batch7j3 was not found")
 				},
 			},
@@ -485,7 +573,7 @@ func Test_myHandler_GetById(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getById: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getById: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -501,7 +589,7 @@ func Test_myHandler_GetById(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				getById: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getById: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -515,13 +603,13 @@ func Test_myHandler_GetById(t *testing.T) {
 				config: testConfig,
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, "requestId", "Unauthorized tenant access. Tenant 'unauthorized_tenant' is not included in the authorized scopes."),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, "requestId", "Unauthorized tenant access. Tenant 'unauthorized_tenant' is not included in the authorized scopes."),
 				},
-				getById: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				getById: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			tenant:       "unauthorized_tenant",
 			batchId:      validBatchId,
 			expectedBody: "{\"errorEventId\":\"\",\"errorDescription\":\"Unauthorized tenant access. Tenant 'unauthorized_tenant' is not included in the authorized scopes.\"}\n",
@@ -574,7 +662,7 @@ func Test_theHandler_GetByIdNoAuth(t *testing.T) {
 
 	handler := theHandler{
 		config: testConfig,
-		getById: func(string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+		getById: func(context.Context, string, model.GetByIdBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
 			return http.StatusOK, map[string]interface{}{"id": myBatchId, "name": batchName, "status": "started",
 				"startDate": "2019-12-13", "dataType": "claims", "topic": topic,
 				"recordCount": float64(recCount), "expectedRecordCount": float64(recCount)}
@@ -599,6 +687,9 @@ func Test_theHandler_GetByIdNoAuth(t *testing.T) {
 
 func Test_myHandler_Get(t *testing.T) {
 	var testConfig = createDefaultTestConfig()
+	archiveTestConfig := createDefaultTestConfig()
+	archiveTestConfig.ArchiveStore = config.ArchiveStoreLocal
+	archiveTestConfig.ArchiveDir = t.TempDir()
 	validTenantId := "tenant_33-z"
 	invalidTenantId := "BAD-93TENant-1"
 	unauthorizedTenantId := "unauthorized_tenant"
@@ -613,8 +704,10 @@ func Test_myHandler_Get(t *testing.T) {
 		lteDateParam string
 		sizeParam    string
 		fromParam    string
-		responseCode int
-		responseBody string
+		// includeArchived query parameter
+		includeArchived string
+		responseCode    int
+		responseBody    string
 	}{
 		{
 			name: "success case minimal",
@@ -624,7 +717,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusOK, map[string]interface{}{"total": float64(1), "results": []interface{}{map[string]interface{}{"id": "uuid", "dataType": "rspec-batch", "invalidThreshold": float64(-1), "name": "mybatch", "startDate": "01/02/2019", "status": "started", "topic": "ingest.test.claims.in", "integratorId": "modified-integrator-id", "metadata": map[string]interface{}{"rspec1": "test1"}}}}
 				},
 			},
@@ -640,7 +733,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusOK, map[string]interface{}{"total": float64(1), "results": []interface{}{map[string]interface{}{"id": "uuid", "dataType": "rspec-batch", "invalidThreshold": float64(-1), "name": "mybatch", "startDate": "01/02/2019", "status": "started", "topic": "ingest.test.claims.in", "integratorId": "modified-integrator-id", "metadata": map[string]interface{}{"rspec1": "test1"}}}}
 				},
 			},
@@ -654,6 +747,40 @@ func Test_myHandler_Get(t *testing.T) {
 			responseCode: http.StatusOK,
 			responseBody: "{\"results\":[{\"dataType\":\"rspec-batch\",\"id\":\"uuid\",\"integratorId\":\"modified-integrator-id\",\"invalidThreshold\":-1,\"metadata\":{\"rspec1\":\"test1\"},\"name\":\"mybatch\",\"startDate\":\"01/02/2019\",\"status\":\"started\",\"topic\":\"ingest.test.claims.in\"}],\"total\":1}\n",
 		},
+		{
+			name: "include archived",
+			handler: theHandler{
+				config: archiveTestConfig,
+				jwtValidator: fakeAuthValidator{
+					claims:  auth.HriClaims{},
+					errResp: nil,
+				},
+				get: func(_ context.Context, _ string, params model.GetBatch, _ auth.HriClaims, _ *elasticsearch.Client, store archive.Store) (int, interface{}) {
+					assert.True(t, params.IncludeArchived)
+					assert.NotNil(t, store)
+					return http.StatusOK, map[string]interface{}{"total": float64(1), "results": []interface{}{map[string]interface{}{"id": "uuid", "name": "mybatch", "status": "completed", "archived": true}}}
+				},
+			},
+			tenantId:        validTenantId,
+			includeArchived: "true",
+			responseCode:    http.StatusOK,
+			responseBody:    "{\"results\":[{\"archived\":true,\"id\":\"uuid\",\"name\":\"mybatch\",\"status\":\"completed\"}],\"total\":1}\n",
+		},
+		{
+			name: "include archived without archive store",
+			handler: theHandler{
+				config: testConfig,
+				jwtValidator: fakeAuthValidator{
+					claims:  auth.HriClaims{},
+					errResp: nil,
+				},
+				get: nil,
+			},
+			tenantId:        validTenantId,
+			includeArchived: "true",
+			responseCode:    http.StatusBadRequest,
+			responseBody:    "{\"errorEventId\":\"\",\"errorDescription\":\"archived batches are not available: no archive store is configured\"}\n",
+		},
 		{
 			name: "missing tenantId param",
 			handler: theHandler{
@@ -662,7 +789,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -677,7 +804,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusNotFound, response.NewErrorDetail("", "The document for tenantId: "+invalidTenantId+" was not found")
 				},
 			},
@@ -693,7 +820,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -710,7 +837,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -727,7 +854,7 @@ func Test_myHandler_Get(t *testing.T) {
 					claims:  auth.HriClaims{},
 					errResp: nil,
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -743,14 +870,14 @@ func Test_myHandler_Get(t *testing.T) {
 				config: testConfig,
 				jwtValidator: fakeAuthValidator{
 					claims:  auth.HriClaims{},
-					errResp: response.NewErrorDetailResponse(http.StatusUnauthorized, "requestId", "Unauthorized tenant access. Tenant '"+unauthorizedTenantId+"' is not included in the authorized scopes."),
+					errResp: response.NewErrorDetailResponse(http.StatusForbidden, "requestId", "Unauthorized tenant access. Tenant '"+unauthorizedTenantId+"' is not included in the authorized scopes."),
 				},
-				get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
 			tenantId:     unauthorizedTenantId,
-			responseCode: http.StatusUnauthorized,
+			responseCode: http.StatusForbidden,
 			responseBody: "{\"errorEventId\":\"\",\"errorDescription\":\"Unauthorized tenant access. Tenant '" + unauthorizedTenantId + "' is not included in the authorized scopes.\"}\n",
 		},
 		{
@@ -780,6 +907,7 @@ func Test_myHandler_Get(t *testing.T) {
 				{param.LteDate, tt.lteDateParam},
 				{param.Size, tt.sizeParam},
 				{param.From, tt.fromParam},
+				{param.IncludeArchived, tt.includeArchived},
 			}
 			for _, paramPair := range queryParams {
 				if len(paramPair[1]) > 0 {
@@ -815,7 +943,7 @@ func Test_theHandler_GetNoAuth(t *testing.T) {
 
 	handler := theHandler{
 		config: testConfig,
-		get: func(string, model.GetBatch, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+		get: func(context.Context, string, model.GetBatch, auth.HriClaims, *elasticsearch.Client, archive.Store) (int, interface{}) {
 			return http.StatusOK, map[string]interface{}{"total": float64(1), "results": []interface{}{map[string]interface{}{"id": myBatchId, "dataType": "claims",
 				"invalidThreshold": float64(-1), "name": batchName,
 				"startDate": startDate, "status": "started",
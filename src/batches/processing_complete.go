@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
@@ -22,6 +23,7 @@ import (
 )
 
 func ProcessingComplete(
+	ctx context.Context,
 	requestId string,
 	request *model.ProcessingCompleteRequest,
 	claims auth.HriClaims,
@@ -30,41 +32,45 @@ func ProcessingComplete(
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/ProcessingComplete"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 	logger.Debugln("Start Batch Processing Complete")
 
-	if !claims.HasScope(auth.HriInternal) {
+	if !auth.Authorize(claims, request.TenantId, auth.ActionProcessingComplete).Allowed {
 		msg := fmt.Sprintf(auth.MsgInternalRoleRequired, "processingComplete")
 		logger.Errorln(msg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, msg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, msg)
 	}
 
-	return processingComplete(requestId, request, esClient, writer, logger, currentStatus)
+	return processingComplete(ctx, requestId, request, esClient, writer, logger, currentStatus)
 }
 
-func ProcessingCompleteNoAuth(requestId string,
+func ProcessingCompleteNoAuth(ctx context.Context, requestId string,
 	request *model.ProcessingCompleteRequest,
 	_ auth.HriClaims, esClient *elasticsearch.Client,
 	writer kafka.Writer,
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/ProcessingCompleteNoAuth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 	logger.Debugln("Start Batch Processing Complete (No Auth)")
 
-	return processingComplete(requestId, request, esClient, writer, logger, currentStatus)
+	return processingComplete(ctx, requestId, request, esClient, writer, logger, currentStatus)
 }
 
-func processingComplete(requestId string,
+func processingComplete(ctx context.Context, requestId string,
 	request *model.ProcessingCompleteRequest,
 	esClient *elasticsearch.Client,
 	writer kafka.Writer,
 	logger logrus.FieldLogger,
 	currentStatus status.BatchStatus) (int, interface{}) {
 
+	if _, errResp := getActiveTenantConfig(ctx, requestId, request.TenantId, "processingComplete", esClient, logger); errResp != nil {
+		return errResp.Code, errResp.Body
+	}
+
 	updateRequest := getProcessingCompleteUpdateScript(request)
 
-	origBatch, errResp := updateStatus(requestId, request.TenantId, request.BatchId, updateRequest, esClient, writer, currentStatus)
+	origBatch, errResp := updateStatus(ctx, requestId, request.TenantId, request.BatchId, updateRequest, esClient, writer, currentStatus)
 	if errResp != nil {
 		return errResp.Code, errResp.Body
 	}
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -97,6 +98,12 @@ func Test_ProcessingComplete(t *testing.T) {
 		t.Errorf("Unable to create batch JSON string: %s", err.Error())
 	}
 
+	tenantConfigPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, test.ValidTenantId)
+	noTenantConfig := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, test.ValidTenantId),
+	}
+
 	tests := []struct {
 		name                 string
 		request              *model.ProcessingCompleteRequest
@@ -112,6 +119,8 @@ func Test_ProcessingComplete(t *testing.T) {
 			request: getValidTestProcessingCompleteRequest(),
 			claims:  validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -137,7 +146,7 @@ func Test_ProcessingComplete(t *testing.T) {
 			request:              getValidTestProcessingCompleteRequest(),
 			claims:               auth.HriClaims{},
 			expectedNotification: completedBatch,
-			expectedCode:         http.StatusUnauthorized,
+			expectedCode:         http.StatusForbidden,
 			expectedResponse:     response.NewErrorDetail(requestId, "Must have hri_internal role to mark a batch as processingComplete"),
 		},
 		{
@@ -145,6 +154,8 @@ func Test_ProcessingComplete(t *testing.T) {
 			request: getValidTestProcessingCompleteRequest(),
 			claims:  validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -170,6 +181,8 @@ func Test_ProcessingComplete(t *testing.T) {
 			request: getValidTestProcessingCompleteRequest(),
 			claims:  validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -189,6 +202,20 @@ func Test_ProcessingComplete(t *testing.T) {
 			expectedCode:     http.StatusConflict,
 			expectedResponse: response.NewErrorDetail(requestId, "processingComplete failed, batch is in 'failed' state"),
 		},
+		{
+			name:    "processingComplete fails on suspended tenant",
+			request: getValidTestProcessingCompleteRequest(),
+			claims:  validClaims,
+			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"suspension":{"suspendDate":"2021-02-24T18:08:36Z"}}}`,
+						elastic.TenantsIndex, test.ValidTenantId),
+				},
+			),
+			expectedCode:     http.StatusLocked,
+			expectedResponse: response.NewErrorDetail(requestId, "processingComplete failed, tenant [134340] is suspended"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -205,7 +232,7 @@ func Test_ProcessingComplete(t *testing.T) {
 				Error:         tt.writerError,
 			}
 
-			code, result := ProcessingComplete(requestId, tt.request, tt.claims, esClient, writer, currentStatus)
+			code, result := ProcessingComplete(context.Background(), requestId, tt.request, tt.claims, esClient, writer, currentStatus)
 
 			if tt.ft != nil {
 				tt.ft.VerifyCalls()
@@ -246,6 +273,12 @@ func Test_ProcessingCompleteNoAuth(t *testing.T) {
 
 	logwrapper.Initialize("error", os.Stdout)
 
+	tenantConfigPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, test.ValidTenantId)
+	noTenantConfig := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, test.ValidTenantId),
+	}
+
 	tests := []struct {
 		name                 string
 		request              model.ProcessingCompleteRequest
@@ -259,6 +292,8 @@ func Test_ProcessingCompleteNoAuth(t *testing.T) {
 			name:    "successful processingComplete",
 			request: *getValidTestProcessingCompleteRequest(),
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId), //"/tenantZzCat44-batches/_doc/batch789J/_update",
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -296,7 +331,7 @@ func Test_ProcessingCompleteNoAuth(t *testing.T) {
 			}
 
 			var emptyClaims = auth.HriClaims{}
-			code, result := ProcessingCompleteNoAuth(requestId, &tt.request, emptyClaims, esClient, writer, currentStatus)
+			code, result := ProcessingCompleteNoAuth(context.Background(), requestId, &tt.request, emptyClaims, esClient, writer, currentStatus)
 
 			tt.ft.VerifyCalls()
 
@@ -0,0 +1,346 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/batches/status"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/param/esparam"
+	"github.com/Alvearie/hri-mgmt-api/common/tracing"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const retentionMonitorRequestId = "batch-retention-monitor"
+
+// MaxArchivedBatches is the max number of batches written to one archive. The retention monitor archives at most this
+// many batches per tenant in one check, the rest are picked up by the next check.
+const MaxArchivedBatches = 1000
+
+// batches in these states can't change anymore, so they can be archived
+var terminalStatuses = []string{status.Completed.String(), status.Failed.String(), status.Terminated.String()}
+
+// the batches under legal hold, which are never archived
+var onLegalHold = map[string]interface{}{"exists": map[string]interface{}{"field": param.LegalHold}}
+
+// StartRetentionMonitor periodically archives the batches that exceeded their tenant's retentionDays, or the server's
+// batch retention days, and removes them from Elastic. The returned function stops the monitor.
+func StartRetentionMonitor(config config.Config) func() {
+	ticker := time.NewTicker(time.Duration(config.RetentionCheckSecs) * time.Second)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				checkRetention(config)
+			}
+		}
+	}()
+
+	// waits for a check that's in progress, so it isn't cut off midway
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func checkRetention(config config.Config) {
+	prefix := "batches/retentionMonitor"
+	var logger = logwrapper.GetMyLogger(retentionMonitorRequestId, prefix)
+
+	esClient, err := elastic.ClientFromConfig(config)
+	if err != nil {
+		logger.Errorf(msgElasticErr, err.Error())
+		return
+	}
+
+	store, err := archive.NewStoreFromConfig(config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(context.Background(), "check batch retention")
+	defer span.End()
+	archiveExpiredBatches(ctx, config.BatchRetentionDays, esClient, store, logger)
+}
+
+// archiveExpiredBatches returns the number of batches that were archived
+func archiveExpiredBatches(ctx context.Context, defaultRetentionDays int, esClient *elasticsearch.Client, store archive.Store,
+	logger logrus.FieldLogger) int {
+
+	tenantIndices, err := elastic.GetTenantIndices(esClient)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return 0
+	}
+
+	tenantConfigs, elasticErr := elastic.GetTenantConfigs(ctx, map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"exists": map[string]interface{}{"field": "retentionDays"}},
+					{"exists": map[string]interface{}{"field": param.LegalHold}},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+	}, esClient)
+	if elasticErr != nil {
+		logger.Errorf("Unable to get tenant retention days: %s", elasticErr.Error())
+		return 0
+	}
+	retentionDays := make(map[string]int, len(tenantConfigs))
+	for _, tenantConfig := range tenantConfigs {
+		if tenantConfig.LegalHold != nil {
+			// nothing is archived while the tenant is under legal hold
+			retentionDays[tenantConfig.TenantId] = 0
+		} else if tenantConfig.RetentionDays != nil {
+			retentionDays[tenantConfig.TenantId] = *tenantConfig.RetentionDays
+		}
+	}
+
+	archived := 0
+	for _, index := range tenantIndices {
+		tenantId := elastic.TenantIdFromIndex(index)
+		days, ok := retentionDays[tenantId]
+		if !ok {
+			days = defaultRetentionDays
+		}
+		if days == 0 {
+			continue
+		}
+
+		count, err := ArchiveBatches(ctx, tenantId, days, esClient, store)
+		if err != nil {
+			logger.Errorf("Unable to archive the expired batches of tenant [%s]: %s", tenantId, err.Error())
+			continue
+		}
+		if count > 0 {
+			logger.Infof("Archived %d batches of tenant [%s], they exceeded the retention of %d days",
+				count, tenantId, days)
+		}
+		archived += count
+	}
+	return archived
+}
+
+// ArchiveBatches writes up to MaxArchivedBatches of the tenant's completed, failed and terminated batches, that ended
+// more than olderThanDays days ago, to a new archive and removes them from Elastic. Batches under legal hold are
+// skipped. Returns the number of removed batches. Batches are only removed after the archive was stored, and only if
+// they haven't changed since they were archived; the batches that did, e.g. that were put under legal hold meanwhile,
+// are kept in Elastic and taken out of the archive.
+func ArchiveBatches(ctx context.Context, tenantId string, olderThanDays int, esClient *elasticsearch.Client, store archive.Store) (int, error) {
+	index := elastic.IndexFromTenantId(tenantId)
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"terms": map[string]interface{}{param.Status: terminalStatuses}},
+					{"range": map[string]interface{}{param.EndDate: map[string]interface{}{"lt": fmt.Sprintf("now-%dd", olderThanDays)}}},
+				},
+				"must_not": onLegalHold,
+			},
+		},
+		"sort": []map[string]interface{}{{param.EndDate: "asc"}},
+	}
+	buf, err := elastic.EncodeQueryBody(query)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := esClient.Search(
+		esClient.Search.WithContext(ctx),
+		esClient.Search.WithIndex(index),
+		esClient.Search.WithBody(buf),
+		esClient.Search.WithSize(MaxArchivedBatches),
+		esClient.Search.WithSeqNoPrimaryTerm(true),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return 0, elasticErr
+	}
+
+	hits := body["hits"].(map[string]interface{})["hits"].([]interface{})
+	if len(hits) == 0 {
+		return 0, nil
+	}
+	batches := make([]map[string]interface{}, 0, len(hits))
+	versions := make([]batchVersion, 0, len(hits))
+	for _, hit := range hits {
+		esDoc := hit.(map[string]interface{})
+		batch := esDoc["_source"].(map[string]interface{})
+		batch[param.BatchId] = esDoc[esparam.EsDocId]
+		batches = append(batches, batch)
+		seqNo, _ := esDoc["_seq_no"].(float64)
+		primaryTerm, _ := esDoc["_primary_term"].(float64)
+		versions = append(versions, batchVersion{id: esDoc[esparam.EsDocId].(string), seqNo: int64(seqNo), primaryTerm: int64(primaryTerm)})
+	}
+
+	data, err := archive.EncodeBatches(batches)
+	if err != nil {
+		return 0, err
+	}
+	key := archive.NewKey(tenantId, time.Now())
+	if err := store.Put(key, data); err != nil {
+		return 0, fmt.Errorf("unable to store archive: %w", err)
+	}
+
+	removed, elasticErr := deleteUnchangedBatches(ctx, index, versions, esClient)
+	if elasticErr != nil {
+		return 0, fmt.Errorf("unable to remove the archived batches: %w", elasticErr)
+	}
+	if len(removed) == len(batches) {
+		return len(removed), nil
+	}
+
+	// the batches that are still in Elastic are taken out of the archive, so they aren't listed twice
+	archived := make([]map[string]interface{}, 0, len(removed))
+	for _, batch := range batches {
+		if removed[batch[param.BatchId].(string)] {
+			archived = append(archived, batch)
+		}
+	}
+	if data, err = archive.EncodeBatches(archived); err == nil {
+		err = store.Put(key, data)
+	}
+	if err != nil {
+		return len(removed), fmt.Errorf("unable to take the batches that changed out of archive %s: %w", key, err)
+	}
+	return len(removed), nil
+}
+
+// batchVersion identifies the version of a batch that was archived
+type batchVersion struct {
+	id          string
+	seqNo       int64
+	primaryTerm int64
+}
+
+// deleteUnchangedBatches deletes the batches that are still at the given versions and returns the ids of the batches
+// that are no longer in Elastic. The batches that changed since are kept.
+func deleteUnchangedBatches(ctx context.Context, index string, versions []batchVersion,
+	esClient *elasticsearch.Client) (map[string]bool, *elastic.ResponseError) {
+
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	for _, version := range versions {
+		action := map[string]interface{}{"delete": map[string]interface{}{
+			"_id": version.id, "if_seq_no": version.seqNo, "if_primary_term": version.primaryTerm,
+		}}
+		if err := encoder.Encode(action); err != nil {
+			return nil, &elastic.ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+		}
+	}
+
+	res, err := esClient.Bulk(
+		&buf,
+		esClient.Bulk.WithContext(ctx),
+		esClient.Bulk.WithIndex(index),
+		esClient.Bulk.WithRefresh("true"),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return nil, elasticErr
+	}
+
+	removed := make(map[string]bool, len(versions))
+	items, _ := body["items"].([]interface{})
+	for _, item := range items {
+		itemResult, _ := item.(map[string]interface{})["delete"].(map[string]interface{})
+		status, _ := itemResult["status"].(float64)
+		// a batch that's not found was already removed, a conflict means it changed since it was archived
+		if id, ok := itemResult[esparam.EsDocId].(string); ok && (status == http.StatusOK || status == http.StatusNotFound) {
+			removed[id] = true
+		}
+	}
+	return removed, nil
+}
+
+// getArchivedBatches returns the archived batches that match the Get parameters, from all the tenant's archives,
+// newest first. The archives are read one at a time and only the matching batches are kept. A batch can be in two
+// archives when two servers archived it at the same time, so only its newest copy is returned. Each batch has
+// 'archived' set to true.
+func getArchivedBatches(params model.GetBatch, claims *auth.HriClaims, store archive.Store,
+	logger logrus.FieldLogger) ([]interface{}, error) {
+
+	keys, err := store.List(archive.TenantPrefix(params.TenantId))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, 0)
+	seen := make(map[interface{}]bool)
+	// the keys are sorted by the time they were archived
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+		data, err := store.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %w", key, err)
+		}
+		batches, err := archive.DecodeBatches(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %w", key, err)
+		}
+		for _, batch := range batches {
+			if id := batch[param.BatchId]; seen[id] {
+				logger.Debugf("Batch %v is in more than one archive, archive %s is skipped", id, key)
+				continue
+			}
+			seen[batch[param.BatchId]] = true
+			if matchesGetParams(batch, params, claims) {
+				batch = NormalizeBatchRecordCountValues(batch)
+				batch[param.Archived] = true
+				results = append(results, batch)
+			}
+		}
+	}
+	return results, nil
+}
+
+// matchesGetParams applies the same restrictions as buildQuery to an archived batch
+func matchesGetParams(batch map[string]interface{}, params model.GetBatch, claims *auth.HriClaims) bool {
+	if params.Name != nil && batch[param.Name] != *params.Name {
+		return false
+	}
+	if params.Status != nil && batch[param.Status] != *params.Status {
+		return false
+	}
+
+	// dates are ISO 8601, so they can be compared as strings. Like Elastic, a partial lteDate includes the whole
+	// period, i.e. lteDate=2021-02-24 includes batches started during that day.
+	startDate, _ := batch[param.StartDate].(string)
+	if params.GteDate != nil && startDate < *params.GteDate {
+		return false
+	}
+	if params.LteDate != nil && !(startDate <= *params.LteDate || strings.HasPrefix(startDate, *params.LteDate)) {
+		return false
+	}
+
+	if claims != nil && !auth.Authorize(*claims, params.TenantId, auth.ActionGetBatches).Permits(batch) {
+		return false
+	}
+	return true
+}
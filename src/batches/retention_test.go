@@ -0,0 +1,342 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	deleteArchivedBody = `{"delete":{"_id":"batch1","if_primary_term":1,"if_seq_no":5}}` + "\n" +
+		`{"delete":{"_id":"batch2","if_primary_term":1,"if_seq_no":6}}` + "\n"
+	expiredSearchBody = `{"query":{"bool":{"filter":\[{"terms":{"status":\["completed","failed","terminated"\]}},{"range":{"endDate":{"lt":"now-%dd"}}}\],"must_not":{"exists":{"field":"legalHold"}}}},"sort":\[{"endDate":"asc"}\]}` + "\n"
+	expiredBatches    = `{"hits":{"hits":[` +
+		`{"_id":"batch1","_seq_no":5,"_primary_term":1,"_source":{"name":"first","status":"completed","startDate":"2021-01-02T10:00:00Z","endDate":"2021-01-02T11:00:00Z","integratorId":"integrator1","expectedRecordCount":10}},` +
+		`{"_id":"batch2","_seq_no":6,"_primary_term":1,"_source":{"name":"second","status":"terminated","startDate":"2021-01-03T10:00:00Z","endDate":"2021-01-03T11:00:00Z","integratorId":"integrator2"}}]}}`
+)
+
+func TestArchiveBatches(t *testing.T) {
+	batchesPath := fmt.Sprintf("/%s-batches/_search", test.ValidTenantId)
+	deletePath := fmt.Sprintf("/%s-batches/_bulk", test.ValidTenantId)
+	batch1 := map[string]interface{}{"id": "batch1", "name": "first", "status": "completed", "startDate": "2021-01-02T10:00:00Z", "endDate": "2021-01-02T11:00:00Z", "integratorId": "integrator1", "expectedRecordCount": float64(10)}
+	batch2 := map[string]interface{}{"id": "batch2", "name": "second", "status": "terminated", "startDate": "2021-01-03T10:00:00Z", "endDate": "2021-01-03T11:00:00Z", "integratorId": "integrator2"}
+
+	testCases := []struct {
+		name             string
+		transport        *test.FakeTransport
+		storeDir         string
+		expectedArchived int
+		expectedBatches  []map[string]interface{}
+		expectedErr      string
+	}{
+		{
+			name: "archives and removes batches",
+			transport: test.NewFakeTransport(t).
+				AddCall(batchesPath, test.ElasticCall{
+					RequestQuery: "seq_no_primary_term=true&size=1000",
+					RequestBody:  fmt.Sprintf(expiredSearchBody, 30),
+					ResponseBody: expiredBatches,
+				}).
+				AddCall(deletePath, test.ElasticCall{
+					RequestQuery: "refresh=true",
+					RequestBody:  deleteArchivedBody,
+					ResponseBody: `{"items":[{"delete":{"_id":"batch1","status":200}},{"delete":{"_id":"batch2","status":200}}]}`,
+				}),
+			expectedArchived: 2,
+			expectedBatches:  []map[string]interface{}{batch1, batch2},
+		},
+		{
+			name: "batches that changed are taken out of the archive",
+			transport: test.NewFakeTransport(t).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: expiredBatches}).
+				AddCall(deletePath, test.ElasticCall{
+					RequestBody: deleteArchivedBody,
+					// batch2 was put under legal hold after the search
+					ResponseBody: `{"items":[{"delete":{"_id":"batch1","status":200}},{"delete":{"_id":"batch2","status":409}}]}`,
+				}),
+			expectedArchived: 1,
+			expectedBatches:  []map[string]interface{}{batch1},
+		},
+		{
+			name: "batches that were already removed stay archived",
+			transport: test.NewFakeTransport(t).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: expiredBatches}).
+				AddCall(deletePath, test.ElasticCall{
+					ResponseBody: `{"items":[{"delete":{"_id":"batch1","status":404}},{"delete":{"_id":"batch2","status":200}}]}`,
+				}),
+			expectedArchived: 2,
+			expectedBatches:  []map[string]interface{}{batch1, batch2},
+		},
+		{
+			name: "nothing to archive",
+			transport: test.NewFakeTransport(t).AddCall(batchesPath, test.ElasticCall{
+				ResponseBody: `{"hits":{"hits":[]}}`,
+			}),
+		},
+		{
+			name: "search error",
+			transport: test.NewFakeTransport(t).AddCall(batchesPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErr: "elasticsearch client error: connection refused",
+		},
+		{
+			name: "archive error keeps the batches",
+			transport: test.NewFakeTransport(t).AddCall(batchesPath, test.ElasticCall{
+				ResponseBody: expiredBatches,
+			}),
+			storeDir:    "/dev/null/archive",
+			expectedErr: "unable to store archive: mkdir /dev/null: not a directory",
+		},
+		{
+			name: "delete error",
+			transport: test.NewFakeTransport(t).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: expiredBatches}).
+				AddCall(deletePath, test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`,
+				}),
+			expectedErr: "unable to remove the archived batches: index_not_found_exception: no such index",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+			storeDir := tc.storeDir
+			if storeDir == "" {
+				storeDir = t.TempDir()
+			}
+			store := archive.NewLocalStore(storeDir)
+
+			archived, err := ArchiveBatches(context.Background(), test.ValidTenantId, 30, client, store)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+			assert.Equal(t, tc.expectedArchived, archived)
+
+			if tc.expectedBatches != nil {
+				keys, err := store.List(archive.TenantPrefix(test.ValidTenantId))
+				assert.NoError(t, err)
+				if assert.Len(t, keys, 1) {
+					data, _ := store.Get(keys[0])
+					batches, err := archive.DecodeBatches(data)
+					assert.NoError(t, err)
+					assert.Equal(t, tc.expectedBatches, batches)
+				}
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestArchiveExpiredBatches(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	logger := logwrapper.GetMyLogger(retentionMonitorRequestId, "batches/retentionMonitor")
+
+	transport := test.NewFakeTransport(t).
+		AddCall("/_cat/indices/*-batches", test.ElasticCall{
+			ResponseBody: `[{"index":"tenant1-batches"},{"index":"tenant2-batches"},{"index":"tenant3-batches"},{"index":"tenant4-batches"}]`,
+		}).
+		AddCall("/"+elastic.TenantsIndex+"/_search", test.ElasticCall{
+			RequestBody: `{"query":{"bool":{"minimum_should_match":1,"should":\[{"exists":{"field":"retentionDays"}},{"exists":{"field":"legalHold"}}\]}}}`,
+			// tenant4 is under legal hold, so none of its batches are archived
+			ResponseBody: `{"hits":{"hits":[{"_id":"tenant1","_source":{"retentionDays":7}},{"_id":"tenant3","_source":{"retentionDays":90}},` +
+				`{"_id":"tenant4","_source":{"retentionDays":7,"legalHold":{"reason":"litigation","actor":"admin","holdDate":"2021-02-24T18:08:36Z"}}}]}}`,
+		}).
+		AddCall("/tenant1-batches/_search", test.ElasticCall{
+			RequestBody:  fmt.Sprintf(expiredSearchBody, 7),
+			ResponseBody: expiredBatches,
+		}).
+		AddCall("/tenant1-batches/_bulk", test.ElasticCall{
+			ResponseBody: `{"items":[{"delete":{"_id":"batch1","status":200}},{"delete":{"_id":"batch2","status":200}}]}`,
+		}).
+		AddCall("/tenant2-batches/_search", test.ElasticCall{
+			RequestBody: fmt.Sprintf(expiredSearchBody, 30),
+			ResponseErr: errors.New("connection refused"),
+		}).
+		AddCall("/tenant3-batches/_search", test.ElasticCall{
+			RequestBody:  fmt.Sprintf(expiredSearchBody, 90),
+			ResponseBody: `{"hits":{"hits":[]}}`,
+		})
+	client, err := elastic.ClientFromTransport(transport)
+	assert.NoError(t, err)
+
+	archived := archiveExpiredBatches(context.Background(), 30, client, archive.NewLocalStore(t.TempDir()), logger)
+	assert.Equal(t, 2, archived)
+	transport.VerifyCalls()
+
+	// tenants without retentionDays are skipped when there's no default retention
+	transport = test.NewFakeTransport(t).
+		AddCall("/_cat/indices/*-batches", test.ElasticCall{ResponseBody: `[{"index":"tenant2-batches"}]`}).
+		AddCall("/"+elastic.TenantsIndex+"/_search", test.ElasticCall{ResponseBody: `{"hits":{"hits":[]}}`})
+	client, err = elastic.ClientFromTransport(transport)
+	assert.NoError(t, err)
+
+	archived = archiveExpiredBatches(context.Background(), 0, client, archive.NewLocalStore(t.TempDir()), logger)
+	assert.Equal(t, 0, archived)
+	transport.VerifyCalls()
+}
+
+func TestGetIncludeArchived(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	requestId := "reqArchive1"
+	logger := logwrapper.GetMyLogger(requestId, "batches/get")
+
+	store := archive.NewLocalStore(t.TempDir())
+	data, err := archive.EncodeBatches([]map[string]interface{}{
+		{"id": "batch1", "name": "first", "status": "completed", "startDate": "2021-01-02T10:00:00Z", "integratorId": "integrator1", "expectedRecordCount": float64(10)},
+		{"id": "batch2", "name": "second", "status": "terminated", "startDate": "2021-01-03T10:00:00Z", "integratorId": "integrator2"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put(archive.NewKey(test.ValidTenantId, time.Now()), data))
+
+	archivedBatch1 := map[string]interface{}{"id": "batch1", "name": "first", "status": "completed", "startDate": "2021-01-02T10:00:00Z", "integratorId": "integrator1", "expectedRecordCount": float64(10), "recordCount": float64(10), "archived": true}
+	archivedBatch2 := map[string]interface{}{"id": "batch2", "name": "second", "status": "terminated", "startDate": "2021-01-03T10:00:00Z", "integratorId": "integrator2", "archived": true}
+	liveBatch := map[string]interface{}{"id": "batch3", "name": "third", "status": "started"}
+	liveResponse := `{"hits":{"total":{"value":1},"hits":[{"_id":"batch3","_source":{"name":"third","status":"started"}}]}}`
+	emptyResponse := `{"hits":{"total":{"value":1},"hits":[]}}`
+	size1, size2, from1, from2 := 1, 2, 1, 2
+	lteDate := "2021-01-02"
+	integratorClaims := auth.HriClaims{Scope: auth.HriIntegrator, Subject: "integrator2"}
+
+	testCases := []struct {
+		name          string
+		params        model.GetBatch
+		claims        *auth.HriClaims
+		esResponse    string
+		expectedTotal float64
+		expected      []interface{}
+	}{
+		{
+			name:          "live batches first",
+			params:        model.GetBatch{Size: &size2},
+			esResponse:    liveResponse,
+			expectedTotal: 3,
+			expected:      []interface{}{liveBatch, archivedBatch1},
+		},
+		{
+			name:          "archived page",
+			params:        model.GetBatch{Size: &size2, From: &from2},
+			esResponse:    emptyResponse,
+			expectedTotal: 3,
+			expected:      []interface{}{archivedBatch2},
+		},
+		{
+			name:          "full page of live batches",
+			params:        model.GetBatch{Size: &size1},
+			esResponse:    liveResponse,
+			expectedTotal: 3,
+			expected:      []interface{}{liveBatch},
+		},
+		{
+			name:          "archived batches are filtered",
+			params:        model.GetBatch{From: &from1, LteDate: &lteDate},
+			esResponse:    emptyResponse,
+			expectedTotal: 2,
+			expected:      []interface{}{archivedBatch1},
+		},
+		{
+			name:          "integrators only get their own archived batches",
+			params:        model.GetBatch{From: &from1},
+			claims:        &integratorClaims,
+			esResponse:    emptyResponse,
+			expectedTotal: 2,
+			expected:      []interface{}{archivedBatch2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.params.TenantId = test.ValidTenantId
+			tc.params.IncludeArchived = true
+			transport := test.NewFakeTransport(t).AddCall(
+				fmt.Sprintf("/%s-batches/_search", test.ValidTenantId),
+				test.ElasticCall{ResponseBody: tc.esResponse},
+			)
+			client, err := elastic.ClientFromTransport(transport)
+			assert.NoError(t, err)
+
+			code, body := get(context.Background(), requestId, tc.params, tc.claims == nil, tc.claims, client, store, logger)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, map[string]interface{}{"total": tc.expectedTotal, "results": tc.expected}, body)
+			transport.VerifyCalls()
+		})
+	}
+
+	// archive read errors
+	transport := test.NewFakeTransport(t).AddCall(
+		fmt.Sprintf("/%s-batches/_search", test.ValidTenantId),
+		test.ElasticCall{ResponseBody: liveResponse},
+	)
+	client, err := elastic.ClientFromTransport(transport)
+	assert.NoError(t, err)
+	badStore := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, badStore.Put(archive.NewKey(test.ValidTenantId, time.Now()), []byte("not gzip")))
+
+	code, body := get(context.Background(), requestId, model.GetBatch{TenantId: test.ValidTenantId, IncludeArchived: true}, true, nil, client, badStore, logger)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Contains(t, body.(*response.ErrorDetail).ErrorDescription, "Get archived batches failed: unable to read archive")
+}
+
+func TestGetIncludeArchivedReadsAllArchives(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	requestId := "reqArchive2"
+	logger := logwrapper.GetMyLogger(requestId, "batches/get")
+
+	store := archive.NewLocalStore(t.TempDir())
+	archiveTime := time.Date(2021, 2, 24, 18, 8, 36, 0, time.UTC)
+	archives := [][]map[string]interface{}{
+		{{"id": "batch1", "name": "first"}},
+		{{"id": "batch2"}},
+		// batch1 was archived by two servers at the same time
+		{{"id": "batch1", "name": "second"}, {"id": "batch3"}},
+	}
+	for i, batches := range archives {
+		data, err := archive.EncodeBatches(batches)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Put(archive.NewKey(test.ValidTenantId, archiveTime.Add(time.Duration(i)*time.Hour)), data))
+	}
+
+	transport := test.NewFakeTransport(t).AddCall(
+		fmt.Sprintf("/%s-batches/_search", test.ValidTenantId),
+		test.ElasticCall{ResponseBody: `{"hits":{"total":{"value":0},"hits":[]}}`},
+	)
+	client, err := elastic.ClientFromTransport(transport)
+	assert.NoError(t, err)
+
+	size := 10
+	params := model.GetBatch{TenantId: test.ValidTenantId, IncludeArchived: true, Size: &size}
+	code, body := get(context.Background(), requestId, params, true, nil, client, store, logger)
+	assert.Equal(t, http.StatusOK, code)
+	// the newest copy of batch1 is returned once
+	assert.Equal(t, map[string]interface{}{
+		"total": float64(3),
+		"results": []interface{}{
+			map[string]interface{}{"id": "batch1", "name": "second", "archived": true},
+			map[string]interface{}{"id": "batch3", "archived": true},
+			map[string]interface{}{"id": "batch2", "archived": true},
+		},
+	}, body)
+	transport.VerifyCalls()
+}
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
@@ -21,7 +22,7 @@ import (
 	"time"
 )
 
-func SendComplete(requestId string,
+func SendComplete(ctx context.Context, requestId string,
 	request *model.SendCompleteRequest,
 	claims auth.HriClaims,
 	esClient *elasticsearch.Client,
@@ -29,21 +30,22 @@ func SendComplete(requestId string,
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/sendComplete"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 
-	// Only Integrators can call sendComplete
-	if !claims.HasScope(auth.HriIntegrator) {
+	// By default, only Integrators can call sendComplete
+	if !auth.Authorize(claims, request.TenantId, auth.ActionSendComplete).Allowed {
 		msg := fmt.Sprintf(auth.MsgIntegratorRoleRequired, "initiate sendComplete on")
 		logger.Errorln(msg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, msg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, msg)
 	}
 
 	//We know claims Must be Non-nil because the handler checks for that before we reach this point
 	var claimSubj = claims.Subject
-	return sendComplete(requestId, request, claimSubj, esClient, writer, logger, currentStatus)
+	return sendComplete(ctx, requestId, request, claimSubj, esClient, writer, logger, currentStatus)
 }
 
 func SendCompleteNoAuth(
+	ctx context.Context,
 	requestId string,
 	request *model.SendCompleteRequest,
 	_ auth.HriClaims,
@@ -52,15 +54,16 @@ func SendCompleteNoAuth(
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/sendCompleteNoAuth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 
 	//claims == nil --> NO Auth (Auth is NOT Enabled)
 	var subject = auth.NoAuthFakeIntegrator
 
-	return sendComplete(requestId, request, subject, esClient, writer, logger, currentStatus)
+	return sendComplete(ctx, requestId, request, subject, esClient, writer, logger, currentStatus)
 }
 
 func sendComplete(
+	ctx context.Context,
 	requestId string,
 	request *model.SendCompleteRequest,
 	claimSubj string,
@@ -69,9 +72,13 @@ func sendComplete(
 	logger logrus.FieldLogger,
 	currentStatus status.BatchStatus) (int, interface{}) {
 
+	if _, errResp := getActiveTenantConfig(ctx, requestId, request.TenantId, "sendComplete", esClient, logger); errResp != nil {
+		return errResp.Code, errResp.Body
+	}
+
 	updateRequest := getSendCompleteUpdateScript(request, claimSubj)
 
-	origBatch, errResp := updateStatus(requestId, request.TenantId, request.BatchId,
+	origBatch, errResp := updateStatus(ctx, requestId, request.TenantId, request.BatchId,
 		updateRequest, esClient, writer, currentStatus)
 	if errResp != nil {
 		return errResp.Code, errResp.Body
@@ -82,7 +89,7 @@ func sendComplete(
 			errMsg := fmt.Sprintf("sendComplete requested by '%s' but owned by '%s'", claimSubj,
 				origBatch[param.IntegratorId])
 			logger.Errorln(errMsg)
-			return http.StatusUnauthorized, response.NewErrorDetail(requestId, errMsg)
+			return http.StatusForbidden, response.NewErrorDetail(requestId, errMsg)
 		} else {
 			// update resulted in no-op, due to previous batch status
 			origBatchStatus := origBatch[param.Status].(string)
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -210,6 +211,12 @@ func TestSendComplete(t *testing.T) {
 		t.Errorf("Unable to create batch JSON string: %s", err.Error())
 	}
 
+	tenantConfigPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, test.ValidTenantId)
+	noTenantConfig := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, test.ValidTenantId),
+	}
+
 	tests := []struct {
 		name                 string
 		request              *model.SendCompleteRequest
@@ -226,6 +233,8 @@ func TestSendComplete(t *testing.T) {
 			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
 			claims:  validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -256,6 +265,8 @@ func TestSendComplete(t *testing.T) {
 			),
 			claims: validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -277,10 +288,10 @@ func TestSendComplete(t *testing.T) {
 			expectedResponse:     nil,
 		},
 		{
-			name:             "401 Unauthorized when Data Integrator scope is missing",
+			name:             "403 Forbidden when Data Integrator scope is missing",
 			request:          getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
 			claims:           auth.HriClaims{Scope: auth.HriConsumer, Subject: integratorId},
-			expectedCode:     http.StatusUnauthorized,
+			expectedCode:     http.StatusForbidden,
 			expectedResponse: response.NewErrorDetail(requestId, `Must have hri_data_integrator role to initiate sendComplete on a batch`),
 		},
 		{
@@ -288,6 +299,8 @@ func TestSendComplete(t *testing.T) {
 			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
 			claims:  validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -313,6 +326,8 @@ func TestSendComplete(t *testing.T) {
 			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
 			claims:  validClaims,
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -337,6 +352,8 @@ func TestSendComplete(t *testing.T) {
 			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
 			claims:  auth.HriClaims{Scope: auth.HriIntegrator, Subject: "wrong id"},
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -353,9 +370,34 @@ func TestSendComplete(t *testing.T) {
 						}`, test.ValidTenantId, test.ValidBatchId, sendCompletedJSON),
 				},
 			),
-			expectedCode:     http.StatusUnauthorized,
+			expectedCode:     http.StatusForbidden,
 			expectedResponse: response.NewErrorDetail(requestId, "sendComplete requested by 'wrong id' but owned by 'integratorId'"),
 		},
+		{
+			name:    "sendComplete fails on suspended tenant",
+			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
+			claims:  validClaims,
+			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"suspension":{"reason":"contract expired","suspendDate":"2021-02-24T18:08:36Z"}}}`,
+						elastic.TenantsIndex, test.ValidTenantId),
+				},
+			),
+			expectedCode:     http.StatusLocked,
+			expectedResponse: response.NewErrorDetail(requestId, "sendComplete failed, tenant [134340] is suspended"),
+		},
+		{
+			name:    "sendComplete fails on tenant config error",
+			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
+			claims:  validClaims,
+			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath,
+				test.ElasticCall{ResponseErr: errors.New("timeout")},
+			),
+			expectedCode:     http.StatusInternalServerError,
+			expectedResponse: response.NewErrorDetail(requestId, "sendComplete failed: [500] elasticsearch client error: timeout"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -372,7 +414,7 @@ func TestSendComplete(t *testing.T) {
 				Error:         tt.writerError,
 			}
 
-			code, result := SendComplete(requestId, tt.request, tt.claims, esClient, writer, tt.currentStatus)
+			code, result := SendComplete(context.Background(), requestId, tt.request, tt.claims, esClient, writer, tt.currentStatus)
 
 			if tt.ft != nil {
 				tt.ft.VerifyCalls()
@@ -441,6 +483,12 @@ func TestSendCompleteNoAuth(t *testing.T) {
 		t.Errorf("Unable to create batch JSON string: %s", err.Error())
 	}
 
+	tenantConfigPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, test.ValidTenantId)
+	noTenantConfig := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, test.ValidTenantId),
+	}
+
 	tests := []struct {
 		name                 string
 		request              *model.SendCompleteRequest
@@ -455,6 +503,8 @@ func TestSendCompleteNoAuth(t *testing.T) {
 			name:    "successful sendCompleteNoAuth, with validation",
 			request: getTestSendCompleteRequest(intPtr(int(batchExpectedRecordCount)), nil, nil, true),
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -484,6 +534,8 @@ func TestSendCompleteNoAuth(t *testing.T) {
 				true,
 			),
 			ft: test.NewFakeTransport(t).AddCall(
+				tenantConfigPath, noTenantConfig,
+			).AddCall(
 				fmt.Sprintf(`/%s-batches/_doc/%s/_update`, test.ValidTenantId, test.ValidBatchId),
 				test.ElasticCall{
 					RequestQuery: transportQueryParams,
@@ -521,7 +573,7 @@ func TestSendCompleteNoAuth(t *testing.T) {
 			}
 
 			var emptyClaims = auth.HriClaims{}
-			code, result := SendCompleteNoAuth(requestId, tt.request, emptyClaims, esClient, writer, currentStatus)
+			code, result := SendCompleteNoAuth(context.Background(), requestId, tt.request, emptyClaims, esClient, writer, currentStatus)
 
 			tt.ft.VerifyCalls()
 
@@ -0,0 +1,38 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const msgTenantSuspended string = "%s failed, tenant [%s] is suspended"
+
+// getActiveTenantConfig returns the tenant's configuration, or a 423 Locked error while the tenant is suspended.
+// action names the operation in error messages, i.e. "sendComplete".
+func getActiveTenantConfig(ctx context.Context, requestId string, tenantId string, action string, esClient *elasticsearch.Client,
+	logger logrus.FieldLogger) (model.TenantConfig, *response.ErrorDetailResponse) {
+
+	tenantConfig, elasticErr := elastic.GetTenantConfig(ctx, tenantId, esClient)
+	if elasticErr != nil {
+		errDetail := elasticErr.LogAndBuildErrorDetail(requestId, logger, fmt.Sprintf("%s failed", action))
+		return model.TenantConfig{}, &response.ErrorDetailResponse{Code: http.StatusInternalServerError, Body: errDetail}
+	}
+
+	if tenantConfig.Suspension != nil {
+		msg := fmt.Sprintf(msgTenantSuspended, action, tenantId)
+		logger.Errorln(msg)
+		return model.TenantConfig{}, response.NewErrorDetailResponse(http.StatusLocked, requestId, msg)
+	}
+	return tenantConfig, nil
+}
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/batches/status"
 	"github.com/Alvearie/hri-mgmt-api/common/auth"
@@ -22,6 +23,7 @@ import (
 )
 
 func Terminate(
+	ctx context.Context,
 	requestId string,
 	request *model.TerminateRequest,
 	claims auth.HriClaims,
@@ -30,21 +32,22 @@ func Terminate(
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/terminate"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 	logger.Debugln("Start Batch Terminate")
 
-	// Only Integrators can call terminate
-	if !claims.HasScope(auth.HriIntegrator) {
+	// By default, only Integrators can call terminate
+	if !auth.Authorize(claims, request.TenantId, auth.ActionTerminate).Allowed {
 		msg := fmt.Sprintf(auth.MsgIntegratorRoleRequired, "terminate")
 		logger.Errorln(msg)
-		return http.StatusUnauthorized, response.NewErrorDetail(requestId, msg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, msg)
 	}
 
 	var subject = claims.Subject
-	return terminate(requestId, request, subject, logger, esClient, writer, currentStatus)
+	return terminate(ctx, requestId, request, subject, logger, esClient, writer, currentStatus)
 }
 
 func TerminateNoAuth(
+	ctx context.Context,
 	requestId string,
 	request *model.TerminateRequest,
 	_ auth.HriClaims,
@@ -53,14 +56,14 @@ func TerminateNoAuth(
 	currentStatus status.BatchStatus) (int, interface{}) {
 
 	prefix := "batches/TerminateNoAuth"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
 	logger.Debugln("Start Batch Terminate (No Auth)")
 
 	var subject = auth.NoAuthFakeIntegrator
-	return terminate(requestId, request, subject, logger, esClient, writer, currentStatus)
+	return terminate(ctx, requestId, request, subject, logger, esClient, writer, currentStatus)
 }
 
-func terminate(requestId string, request *model.TerminateRequest,
+func terminate(ctx context.Context, requestId string, request *model.TerminateRequest,
 	claimsSubject string,
 	logger logrus.FieldLogger,
 	esClient *elasticsearch.Client,
@@ -69,7 +72,7 @@ func terminate(requestId string, request *model.TerminateRequest,
 
 	updateRequest := getTerminateUpdateScript(request, claimsSubject)
 
-	origBatch, errResp := updateStatus(requestId, request.TenantId, request.BatchId, updateRequest, esClient, writer, currentStatus)
+	origBatch, errResp := updateStatus(ctx, requestId, request.TenantId, request.BatchId, updateRequest, esClient, writer, currentStatus)
 	if errResp != nil {
 		return errResp.Code, errResp.Body
 	}
@@ -78,7 +81,7 @@ func terminate(requestId string, request *model.TerminateRequest,
 			// update resulted in no-op, due to insufficient permissions
 			errMsg := fmt.Sprintf("terminate requested by '%s' but owned by '%s'", claimsSubject, origBatch[param.IntegratorId])
 			logger.Errorln(errMsg)
-			return http.StatusUnauthorized, response.NewErrorDetail(requestId, errMsg)
+			return http.StatusForbidden, response.NewErrorDetail(requestId, errMsg)
 		} else {
 			// update resulted in no-op, due to previous batch status
 			errMsg := fmt.Sprintf("terminate failed, batch is in '%s' state", origBatch[param.Status].(string))
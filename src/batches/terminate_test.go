@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -148,10 +149,10 @@ func TestUpdateStatus_Terminate(t *testing.T) {
 			expectedResponse:     nil,
 		},
 		{
-			name:             "401 Unauthorized when Data Integrator scope is missing",
+			name:             "403 Forbidden when Data Integrator scope is missing",
 			request:          getTestTerminateRequest(nil),
 			claims:           auth.HriClaims{},
-			expectedCode:     http.StatusUnauthorized,
+			expectedCode:     http.StatusForbidden,
 			expectedResponse: response.NewErrorDetail(requestId, "Must have hri_data_integrator role to terminate a batch"),
 		},
 		{
@@ -201,7 +202,7 @@ func TestUpdateStatus_Terminate(t *testing.T) {
 				},
 			),
 			expectedNotification: terminatedBatch,
-			expectedCode:         http.StatusUnauthorized,
+			expectedCode:         http.StatusForbidden,
 			expectedResponse:     response.NewErrorDetail(requestId, "terminate requested by 'wrong id' but owned by 'integratorId'"),
 		},
 		{
@@ -244,7 +245,7 @@ func TestUpdateStatus_Terminate(t *testing.T) {
 				Error:         tt.writerError,
 			}
 
-			code, result := Terminate(requestId, tt.request, tt.claims, esClient, writer, currentStatus)
+			code, result := Terminate(context.Background(), requestId, tt.request, tt.claims, esClient, writer, currentStatus)
 
 			if tt.ft != nil {
 				tt.ft.VerifyCalls()
@@ -340,7 +341,7 @@ func Test_TerminateNoAuth(t *testing.T) {
 			}
 
 			var emptyClaims = auth.HriClaims{}
-			code, result := TerminateNoAuth(requestId, tt.request, emptyClaims, esClient, writer, currentStatus)
+			code, result := TerminateNoAuth(context.Background(), requestId, tt.request, emptyClaims, esClient, writer, currentStatus)
 
 			tt.ft.VerifyCalls()
 			if code != tt.expectedCode {
@@ -0,0 +1,167 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/batches/status"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/param/esparam"
+	"github.com/Alvearie/hri-mgmt-api/common/tracing"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const timeoutMonitorRequestId = "batch-timeout-monitor"
+
+// max number of timed out batches terminated per tenant in one check, the rest are picked up by the next check
+const maxTimedOutBatches = 100
+
+// StartTimeoutMonitor periodically terminates batches that have been in the 'started' state for longer than their
+// tenant's configured batchTimeoutSeconds. The Elastic client and Kafka writer are created once and reused by every
+// check. The returned function stops the monitor.
+func StartTimeoutMonitor(config config.Config) func() {
+	prefix := "batches/timeoutMonitor"
+	var logger = logwrapper.GetMyLogger(timeoutMonitorRequestId, prefix)
+
+	esClient, err := elastic.ClientFromConfig(config)
+	if err != nil {
+		logger.Errorf("Unable to start the batch timeout monitor: "+msgElasticErr, err.Error())
+		return func() {}
+	}
+
+	kafkaWriter, err := kafka.NewWriterFromConfig(config)
+	if err != nil {
+		logger.Errorf("Unable to start the batch timeout monitor: %s", err.Error())
+		return func() {}
+	}
+
+	ticker := time.NewTicker(time.Duration(config.BatchTimeoutCheckSecs) * time.Second)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		defer kafkaWriter.Close()
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				checkTimeouts(esClient, kafkaWriter, logger)
+			}
+		}
+	}()
+
+	// waits for a check that's in progress, so it isn't cut off midway
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func checkTimeouts(esClient *elasticsearch.Client, kafkaWriter kafka.Writer, logger logrus.FieldLogger) {
+	ctx, span := tracing.Tracer().Start(context.Background(), "check batch timeouts")
+	defer span.End()
+	terminateTimedOutBatches(ctx, timeoutMonitorRequestId, esClient, kafkaWriter, logger)
+}
+
+// terminateTimedOutBatches returns the number of batches that were terminated
+func terminateTimedOutBatches(ctx context.Context, requestId string, esClient *elasticsearch.Client, kafkaWriter kafka.Writer,
+	logger logrus.FieldLogger) int {
+
+	tenantConfigs, elasticErr := elastic.GetTenantConfigs(ctx, map[string]interface{}{
+		"query": map[string]interface{}{"exists": map[string]interface{}{"field": "batchTimeoutSeconds"}},
+	}, esClient)
+	if elasticErr != nil {
+		logger.Errorf("Unable to get tenant batch timeouts: %s", elasticErr.Error())
+		return 0
+	}
+
+	terminated := 0
+	for _, tenantConfig := range tenantConfigs {
+		batchIds, err := getTimedOutBatchIds(ctx, tenantConfig.TenantId, *tenantConfig.BatchTimeoutSeconds, esClient)
+		if err != nil {
+			logger.Errorf("Unable to get timed out batches of tenant [%s]: %s", tenantConfig.TenantId, err.Error())
+			continue
+		}
+
+		for _, batchId := range batchIds {
+			unchangedBatch, errResp := updateStatus(ctx, requestId, tenantConfig.TenantId, batchId, getTimeoutUpdateScript(),
+				esClient, kafkaWriter, status.Started)
+			if errResp != nil {
+				logger.Errorf("Unable to terminate timed out batch [%s] of tenant [%s]: %s",
+					batchId, tenantConfig.TenantId, errResp.Body.ErrorDescription)
+				continue
+			}
+			if unchangedBatch != nil {
+				// the batch left the 'started' state after it was found
+				continue
+			}
+			logger.Infof("Terminated batch [%s] of tenant [%s], it exceeded the timeout of %d seconds",
+				batchId, tenantConfig.TenantId, *tenantConfig.BatchTimeoutSeconds)
+			terminated++
+		}
+	}
+	return terminated
+}
+
+func getTimedOutBatchIds(ctx context.Context, tenantId string, timeoutSecs int, esClient *elasticsearch.Client) ([]string, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{param.Status: status.Started.String()}},
+					{"range": map[string]interface{}{param.StartDate: map[string]interface{}{"lt": fmt.Sprintf("now-%ds", timeoutSecs)}}},
+				},
+			},
+		},
+	}
+	buf, err := elastic.EncodeQueryBody(query)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := esClient.Search(
+		esClient.Search.WithContext(ctx),
+		esClient.Search.WithIndex(elastic.IndexFromTenantId(tenantId)),
+		esClient.Search.WithBody(buf),
+		esClient.Search.WithSize(maxTimedOutBatches),
+		esClient.Search.WithSource("false"),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return nil, elasticErr
+	}
+
+	hits := body["hits"].(map[string]interface{})["hits"].([]interface{})
+	batchIds := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		batchIds = append(batchIds, hit.(map[string]interface{})[esparam.EsDocId].(string))
+	}
+	return batchIds, nil
+}
+
+func getTimeoutUpdateScript() map[string]interface{} {
+	currentTime := time.Now().UTC()
+
+	// the batch may have been sent complete or terminated since it was found, so only transition from 'started'
+	updateScript := fmt.Sprintf("if (ctx._source.status == '%s') {ctx._source.status = '%s'; ctx._source.endDate = '%s';} else {ctx.op = 'none'}",
+		status.Started, status.Terminated, currentTime.Format(elastic.DateTimeFormat))
+
+	return map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": updateScript,
+		},
+	}
+}
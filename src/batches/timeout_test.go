@@ -0,0 +1,157 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package batches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/batches/status"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTerminateTimedOutBatches(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	logger := logwrapper.GetMyLogger(timeoutMonitorRequestId, "batches/timeoutMonitor")
+
+	const (
+		tenantConfigsPath = "/" + elastic.TenantsIndex + "/_search"
+		configsResponse   = `{"hits":{"hits":[{"_id":"` + test.ValidTenantId + `","_source":{"batchTimeoutSeconds":600}}]}}`
+		searchBody        = `{"query":{"bool":{"filter":\[{"term":{"status":"started"}},{"range":{"startDate":{"lt":"now-600s"}}}\]}}}` + "\n"
+		timeoutScript     = `{"script":{"source":"if \(ctx\._source\.status == 'started'\) {ctx\._source\.status = 'terminated'; ctx\._source\.endDate = '` + test.DatePattern + `';} else {ctx\.op = 'none'}"}}` + "\n"
+	)
+	batchesSearchPath := fmt.Sprintf("/%s-batches/_search", test.ValidTenantId)
+	updatePath := func(batchId string) string {
+		return fmt.Sprintf("/%s-batches/_doc/%s/_update", test.ValidTenantId, batchId)
+	}
+	updateResponse := func(batchId string, result string, batchStatus status.BatchStatus) string {
+		return fmt.Sprintf(`{"_id":"%s","result":"%s","get":{"_source":{"name":"%s","topic":"%s","dataType":"%s","status":"%s","startDate":"%s","invalidThreshold":-1}}}`,
+			batchId, result, batchName, batchTopic, batchDataType, batchStatus, batchStartDate)
+	}
+
+	terminatedBatch := map[string]interface{}{
+		param.BatchId:          "batch1",
+		param.Name:             batchName,
+		param.Topic:            batchTopic,
+		param.DataType:         batchDataType,
+		param.Status:           status.Terminated.String(),
+		param.StartDate:        batchStartDate,
+		param.InvalidThreshold: float64(-1),
+	}
+
+	testCases := []struct {
+		name               string
+		transport          *test.FakeTransport
+		writer             test.FakeWriter
+		expectedTerminated int
+	}{
+		{
+			name: "terminates timed out batches",
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigsPath,
+				test.ElasticCall{ResponseBody: configsResponse},
+			).AddCall(
+				batchesSearchPath,
+				test.ElasticCall{
+					RequestQuery: "_source=false&size=100",
+					RequestBody:  searchBody,
+					ResponseBody: `{"hits":{"hits":[{"_id":"batch1"},{"_id":"batch2"}]}}`,
+				},
+			).AddCall(
+				updatePath("batch1"),
+				test.ElasticCall{
+					RequestBody:  timeoutScript,
+					ResponseBody: updateResponse("batch1", "updated", status.Terminated),
+				},
+			).AddCall(
+				updatePath("batch2"),
+				test.ElasticCall{
+					RequestBody:  timeoutScript,
+					ResponseBody: updateResponse("batch2", "noop", status.SendCompleted),
+				},
+			),
+			writer: test.FakeWriter{
+				T:             t,
+				ExpectedTopic: InputTopicToNotificationTopic(batchTopic),
+				ExpectedKey:   "batch1",
+				ExpectedValue: terminatedBatch,
+			},
+			expectedTerminated: 1,
+		},
+		{
+			name: "tenant configs error",
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigsPath,
+				test.ElasticCall{ResponseErr: errors.New("connection refused")},
+			),
+			writer:             test.FakeWriter{T: t},
+			expectedTerminated: 0,
+		},
+		{
+			name: "batch search error",
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigsPath,
+				test.ElasticCall{ResponseBody: configsResponse},
+			).AddCall(
+				batchesSearchPath,
+				test.ElasticCall{ResponseErr: errors.New("connection refused")},
+			),
+			writer:             test.FakeWriter{T: t},
+			expectedTerminated: 0,
+		},
+		{
+			name: "update error",
+			transport: test.NewFakeTransport(t).AddCall(
+				tenantConfigsPath,
+				test.ElasticCall{ResponseBody: configsResponse},
+			).AddCall(
+				batchesSearchPath,
+				test.ElasticCall{ResponseBody: `{"hits":{"hits":[{"_id":"batch1"}]}}`},
+			).AddCall(
+				updatePath("batch1"),
+				test.ElasticCall{ResponseErr: errors.New("connection refused")},
+			),
+			writer:             test.FakeWriter{T: t},
+			expectedTerminated: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			if err != nil {
+				t.Error(err)
+			}
+
+			terminated := terminateTimedOutBatches(context.Background(), timeoutMonitorRequestId, client, tc.writer, logger)
+			assert.Equal(t, tc.expectedTerminated, terminated)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestStartTimeoutMonitorStop(t *testing.T) {
+	stop := StartTimeoutMonitor(config.Config{BatchTimeoutCheckSecs: 3600})
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the timeout monitor didn't stop")
+	}
+}
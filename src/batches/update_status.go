@@ -12,6 +12,7 @@ import (
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/elastic/go-elasticsearch/v7"
@@ -30,7 +31,7 @@ const (
 // On success return (nil, nil)
 // If the update results in a 'noop', the original batch is returned: (batch, nil)
 // On error returns (nil, error)
-func updateStatus(requestId string,
+func updateStatus(ctx context.Context, requestId string,
 	tenantId string,
 	batchId string,
 	updateRequest map[string]interface{},
@@ -39,7 +40,7 @@ func updateStatus(requestId string,
 	currentStatus status.BatchStatus) (map[string]interface{}, *response.ErrorDetailResponse) {
 
 	prefix := "batches/updateStatus"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, tenantId, batchId)
 	logger.Debugln("Start Batch Update Status")
 
 	index := elastic.IndexFromTenantId(tenantId)
@@ -55,7 +56,7 @@ func updateStatus(requestId string,
 		index,
 		batchId,
 		encodedQuery, // request body
-		client.Update.WithContext(context.Background()),
+		client.Update.WithContext(ctx),
 		client.Update.WithSource("true"), // return updated batch in response
 	)
 
@@ -84,12 +85,13 @@ func updateStatus(requestId string,
 		updatedBatch[param.BatchId] = batchId
 		notificationTopic := InputTopicToNotificationTopic(updatedBatch[param.Topic].(string))
 		updatedBatch = NormalizeBatchRecordCountValues(updatedBatch)
-		err = kafkaWriter.Write(notificationTopic, batchId, updatedBatch)
+		err = kafkaWriter.Write(ctx, notificationTopic, batchId, updatedBatch)
 		if err != nil { //Write to Elastic Failed, try to Revert Batch Status
+			metrics.NotificationPublishFailed(metrics.BatchNotification)
 			kafkaErrMsg := fmt.Sprintf("error writing batch notification to kafka: %s", err.Error())
 			logger.Errorln(kafkaErrMsg)
 
-			encodeErr := revertBatchStatus(requestId, index, batchId, client, currentStatus, logger)
+			encodeErr := revertBatchStatus(ctx, requestId, index, batchId, client, currentStatus, logger)
 			if encodeErr != nil {
 				return nil, encodeErr
 			}
@@ -109,7 +111,7 @@ func updateStatus(requestId string,
 //Here we are reverting the Batch status to "currentStatus" in Elastic. "currentStatus" is the
 //   status that the Batch had BEFORE the update operation
 // If the Revert attempt in Elastic fails, we retry up to 5 times.
-func revertBatchStatus(requestId string, idx string,
+func revertBatchStatus(ctx context.Context, requestId string, idx string,
 	batchId string,
 	client *elasticsearch.Client,
 	currentStatus status.BatchStatus,
@@ -135,10 +137,11 @@ func revertBatchStatus(requestId string, idx string,
 			idx,
 			batchId,
 			encodedQuery, // Revert Status request body
-			client.Update.WithContext(context.Background()),
+			client.Update.WithContext(ctx),
 		)
 
 		decodedRevertResponse, elasticErr := elastic.DecodeBody(revertResponse, revertErr)
+		metrics.BatchStatusRevertAttempted(elasticErr == nil)
 
 		if elasticErr != nil {
 			msg := fmt.Sprintf(revertErrMsg, attemptNum, currentStatus,
@@ -6,6 +6,7 @@
 package batches
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -375,7 +376,7 @@ func Test_updateStatus(t *testing.T) {
 				Error:         tt.writerError,
 			}
 
-			result, errResp := updateStatus(requestId, test.ValidTenantId, test.ValidBatchId, updateRequest, esClient, writer, tt.currentStatus)
+			result, errResp := updateStatus(context.Background(), requestId, test.ValidTenantId, test.ValidBatchId, updateRequest, esClient, writer, tt.currentStatus)
 
 			tt.ft.VerifyCalls()
 
@@ -0,0 +1,55 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+)
+
+// EncodeBatches returns the batches as gzip compressed NDJSON
+func EncodeBatches(batches []map[string]interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	writer := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(writer)
+	for _, batch := range batches {
+		// Encode terminates every batch with a newline
+		if err := encoder.Encode(batch); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeBatches reads the batches from gzip compressed NDJSON
+func DecodeBatches(data []byte) ([]map[string]interface{}, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	batches := make([]map[string]interface{}, 0)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		batch := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+			return nil, fmt.Errorf("invalid batch on line %d: %w", line, err)
+		}
+		batches = append(batches, batch)
+	}
+	return batches, scanner.Err()
+}
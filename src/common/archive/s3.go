@@ -0,0 +1,225 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package archive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	amzDateHeader    = "X-Amz-Date"
+	amzContentHeader = "X-Amz-Content-Sha256"
+)
+
+// S3Store keeps archives in a bucket of an S3 compatible object store, i.e. IBM Cloud Object Storage or MinIO.
+// Requests use path-style URLs and are signed with AWS Signature Version 4 using HMAC credentials.
+type S3Store struct {
+	endpoint   string
+	region     string
+	bucket     string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func NewS3Store(endpoint string, region string, bucket string, accessKey string, secretKey string,
+	httpClient *http.Client) *S3Store {
+
+	return &S3Store{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		region:     region,
+		bucket:     bucket,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+func (s *S3Store) Put(key string, data []byte) error {
+	_, err := s.do(http.MethodPut, key, nil, data)
+	return err
+}
+
+func (s *S3Store) Get(key string) ([]byte, error) {
+	return s.do(http.MethodGet, key, nil, nil)
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Store) List(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("invalid S3 list response: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3Store) do(method string, key string, query url.Values, data []byte) ([]byte, error) {
+	path := "/" + s.bucket
+	if key != "" {
+		path += "/" + key
+	}
+	requestUrl := s.endpoint + encodePath(path)
+	if len(query) > 0 {
+		requestUrl += "?" + encodeQuery(query)
+	}
+
+	request, err := http.NewRequest(method, requestUrl, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(request, data)
+
+	res, err := s.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("S3 client error: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading S3 response: %w", err)
+	}
+
+	if res.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("S3 %s %s failed with status %d: %s", method, path, res.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization header. Every header that's already set on the request is
+// signed along with the host.
+func (s *S3Store) sign(request *http.Request, payload []byte) {
+	now := s.now().UTC()
+	payloadHash := sha256.Sum256(payload)
+	request.Header.Set(amzDateHeader, now.Format(amzDateFormat))
+	request.Header.Set(amzContentHeader, hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		encodePath(request.URL.Path),
+		encodeQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		request.Header.Get(amzContentHeader),
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	date := now.Format("20060102")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		request.Header.Get(amzDateHeader),
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+s.secretKey), date)
+	signingKey = hmacSha256(signingKey, s.region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// encodePath escapes everything except the unreserved characters and '/', as required by Signature Version 4
+func encodePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func encodeQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			params = append(params, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func uriEncode(s string) string {
+	encoded := strings.Builder{}
+	for _, b := range []byte(s) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' {
+			encoded.WriteByte(b)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return encoded.String()
+}
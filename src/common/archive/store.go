@@ -0,0 +1,55 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package archive stores batches that were removed from Elastic because they exceeded their retention. Each archive
+// is a gzip compressed NDJSON file with one batch per line.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
+	"net/http"
+	"time"
+)
+
+// ErrNotFound is returned when the requested archive doesn't exist
+var ErrNotFound = errors.New("archive not found")
+
+// Store is a place to keep archives. Keys are '/' separated paths.
+type Store interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	// List returns the keys that start with prefix, sorted
+	List(prefix string) ([]string, error)
+}
+
+const keyTimeFormat = "20060102T150405.000000000Z"
+const keySuffix = ".ndjson.gz"
+
+// NewStoreFromConfig returns the configured store, or an error if archiving isn't configured
+func NewStoreFromConfig(config configPkg.Config) (Store, error) {
+	switch config.ArchiveStore {
+	case configPkg.ArchiveStoreLocal:
+		return NewLocalStore(config.ArchiveDir), nil
+	case configPkg.ArchiveStoreS3:
+		return NewS3Store(config.ArchiveS3Endpoint, config.ArchiveS3Region, config.ArchiveS3Bucket,
+			config.ArchiveS3AccessKey, config.ArchiveS3SecretKey, http.DefaultClient), nil
+	case "":
+		return nil, errors.New("no archive store is configured")
+	}
+	return nil, fmt.Errorf("unknown archive store '%s'", config.ArchiveStore)
+}
+
+// TenantPrefix is the key prefix of all archives of a tenant
+func TenantPrefix(tenantId string) string {
+	return tenantId + "/"
+}
+
+// NewKey returns the key of a new archive of the tenant's batches
+func NewKey(tenantId string, archiveTime time.Time) string {
+	return TenantPrefix(tenantId) + "batches-" + archiveTime.UTC().Format(keyTimeFormat) + keySuffix
+}
@@ -0,0 +1,82 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package archive
+
+import (
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewStoreFromConfig(t *testing.T) {
+	store, err := NewStoreFromConfig(config.Config{ArchiveStore: config.ArchiveStoreLocal, ArchiveDir: "/tmp"})
+	assert.NoError(t, err)
+	assert.IsType(t, &LocalStore{}, store)
+
+	store, err = NewStoreFromConfig(config.Config{ArchiveStore: config.ArchiveStoreS3})
+	assert.NoError(t, err)
+	assert.IsType(t, &S3Store{}, store)
+
+	_, err = NewStoreFromConfig(config.Config{})
+	assert.EqualError(t, err, "no archive store is configured")
+
+	_, err = NewStoreFromConfig(config.Config{ArchiveStore: "ftp"})
+	assert.EqualError(t, err, "unknown archive store 'ftp'")
+}
+
+func TestNewKey(t *testing.T) {
+	archiveTime := time.Date(2021, 2, 24, 18, 8, 36, 123, time.UTC)
+	assert.Equal(t, "tenant1/batches-20210224T180836.000000123Z.ndjson.gz", NewKey("tenant1", archiveTime))
+}
+
+func TestLocalStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	store := NewLocalStore(dir)
+
+	// the directory is created by the first Put
+	keys, err := store.List(TenantPrefix("tenant1"))
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.NoError(t, store.Put("tenant1/batches-2.ndjson.gz", []byte("second")))
+	assert.NoError(t, store.Put("tenant1/batches-1.ndjson.gz", []byte("first")))
+	assert.NoError(t, store.Put("tenant10/batches-1.ndjson.gz", []byte("other tenant")))
+
+	keys, err = store.List(TenantPrefix("tenant1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tenant1/batches-1.ndjson.gz", "tenant1/batches-2.ndjson.gz"}, keys)
+
+	data, err := store.Get("tenant1/batches-1.ndjson.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+
+	_, err = store.Get("tenant1/missing.ndjson.gz")
+	assert.Equal(t, ErrNotFound, err)
+
+	err = store.Put("../escape", []byte("data"))
+	assert.EqualError(t, err, "invalid archive key '../escape'")
+	_, err = os.Stat(filepath.Join(filepath.Dir(dir), "escape"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEncodeDecodeBatches(t *testing.T) {
+	batches := []map[string]interface{}{
+		{"id": "batch1", "name": "first", "status": "completed", "expectedRecordCount": float64(10)},
+		{"id": "batch2", "name": "second", "status": "terminated", "metadata": map[string]interface{}{"a": "b"}},
+	}
+
+	data, err := EncodeBatches(batches)
+	assert.NoError(t, err)
+	decoded, err := DecodeBatches(data)
+	assert.NoError(t, err)
+	assert.Equal(t, batches, decoded)
+
+	_, err = DecodeBatches([]byte("not gzip"))
+	assert.Error(t, err)
+}
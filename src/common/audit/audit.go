@@ -0,0 +1,246 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry records who called which endpoint, on which tenant, batch or stream, and the outcome. Every entry has the hash
+// of the previous one in its chain, so removing or changing an entry breaks the chain of hashes that follows it. The
+// hashes are HMACs with a secret key, so a changed chain can't be hashed again without the key.
+type Entry struct {
+	Time      string `json:"time"`
+	RequestId string `json:"requestId"`
+	Actor     string `json:"actor,omitempty"`
+	ClientId  string `json:"clientId,omitempty"`
+	TenantId  string `json:"tenantId,omitempty"`
+	Method    string `json:"method"`
+	Endpoint  string `json:"endpoint"`
+	BatchId   string `json:"batchId,omitempty"`
+	StreamId  string `json:"streamId,omitempty"`
+	Outcome   int    `json:"outcome"`
+	SourceIp  string `json:"sourceIp"`
+	ChainId   string `json:"chainId"`
+	PrevHash  string `json:"prevHash"`
+	Hash      string `json:"hash"`
+}
+
+// computeHash returns the hex encoded HMAC-SHA256 of the entry's JSON without its hash, which includes the previous
+// entry's hash
+func (e Entry) computeHash(key []byte) (string, error) {
+	e.Hash = ""
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// newChainId returns a random id for a new chain
+func newChainId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("unable to generate an audit chain id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Sink is where the audit entries are written, separately from the application log
+type Sink interface {
+	Write(entry Entry) error
+	Close()
+}
+
+// entries waiting to be written to the sinks, Record blocks once the sinks are this far behind
+const queueSize = 1024
+
+// Logger chains the entries and writes them to all its sinks. It's safe for concurrent use; the entries are chained
+// in the order they are recorded, and written to the sinks in that order by a single goroutine, so that a slow sink
+// doesn't hold up the requests.
+type Logger struct {
+	mutex    sync.Mutex
+	sinks    []Sink
+	key      []byte
+	chainId  string
+	lastHash string
+	now      func() time.Time
+	queue    chan Entry
+	closed   bool
+	done     chan struct{}
+}
+
+// NewLogger returns a logger that hashes the entries with the key and chains its first entry to lastHash, the hash of
+// the last entry of the chain that was written to the sinks, or an empty string to start a new chain
+func NewLogger(key []byte, chainId string, lastHash string, sinks ...Sink) *Logger {
+	l := &Logger{
+		sinks:    sinks,
+		key:      key,
+		chainId:  chainId,
+		lastHash: lastHash,
+		now:      time.Now,
+		queue:    make(chan Entry, queueSize),
+		done:     make(chan struct{}),
+	}
+	go l.write()
+	return l
+}
+
+// NewLoggerFromConfig returns a logger that writes to the configured audit log file and Kafka topic, or nil when
+// neither is configured. The chain continues from the last entry in the file, otherwise every instance starts a new
+// chain with a random id.
+func NewLoggerFromConfig(config config.Config) (*Logger, error) {
+	var sinks []Sink
+	chainId, lastHash := "", ""
+	if config.AuditLogFile != "" {
+		fileSink, err := NewFileSink(config.AuditLogFile)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, fileSink)
+		chainId, lastHash = fileSink.chainId, fileSink.lastHash
+	}
+	if config.AuditKafkaTopic != "" {
+		writer, err := kafka.NewWriterFromConfig(config)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, NewKafkaSink(writer, config.AuditKafkaTopic))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	if chainId == "" {
+		var err error
+		if chainId, err = newChainId(); err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+	}
+	return NewLogger([]byte(config.AuditHmacKey), chainId, lastHash, sinks...), nil
+}
+
+// Record chains the entry to the previous one and queues it to be written to every sink. Errors writing to the
+// sinks are logged; the entry stays chained, so the sinks that did write it keep a valid chain.
+func (l *Logger) Record(entry Entry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return fmt.Errorf("unable to write audit entry %s: the audit log is closed", entry.RequestId)
+	}
+
+	if entry.Time == "" {
+		entry.Time = l.now().UTC().Format(elastic.DateTimeFormat)
+	}
+	entry.ChainId = l.chainId
+	entry.PrevHash = l.lastHash
+	hash, err := entry.computeHash(l.key)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	l.lastHash = hash
+
+	// only blocks when the sinks are behind, the queue keeps the order of the chain
+	l.queue <- entry
+	return nil
+}
+
+// write writes the queued entries to the sinks until the logger is closed
+func (l *Logger) write() {
+	defer close(l.done)
+	for entry := range l.queue {
+		var errs []string
+		for _, sink := range l.sinks {
+			if err := sink.Write(entry); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			logwrapper.GetMyLogger(entry.RequestId, "audit/Logger").Errorf(
+				"unable to write audit entry %s: %s", entry.RequestId, strings.Join(errs, "; "))
+		}
+	}
+}
+
+// Close writes the queued entries, then closes all the sinks
+func (l *Logger) Close() {
+	l.mutex.Lock()
+	if l.closed {
+		l.mutex.Unlock()
+		return
+	}
+	l.closed = true
+	close(l.queue)
+	l.mutex.Unlock()
+
+	<-l.done
+	closeSinks(l.sinks)
+}
+
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
+
+// VerifyChain reads an audit log of one chain, one JSON entry per line, and checks that every entry's hash is the HMAC
+// of the entry with the key and that it's chained to the previous entry. It returns the number of entries that were
+// verified, and an error that identifies the first entry that was changed, or follows a removed entry.
+func VerifyChain(reader io.Reader, key []byte) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	count := 0
+	chainId, prevHash := "", ""
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		count++
+
+		entry := Entry{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return count - 1, fmt.Errorf("audit entry %d isn't valid JSON: %w", count, err)
+		}
+		// the first entry may continue a chain that started in an older, rotated file
+		if count > 1 && entry.ChainId != chainId {
+			return count - 1, fmt.Errorf("audit entry %d belongs to another chain", count)
+		}
+		if count > 1 && entry.PrevHash != prevHash {
+			return count - 1, fmt.Errorf("audit entry %d isn't chained to the previous entry", count)
+		}
+		hash, err := entry.computeHash(key)
+		if err != nil {
+			return count - 1, err
+		}
+		if !hmac.Equal([]byte(hash), []byte(entry.Hash)) {
+			return count - 1, fmt.Errorf("audit entry %d doesn't match its hash", count)
+		}
+		chainId, prevHash = entry.ChainId, entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("unable to read the audit log: %w", err)
+	}
+	return count, nil
+}
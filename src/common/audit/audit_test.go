@@ -0,0 +1,218 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fake sink that keeps the entries, or fails with err
+type fakeSink struct {
+	entries []Entry
+	err     error
+	closed  bool
+}
+
+func (s *fakeSink) Write(entry Entry) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *fakeSink) Close() {
+	s.closed = true
+}
+
+var testKey = []byte("secret")
+
+func fixedTime() time.Time {
+	return time.Date(2021, 2, 24, 18, 8, 36, 0, time.UTC)
+}
+
+func encodeEntries(t *testing.T, entries []Entry) string {
+	buf := bytes.Buffer{}
+	for _, entry := range entries {
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(encoded, '\n'))
+	}
+	return buf.String()
+}
+
+func TestRecord(t *testing.T) {
+	sink := &fakeSink{}
+	logger := NewLogger(testKey, "chain1", "previous", sink)
+	logger.now = fixedTime
+
+	assert.NoError(t, logger.Record(Entry{RequestId: "request1", Actor: "integrator", TenantId: "tenant1", Outcome: 201}))
+	assert.NoError(t, logger.Record(Entry{RequestId: "request2", Actor: "consumer", TenantId: "tenant1", Outcome: 200}))
+	// writes the queued entries
+	logger.Close()
+	assert.True(t, sink.closed)
+
+	if assert.Len(t, sink.entries, 2) {
+		first, second := sink.entries[0], sink.entries[1]
+		assert.Equal(t, "2021-02-24T18:08:36Z", first.Time)
+		assert.Equal(t, "chain1", first.ChainId)
+		assert.Equal(t, "previous", first.PrevHash)
+		assert.Len(t, first.Hash, 64)
+		assert.Equal(t, first.Hash, second.PrevHash)
+		assert.NotEqual(t, first.Hash, second.Hash)
+
+		count, err := VerifyChain(strings.NewReader(encodeEntries(t, sink.entries)), testKey)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	}
+	assert.EqualError(t, logger.Record(Entry{RequestId: "request3"}), "unable to write audit entry request3: the audit log is closed")
+}
+
+func TestRecordSinkError(t *testing.T) {
+	working := &fakeSink{}
+	failing := &fakeSink{err: errors.New("disk full")}
+	logger := NewLogger(testKey, "chain1", "", failing, working)
+	logs := new(bytes.Buffer)
+	logwrapper.Initialize("error", logs)
+
+	assert.NoError(t, logger.Record(Entry{RequestId: "request1"}))
+	assert.NoError(t, logger.Record(Entry{RequestId: "request2"}))
+	logger.Close()
+
+	assert.Contains(t, logs.String(), "unable to write audit entry request1: disk full")
+	assert.Contains(t, logs.String(), "unable to write audit entry request2: disk full")
+
+	// the working sink still has a valid chain
+	count, err := VerifyChain(strings.NewReader(encodeEntries(t, working.entries)), testKey)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestVerifyChain(t *testing.T) {
+	sink := &fakeSink{}
+	logger := NewLogger(testKey, "chain1", "", sink)
+	for _, requestId := range []string{"request1", "request2", "request3"} {
+		assert.NoError(t, logger.Record(Entry{RequestId: requestId, TenantId: "tenant1", Outcome: 200}))
+	}
+	logger.Close()
+
+	changed := append([]Entry{}, sink.entries...)
+	changed[1].Outcome = 401
+
+	// a new chain, like the one of a restarted instance
+	other := &fakeSink{}
+	otherLogger := NewLogger(testKey, "chain2", sink.entries[0].Hash, other)
+	assert.NoError(t, otherLogger.Record(Entry{RequestId: "request4", TenantId: "tenant1", Outcome: 200}))
+	otherLogger.Close()
+
+	tests := []struct {
+		name     string
+		log      string
+		key      []byte
+		expCount int
+		expErr   string
+	}{
+		{
+			name:     "valid",
+			log:      encodeEntries(t, sink.entries),
+			expCount: 3,
+		},
+		{
+			name:     "continues a rotated file",
+			log:      encodeEntries(t, sink.entries[1:]),
+			expCount: 2,
+		},
+		{
+			name: "empty",
+			log:  "\n",
+		},
+		{
+			name:     "changed entry",
+			log:      encodeEntries(t, changed),
+			expCount: 1,
+			expErr:   "audit entry 2 doesn't match its hash",
+		},
+		{
+			name:   "wrong key",
+			log:    encodeEntries(t, sink.entries),
+			key:    []byte("another key"),
+			expErr: "audit entry 1 doesn't match its hash",
+		},
+		{
+			name:     "another chain",
+			log:      encodeEntries(t, []Entry{sink.entries[0], other.entries[0]}),
+			expCount: 1,
+			expErr:   "audit entry 2 belongs to another chain",
+		},
+		{
+			name:     "removed entry",
+			log:      encodeEntries(t, []Entry{sink.entries[0], sink.entries[2]}),
+			expCount: 1,
+			expErr:   "audit entry 2 isn't chained to the previous entry",
+		},
+		{
+			name:     "invalid json",
+			log:      encodeEntries(t, sink.entries[:1]) + "{",
+			expCount: 1,
+			expErr:   "audit entry 2 isn't valid JSON: unexpected end of JSON input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			if key == nil {
+				key = testKey
+			}
+			count, err := VerifyChain(strings.NewReader(tt.log), key)
+			assert.Equal(t, tt.expCount, count)
+			if tt.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expErr)
+			}
+		})
+	}
+}
+
+func TestNewLoggerFromConfig(t *testing.T) {
+	logger, err := NewLoggerFromConfig(config.Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, logger)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	conf := config.Config{AuditLogFile: path, AuditHmacKey: "secret"}
+	logger, err = NewLoggerFromConfig(conf)
+	assert.NoError(t, err)
+	if assert.NotNil(t, logger) {
+		assert.Len(t, logger.chainId, 32)
+		assert.Equal(t, []byte("secret"), logger.key)
+		assert.NoError(t, logger.Record(Entry{RequestId: "request1"}))
+		chainId, lastHash := logger.chainId, logger.lastHash
+		logger.Close()
+
+		// a restarted server continues the file's chain
+		logger, err = NewLoggerFromConfig(conf)
+		assert.NoError(t, err)
+		assert.Equal(t, chainId, logger.chainId)
+		assert.Equal(t, lastHash, logger.lastHash)
+		logger.Close()
+	}
+
+	_, err = NewLoggerFromConfig(config.Config{AuditLogFile: filepath.Join(t.TempDir(), "missing", "audit.log")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package audit
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/labstack/echo/v4"
+	"strings"
+)
+
+// the probes and the metrics scrapes are called every few seconds and don't access any tenant's data
+var skippedPaths = map[string]bool{
+	"/alive":           true,
+	"/ready":           true,
+	"/hri/healthcheck": true,
+	"/metrics":         true,
+}
+
+type callerKey struct{}
+
+// caller is filled in by the auth validator once it has authenticated the request
+type caller struct {
+	actor    string
+	clientId string
+}
+
+// SetCaller records who made the request in its audit entry. It does nothing when the request isn't audited.
+func SetCaller(ctx context.Context, actor string, clientId string) {
+	if c, ok := ctx.Value(callerKey{}).(*caller); ok {
+		c.actor = actor
+		c.clientId = clientId
+	}
+}
+
+// Middleware records an audit entry for every request once it has been handled. Requests that fail authentication are
+// recorded too, without an actor.
+func Middleware(logger *Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skippedPaths[c.Path()] {
+				return next(c)
+			}
+
+			requestCaller := &caller{}
+			request := c.Request()
+			c.SetRequest(request.WithContext(context.WithValue(request.Context(), callerKey{}, requestCaller)))
+
+			err := next(c)
+			if err != nil {
+				// sets the response status of the error
+				c.Error(err)
+			}
+
+			entry := newEntry(c, requestCaller)
+			if recordErr := logger.Record(entry); recordErr != nil {
+				logwrapper.GetMyLogger(entry.RequestId, "audit/Middleware").Errorln(recordErr.Error())
+			}
+			return err
+		}
+	}
+}
+
+func newEntry(c echo.Context, requestCaller *caller) Entry {
+	endpoint := c.Path()
+	if endpoint == "" {
+		endpoint = c.Request().URL.Path
+	} else if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+
+	entry := Entry{
+		RequestId: c.Response().Header().Get(echo.HeaderXRequestID),
+		Actor:     requestCaller.actor,
+		ClientId:  requestCaller.clientId,
+		TenantId:  c.Param(param.TenantId),
+		Method:    c.Request().Method,
+		Endpoint:  endpoint,
+		Outcome:   c.Response().Status,
+		SourceIp:  c.RealIP(),
+	}
+	// batches and streams both use the id path parameter
+	if strings.Contains(endpoint, "/streams/") {
+		entry.StreamId = c.Param(param.StreamId)
+	} else {
+		entry.BatchId = c.Param(param.BatchId)
+	}
+	return entry
+}
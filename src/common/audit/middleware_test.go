@@ -0,0 +1,120 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package audit
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected *Entry
+	}{
+		{
+			name:   "batch",
+			method: http.MethodPut,
+			path:   "/hri/tenants/tenant1/batches/batch1/action/terminate",
+			expected: &Entry{
+				RequestId: "request1",
+				Actor:     "integrator",
+				ClientId:  "client1",
+				TenantId:  "tenant1",
+				Method:    http.MethodPut,
+				Endpoint:  "/hri/tenants/:tenantId/batches/:id/action/terminate",
+				BatchId:   "batch1",
+				Outcome:   http.StatusOK,
+				SourceIp:  "10.0.0.1",
+			},
+		},
+		{
+			name:   "stream error",
+			method: http.MethodDelete,
+			path:   "/hri/tenants/tenant1/streams/stream1",
+			expected: &Entry{
+				RequestId: "request1",
+				TenantId:  "tenant1",
+				Method:    http.MethodDelete,
+				Endpoint:  "/hri/tenants/:tenantId/streams/:id",
+				StreamId:  "stream1",
+				Outcome:   http.StatusUnauthorized,
+				SourceIp:  "10.0.0.1",
+			},
+		},
+		{
+			name:   "unknown route",
+			method: http.MethodGet,
+			path:   "/hri/unknown",
+			expected: &Entry{
+				RequestId: "request1",
+				Method:    http.MethodGet,
+				Endpoint:  "/hri/unknown",
+				Outcome:   http.StatusNotFound,
+				SourceIp:  "10.0.0.1",
+			},
+		},
+		{
+			name:   "probe",
+			method: http.MethodGet,
+			path:   "/alive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &fakeSink{}
+			logger := NewLogger(testKey, "chain1", "", sink)
+			e := newTestServer(logger)
+			request := httptest.NewRequest(tt.method, tt.path, nil)
+			request.RemoteAddr = "10.0.0.1:54321"
+			e.ServeHTTP(httptest.NewRecorder(), request)
+			// writes the queued entry
+			logger.Close()
+
+			if tt.expected == nil {
+				assert.Empty(t, sink.entries)
+			} else if assert.Len(t, sink.entries, 1) {
+				entry := sink.entries[0]
+				entry.Time, entry.ChainId, entry.PrevHash, entry.Hash = "", "", "", ""
+				assert.Equal(t, *tt.expected, entry)
+			}
+		})
+	}
+}
+
+func newTestServer(logger *Logger) *echo.Echo {
+	e := echo.New()
+	e.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{Generator: func() string { return "request1" }}))
+	e.Use(Middleware(logger))
+	e.GET("/alive", func(c echo.Context) error {
+		return c.String(http.StatusOK, "yes")
+	})
+	e.PUT("/hri/tenants/:tenantId/batches/:id/action/terminate", func(c echo.Context) error {
+		SetCaller(c.Request().Context(), "integrator", "client1")
+		return c.NoContent(http.StatusOK)
+	})
+	e.DELETE("hri/tenants/:tenantId/streams/:id", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
+	})
+	return e
+}
+
+func TestSetCallerWithoutAudit(t *testing.T) {
+	// requests that aren't audited don't have a caller to set
+	assert.NotPanics(t, func() { SetCaller(context.Background(), "integrator", "client1") })
+}
@@ -0,0 +1,100 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"os"
+)
+
+// entries are small, this only guards against reading a corrupted file into memory
+const maxEntrySize = 1024 * 1024
+
+// FileSink appends the entries to a file, one JSON entry per line
+type FileSink struct {
+	file     *os.File
+	chainId  string
+	lastHash string
+}
+
+// NewFileSink opens the audit log file for appending, creating it if needed. The chain id and hash of its last entry
+// are kept, so that new entries continue its chain.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the audit log file: %w", err)
+	}
+
+	chainId, lastHash := "", ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("unable to parse the audit log file %s: %w", path, err)
+		}
+		chainId, lastHash = entry.ChainId, entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to read the audit log file %s: %w", path, err)
+	}
+	return &FileSink{file: file, chainId: chainId, lastHash: lastHash}, nil
+}
+
+func (s *FileSink) Write(entry Entry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	if _, err := s.file.Write(append(encoded, '\n')); err != nil {
+		return fmt.Errorf("unable to write to the audit log file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Close() {
+	s.file.Close()
+}
+
+// KafkaSink writes the entries to a Kafka topic, keyed by chain id, so that all the entries of a chain are in the same
+// partition, in order
+type KafkaSink struct {
+	writer kafka.Writer
+	topic  string
+}
+
+func NewKafkaSink(writer kafka.Writer, topic string) *KafkaSink {
+	return &KafkaSink{writer: writer, topic: topic}
+}
+
+func (s *KafkaSink) Write(entry Entry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	value := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	// the audit log is written separately from the request, so it isn't part of its trace
+	if err := s.writer.Write(context.Background(), s.topic, entry.ChainId, value); err != nil {
+		return fmt.Errorf("unable to write to the audit topic: %w", err)
+	}
+	return nil
+}
+
+func (s *KafkaSink) Close() {
+	s.writer.Close()
+}
@@ -0,0 +1,94 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package audit
+
+import (
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "", sink.chainId)
+	assert.Equal(t, "", sink.lastHash)
+	assert.NoError(t, sink.Write(Entry{RequestId: "request1", ChainId: "chain1", Hash: "hash1"}))
+	assert.NoError(t, sink.Write(Entry{RequestId: "request2", ChainId: "chain1", PrevHash: "hash1", Hash: "hash2"}))
+	sink.Close()
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`{"time":"","requestId":"request1","method":"","endpoint":"","outcome":0,"sourceIp":"","chainId":"chain1","prevHash":"","hash":"hash1"}`+"\n"+
+			`{"time":"","requestId":"request2","method":"","endpoint":"","outcome":0,"sourceIp":"","chainId":"chain1","prevHash":"hash1","hash":"hash2"}`+"\n",
+		string(content))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// reopening the file keeps its chain and appends to it
+	sink, err = NewFileSink(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "chain1", sink.chainId)
+	assert.Equal(t, "hash2", sink.lastHash)
+	sink.Close()
+}
+
+func TestNewFileSinkErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewFileSink(filepath.Join(dir, "missing", "audit.log"))
+	assert.EqualError(t, err, "unable to open the audit log file: open "+filepath.Join(dir, "missing", "audit.log")+": no such file or directory")
+
+	path := filepath.Join(dir, "corrupted.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("not json\n"), 0600))
+	_, err = NewFileSink(path)
+	assert.EqualError(t, err, "unable to parse the audit log file "+path+": invalid character 'o' in literal null (expecting 'u')")
+}
+
+func TestKafkaSink(t *testing.T) {
+	topic := "hri.audit"
+	entry := Entry{
+		Time:      "2021-02-24T18:08:36Z",
+		RequestId: "request1",
+		Actor:     "integrator",
+		TenantId:  "tenant1",
+		Method:    "POST",
+		Endpoint:  "/hri/tenants/:tenantId/batches",
+		Outcome:   201,
+		SourceIp:  "10.0.0.1",
+		ChainId:   "chain1",
+		PrevHash:  "hash0",
+		Hash:      "hash1",
+	}
+	expectedValue := map[string]interface{}{
+		"time":      "2021-02-24T18:08:36Z",
+		"requestId": "request1",
+		"actor":     "integrator",
+		"tenantId":  "tenant1",
+		"method":    "POST",
+		"endpoint":  "/hri/tenants/:tenantId/batches",
+		"outcome":   float64(201),
+		"sourceIp":  "10.0.0.1",
+		"chainId":   "chain1",
+		"prevHash":  "hash0",
+		"hash":      "hash1",
+	}
+
+	sink := NewKafkaSink(test.FakeWriter{T: t, ExpectedTopic: topic, ExpectedKey: "chain1", ExpectedValue: expectedValue}, topic)
+	assert.NoError(t, sink.Write(entry))
+	sink.Close()
+
+	sink = NewKafkaSink(test.FakeWriter{T: t, ExpectedTopic: topic, ExpectedKey: "chain1", ExpectedValue: expectedValue,
+		Error: errors.New("broker unavailable")}, topic)
+	assert.EqualError(t, sink.Write(entry), "unable to write to the audit topic: broker unavailable")
+}
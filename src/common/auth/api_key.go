@@ -0,0 +1,134 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ApiKeyHeader is the header API keys are sent in
+const ApiKeyHeader = "X-API-Key"
+
+// API keys look like hri_<key id>_<secret>. The key id is used to look up the key's document, which only has the hash of
+// the whole key.
+const (
+	apiKeyPrefix      = "hri"
+	apiKeyIdBytes     = 8
+	apiKeySecretBytes = 32
+)
+
+// the subject of an API key's claims is namespaced, so a key can't take the subject of an integrator whatever its name
+const apiKeySubjectPrefix = "apikey:"
+
+// NewApiKey generates a new random API key and returns it with its key id
+func NewApiKey() (key string, keyId string, err error) {
+	idBytes := make([]byte, apiKeyIdBytes)
+	secretBytes := make([]byte, apiKeySecretBytes)
+	if _, err = rand.Read(idBytes); err == nil {
+		_, err = rand.Read(secretBytes)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to generate an API key: %w", err)
+	}
+
+	keyId = hex.EncodeToString(idBytes)
+	key = strings.Join([]string{apiKeyPrefix, keyId, base64.RawURLEncoding.EncodeToString(secretBytes)}, "_")
+	return key, keyId, nil
+}
+
+// HashApiKey returns the hex encoded SHA-256 hash of the key. The keys are random, so they don't need a salted hash.
+func HashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// parseApiKey returns the key's id, false if the key isn't formatted like an HRI API key
+func parseApiKey(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || len(parts[1]) != 2*apiKeyIdBytes || parts[2] == "" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// matchesApiKey compares the key with the stored hash in constant time
+func matchesApiKey(key string, apiKey model.ApiKey) bool {
+	return subtle.ConstantTimeCompare([]byte(HashApiKey(key)), []byte(apiKey.Hash)) == 1
+}
+
+// apiKeyClaims returns the key's claims, with its scopes and tenant scopes as the scope, apikey:<key id> as the subject
+// and its id as the client id. The key's name is free-form, so it isn't used as the subject.
+func apiKeyClaims(apiKey model.ApiKey) HriClaims {
+	return HriClaims{Scope: joinScopes(apiKey.Scopes, apiKey.Tenants), Subject: apiKeySubjectPrefix + apiKey.KeyId,
+		ClientId: apiKey.KeyId}
+}
+
+// joinScopes returns the space separated scope claim of the roles and the tenants' scopes
+func joinScopes(roles []string, tenants []string) string {
+	scopes := append([]string{}, roles...)
+	for _, tenant := range tenants {
+		scopes = append(scopes, TenantScopePrefix+tenant)
+	}
+	return strings.Join(scopes, " ")
+}
+
+// how long an API key that was looked up is cached, which is also how long a revoked key may still be accepted
+const apiKeyCacheTTL = 30 * time.Second
+
+// apiKeyCache keeps the API keys that were found for a short time, so a key isn't read from Elastic on every request.
+// Lookups of unknown keys and errors aren't cached, so the cache only grows with the keys that exist.
+type apiKeyCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	now    func() time.Time
+	lookup func(ctx context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError)
+	keys   map[string]cachedApiKey
+}
+
+type cachedApiKey struct {
+	apiKey  *model.ApiKey
+	expires time.Time
+}
+
+func newApiKeyCache(ttl time.Duration,
+	lookup func(ctx context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError)) *apiKeyCache {
+	return &apiKeyCache{ttl: ttl, now: time.Now, lookup: lookup, keys: map[string]cachedApiKey{}}
+}
+
+// get returns the cached key, or looks it up when it isn't cached or expired. The lock isn't held during the lookup,
+// so a slow lookup doesn't hold up requests with other keys.
+func (c *apiKeyCache) get(ctx context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError) {
+	c.mu.Lock()
+	cached, ok := c.keys[keyId]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.apiKey, nil
+	}
+
+	apiKey, elasticErr := c.lookup(ctx, keyId)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elasticErr != nil || apiKey == nil {
+		delete(c.keys, keyId)
+		return apiKey, elasticErr
+	}
+	c.keys[keyId] = cachedApiKey{apiKey: apiKey, expires: c.now().Add(c.ttl)}
+	return apiKey, nil
+}
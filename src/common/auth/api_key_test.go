@@ -0,0 +1,192 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewApiKey(t *testing.T) {
+	key, keyId, err := NewApiKey()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^hri_[0-9a-f]{16}_[A-Za-z0-9_-]{43}$`), key)
+
+	parsedId, ok := parseApiKey(key)
+	assert.True(t, ok)
+	assert.Equal(t, keyId, parsedId)
+
+	otherKey, otherId, err := NewApiKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, otherKey)
+	assert.NotEqual(t, keyId, otherId)
+}
+
+func TestParseApiKey(t *testing.T) {
+	tests := []struct {
+		key        string
+		expectedId string
+	}{
+		{key: "hri_0123456789abcdef_secret", expectedId: "0123456789abcdef"},
+		{key: "hri_0123456789abcdef_secret_with_underscores", expectedId: "0123456789abcdef"},
+		{key: "hri_0123456789abcdef_"},
+		{key: "hri_0123456789abcdeg_secret"},
+		{key: "hri_0123_secret"},
+		{key: "key_0123456789abcdef_secret"},
+		{key: "Bearer token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			keyId, ok := parseApiKey(tt.key)
+			assert.Equal(t, tt.expectedId != "", ok)
+			assert.Equal(t, tt.expectedId, keyId)
+		})
+	}
+}
+
+func TestGetValidatedClaimsWithApiKey(t *testing.T) {
+	key := "hri_0123456789abcdef_secret"
+	revokedKey := "hri_fedcba9876543210_secret"
+	apiKeys := map[string]*model.ApiKey{
+		"0123456789abcdef": {
+			KeyId:   "0123456789abcdef",
+			Name:    "nightly-ingest",
+			Hash:    HashApiKey(key),
+			Scopes:  []string{HriIntegrator},
+			Tenants: []string{tenantId},
+		},
+		"fedcba9876543210": {
+			KeyId:       "fedcba9876543210",
+			Name:        "old-ingest",
+			Hash:        HashApiKey(revokedKey),
+			Scopes:      []string{HriAdmin},
+			RevokedDate: "2021-02-26T18:08:36Z",
+		},
+	}
+	validator := theValidator{issuer: issuer, audienceId: audienceId, keys: newFakeKeyCache(nil),
+		getApiKey: func(_ context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError) {
+			if keyId == "0000000000000000" {
+				return nil, &elastic.ResponseError{ErrorObj: errors.New("connection refused"), Code: http.StatusInternalServerError}
+			}
+			return apiKeys[keyId], nil
+		},
+	}
+	keyRequest := func(key string) *http.Request {
+		request := authorizedRequest("")
+		request.Header.Set(ApiKeyHeader, key)
+		return request
+	}
+
+	claims, errResp := validator.GetValidatedClaims(requestId, keyRequest(key), tenantId)
+	assert.Nil(t, errResp)
+	assert.Equal(t, HriClaims{Scope: HriIntegrator + " tenant_" + tenantId, Subject: "apikey:0123456789abcdef", ClientId: "0123456789abcdef"}, claims)
+
+	_, errResp = validator.GetValidatedAdminClaims(requestId, keyRequest(key))
+	assert.Equal(t, response.NewErrorDetailResponse(http.StatusForbidden, requestId,
+		"Unauthorized admin access. 'hri_admin' is not included in the authorized scopes: hri_data_integrator tenant_tenantId."), errResp)
+
+	tests := []struct {
+		name    string
+		key     string
+		expResp *response.ErrorDetailResponse
+	}{
+		{
+			name:    "malformed",
+			key:     "not-a-key",
+			expResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, MsgInvalidApiKey),
+		},
+		{
+			name:    "unknown",
+			key:     "hri_1111111111111111_secret",
+			expResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, MsgInvalidApiKey),
+		},
+		{
+			name:    "wrong secret",
+			key:     "hri_0123456789abcdef_guess",
+			expResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, MsgInvalidApiKey),
+		},
+		{
+			name: "revoked",
+			key:  revokedKey,
+			expResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId,
+				"The API key [fedcba9876543210] has been revoked"),
+		},
+		{
+			name: "lookup error",
+			key:  "hri_0000000000000000_secret",
+			expResp: response.NewErrorDetailResponse(http.StatusInternalServerError, requestId,
+				"Failed to look up the API key: connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errResp := validator.GetValidatedAdminClaims(requestId, keyRequest(tt.key))
+			assert.Equal(t, tt.expResp, errResp)
+		})
+	}
+}
+
+func TestApiKeyCache(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	stored := &model.ApiKey{KeyId: "0123456789abcdef"}
+	lookups := 0
+	var lookupErr *elastic.ResponseError
+	cache := newApiKeyCache(apiKeyCacheTTL, func(_ context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError) {
+		lookups++
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if keyId != stored.KeyId {
+			return nil, nil
+		}
+		return stored, nil
+	})
+	cache.now = func() time.Time { return now }
+
+	// found keys are reused within the TTL
+	apiKey, elasticErr := cache.get(context.Background(), stored.KeyId)
+	assert.Nil(t, elasticErr)
+	assert.Equal(t, stored, apiKey)
+	now = now.Add(apiKeyCacheTTL - time.Second)
+	stored = &model.ApiKey{KeyId: "0123456789abcdef", RevokedDate: "2021-06-01T12:00:10Z"}
+	apiKey, _ = cache.get(context.Background(), stored.KeyId)
+	assert.Empty(t, apiKey.RevokedDate)
+	assert.Equal(t, 1, lookups)
+
+	// and looked up again once it expires, so the revocation is seen
+	now = now.Add(time.Second)
+	apiKey, _ = cache.get(context.Background(), stored.KeyId)
+	assert.Equal(t, "2021-06-01T12:00:10Z", apiKey.RevokedDate)
+	assert.Equal(t, 2, lookups)
+
+	// unknown keys aren't cached
+	for i := 0; i < 2; i++ {
+		apiKey, elasticErr = cache.get(context.Background(), "fedcba9876543210")
+		assert.Nil(t, apiKey)
+		assert.Nil(t, elasticErr)
+	}
+	assert.Equal(t, 4, lookups)
+
+	// neither are errors
+	now = now.Add(apiKeyCacheTTL)
+	lookupErr = &elastic.ResponseError{ErrorObj: errors.New("timeout"), Code: http.StatusInternalServerError}
+	for i := 0; i < 2; i++ {
+		_, elasticErr = cache.get(context.Background(), stored.KeyId)
+		assert.Equal(t, lookupErr, elasticErr)
+	}
+	assert.Equal(t, 6, lookups)
+	assert.NotContains(t, cache.keys, stored.KeyId)
+}
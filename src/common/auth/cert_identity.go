@@ -0,0 +1,117 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"strings"
+)
+
+// CertIdentity is the HRI identity of the clients that authenticate with a matching TLS client certificate. It gives
+// them the same claims as a JWT with the roles and tenants as scopes, so the authorization policy applies to them too.
+type CertIdentity struct {
+	// the certificate's subject distinguished name, e.g. CN=batch-job,OU=Ingest,O=Example
+	Subject string `yaml:"subject"`
+	// one of the certificate's DNS, email, URI or IP subject alternative names
+	San string `yaml:"san"`
+	// the claims' subject, which integrators are identified by, defaults to the certificate's common name
+	Name    string   `yaml:"name"`
+	Roles   []string `yaml:"roles"`
+	Tenants []string `yaml:"tenants"`
+}
+
+type certIdentityFile struct {
+	Identities []CertIdentity `yaml:"identities"`
+}
+
+var globalCertIdentities []CertIdentity
+
+// LoadCertIdentities reads and validates a YAML file of certificate identities
+func LoadCertIdentities(path string) ([]CertIdentity, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the certificate identity file: %w", err)
+	}
+
+	file := certIdentityFile{}
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return nil, fmt.Errorf("unable to parse the certificate identity file %s: %w", path, err)
+	}
+
+	errorHeader := "Certificate identity errors:"
+	errorBuilder := strings.Builder{}
+	errorBuilder.WriteString(errorHeader)
+	if len(file.Identities) == 0 {
+		errorBuilder.WriteString("\n\tThe file has no identities")
+	}
+	for i, identity := range file.Identities {
+		if (identity.Subject == "") == (identity.San == "") {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tIdentity %d must have either a subject or a san", i+1))
+		}
+		if len(identity.Roles) == 0 && len(identity.Tenants) == 0 {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tIdentity %d has no roles or tenants", i+1))
+		}
+	}
+	if errorMsg := errorBuilder.String(); len(errorMsg) > len(errorHeader) {
+		return nil, errors.New(errorMsg)
+	}
+	return file.Identities, nil
+}
+
+// InitializeCertIdentities loads the identities of the clients that authenticate with a certificate, which are used by
+// the validators created afterwards. Without a file, clients can't authenticate with a certificate.
+func InitializeCertIdentities(path string) error {
+	if path == "" {
+		globalCertIdentities = nil
+		return nil
+	}
+	identities, err := LoadCertIdentities(path)
+	if err != nil {
+		return err
+	}
+	globalCertIdentities = identities
+	return nil
+}
+
+// findCertIdentity returns the first identity that matches the certificate, nil if none does
+func findCertIdentity(identities []CertIdentity, cert *x509.Certificate) *CertIdentity {
+	for i, identity := range identities {
+		if identity.Subject != "" && identity.Subject == cert.Subject.String() {
+			return &identities[i]
+		}
+		if identity.San != "" && contains(subjectAltNames(cert), identity.San) {
+			return &identities[i]
+		}
+	}
+	return nil
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	names := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.URIs)+len(cert.IPAddresses))
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+// claims returns the identity's claims, with the roles and tenant scopes as the scope and the certificate's subject as
+// the client id
+func (i CertIdentity) claims(cert *x509.Certificate) HriClaims {
+	subject := i.Name
+	if subject == "" {
+		subject = cert.Subject.CommonName
+	}
+	return HriClaims{Scope: joinScopes(i.Roles, i.Tenants), Subject: subject, ClientId: cert.Subject.String()}
+}
@@ -0,0 +1,185 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCertIdentities = `
+identities:
+  - subject: CN=batch-job,OU=Ingest,O=Example
+    roles: [hri_data_integrator]
+    tenants: [tenant1, tenant2]
+  - san: spiffe://example.com/consumer
+    name: consumer
+    roles: [hri_consumer]
+    tenants: [tenant1]
+`
+
+func writeCertIdentities(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "identities.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCertIdentities(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		expErr  string
+	}{
+		{
+			name:    "valid",
+			content: testCertIdentities,
+		},
+		{
+			name:    "no identities",
+			content: "identities: []",
+			expErr:  "Certificate identity errors:\n\tThe file has no identities",
+		},
+		{
+			name: "invalid identities",
+			content: `
+identities:
+  - subject: CN=job
+    san: job.example.com
+    roles: [hri_consumer]
+  - name: nobody
+`,
+			expErr: "Certificate identity errors:" +
+				"\n\tIdentity 1 must have either a subject or a san" +
+				"\n\tIdentity 2 must have either a subject or a san" +
+				"\n\tIdentity 2 has no roles or tenants",
+		},
+		{
+			name:    "unknown field",
+			content: "identities:\n  - subject: CN=job\n    role: [hri_consumer]\n",
+			expErr:  "unable to parse the certificate identity file {path}: yaml: unmarshal errors:\n  line 3: field role not found in type auth.CertIdentity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeCertIdentities(t, tt.content)
+			identities, err := LoadCertIdentities(path)
+			if tt.expErr == "" {
+				assert.NoError(t, err)
+				assert.Len(t, identities, 2)
+			} else {
+				assert.EqualError(t, err, strings.ReplaceAll(tt.expErr, "{path}", path))
+			}
+		})
+	}
+}
+
+func TestInitializeCertIdentities(t *testing.T) {
+	defer func() { globalCertIdentities = nil }()
+
+	assert.NoError(t, InitializeCertIdentities(writeCertIdentities(t, testCertIdentities)))
+	assert.Len(t, globalCertIdentities, 2)
+	assert.Len(t, NewValidator(config.Config{OidcIssuer: issuer}).(theValidator).identities, 2)
+
+	assert.Error(t, InitializeCertIdentities(filepath.Join(t.TempDir(), "missing.yml")))
+	assert.Len(t, globalCertIdentities, 2)
+
+	assert.NoError(t, InitializeCertIdentities(""))
+	assert.Nil(t, globalCertIdentities)
+}
+
+func TestFindCertIdentity(t *testing.T) {
+	identities := []CertIdentity{
+		{Subject: "CN=batch-job,OU=Ingest,O=Example", Roles: []string{HriIntegrator}},
+		{San: "consumer.example.com", Roles: []string{HriConsumer}},
+		{San: "spiffe://example.com/internal", Roles: []string{HriInternal}},
+		{San: "10.0.0.1", Roles: []string{HriAdmin}},
+	}
+	spiffeId, _ := url.Parse("spiffe://example.com/internal")
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		expected *CertIdentity
+	}{
+		{
+			name:     "subject",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "batch-job", OrganizationalUnit: []string{"Ingest"}, Organization: []string{"Example"}}},
+			expected: &identities[0],
+		},
+		{
+			name:     "dns san",
+			cert:     &x509.Certificate{Subject: pkix.Name{CommonName: "consumer"}, DNSNames: []string{"other.example.com", "consumer.example.com"}},
+			expected: &identities[1],
+		},
+		{
+			name:     "uri san",
+			cert:     &x509.Certificate{URIs: []*url.URL{spiffeId}},
+			expected: &identities[2],
+		},
+		{
+			name:     "ip san",
+			cert:     &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}},
+			expected: &identities[3],
+		},
+		{
+			name: "no match",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "batch-job"}, EmailAddresses: []string{"job@example.com"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, findCertIdentity(identities, tt.cert))
+		})
+	}
+}
+
+func TestGetValidatedClaimsWithCertificate(t *testing.T) {
+	identities := []CertIdentity{
+		{Subject: "CN=batch-job", Roles: []string{HriIntegrator}, Tenants: []string{tenantId}},
+		{San: "admin.example.com", Name: "admin", Roles: []string{HriAdmin}},
+	}
+	validator := theValidator{issuer: issuer, audienceId: audienceId, keys: newFakeKeyCache(nil), identities: identities}
+
+	certRequest := func(cert *x509.Certificate) *http.Request {
+		request := httptest.NewRequest(http.MethodGet, "/hri/tenants", nil)
+		request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return request
+	}
+	integratorCert := &x509.Certificate{Subject: pkix.Name{CommonName: "batch-job"}}
+	adminCert := &x509.Certificate{Subject: pkix.Name{CommonName: "ops"}, DNSNames: []string{"admin.example.com"}}
+
+	claims, errResp := validator.GetValidatedClaims(requestId, certRequest(integratorCert), tenantId)
+	assert.Nil(t, errResp)
+	assert.Equal(t, HriClaims{Scope: HriIntegrator + " tenant_" + tenantId, Subject: "batch-job", ClientId: "CN=batch-job"}, claims)
+
+	_, errResp = validator.GetValidatedClaims(requestId, certRequest(integratorCert), "otherTenant")
+	assert.Equal(t, response.NewErrorDetailResponse(http.StatusForbidden, requestId,
+		"Unauthorized tenant access. Tenant 'otherTenant' is not included in the authorized scopes: hri_data_integrator tenant_tenantId."), errResp)
+
+	claims, errResp = validator.GetValidatedAdminClaims(requestId, certRequest(adminCert))
+	assert.Nil(t, errResp)
+	assert.Equal(t, HriClaims{Scope: HriAdmin, Subject: "admin", ClientId: "CN=ops"}, claims)
+
+	_, errResp = validator.GetValidatedAdminClaims(requestId, certRequest(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}}))
+	assert.Equal(t, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId,
+		"The client certificate 'CN=unknown' isn't mapped to an HRI identity"), errResp)
+}
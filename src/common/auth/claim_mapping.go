@@ -0,0 +1,106 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
+	"strings"
+)
+
+const defaultScopeClaim = "scope"
+
+// claimMapping builds the HRI scopes from the claims of identity providers that don't issue HRI scopes in the
+// `scope` claim, e.g. Keycloak's `realm_access.roles` or Azure AD's `roles` and `groups` arrays. The values of the
+// claims are mapped to HRI scopes; values that aren't mapped are dropped, so the identity provider only grants the
+// scopes that are configured, unless passthrough is enabled.
+type claimMapping struct {
+	// dot separated paths of the claims that hold scopes or roles, e.g. realm_access.roles
+	paths [][]string
+	// identity provider value -> HRI scope, e.g. hri-integrators -> hri_data_integrator
+	values map[string]string
+	// values with this prefix are tenant scopes, e.g. with prefix hri-tenant-, hri-tenant-123 is tenant_123. No value
+	// is a tenant scope when it's empty
+	tenantPrefix string
+	// keep the values that aren't mapped as they are
+	passthrough bool
+}
+
+// newClaimMapping returns nil when the HRI scopes are read from the `scope` claim as they are
+func newClaimMapping(config configPkg.Config) *claimMapping {
+	paths := config.ClaimScopePaths
+	if len(paths) == 0 {
+		paths = []string{defaultScopeClaim}
+	}
+	tenantPrefix := config.ClaimTenantPrefix
+	if len(paths) == 1 && paths[0] == defaultScopeClaim && len(config.ClaimValueMappings) == 0 &&
+		(tenantPrefix == "" || tenantPrefix == TenantScopePrefix) {
+		return nil
+	}
+
+	mapping := &claimMapping{
+		values:       config.ClaimValueMappings,
+		tenantPrefix: tenantPrefix,
+		passthrough:  config.ClaimPassthrough,
+	}
+	for _, path := range paths {
+		mapping.paths = append(mapping.paths, strings.Split(path, "."))
+	}
+	return mapping
+}
+
+// scope returns the HRI scopes found in the claims, space delimited like the `scope` claim
+func (m *claimMapping) scope(claims map[string]interface{}) string {
+	scopes := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, path := range m.paths {
+		for _, value := range claimValues(claims, path) {
+			scope, ok := m.mapValue(value)
+			if ok && !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+// mapValue returns the HRI scope of the claim value; ok is false when the value isn't mapped and is dropped
+func (m *claimMapping) mapValue(value string) (scope string, ok bool) {
+	if scope, ok := m.values[value]; ok {
+		return scope, true
+	}
+	if m.tenantPrefix != "" && strings.HasPrefix(value, m.tenantPrefix) {
+		return TenantScopePrefix + strings.TrimPrefix(value, m.tenantPrefix), true
+	}
+	return value, m.passthrough
+}
+
+// claimValues returns the strings at the path; a string claim is split on spaces, like the `scope` claim
+func claimValues(claims map[string]interface{}, path []string) []string {
+	var claim interface{} = claims
+	for _, name := range path {
+		object, ok := claim.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		claim = object[name]
+	}
+
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []interface{}:
+		values := make([]string, 0, len(claim))
+		for _, element := range claim {
+			if value, ok := element.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
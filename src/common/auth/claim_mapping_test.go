@@ -0,0 +1,120 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"encoding/json"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewClaimMapping(t *testing.T) {
+	assert.Nil(t, newClaimMapping(config.Config{}))
+	assert.Nil(t, newClaimMapping(config.Config{ClaimScopePaths: config.StringSlice{"scope"}, ClaimTenantPrefix: "tenant_"}))
+
+	mapping := newClaimMapping(config.Config{ClaimScopePaths: config.StringSlice{"realm_access.roles", "groups"}, ClaimPassthrough: true})
+	assert.Equal(t, &claimMapping{
+		paths:       [][]string{{"realm_access", "roles"}, {"groups"}},
+		passthrough: true,
+	}, mapping)
+}
+
+func TestClaimMappingScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   config.Config
+		claims   string
+		expected string
+	}{
+		{
+			name:     "keycloak realm roles with passthrough",
+			config:   config.Config{ClaimScopePaths: config.StringSlice{"realm_access.roles"}, ClaimPassthrough: true},
+			claims:   `{"scope":"openid","realm_access":{"roles":["hri_data_integrator","tenant_123","offline_access"]}}`,
+			expected: "hri_data_integrator tenant_123 offline_access",
+		},
+		{
+			name: "azure roles and groups",
+			config: config.Config{
+				ClaimScopePaths:    config.StringSlice{"roles", "groups"},
+				ClaimValueMappings: config.StringMap{"HRI.Consumer": HriConsumer, "0b8e7c4e-group-id": HriInternal},
+				ClaimTenantPrefix:  "HRI.Tenant.",
+			},
+			claims:   `{"roles":["HRI.Consumer","HRI.Tenant.123","HRI.Consumer"],"groups":["0b8e7c4e-group-id","other"]}`,
+			expected: "hri_consumer tenant_123 hri_internal",
+		},
+		{
+			name: "unmapped values are dropped",
+			config: config.Config{
+				ClaimScopePaths:    config.StringSlice{"roles", "groups"},
+				ClaimValueMappings: config.StringMap{"HRI.Consumer": HriConsumer},
+			},
+			claims:   `{"roles":["HRI.Consumer","hri_admin"],"groups":["tenant_acme","hri_internal"]}`,
+			expected: "hri_consumer",
+		},
+		{
+			name: "string claim",
+			config: config.Config{
+				ClaimScopePaths:    config.StringSlice{"scp"},
+				ClaimValueMappings: config.StringMap{"Batches.Write": HriIntegrator},
+				ClaimTenantPrefix:  TenantScopePrefix,
+			},
+			claims:   `{"scp":"Batches.Write tenant_123 other"}`,
+			expected: "hri_data_integrator tenant_123",
+		},
+		{
+			name:     "missing or invalid claims",
+			config:   config.Config{ClaimScopePaths: config.StringSlice{"realm_access.roles", "groups", "scope.roles"}},
+			claims:   `{"realm_access":{},"groups":[1,{"name":"a"}],"scope":"hri_consumer"}`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(tt.claims), &claims); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expected, newClaimMapping(tt.config).scope(claims))
+		})
+	}
+}
+
+type jsonClaimsHolder string
+
+func (j jsonClaimsHolder) Claims(claims interface{}) error {
+	return json.Unmarshal([]byte(j), claims)
+}
+
+func TestGetValidatedClaimsWithClaimMapping(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+	mocktokenVerifier := NewMocktokenVerifier(controller)
+
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(jsonClaimsHolder(`{"sub":"subject","aud":"audienceId","groups":["hri-integrators","hri-tenant-tenantId"]}`), nil)
+
+	validator := theValidator{
+		issuer:     issuer,
+		audienceId: audienceId,
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
+		mapping: newClaimMapping(config.Config{
+			ClaimScopePaths:    config.StringSlice{"groups"},
+			ClaimValueMappings: config.StringMap{"hri-integrators": HriIntegrator},
+			ClaimTenantPrefix:  "hri-tenant-",
+		}),
+	}
+
+	claims, errResp := validator.GetValidatedClaims(requestId, authorizedRequest(authorization), tenantId)
+	assert.Nil(t, errResp)
+	assert.Equal(t, HriClaims{Scope: HriIntegrator + " tenant_" + tenantId, Subject: "subject", Audience: []string{audienceId}}, claims)
+	assert.True(t, claims.HasScope(HriIntegrator))
+}
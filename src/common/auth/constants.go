@@ -9,11 +9,16 @@ const (
 	HriIntegrator                string = "hri_data_integrator"
 	HriInternal                  string = "hri_internal"
 	HriConsumer                  string = "hri_consumer"
+	HriAdmin                     string = "hri_admin"
 	NoAuthFakeIntegrator         string = "NoAuthUnkIntegrator"
+	NoAuthFakeAdmin              string = "NoAuthUnkAdmin"
 	TenantScopePrefix            string = "tenant_"
 	MsgAccessTokenMissingScopes         = "The access token must have one of these scopes: hri_consumer, hri_data_integrator"
 	MsgIntegratorSubClaimNoMatch        = "The token's sub claim (clientId): %s does not match the data integratorId: %s"
 	MsgIntegratorRoleRequired           = "Must have hri_data_integrator role to %s a batch"
 	MsgInternalRoleRequired             = "Must have hri_internal role to mark a batch as %s"
+	MsgAdminRoleRequired                = "Must have hri_admin role to %s a legal hold"
 	MsgSubClaimRequiredInJwt            = "JWT access token 'sub' claim must be populated."
+	MsgInvalidApiKey                    = "Invalid API key"
+	MsgApiKeyRevoked                    = "The API key [%s] has been revoked"
 )
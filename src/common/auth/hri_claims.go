@@ -6,6 +6,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"strings"
 )
 
@@ -19,6 +20,37 @@ type HriClaims struct {
 	Scope    string   `json:"scope"`
 	Subject  string   `json:"sub"`
 	Audience []string `json:"aud"`
+	// the OAuth client the token was issued to, the API key id, or the client certificate's subject
+	ClientId string `json:"client_id"`
+}
+
+// UnmarshalJSON accepts an `aud` claim with a single audience as a string, as issued by Azure AD and Keycloak. The
+// client id is taken from the `azp` claim when the token has no `client_id` claim.
+func (c *HriClaims) UnmarshalJSON(data []byte) error {
+	type claims HriClaims
+	var raw struct {
+		claims
+		Audience        json.RawMessage `json:"aud"`
+		AuthorizedParty string          `json:"azp"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = HriClaims(raw.claims)
+	if c.ClientId == "" {
+		c.ClientId = raw.AuthorizedParty
+	}
+
+	if len(raw.Audience) == 0 || string(raw.Audience) == "null" {
+		c.Audience = nil
+		return nil
+	}
+	var audience string
+	if err := json.Unmarshal(raw.Audience, &audience); err == nil {
+		c.Audience = []string{audience}
+		return nil
+	}
+	return json.Unmarshal(raw.Audience, &c.Audience)
 }
 
 func (c HriClaims) HasScope(claim string) bool {
@@ -96,3 +96,40 @@ func TestUnmarshalClaims(t *testing.T) {
 		t.Fatalf("Unexpected result.\nexpected: %v\nactual  : %v", []string{testAudience}, claims.Audience)
 	}
 }
+
+func TestUnmarshalClaimsStringAudience(t *testing.T) {
+	claims := HriClaims{}
+	if err := json.Unmarshal([]byte(`{"sub":"testSubject","aud":"testAudience"}`), &claims); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := HriClaims{Subject: "testSubject", Audience: []string{"testAudience"}}
+	if !reflect.DeepEqual(claims, expected) {
+		t.Fatalf("Unexpected result.\nexpected: %v\nactual  : %v", expected, claims)
+	}
+
+	if err := json.Unmarshal([]byte(`{"aud":5}`), &claims); err == nil {
+		t.Fatal("Expected an error for a numeric aud claim")
+	}
+}
+
+func TestUnmarshalClaimsClientId(t *testing.T) {
+	tests := []struct {
+		token    string
+		expected string
+	}{
+		{`{"sub":"testSubject","client_id":"testClient"}`, "testClient"},
+		{`{"sub":"testSubject","azp":"testParty"}`, "testParty"},
+		{`{"sub":"testSubject","client_id":"testClient","azp":"testParty"}`, "testClient"},
+		{`{"sub":"testSubject"}`, ""},
+	}
+
+	for _, tt := range tests {
+		claims := HriClaims{}
+		if err := json.Unmarshal([]byte(tt.token), &claims); err != nil {
+			t.Fatal(err.Error())
+		}
+		if claims.ClientId != tt.expected {
+			t.Fatalf("Unexpected client id for %s.\nexpected: %v\nactual  : %v", tt.token, tt.expected, claims.ClientId)
+		}
+	}
+}
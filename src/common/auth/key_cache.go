@@ -0,0 +1,369 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"gopkg.in/square/go-jose.v2"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keyRefreshRequestId = "oidc-key-refresh"
+
+// tokens signed with an unknown key trigger a refresh, in case the issuer rotated its keys, but at most this often
+const minKeyRefreshInterval = time.Minute
+
+const keyFetchTimeout = 10 * time.Second
+
+// KeyCache holds the OIDC issuer's token signing keys, so tokens are verified without calling the issuer on every
+// request. It implements oidc.KeySet. When refreshing the keys fails, the last keys that were fetched are kept, so
+// tokens can still be verified while the issuer is unavailable. Without a reachable issuer, the keys can be read from a
+// local key file instead.
+type KeyCache struct {
+	issuer  string
+	keyFile string // when set, the keys are read from this file instead of the issuer
+	fetch   func(ctx context.Context, issuer string) ([]jose.JSONWebKey, error)
+
+	mu          sync.RWMutex
+	keys        []jose.JSONWebKey
+	lastRefresh time.Time // last successful refresh
+	lastAttempt time.Time
+	lastErr     error
+
+	// only one refresh runs at a time
+	refreshMu sync.Mutex
+}
+
+// KeyCacheState is reported by the healthcheck
+type KeyCacheState struct {
+	Issuer      string `json:"issuer"`
+	KeyFile     string `json:"keyFile,omitempty"`
+	Keys        int    `json:"keys"`
+	LastRefresh string `json:"lastRefresh,omitempty"`
+	LastAttempt string `json:"lastAttempt,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	// the last refresh failed, so the keys from an earlier refresh are used
+	Stale bool `json:"stale"`
+}
+
+var sharedKeyCache struct {
+	sync.Mutex
+	cache *KeyCache
+}
+
+func newKeyCache(issuer string, keyFile string) *KeyCache {
+	if keyFile != "" {
+		return &KeyCache{issuer: issuer, keyFile: keyFile, fetch: func(_ context.Context, _ string) ([]jose.JSONWebKey, error) {
+			return readKeyFile(keyFile)
+		}}
+	}
+	return &KeyCache{issuer: issuer, fetch: fetchKeys}
+}
+
+// getKeyCache returns the cache of the issuer's keys that all the validators share
+func getKeyCache(config configPkg.Config) *KeyCache {
+	sharedKeyCache.Lock()
+	defer sharedKeyCache.Unlock()
+	cache := sharedKeyCache.cache
+	if cache == nil || cache.issuer != config.OidcIssuer || cache.keyFile != config.OidcKeyFile {
+		sharedKeyCache.cache = newKeyCache(config.OidcIssuer, config.OidcKeyFile)
+	}
+	return sharedKeyCache.cache
+}
+
+// StartKeyRefresh fetches the issuer's keys, or reads the key file, and refreshes them every OidcKeyRefreshSecs seconds.
+// With 0 seconds, the keys are only refreshed when a token is signed with an unknown key. The returned function stops
+// the refresh.
+func StartKeyRefresh(config configPkg.Config) func() {
+	cache := getKeyCache(config)
+	refreshSecs := config.OidcKeyRefreshSecs
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		cache.refreshAndLog()
+		if refreshSecs <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(time.Duration(refreshSecs) * time.Second)
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				cache.refreshAndLog()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// GetKeyCacheState returns nil when no validator was created, i.e. when authorization is disabled
+func GetKeyCacheState() *KeyCacheState {
+	sharedKeyCache.Lock()
+	cache := sharedKeyCache.cache
+	sharedKeyCache.Unlock()
+	if cache == nil {
+		return nil
+	}
+	state := cache.State()
+	return &state
+}
+
+func (c *KeyCache) State() KeyCacheState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	state := KeyCacheState{Issuer: c.issuer, KeyFile: c.keyFile, Keys: len(c.keys)}
+	if !c.lastRefresh.IsZero() {
+		state.LastRefresh = c.lastRefresh.UTC().Format(time.RFC3339)
+	}
+	if !c.lastAttempt.IsZero() {
+		state.LastAttempt = c.lastAttempt.UTC().Format(time.RFC3339)
+	}
+	if c.lastErr != nil {
+		state.LastError = c.lastErr.Error()
+		state.Stale = len(c.keys) > 0
+	}
+	return state
+}
+
+// VerifySignature implements oidc.KeySet
+func (c *KeyCache) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed jwt: %v", err)
+	}
+
+	keyId := ""
+	for _, signature := range jws.Signatures {
+		keyId = signature.Header.KeyID
+		break
+	}
+
+	keys, lastAttempt := c.cachedKeys()
+	if payload, ok := verifyWithKeys(jws, keyId, keys); ok {
+		return payload, nil
+	}
+
+	// the issuer may have rotated its keys
+	if time.Since(lastAttempt) < minKeyRefreshInterval {
+		return nil, errors.New("failed to verify id token signature")
+	}
+	if err := c.refresh(ctx, lastAttempt); err != nil {
+		return nil, fmt.Errorf("failed to verify id token signature, the signing keys can't be refreshed: %w", err)
+	}
+	keys, _ = c.cachedKeys()
+	if payload, ok := verifyWithKeys(jws, keyId, keys); ok {
+		return payload, nil
+	}
+	return nil, errors.New("failed to verify id token signature")
+}
+
+// ensureKeys fetches the keys if there aren't any yet
+func (c *KeyCache) ensureKeys(ctx context.Context) error {
+	keys, lastAttempt := c.cachedKeys()
+	if len(keys) > 0 {
+		return nil
+	}
+	if err := c.refresh(ctx, lastAttempt); err != nil {
+		return err
+	}
+	if keys, _ = c.cachedKeys(); len(keys) == 0 {
+		if c.keyFile != "" {
+			return fmt.Errorf("the key file %s has no signing keys", c.keyFile)
+		}
+		return fmt.Errorf("the issuer %s has no signing keys", c.issuer)
+	}
+	return nil
+}
+
+func (c *KeyCache) cachedKeys() ([]jose.JSONWebKey, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.keys, c.lastAttempt
+}
+
+// refresh fetches the keys, unless another refresh was attempted since seenAttempt, in which case its result is used
+func (c *KeyCache) refresh(ctx context.Context, seenAttempt time.Time) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	lastAttempt, lastErr := c.lastAttempt, c.lastErr
+	c.mu.RUnlock()
+	if lastAttempt.After(seenAttempt) {
+		return lastErr
+	}
+
+	keys, err := c.fetch(ctx, c.issuer)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastAttempt = time.Now()
+	c.lastErr = err
+	if err == nil {
+		c.keys = keys
+		c.lastRefresh = c.lastAttempt
+	}
+	return err
+}
+
+func (c *KeyCache) refreshAndLog() {
+	prefix := "auth/keyRefresh"
+	logger := logwrapper.GetMyLogger(keyRefreshRequestId, prefix)
+
+	_, lastAttempt := c.cachedKeys()
+	if err := c.refresh(context.Background(), lastAttempt); err != nil {
+		state := c.State()
+		logger.Errorf("Unable to refresh the OIDC signing keys, still using %d keys from %s: %s",
+			state.Keys, state.LastRefresh, err.Error())
+		return
+	}
+	if c.keyFile != "" {
+		logger.Debugf("Read the OIDC signing keys from %s", c.keyFile)
+		return
+	}
+	logger.Debugf("Refreshed the OIDC signing keys of %s", c.issuer)
+}
+
+func verifyWithKeys(jws *jose.JSONWebSignature, keyId string, keys []jose.JSONWebKey) ([]byte, bool) {
+	for _, key := range keys {
+		// keys read from PEM files don't have an id
+		if keyId == "" || key.KeyID == "" || key.KeyID == keyId {
+			if payload, err := jws.Verify(&key); err == nil {
+				return payload, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// fetchKeys uses OIDC discovery to find the issuer's key set and returns its keys
+func fetchKeys(ctx context.Context, issuer string) ([]jose.JSONWebKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, keyFetchTimeout)
+	defer cancel()
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JwksUri string `json:"jwks_uri"`
+	}
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJson(ctx, wellKnown, &discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("OIDC discovery failed: issuer did not match the issuer returned by provider, expected %q got %q",
+			issuer, discovery.Issuer)
+	}
+
+	var keySet jose.JSONWebKeySet
+	if err := getJson(ctx, discovery.JwksUri, &keySet); err != nil {
+		return nil, fmt.Errorf("unable to get the signing keys: %w", err)
+	}
+	return keySet.Keys, nil
+}
+
+// readKeyFile reads the public keys tokens are signed with from a JWKS file, or a file of PEM encoded public keys or
+// certificates. Private and symmetric keys are rejected, so a key file can't be used to sign tokens.
+func readKeyFile(path string) ([]jose.JSONWebKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the key file: %w", err)
+	}
+
+	var keys []jose.JSONWebKey
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		var keySet jose.JSONWebKeySet
+		if err := json.Unmarshal(trimmed, &keySet); err != nil {
+			return nil, fmt.Errorf("unable to parse the JWKS key file %s: %w", path, err)
+		}
+		keys = keySet.Keys
+	} else if keys, err = parsePemKeys(content); err != nil {
+		return nil, fmt.Errorf("unable to parse the PEM key file %s: %w", path, err)
+	}
+
+	for i, key := range keys {
+		if !key.IsPublic() {
+			return nil, fmt.Errorf("the key file %s contains a key that isn't a public key: %d '%s'", path, i+1, key.KeyID)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the key file %s has no keys", path)
+	}
+	return keys, nil
+}
+
+func parsePemKeys(content []byte) ([]jose.JSONWebKey, error) {
+	var keys []jose.JSONWebKey
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return keys, nil
+		}
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			return nil, fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jose.JSONWebKey{Key: key, Use: "sig"})
+	}
+}
+
+func getJson(ctx context.Context, url string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return json.Unmarshal(body, v)
+}
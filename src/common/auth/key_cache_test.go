@@ -0,0 +1,291 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newSigningKey(t *testing.T, keyId string) (jose.Signer, jose.JSONWebKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: privateKey, KeyID: keyId}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, jose.JSONWebKey{Key: &privateKey.PublicKey, KeyID: keyId, Algorithm: string(jose.RS256), Use: "sig"}
+}
+
+func sign(t *testing.T, signer jose.Signer, payload string) string {
+	jws, err := signer.Sign([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwt
+}
+
+func TestKeyCacheVerifySignature(t *testing.T) {
+	signer1, key1 := newSigningKey(t, "key1")
+	signer2, key2 := newSigningKey(t, "key2")
+	payload := `{"sub":"subject"}`
+
+	tests := []struct {
+		name        string
+		keys        []jose.JSONWebKey
+		lastAttempt time.Time
+		fetchKeys   []jose.JSONWebKey
+		fetchErr    error
+		signer      jose.Signer
+		expFetches  int
+		expErr      string
+	}{
+		{
+			name:        "cached key",
+			keys:        []jose.JSONWebKey{key1},
+			lastAttempt: time.Now(),
+			signer:      signer1,
+		},
+		{
+			name:        "rotated key is fetched",
+			keys:        []jose.JSONWebKey{key1},
+			lastAttempt: time.Now().Add(-2 * minKeyRefreshInterval),
+			fetchKeys:   []jose.JSONWebKey{key1, key2},
+			signer:      signer2,
+			expFetches:  1,
+		},
+		{
+			name:        "unknown key refreshed recently",
+			keys:        []jose.JSONWebKey{key1},
+			lastAttempt: time.Now(),
+			signer:      signer2,
+			expErr:      "failed to verify id token signature",
+		},
+		{
+			name:        "unknown key refresh fails",
+			keys:        []jose.JSONWebKey{key1},
+			lastAttempt: time.Now().Add(-2 * minKeyRefreshInterval),
+			fetchErr:    errors.New("issuer unavailable"),
+			signer:      signer2,
+			expFetches:  1,
+			expErr:      "failed to verify id token signature, the signing keys can't be refreshed: issuer unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches := 0
+			cache := &KeyCache{
+				issuer:      issuer,
+				keys:        tt.keys,
+				lastAttempt: tt.lastAttempt,
+				fetch: func(_ context.Context, _ string) ([]jose.JSONWebKey, error) {
+					fetches++
+					return tt.fetchKeys, tt.fetchErr
+				},
+			}
+
+			actual, err := cache.VerifySignature(context.Background(), sign(t, tt.signer, payload))
+			if tt.expErr != "" {
+				assert.EqualError(t, err, tt.expErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, payload, string(actual))
+			}
+			assert.Equal(t, tt.expFetches, fetches)
+		})
+	}
+}
+
+func TestKeyCacheKeepsKeysWhenRefreshFails(t *testing.T) {
+	_, key1 := newSigningKey(t, "key1")
+	fetchErr := error(nil)
+	cache := &KeyCache{
+		issuer: issuer,
+		fetch: func(_ context.Context, _ string) ([]jose.JSONWebKey, error) {
+			if fetchErr != nil {
+				return nil, fetchErr
+			}
+			return []jose.JSONWebKey{key1}, nil
+		},
+	}
+
+	assert.Equal(t, KeyCacheState{Issuer: issuer}, cache.State())
+
+	assert.NoError(t, cache.refresh(context.Background(), time.Time{}))
+	state := cache.State()
+	assert.Equal(t, 1, state.Keys)
+	assert.False(t, state.Stale)
+	assert.NotEmpty(t, state.LastRefresh)
+
+	fetchErr = errors.New("issuer unavailable")
+	_, lastAttempt := cache.cachedKeys()
+	assert.EqualError(t, cache.refresh(context.Background(), lastAttempt), "issuer unavailable")
+	assert.NoError(t, cache.ensureKeys(context.Background()))
+	state = cache.State()
+	assert.Equal(t, 1, state.Keys)
+	assert.True(t, state.Stale)
+	assert.Equal(t, "issuer unavailable", state.LastError)
+}
+
+func TestFetchKeys(t *testing.T) {
+	_, key1 := newSigningKey(t, "key1")
+
+	var server *httptest.Server
+	discoveredIssuer := ""
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": discoveredIssuer, "jwks_uri": server.URL + "/keys"})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key1}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	discoveredIssuer = server.URL
+	keys, err := fetchKeys(context.Background(), server.URL)
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, "key1", keys[0].KeyID)
+	}
+
+	discoveredIssuer = "https://other"
+	_, err = fetchKeys(context.Background(), server.URL)
+	assert.EqualError(t, err, "OIDC discovery failed: issuer did not match the issuer returned by provider, expected \""+
+		server.URL+"\" got \"https://other\"")
+
+	_, err = fetchKeys(context.Background(), server.URL+"/missing")
+	assert.Error(t, err)
+}
+
+func TestReadKeyFile(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	certDer, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicJwks, _ := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &privateKey.PublicKey, KeyID: "key1"}}})
+	privateJwks, _ := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: privateKey, KeyID: "key1"}}})
+
+	tests := []struct {
+		name    string
+		content string
+		expKeys int
+		expErr  string
+	}{
+		{
+			name:    "jwks",
+			content: string(publicJwks),
+			expKeys: 1,
+		},
+		{
+			name: "pem public key and certificate",
+			content: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})) +
+				string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer})),
+			expKeys: 2,
+		},
+		{
+			name:    "jwks private key",
+			content: string(privateJwks),
+			expErr:  "the key file %s contains a key that isn't a public key: 1 'key1'",
+		},
+		{
+			name:    "pem private key",
+			content: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})),
+			expErr:  "unable to parse the PEM key file %s: unsupported PEM block type 'RSA PRIVATE KEY'",
+		},
+		{
+			name:    "no keys",
+			content: "not a key",
+			expErr:  "the key file %s has no keys",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			keys, err := readKeyFile(path)
+			if tt.expErr != "" {
+				assert.EqualError(t, err, fmt.Sprintf(tt.expErr, path))
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, keys, tt.expKeys)
+			}
+		})
+	}
+
+	_, err = readKeyFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestOfflineValidation(t *testing.T) {
+	signer, key := newSigningKey(t, "key1")
+	keySet, _ := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key}})
+	keyFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(keyFile, keySet, 0600); err != nil {
+		t.Fatal(err)
+	}
+	const offlineIssuer = "hri-test-issuer"
+	validator := NewValidator(config.Config{OidcIssuer: offlineIssuer, JwtAudienceId: audienceId, OidcKeyFile: keyFile})
+
+	token := func(iss string) string {
+		claims, _ := json.Marshal(map[string]interface{}{
+			"iss":   iss,
+			"aud":   []string{audienceId},
+			"sub":   "subject",
+			"scope": "tenant_" + tenantId + " " + HriIntegrator,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		return "Bearer " + sign(t, signer, string(claims))
+	}
+
+	claims, errResp := validator.GetValidatedClaims(requestId, authorizedRequest(token(offlineIssuer)), tenantId)
+	assert.Nil(t, errResp)
+	assert.True(t, claims.HasScope(HriIntegrator))
+
+	_, errResp = validator.GetValidatedClaims(requestId, authorizedRequest(token("https://other")), tenantId)
+	if assert.NotNil(t, errResp) {
+		assert.Equal(t, http.StatusUnauthorized, errResp.Code)
+	}
+}
@@ -6,10 +6,10 @@ package auth
 
 import (
 	context "context"
+	http "net/http"
 	reflect "reflect"
 
 	response "github.com/Alvearie/hri-mgmt-api/common/response"
-	go_oidc "github.com/coreos/go-oidc"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -37,55 +37,33 @@ func (m *MockValidator) EXPECT() *MockValidatorMockRecorder {
 }
 
 // GetValidatedClaims mocks base method.
-func (m *MockValidator) GetValidatedClaims(requestId, authorization, tenant string) (HriClaims, *response.ErrorDetailResponse) {
+func (m *MockValidator) GetValidatedClaims(requestId string, request *http.Request, tenant string) (HriClaims, *response.ErrorDetailResponse) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetValidatedClaims", requestId, authorization, tenant)
+	ret := m.ctrl.Call(m, "GetValidatedClaims", requestId, request, tenant)
 	ret0, _ := ret[0].(HriClaims)
 	ret1, _ := ret[1].(*response.ErrorDetailResponse)
 	return ret0, ret1
 }
 
 // GetValidatedClaims indicates an expected call of GetValidatedClaims.
-func (mr *MockValidatorMockRecorder) GetValidatedClaims(requestId, authorization, tenant interface{}) *gomock.Call {
+func (mr *MockValidatorMockRecorder) GetValidatedClaims(requestId, request, tenant interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetValidatedClaims", reflect.TypeOf((*MockValidator)(nil).GetValidatedClaims), requestId, authorization, tenant)
 }
 
-// MockoidcProvider is a mock of oidcProvider interface.
-type MockoidcProvider struct {
-	ctrl     *gomock.Controller
-	recorder *MockoidcProviderMockRecorder
-}
-
-// MockoidcProviderMockRecorder is the mock recorder for MockoidcProvider.
-type MockoidcProviderMockRecorder struct {
-	mock *MockoidcProvider
-}
-
-// NewMockoidcProvider creates a new mock instance.
-func NewMockoidcProvider(ctrl *gomock.Controller) *MockoidcProvider {
-	mock := &MockoidcProvider{ctrl: ctrl}
-	mock.recorder = &MockoidcProviderMockRecorder{mock}
-	return mock
-}
-
-// EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockoidcProvider) EXPECT() *MockoidcProviderMockRecorder {
-	return m.recorder
-}
-
-// Verifier mocks base method.
-func (m *MockoidcProvider) Verifier(config *go_oidc.Config) tokenVerifier {
+// GetValidatedAdminClaims mocks base method.
+func (m *MockValidator) GetValidatedAdminClaims(requestId string, request *http.Request) (HriClaims, *response.ErrorDetailResponse) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Verifier", config)
-	ret0, _ := ret[0].(tokenVerifier)
-	return ret0
+	ret := m.ctrl.Call(m, "GetValidatedAdminClaims", requestId, request)
+	ret0, _ := ret[0].(HriClaims)
+	ret1, _ := ret[1].(*response.ErrorDetailResponse)
+	return ret0, ret1
 }
 
-// Verifier indicates an expected call of Verifier.
-func (mr *MockoidcProviderMockRecorder) Verifier(config interface{}) *gomock.Call {
+// GetValidatedAdminClaims indicates an expected call of GetValidatedAdminClaims.
+func (mr *MockValidatorMockRecorder) GetValidatedAdminClaims(requestId, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verifier", reflect.TypeOf((*MockoidcProvider)(nil).Verifier), config)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetValidatedAdminClaims", reflect.TypeOf((*MockValidator)(nil).GetValidatedAdminClaims), requestId, authorization)
 }
 
 // MocktokenVerifier is a mock of tokenVerifier interface.
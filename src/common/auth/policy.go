@@ -0,0 +1,345 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Actions that policy rules authorize
+const (
+	ActionCreateBatch        = "batches:create"
+	ActionGetBatches         = "batches:get"
+	ActionGetBatch           = "batches:getById"
+	ActionSendComplete       = "batches:sendComplete"
+	ActionTerminate          = "batches:terminate"
+	ActionProcessingComplete = "batches:processingComplete"
+	ActionFail               = "batches:fail"
+	ActionSetLegalHold       = "legalholds:set"
+	ActionReleaseLegalHold   = "legalholds:release"
+)
+
+var knownActions = []string{
+	ActionCreateBatch, ActionGetBatches, ActionGetBatch, ActionSendComplete, ActionTerminate,
+	ActionProcessingComplete, ActionFail, ActionSetLegalHold, ActionReleaseLegalHold,
+}
+
+// the actions that apply the row filters of the rules that allow them
+var filteredActions = []string{ActionGetBatches, ActionGetBatch}
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// placeholders in rule values
+const (
+	subjectPlaceholder = "{sub}"
+	tenantPlaceholder  = "{tenant}"
+)
+
+// PolicyRule allows or denies actions to the tokens with one of its scopes. Rules without scopes apply to every token.
+type PolicyRule struct {
+	Name   string `yaml:"name"`
+	Effect string `yaml:"effect"` // allow or deny, defaults to allow
+	// actions such as batches:get, a trailing * matches any action with that prefix, e.g. batches:*
+	Actions []string `yaml:"actions"`
+	// a scope may contain {tenant}, which is replaced by the requested tenant's id
+	Scopes []string `yaml:"scopes"`
+	// the rule only applies to these tenants, all tenants when empty
+	Tenants []string `yaml:"tenants"`
+	// Row filter of allow rules: the batches with these attribute values, a value of {sub} is the token's subject.
+	// Only the read actions, batches:get and batches:getById, can be filtered; they only return the matching batches.
+	Batch map[string]string `yaml:"batch"`
+}
+
+// Policy decides which actions claims are authorized for. Deny rules take precedence over allow rules, and actions
+// no rule allows are denied.
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// DefaultPolicy is the HRI authorization model, which applies when no policy file is configured
+var DefaultPolicy = Policy{Rules: []PolicyRule{
+	{
+		Name:    "integrators",
+		Actions: []string{ActionCreateBatch, ActionSendComplete, ActionTerminate},
+		Scopes:  []string{HriIntegrator},
+	},
+	{
+		Name:    "integrators-read-own-batches",
+		Actions: []string{ActionGetBatches, ActionGetBatch},
+		Scopes:  []string{HriIntegrator},
+		Batch:   map[string]string{param.IntegratorId: subjectPlaceholder},
+	},
+	{
+		Name:    "consumers",
+		Actions: []string{ActionGetBatches, ActionGetBatch},
+		Scopes:  []string{HriConsumer},
+	},
+	{
+		Name:    "internal",
+		Actions: []string{ActionProcessingComplete, ActionFail},
+		Scopes:  []string{HriInternal},
+	},
+	{
+		Name:    "admins",
+		Actions: []string{"legalholds:*"},
+		Scopes:  []string{HriAdmin},
+	},
+}}
+
+var globalPolicy = DefaultPolicy
+
+// Decision is the outcome of authorizing an action
+type Decision struct {
+	Allowed bool
+	// a batch is visible when it has all the values of one of the filters, nil when every batch is visible
+	filters []map[string]string
+}
+
+// LoadPolicy reads and validates a YAML policy file
+func LoadPolicy(path string) (Policy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Policy{}, fmt.Errorf("unable to read the policy file: %w", err)
+	}
+
+	policy := Policy{}
+	if err := yaml.UnmarshalStrict(content, &policy); err != nil {
+		return Policy{}, fmt.Errorf("unable to parse the policy file %s: %w", path, err)
+	}
+	if err := policy.validate(); err != nil {
+		return Policy{}, err
+	}
+	return policy, nil
+}
+
+// InitializePolicy loads the policy file and makes it the policy used by the rest of the server. Without a file the
+// DefaultPolicy is used.
+func InitializePolicy(path string) error {
+	if path == "" {
+		globalPolicy = DefaultPolicy
+		return nil
+	}
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		return err
+	}
+	globalPolicy = policy
+	return nil
+}
+
+// GetPolicy returns the policy the server was initialized with
+func GetPolicy() Policy {
+	return globalPolicy
+}
+
+func (p Policy) validate() error {
+	errorHeader := "Policy errors:"
+	errorBuilder := strings.Builder{}
+	errorBuilder.WriteString(errorHeader)
+
+	if len(p.Rules) == 0 {
+		errorBuilder.WriteString("\n\tThe policy has no rules")
+	}
+	for i, rule := range p.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+		if rule.Effect != "" && rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tRule '%s' has an invalid effect '%s'; valid effects are: allow, deny", name, rule.Effect))
+		}
+		if len(rule.Actions) == 0 {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tRule '%s' has no actions", name))
+		}
+		for _, action := range rule.Actions {
+			if !strings.HasSuffix(action, "*") && !contains(knownActions, action) {
+				errorBuilder.WriteString(fmt.Sprintf("\n\tRule '%s' has an unknown action '%s'", name, action))
+			}
+		}
+		if rule.Effect == EffectDeny && len(rule.Batch) > 0 {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tRule '%s' denies actions, so it can't have a batch filter", name))
+		} else if len(rule.Batch) > 0 {
+			for _, action := range rule.Actions {
+				if !isFiltered(action) {
+					errorBuilder.WriteString(fmt.Sprintf("\n\tRule '%s' has a batch filter, which only applies to %s, but allows '%s'",
+						name, strings.Join(filteredActions, " and "), action))
+				}
+			}
+		}
+	}
+
+	errorMsg := errorBuilder.String()
+	if len(errorMsg) > len(errorHeader) {
+		return errors.New(errorMsg)
+	}
+	return nil
+}
+
+// Authorize decides whether the claims allow the action on the tenant's data, and which batches it applies to
+func (p Policy) Authorize(claims HriClaims, tenantId string, action string) Decision {
+	allowed := false
+	unfiltered := false
+	var filters []map[string]string
+	for _, rule := range p.Rules {
+		if !rule.applies(claims, tenantId, action) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return Decision{}
+		}
+
+		allowed = true
+		if len(rule.Batch) == 0 {
+			unfiltered = true
+			continue
+		}
+		filter := make(map[string]string, len(rule.Batch))
+		for attribute, value := range rule.Batch {
+			filter[attribute] = strings.ReplaceAll(value, subjectPlaceholder, claims.Subject)
+		}
+		filters = append(filters, filter)
+	}
+
+	if !allowed {
+		return Decision{}
+	}
+	if unfiltered {
+		return Decision{Allowed: true}
+	}
+	return Decision{Allowed: true, filters: filters}
+}
+
+// Authorize decides with the policy the server was initialized with
+func Authorize(claims HriClaims, tenantId string, action string) Decision {
+	return globalPolicy.Authorize(claims, tenantId, action)
+}
+
+// isFiltered returns whether every known action the action matches applies the row filters
+func isFiltered(action string) bool {
+	for _, known := range knownActions {
+		if matchesAny([]string{action}, known) && !contains(filteredActions, known) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r PolicyRule) applies(claims HriClaims, tenantId string, action string) bool {
+	if !matchesAny(r.Actions, action) {
+		return false
+	}
+	if len(r.Tenants) > 0 && !matchesAny(r.Tenants, tenantId) {
+		return false
+	}
+	if len(r.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range r.Scopes {
+		if claims.HasScope(strings.ReplaceAll(scope, tenantPlaceholder, tenantId)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filtered returns whether the decision only applies to the batches that pass its row filters
+func (d Decision) Filtered() bool {
+	return d.filters != nil
+}
+
+// Permits returns whether the batch passes the decision's row filters
+func (d Decision) Permits(batch map[string]interface{}) bool {
+	if !d.Allowed {
+		return false
+	}
+	if d.filters == nil {
+		return true
+	}
+	for _, filter := range d.filters {
+		if matchesFilter(batch, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryClauses returns the Elasticsearch clauses that limit a search to the batches that pass the decision's row
+// filters, nil when every batch is visible. All the clauses must match.
+func (d Decision) QueryClauses() []map[string]interface{} {
+	if d.filters == nil {
+		return nil
+	}
+
+	shouldClauses := make([]map[string]interface{}, 0, len(d.filters))
+	for _, filter := range d.filters {
+		shouldClauses = append(shouldClauses, filterClause(filter))
+	}
+	if len(shouldClauses) == 1 {
+		return shouldClauses[0]["bool"].(map[string]interface{})["must"].([]map[string]interface{})
+	}
+	return []map[string]interface{}{{
+		"bool": map[string]interface{}{
+			"should":               shouldClauses,
+			"minimum_should_match": 1,
+		},
+	}}
+}
+
+func filterClause(filter map[string]string) map[string]interface{} {
+	mustClauses := make([]map[string]interface{}, 0, len(filter))
+	for _, attribute := range sortedKeys(filter) {
+		mustClauses = append(mustClauses, map[string]interface{}{
+			"term": map[string]interface{}{attribute: filter[attribute]},
+		})
+	}
+	return map[string]interface{}{"bool": map[string]interface{}{"must": mustClauses}}
+}
+
+func matchesFilter(batch map[string]interface{}, filter map[string]string) bool {
+	for attribute, value := range filter {
+		actual, ok := batch[attribute]
+		if !ok || fmt.Sprint(actual) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == value || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,265 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `
+rules:
+  - name: auditors
+    actions: ["batches:get*"]
+    scopes: [hri_auditor]
+  - name: tenant-admins
+    actions: ["batches:*", "legalholds:*"]
+    scopes: ["tenant_admin_{tenant}"]
+  - name: integrators
+    actions: [batches:create, batches:sendComplete, batches:terminate]
+    scopes: [hri_data_integrator]
+  - name: integrators-read-own-batches
+    actions: [batches:get, batches:getById]
+    scopes: [hri_data_integrator]
+    batch:
+      integratorId: "{sub}"
+  - name: partner-reads-shared-batches
+    actions: [batches:get]
+    scopes: [hri_partner]
+    tenants: [tenant1]
+    batch:
+      dataType: shared
+  - name: no-terminate-for-tenant2
+    effect: deny
+    actions: [batches:terminate]
+    tenants: [tenant2]
+`
+
+func writePolicy(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		expErr  string
+	}{
+		{
+			name:    "valid",
+			content: testPolicy,
+		},
+		{
+			name:    "no rules",
+			content: "rules: []",
+			expErr:  "Policy errors:\n\tThe policy has no rules",
+		},
+		{
+			name: "invalid rules",
+			content: `
+rules:
+  - name: bad
+    effect: maybe
+    actions: [batches:delete]
+  - effect: deny
+    batch: {integratorId: "{sub}"}
+  - name: filtered-writes
+    actions: [batches:getById, batches:terminate, "batches:*"]
+    batch: {integratorId: "{sub}"}
+`,
+			expErr: "Policy errors:" +
+				"\n\tRule 'bad' has an invalid effect 'maybe'; valid effects are: allow, deny" +
+				"\n\tRule 'bad' has an unknown action 'batches:delete'" +
+				"\n\tRule '2' has no actions" +
+				"\n\tRule '2' denies actions, so it can't have a batch filter" +
+				"\n\tRule 'filtered-writes' has a batch filter, which only applies to batches:get and batches:getById, but allows 'batches:terminate'" +
+				"\n\tRule 'filtered-writes' has a batch filter, which only applies to batches:get and batches:getById, but allows 'batches:*'",
+		},
+		{
+			name:    "unknown field",
+			content: "rules:\n  - name: typo\n    action: [batches:get]\n",
+			expErr:  "unable to parse the policy file {path}: yaml: unmarshal errors:\n  line 3: field action not found in type auth.PolicyRule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePolicy(t, tt.content)
+			policy, err := LoadPolicy(path)
+			if tt.expErr == "" {
+				assert.NoError(t, err)
+				assert.Len(t, policy.Rules, 6)
+			} else {
+				assert.EqualError(t, err, strings.ReplaceAll(tt.expErr, "{path}", path))
+			}
+		})
+	}
+}
+
+func TestInitializePolicy(t *testing.T) {
+	defer func() { globalPolicy = DefaultPolicy }()
+
+	assert.NoError(t, InitializePolicy(writePolicy(t, testPolicy)))
+	assert.Len(t, GetPolicy().Rules, 6)
+	assert.True(t, Authorize(HriClaims{Scope: "hri_auditor"}, "tenant1", ActionGetBatch).Allowed)
+
+	assert.Error(t, InitializePolicy(writePolicy(t, "rules: []")))
+	assert.Len(t, GetPolicy().Rules, 6)
+
+	assert.NoError(t, InitializePolicy(""))
+	assert.Equal(t, DefaultPolicy, GetPolicy())
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	integrator := HriClaims{Scope: HriIntegrator, Subject: "integrator1"}
+	consumer := HriClaims{Scope: HriConsumer}
+	both := HriClaims{Scope: HriIntegrator + " " + HriConsumer, Subject: "integrator1"}
+	internal := HriClaims{Scope: HriInternal}
+	admin := HriClaims{Scope: HriAdmin}
+	none := HriClaims{Scope: "tenant_tenant1"}
+
+	tests := []struct {
+		claims   HriClaims
+		action   string
+		expected Decision
+	}{
+		{integrator, ActionCreateBatch, Decision{Allowed: true}},
+		{integrator, ActionSendComplete, Decision{Allowed: true}},
+		{integrator, ActionTerminate, Decision{Allowed: true}},
+		{integrator, ActionGetBatches, Decision{Allowed: true, filters: []map[string]string{{"integratorId": "integrator1"}}}},
+		{integrator, ActionProcessingComplete, Decision{}},
+		{consumer, ActionGetBatch, Decision{Allowed: true}},
+		{consumer, ActionCreateBatch, Decision{}},
+		{both, ActionGetBatches, Decision{Allowed: true}},
+		{internal, ActionFail, Decision{Allowed: true}},
+		{internal, ActionGetBatches, Decision{}},
+		{admin, ActionSetLegalHold, Decision{Allowed: true}},
+		{admin, ActionReleaseLegalHold, Decision{Allowed: true}},
+		{admin, ActionGetBatches, Decision{}},
+		{none, ActionGetBatches, Decision{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.claims.Scope+" "+tt.action, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DefaultPolicy.Authorize(tt.claims, "tenant1", tt.action))
+		})
+	}
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		claims   HriClaims
+		tenantId string
+		action   string
+		expected Decision
+	}{
+		{
+			name:     "auditor reads",
+			claims:   HriClaims{Scope: "hri_auditor"},
+			tenantId: "tenant1",
+			action:   ActionGetBatch,
+			expected: Decision{Allowed: true},
+		},
+		{
+			name:     "auditor can't write",
+			claims:   HriClaims{Scope: "hri_auditor"},
+			tenantId: "tenant1",
+			action:   ActionTerminate,
+			expected: Decision{},
+		},
+		{
+			name:     "tenant admin of the tenant",
+			claims:   HriClaims{Scope: "tenant_admin_tenant1"},
+			tenantId: "tenant1",
+			action:   ActionSetLegalHold,
+			expected: Decision{Allowed: true},
+		},
+		{
+			name:     "tenant admin of another tenant",
+			claims:   HriClaims{Scope: "tenant_admin_tenant1"},
+			tenantId: "tenant3",
+			action:   ActionSetLegalHold,
+			expected: Decision{},
+		},
+		{
+			name:     "deny takes precedence",
+			claims:   HriClaims{Scope: "tenant_admin_tenant2 " + HriIntegrator},
+			tenantId: "tenant2",
+			action:   ActionTerminate,
+			expected: Decision{},
+		},
+		{
+			name:     "filters are combined",
+			claims:   HriClaims{Scope: HriIntegrator + " hri_partner", Subject: "integrator1"},
+			tenantId: "tenant1",
+			action:   ActionGetBatches,
+			expected: Decision{Allowed: true, filters: []map[string]string{
+				{"integratorId": "integrator1"},
+				{"dataType": "shared"},
+			}},
+		},
+		{
+			name:     "rule of another tenant",
+			claims:   HriClaims{Scope: "hri_partner"},
+			tenantId: "tenant2",
+			action:   ActionGetBatches,
+			expected: Decision{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, policy.Authorize(tt.claims, tt.tenantId, tt.action))
+		})
+	}
+}
+
+func TestDecisionFilters(t *testing.T) {
+	unfiltered := Decision{Allowed: true}
+	assert.False(t, unfiltered.Filtered())
+	assert.Nil(t, unfiltered.QueryClauses())
+	assert.True(t, unfiltered.Permits(map[string]interface{}{}))
+	assert.False(t, Decision{}.Permits(map[string]interface{}{}))
+
+	single := Decision{Allowed: true, filters: []map[string]string{{"integratorId": "integrator1", "dataType": "rest"}}}
+	assert.True(t, single.Filtered())
+	assert.Equal(t, []map[string]interface{}{
+		{"term": map[string]interface{}{"dataType": "rest"}},
+		{"term": map[string]interface{}{"integratorId": "integrator1"}},
+	}, single.QueryClauses())
+	assert.True(t, single.Permits(map[string]interface{}{"integratorId": "integrator1", "dataType": "rest", "name": "a"}))
+	assert.False(t, single.Permits(map[string]interface{}{"integratorId": "integrator1"}))
+
+	multiple := Decision{Allowed: true, filters: []map[string]string{{"integratorId": "integrator1"}, {"recordCount": "5"}}}
+	assert.Equal(t, []map[string]interface{}{{
+		"bool": map[string]interface{}{
+			"should": []map[string]interface{}{
+				{"bool": map[string]interface{}{"must": []map[string]interface{}{
+					{"term": map[string]interface{}{"integratorId": "integrator1"}},
+				}}},
+				{"bool": map[string]interface{}{"must": []map[string]interface{}{
+					{"term": map[string]interface{}{"recordCount": "5"}},
+				}}},
+			},
+			"minimum_should_match": 1,
+		},
+	}}, multiple.QueryClauses())
+	assert.True(t, multiple.Permits(map[string]interface{}{"integratorId": "other", "recordCount": float64(5)}))
+	assert.False(t, multiple.Permits(map[string]interface{}{"integratorId": "other", "recordCount": float64(6)}))
+}
@@ -7,44 +7,44 @@ package auth
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/audit"
+	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/coreos/go-oidc"
 	"net/http"
 	"strings"
 )
 
-// Validator Public interface
+// Validator Public interface. The claims come from the request's bearer token, its API key, or its verified TLS client
+// certificate, in that order.
 type Validator interface {
-	GetValidatedClaims(requestId string, authorization string, tenant string) (HriClaims, *response.ErrorDetailResponse)
+	GetValidatedClaims(requestId string, request *http.Request, tenant string) (HriClaims, *response.ErrorDetailResponse)
+	// GetValidatedAdminClaims validates the claims of endpoints that aren't tenant specific, which require hri_admin
+	GetValidatedAdminClaims(requestId string, request *http.Request) (HriClaims, *response.ErrorDetailResponse)
 }
 
+const authorizationHeader = "Authorization"
+
 // struct that implements the Validator interface
 type theValidator struct {
-	issuer      string
-	audienceId  string
-	providerNew newOidcProvider // this enables unit tests to use a mocked oidcProvider
-}
-
-// Interfaces cannot be directly created for the auth Provider and IDTokenVerifier, because return types are not
-// inferred in Golang. These wrapper structs embed the originals to meet our interface definitions, which enables unit
-// testing.
-
-type newOidcProvider func(context.Context, string) (oidcProvider, error)
-
-type oidcProvider interface {
-	Verifier(config *oidc.Config) tokenVerifier
-}
-
-// Embeds an oidc.Provider and wraps the Verifier method with the correct return type to meet the oidcProvider interface
-type theOidcProvider struct {
-	*oidc.Provider
+	issuer     string
+	audienceId string
+	keys       *KeyCache
+	verifier   tokenVerifier  // this enables unit tests to use a mocked tokenVerifier
+	mapping    *claimMapping  // nil when the HRI scopes are in the `scope` claim
+	identities []CertIdentity // clients can't authenticate with a certificate when empty
+	// looks up API keys by id, returns nil if there is no such key. Found keys are cached for apiKeyCacheTTL.
+	getApiKey func(ctx context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError)
 }
 
-func (p theOidcProvider) Verifier(config *oidc.Config) tokenVerifier {
-	return theTokenVerifier{p.Provider.Verifier(config)}
-}
+// Interfaces cannot be directly created for the IDTokenVerifier, because return types are not inferred in Golang.
+// This wrapper struct embeds the original to meet our interface definition, which enables unit testing.
 
 type tokenVerifier interface {
 	Verify(ctx context.Context, rawIDToken string) (ClaimsHolder, error)
@@ -59,40 +59,59 @@ func (t theTokenVerifier) Verify(ctx context.Context, rawIDToken string) (Claims
 	return t.IDTokenVerifier.Verify(ctx, rawIDToken)
 }
 
-// Method to create our custom oidcProvider, which embeds an oidc.Provider
-func newProvider(ctx context.Context, issuer string) (oidcProvider, error) {
-	oidcProvider, err := oidc.NewProvider(ctx, issuer)
-	return theOidcProvider{oidcProvider}, err
+// the algorithms the issuer may sign tokens with
+var supportedSigningAlgs = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
 }
 
-// NewValidator Public default constructor
-func NewValidator(issuer string, audienceId string) Validator {
+// NewValidator Public default constructor. The issuer's signing keys are cached and shared by all the validators, see
+// StartKeyRefresh. When config.OidcKeyFile is set, tokens are validated offline with the keys in that file, and the
+// issuer is only compared with the token's `iss` claim. The certificate identities are the ones the server was
+// initialized with, see InitializeCertIdentities.
+func NewValidator(config configPkg.Config) Validator {
+	issuer, audienceId := config.OidcIssuer, config.JwtAudienceId
+	keys := getKeyCache(config)
+	// This verifies the `aud` claim equals the configured audienceId
+	verifier := oidc.NewVerifier(issuer, keys, &oidc.Config{
+		ClientID:             audienceId,
+		SupportedSigningAlgs: supportedSigningAlgs,
+	})
+	// the Elastic client doesn't connect until it's used, so it's created once and shared by the lookups
+	client, clientErr := elastic.ClientFromConfig(config)
+	apiKeys := newApiKeyCache(apiKeyCacheTTL, func(ctx context.Context, keyId string) (*model.ApiKey, *elastic.ResponseError) {
+		if clientErr != nil {
+			return nil, &elastic.ResponseError{ErrorObj: clientErr, Code: http.StatusInternalServerError}
+		}
+		return elastic.GetApiKey(ctx, keyId, client)
+	})
 	return theValidator{
-		issuer:      issuer,
-		audienceId:  audienceId,
-		providerNew: newProvider,
+		issuer:     issuer,
+		audienceId: audienceId,
+		keys:       keys,
+		verifier:   theTokenVerifier{verifier},
+		mapping:    newClaimMapping(config),
+		identities: globalCertIdentities,
+		getApiKey:  apiKeys.get,
 	}
 }
 
 // Ensures the request has a valid OAuth JWT OIDC compliant access token.
-func (v theValidator) getSignedToken(requestId string, authorization string) (ClaimsHolder, *response.ErrorDetailResponse) {
+func (v theValidator) getSignedToken(ctx context.Context, requestId string, authorization string) (ClaimsHolder, *response.ErrorDetailResponse) {
 	rawToken := strings.ReplaceAll(strings.ReplaceAll(authorization, "Bearer ", ""), "bearer ", "")
 
-	ctx := context.Background()
 	prefix := "auth/validate"
 	logger := logwrapper.GetMyLogger(requestId, prefix)
 
-	provider, err := v.providerNew(ctx, v.issuer)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to create OIDC provider: %s", err.Error())
+	// the keys are normally fetched at startup, this only calls the issuer if that failed
+	if err := v.keys.ensureKeys(ctx); err != nil {
+		msg := fmt.Sprintf("Failed to get the OIDC signing keys: %s", err.Error())
 		logger.Errorln(msg)
 		return nil, response.NewErrorDetailResponse(http.StatusInternalServerError, requestId, msg)
 	}
 
-	// This verifies the `aud` claim equals the configured audienceId
-	verifier := provider.Verifier(&oidc.Config{ClientID: v.audienceId})
-
-	token, err := verifier.Verify(ctx, rawToken)
+	token, err := v.verifier.Verify(ctx, rawToken)
 	if err != nil {
 		msg := fmt.Sprintf("Authorization token validation failed: %s", err.Error())
 		logger.Errorln(msg)
@@ -111,35 +130,131 @@ func (v theValidator) checkTenant(requestId string, tenantId string, claims HriC
 		// The authorized scopes do not include tenant data
 		msg := fmt.Sprintf("Unauthorized tenant access. Tenant '%s' is not included in the authorized scopes: %v.", tenantId, claims.Scope)
 		logger.Errorln(msg)
-		return response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, msg)
+		return response.NewErrorDetailResponse(http.StatusForbidden, requestId, msg)
 	}
 
 	// Tenant data included in authorized scopes
 	return nil
 }
 
-func (v theValidator) GetValidatedClaims(requestId string, authorization string, tenant string) (HriClaims, *response.ErrorDetailResponse) {
+func (v theValidator) checkAdmin(requestId string, claims HriClaims) *response.ErrorDetailResponse {
+	prefix := "auth/checkAdmin"
+	logger := logwrapper.GetMyLogger(requestId, prefix)
+
+	if !claims.HasScope(HriAdmin) {
+		msg := fmt.Sprintf("Unauthorized admin access. '%s' is not included in the authorized scopes: %v.", HriAdmin, claims.Scope)
+		logger.Errorln(msg)
+		return response.NewErrorDetailResponse(http.StatusForbidden, requestId, msg)
+	}
+	return nil
+}
+
+// Returns the claims of the client's verified TLS certificate
+func (v theValidator) getCertificateClaims(requestId string, cert *x509.Certificate) (HriClaims, *response.ErrorDetailResponse) {
+	prefix := "auth/getCertificateClaims"
+	logger := logwrapper.GetMyLogger(requestId, prefix)
+
+	identity := findCertIdentity(v.identities, cert)
+	if identity == nil {
+		msg := fmt.Sprintf("The client certificate '%s' isn't mapped to an HRI identity", cert.Subject.String())
+		logger.Errorln(msg)
+		return HriClaims{}, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, msg)
+	}
+	return identity.claims(cert), nil
+}
+
+// Returns the claims of the API key, which must exist and not be revoked
+func (v theValidator) getApiKeyClaims(ctx context.Context, requestId string, key string) (HriClaims, *response.ErrorDetailResponse) {
+	prefix := "auth/getApiKeyClaims"
+	logger := logwrapper.GetMyLogger(requestId, prefix)
+
+	keyId, ok := parseApiKey(key)
+	if !ok {
+		logger.Errorln(MsgInvalidApiKey)
+		return HriClaims{}, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, MsgInvalidApiKey)
+	}
+
+	apiKey, elasticErr := v.getApiKey(ctx, keyId)
+	if elasticErr != nil {
+		msg := fmt.Sprintf("Failed to look up the API key: %s", elasticErr.Error())
+		logger.Errorln(msg)
+		return HriClaims{}, response.NewErrorDetailResponse(http.StatusInternalServerError, requestId, msg)
+	}
+	if apiKey == nil || !matchesApiKey(key, *apiKey) {
+		logger.Errorln(MsgInvalidApiKey)
+		return HriClaims{}, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, MsgInvalidApiKey)
+	}
+	if apiKey.RevokedDate != "" {
+		msg := fmt.Sprintf(MsgApiKeyRevoked, keyId)
+		logger.Errorln(msg)
+		return HriClaims{}, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, msg)
+	}
+	return apiKeyClaims(*apiKey), nil
+}
+
+// verify that request has a signed OAuth JWT OIDC-compliant access token, and extract the HRI-related claims from it.
+// Requests without an Authorization header may authenticate with an API key or a client certificate instead.
+func (v theValidator) getClaims(requestId string, request *http.Request) (HriClaims, *response.ErrorDetailResponse) {
 	claims := HriClaims{}
 
-	prefix := "auth/getValidatedClaims"
+	prefix := "auth/getClaims"
 	logger := logwrapper.GetMyLogger(requestId, prefix)
 
-	// verify that request has a signed OAuth JWT OIDC-compliant access token
-	token, errResp := v.getSignedToken(requestId, authorization)
+	authorization := request.Header.Get(authorizationHeader)
+	if key := request.Header.Get(ApiKeyHeader); authorization == "" && key != "" {
+		return v.getApiKeyClaims(request.Context(), requestId, key)
+	}
+	if authorization == "" && len(v.identities) > 0 && request.TLS != nil && len(request.TLS.VerifiedChains) > 0 {
+		return v.getCertificateClaims(requestId, request.TLS.VerifiedChains[0][0])
+	}
+
+	token, errResp := v.getSignedToken(request.Context(), requestId, authorization)
 	if errResp != nil {
 		return claims, errResp
 	}
 
-	// extract HRI-related claims from JWT access token
 	if err := token.Claims(&claims); err != nil {
 		logger.Errorln(err.Error())
 		return claims, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, err.Error())
 	}
+	if v.mapping != nil {
+		rawClaims := map[string]interface{}{}
+		if err := token.Claims(&rawClaims); err != nil {
+			logger.Errorln(err.Error())
+			return HriClaims{}, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, err.Error())
+		}
+		claims.Scope = v.mapping.scope(rawClaims)
+	}
+	return claims, nil
+}
+
+func (v theValidator) GetValidatedClaims(requestId string, request *http.Request, tenant string) (HriClaims, *response.ErrorDetailResponse) {
+	claims, errResp := v.getClaims(requestId, request)
+	if errResp != nil {
+		return claims, errResp
+	}
+	audit.SetCaller(request.Context(), claims.Subject, claims.ClientId)
 
 	// verify that necessary tenant claim exists to access this endpoint's data
 	if errResp := v.checkTenant(requestId, tenant, claims); errResp != nil {
 		return claims, errResp
 	}
+	metrics.TenantAuthorized(request.Context())
+
+	return claims, nil
+}
+
+func (v theValidator) GetValidatedAdminClaims(requestId string, request *http.Request) (HriClaims, *response.ErrorDetailResponse) {
+	claims, errResp := v.getClaims(requestId, request)
+	if errResp != nil {
+		return claims, errResp
+	}
+	audit.SetCaller(request.Context(), claims.Subject, claims.ClientId)
+
+	if errResp := v.checkAdmin(requestId, claims); errResp != nil {
+		return claims, errResp
+	}
+	metrics.TenantAuthorized(request.Context())
 
 	return claims, nil
 }
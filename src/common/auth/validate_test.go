@@ -10,11 +10,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
-	"github.com/coreos/go-oidc"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
@@ -52,13 +54,9 @@ func /*Test*/ OidcLib(t *testing.T) {
 		t.Fatalf("Error decoding AppID token response: %v", err)
 	}
 
-	validator := theValidator{
-		issuer:      iss,
-		audienceId:  audienceId,
-		providerNew: newProvider,
-	}
+	validator := NewValidator(config.Config{OidcIssuer: iss, JwtAudienceId: audienceId}).(theValidator)
 
-	_, errResp := validator.getSignedToken(requestId, body["access_token"].(string))
+	_, errResp := validator.getSignedToken(context.Background(), requestId, body["access_token"].(string))
 
 	if errResp != nil {
 		t.Fatalf("Error: %v", errResp)
@@ -84,37 +82,48 @@ const (
 	audienceId    = "audienceId"
 )
 
+// newFakeKeyCache returns a KeyCache whose fetch returns one key, or fetchErr
+func newFakeKeyCache(fetchErr error) *KeyCache {
+	return &KeyCache{
+		issuer: issuer,
+		fetch: func(_ context.Context, _ string) ([]jose.JSONWebKey, error) {
+			if fetchErr != nil {
+				return nil, fetchErr
+			}
+			return []jose.JSONWebKey{{KeyID: "key1"}}, nil
+		},
+	}
+}
+
+// authorizedRequest returns a request with the Authorization header
+func authorizedRequest(authorization string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/hri/tenants", nil)
+	request.Header.Set(authorizationHeader, authorization)
+	return request
+}
+
 var hriClaims = HriClaims{Scope: "tenant_" + tenantId, Subject: "subject", Audience: []string{audienceId}}
 
 func TestGetSignedTokenHappyPath(t *testing.T) {
 	// create the mocks
 	controller := gomock.NewController(t)
 	defer controller.Finish()
-	mockProvider := NewMockoidcProvider(controller)
 	mocktokenVerifier := NewMocktokenVerifier(controller)
 
 	// define expected calls
-	gomock.InOrder(
-		mockProvider.
-			EXPECT().
-			Verifier(&oidc.Config{ClientID: audienceId}).
-			Return(mocktokenVerifier),
-		mocktokenVerifier.
-			EXPECT().
-			Verify(gomock.Any(), token).
-			Return(fakeClaimsHolder{claims: hriClaims, err: nil}, nil),
-	)
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(fakeClaimsHolder{claims: hriClaims, err: nil}, nil)
 
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			assert.Equal(t, issuer, s)
-			return mockProvider, nil
-		},
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
 	}
 
-	actClaimsHolder, errResp := validator.getSignedToken(requestId, authorization)
+	actClaimsHolder, errResp := validator.getSignedToken(context.Background(), requestId, authorization)
 	actClaims := HriClaims{}
 	actClaimsHolder.Claims(&actClaims)
 
@@ -122,18 +131,16 @@ func TestGetSignedTokenHappyPath(t *testing.T) {
 	assert.Equal(t, hriClaims, actClaims)
 }
 
-func TestGetSignedTokenNewProviderError(t *testing.T) {
+func TestGetSignedTokenNoKeys(t *testing.T) {
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			return nil, errors.New("Bad issuer url")
-		},
+		keys:       newFakeKeyCache(errors.New("Bad issuer url")),
 	}
 
-	expErr := response.NewErrorDetailResponse(http.StatusInternalServerError, requestId, "Failed to create OIDC provider: Bad issuer url")
+	expErr := response.NewErrorDetailResponse(http.StatusInternalServerError, requestId, "Failed to get the OIDC signing keys: Bad issuer url")
 
-	_, err := validator.getSignedToken(requestId, authorization)
+	_, err := validator.getSignedToken(context.Background(), requestId, authorization)
 
 	if err == nil || !reflect.DeepEqual(*err, *expErr) {
 		t.Fatalf("Unexpected error, expected:\n%v -> %v \nactual:\n%v -> %v", *expErr, *expErr.Body, *err, *err.Body)
@@ -144,33 +151,24 @@ func TestGetSignedTokenBadToken(t *testing.T) {
 	// create the mocks
 	controller := gomock.NewController(t)
 	defer controller.Finish()
-	mockProvider := NewMockoidcProvider(controller)
 	mocktokenVerifier := NewMocktokenVerifier(controller)
 
 	// define expected calls
-	gomock.InOrder(
-		mockProvider.
-			EXPECT().
-			Verifier(&oidc.Config{ClientID: audienceId}).
-			Return(mocktokenVerifier),
-		mocktokenVerifier.
-			EXPECT().
-			Verify(gomock.Any(), token).
-			Return(nil, errors.New("oidc: malformed jwt: square/go-jose: compact JWS format must have three parts")),
-	)
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(nil, errors.New("oidc: malformed jwt: square/go-jose: compact JWS format must have three parts"))
 
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			assert.Equal(t, issuer, s)
-			return mockProvider, nil
-		},
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
 	}
 
 	expErrResp := response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "Authorization token validation failed: oidc: malformed jwt: square/go-jose: compact JWS format must have three parts")
 
-	_, errResp := validator.getSignedToken(requestId, authorization)
+	_, errResp := validator.getSignedToken(context.Background(), requestId, authorization)
 
 	assert.Equal(t, *expErrResp, *errResp)
 }
@@ -193,11 +191,11 @@ func TestCheckTenant(t *testing.T) {
 			name:       "Unauthorized Tenant",
 			tenant:     authorizedTenant,
 			claims:     HriClaims{Scope: "unauthorizedTenant"},
-			statusCode: http.StatusUnauthorized,
+			statusCode: http.StatusForbidden,
 		},
 	}
 
-	validator := NewValidator("https://issuer", "audienceId").(theValidator)
+	validator := NewValidator(config.Config{OidcIssuer: "https://issuer", JwtAudienceId: "audienceId"}).(theValidator)
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -216,31 +214,22 @@ func TestGetValidatedClaimsHappyPath(t *testing.T) {
 	// create the mocks
 	controller := gomock.NewController(t)
 	defer controller.Finish()
-	mockProvider := NewMockoidcProvider(controller)
 	mocktokenVerifier := NewMocktokenVerifier(controller)
 
 	// define expected calls
-	gomock.InOrder(
-		mockProvider.
-			EXPECT().
-			Verifier(&oidc.Config{ClientID: audienceId}).
-			Return(mocktokenVerifier),
-		mocktokenVerifier.
-			EXPECT().
-			Verify(gomock.Any(), token).
-			Return(fakeClaimsHolder{claims: hriClaims, err: nil}, nil),
-	)
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(fakeClaimsHolder{claims: hriClaims, err: nil}, nil)
 
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			assert.Equal(t, issuer, s)
-			return mockProvider, nil
-		},
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
 	}
 
-	claims, err := validator.GetValidatedClaims(requestId, authorization, tenantId)
+	claims, err := validator.GetValidatedClaims(requestId, authorizedRequest(authorization), tenantId)
 
 	assert.Nil(t, err)
 	assert.Equal(t, hriClaims, claims)
@@ -250,31 +239,22 @@ func TestGetValidatedClaimsTokenError(t *testing.T) {
 	// create the mocks
 	controller := gomock.NewController(t)
 	defer controller.Finish()
-	mockProvider := NewMockoidcProvider(controller)
 	mocktokenVerifier := NewMocktokenVerifier(controller)
 
 	// define expected calls
-	gomock.InOrder(
-		mockProvider.
-			EXPECT().
-			Verifier(&oidc.Config{ClientID: audienceId}).
-			Return(mocktokenVerifier),
-		mocktokenVerifier.
-			EXPECT().
-			Verify(gomock.Any(), token).
-			Return(nil, errors.New("oidc: malformed jwt: square/go-jose: compact JWS format must have three parts")),
-	)
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(nil, errors.New("oidc: malformed jwt: square/go-jose: compact JWS format must have three parts"))
 
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			assert.Equal(t, issuer, s)
-			return mockProvider, nil
-		},
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
 	}
 
-	claims, err := validator.GetValidatedClaims(requestId, authorization, tenantId)
+	claims, err := validator.GetValidatedClaims(requestId, authorizedRequest(authorization), tenantId)
 
 	// we expect to get back an empty set of claims and a bad token error
 	expClaims := HriClaims{}
@@ -290,33 +270,24 @@ func TestGetValidatedClaimsExtractionError(t *testing.T) {
 	// create the mocks
 	controller := gomock.NewController(t)
 	defer controller.Finish()
-	mockProvider := NewMockoidcProvider(controller)
 	mocktokenVerifier := NewMocktokenVerifier(controller)
 
 	badClaimsHolderErr := "bad claims holder"
 
 	// define expected calls
-	gomock.InOrder(
-		mockProvider.
-			EXPECT().
-			Verifier(&oidc.Config{ClientID: audienceId}).
-			Return(mocktokenVerifier),
-		mocktokenVerifier.
-			EXPECT().
-			Verify(gomock.Any(), token).
-			Return(fakeClaimsHolder{claims: HriClaims{}, err: errors.New(badClaimsHolderErr)}, nil),
-	)
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(fakeClaimsHolder{claims: HriClaims{}, err: errors.New(badClaimsHolderErr)}, nil)
 
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			assert.Equal(t, issuer, s)
-			return mockProvider, nil
-		},
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
 	}
 
-	claims, err := validator.GetValidatedClaims(requestId, authorization, tenantId)
+	claims, err := validator.GetValidatedClaims(requestId, authorizedRequest(authorization), tenantId)
 
 	// we expect to get back an empty set of claims and a bad claims error
 	expClaims := HriClaims{}
@@ -332,36 +303,81 @@ func TestGetValidatedClaimsTenantError(t *testing.T) {
 	// create the mocks
 	controller := gomock.NewController(t)
 	defer controller.Finish()
-	mockProvider := NewMockoidcProvider(controller)
 	mocktokenVerifier := NewMocktokenVerifier(controller)
 
 	// define expected calls
-	gomock.InOrder(
-		mockProvider.
-			EXPECT().
-			Verifier(&oidc.Config{ClientID: audienceId}).
-			Return(mocktokenVerifier),
-		mocktokenVerifier.
-			EXPECT().
-			Verify(gomock.Any(), token).
-			Return(fakeClaimsHolder{claims: hriClaims, err: nil}, nil),
-	)
+	mocktokenVerifier.
+		EXPECT().
+		Verify(gomock.Any(), token).
+		Return(fakeClaimsHolder{claims: hriClaims, err: nil}, nil)
 
 	validator := theValidator{
 		issuer:     issuer,
 		audienceId: audienceId,
-		providerNew: func(_ context.Context, s string) (oidcProvider, error) {
-			assert.Equal(t, issuer, s)
-			return mockProvider, nil
-		},
+		keys:       newFakeKeyCache(nil),
+		verifier:   mocktokenVerifier,
 	}
 
-	claims, err := validator.GetValidatedClaims(requestId, authorization, "wrongTenantId")
+	claims, err := validator.GetValidatedClaims(requestId, authorizedRequest(authorization), "wrongTenantId")
 
-	expErrResp := response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, "Unauthorized tenant access. Tenant 'wrongTenantId' is not included in the authorized scopes: tenant_tenantId.")
+	expErrResp := response.NewErrorDetailResponse(http.StatusForbidden, requestId, "Unauthorized tenant access. Tenant 'wrongTenantId' is not included in the authorized scopes: tenant_tenantId.")
 
 	if !reflect.DeepEqual(err, expErrResp) {
 		t.Fatalf("Unexpected err response.\nexpected: %v -> %v \nactual  : %v -> %v ", *expErrResp, *expErrResp.Body, *err, *err.Body)
 	}
 	assert.Equal(t, hriClaims, claims)
 }
+
+func TestGetValidatedAdminClaims(t *testing.T) {
+	adminClaims := HriClaims{Scope: HriAdmin, Subject: "admin", Audience: []string{audienceId}}
+
+	tests := []struct {
+		name       string
+		claims     HriClaims
+		verifyErr  error
+		expErrResp *response.ErrorDetailResponse
+	}{
+		{
+			name:   "admin",
+			claims: adminClaims,
+		},
+		{
+			name:   "not an admin",
+			claims: hriClaims,
+			expErrResp: response.NewErrorDetailResponse(http.StatusForbidden, requestId,
+				"Unauthorized admin access. 'hri_admin' is not included in the authorized scopes: tenant_tenantId."),
+		},
+		{
+			name:      "invalid token",
+			verifyErr: errors.New("token expired"),
+			expErrResp: response.NewErrorDetailResponse(http.StatusUnauthorized, requestId,
+				"Authorization token validation failed: token expired"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mocktokenVerifier := NewMocktokenVerifier(controller)
+			if tt.verifyErr != nil {
+				mocktokenVerifier.EXPECT().Verify(gomock.Any(), token).Return(nil, tt.verifyErr)
+			} else {
+				mocktokenVerifier.EXPECT().Verify(gomock.Any(), token).Return(fakeClaimsHolder{claims: tt.claims}, nil)
+			}
+
+			validator := theValidator{
+				issuer:     issuer,
+				audienceId: audienceId,
+				keys:       newFakeKeyCache(nil),
+				verifier:   mocktokenVerifier,
+			}
+
+			claims, errResp := validator.GetValidatedAdminClaims(requestId, authorizedRequest(authorization))
+			assert.Equal(t, tt.expErrResp, errResp)
+			if tt.verifyErr == nil {
+				assert.Equal(t, tt.claims, claims)
+			}
+		})
+	}
+}
@@ -18,9 +18,27 @@ import (
 
 // Config Final config struct returned to be passed around
 type Config struct {
-	ConfigPath         string
-	OidcIssuer         string
-	JwtAudienceId      string
+	ConfigPath string
+	// Address the server listens on, all interfaces when the host is empty
+	Host string
+	Port int
+	// On SIGTERM, the server waits this long for the requests in progress before shutting down
+	ShutdownTimeoutSecs int
+	OidcIssuer          string
+	JwtAudienceId       string
+	// JWKS or PEM file with the keys tokens are signed with, for validating tokens without a reachable OIDC issuer
+	OidcKeyFile string
+	// Claims that hold the HRI scopes, and how their values map to HRI scopes, for identity providers that don't issue
+	// the scopes in the `scope` claim
+	ClaimScopePaths    StringSlice
+	ClaimValueMappings StringMap
+	ClaimTenantPrefix  string
+	// Use the claim values that aren't mapped as HRI scopes as they are
+	ClaimPassthrough bool
+	// YAML authorization policy, the built-in HRI roles apply when it isn't set
+	PolicyFile string
+	// How often to refresh the OIDC issuer's signing keys, 0 to only refresh them when a token uses an unknown key
+	OidcKeyRefreshSecs int
 	Validation         bool
 	AuthDisabled       bool
 	ElasticUrl         string
@@ -28,18 +46,106 @@ type Config struct {
 	ElasticPassword    string
 	ElasticCert        string
 	ElasticServiceCrn  string
+	// How the tenants and streams endpoints are authorized, 'iam' or 'jwt'
+	AdminAuthMode      string
 	KafkaAdminUrl      string // required for IBM Event Streams to manage topics
+	KafkaAdminApiKey   string // the Kafka administration API is called with this key instead of the caller's token in jwt mode
 	KafkaBrokers       StringSlice
 	KafkaProperties    StringMap // valid properties: https://github.com/edenhill/librdkafka/blob/master/CONFIGURATION.md
 	LogLevel           string
+	LogFormat          string // 'text' or 'json'
+	LogOutput          string // where the logs are written, 'stdout', 'file' or 'syslog'
+	LogFile            string
+	LogSyslogAddress   string // e.g. udp://localhost:514, the local syslog daemon when empty
 	NewRelicEnabled    bool
 	NewRelicAppName    string
 	NewRelicLicenseKey string
 	TlsEnabled         bool
 	TlsCertPath        string
 	TlsKeyPath         string
+	// Whether clients authenticate with a certificate signed by one of the CAs in TlsClientCaPath: 'none', 'optional'
+	// or 'required'. The certificates are mapped to HRI identities by CertIdentityFile.
+	TlsClientAuth     string
+	TlsClientCaPath   string
+	CertIdentityFile  string
+	TopicNameTemplate string
+	TopicTypeNames    StringMap
+	// How often to terminate batches that exceeded their tenant's batch timeout, 0 to disable
+	BatchTimeoutCheckSecs   int
+	TenantNotificationTopic string // receives a message whenever a tenant is suspended or resumed
+	// Batches in a terminal state are archived and removed after this many days, 0 to keep them. Tenants can override it
+	BatchRetentionDays int
+	RetentionCheckSecs int
+	ArchiveStore       string // where archived batches are written, 'local' or 's3'
+	ArchiveDir         string
+	ArchiveS3Endpoint  string
+	ArchiveS3Region    string
+	ArchiveS3Bucket    string
+	ArchiveS3AccessKey string
+	ArchiveS3SecretKey string
+	// Every request is recorded in a hash chained audit log, in this file and/or Kafka topic. Disabled when neither is set
+	AuditLogFile    string
+	AuditKafkaTopic string
+	AuditHmacKey    string // secret key of the HMACs that chain the audit log entries, required when it's enabled
+	// Seconds the healthcheck results are cached, so frequent probes don't overload the backends
+	HealthcheckCacheSecs int
+	SchemaRegistryUrl    string // reported by the verbose healthcheck when set
+	// Validate the requests and responses against the OpenAPI document, for development and test environments
+	OpenApiValidation bool
+	// Port of the admin server the Prometheus metrics are served on, 0 to serve them on the API's port
+	MetricsPort int
+	// Token bucket rate limits of creating and searching batches, per client and per tenant, 0 to not limit them.
+	// Tenants can override their limit with their quota's requestsPerSecond and requestBurst.
+	RateLimitClientRps   float64
+	RateLimitClientBurst int
+	RateLimitTenantRps   float64
+	RateLimitTenantBurst int
+	// Largest tenant import body accepted, in megabytes, 0 to not limit it
+	ImportMaxMegabytes int
+	// Where the OpenTelemetry spans are exported, 'none', 'stdout' or 'otlp'
+	TracingExporter     string
+	TracingOtlpEndpoint string // OTLP/HTTP endpoint of the collector, e.g. http://localhost:4318
 }
 
+const (
+	// AdminAuthModeIam passes the caller's IBM Cloud IAM token to the Resource Controller and Event Streams
+	AdminAuthModeIam = "iam"
+	// AdminAuthModeJwt requires an HRI token with the hri_admin scope
+	AdminAuthModeJwt = "jwt"
+)
+
+const (
+	TlsClientAuthNone     = "none"
+	TlsClientAuthOptional = "optional"
+	TlsClientAuthRequired = "required"
+)
+
+const (
+	ArchiveStoreLocal = "local"
+	ArchiveStoreS3    = "s3"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJson = "json"
+)
+
+const (
+	LogOutputStdout = "stdout"
+	LogOutputFile   = "file"
+	LogOutputSyslog = "syslog"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOtlp   = "otlp"
+)
+
+// DefaultTopicNameTemplate is the HRI topic naming convention. It's defined here rather than with the other naming
+// placeholders in eventstreams, since that package imports this one.
+const DefaultTopicNameTemplate = "ingest.{tenantId}.{streamId}.{topicType}"
+
 // StringSlice is a flag.Value that collects each Set string into a slice, allowing for repeated flags.
 type StringSlice []string
 
@@ -80,6 +186,12 @@ func (sm *StringMap) String() string {
 	return fmt.Sprint(*sm)
 }
 
+// ValidatesTokens is true when the server validates HRI tokens, i.e. unless auth is disabled, and in the jwt admin auth
+// mode even then, because auth-disabled only applies to the batch endpoints
+func (config Config) ValidatesTokens() bool {
+	return !config.AuthDisabled || config.AdminAuthMode == AdminAuthModeJwt
+}
+
 // ValidateConfig Perform verification on the finalized config.  Return an error if validation failed.
 func ValidateConfig(config Config) error {
 	if len(config.ConfigPath) == 0 {
@@ -90,10 +202,15 @@ func ValidateConfig(config Config) error {
 	errorHeader := "Configuration errors:"
 	errorBuilder.WriteString(errorHeader)
 
-	// Make sure OidcIssuer in the form of a valid URL
-	if !config.AuthDisabled && !isValidUrl(config.OidcIssuer) {
+	// Make sure OidcIssuer in the form of a valid URL. With a key file the issuer isn't called, so it only has to match
+	// the tokens' issuer claim.
+	validatesTokens := config.ValidatesTokens()
+	if validatesTokens && config.OidcKeyFile == "" && !isValidUrl(config.OidcIssuer) {
 		errorBuilder.WriteString("\n\tOIDC Issuer is an invalid URL:  " + config.OidcIssuer)
 	}
+	if validatesTokens && config.OidcKeyFile != "" && config.OidcIssuer == "" {
+		errorBuilder.WriteString("\n\tAn OIDC key file was specified, but the OIDC issuer was not")
+	}
 	if config.ElasticUrl == "" {
 		errorBuilder.WriteString("\n\tAn Elasticsearch base URL was not specified")
 	}
@@ -112,8 +229,18 @@ func ValidateConfig(config Config) error {
 			errorBuilder.WriteString("\n\tThe Elasticsearch certificate is invalid")
 		}
 	}
-	if config.ElasticServiceCrn == "" {
-		errorBuilder.WriteString("\n\tAn Elasticsearch service CRN was not specified")
+	switch config.AdminAuthMode {
+	case "", AdminAuthModeIam:
+		if config.ElasticServiceCrn == "" {
+			errorBuilder.WriteString("\n\tAn Elasticsearch service CRN was not specified")
+		}
+	case AdminAuthModeJwt:
+		if config.KafkaAdminApiKey == "" {
+			errorBuilder.WriteString("\n\tThe admin auth mode is 'jwt' but a Kafka administration API key was not specified")
+		}
+	default:
+		errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown admin auth mode '%s', must be '%s' or '%s'",
+			config.AdminAuthMode, AdminAuthModeIam, AdminAuthModeJwt))
 	}
 	if config.KafkaAdminUrl == "" {
 		errorBuilder.WriteString("\n\tThe Kafka administration url was not specified")
@@ -127,6 +254,103 @@ func ValidateConfig(config Config) error {
 	if config.NewRelicEnabled && config.NewRelicLicenseKey == "" {
 		errorBuilder.WriteString("\n\tNew Relic monitoring enabled, but the New Relic license key was not specified")
 	}
+	if config.OidcKeyRefreshSecs < 0 {
+		errorBuilder.WriteString("\n\tThe OIDC key refresh interval can't be negative")
+	}
+	if config.BatchTimeoutCheckSecs < 0 {
+		errorBuilder.WriteString("\n\tThe batch timeout check interval can't be negative")
+	}
+	if config.BatchRetentionDays < 0 {
+		errorBuilder.WriteString("\n\tThe batch retention days can't be negative")
+	}
+	if config.RetentionCheckSecs < 0 {
+		errorBuilder.WriteString("\n\tThe retention check interval can't be negative")
+	}
+	if config.HealthcheckCacheSecs < 0 {
+		errorBuilder.WriteString("\n\tThe healthcheck cache interval can't be negative")
+	}
+	if config.SchemaRegistryUrl != "" && !isValidUrl(config.SchemaRegistryUrl) {
+		errorBuilder.WriteString("\n\tThe schema registry URL is an invalid URL:  " + config.SchemaRegistryUrl)
+	}
+	if config.RateLimitClientRps < 0 || config.RateLimitTenantRps < 0 {
+		errorBuilder.WriteString("\n\tThe rate limits can't be negative")
+	}
+	if config.RateLimitClientRps > 0 && config.RateLimitClientBurst < 1 {
+		errorBuilder.WriteString("\n\tThe client rate limit is enabled but its burst is less than 1")
+	}
+	if config.RateLimitTenantRps > 0 && config.RateLimitTenantBurst < 1 {
+		errorBuilder.WriteString("\n\tThe tenant rate limit is enabled but its burst is less than 1")
+	}
+	if config.Port < 0 || config.Port > 65535 {
+		errorBuilder.WriteString(fmt.Sprintf("\n\tThe port %d is invalid, must be between 0 and 65535", config.Port))
+	}
+	if config.ImportMaxMegabytes < 0 {
+		errorBuilder.WriteString("\n\tThe import size limit can't be negative")
+	}
+	if config.ShutdownTimeoutSecs < 0 {
+		errorBuilder.WriteString("\n\tThe shutdown timeout can't be negative")
+	}
+	if config.MetricsPort < 0 || config.MetricsPort > 65535 {
+		errorBuilder.WriteString(fmt.Sprintf("\n\tThe metrics port %d is invalid, must be between 0 and 65535", config.MetricsPort))
+	} else if config.MetricsPort != 0 && config.MetricsPort == config.Port {
+		errorBuilder.WriteString(fmt.Sprintf("\n\tThe metrics port %d must differ from the server's port", config.MetricsPort))
+	}
+	switch config.ArchiveStore {
+	case "":
+		if config.BatchRetentionDays > 0 {
+			errorBuilder.WriteString("\n\tBatch retention is enabled but an archive store was not specified")
+		}
+	case ArchiveStoreLocal:
+		if config.ArchiveDir == "" {
+			errorBuilder.WriteString("\n\tThe local archive store is enabled but an archive directory was not specified")
+		}
+	case ArchiveStoreS3:
+		if !isValidUrl(config.ArchiveS3Endpoint) {
+			errorBuilder.WriteString("\n\tThe S3 archive store endpoint is an invalid URL:  " + config.ArchiveS3Endpoint)
+		}
+		if config.ArchiveS3Bucket == "" {
+			errorBuilder.WriteString("\n\tThe S3 archive store is enabled but a bucket was not specified")
+		}
+		if config.ArchiveS3AccessKey == "" || config.ArchiveS3SecretKey == "" {
+			errorBuilder.WriteString("\n\tThe S3 archive store is enabled but the access key or secret key was not specified")
+		}
+	default:
+		errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown archive store '%s', must be '%s' or '%s'",
+			config.ArchiveStore, ArchiveStoreLocal, ArchiveStoreS3))
+	}
+	if (config.AuditLogFile != "" || config.AuditKafkaTopic != "") && config.AuditHmacKey == "" {
+		errorBuilder.WriteString("\n\tThe audit log is enabled but an HMAC key was not specified")
+	}
+	switch config.LogFormat {
+	case "", LogFormatText, LogFormatJson:
+	default:
+		errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown log format '%s', must be '%s' or '%s'",
+			config.LogFormat, LogFormatText, LogFormatJson))
+	}
+	switch config.LogOutput {
+	case "", LogOutputStdout:
+	case LogOutputFile:
+		if config.LogFile == "" {
+			errorBuilder.WriteString("\n\tThe log output is a file but a log file was not specified")
+		}
+	case LogOutputSyslog:
+		if config.LogSyslogAddress != "" && !isValidUrl(config.LogSyslogAddress) {
+			errorBuilder.WriteString("\n\tThe syslog address is an invalid URL:  " + config.LogSyslogAddress)
+		}
+	default:
+		errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown log output '%s', must be '%s', '%s' or '%s'",
+			config.LogOutput, LogOutputStdout, LogOutputFile, LogOutputSyslog))
+	}
+	switch config.TracingExporter {
+	case "", TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOtlp:
+		if !isValidUrl(config.TracingOtlpEndpoint) {
+			errorBuilder.WriteString("\n\tThe OTLP trace endpoint is an invalid URL:  " + config.TracingOtlpEndpoint)
+		}
+	default:
+		errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown trace exporter '%s', must be '%s', '%s' or '%s'",
+			config.TracingExporter, TracingExporterNone, TracingExporterStdout, TracingExporterOtlp))
+	}
 	if config.TlsEnabled {
 		if config.TlsCertPath == "" {
 			errorBuilder.WriteString("\n\tTLS is enabled but a path to a TLS certificate for the server was not specified")
@@ -135,6 +359,22 @@ func ValidateConfig(config Config) error {
 			errorBuilder.WriteString("\n\tTLS is enabled but a path to a TLS key for the server was not specified")
 		}
 	}
+	switch config.TlsClientAuth {
+	case "", TlsClientAuthNone:
+		if config.CertIdentityFile != "" {
+			errorBuilder.WriteString("\n\tA certificate identity file was specified, but TLS client authentication is disabled")
+		}
+	case TlsClientAuthOptional, TlsClientAuthRequired:
+		if !config.TlsEnabled {
+			errorBuilder.WriteString("\n\tTLS client authentication is enabled but TLS is not")
+		}
+		if config.TlsClientCaPath == "" {
+			errorBuilder.WriteString("\n\tTLS client authentication is enabled but a path to the client CA certificates was not specified")
+		}
+	default:
+		errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown TLS client authentication '%s', must be '%s', '%s' or '%s'",
+			config.TlsClientAuth, TlsClientAuthNone, TlsClientAuthOptional, TlsClientAuthRequired))
+	}
 
 	errorMsg := errorBuilder.String()
 	if len(errorMsg) > len(errorHeader) {
@@ -154,8 +394,18 @@ func GetConfig(configPath string, commandLineFlags []string) (Config, error) {
 	// In runtime, flag names are capitalized, and separator characters are converted to underscores.
 	config := Config{}
 	fs.StringVar(&config.ConfigPath, "config-path", configPath, "(Optional) Path of an alternate config file")
-	fs.BoolVar(&config.AuthDisabled, "auth-disabled", false, "(Optional) True to disable Authorization using OAuth")
+	fs.BoolVar(&config.AuthDisabled, "auth-disabled", false, "(Optional) True to disable Authorization using OAuth on the batch endpoints. The tenant and stream endpoints are always authorized")
+	fs.StringVar(&config.Host, "host", "", "(Optional) Host or IP address the server listens on, all interfaces when empty")
+	fs.IntVar(&config.Port, "port", 1323, "(Optional) Port the server listens on")
+	fs.IntVar(&config.ShutdownTimeoutSecs, "shutdown-timeout", 30, "(Optional) Seconds the server waits for the requests in progress to finish when it's stopped, before shutting down")
 	fs.StringVar(&config.OidcIssuer, "oidc-issuer", "", "(Optional) The base URL of the OIDC issuer to use for OAuth authentication (e.g. https://us-south.appid.cloud.ibm.com/oauth/v4/<tenantId>)")
+	fs.StringVar(&config.OidcKeyFile, "oidc-key-file", "", "(Optional) Path of a JWKS file, or a file of PEM encoded public keys or certificates, to validate tokens with instead of the OIDC issuer's keys. The tokens' issuer still has to match oidc-issuer")
+	fs.IntVar(&config.OidcKeyRefreshSecs, "oidc-key-refresh-interval", 3600, "(Optional) Seconds between refreshes of the OIDC issuer's signing keys, 0 to only refresh them when a token is signed with an unknown key")
+	fs.Var(&config.ClaimScopePaths, "claim-scope-paths", "(Optional) Dot separated paths of the token claims that hold the HRI scopes or roles, separated by \",\" (e.g. realm_access.roles,groups). Claims can be space delimited strings or arrays. Defaults to scope")
+	fs.Var(&config.ClaimValueMappings, "claim-value-mappings", "(Optional) HRI scopes of claim values, entries separated by \",\", value scope pairs separated by \":\" (e.g. hri-integrators:hri_data_integrator,hri-consumers:hri_consumer). Values that aren't mapped are dropped, unless claim-passthrough is set")
+	fs.StringVar(&config.ClaimTenantPrefix, "claim-tenant-prefix", "", "(Optional) Prefix of the claim values that grant access to a tenant, the rest of the value is the tenant id. No claim value grants access to a tenant when it isn't set")
+	fs.BoolVar(&config.ClaimPassthrough, "claim-passthrough", false, "(Optional) True to use the claim values that aren't mapped by claim-value-mappings or claim-tenant-prefix as HRI scopes as they are. Only enable it when the identity provider can't issue values that match HRI scopes unintentionally")
+	fs.StringVar(&config.PolicyFile, "policy-file", "", "(Optional) Path of a YAML authorization policy, whose rules allow or deny actions and filter batches by the token's scopes, the tenant and batch attributes. Defaults to the built-in HRI roles")
 	fs.StringVar(&config.JwtAudienceId, "jwt-audience-id", "", "(Optional) The ID of the HRI Management API within your authorization service.")
 	fs.BoolVar(&config.Validation, "validation", false, "(Optional) True to enable record validation, false otherwise")
 	fs.StringVar(&config.ElasticUrl, "elastic-url", "", "(Optional) The base url to the Elasticsearch instance")
@@ -163,16 +413,52 @@ func GetConfig(configPath string, commandLineFlags []string) (Config, error) {
 	fs.StringVar(&config.ElasticPassword, "elastic-password", "", "(Optional) Elasticsearch password")
 	fs.StringVar(&config.ElasticCert, "elastic-cert", "", "(Optional) Elasticsearch TLS public certificate")
 	fs.StringVar(&config.ElasticServiceCrn, "elastic-crn", "", "(Optional) Elasticsearch service CRN")
+	fs.StringVar(&config.AdminAuthMode, "admin-auth-mode", "iam", "(Optional) How the tenants and streams endpoints are authorized: 'iam' checks the caller's IBM Cloud IAM token against elastic-crn and passes it to Event Streams, 'jwt' requires an HRI token with the hri_admin scope")
 	fs.StringVar(&config.KafkaAdminUrl, "kafka-admin-url", "", "(Optional) Kafka administration url")
+	fs.StringVar(&config.KafkaAdminApiKey, "kafka-admin-api-key", "", "(Optional) API key of the Kafka administration API, used instead of the caller's token when admin-auth-mode is 'jwt'")
 	fs.Var(&config.KafkaBrokers, "kafka-brokers", "(Optional) A list of Kafka brokers, separated by \",\"")
 	fs.Var(&config.KafkaProperties, "kafka-properties", "(Optional) A list of Kafka properties, entries separated by \",\", key value pairs separated by \":\"")
 	fs.StringVar(&config.LogLevel, "log-level", "info", "(Optional) Minimum Log Level for logging output. Available levels are: Trace, Debug, Info, Warning, Error, Fatal and Panic.")
+	fs.StringVar(&config.LogFormat, "log-format", LogFormatText, "(Optional) Format of the log entries, 'text' or 'json'")
+	fs.StringVar(&config.LogOutput, "log-output", LogOutputStdout, "(Optional) Where the logs are written, 'stdout', 'file' or 'syslog'")
+	fs.StringVar(&config.LogFile, "log-file", "", "(Optional) Path of the file the logs are appended to, when log-output is 'file'")
+	fs.StringVar(&config.LogSyslogAddress, "log-syslog-address", "", "(Optional) Address of the syslog daemon when log-output is 'syslog', e.g. udp://localhost:514. Defaults to the local syslog daemon")
 	fs.BoolVar(&config.NewRelicEnabled, "new-relic-enabled", false, "(Optional) True to enable New Relic monitoring, false otherwise")
 	fs.StringVar(&config.NewRelicAppName, "new-relic-app-name", "", "(Optional) Application name to aggregate data under in New Relic")
 	fs.StringVar(&config.NewRelicLicenseKey, "new-relic-license-key", "", "(Optional) New Relic license key")
 	fs.BoolVar(&config.TlsEnabled, "tls-enabled", false, "(Optional) Toggle enabling an encrypted connection via TLS")
 	fs.StringVar(&config.TlsCertPath, "tls-cert-path", "", "(Optional) path of TLS certificate signed by the Kubernetes CA")
 	fs.StringVar(&config.TlsKeyPath, "tls-key-path", "", "(Optional) path of key from TLS certificate signed by the Kubernetes CA")
+	fs.StringVar(&config.TlsClientAuth, "tls-client-auth", "none", "(Optional) TLS client certificate authentication: 'none', 'optional' to accept certificates as an alternative to tokens, or 'required'")
+	fs.StringVar(&config.TlsClientCaPath, "tls-client-ca-path", "", "(Optional) path of the PEM encoded CA certificates that sign the client certificates")
+	fs.StringVar(&config.CertIdentityFile, "cert-identity-file", "", "(Optional) Path of a YAML file that maps client certificate subjects or subject alternative names to HRI identities with roles and tenants")
+	fs.StringVar(&config.TopicNameTemplate, "topic-name-template", DefaultTopicNameTemplate, "(Optional) Template of stream topic names, which must contain {tenantId}, {streamId} and {topicType} exactly once")
+	fs.IntVar(&config.BatchTimeoutCheckSecs, "batch-timeout-check-interval", 60, "(Optional) Seconds between checks for batches that exceeded their tenant's batch timeout, 0 to disable the checks")
+	fs.StringVar(&config.TenantNotificationTopic, "tenant-notification-topic", "hri.tenants.notification", "(Optional) Kafka topic for tenant notifications, i.e. when a tenant is suspended or resumed")
+	fs.IntVar(&config.BatchRetentionDays, "batch-retention-days", 0, "(Optional) Days after which completed, failed and terminated batches are archived and removed, 0 to keep them. Tenants can override it with their retentionDays")
+	fs.IntVar(&config.RetentionCheckSecs, "retention-check-interval", 3600, "(Optional) Seconds between checks for batches that exceeded their retention, 0 to disable the checks")
+	fs.StringVar(&config.ArchiveStore, "archive-store", "", "(Optional) Where archived batches are stored, 'local' or 's3'")
+	fs.StringVar(&config.ArchiveDir, "archive-dir", "", "(Optional) Directory of the local archive store")
+	fs.StringVar(&config.ArchiveS3Endpoint, "archive-s3-endpoint", "", "(Optional) Base URL of the S3 compatible archive store")
+	fs.StringVar(&config.ArchiveS3Region, "archive-s3-region", "us-east-1", "(Optional) Region of the S3 compatible archive store")
+	fs.StringVar(&config.ArchiveS3Bucket, "archive-s3-bucket", "", "(Optional) Bucket of the S3 compatible archive store")
+	fs.StringVar(&config.ArchiveS3AccessKey, "archive-s3-access-key", "", "(Optional) HMAC access key of the S3 compatible archive store")
+	fs.StringVar(&config.ArchiveS3SecretKey, "archive-s3-secret-key", "", "(Optional) HMAC secret key of the S3 compatible archive store")
+	fs.StringVar(&config.AuditLogFile, "audit-log-file", "", "(Optional) Path of the file the audit log is appended to, one JSON entry per line")
+	fs.StringVar(&config.AuditKafkaTopic, "audit-kafka-topic", "", "(Optional) Kafka topic the audit log entries are written to")
+	fs.StringVar(&config.AuditHmacKey, "audit-hmac-key", "", "(Optional) Secret key of the HMAC-SHA256 hashes that chain the audit log entries, required when the audit log is enabled")
+	fs.StringVar(&config.TracingExporter, "tracing-exporter", TracingExporterNone, "(Optional) Where the OpenTelemetry spans are exported, 'none', 'stdout' or 'otlp'")
+	fs.StringVar(&config.TracingOtlpEndpoint, "tracing-otlp-endpoint", "", "(Optional) OTLP/HTTP endpoint of the trace collector, e.g. http://localhost:4318")
+	fs.IntVar(&config.HealthcheckCacheSecs, "healthcheck-cache-interval", 5, "(Optional) Seconds the results of /ready and /hri/healthcheck are cached, so frequent probes don't overload Elasticsearch and Kafka, 0 to not cache them")
+	fs.StringVar(&config.SchemaRegistryUrl, "schema-registry-url", "", "(Optional) Base URL of the schema registry, whose reachability is reported by the verbose healthcheck")
+	fs.BoolVar(&config.OpenApiValidation, "openapi-validation", false, "(Optional) Validate the requests and responses against the OpenAPI document served on /hri/openapi.json. Invalid requests are rejected and invalid responses are logged. Meant for development and test environments")
+	fs.IntVar(&config.MetricsPort, "metrics-port", 0, "(Optional) Port of a separate admin server for the Prometheus metrics, 0 to serve /metrics on the API's port")
+	fs.IntVar(&config.ImportMaxMegabytes, "import-max-size", 100, "(Optional) Megabytes the body of a tenant import can have, 0 to not limit it")
+	fs.Float64Var(&config.RateLimitClientRps, "rate-limit-client-rps", 0, "(Optional) Requests per second each client (token subject or API key) can make to create and search batches, 0 to not limit them")
+	fs.IntVar(&config.RateLimitClientBurst, "rate-limit-client-burst", 10, "(Optional) Requests a client can make at once, above its rate limit")
+	fs.Float64Var(&config.RateLimitTenantRps, "rate-limit-tenant-rps", 0, "(Optional) Requests per second all the clients of a tenant can make to create and search batches, 0 to not limit them. Tenants can override it with their quota's requestsPerSecond")
+	fs.IntVar(&config.RateLimitTenantBurst, "rate-limit-tenant-burst", 50, "(Optional) Requests a tenant's clients can make at once, above its rate limit. Tenants can override it with their quota's requestBurst")
+	fs.Var(&config.TopicTypeNames, "topic-type-names", "(Optional) Names used for {topicType} in topic names, entries separated by \",\", type name pairs separated by \":\" (e.g. in:input,notification:notify). Valid types are in, notification, out and invalid")
 
 	err := ff.Parse(fs, commandLineFlags,
 		ff.WithIgnoreUndefined(true),
@@ -67,6 +67,292 @@ func TestValidateConfig(t *testing.T) {
 				TlsKeyPath:         "./server-key.pem",
 			},
 		},
+		{
+			name: "negative batch timeout check interval",
+			config: Config{
+				ConfigPath:            "validPath",
+				AuthDisabled:          true,
+				ElasticUrl:            "https://ibm.com",
+				ElasticUsername:       "elasticUsername",
+				ElasticPassword:       "elasticPassword",
+				ElasticCert:           testCert,
+				ElasticServiceCrn:     "elasticServiceCrn",
+				KafkaAdminUrl:         "https://ibm.kafka.com",
+				KafkaBrokers:          StringSlice{"broker 1", "broker 2"},
+				BatchTimeoutCheckSecs: -1,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe batch timeout check interval can't be negative",
+		},
+		{
+			name: "batch retention without archive store",
+			config: Config{
+				ConfigPath:         "validPath",
+				AuthDisabled:       true,
+				ElasticUrl:         "https://ibm.com",
+				ElasticUsername:    "elasticUsername",
+				ElasticPassword:    "elasticPassword",
+				ElasticCert:        testCert,
+				ElasticServiceCrn:  "elasticServiceCrn",
+				KafkaAdminUrl:      "https://ibm.kafka.com",
+				KafkaBrokers:       StringSlice{"broker 1", "broker 2"},
+				BatchRetentionDays: 30,
+				RetentionCheckSecs: -1,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe retention check interval can't be negative" +
+				"\n\tBatch retention is enabled but an archive store was not specified",
+		},
+		{
+			name: "invalid metrics port",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				MetricsPort:       70000,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe metrics port 70000 is invalid, must be between 0 and 65535",
+		},
+		{
+			name: "otlp tracing without endpoint",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				TracingExporter:   "otlp",
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe OTLP trace endpoint is an invalid URL:  ",
+		},
+		{
+			name: "unknown trace exporter",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				TracingExporter:   "jaeger",
+			},
+			expectedErrMsg: "Configuration errors:\n\tUnknown trace exporter 'jaeger', must be 'none', 'stdout' or 'otlp'",
+		},
+		{
+			name: "unknown log format",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				LogFormat:         "xml",
+			},
+			expectedErrMsg: "Configuration errors:\n\tUnknown log format 'xml', must be 'text' or 'json'",
+		},
+		{
+			name: "file log output without a file",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				LogOutput:         "file",
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe log output is a file but a log file was not specified",
+		},
+		{
+			name: "invalid syslog address",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				LogOutput:         "syslog",
+				LogSyslogAddress:  "localhost",
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe syslog address is an invalid URL:  localhost",
+		},
+		{
+			name: "unknown log output",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				LogOutput:         "stderr",
+			},
+			expectedErrMsg: "Configuration errors:\n\tUnknown log output 'stderr', must be 'stdout', 'file' or 'syslog'",
+		},
+		{
+			name: "negative healthcheck cache interval and invalid schema registry url",
+			config: Config{
+				ConfigPath:           "validPath",
+				AuthDisabled:         true,
+				ElasticUrl:           "https://ibm.com",
+				ElasticUsername:      "elasticUsername",
+				ElasticPassword:      "elasticPassword",
+				ElasticCert:          testCert,
+				ElasticServiceCrn:    "elasticServiceCrn",
+				KafkaAdminUrl:        "https://ibm.kafka.com",
+				KafkaBrokers:         StringSlice{"broker 1", "broker 2"},
+				HealthcheckCacheSecs: -1,
+				SchemaRegistryUrl:    "registry",
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe healthcheck cache interval can't be negative" +
+				"\n\tThe schema registry URL is an invalid URL:  registry",
+		},
+		{
+			name: "negative rate limit and missing burst",
+			config: Config{
+				ConfigPath:           "validPath",
+				AuthDisabled:         true,
+				ElasticUrl:           "https://ibm.com",
+				ElasticUsername:      "elasticUsername",
+				ElasticPassword:      "elasticPassword",
+				ElasticCert:          testCert,
+				ElasticServiceCrn:    "elasticServiceCrn",
+				KafkaAdminUrl:        "https://ibm.kafka.com",
+				KafkaBrokers:         StringSlice{"broker 1", "broker 2"},
+				RateLimitClientRps:   -1,
+				RateLimitTenantRps:   5,
+				RateLimitTenantBurst: 0,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe rate limits can't be negative" +
+				"\n\tThe tenant rate limit is enabled but its burst is less than 1",
+		},
+		{
+			name: "invalid port and negative shutdown timeout",
+			config: Config{
+				ConfigPath:          "validPath",
+				AuthDisabled:        true,
+				ElasticUrl:          "https://ibm.com",
+				ElasticUsername:     "elasticUsername",
+				ElasticPassword:     "elasticPassword",
+				ElasticCert:         testCert,
+				ElasticServiceCrn:   "elasticServiceCrn",
+				KafkaAdminUrl:       "https://ibm.kafka.com",
+				KafkaBrokers:        StringSlice{"broker 1", "broker 2"},
+				Port:                70000,
+				ShutdownTimeoutSecs: -1,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe port 70000 is invalid, must be between 0 and 65535" +
+				"\n\tThe shutdown timeout can't be negative",
+		},
+		{
+			name: "negative import size",
+			config: Config{
+				ConfigPath:         "validPath",
+				AuthDisabled:       true,
+				ElasticUrl:         "https://ibm.com",
+				ElasticUsername:    "elasticUsername",
+				ElasticPassword:    "elasticPassword",
+				ElasticCert:        testCert,
+				ElasticServiceCrn:  "elasticServiceCrn",
+				KafkaAdminUrl:      "https://ibm.kafka.com",
+				KafkaBrokers:       StringSlice{"broker 1", "broker 2"},
+				ImportMaxMegabytes: -1,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe import size limit can't be negative",
+		},
+		{
+			name: "metrics port same as the port",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				Port:              1323,
+				MetricsPort:       1323,
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe metrics port 1323 must differ from the server's port",
+		},
+		{
+			name: "invalid s3 archive store",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				ArchiveStore:      ArchiveStoreS3,
+				ArchiveS3Endpoint: "not a url",
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe S3 archive store endpoint is an invalid URL:  not a url" +
+				"\n\tThe S3 archive store is enabled but a bucket was not specified" +
+				"\n\tThe S3 archive store is enabled but the access key or secret key was not specified",
+		},
+		{
+			name: "audit log without an hmac key",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				AuditKafkaTopic:   "hri.audit",
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe audit log is enabled but an HMAC key was not specified",
+		},
+		{
+			name: "unknown archive store",
+			config: Config{
+				ConfigPath:        "validPath",
+				AuthDisabled:      true,
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				ArchiveStore:      "ftp",
+			},
+			expectedErrMsg: "Configuration errors:\n\tUnknown archive store 'ftp', must be 'local' or 's3'",
+		},
 		{
 			name: "no config file specified",
 			config: Config{
@@ -107,6 +393,21 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectedErrMsg: "Configuration errors:\n\tOIDC Issuer is an invalid URL:  invalidUrl.gov",
 		},
+		{
+			name: "oidc key file without issuer",
+			config: Config{
+				ConfigPath:        "validPath",
+				OidcKeyFile:       "./jwks.json",
+				ElasticUrl:        "https://ibm.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+			},
+			expectedErrMsg: "Configuration errors:\n\tAn OIDC key file was specified, but the OIDC issuer was not",
+		},
 		{
 			name: "nr enabled but no app name or license",
 			config: Config{
@@ -172,6 +473,138 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectedErrMsg: "Configuration errors:\n\tThe Elasticsearch certificate is invalid",
 		},
+		{
+			name: "jwt admin auth mode doesn't need an elasticsearch crn",
+			config: Config{
+				ConfigPath:       "validPath",
+				OidcIssuer:       "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:       "https://elastic.com",
+				ElasticUsername:  "elasticUsername",
+				ElasticPassword:  "elasticPassword",
+				ElasticCert:      testCert,
+				AdminAuthMode:    AdminAuthModeJwt,
+				KafkaAdminUrl:    "https://kafka-admin.com",
+				KafkaAdminApiKey: "kafkaAdminApiKey",
+				KafkaBrokers:     StringSlice{"broker 1", "broker 2"},
+			},
+		},
+		{
+			name: "jwt admin auth mode without a kafka admin api key",
+			config: Config{
+				ConfigPath:      "validPath",
+				OidcIssuer:      "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:      "https://elastic.com",
+				ElasticUsername: "elasticUsername",
+				ElasticPassword: "elasticPassword",
+				ElasticCert:     testCert,
+				AdminAuthMode:   AdminAuthModeJwt,
+				KafkaAdminUrl:   "https://kafka-admin.com",
+				KafkaBrokers:    StringSlice{"broker 1", "broker 2"},
+			},
+			expectedErrMsg: "Configuration errors:\n\tThe admin auth mode is 'jwt' but a Kafka administration API key was not specified",
+		},
+		{
+			name: "jwt admin auth mode with auth disabled still needs the oidc issuer",
+			config: Config{
+				ConfigPath:       "validPath",
+				AuthDisabled:     true,
+				ElasticUrl:       "https://elastic.com",
+				ElasticUsername:  "elasticUsername",
+				ElasticPassword:  "elasticPassword",
+				ElasticCert:      testCert,
+				AdminAuthMode:    AdminAuthModeJwt,
+				KafkaAdminUrl:    "https://kafka-admin.com",
+				KafkaAdminApiKey: "kafkaAdminApiKey",
+				KafkaBrokers:     StringSlice{"broker 1", "broker 2"},
+			},
+			expectedErrMsg: "Configuration errors:\n\tOIDC Issuer is an invalid URL:  ",
+		},
+		{
+			name: "tls client authentication",
+			config: Config{
+				ConfigPath:        "validPath",
+				OidcIssuer:        "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:        "https://elastic.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				TlsEnabled:        true,
+				TlsCertPath:       "./server-cert.pem",
+				TlsKeyPath:        "./server-key.pem",
+				TlsClientAuth:     TlsClientAuthOptional,
+				TlsClientCaPath:   "./client-ca.pem",
+				CertIdentityFile:  "./cert-identities.yml",
+			},
+		},
+		{
+			name: "invalid tls client authentication",
+			config: Config{
+				ConfigPath:        "validPath",
+				OidcIssuer:        "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:        "https://elastic.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				TlsClientAuth:     TlsClientAuthRequired,
+			},
+			expectedErrMsg: "Configuration errors:" +
+				"\n\tTLS client authentication is enabled but TLS is not" +
+				"\n\tTLS client authentication is enabled but a path to the client CA certificates was not specified",
+		},
+		{
+			name: "certificate identities without tls client authentication",
+			config: Config{
+				ConfigPath:        "validPath",
+				OidcIssuer:        "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:        "https://elastic.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				CertIdentityFile:  "./cert-identities.yml",
+			},
+			expectedErrMsg: "Configuration errors:\n\tA certificate identity file was specified, but TLS client authentication is disabled",
+		},
+		{
+			name: "unknown tls client authentication",
+			config: Config{
+				ConfigPath:        "validPath",
+				OidcIssuer:        "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:        "https://elastic.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+				TlsClientAuth:     "always",
+			},
+			expectedErrMsg: "Configuration errors:\n\tUnknown TLS client authentication 'always', must be 'none', 'optional' or 'required'",
+		},
+		{
+			name: "unknown admin auth mode",
+			config: Config{
+				ConfigPath:        "validPath",
+				OidcIssuer:        "https://us-south.appid.cloud.ibm.com/oauth/v4/",
+				ElasticUrl:        "https://elastic.com",
+				ElasticUsername:   "elasticUsername",
+				ElasticPassword:   "elasticPassword",
+				ElasticCert:       testCert,
+				ElasticServiceCrn: "elasticServiceCrn",
+				AdminAuthMode:     "apikey",
+				KafkaAdminUrl:     "https://ibm.kafka.com",
+				KafkaBrokers:      StringSlice{"broker 1", "broker 2"},
+			},
+			expectedErrMsg: "Configuration errors:\n\tUnknown admin auth mode 'apikey', must be 'iam' or 'jwt'",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			err := ValidateConfig(tc.config)
@@ -234,26 +667,43 @@ func TestGetConfig(t *testing.T) {
 			commandLineFlags: []string{"-jwt-audience-id=ValFromFlag", "-validation=true", fmt.Sprintf("-kafka-brokers=%s,%s", "broker1", "broker2")},
 			envVars:          [][2]string{{"OIDC_ISSUER", "http://ValFromEnv.gov"}, {"JWT_AUDIENCE_ID", "ValFromEnv"}},
 			expectedConfig: Config{
-				ConfigPath:         configPath,
-				AuthDisabled:       false,
-				OidcIssuer:         "http://ValFromEnv.gov",
-				JwtAudienceId:      "ValFromFlag",
-				Validation:         true,
-				ElasticUrl:         "https://elastic.com",
-				ElasticUsername:    "elasticUsername",
-				ElasticPassword:    "elasticPassword",
-				ElasticCert:        testCert,
-				ElasticServiceCrn:  "elasticCrn",
-				KafkaAdminUrl:      "https://ibm.kafka.com",
-				KafkaBrokers:       StringSlice{"broker1", "broker2"},
-				KafkaProperties:    StringMap{"sasl.mechanism": "PLAIN", "sasl.username": "kafkaUsername", "sasl.password": "kafkaPassword"},
-				LogLevel:           "info",
-				NewRelicEnabled:    true,
-				NewRelicAppName:    "nrAppName",
-				NewRelicLicenseKey: "nrLicenseKey0000000000000000000000000000",
-				TlsEnabled:         true,
-				TlsCertPath:        "./server-cert.pem",
-				TlsKeyPath:         "./server-key.pem",
+				ConfigPath:              configPath,
+				AuthDisabled:            false,
+				OidcIssuer:              "http://ValFromEnv.gov",
+				JwtAudienceId:           "ValFromFlag",
+				OidcKeyRefreshSecs:      3600,
+				Validation:              true,
+				ElasticUrl:              "https://elastic.com",
+				ElasticUsername:         "elasticUsername",
+				ElasticPassword:         "elasticPassword",
+				ElasticCert:             testCert,
+				ElasticServiceCrn:       "elasticCrn",
+				AdminAuthMode:           "iam",
+				KafkaAdminUrl:           "https://ibm.kafka.com",
+				KafkaBrokers:            StringSlice{"broker1", "broker2"},
+				KafkaProperties:         StringMap{"sasl.mechanism": "PLAIN", "sasl.username": "kafkaUsername", "sasl.password": "kafkaPassword"},
+				LogLevel:                "info",
+				LogFormat:               "text",
+				LogOutput:               "stdout",
+				NewRelicEnabled:         true,
+				NewRelicAppName:         "nrAppName",
+				NewRelicLicenseKey:      "nrLicenseKey0000000000000000000000000000",
+				TlsEnabled:              true,
+				TlsCertPath:             "./server-cert.pem",
+				TlsKeyPath:              "./server-key.pem",
+				TlsClientAuth:           "none",
+				TopicNameTemplate:       "ingest.{tenantId}.{streamId}.{topicType}",
+				BatchTimeoutCheckSecs:   60,
+				TenantNotificationTopic: "hri.tenants.notification",
+				RetentionCheckSecs:      3600,
+				ArchiveS3Region:         "us-east-1",
+				TracingExporter:         "none",
+				HealthcheckCacheSecs:    5,
+				Port:                    1323,
+				ShutdownTimeoutSecs:     30,
+				RateLimitClientBurst:    10,
+				RateLimitTenantBurst:    50,
+				ImportMaxMegabytes:      100,
 			},
 		},
 	} {
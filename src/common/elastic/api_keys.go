@@ -0,0 +1,129 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+	"strings"
+)
+
+// ApiKeysIndex holds one document per API key, with the key id as the document id
+const ApiKeysIndex = "hri-api-keys"
+
+const maxApiKeys = 10000
+
+// AddApiKey stores a new API key. It fails with a 409 if a key with the same id already exists.
+func AddApiKey(ctx context.Context, apiKey model.ApiKey, client *elasticsearch.Client) *ResponseError {
+	jsonKey, err := json.Marshal(apiKey)
+	if err != nil {
+		return &ResponseError{ErrorObj: fmt.Errorf("error encoding API key: %w", err), Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Index(
+		ApiKeysIndex,
+		strings.NewReader(string(jsonKey)),
+		client.Index.WithDocumentID(apiKey.KeyId),
+		client.Index.WithOpType("create"),
+		client.Index.WithContext(ctx),
+		client.Index.WithRefresh("true"),
+	)
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
+
+// GetApiKey returns the API key's document, nil if there is no key with that id
+func GetApiKey(ctx context.Context, keyId string, client *elasticsearch.Client) (*model.ApiKey, *ResponseError) {
+	res, err := client.Get(ApiKeysIndex, keyId, client.Get.WithContext(ctx))
+
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		// the document or the whole index not existing both mean the key doesn't exist
+		if elasticErr.Code == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, elasticErr
+	}
+
+	apiKey, elasticErr := apiKeyFromSource(keyId, body["_source"])
+	if elasticErr != nil {
+		return nil, elasticErr
+	}
+	return &apiKey, nil
+}
+
+// GetApiKeys returns all the API keys, including the revoked ones, oldest first
+func GetApiKeys(ctx context.Context, client *elasticsearch.Client) ([]model.ApiKey, *ResponseError) {
+	res, err := client.Search(
+		client.Search.WithContext(ctx),
+		client.Search.WithIndex(ApiKeysIndex),
+		client.Search.WithSort("createdDate:asc"),
+		client.Search.WithSize(maxApiKeys),
+	)
+
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		if elasticErr.Code == http.StatusNotFound {
+			return []model.ApiKey{}, nil
+		}
+		return nil, elasticErr
+	}
+
+	hits := body["hits"].(map[string]interface{})["hits"].([]interface{})
+	apiKeys := make([]model.ApiKey, 0, len(hits))
+	for _, hit := range hits {
+		doc := hit.(map[string]interface{})
+		apiKey, elasticErr := apiKeyFromSource(doc["_id"].(string), doc["_source"])
+		if elasticErr != nil {
+			return nil, elasticErr
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, nil
+}
+
+// RevokeApiKey records who revoked the API key and when. The document is kept, so the key stays listed.
+func RevokeApiKey(ctx context.Context, keyId string, revokedBy string, revokedDate string, client *elasticsearch.Client) *ResponseError {
+	update := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"revokedBy":   revokedBy,
+			"revokedDate": revokedDate,
+		},
+	}
+	buf, err := EncodeQueryBody(update)
+	if err != nil {
+		return &ResponseError{ErrorObj: fmt.Errorf("error encoding API key update: %w", err), Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Update(
+		ApiKeysIndex,
+		keyId,
+		buf,
+		client.Update.WithContext(ctx),
+		client.Update.WithRefresh("true"),
+	)
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
+
+func apiKeyFromSource(keyId string, source interface{}) (model.ApiKey, *ResponseError) {
+	apiKey := model.ApiKey{}
+	encoded, err := json.Marshal(source)
+	if err == nil {
+		err = json.Unmarshal(encoded, &apiKey)
+	}
+	if err != nil {
+		err = fmt.Errorf("error parsing API key [%s]: %w", keyId, err)
+		return model.ApiKey{}, &ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+	}
+
+	apiKey.KeyId = keyId
+	return apiKey, nil
+}
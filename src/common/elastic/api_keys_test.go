@@ -0,0 +1,189 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestAddApiKey(t *testing.T) {
+	apiKey := model.ApiKey{
+		KeyId:       "0123456789abcdef",
+		Name:        "nightly-ingest",
+		Hash:        "hash",
+		Scopes:      []string{"hri_data_integrator"},
+		Tenants:     []string{"tenant1"},
+		CreatedBy:   "admin",
+		CreatedDate: "2021-02-24T18:08:36Z",
+	}
+	keyPath := "/" + ApiKeysIndex + "/_doc/" + apiKey.KeyId
+
+	transport := test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+		RequestQuery: "op_type=create&refresh=true",
+		RequestBody:  `{"name":"nightly-ingest","hash":"hash","scopes":\["hri_data_integrator"\],"tenants":\["tenant1"\],"createdBy":"admin","createdDate":"2021-02-24T18:08:36Z"}`,
+		ResponseBody: `{"_id":"0123456789abcdef","result":"created"}`,
+	}).AddCall(keyPath, test.ElasticCall{
+		ResponseStatusCode: http.StatusConflict,
+		ResponseBody:       `{"error":{"type":"version_conflict_engine_exception","reason":"document already exists"},"status":409}`,
+	})
+	client, err := ClientFromTransport(transport)
+	assert.NoError(t, err)
+
+	assert.Nil(t, AddApiKey(context.Background(), apiKey, client))
+	elasticErr := AddApiKey(context.Background(), apiKey, client)
+	if assert.NotNil(t, elasticErr) {
+		assert.Equal(t, http.StatusConflict, elasticErr.Code)
+	}
+	transport.VerifyCalls()
+}
+
+func TestGetApiKey(t *testing.T) {
+	keyId := "0123456789abcdef"
+	keyPath := "/" + ApiKeysIndex + "/_doc/" + keyId
+
+	testCases := []struct {
+		name        string
+		transport   *test.FakeTransport
+		expectedKey *model.ApiKey
+		expectedErr *ResponseError
+	}{
+		{
+			name: "found",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseBody: `{"_id":"0123456789abcdef","found":true,"_source":{"name":"nightly-ingest","hash":"hash","scopes":["hri_consumer"],"createdBy":"admin","createdDate":"2021-02-24T18:08:36Z"}}`,
+			}),
+			expectedKey: &model.ApiKey{
+				KeyId:       keyId,
+				Name:        "nightly-ingest",
+				Hash:        "hash",
+				Scopes:      []string{"hri_consumer"},
+				CreatedBy:   "admin",
+				CreatedDate: "2021-02-24T18:08:36Z",
+			},
+		},
+		{
+			name: "not found",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusNotFound,
+				ResponseBody:       `{"_id":"0123456789abcdef","found":false}`,
+			}),
+		},
+		{
+			name: "bad source",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseBody: `{"_id":"0123456789abcdef","found":true,"_source":{"scopes":"hri_consumer"}}`,
+			}),
+			expectedErr: &ResponseError{
+				ErrorObj: errors.New("error parsing API key [0123456789abcdef]: json: cannot unmarshal string into Go struct field ApiKey.scopes of type []string"),
+				Code:     http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "client error",
+			transport: test.NewFakeTransport(t).AddCall(keyPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErr: &ResponseError{
+				ErrorObj: errors.New("elasticsearch client error: connection refused"),
+				Code:     http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			apiKey, elasticErr := GetApiKey(context.Background(), keyId, client)
+			if tc.expectedErr == nil {
+				assert.Nil(t, elasticErr)
+				assert.Equal(t, tc.expectedKey, apiKey)
+			} else if assert.NotNil(t, elasticErr) {
+				assert.Equal(t, tc.expectedErr.Code, elasticErr.Code)
+				assert.Equal(t, tc.expectedErr.ErrorObj.Error(), elasticErr.ErrorObj.Error())
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestGetApiKeys(t *testing.T) {
+	searchPath := "/" + ApiKeysIndex + "/_search"
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedKeys []model.ApiKey
+		expectedErr  string
+	}{
+		{
+			name: "found",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				RequestQuery: "size=10000&sort=createdDate%3Aasc",
+				ResponseBody: `{"hits":{"hits":[
+					{"_id":"key1","_source":{"name":"first","hash":"hash1","createdDate":"2021-02-24T18:08:36Z"}},
+					{"_id":"key2","_source":{"name":"second","hash":"hash2","createdDate":"2021-02-25T18:08:36Z","revokedDate":"2021-02-26T18:08:36Z"}}
+				]}}`,
+			}),
+			expectedKeys: []model.ApiKey{
+				{KeyId: "key1", Name: "first", Hash: "hash1", CreatedDate: "2021-02-24T18:08:36Z"},
+				{KeyId: "key2", Name: "second", Hash: "hash2", CreatedDate: "2021-02-25T18:08:36Z", RevokedDate: "2021-02-26T18:08:36Z"},
+			},
+		},
+		{
+			name: "no index",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusNotFound,
+				ResponseBody:       `{"error":{"type":"index_not_found_exception","reason":"no such index [hri-api-keys]"},"status":404}`,
+			}),
+			expectedKeys: []model.ApiKey{},
+		},
+		{
+			name: "client error",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErr: "elasticsearch client error: connection refused",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			apiKeys, elasticErr := GetApiKeys(context.Background(), client)
+			if tc.expectedErr == "" {
+				assert.Nil(t, elasticErr)
+				assert.Equal(t, tc.expectedKeys, apiKeys)
+			} else if assert.NotNil(t, elasticErr) {
+				assert.Equal(t, tc.expectedErr, elasticErr.ErrorObj.Error())
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestRevokeApiKey(t *testing.T) {
+	keyId := "0123456789abcdef"
+	transport := test.NewFakeTransport(t).AddCall("/"+ApiKeysIndex+"/_doc/"+keyId+"/_update", test.ElasticCall{
+		RequestQuery: "refresh=true",
+		RequestBody:  `{"doc":{"revokedBy":"admin","revokedDate":"2021-02-26T18:08:36Z"}}` + "\n",
+		ResponseBody: `{"_id":"0123456789abcdef","result":"updated"}`,
+	})
+	client, err := ClientFromTransport(transport)
+	assert.NoError(t, err)
+
+	assert.Nil(t, RevokeApiKey(context.Background(), keyId, "admin", "2021-02-26T18:08:36Z", client))
+	transport.VerifyCalls()
+}
@@ -15,11 +15,14 @@ import (
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/tracing"
 	service "github.com/IBM/resource-controller-go-sdk-generator/build/generated"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -102,15 +105,15 @@ func CreateResourceControllerService() ResourceControllerService {
 	return service
 }
 
-func CheckElasticIAM(elasticServiceCrn string, bearerToken string, service ResourceControllerService) (int, error) {
-	_, response, err := service.GetResourceInstance(context.Background(), bearerToken, elasticServiceCrn)
+func CheckElasticIAM(ctx context.Context, elasticServiceCrn string, bearerToken string, service ResourceControllerService) (int, error) {
+	_, response, err := service.GetResourceInstance(ctx, bearerToken, elasticServiceCrn)
 
 	if response == nil {
 		return http.StatusInternalServerError, err
 	} else if response.StatusCode == http.StatusOK {
 		return http.StatusOK, nil
 	} else if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
-		return http.StatusUnauthorized, fmt.Errorf("elastic IAM authentication returned %d : %w", response.StatusCode, err)
+		return response.StatusCode, fmt.Errorf("elastic IAM authentication returned %d : %w", response.StatusCode, err)
 	} else if response.StatusCode == http.StatusNotFound {
 		return http.StatusInternalServerError, fmt.Errorf("elastic IAM authentication returned 404 : %w", err)
 	} else {
@@ -125,6 +128,7 @@ func ClientFromTransport(transport http.RoundTripper) (*elasticsearch.Client, er
 }
 
 func fromConfig(config elasticsearch.Config) (*elasticsearch.Client, error) {
+	config.Transport = tracing.NewElasticTransport(metrics.NewElasticTransport(config.Transport))
 	client, err := elasticsearch.NewClient(config)
 	if err != nil {
 		return nil, err
@@ -156,3 +160,43 @@ func TenantsFromIndices(body []map[string]interface{}) map[string]interface{} {
 	tenantsMap["results"] = indices
 	return tenantsMap
 }
+
+// IndexExists returns whether the index exists. Any status other than 200 or 404, i.e. the credentials being rejected,
+// is an error, because it doesn't tell whether the index exists.
+func IndexExists(ctx context.Context, index string, client *elasticsearch.Client) (bool, error) {
+	res, err := client.Indices.Exists([]string{index}, client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to check if index %s exists: %s", index, res.Status())
+}
+
+// GetTenantIndices returns the batches index of every tenant, sorted
+func GetTenantIndices(client *elasticsearch.Client) ([]string, error) {
+	res, err := client.Cat.Indices(
+		client.Cat.Indices.WithIndex("*"+IndexFromTenantId("")),
+		client.Cat.Indices.WithH("index"),
+		client.Cat.Indices.WithFormat("json"),
+	)
+	body, elasticErr := DecodeBodyFromJsonArray(res, err)
+	if elasticErr != nil {
+		return nil, fmt.Errorf("unable to list the tenant indices: %w", elasticErr)
+	}
+
+	indices := make([]string, 0, len(body))
+	for _, entry := range body {
+		index, _ := entry["index"].(string)
+		if strings.HasSuffix(index, IndexFromTenantId("")) {
+			indices = append(indices, index)
+		}
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
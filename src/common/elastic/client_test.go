@@ -7,6 +7,7 @@ package elastic
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -128,7 +129,7 @@ func TestCheckElasticBearerTokenSuccess(t *testing.T) {
 		GetResourceInstance(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(generated.ResourceInstance{}, &http.Response{StatusCode: http.StatusOK}, nil).AnyTimes()
 
-	code, err := CheckElasticIAM(crn, bearerToken, mockResourceInstanceService)
+	code, err := CheckElasticIAM(context.Background(), crn, bearerToken, mockResourceInstanceService)
 	if code != http.StatusOK || err != nil {
 		t.Fatal(err)
 	}
@@ -146,12 +147,30 @@ func TestCheckElasticBearerTokenNon200Response(t *testing.T) {
 		GetResourceInstance(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(generated.ResourceInstance{}, &http.Response{StatusCode: http.StatusUnauthorized}, errors.New(errMsg)).AnyTimes()
 
-	code, err := CheckElasticIAM(crn, bearerToken, mockResourceInstanceService)
+	code, err := CheckElasticIAM(context.Background(), crn, bearerToken, mockResourceInstanceService)
 	if code != http.StatusUnauthorized || err == nil || err.Error() != "elastic IAM authentication returned 401 : "+errMsg {
 		t.Errorf("CheckElasticIAM() = %v, expected: Resource controller returned status of:", err)
 	}
 }
 
+func TestCheckElasticBearerTokenForbidden(t *testing.T) {
+	crn := "myElasticCrn"
+	bearerToken := "myBearerToken"
+	errMsg := "getResourceInstanceErrMsg"
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+	mockResourceInstanceService := test.NewMockResourceControllerService(controller)
+	mockResourceInstanceService.
+		EXPECT().
+		GetResourceInstance(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(generated.ResourceInstance{}, &http.Response{StatusCode: http.StatusForbidden}, errors.New(errMsg)).AnyTimes()
+
+	code, err := CheckElasticIAM(context.Background(), crn, bearerToken, mockResourceInstanceService)
+	if code != http.StatusForbidden || err == nil || err.Error() != "elastic IAM authentication returned 403 : "+errMsg {
+		t.Errorf("CheckElasticIAM() = %v, expected: 403", err)
+	}
+}
+
 func TestCheckElasticBearerTokenFailures(t *testing.T) {
 	crn := "myElasticCrn"
 	bearerToken := "myBearerToken"
@@ -165,7 +184,7 @@ func TestCheckElasticBearerTokenFailures(t *testing.T) {
 		GetResourceInstance(gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(generated.ResourceInstance{}, &http.Response{StatusCode: http.StatusNotFound}, errors.New(errMsg)).AnyTimes()
 
-	code, err := CheckElasticIAM(crn, bearerToken, mockResourceInstanceService)
+	code, err := CheckElasticIAM(context.Background(), crn, bearerToken, mockResourceInstanceService)
 	if code != http.StatusInternalServerError || err == nil || err.Error() != "elastic IAM authentication returned 404 : "+errMsg {
 		t.Errorf("CheckElasticIAM() = %v, expected: 404", err)
 	}
@@ -250,3 +269,42 @@ func TestTenantsFromIndices(t *testing.T) {
 	expResultsMap["results"] = expIndices
 	assert.Equal(t, expResultsMap, resultsMap)
 }
+
+func TestIndexExists(t *testing.T) {
+	testCases := []struct {
+		name           string
+		call           test.ElasticCall
+		expectedExists bool
+		expectedErr    string
+	}{
+		{name: "exists", call: test.ElasticCall{ResponseStatusCode: http.StatusOK}, expectedExists: true},
+		{name: "not found", call: test.ElasticCall{ResponseStatusCode: http.StatusNotFound}},
+		{
+			name:        "unauthorized",
+			call:        test.ElasticCall{ResponseStatusCode: http.StatusUnauthorized},
+			expectedErr: "unable to check if index tenant1-batches exists: 401 Unauthorized",
+		},
+		{
+			name:        "client error",
+			call:        test.ElasticCall{ResponseErr: errors.New("connection refused")},
+			expectedErr: "connection refused",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := test.NewFakeTransport(t).AddCall("/tenant1-batches", tc.call)
+			client, err := ClientFromTransport(transport)
+			assert.NoError(t, err)
+
+			exists, err := IndexExists(context.Background(), "tenant1-batches", client)
+			assert.Equal(t, tc.expectedExists, exists)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+			transport.VerifyCalls()
+		})
+	}
+}
@@ -0,0 +1,46 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+	"strings"
+)
+
+// LegalHoldAuditIndex holds one document for every legal hold that was set or released
+const LegalHoldAuditIndex = "hri-legal-hold-audit"
+
+// LegalHoldAuditEntry records who set or released a legal hold on a batch, or on a whole tenant when BatchId is empty
+type LegalHoldAuditEntry struct {
+	TenantId  string `json:"tenantId"`
+	BatchId   string `json:"batchId,omitempty"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason"`
+	Actor     string `json:"actor"`
+	Date      string `json:"date"`
+	RequestId string `json:"requestId"`
+}
+
+// AddLegalHoldAuditEntry stores the entry in the legal hold audit index. Entries are never updated or removed.
+func AddLegalHoldAuditEntry(ctx context.Context, entry LegalHoldAuditEntry, client *elasticsearch.Client) *ResponseError {
+	jsonEntry, err := json.Marshal(entry)
+	if err != nil {
+		return &ResponseError{ErrorObj: fmt.Errorf("error encoding legal hold audit entry: %w", err),
+			Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Index(
+		LegalHoldAuditIndex,
+		strings.NewReader(string(jsonEntry)),
+		client.Index.WithContext(ctx),
+	)
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
@@ -0,0 +1,299 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// Migration upgrades a tenant's batches index from Version-1 to Version. The index is reindexed into a new index that
+// has the current mapping, so changes to how existing fields are indexed only need a new template version. Script
+// is an optional painless script that is run on every batch while reindexing, i.e. to rename a field.
+type Migration struct {
+	Version     int
+	Description string
+	Script      string
+}
+
+// Indices created before the templates were versioned don't have a schema version in their mapping
+const unversionedSchema = 1
+
+const migrationIndexSuffix = "-migration"
+
+// index of the migration step that deletes the original index, after which a failed migration can't be undone
+const originalDeletedStep = 4
+
+// index of the last migration step, which deletes the temporary index once the batches are migrated
+const tempDeletedStep = 6
+
+var batchesMigrations = []Migration{
+	{
+		Version:     2,
+		Description: "index endDate and replace the deprecated recordCount with expectedRecordCount",
+		Script: "if (ctx._source.recordCount != null) {" +
+			"if (ctx._source.expectedRecordCount == null) {ctx._source.expectedRecordCount = ctx._source.recordCount;} " +
+			"ctx._source.remove('recordCount');}",
+	},
+	{
+		Version:     3,
+		Description: "map the legalHold of held batches",
+	},
+}
+
+// GetSchemaVersion returns the schema version of a tenant's batches index
+func GetSchemaVersion(index string, client *elasticsearch.Client) (int, *ResponseError) {
+	res, err := client.Indices.GetMapping(
+		client.Indices.GetMapping.WithIndex(index),
+		client.Indices.GetMapping.WithContext(context.Background()),
+	)
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		return 0, elasticErr
+	}
+
+	indexMapping, _ := body[index].(map[string]interface{})
+	mappings, _ := indexMapping["mappings"].(map[string]interface{})
+	meta, _ := mappings["_meta"].(map[string]interface{})
+	if version, ok := meta["schemaVersion"].(float64); ok {
+		return int(version), nil
+	}
+	return unversionedSchema, nil
+}
+
+// PendingMigrations returns the migrations that haven't been applied to an index with the given schema version
+func PendingMigrations(schemaVersion int) []Migration {
+	pending := make([]Migration, 0)
+	for _, migration := range batchesMigrations {
+		if migration.Version > schemaVersion {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// OutdatedTenantIndices returns the tenants' batches indices that have pending migrations
+func OutdatedTenantIndices(client *elasticsearch.Client) ([]string, error) {
+	indices, err := GetTenantIndices(client)
+	if err != nil {
+		return nil, err
+	}
+
+	outdated := make([]string, 0)
+	for _, index := range indices {
+		schemaVersion, elasticErr := GetSchemaVersion(index, client)
+		if elasticErr != nil {
+			return nil, fmt.Errorf("unable to get the schema version of index %s: %w", index, elasticErr)
+		}
+		if len(PendingMigrations(schemaVersion)) > 0 {
+			outdated = append(outdated, index)
+		}
+	}
+	return outdated, nil
+}
+
+// MigrateTenantIndices migrates every tenant's batches index to the current schema version. It keeps going when an
+// index can't be migrated and returns the number of migrated indices and the errors.
+func MigrateTenantIndices(client *elasticsearch.Client, logger logrus.FieldLogger) (int, []error) {
+	indices, err := GetTenantIndices(client)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	migrated := 0
+	errs := make([]error, 0)
+	for _, index := range indices {
+		didMigrate, err := MigrateIndex(index, client, logger)
+		if err != nil {
+			logger.Errorln(err.Error())
+			errs = append(errs, err)
+		} else if didMigrate {
+			migrated++
+		}
+	}
+	return migrated, errs
+}
+
+// MigrateIndex brings a tenant's batches index up to the current schema version. Writes to the index are blocked while
+// the batches are copied to a temporary index created from the current template. The index is then replaced by a
+// clone of the temporary index, which has all the batches as soon as it's created, so the index never accepts writes
+// before it's migrated. Only the writes between the deletion of the index and its clone fail. If a step fails while
+// the index still exists, the migration is undone. After that the temporary index is left in place, so no batches are
+// lost. The batches are migrated once the clone exists, so failing to delete the temporary index is only logged.
+func MigrateIndex(index string, client *elasticsearch.Client, logger logrus.FieldLogger) (bool, error) {
+	schemaVersion, elasticErr := GetSchemaVersion(index, client)
+	if elasticErr != nil {
+		return false, fmt.Errorf("unable to get the schema version of index %s: %w", index, elasticErr)
+	}
+	pending := PendingMigrations(schemaVersion)
+	if len(pending) == 0 {
+		logger.Debugf("Index %s is up to date at schema version %d", index, schemaVersion)
+		return false, nil
+	}
+
+	template, err := GetIndexTemplate(BatchesTemplateName)
+	if err != nil {
+		return false, err
+	}
+	indexBody, err := template.IndexBody()
+	if err != nil {
+		return false, err
+	}
+
+	scripts := make([]string, 0, len(pending))
+	for _, migration := range pending {
+		logger.Infof("Migrating index %s to schema version %d: %s", index, migration.Version, migration.Description)
+		if migration.Script != "" {
+			scripts = append(scripts, "{"+migration.Script+"}")
+		}
+	}
+
+	tempIndex := index + migrationIndexSuffix
+	steps := []struct {
+		description string
+		run         func() *ResponseError
+	}{
+		{"block writes to " + index, func() *ResponseError {
+			return blockWrites(index, client)
+		}},
+		{"create " + tempIndex, func() *ResponseError {
+			return createIndex(tempIndex, indexBody, client)
+		}},
+		{"copy the batches of " + index + " to " + tempIndex, func() *ResponseError {
+			return reindex(index, tempIndex, strings.Join(scripts, " "), client)
+		}},
+		{"block writes to " + tempIndex, func() *ResponseError {
+			// an index can only be cloned once it's read-only
+			return blockWrites(tempIndex, client)
+		}},
+		{"delete " + index, func() *ResponseError {
+			return deleteIndex(index, client)
+		}},
+		{"recreate " + index + " as a clone of " + tempIndex, func() *ResponseError {
+			return cloneIndex(tempIndex, index, client)
+		}},
+		{"delete " + tempIndex, func() *ResponseError {
+			return deleteIndex(tempIndex, client)
+		}},
+	}
+	for i, step := range steps {
+		elasticErr := step.run()
+		if elasticErr == nil {
+			continue
+		}
+		if i == tempDeletedStep {
+			logger.Warnf("Migrated index %s, but unable to %s: %s", index, step.description, elasticErr.Error())
+			break
+		}
+		if i <= originalDeletedStep && originalIntact(i, index, client, logger) {
+			unblockWrites(index, client, logger)
+			if i > 1 {
+				deleteIndex(tempIndex, client)
+			}
+		}
+		return false, fmt.Errorf("migration of index %s failed, unable to %s: %w", index, step.description, elasticErr)
+	}
+
+	logger.Infof("Migrated index %s from schema version %d to %d", index, schemaVersion, template.Version)
+	return true, nil
+}
+
+// originalIntact returns whether the original index is still there after the migration failed at the given step, so
+// the migration can be undone. A failed delete usually leaves the index in place, but that has to be checked.
+func originalIntact(failedStep int, index string, client *elasticsearch.Client, logger logrus.FieldLogger) bool {
+	if failedStep < originalDeletedStep {
+		return true
+	}
+	exists, err := IndexExists(context.Background(), index, client)
+	if err != nil {
+		logger.Errorf("Unable to check if index %s still exists, the migration isn't undone: %s", index, err.Error())
+		return false
+	}
+	return exists
+}
+
+func blockWrites(index string, client *elasticsearch.Client) *ResponseError {
+	res, err := client.Indices.PutSettings(
+		strings.NewReader(`{"index.blocks.write":true}`),
+		client.Indices.PutSettings.WithIndex(index),
+		client.Indices.PutSettings.WithContext(context.Background()),
+	)
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
+
+func unblockWrites(index string, client *elasticsearch.Client, logger logrus.FieldLogger) {
+	res, err := client.Indices.PutSettings(
+		strings.NewReader(`{"index.blocks.write":null}`),
+		client.Indices.PutSettings.WithIndex(index),
+		client.Indices.PutSettings.WithContext(context.Background()),
+	)
+	if _, elasticErr := DecodeBody(res, err); elasticErr != nil {
+		logger.Errorf("Unable to unblock writes to index %s: %s", index, elasticErr.Error())
+	}
+}
+
+// cloneIndex creates the target index with the source's mappings and batches, without the source's write block
+func cloneIndex(source string, target string, client *elasticsearch.Client) *ResponseError {
+	res, err := client.Indices.Clone(
+		source,
+		target,
+		client.Indices.Clone.WithBody(strings.NewReader(`{"settings":{"index.blocks.write":null}}`)),
+		client.Indices.Clone.WithContext(context.Background()),
+	)
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
+
+func createIndex(index string, body []byte, client *elasticsearch.Client) *ResponseError {
+	res, err := client.Indices.Create(
+		index,
+		client.Indices.Create.WithBody(bytes.NewReader(body)),
+		client.Indices.Create.WithContext(context.Background()),
+	)
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
+
+func deleteIndex(index string, client *elasticsearch.Client) *ResponseError {
+	res, err := client.Indices.Delete([]string{index}, client.Indices.Delete.WithContext(context.Background()))
+	_, elasticErr := DecodeBody(res, err)
+	return elasticErr
+}
+
+func reindex(source string, dest string, script string, client *elasticsearch.Client) *ResponseError {
+	request := map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": dest},
+	}
+	if script != "" {
+		request["script"] = map[string]interface{}{"source": script, "lang": "painless"}
+	}
+	buf, err := EncodeQueryBody(request)
+	if err != nil {
+		return &ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Reindex(
+		buf,
+		client.Reindex.WithContext(context.Background()),
+		client.Reindex.WithRefresh(true),
+		client.Reindex.WithWaitForCompletion(true),
+	)
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		return elasticErr
+	}
+	if failures, _ := body["failures"].([]interface{}); len(failures) > 0 {
+		return &ResponseError{ErrorObj: fmt.Errorf("%d batches could not be copied: %v", len(failures), failures[0]), Code: http.StatusInternalServerError}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestPendingMigrations(t *testing.T) {
+	assert.Equal(t, batchesMigrations, PendingMigrations(unversionedSchema))
+	assert.Empty(t, PendingMigrations(batchesMigrations[len(batchesMigrations)-1].Version))
+}
+
+func TestGetSchemaVersion(t *testing.T) {
+	index := "tenant1-batches"
+	mappingPath := "/" + index + "/_mapping"
+
+	testCases := []struct {
+		name            string
+		transport       *test.FakeTransport
+		expectedVersion int
+		expectedErr     string
+	}{
+		{
+			name: "versioned",
+			transport: test.NewFakeTransport(t).AddCall(mappingPath, test.ElasticCall{
+				ResponseBody: `{"tenant1-batches":{"mappings":{"_meta":{"schemaVersion":2},"properties":{}}}}`,
+			}),
+			expectedVersion: 2,
+		},
+		{
+			name: "unversioned",
+			transport: test.NewFakeTransport(t).AddCall(mappingPath, test.ElasticCall{
+				ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+			}),
+			expectedVersion: unversionedSchema,
+		},
+		{
+			name: "client error",
+			transport: test.NewFakeTransport(t).AddCall(mappingPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErr: "elasticsearch client error: connection refused",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			version, elasticErr := GetSchemaVersion(index, client)
+			if tc.expectedErr == "" {
+				assert.Nil(t, elasticErr)
+				assert.Equal(t, tc.expectedVersion, version)
+			} else if assert.NotNil(t, elasticErr) {
+				assert.Equal(t, tc.expectedErr, elasticErr.Error())
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestMigrateTenantIndices(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	logger := logwrapper.GetMyLogger("", "elastic/TestMigrateTenantIndices")
+
+	catPath := "/_cat/indices/*-batches"
+	oldIndex := "tenant1-batches"
+	tempIndex := oldIndex + migrationIndexSuffix
+	currentMapping := `{"tenant2-batches":{"mappings":{"_meta":{"schemaVersion":3}}}}`
+	acknowledged := `{"acknowledged":true}`
+
+	testCases := []struct {
+		name             string
+		transport        *test.FakeTransport
+		expectedMigrated int
+		expectedErrs     []string
+		expectedOutdated []string
+	}{
+		{
+			name: "migrates outdated index",
+			transport: test.NewFakeTransport(t).
+				AddCall(catPath, test.ElasticCall{
+					ResponseBody: `[{"index":"tenant2-batches"},{"index":"tenant1-batches"}]`,
+				}).
+				AddCall("/"+oldIndex+"/_mapping", test.ElasticCall{
+					ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":true}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{
+					RequestBody:  `"_meta":{"schemaVersion":3}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/_reindex", test.ElasticCall{
+					RequestQuery: "refresh=true&wait_for_completion=true",
+					RequestBody:  `"dest":{"index":"tenant1-batches-migration"},"script":{"lang":"painless","source":"{if \(ctx._source.recordCount != null\)`,
+					ResponseBody: `{"total":2,"created":2,"failures":[]}`,
+				}).
+				AddCall("/"+tempIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":true}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+oldIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex+"/_clone/"+oldIndex, test.ElasticCall{
+					RequestBody:  `{"settings":{"index.blocks.write":null}}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/tenant2-batches/_mapping", test.ElasticCall{ResponseBody: currentMapping}),
+			expectedMigrated: 1,
+			expectedErrs:     []string{},
+		},
+		{
+			name: "migrations without a script only reindex",
+			transport: test.NewFakeTransport(t).
+				AddCall(catPath, test.ElasticCall{ResponseBody: `[{"index":"tenant1-batches"}]`}).
+				AddCall("/"+oldIndex+"/_mapping", test.ElasticCall{
+					ResponseBody: `{"tenant1-batches":{"mappings":{"_meta":{"schemaVersion":2},"properties":{}}}}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":true}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{
+					RequestBody:  `"legalHold":{"properties":{"actor":{"type":"keyword"}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/_reindex", test.ElasticCall{
+					RequestQuery: "refresh=true&wait_for_completion=true",
+					RequestBody:  `"source":{"index":"tenant1-batches"}}\s*$`,
+					ResponseBody: `{"total":2,"created":2,"failures":[]}`,
+				}).
+				AddCall("/"+tempIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":true}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+oldIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex+"/_clone/"+oldIndex, test.ElasticCall{
+					RequestBody:  `{"settings":{"index.blocks.write":null}}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}),
+			expectedMigrated: 1,
+			expectedErrs:     []string{},
+		},
+		{
+			name: "reindex failure is undone",
+			transport: test.NewFakeTransport(t).
+				AddCall(catPath, test.ElasticCall{ResponseBody: `[{"index":"tenant1-batches"}]`}).
+				AddCall("/"+oldIndex+"/_mapping", test.ElasticCall{
+					ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":true}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/_reindex", test.ElasticCall{
+					ResponseBody: `{"total":1,"created":0,"failures":[{"id":"batch1"}]}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":null}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}),
+			expectedMigrated: 0,
+			expectedErrs: []string{"migration of index tenant1-batches failed, unable to copy the batches of " +
+				"tenant1-batches to tenant1-batches-migration: 1 batches could not be copied: map[id:batch1]"},
+		},
+		{
+			name: "clone failure keeps the temporary index",
+			transport: test.NewFakeTransport(t).
+				AddCall(catPath, test.ElasticCall{ResponseBody: `[{"index":"tenant1-batches"}]`}).
+				AddCall("/"+oldIndex+"/_mapping", test.ElasticCall{
+					ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/_reindex", test.ElasticCall{ResponseBody: `{"total":2,"created":2,"failures":[]}`}).
+				AddCall("/"+tempIndex+"/_settings", test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+oldIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex+"/_clone/"+oldIndex, test.ElasticCall{
+					ResponseStatusCode: http.StatusBadRequest,
+					ResponseBody:       `{"error":{"type":"resource_already_exists_exception","reason":"index [tenant1-batches] already exists"},"status":400}`,
+				}),
+			expectedMigrated: 0,
+			expectedErrs: []string{"migration of index tenant1-batches failed, unable to recreate tenant1-batches as a clone of " +
+				"tenant1-batches-migration: resource_already_exists_exception: index [tenant1-batches] already exists"},
+		},
+		{
+			name: "delete failure is undone while the index still exists",
+			transport: test.NewFakeTransport(t).
+				AddCall(catPath, test.ElasticCall{ResponseBody: `[{"index":"tenant1-batches"}]`}).
+				AddCall("/"+oldIndex+"/_mapping", test.ElasticCall{
+					ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/_reindex", test.ElasticCall{ResponseBody: `{"total":2,"created":2,"failures":[]}`}).
+				AddCall("/"+tempIndex+"/_settings", test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+oldIndex, test.ElasticCall{
+					ResponseStatusCode: http.StatusInternalServerError,
+					ResponseBody:       `{"error":{"type":"process_cluster_event_timeout_exception","reason":"failed to process cluster event"},"status":500}`,
+				}).
+				// the index still exists
+				AddCall("/"+oldIndex, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{
+					RequestBody:  `{"index.blocks.write":null}`,
+					ResponseBody: acknowledged,
+				}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}),
+			expectedMigrated: 0,
+			expectedErrs: []string{"migration of index tenant1-batches failed, unable to delete tenant1-batches: " +
+				"process_cluster_event_timeout_exception: failed to process cluster event"},
+		},
+		{
+			name: "failing to delete the temporary index is only a warning",
+			transport: test.NewFakeTransport(t).
+				AddCall(catPath, test.ElasticCall{ResponseBody: `[{"index":"tenant1-batches"}]`}).
+				AddCall("/"+oldIndex+"/_mapping", test.ElasticCall{
+					ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+				}).
+				AddCall("/"+oldIndex+"/_settings", test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/_reindex", test.ElasticCall{ResponseBody: `{"total":2,"created":2,"failures":[]}`}).
+				AddCall("/"+tempIndex+"/_settings", test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+oldIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex+"/_clone/"+oldIndex, test.ElasticCall{ResponseBody: acknowledged}).
+				AddCall("/"+tempIndex, test.ElasticCall{ResponseErr: errors.New("connection refused")}),
+			expectedMigrated: 1,
+			expectedErrs:     []string{},
+		},
+		{
+			name: "list error",
+			transport: test.NewFakeTransport(t).AddCall(catPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErrs: []string{"unable to list the tenant indices: elasticsearch client error: connection refused"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			migrated, errs := MigrateTenantIndices(client, logger)
+			assert.Equal(t, tc.expectedMigrated, migrated)
+			errStrings := make([]string, 0, len(errs))
+			for _, err := range errs {
+				errStrings = append(errStrings, err.Error())
+			}
+			assert.Equal(t, tc.expectedErrs, errStrings)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestOutdatedTenantIndices(t *testing.T) {
+	transport := test.NewFakeTransport(t).
+		AddCall("/_cat/indices/*-batches", test.ElasticCall{
+			ResponseBody: `[{"index":"tenant2-batches"},{"index":"tenant1-batches"}]`,
+		}).
+		AddCall("/tenant1-batches/_mapping", test.ElasticCall{
+			ResponseBody: `{"tenant1-batches":{"mappings":{"properties":{}}}}`,
+		}).
+		AddCall("/tenant2-batches/_mapping", test.ElasticCall{
+			ResponseBody: `{"tenant2-batches":{"mappings":{"_meta":{"schemaVersion":3}}}}`,
+		})
+	client, err := ClientFromTransport(transport)
+	assert.NoError(t, err)
+
+	outdated, err := OutdatedTenantIndices(client)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tenant1-batches"}, outdated)
+	transport.VerifyCalls()
+}
@@ -0,0 +1,171 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// The index templates determine how batches and tenant configurations are stored and indexed. Each template has a
+// version, which is also stored in the '_meta.schemaVersion' of the mapping of every index created from it. Whenever
+// a template's fields change, increment both and add a Migration for the existing indices.
+//
+//go:embed templates/*.json
+var templateFiles embed.FS
+
+const BatchesTemplateName = "batches"
+
+// IndexTemplate is one of the embedded composable index templates
+type IndexTemplate struct {
+	Name    string
+	Version int
+	body    []byte
+}
+
+type templateDoc struct {
+	Version  int `json:"version"`
+	Template struct {
+		Settings map[string]interface{} `json:"settings"`
+		Mappings map[string]interface{} `json:"mappings"`
+	} `json:"template"`
+}
+
+// IndexTemplates returns the embedded index templates sorted by name
+func IndexTemplates() ([]IndexTemplate, error) {
+	entries, err := templateFiles.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]IndexTemplate, 0, len(entries))
+	for _, entry := range entries {
+		body, err := templateFiles.ReadFile(path.Join("templates", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		doc, err := parseTemplate(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index template %s: %w", entry.Name(), err)
+		}
+		templates = append(templates, IndexTemplate{
+			Name:    strings.TrimSuffix(entry.Name(), ".json"),
+			Version: doc.Version,
+			body:    body,
+		})
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// GetIndexTemplate returns the embedded index template with the given name
+func GetIndexTemplate(name string) (IndexTemplate, error) {
+	templates, err := IndexTemplates()
+	if err != nil {
+		return IndexTemplate{}, err
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return template, nil
+		}
+	}
+	return IndexTemplate{}, fmt.Errorf("unknown index template: %s", name)
+}
+
+// IndexBody returns the settings and mappings of the template, as the body of an index creation request. This is
+// needed for indices that don't match the template's index patterns.
+func (t IndexTemplate) IndexBody() ([]byte, error) {
+	doc, err := parseTemplate(t.body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"settings": doc.Template.Settings,
+		"mappings": doc.Template.Mappings,
+	})
+}
+
+// InstallIndexTemplates creates or updates the index templates in Elastic. Templates that are installed with the
+// same or a newer version are left alone, so an older server can't downgrade them. Returns the names of the
+// templates that were installed.
+func InstallIndexTemplates(client *elasticsearch.Client, logger logrus.FieldLogger) ([]string, error) {
+	templates, err := IndexTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	installed := make([]string, 0, len(templates))
+	for _, template := range templates {
+		currentVersion, elasticErr := installedTemplateVersion(template.Name, client)
+		if elasticErr != nil {
+			return installed, fmt.Errorf("unable to get index template %s: %w", template.Name, elasticErr)
+		}
+		if currentVersion >= template.Version {
+			logger.Debugf("Index template %s is up to date at version %d", template.Name, currentVersion)
+			continue
+		}
+
+		res, err := client.Indices.PutIndexTemplate(
+			template.Name,
+			bytes.NewReader(template.body),
+			client.Indices.PutIndexTemplate.WithContext(context.Background()),
+		)
+		if _, elasticErr := DecodeBody(res, err); elasticErr != nil {
+			return installed, fmt.Errorf("unable to install index template %s: %w", template.Name, elasticErr)
+		}
+		logger.Infof("Installed index template %s version %d, previous version %d",
+			template.Name, template.Version, currentVersion)
+		installed = append(installed, template.Name)
+	}
+	return installed, nil
+}
+
+// returns 0 when the template isn't installed
+func installedTemplateVersion(name string, client *elasticsearch.Client) (int, *ResponseError) {
+	res, err := client.Indices.GetIndexTemplate(
+		client.Indices.GetIndexTemplate.WithName(name),
+		client.Indices.GetIndexTemplate.WithContext(context.Background()),
+	)
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		if elasticErr.Code == http.StatusNotFound {
+			return 0, nil
+		}
+		return 0, elasticErr
+	}
+
+	templates, _ := body["index_templates"].([]interface{})
+	for _, entry := range templates {
+		entryMap, _ := entry.(map[string]interface{})
+		if entryMap["name"] != name {
+			continue
+		}
+		template, _ := entryMap["index_template"].(map[string]interface{})
+		version, _ := template["version"].(float64)
+		return int(version), nil
+	}
+	return 0, nil
+}
+
+func parseTemplate(body []byte) (templateDoc, error) {
+	doc := templateDoc{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return doc, err
+	}
+	if doc.Version < 1 {
+		return doc, fmt.Errorf("missing version")
+	}
+	return doc, nil
+}
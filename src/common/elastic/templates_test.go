@@ -0,0 +1,162 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestIndexTemplates(t *testing.T) {
+	templates, err := IndexTemplates()
+	assert.NoError(t, err)
+	if assert.Len(t, templates, 4) {
+		assert.Equal(t, "api-keys", templates[0].Name)
+		assert.Equal(t, BatchesTemplateName, templates[1].Name)
+		assert.Equal(t, "legal-hold-audit", templates[2].Name)
+		assert.Equal(t, "tenants", templates[3].Name)
+	}
+
+	for _, template := range templates {
+		t.Run(template.Name, func(t *testing.T) {
+			// the schema version of new indices must match the template version
+			body, err := template.IndexBody()
+			assert.NoError(t, err)
+			index := map[string]map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal(body, &index))
+			meta, _ := index["mappings"]["_meta"].(map[string]interface{})
+			assert.Equal(t, float64(template.Version), meta["schemaVersion"])
+			assert.NotNil(t, index["settings"])
+		})
+	}
+
+	// every version change of the batches template needs a migration
+	batches, err := GetIndexTemplate(BatchesTemplateName)
+	assert.NoError(t, err)
+	assert.Equal(t, batches.Version, batchesMigrations[len(batchesMigrations)-1].Version)
+
+	_, err = GetIndexTemplate("missing")
+	assert.EqualError(t, err, "unknown index template: missing")
+}
+
+func TestInstallIndexTemplates(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	logger := logwrapper.GetMyLogger("", "elastic/TestInstallIndexTemplates")
+
+	apiKeysPath := "/_index_template/api-keys"
+	batchesPath := "/_index_template/" + BatchesTemplateName
+	auditPath := "/_index_template/legal-hold-audit"
+	tenantsPath := "/_index_template/tenants"
+	installed := func(name string, version int) string {
+		return `{"index_templates":[{"name":"` + name + `","index_template":{"version":` + strconv.Itoa(version) + `}}]}`
+	}
+
+	testCases := []struct {
+		name              string
+		transport         *test.FakeTransport
+		expectedInstalled []string
+		expectedErr       string
+	}{
+		{
+			name: "not installed",
+			transport: test.NewFakeTransport(t).
+				AddCall(apiKeysPath, test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       `{"error":{"type":"resource_not_found_exception","reason":"index template matching [api-keys] not found"},"status":404}`,
+				}).
+				AddCall(apiKeysPath, test.ElasticCall{
+					RequestBody:  `"index_patterns": \["hri-api-keys"\]`,
+					ResponseBody: `{"acknowledged":true}`,
+				}).
+				AddCall(batchesPath, test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       `{"error":{"type":"resource_not_found_exception","reason":"index template matching [batches] not found"},"status":404}`,
+				}).
+				AddCall(batchesPath, test.ElasticCall{
+					RequestBody:  `"index_patterns": \["\*-batches"\]`,
+					ResponseBody: `{"acknowledged":true}`,
+				}).
+				AddCall(auditPath, test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       `{"error":{"type":"resource_not_found_exception","reason":"index template matching [legal-hold-audit] not found"},"status":404}`,
+				}).
+				AddCall(auditPath, test.ElasticCall{
+					RequestBody:  `"index_patterns": \["hri-legal-hold-audit"\]`,
+					ResponseBody: `{"acknowledged":true}`,
+				}).
+				AddCall(tenantsPath, test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       `{"error":{"type":"resource_not_found_exception","reason":"index template matching [tenants] not found"},"status":404}`,
+				}).
+				AddCall(tenantsPath, test.ElasticCall{
+					RequestBody:  `"index_patterns": \["hri-tenants"\]`,
+					ResponseBody: `{"acknowledged":true}`,
+				}),
+			expectedInstalled: []string{"api-keys", BatchesTemplateName, "legal-hold-audit", "tenants"},
+		},
+		{
+			name: "older version installed",
+			transport: test.NewFakeTransport(t).
+				AddCall(apiKeysPath, test.ElasticCall{ResponseBody: installed("api-keys", 1)}).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: installed(BatchesTemplateName, 1)}).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: `{"acknowledged":true}`}).
+				AddCall(auditPath, test.ElasticCall{ResponseBody: installed("legal-hold-audit", 1)}).
+				AddCall(tenantsPath, test.ElasticCall{ResponseBody: installed("tenants", 2)}),
+			expectedInstalled: []string{BatchesTemplateName},
+		},
+		{
+			name: "newer version installed",
+			transport: test.NewFakeTransport(t).
+				AddCall(apiKeysPath, test.ElasticCall{ResponseBody: installed("api-keys", 2)}).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: installed(BatchesTemplateName, 3)}).
+				AddCall(auditPath, test.ElasticCall{ResponseBody: installed("legal-hold-audit", 2)}).
+				AddCall(tenantsPath, test.ElasticCall{ResponseBody: installed("tenants", 3)}),
+			expectedInstalled: []string{},
+		},
+		{
+			name: "get error",
+			transport: test.NewFakeTransport(t).
+				AddCall(apiKeysPath, test.ElasticCall{ResponseErr: errors.New("connection refused")}),
+			expectedInstalled: []string{},
+			expectedErr:       "unable to get index template api-keys: elasticsearch client error: connection refused",
+		},
+		{
+			name: "put error",
+			transport: test.NewFakeTransport(t).
+				AddCall(apiKeysPath, test.ElasticCall{ResponseBody: installed("api-keys", 1)}).
+				AddCall(batchesPath, test.ElasticCall{ResponseBody: installed(BatchesTemplateName, 1)}).
+				AddCall(batchesPath, test.ElasticCall{
+					ResponseStatusCode: http.StatusBadRequest,
+					ResponseBody:       `{"error":{"type":"illegal_argument_exception","reason":"bad mapping"},"status":400}`,
+				}),
+			expectedInstalled: []string{},
+			expectedErr:       "unable to install index template batches: illegal_argument_exception: bad mapping",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			names, err := InstallIndexTemplates(client, logger)
+			assert.Equal(t, tc.expectedInstalled, names)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
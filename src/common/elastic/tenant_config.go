@@ -0,0 +1,87 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+)
+
+// TenantsIndex holds one configuration document per tenant, with the tenant id as the document id
+const TenantsIndex = "hri-tenants"
+
+const maxTenantConfigs = 10000
+
+// GetTenantConfig returns the tenant's configuration document. Tenants without one get an empty configuration, which
+// leaves every setting at the server default.
+func GetTenantConfig(ctx context.Context, tenantId string, client *elasticsearch.Client) (model.TenantConfig, *ResponseError) {
+	res, err := client.Get(TenantsIndex, tenantId, client.Get.WithContext(ctx))
+
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		// the document or the whole index not existing both mean the tenant has no configuration
+		if elasticErr.Code == http.StatusNotFound {
+			return model.TenantConfig{TenantId: tenantId}, nil
+		}
+		return model.TenantConfig{}, elasticErr
+	}
+
+	return tenantConfigFromSource(tenantId, body["_source"])
+}
+
+// GetTenantConfigs returns the configuration documents that match the query, i.e. all the tenants with a batch timeout
+func GetTenantConfigs(ctx context.Context, query map[string]interface{}, client *elasticsearch.Client) ([]model.TenantConfig, *ResponseError) {
+	buf, err := EncodeQueryBody(query)
+	if err != nil {
+		return nil, &ResponseError{ErrorObj: fmt.Errorf("error encoding Elastic query: %w", err), Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Search(
+		client.Search.WithContext(ctx),
+		client.Search.WithIndex(TenantsIndex),
+		client.Search.WithBody(buf),
+		client.Search.WithSize(maxTenantConfigs),
+	)
+
+	body, elasticErr := DecodeBody(res, err)
+	if elasticErr != nil {
+		if elasticErr.Code == http.StatusNotFound {
+			return []model.TenantConfig{}, nil
+		}
+		return nil, elasticErr
+	}
+
+	hits := body["hits"].(map[string]interface{})["hits"].([]interface{})
+	configs := make([]model.TenantConfig, 0, len(hits))
+	for _, hit := range hits {
+		doc := hit.(map[string]interface{})
+		tenantConfig, elasticErr := tenantConfigFromSource(doc["_id"].(string), doc["_source"])
+		if elasticErr != nil {
+			return nil, elasticErr
+		}
+		configs = append(configs, tenantConfig)
+	}
+	return configs, nil
+}
+
+func tenantConfigFromSource(tenantId string, source interface{}) (model.TenantConfig, *ResponseError) {
+	tenantConfig := model.TenantConfig{}
+	encoded, err := json.Marshal(source)
+	if err == nil {
+		err = json.Unmarshal(encoded, &tenantConfig)
+	}
+	if err != nil {
+		err = fmt.Errorf("error parsing the configuration of tenant [%s]: %w", tenantId, err)
+		return model.TenantConfig{}, &ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+	}
+
+	tenantConfig.TenantId = tenantId
+	return tenantConfig, nil
+}
@@ -0,0 +1,143 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package elastic
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestGetTenantConfig(t *testing.T) {
+	tenantId := "tenant123"
+	configPath := "/" + TenantsIndex + "/_doc/" + tenantId
+	timeout := 3600
+
+	testCases := []struct {
+		name           string
+		transport      *test.FakeTransport
+		expectedConfig model.TenantConfig
+		expectedErr    *ResponseError
+	}{
+		{
+			name: "found",
+			transport: test.NewFakeTransport(t).AddCall(configPath, test.ElasticCall{
+				ResponseBody: `{"_id":"tenant123","found":true,"_source":{"allowedDataTypes":["claims"],"batchTimeoutSeconds":3600}}`,
+			}),
+			expectedConfig: model.TenantConfig{
+				TenantId:            tenantId,
+				AllowedDataTypes:    []string{"claims"},
+				BatchTimeoutSeconds: &timeout,
+			},
+		},
+		{
+			name: "not found",
+			transport: test.NewFakeTransport(t).AddCall(configPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusNotFound,
+				ResponseBody:       `{"_id":"tenant123","found":false}`,
+			}),
+			expectedConfig: model.TenantConfig{TenantId: tenantId},
+		},
+		{
+			name: "bad source",
+			transport: test.NewFakeTransport(t).AddCall(configPath, test.ElasticCall{
+				ResponseBody: `{"_id":"tenant123","found":true,"_source":{"allowedDataTypes":"claims"}}`,
+			}),
+			expectedErr: &ResponseError{
+				ErrorObj: errors.New("error parsing the configuration of tenant [tenant123]: json: cannot unmarshal string into Go struct field TenantConfig.allowedDataTypes of type []string"),
+				Code:     http.StatusInternalServerError,
+			},
+		},
+		{
+			name: "client error",
+			transport: test.NewFakeTransport(t).AddCall(configPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErr: &ResponseError{
+				ErrorObj: errors.New("elasticsearch client error: connection refused"),
+				Code:     http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			tenantConfig, elasticErr := GetTenantConfig(context.Background(), tenantId, client)
+			if tc.expectedErr == nil {
+				assert.Nil(t, elasticErr)
+				assert.Equal(t, tc.expectedConfig, tenantConfig)
+			} else if assert.NotNil(t, elasticErr) {
+				assert.Equal(t, tc.expectedErr.Code, elasticErr.Code)
+				assert.Equal(t, tc.expectedErr.ErrorObj.Error(), elasticErr.ErrorObj.Error())
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestGetTenantConfigs(t *testing.T) {
+	searchPath := "/" + TenantsIndex + "/_search"
+	query := map[string]interface{}{"query": map[string]interface{}{"exists": map[string]interface{}{"field": "batchTimeoutSeconds"}}}
+	timeout := 600
+
+	testCases := []struct {
+		name            string
+		transport       *test.FakeTransport
+		expectedConfigs []model.TenantConfig
+		expectedErrCode int
+	}{
+		{
+			name: "found",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				RequestQuery: "size=10000",
+				RequestBody:  `{"query":{"exists":{"field":"batchTimeoutSeconds"}}}`,
+				ResponseBody: `{"hits":{"hits":[{"_id":"tenant1","_source":{"batchTimeoutSeconds":600}},{"_id":"tenant2","_source":{}}]}}`,
+			}),
+			expectedConfigs: []model.TenantConfig{
+				{TenantId: "tenant1", BatchTimeoutSeconds: &timeout},
+				{TenantId: "tenant2"},
+			},
+		},
+		{
+			name: "no tenants index",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusNotFound,
+				ResponseBody:       `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`,
+			}),
+			expectedConfigs: []model.TenantConfig{},
+		},
+		{
+			name: "client error",
+			transport: test.NewFakeTransport(t).AddCall(searchPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedErrCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			configs, elasticErr := GetTenantConfigs(context.Background(), query, client)
+			if tc.expectedErrCode == 0 {
+				assert.Nil(t, elasticErr)
+				assert.Equal(t, tc.expectedConfigs, configs)
+			} else if assert.NotNil(t, elasticErr) {
+				assert.Equal(t, tc.expectedErrCode, elasticErr.Code)
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
@@ -5,22 +5,13 @@
  */
 package eventstreams
 
-import "strings"
-
-const (
-	TopicPrefix        string = "ingest."
-	InSuffix           string = ".in"
-	NotificationSuffix string = ".notification"
-	OutSuffix          string = ".out"
-	InvalidSuffix      string = ".invalid"
-)
-
+// CreateTopicNames returns the in, notification, out and invalid topic names of a stream, using the naming
+// convention the server was initialized with.
 func CreateTopicNames(tenantId string, streamId string) (string, string, string, string) {
-	// See documentation on HRI topic naming conventions: https://alvearie.io/HRI/admin.html#onboarding-new-data-integrators
-	baseTopicName := strings.Join([]string{tenantId, streamId}, ".")
-	inTopicName := TopicPrefix + baseTopicName + InSuffix
-	notificationTopicName := TopicPrefix + baseTopicName + NotificationSuffix
-	outTopicName := TopicPrefix + baseTopicName + OutSuffix
-	invalidTopicName := TopicPrefix + baseTopicName + InvalidSuffix
+	naming := GetTopicNaming()
+	inTopicName := naming.TopicName(tenantId, streamId, InTopic)
+	notificationTopicName := naming.TopicName(tenantId, streamId, NotificationTopic)
+	outTopicName := naming.TopicName(tenantId, streamId, OutTopic)
+	invalidTopicName := naming.TopicName(tenantId, streamId, InvalidTopic)
 	return inTopicName, notificationTopicName, outTopicName, invalidTopicName
 }
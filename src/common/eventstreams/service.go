@@ -7,6 +7,7 @@ package eventstreams
 
 import (
 	"context"
+	"encoding/base64"
 	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
 	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
 	"net/http"
@@ -45,7 +46,27 @@ func (connect EventStreamsConnect) HandleModelError(err error) *es.ModelError {
 func CreateServiceFromConfig(config configPkg.Config, bearerToken string) Service {
 	esConfig := es.NewConfiguration()
 	esConfig.BasePath = config.KafkaAdminUrl
-	esConfig.AddDefaultHeader(bearerTokenHeader, bearerToken)
+	if bearerToken != "" {
+		esConfig.AddDefaultHeader(bearerTokenHeader, bearerToken)
+	}
 	client := es.NewAPIClient(esConfig)
 	return EventStreamsConnect{client.DefaultApi}
 }
+
+// CreateServiceForCaller creates the service that manages topics for a caller of the HRI. In the iam admin auth mode
+// the caller's IAM token is passed on to Event Streams. In the jwt mode the caller has an HRI token, which Event
+// Streams doesn't accept, so the configured API key is used instead.
+func CreateServiceForCaller(config configPkg.Config, authorization string) Service {
+	if config.AdminAuthMode == configPkg.AdminAuthModeJwt {
+		return CreateServiceFromConfig(config, apiKeyAuthorization(config.KafkaAdminApiKey))
+	}
+	return CreateServiceFromConfig(config, authorization)
+}
+
+// Event Streams accepts API keys as the password of basic authentication, with the user name 'token'
+func apiKeyAuthorization(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte("token:"+apiKey))
+}
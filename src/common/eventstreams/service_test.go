@@ -28,6 +28,16 @@ func TestCreateEventstreamsService(t *testing.T) {
 	assert.NotNil(t, service)
 }
 
+func TestCreateServiceForCaller(t *testing.T) {
+	assert.NotNil(t, CreateServiceForCaller(config.Config{}, "Bearer token"))
+	assert.NotNil(t, CreateServiceForCaller(config.Config{AdminAuthMode: config.AdminAuthModeJwt}, "Bearer token"))
+}
+
+func TestApiKeyAuthorization(t *testing.T) {
+	assert.Equal(t, "Basic dG9rZW46bXlBcGlLZXk=", apiKeyAuthorization("myApiKey"))
+	assert.Equal(t, "", apiKeyAuthorization(""))
+}
+
 func TestHandleModelErrorNil(t *testing.T) {
 	config := config.Config{
 		ConfigPath:      "",
@@ -0,0 +1,189 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package eventstreams
+
+import (
+	"errors"
+	"fmt"
+	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
+	"regexp"
+	"strings"
+)
+
+// Placeholders that a topic name template must contain exactly once
+const (
+	TenantIdPlaceholder      string = "{tenantId}"
+	StreamIdPlaceholder      string = "{streamId}"
+	TopicTypePlaceholder     string = "{topicType}"
+	DefaultTopicNameTemplate string = configPkg.DefaultTopicNameTemplate
+)
+
+// TopicType identifies one of the topics that make up a stream. Its value is also the default name of the type.
+type TopicType string
+
+const (
+	InTopic           TopicType = "in"
+	NotificationTopic TopicType = "notification"
+	OutTopic          TopicType = "out"
+	InvalidTopic      TopicType = "invalid"
+)
+
+var topicTypes = []TopicType{InTopic, NotificationTopic, OutTopic, InvalidTopic}
+
+// Kafka topic names may only contain these characters
+var validTopicChars = regexp.MustCompile(`^[a-zA-Z0-9._-]*$`)
+
+// TopicNaming builds and parses stream topic names from a template such as "ingest.{tenantId}.{streamId}.{topicType}"
+type TopicNaming struct {
+	template  string
+	typeNames map[TopicType]string
+	// matches the topics of any tenant; it's compiled once because every batch notification uses it
+	anyTenantPattern *regexp.Regexp
+}
+
+var globalNaming = newTopicNaming(DefaultTopicNameTemplate, map[TopicType]string{
+	InTopic:           string(InTopic),
+	NotificationTopic: string(NotificationTopic),
+	OutTopic:          string(OutTopic),
+	InvalidTopic:      string(InvalidTopic),
+})
+
+// NewTopicNaming validates the template and the optional topic type name overrides (i.e. "in" -> "input").
+// The template must contain each placeholder exactly once, separated by at least one literal character, and
+// only characters that are valid in a Kafka topic name.
+func NewTopicNaming(template string, typeNames map[string]string) (TopicNaming, error) {
+	errorBuilder := strings.Builder{}
+	errorHeader := "Topic naming errors:"
+	errorBuilder.WriteString(errorHeader)
+
+	for _, placeholder := range []string{TenantIdPlaceholder, StreamIdPlaceholder, TopicTypePlaceholder} {
+		if count := strings.Count(template, placeholder); count != 1 {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tThe topic name template must contain %s exactly once, found %d",
+				placeholder, count))
+		}
+	}
+	if strings.Contains(template, "}{") {
+		errorBuilder.WriteString("\n\tThe topic name template placeholders must be separated by at least one character")
+	}
+	literals := strings.NewReplacer(TenantIdPlaceholder, "", StreamIdPlaceholder, "", TopicTypePlaceholder, "").Replace(template)
+	if !validTopicChars.MatchString(literals) {
+		errorBuilder.WriteString("\n\tThe topic name template may only contain the characters [a-zA-Z0-9._-] outside of placeholders: " + template)
+	}
+
+	names := make(map[TopicType]string)
+	seen := make(map[string]TopicType)
+	for _, topicType := range topicTypes {
+		names[topicType] = string(topicType)
+	}
+	for key, name := range typeNames {
+		topicType := TopicType(key)
+		if _, ok := names[topicType]; !ok {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tUnknown topic type '%s'; valid types are: in, notification, out, invalid", key))
+			continue
+		}
+		if name == "" || !validTopicChars.MatchString(name) {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tThe name of topic type '%s' may only contain the characters [a-zA-Z0-9._-] and can't be empty", key))
+			continue
+		}
+		names[topicType] = name
+	}
+	for _, topicType := range topicTypes {
+		if other, ok := seen[names[topicType]]; ok {
+			errorBuilder.WriteString(fmt.Sprintf("\n\tTopic types '%s' and '%s' have the same name '%s'", other, topicType, names[topicType]))
+		}
+		seen[names[topicType]] = topicType
+	}
+
+	errorMsg := errorBuilder.String()
+	if len(errorMsg) > len(errorHeader) {
+		return TopicNaming{}, errors.New(errorMsg)
+	}
+	return newTopicNaming(template, names), nil
+}
+
+func newTopicNaming(template string, typeNames map[TopicType]string) TopicNaming {
+	naming := TopicNaming{template: template, typeNames: typeNames}
+	naming.anyTenantPattern = naming.pattern(".+?")
+	return naming
+}
+
+// InitializeTopicNaming validates the naming convention and makes it the one used by the rest of the server
+func InitializeTopicNaming(template string, typeNames map[string]string) error {
+	naming, err := NewTopicNaming(template, typeNames)
+	if err != nil {
+		return err
+	}
+	globalNaming = naming
+	return nil
+}
+
+// GetTopicNaming returns the naming convention the server was initialized with
+func GetTopicNaming() TopicNaming {
+	return globalNaming
+}
+
+func (n TopicNaming) TopicName(tenantId string, streamId string, topicType TopicType) string {
+	replacer := strings.NewReplacer(
+		TenantIdPlaceholder, tenantId,
+		StreamIdPlaceholder, streamId,
+		TopicTypePlaceholder, n.typeNames[topicType],
+	)
+	return replacer.Replace(n.template)
+}
+
+// ParseTopicName returns the stream id and topic type of one of the tenant's topics. ok is false if the topic
+// doesn't follow the naming convention or belongs to a different tenant.
+func (n TopicNaming) ParseTopicName(topicName string, tenantId string) (streamId string, topicType TopicType, ok bool) {
+	return n.parse(topicName, regexp.QuoteMeta(tenantId))
+}
+
+// NotificationTopicName derives the notification topic from a stream's input topic. Input topics that don't
+// follow the naming convention have the input type suffix replaced, or the notification suffix appended when
+// they don't end with it, i.e. "<topic>.in" -> "<topic>.notification".
+func (n TopicNaming) NotificationTopicName(inputTopic string) string {
+	pattern := n.anyTenantPattern
+	if match := pattern.FindStringSubmatchIndex(inputTopic); match != nil {
+		// only the topic type part differs, so replacing the matched type name is enough
+		typeGroup := pattern.SubexpIndex("type")
+		typeStart, typeEnd := match[2*typeGroup], match[2*typeGroup+1]
+		if inputTopic[typeStart:typeEnd] == n.typeNames[InTopic] {
+			return inputTopic[:typeStart] + n.typeNames[NotificationTopic] + inputTopic[typeEnd:]
+		}
+	}
+	return strings.TrimSuffix(inputTopic, "."+n.typeNames[InTopic]) + "." + n.typeNames[NotificationTopic]
+}
+
+func (n TopicNaming) parse(topicName string, tenantPattern string) (string, TopicType, bool) {
+	pattern := n.pattern(tenantPattern)
+	match := pattern.FindStringSubmatch(topicName)
+	if match == nil {
+		return "", "", false
+	}
+
+	typeName := match[pattern.SubexpIndex("type")]
+	for _, topicType := range topicTypes {
+		if n.typeNames[topicType] == typeName {
+			return match[pattern.SubexpIndex("stream")], topicType, true
+		}
+	}
+	return "", "", false
+}
+
+// builds an anchored regex of the template, where each placeholder is a named group
+func (n TopicNaming) pattern(tenantPattern string) *regexp.Regexp {
+	typeNames := make([]string, 0, len(topicTypes))
+	for _, topicType := range topicTypes {
+		typeNames = append(typeNames, regexp.QuoteMeta(n.typeNames[topicType]))
+	}
+
+	quoted := regexp.QuoteMeta(n.template)
+	replacer := strings.NewReplacer(
+		regexp.QuoteMeta(TenantIdPlaceholder), "(?P<tenant>"+tenantPattern+")",
+		regexp.QuoteMeta(StreamIdPlaceholder), "(?P<stream>.+)",
+		regexp.QuoteMeta(TopicTypePlaceholder), "(?P<type>"+strings.Join(typeNames, "|")+")",
+	)
+	return regexp.MustCompile("^" + replacer.Replace(quoted) + "$")
+}
@@ -0,0 +1,197 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package eventstreams
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewTopicNaming(t *testing.T) {
+	tests := []struct {
+		name           string
+		template       string
+		typeNames      map[string]string
+		expectedErrMsg string
+	}{
+		{
+			name:     "default template",
+			template: DefaultTopicNameTemplate,
+		},
+		{
+			name:      "custom template and type names",
+			template:  "hri-{topicType}_{tenantId}.{streamId}",
+			typeNames: map[string]string{"in": "input", "notification": "notify"},
+		},
+		{
+			name:     "missing and repeated placeholders",
+			template: "ingest.{tenantId}.{tenantId}.{topicType}",
+			expectedErrMsg: "Topic naming errors:" +
+				"\n\tThe topic name template must contain {tenantId} exactly once, found 2" +
+				"\n\tThe topic name template must contain {streamId} exactly once, found 0",
+		},
+		{
+			name:     "adjacent placeholders and invalid characters",
+			template: "ingest/{tenantId}{streamId}.{topicType}",
+			expectedErrMsg: "Topic naming errors:" +
+				"\n\tThe topic name template placeholders must be separated by at least one character" +
+				"\n\tThe topic name template may only contain the characters [a-zA-Z0-9._-] outside of placeholders: ingest/{tenantId}{streamId}.{topicType}",
+		},
+		{
+			name:           "unknown topic type",
+			template:       DefaultTopicNameTemplate,
+			typeNames:      map[string]string{"errors": "err"},
+			expectedErrMsg: "Topic naming errors:\n\tUnknown topic type 'errors'; valid types are: in, notification, out, invalid",
+		},
+		{
+			name:           "invalid topic type name",
+			template:       DefaultTopicNameTemplate,
+			typeNames:      map[string]string{"out": "out/put"},
+			expectedErrMsg: "Topic naming errors:\n\tThe name of topic type 'out' may only contain the characters [a-zA-Z0-9._-] and can't be empty",
+		},
+		{
+			name:           "duplicate topic type names",
+			template:       DefaultTopicNameTemplate,
+			typeNames:      map[string]string{"invalid": "out"},
+			expectedErrMsg: "Topic naming errors:\n\tTopic types 'out' and 'invalid' have the same name 'out'",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTopicNaming(tc.template, tc.typeNames)
+			if tc.expectedErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+			}
+		})
+	}
+}
+
+func TestInitializeTopicNaming(t *testing.T) {
+	defer InitializeTopicNaming(DefaultTopicNameTemplate, nil)
+
+	err := InitializeTopicNaming("{tenantId}-{streamId}-{topicType}", map[string]string{"in": "input"})
+	assert.NoError(t, err)
+	inTopicName, notificationTopicName, outTopicName, invalidTopicName := CreateTopicNames("tenant1", "dataIntegrator1.qualifier1")
+	assert.Equal(t, "tenant1-dataIntegrator1.qualifier1-input", inTopicName)
+	assert.Equal(t, "tenant1-dataIntegrator1.qualifier1-notification", notificationTopicName)
+	assert.Equal(t, "tenant1-dataIntegrator1.qualifier1-out", outTopicName)
+	assert.Equal(t, "tenant1-dataIntegrator1.qualifier1-invalid", invalidTopicName)
+
+	// an invalid naming convention leaves the current one in place
+	err = InitializeTopicNaming("{tenantId}", nil)
+	assert.Error(t, err)
+	assert.Equal(t, "tenant1-stream1-out", GetTopicNaming().TopicName("tenant1", "stream1", OutTopic))
+}
+
+func TestParseTopicName(t *testing.T) {
+	custom, err := NewTopicNaming("hri-{topicType}_{tenantId}.{streamId}", map[string]string{"in": "input"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		naming           TopicNaming
+		topicName        string
+		tenantId         string
+		expectedStreamId string
+		expectedType     TopicType
+		expectedOk       bool
+	}{
+		{
+			name:             "default naming",
+			naming:           GetTopicNaming(),
+			topicName:        "ingest.tenant1.dataIntegrator1.qualifier1.notification",
+			tenantId:         "tenant1",
+			expectedStreamId: "dataIntegrator1.qualifier1",
+			expectedType:     NotificationTopic,
+			expectedOk:       true,
+		},
+		{
+			name:       "different tenant with a common prefix",
+			naming:     GetTopicNaming(),
+			topicName:  "ingest.tenant10.dataIntegrator1.in",
+			tenantId:   "tenant1",
+			expectedOk: false,
+		},
+		{
+			name:       "unknown topic type",
+			naming:     GetTopicNaming(),
+			topicName:  "ingest.tenant1.dataIntegrator1.errors",
+			tenantId:   "tenant1",
+			expectedOk: false,
+		},
+		{
+			name:             "custom naming",
+			naming:           custom,
+			topicName:        "hri-input_tenant1.dataIntegrator1",
+			tenantId:         "tenant1",
+			expectedStreamId: "dataIntegrator1",
+			expectedType:     InTopic,
+			expectedOk:       true,
+		},
+		{
+			name:       "custom naming with a default type name",
+			naming:     custom,
+			topicName:  "hri-in_tenant1.dataIntegrator1",
+			tenantId:   "tenant1",
+			expectedOk: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			streamId, topicType, ok := tc.naming.ParseTopicName(tc.topicName, tc.tenantId)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedStreamId, streamId)
+			assert.Equal(t, tc.expectedType, topicType)
+		})
+	}
+}
+
+func TestNotificationTopicName(t *testing.T) {
+	custom, err := NewTopicNaming("hri-{topicType}_{tenantId}.{streamId}", map[string]string{"in": "input", "notification": "notify"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		naming     TopicNaming
+		inputTopic string
+		expected   string
+	}{
+		{
+			name:       "default naming",
+			naming:     GetTopicNaming(),
+			inputTopic: "ingest.tenant1.dataIntegrator1.in",
+			expected:   "ingest.tenant1.dataIntegrator1.notification",
+		},
+		{
+			name:       "default naming without in suffix",
+			naming:     GetTopicNaming(),
+			inputTopic: "some.topic",
+			expected:   "some.topic.notification",
+		},
+		{
+			name:       "custom naming",
+			naming:     custom,
+			inputTopic: "hri-input_tenant1.dataIntegrator1.qualifier1",
+			expected:   "hri-notify_tenant1.dataIntegrator1.qualifier1",
+		},
+		{
+			name:       "custom naming with a legacy topic",
+			naming:     custom,
+			inputTopic: "ingest.tenant1.dataIntegrator1.input",
+			expected:   "ingest.tenant1.dataIntegrator1.notify",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.naming.NotificationTopicName(tc.inputTopic))
+		})
+	}
+}
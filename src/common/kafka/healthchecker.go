@@ -6,19 +6,27 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
-	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"strings"
+	"time"
 )
 
-// HealthChecker Public interface
+// HealthChecker Public interface. The checks end at the context's deadline, or after a second without one.
 type HealthChecker interface {
-	Check() error
+	Check(ctx context.Context) error
+	// BrokerCount returns the number of brokers in the cluster's metadata
+	BrokerCount(ctx context.Context) (int, error)
 	Close()
 }
 
+// the timeout of the metadata request when the context has no deadline
+const defaultMetadataTimeout = time.Second
+
 // internal type that meets the HealthChecker interface
 type confluentHealthChecker struct {
 	confluentAdminClient
@@ -44,17 +52,48 @@ func NewHealthChecker(config config.Config) (HealthChecker, error) {
 	return confluentHealthChecker{client}, nil
 }
 
-func (chc confluentHealthChecker) Check() error {
-	metadata, err := chc.GetMetadata(nil, true, 1000)
+func (chc confluentHealthChecker) Check(ctx context.Context) error {
+	start := time.Now()
+	err := chc.check(ctx)
+	metrics.ObserveKafka(metrics.KafkaHealthcheck, start, err)
+	return err
+}
+
+func (chc confluentHealthChecker) check(ctx context.Context) error {
+	_, err := chc.brokerCount(ctx)
+	return err
+}
+
+func (chc confluentHealthChecker) BrokerCount(ctx context.Context) (int, error) {
+	start := time.Now()
+	brokers, err := chc.brokerCount(ctx)
+	metrics.ObserveKafka(metrics.KafkaHealthcheck, start, err)
+	return brokers, err
+}
+
+func (chc confluentHealthChecker) brokerCount(ctx context.Context) (int, error) {
+	timeout := defaultMetadataTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	err := ctx.Err()
+	if err == nil && timeout <= 0 {
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting Kafka topics: %w", err)
+	}
+
+	metadata, err := chc.GetMetadata(nil, true, int(timeout.Milliseconds()))
 	if err != nil {
-		return fmt.Errorf("error getting Kafka topics: %w", err)
+		return 0, fmt.Errorf("error getting Kafka topics: %w", err)
 	}
 
 	if metadata == nil || len(metadata.Brokers) == 0 {
-		return errors.New("error getting Kafka topics; returned metadata or list of brokers was empty")
+		return 0, errors.New("error getting Kafka topics; returned metadata or list of brokers was empty")
 	}
 
 	// We can't assume that there are any topics, so if `GetMetadata()` succeeds without an error
 	// then we can assume Kafka is up and we can connect
-	return nil
+	return len(metadata.Brokers), nil
 }
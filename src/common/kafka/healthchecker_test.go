@@ -6,12 +6,14 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
-	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestNewHealthChecker(t *testing.T) {
@@ -69,7 +71,7 @@ func TestConfluentHealthChecker_Check(t *testing.T) {
 			client: fakeAdminClient{
 				t: t,
 				metadata: &kafka.Metadata{
-					Brokers:           []kafka.BrokerMetadata{{1, "broker-host", 9093}},
+					Brokers:           []kafka.BrokerMetadata{{ID: 1, Host: "broker-host", Port: 9093}},
 					Topics:            map[string]kafka.TopicMetadata{"topic": {Topic: "topic"}},
 					OriginatingBroker: kafka.BrokerMetadata{},
 				},
@@ -113,9 +115,40 @@ func TestConfluentHealthChecker_Check(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			healthChecker := confluentHealthChecker{tt.client}
-			err := healthChecker.Check()
+			err := healthChecker.Check(context.Background())
 
 			assert.Equal(t, tt.expErr, err)
 		})
 	}
 }
+
+func TestConfluentHealthChecker_BrokerCount(t *testing.T) {
+	healthChecker := confluentHealthChecker{fakeAdminClient{
+		t: t,
+		metadata: &kafka.Metadata{
+			Brokers: []kafka.BrokerMetadata{{ID: 1, Host: "broker-1", Port: 9093}, {ID: 2, Host: "broker-2", Port: 9093}},
+		},
+	}}
+	brokers, err := healthChecker.BrokerCount(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, brokers)
+
+	healthChecker = confluentHealthChecker{fakeAdminClient{t: t, err: errors.New("connection timeout")}}
+	brokers, err = healthChecker.BrokerCount(context.Background())
+	assert.Equal(t, fmt.Errorf("error getting Kafka topics: %w", errors.New("connection timeout")), err)
+	assert.Equal(t, 0, brokers)
+}
+
+func TestConfluentHealthCheckerContextDone(t *testing.T) {
+	// Kafka isn't asked for the metadata once the context is done
+	healthChecker := confluentHealthChecker{fakeAdminClient{t: t}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := healthChecker.BrokerCount(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	assert.ErrorIs(t, healthChecker.Check(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,244 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"sort"
+	"strings"
+	"time"
+)
+
+const lagRequestTimeout = 10 * time.Second
+const watermarkTimeoutMs = 1000
+
+// PartitionLag is the committed offset, end offset and lag of one consumer group on one topic partition
+type PartitionLag struct {
+	Topic           string `json:"topic"`
+	Partition       int32  `json:"partition"`
+	CommittedOffset int64  `json:"committedOffset"`
+	EndOffset       int64  `json:"endOffset"`
+	Lag             int64  `json:"lag"`
+}
+
+// ConsumerGroupLag is the lag of one consumer group across all the requested topic partitions it has committed to
+type ConsumerGroupLag struct {
+	GroupId    string         `json:"groupId"`
+	State      string         `json:"state"`
+	TotalLag   int64          `json:"totalLag"`
+	Partitions []PartitionLag `json:"partitions"`
+}
+
+// LagReader Public interface
+type LagReader interface {
+	GetConsumerLag(ctx context.Context, topics []string) ([]ConsumerGroupLag, error)
+	Close()
+}
+
+// internal type that meets the LagReader interface
+type confluentLagReader struct {
+	confluentLagClient
+}
+
+// internal interface for unit testing
+type confluentLagClient interface {
+	GetMetadata(*string, bool, int) (*kafka.Metadata, error)
+	ListConsumerGroups(context.Context, ...kafka.ListConsumerGroupsAdminOption) (kafka.ListConsumerGroupsResult, error)
+	DescribeConsumerGroups(context.Context, []string, ...kafka.DescribeConsumerGroupsAdminOption) (kafka.DescribeConsumerGroupsResult, error)
+	ListConsumerGroupOffsets(context.Context, []kafka.ConsumerGroupTopicPartitions, ...kafka.ListConsumerGroupOffsetsAdminOption) (kafka.ListConsumerGroupOffsetsResult, error)
+	QueryWatermarkOffsets(string, int32, int) (int64, int64, error)
+	Close()
+}
+
+// The AdminClient can't query watermarks, so it is derived from a Producer which can. Both share one connection.
+type adminWithWatermarks struct {
+	*kafka.AdminClient
+	producer *kafka.Producer
+}
+
+func (a adminWithWatermarks) QueryWatermarkOffsets(topic string, partition int32, timeoutMs int) (int64, int64, error) {
+	return a.producer.QueryWatermarkOffsets(topic, partition, timeoutMs)
+}
+
+func (a adminWithWatermarks) Close() {
+	a.AdminClient.Close()
+	a.producer.Close()
+}
+
+func NewLagReader(config config.Config) (LagReader, error) {
+	kafkaConfig := &kafka.ConfigMap{"bootstrap.servers": strings.Join(config.KafkaBrokers, ",")}
+	for key, value := range config.KafkaProperties {
+		kafkaConfig.SetKey(key, value)
+	}
+
+	producer, err := kafka.NewProducer(kafkaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error constructing Kafka admin client: %w", err)
+	}
+
+	admin, err := kafka.NewAdminClientFromProducer(producer)
+	if err != nil {
+		producer.Close()
+		return nil, fmt.Errorf("error constructing Kafka admin client: %w", err)
+	}
+
+	return confluentLagReader{adminWithWatermarks{admin, producer}}, nil
+}
+
+// GetConsumerLag returns every consumer group that has committed offsets on any of the given topics. Topics that
+// don't exist are ignored. Groups are sorted by id and partitions by topic and partition number. The committed offsets
+// are only fetched for the groups that may consume the topics, see mayConsume.
+func (clr confluentLagReader) GetConsumerLag(ctx context.Context, topics []string) ([]ConsumerGroupLag, error) {
+	start := time.Now()
+	lags, err := clr.getConsumerLag(ctx, topics)
+	metrics.ObserveKafka(metrics.KafkaConsumerLag, start, err)
+	return lags, err
+}
+
+func (clr confluentLagReader) getConsumerLag(ctx context.Context, topics []string) ([]ConsumerGroupLag, error) {
+	ctx, cancel := context.WithTimeout(ctx, lagRequestTimeout)
+	defer cancel()
+
+	partitions, err := clr.getTopicPartitions(topics)
+	if err != nil {
+		return nil, err
+	}
+	if len(partitions) == 0 {
+		return []ConsumerGroupLag{}, nil
+	}
+
+	groups, err := clr.ListConsumerGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing Kafka consumer groups: %w", err)
+	}
+	if len(groups.Errors) > 0 {
+		return nil, fmt.Errorf("error listing Kafka consumer groups: %w", groups.Errors[0])
+	}
+
+	if len(groups.Valid) == 0 {
+		return []ConsumerGroupLag{}, nil
+	}
+	groupIds := make([]string, 0, len(groups.Valid))
+	for _, group := range groups.Valid {
+		groupIds = append(groupIds, group.GroupID)
+	}
+	descriptions, err := clr.DescribeConsumerGroups(ctx, groupIds)
+	if err != nil {
+		return nil, fmt.Errorf("error describing Kafka consumer groups: %w", err)
+	}
+
+	topicSet := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		topicSet[topic] = true
+	}
+
+	endOffsets := make(map[string]int64)
+	results := []ConsumerGroupLag{}
+	for _, group := range descriptions.ConsumerGroupDescriptions {
+		if group.Error.Code() != kafka.ErrNoError {
+			return nil, fmt.Errorf("error describing Kafka consumer group [%s]: %w", group.GroupID, group.Error)
+		}
+		if !mayConsume(group, topicSet) {
+			continue
+		}
+
+		// only one group per request is supported by the client library
+		offsets, err := clr.ListConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{
+			{Group: group.GroupID, Partitions: partitions},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting committed offsets of consumer group [%s]: %w", group.GroupID, err)
+		}
+
+		groupLag := ConsumerGroupLag{GroupId: group.GroupID, State: group.State.String(), Partitions: []PartitionLag{}}
+		for _, groupPartitions := range offsets.ConsumerGroupsTopicPartitions {
+			for _, tp := range groupPartitions.Partitions {
+				// a negative offset means the group has never committed to this partition
+				if tp.Error != nil || tp.Offset < 0 {
+					continue
+				}
+
+				key := fmt.Sprintf("%s/%d", *tp.Topic, tp.Partition)
+				endOffset, ok := endOffsets[key]
+				if !ok {
+					_, endOffset, err = clr.QueryWatermarkOffsets(*tp.Topic, tp.Partition, watermarkTimeoutMs)
+					if err != nil {
+						return nil, fmt.Errorf("error getting end offset of topic [%s] partition [%d]: %w",
+							*tp.Topic, tp.Partition, err)
+					}
+					endOffsets[key] = endOffset
+				}
+
+				lag := endOffset - int64(tp.Offset)
+				if lag < 0 {
+					lag = 0
+				}
+				groupLag.Partitions = append(groupLag.Partitions, PartitionLag{
+					Topic:           *tp.Topic,
+					Partition:       tp.Partition,
+					CommittedOffset: int64(tp.Offset),
+					EndOffset:       endOffset,
+					Lag:             lag,
+				})
+				groupLag.TotalLag += lag
+			}
+		}
+
+		if len(groupLag.Partitions) > 0 {
+			sort.Slice(groupLag.Partitions, func(i, j int) bool {
+				a, b := groupLag.Partitions[i], groupLag.Partitions[j]
+				return a.Topic < b.Topic || (a.Topic == b.Topic && a.Partition < b.Partition)
+			})
+			results = append(results, groupLag)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].GroupId < results[j].GroupId })
+	return results, nil
+}
+
+// mayConsume is false for groups whose members are assigned partitions, none of them of the topics. Groups without
+// assigned partitions, i.e. without members or while they rebalance, may still have committed offsets on the topics.
+func mayConsume(group kafka.ConsumerGroupDescription, topics map[string]bool) bool {
+	assigned := false
+	for _, member := range group.Members {
+		for _, tp := range member.Assignment.TopicPartitions {
+			if tp.Topic != nil && topics[*tp.Topic] {
+				return true
+			}
+			assigned = true
+		}
+	}
+	return !assigned
+}
+
+func (clr confluentLagReader) getTopicPartitions(topics []string) ([]kafka.TopicPartition, error) {
+	metadata, err := clr.GetMetadata(nil, true, watermarkTimeoutMs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Kafka topics: %w", err)
+	}
+	if metadata == nil {
+		return nil, errors.New("error getting Kafka topics; returned metadata was empty")
+	}
+
+	partitions := []kafka.TopicPartition{}
+	for _, topic := range topics {
+		topicMetadata, ok := metadata.Topics[topic]
+		if !ok {
+			continue
+		}
+		for _, partition := range topicMetadata.Partitions {
+			topicName := topic
+			partitions = append(partitions, kafka.TopicPartition{Topic: &topicName, Partition: partition.ID})
+		}
+	}
+	return partitions, nil
+}
@@ -0,0 +1,236 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewLagReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Config
+		expErr error
+	}{
+		{
+			name:   "successful construction",
+			config: config.Config{KafkaBrokers: []string{"broker1", "broker2"}, KafkaProperties: config.StringMap{"message.max.bytes": "10000"}},
+			expErr: nil,
+		},
+		{
+			name:   "bad config",
+			config: config.Config{KafkaBrokers: []string{"broker1", "broker2"}, KafkaProperties: config.StringMap{"message.max.bytes": "bad_value"}},
+			expErr: fmt.Errorf("error constructing Kafka admin client: %w",
+				kafka.NewError(-186, "Invalid value for configuration property \"message.max.bytes\"", false)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lagReader, err := NewLagReader(tt.config)
+
+			assert.Equal(t, tt.expErr, err)
+			if lagReader != nil {
+				lagReader.Close()
+			}
+		})
+	}
+}
+
+type fakeLagClient struct {
+	t           *testing.T
+	metadata    *kafka.Metadata
+	metadataErr error
+	groups      kafka.ListConsumerGroupsResult
+	groupsErr   error
+	// the topics assigned to the members of each group
+	assignments map[string][]string
+	describeErr error
+	// committed offsets by group, then by "topic/partition"
+	committed  map[string]map[string]kafka.Offset
+	offsetsErr error
+	endOffsets map[string]int64
+	endErr     error
+}
+
+func (flc fakeLagClient) GetMetadata(topic *string, allTopics bool, timeout int) (*kafka.Metadata, error) {
+	assert.Nil(flc.t, topic)
+	assert.True(flc.t, allTopics)
+	return flc.metadata, flc.metadataErr
+}
+
+func (flc fakeLagClient) ListConsumerGroups(context.Context, ...kafka.ListConsumerGroupsAdminOption) (kafka.ListConsumerGroupsResult, error) {
+	return flc.groups, flc.groupsErr
+}
+
+func (flc fakeLagClient) DescribeConsumerGroups(_ context.Context, groupIds []string,
+	_ ...kafka.DescribeConsumerGroupsAdminOption) (kafka.DescribeConsumerGroupsResult, error) {
+	if flc.describeErr != nil {
+		return kafka.DescribeConsumerGroupsResult{}, flc.describeErr
+	}
+
+	result := kafka.DescribeConsumerGroupsResult{}
+	for i, groupId := range groupIds {
+		description := kafka.ConsumerGroupDescription{GroupID: groupId, State: flc.groups.Valid[i].State,
+			Error: kafka.NewError(kafka.ErrNoError, "", false)}
+		for _, topic := range flc.assignments[groupId] {
+			topicName := topic
+			description.Members = append(description.Members, kafka.MemberDescription{
+				Assignment: kafka.MemberAssignment{TopicPartitions: []kafka.TopicPartition{{Topic: &topicName}}},
+			})
+		}
+		result.ConsumerGroupDescriptions = append(result.ConsumerGroupDescriptions, description)
+	}
+	return result, nil
+}
+
+func (flc fakeLagClient) ListConsumerGroupOffsets(_ context.Context, groupsPartitions []kafka.ConsumerGroupTopicPartitions,
+	_ ...kafka.ListConsumerGroupOffsetsAdminOption) (kafka.ListConsumerGroupOffsetsResult, error) {
+	if flc.offsetsErr != nil {
+		return kafka.ListConsumerGroupOffsetsResult{}, flc.offsetsErr
+	}
+
+	assert.Equal(flc.t, 1, len(groupsPartitions))
+	result := kafka.ConsumerGroupTopicPartitions{Group: groupsPartitions[0].Group}
+	for _, tp := range groupsPartitions[0].Partitions {
+		offset, ok := flc.committed[groupsPartitions[0].Group][fmt.Sprintf("%s/%d", *tp.Topic, tp.Partition)]
+		if !ok {
+			offset = kafka.OffsetInvalid
+		}
+		result.Partitions = append(result.Partitions, kafka.TopicPartition{Topic: tp.Topic, Partition: tp.Partition, Offset: offset})
+	}
+	return kafka.ListConsumerGroupOffsetsResult{ConsumerGroupsTopicPartitions: []kafka.ConsumerGroupTopicPartitions{result}}, nil
+}
+
+func (flc fakeLagClient) QueryWatermarkOffsets(topic string, partition int32, _ int) (int64, int64, error) {
+	return 0, flc.endOffsets[fmt.Sprintf("%s/%d", topic, partition)], flc.endErr
+}
+
+func (flc fakeLagClient) Close() {}
+
+func TestConfluentLagReader_GetConsumerLag(t *testing.T) {
+	inTopic := "ingest.tenant.stream.in"
+	notificationTopic := "ingest.tenant.stream.notification"
+	metadata := &kafka.Metadata{
+		Topics: map[string]kafka.TopicMetadata{
+			inTopic:           {Topic: inTopic, Partitions: []kafka.PartitionMetadata{{ID: 0}, {ID: 1}}},
+			notificationTopic: {Topic: notificationTopic, Partitions: []kafka.PartitionMetadata{{ID: 0}}},
+			"other.topic":     {Topic: "other.topic", Partitions: []kafka.PartitionMetadata{{ID: 0}}},
+		},
+	}
+	groups := kafka.ListConsumerGroupsResult{Valid: []kafka.ConsumerGroupListing{
+		{GroupID: "validation", State: kafka.ConsumerGroupStateStable},
+		{GroupID: "downstream", State: kafka.ConsumerGroupStateEmpty},
+		{GroupID: "unrelated", State: kafka.ConsumerGroupStateStable},
+	}}
+	committed := map[string]map[string]kafka.Offset{
+		"validation": {inTopic + "/0": 10, inTopic + "/1": 20},
+		"downstream": {notificationTopic + "/0": 7},
+		// the offsets of groups consuming other topics aren't fetched
+		"unrelated": {inTopic + "/0": 1},
+	}
+	assignments := map[string][]string{"validation": {inTopic}, "unrelated": {"other.topic"}}
+	endOffsets := map[string]int64{inTopic + "/0": 15, inTopic + "/1": 20, notificationTopic + "/0": 9}
+
+	tests := []struct {
+		name      string
+		topics    []string
+		client    fakeLagClient
+		expResult []ConsumerGroupLag
+		expErr    error
+	}{
+		{
+			name:   "lag of each subscribed group",
+			topics: []string{inTopic, notificationTopic, "ingest.tenant.stream.out"},
+			client: fakeLagClient{t: t, metadata: metadata, groups: groups, assignments: assignments, committed: committed,
+				endOffsets: endOffsets},
+			expResult: []ConsumerGroupLag{
+				{
+					GroupId:  "downstream",
+					State:    "Empty",
+					TotalLag: 2,
+					Partitions: []PartitionLag{
+						{Topic: notificationTopic, Partition: 0, CommittedOffset: 7, EndOffset: 9, Lag: 2},
+					},
+				},
+				{
+					GroupId:  "validation",
+					State:    "Stable",
+					TotalLag: 5,
+					Partitions: []PartitionLag{
+						{Topic: inTopic, Partition: 0, CommittedOffset: 10, EndOffset: 15, Lag: 5},
+						{Topic: inTopic, Partition: 1, CommittedOffset: 20, EndOffset: 20, Lag: 0},
+					},
+				},
+			},
+		},
+		{
+			name:      "no existing topics",
+			topics:    []string{"ingest.tenant.missing.in"},
+			client:    fakeLagClient{t: t, metadata: metadata},
+			expResult: []ConsumerGroupLag{},
+		},
+		{
+			name:   "metadata error",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t, metadataErr: errors.New("connection timeout")},
+			expErr: fmt.Errorf("error getting Kafka topics: %w", errors.New("connection timeout")),
+		},
+		{
+			name:   "missing metadata",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t},
+			expErr: errors.New("error getting Kafka topics; returned metadata was empty"),
+		},
+		{
+			name:   "list groups error",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t, metadata: metadata, groupsErr: errors.New("broker down")},
+			expErr: fmt.Errorf("error listing Kafka consumer groups: %w", errors.New("broker down")),
+		},
+		{
+			name:   "list groups partial error",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t, metadata: metadata,
+				groups: kafka.ListConsumerGroupsResult{Errors: []error{errors.New("coordinator not available")}}},
+			expErr: fmt.Errorf("error listing Kafka consumer groups: %w", errors.New("coordinator not available")),
+		},
+		{
+			name:   "describe groups error",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t, metadata: metadata, groups: groups, describeErr: errors.New("broker down")},
+			expErr: fmt.Errorf("error describing Kafka consumer groups: %w", errors.New("broker down")),
+		},
+		{
+			name:   "committed offsets error",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t, metadata: metadata, groups: groups, offsetsErr: errors.New("not authorized")},
+			expErr: fmt.Errorf("error getting committed offsets of consumer group [validation]: %w", errors.New("not authorized")),
+		},
+		{
+			name:   "watermark error",
+			topics: []string{inTopic},
+			client: fakeLagClient{t: t, metadata: metadata, groups: groups, committed: committed, endErr: errors.New("timed out")},
+			expErr: fmt.Errorf("error getting end offset of topic [%s] partition [0]: %w", inTopic, errors.New("timed out")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lagReader := confluentLagReader{tt.client}
+			result, err := lagReader.GetConsumerLag(context.Background(), tt.topics)
+
+			assert.Equal(t, tt.expErr, err)
+			assert.Equal(t, tt.expResult, result)
+		})
+	}
+}
@@ -6,15 +6,27 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
-	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/Alvearie/hri-mgmt-api/common/tracing"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 	"strings"
+	"time"
 )
 
+// when the writer is closed, e.g. when the server shuts down, this is how long it waits for undelivered messages
+const closeFlushMs = 5000
+
 type Writer interface {
-	Write(topic string, key string, val map[string]interface{}) error
+	// Write publishes the value to the topic. The trace context of ctx is propagated in the message's headers.
+	Write(ctx context.Context, topic string, key string, val map[string]interface{}) error
 	Close()
 }
 
@@ -48,17 +60,49 @@ func NewWriterFromConfig(config config.Config) (Writer, error) {
 }
 
 // This method is not thread safe. Each thread needs it's own ConfluentKafkaWriter instance
-func (cfk confluentKafkaWriter) Write(topic string, key string, val map[string]interface{}) error {
+func (cfk confluentKafkaWriter) Write(ctx context.Context, topic string, key string, val map[string]interface{}) error {
+	ctx, span := tracing.Tracer().Start(ctx, topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("kafka"),
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingDestinationKey.String(topic),
+			semconv.MessagingKafkaMessageKeyKey.String(key),
+		),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := cfk.write(ctx, topic, key, val)
+	metrics.ObserveKafka(metrics.KafkaWrite, start, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// Close waits up to closeFlushMs for the messages that haven't been delivered yet, before closing the producer
+func (cfk confluentKafkaWriter) Close() {
+	cfk.Flush(closeFlushMs)
+	cfk.confluentProducer.Close()
+}
+
+func (cfk confluentKafkaWriter) write(ctx context.Context, topic string, key string, val map[string]interface{}) error {
 	jsonVal, err := json.Marshal(val)
 	if err != nil {
 		return fmt.Errorf("error marshaling kafka message: %w", err)
 	}
 
-	err = cfk.Produce(&kafka.Message{
+	message := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Key:            []byte(key),
 		Value:          jsonVal,
-	}, nil) // nil uses the default producer channel
+	}
+	// consumers can continue the trace from the traceparent header
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{message})
+
+	err = cfk.Produce(message, nil) // nil uses the default producer channel
 
 	if err != nil {
 		return fmt.Errorf("kafka producer error: %w", err)
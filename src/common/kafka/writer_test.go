@@ -6,13 +6,17 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
-	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"testing"
 )
 
@@ -132,13 +136,60 @@ func TestConfluentKafkaWriter_Write(t *testing.T) {
 				}
 			}
 
-			err := writer.Write(tt.topic, tt.key, tt.value)
+			err := writer.Write(context.Background(), tt.topic, tt.key, tt.value)
 
 			assert.Equal(t, tt.expError, err)
 		})
 	}
 }
 
+func TestConfluentKafkaWriter_WritePropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	controller := gomock.NewController(t)
+	mockProducer := NewMockconfluentProducer(controller)
+	deliveryChan := make(chan kafka.Event)
+	defer close(deliveryChan)
+
+	mockProducer.EXPECT().
+		Produce(gomock.Any(), nil).
+		DoAndReturn(func(message *kafka.Message, _ chan kafka.Event) interface{} {
+			assert.Equal(t, []kafka.Header{
+				{Key: "traceparent", Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+			}, message.Headers)
+			go sendMessage(message, deliveryChan)
+			return nil
+		})
+	mockProducer.EXPECT().Flush(1000)
+	mockProducer.EXPECT().Events().Return(deliveryChan)
+
+	writer := confluentKafkaWriter{mockProducer}
+	assert.NoError(t, writer.Write(ctx, "a.topic", "a_unique_key", map[string]interface{}{"field1": "value"}))
+}
+
+func TestConfluentKafkaWriter_CloseFlushes(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+	mockProducer := NewMockconfluentProducer(controller)
+
+	gomock.InOrder(
+		mockProducer.EXPECT().Flush(5000).Return(0),
+		mockProducer.EXPECT().Close(),
+	)
+
+	writer := confluentKafkaWriter{mockProducer}
+	writer.Close()
+}
+
 func sendMessage(message *kafka.Message, channel chan kafka.Event) {
 	channel <- message
 }
@@ -0,0 +1,119 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package logwrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// the batch fields that may hold PHI, and the plaintext key returned once when an API key is created
+var redactedFields = map[string]bool{
+	param.Metadata:       true,
+	param.FailureMessage: true,
+	param.ApiKey:         true,
+}
+
+// RequestLogger logs every request and response with the shared logger, so they have the same format and output as
+// the other logs
+func RequestLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// sets the response status of the error
+				c.Error(err)
+			}
+
+			requestLogger(c, "RequestLogger").WithFields(logrus.Fields{
+				"method":    c.Request().Method,
+				"uri":       c.Request().RequestURI,
+				"status":    c.Response().Status,
+				"latencyMs": time.Since(start).Milliseconds(),
+				"remoteIp":  c.RealIP(),
+			}).Info("request handled")
+			return err
+		}
+	}
+}
+
+// BodyDump logs the request and response bodies at debug level, without the batches' metadata and failure messages
+// or the new API keys.
+// The tenant exports and imports are streamed, so they're skipped rather than copied into memory.
+func BodyDump() echo.MiddlewareFunc {
+	return middleware.BodyDumpWithConfig(middleware.BodyDumpConfig{
+		Skipper: IsStreamed,
+		Handler: func(c echo.Context, reqBody, resBody []byte) {
+			requestLogger(c, "BodyDump").Debugf("%s %s '%v' -> %d '%v'",
+				c.Request().Method, c.Request().URL, RedactBody(reqBody), c.Response().Status, RedactBody(resBody))
+		},
+	})
+}
+
+// IsStreamed returns whether the route streams its body, i.e. a tenant export or import of either API version.
+// Middleware mustn't buffer the bodies of these routes, they can be as large as a tenant.
+func IsStreamed(c echo.Context) bool {
+	path := c.Path()
+	return strings.HasSuffix(path, "/:"+param.TenantId+"/export") || strings.HasSuffix(path, "/:"+param.TenantId+"/import")
+}
+
+// requestLogger returns a logger with the request id, and the tenant and batch ids of the request's route
+func requestLogger(c echo.Context, prefix string) logrus.FieldLogger {
+	logger := GetMyLogger(c.Response().Header().Get(echo.HeaderXRequestID), prefix)
+	if tenantId := c.Param(param.TenantId); tenantId != "" {
+		logger = logger.WithField(TenantIdField, tenantId)
+	}
+	// the streams' routes also have an id, but only the batches' routes are under a batches path
+	if batchId := c.Param(param.BatchId); batchId != "" && strings.Contains(c.Path(), "/batches/:"+param.BatchId) {
+		logger = logger.WithField(BatchIdField, batchId)
+	}
+	return logger
+}
+
+// RedactBody returns the JSON body with the values of the fields that may hold PHI or secrets replaced. Bodies that aren't JSON
+// aren't returned, since they can't be redacted.
+func RedactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return fmt.Sprintf("[%d bytes, not JSON]", len(body))
+	}
+	encoded, err := json.Marshal(redact(decoded))
+	if err != nil {
+		return fmt.Sprintf("[%d bytes, not JSON]", len(body))
+	}
+	return string(encoded)
+}
+
+func redact(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range typed {
+			if redactedFields[key] {
+				typed[key] = redacted
+			} else {
+				typed[key] = redact(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i, element := range typed {
+			typed[i] = redact(element)
+		}
+	}
+	return value
+}
@@ -0,0 +1,132 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package logwrapper
+
+import (
+	"bytes"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "empty",
+			body:     "",
+			expected: "",
+		},
+		{
+			name:     "batch",
+			body:     `{"name":"batch1","metadata":{"patient":"John Doe"},"failureMessage":"bad record for John Doe"}`,
+			expected: `{"failureMessage":"[REDACTED]","metadata":"[REDACTED]","name":"batch1"}`,
+		},
+		{
+			name:     "list of batches",
+			body:     `{"total":1,"results":[{"id":"batch1","metadata":{"patient":"John Doe"}}]}`,
+			expected: `{"results":[{"id":"batch1","metadata":"[REDACTED]"}],"total":1}`,
+		},
+		{
+			name:     "new API key",
+			body:     `{"id":"0123abcd","name":"pipeline","key":"hri_0123abcd_secret"}`,
+			expected: `{"id":"0123abcd","key":"[REDACTED]","name":"pipeline"}`,
+		},
+		{
+			name:     "without PHI",
+			body:     `{"errorEventId":"req1","errorDescription":"not found"}`,
+			expected: `{"errorDescription":"not found","errorEventId":"req1"}`,
+		},
+		{
+			name:     "not JSON",
+			body:     `{"metadata":{"patient":"John Doe"`,
+			expected: "[33 bytes, not JSON]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RedactBody([]byte(tt.body)))
+		})
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	Initialize("debug", &buf)
+
+	e := echo.New()
+	e.Use(middleware.RequestID(), RequestLogger(), BodyDump())
+	e.PUT("/hri/tenants/:tenantId/batches/:id/action/fail", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{"id": c.Param("id"), "failureMessage": "bad record for John Doe"})
+	})
+	e.GET("/hri/tenants/:tenantId/streams/:id", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "stream not found")
+	})
+
+	request := httptest.NewRequest(http.MethodPut, "/hri/tenants/tenant1/batches/batch1/action/fail",
+		strings.NewReader(`{"failureMessage":"bad record for John Doe"}`))
+	request.Header.Set(echo.HeaderXRequestID, "request1")
+	e.ServeHTTP(httptest.NewRecorder(), request)
+
+	output := buf.String()
+	assert.NotContains(t, output, "John Doe")
+	assert.Contains(t, output, `level=debug msg="PUT /hri/tenants/tenant1/batches/batch1/action/fail '{\"failureMessage\":\"[REDACTED]\"}' -> 200 '{\"failureMessage\":\"[REDACTED]\",\"id\":\"batch1\"}'" batchId=batch1 functionPrefix=BodyDump requestId=request1 tenantId=tenant1`)
+	assert.Contains(t, output, `level=info msg="request handled" batchId=batch1 functionPrefix=RequestLogger`)
+	assert.Contains(t, output, "method=PUT remoteIp=192.0.2.1 requestId=request1 status=200 tenantId=tenant1 uri=/hri/tenants/tenant1/batches/batch1/action/fail\n")
+
+	// a stream id isn't a batch id, and the status of errors is logged
+	buf.Reset()
+	response := httptest.NewRecorder()
+	e.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/hri/tenants/tenant1/streams/stream1", nil))
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.NotContains(t, buf.String(), "batchId")
+	assert.Contains(t, buf.String(), "status=404 tenantId=tenant1")
+}
+
+func TestBodyDumpSkipsStreamedBodies(t *testing.T) {
+	var buf bytes.Buffer
+	Initialize("debug", &buf)
+
+	e := echo.New()
+	e.Use(BodyDump())
+	handler := func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "application/x-ndjson", []byte(`{"type":"config","config":{}}`))
+	}
+	e.GET("/hri/tenants/:tenantId/export", handler)
+	e.POST("/hri/tenants/:tenantId/import", handler)
+	e.GET("/hri/v2/tenants/:tenantId/export", handler)
+	e.GET("/hri/tenants/:tenantId/config", handler)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected bool
+	}{
+		{method: http.MethodGet, path: "/hri/tenants/tenant1/export", expected: false},
+		{method: http.MethodPost, path: "/hri/tenants/tenant1/import", expected: false},
+		{method: http.MethodGet, path: "/hri/v2/tenants/tenant1/export", expected: false},
+		{method: http.MethodGet, path: "/hri/tenants/tenant1/config", expected: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			buf.Reset()
+			response := httptest.NewRecorder()
+			e.ServeHTTP(response, httptest.NewRequest(tc.method, tc.path, nil))
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Equal(t, tc.expected, strings.Contains(buf.String(), "functionPrefix=BodyDump"))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package logwrapper
+
+import (
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/sirupsen/logrus"
+	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"io"
+	"io/ioutil"
+	"log/syslog"
+	"net/url"
+	"os"
+)
+
+const syslogTag = "hri-mgmt-api"
+
+// output is where the shared logger writes its entries. Syslog is written by a hook, which maps the log levels to
+// syslog priorities, so nothing is written to the writer.
+type output struct {
+	writer io.Writer
+	hooks  []logrus.Hook
+	close  func()
+}
+
+func newOutput(serverConfig config.Config) (output, error) {
+	switch serverConfig.LogOutput {
+	case "", config.LogOutputStdout:
+		return output{writer: os.Stdout, close: func() {}}, nil
+	case config.LogOutputFile:
+		file, err := os.OpenFile(serverConfig.LogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+		if err != nil {
+			return output{}, fmt.Errorf("unable to open the log file: %w", err)
+		}
+		return output{writer: file, close: func() { file.Close() }}, nil
+	case config.LogOutputSyslog:
+		hook, err := newSyslogHook(serverConfig.LogSyslogAddress)
+		if err != nil {
+			return output{}, fmt.Errorf("unable to connect to syslog: %w", err)
+		}
+		return output{writer: ioutil.Discard, hooks: []logrus.Hook{hook}, close: func() { hook.Writer.Close() }}, nil
+	default:
+		return output{}, fmt.Errorf("unknown log output '%s'", serverConfig.LogOutput)
+	}
+}
+
+// newSyslogHook connects to the syslog daemon at the address, e.g. udp://localhost:514, or the local daemon when it's
+// empty
+func newSyslogHook(address string) (*lsyslog.SyslogHook, error) {
+	network, raddr := "", ""
+	if address != "" {
+		syslogUrl, err := url.Parse(address)
+		if err != nil {
+			return nil, err
+		}
+		network, raddr = syslogUrl.Scheme, syslogUrl.Host
+	}
+	return lsyslog.NewSyslogHook(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+}
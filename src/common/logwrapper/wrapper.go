@@ -8,14 +8,18 @@ package logwrapper
 
 import (
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 	"os"
 )
 
 const (
 	RequestIdField      = "requestId"
 	FunctionPrefixField = "functionPrefix"
+	TenantIdField       = "tenantId"
+	BatchIdField        = "batchId"
 )
 
 type LogConfig struct {
@@ -25,6 +29,12 @@ type LogConfig struct {
 
 var globalConfig LogConfig
 
+// every logger is an entry of this logger, with the fields of its request. Nothing is logged until it's initialized.
+var sharedLogger = newLogger(logrus.PanicLevel, ioutil.Discard)
+
+// closes the log file, when the logs are written to one
+var closeOutput = func() {}
+
 func Initialize(lvlStr string, out io.Writer) (*LogConfig, error) {
 	parsedLvl, err := ParseLevelFromStr(lvlStr)
 	if err != nil {
@@ -35,9 +45,58 @@ func Initialize(lvlStr string, out io.Writer) (*LogConfig, error) {
 		Level:    parsedLvl,
 		Location: out,
 	}
+	sharedLogger = newLogger(parsedLvl, out)
 	return &globalConfig, nil
 }
 
+// InitializeFromConfig initializes the shared logger with the configured level, format and output
+func InitializeFromConfig(config config.Config) (*LogConfig, error) {
+	formatter, err := newFormatter(config.LogFormat)
+	if err != nil {
+		return nil, err
+	}
+	output, err := newOutput(config)
+	if err != nil {
+		return nil, err
+	}
+
+	logConfig, err := Initialize(config.LogLevel, output.writer)
+	if err != nil {
+		output.close()
+		return nil, err
+	}
+	sharedLogger.SetFormatter(formatter)
+	for _, hook := range output.hooks {
+		sharedLogger.AddHook(hook)
+	}
+	closeOutput = output.close
+	return logConfig, nil
+}
+
+// Close closes the log file or syslog connection, the logs written afterwards are lost
+func Close() {
+	closeOutput()
+	closeOutput = func() {}
+}
+
+func newLogger(level logrus.Level, out io.Writer) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(level)
+	logger.SetOutput(out)
+	return logger
+}
+
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "", config.LogFormatText:
+		return &logrus.TextFormatter{}, nil
+	case config.LogFormatJson:
+		return &logrus.JSONFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format '%s'", format)
+	}
+}
+
 // CreateLogger returns a logrus FieldLogger instance.
 // standardFlds is expected to be a Map At Least 1 entry (for :
 // 1. the requestId (RequestIdField)
@@ -54,10 +113,6 @@ func CreateLogger(fields map[string]string) (logrus.FieldLogger, error) {
 	}
 
 	//Set Standard Fields - 2 Fields: requestId & functionPrefix
-	var logrusLog = logrus.New()
-	logrusLog.SetLevel(globalConfig.Level)
-	logrusLog.SetOutput(globalConfig.Location)
-
 	var logFields = map[string]interface{}{}
 	logFields = logrus.Fields{
 		RequestIdField:      fields[RequestIdField],
@@ -73,7 +128,7 @@ func CreateLogger(fields map[string]string) (logrus.FieldLogger, error) {
 		}
 	}
 
-	entry := logrusLog.WithFields(logFields)
+	entry := sharedLogger.WithFields(logFields)
 
 	return entry, nil
 }
@@ -108,3 +163,16 @@ func GetMyLogger(requestId string, prefix string) logrus.FieldLogger {
 
 	return logger
 }
+
+// GetMyTenantLogger is GetMyLogger with the tenant id as a standard field
+func GetMyTenantLogger(requestId string, prefix string, tenantId string) logrus.FieldLogger {
+	return GetMyLogger(requestId, prefix).WithField(TenantIdField, tenantId)
+}
+
+// GetMyBatchLogger is GetMyLogger with the tenant and batch ids as standard fields
+func GetMyBatchLogger(requestId string, prefix string, tenantId string, batchId string) logrus.FieldLogger {
+	return GetMyLogger(requestId, prefix).WithFields(logrus.Fields{
+		TenantIdField: tenantId,
+		BatchIdField:  batchId,
+	})
+}
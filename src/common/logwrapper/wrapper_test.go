@@ -8,11 +8,13 @@ package logwrapper
 
 import (
 	"bytes"
-	//"bytes"
+	"encoding/json"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -251,3 +253,75 @@ func getDefaultFields() map[string]string {
 	fields[FunctionPrefixField] = TestFuncPrefixValue
 	return fields
 }
+
+func TestInitializeFromConfig(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "hri.log")
+	defer Close()
+
+	tests := []struct {
+		name        string
+		config      config.Config
+		expectedErr string
+	}{
+		{
+			name:   "json to file",
+			config: config.Config{LogLevel: "info", LogFormat: config.LogFormatJson, LogOutput: config.LogOutputFile, LogFile: logFile},
+		},
+		{
+			name:        "unknown format",
+			config:      config.Config{LogLevel: "info", LogFormat: "xml"},
+			expectedErr: "unknown log format 'xml'",
+		},
+		{
+			name:        "unknown output",
+			config:      config.Config{LogLevel: "info", LogOutput: "stderr"},
+			expectedErr: "unknown log output 'stderr'",
+		},
+		{
+			name:        "missing directory",
+			config:      config.Config{LogLevel: "info", LogOutput: config.LogOutputFile, LogFile: filepath.Join(t.TempDir(), "missing", "hri.log")},
+			expectedErr: "unable to open the log file: open ",
+		},
+		{
+			name:        "invalid level",
+			config:      config.Config{LogLevel: "loud", LogOutput: config.LogOutputStdout},
+			expectedErr: "error parsing log Level - not a valid logrus Level: \"loud\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := InitializeFromConfig(tt.config)
+			if tt.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.expectedErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	// the json logger of the first test is still the shared logger
+	GetMyBatchLogger(TestRequestIdValue, TestFuncPrefixValue, "tenant1", "batch1").Info("batch logged")
+	Close()
+
+	content, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	entry := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(content, &entry))
+	assert.Equal(t, "batch logged", entry["msg"])
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, TestRequestIdValue, entry[RequestIdField])
+	assert.Equal(t, TestFuncPrefixValue, entry[FunctionPrefixField])
+	assert.Equal(t, "tenant1", entry[TenantIdField])
+	assert.Equal(t, "batch1", entry[BatchIdField])
+}
+
+func TestGetMyTenantLogger(t *testing.T) {
+	var buf bytes.Buffer
+	Initialize("info", &buf)
+
+	GetMyTenantLogger(TestRequestIdValue, TestFuncPrefixValue, "tenant1").Info("tenant logged")
+	assert.Contains(t, buf.String(), "level=info msg=\"tenant logged\" functionPrefix=module/fakeclass/fakefunction requestId=xx6749493cj0 tenantId=tenant1\n")
+}
@@ -0,0 +1,59 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+// BatchCounter returns the number of batches of each tenant by status
+type BatchCounter func() (map[string]map[string]int, error)
+
+var batches = &batchCollector{
+	desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "batches"),
+		"Number of batches by tenant and status.", []string{"tenant", "status"}, nil),
+}
+
+// batchCollector counts the batches when the metrics are scraped, so the gauges are never stale
+type batchCollector struct {
+	mutex sync.RWMutex
+	count BatchCounter
+	desc  *prometheus.Desc
+}
+
+// SetBatchCounter sets how the batches are counted. The batch gauges aren't exposed until it's set.
+func SetBatchCounter(count BatchCounter) {
+	batches.mutex.Lock()
+	defer batches.mutex.Unlock()
+	batches.count = count
+}
+
+func (b *batchCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- b.desc
+}
+
+func (b *batchCollector) Collect(metrics chan<- prometheus.Metric) {
+	b.mutex.RLock()
+	count := b.count
+	b.mutex.RUnlock()
+	if count == nil {
+		return
+	}
+
+	counts, err := count()
+	if err != nil {
+		// the other metrics are still exposed
+		logwrapper.GetMyLogger("", "metrics/batches").Errorf("Unable to count the batches: %s", err.Error())
+		return
+	}
+	for tenant, statuses := range counts {
+		for status, value := range statuses {
+			metrics <- prometheus.MustNewConstMetric(b.desc, prometheus.GaugeValue, float64(value), tenant, status)
+		}
+	}
+}
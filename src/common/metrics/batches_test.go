@@ -0,0 +1,44 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchCollector(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	defer SetBatchCounter(nil)
+
+	// not exposed until a counter is set
+	assert.Equal(t, 0, testutil.CollectAndCount(batches))
+
+	SetBatchCounter(func() (map[string]map[string]int, error) {
+		return map[string]map[string]int{
+			"tenant1": {"started": 2, "completed": 1},
+			"tenant2": {"failed": 1},
+		}, nil
+	})
+	expected := `
+# HELP hri_batches Number of batches by tenant and status.
+# TYPE hri_batches gauge
+hri_batches{status="completed",tenant="tenant1"} 1
+hri_batches{status="failed",tenant="tenant2"} 1
+hri_batches{status="started",tenant="tenant1"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(batches, strings.NewReader(expected)))
+
+	SetBatchCounter(func() (map[string]map[string]int, error) {
+		return nil, errors.New("elasticsearch client error: connection refused")
+	})
+	assert.Equal(t, 0, testutil.CollectAndCount(batches))
+}
@@ -0,0 +1,52 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// elasticTransport records the latency and errors of the requests to Elasticsearch
+type elasticTransport struct {
+	next http.RoundTripper
+}
+
+// NewElasticTransport wraps the transport of an Elasticsearch client, or the default transport when it's nil
+func NewElasticTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return elasticTransport{next: next}
+}
+
+func (t elasticTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	operation := elasticOperation(request.URL.Path)
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+	elasticRequestDuration.WithLabelValues(request.Method, operation).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		elasticErrors.WithLabelValues(request.Method, operation, "none").Inc()
+	} else if response.StatusCode >= http.StatusBadRequest && response.StatusCode != http.StatusNotFound {
+		// a missing document or index is an expected result for many requests
+		elasticErrors.WithLabelValues(request.Method, operation, strconv.Itoa(response.StatusCode)).Inc()
+	}
+	return response, err
+}
+
+// elasticOperation returns the first Elasticsearch API of the path, e.g. '_search' or '_doc', so that index names and
+// document ids aren't used as labels. Requests on an index itself, like creating it, are the 'index' operation.
+func elasticOperation(path string) string {
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "_") {
+			return segment
+		}
+	}
+	return "index"
+}
@@ -0,0 +1,78 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeRoundTripper struct {
+	statusCode int
+	err        error
+}
+
+func (f fakeRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{StatusCode: f.statusCode}, nil
+}
+
+func TestElasticOperation(t *testing.T) {
+	tests := []struct {
+		path      string
+		operation string
+	}{
+		{path: "/tenant1-batches/_search", operation: "_search"},
+		{path: "/tenant1-batches/_doc/batch1/_update", operation: "_doc"},
+		{path: "/_cat/indices", operation: "_cat"},
+		{path: "/_index_template/batches", operation: "_index_template"},
+		{path: "/tenant1-batches", operation: "index"},
+		{path: "/", operation: "index"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.operation, elasticOperation(tt.path))
+		})
+	}
+}
+
+func TestElasticTransport(t *testing.T) {
+	tests := []struct {
+		name        string
+		next        fakeRoundTripper
+		errorStatus string
+	}{
+		{name: "success", next: fakeRoundTripper{statusCode: http.StatusOK}},
+		{name: "not found", next: fakeRoundTripper{statusCode: http.StatusNotFound}},
+		{name: "error status", next: fakeRoundTripper{statusCode: http.StatusConflict}, errorStatus: "409"},
+		{name: "transport error", next: fakeRoundTripper{err: errors.New("connection refused")}, errorStatus: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elasticErrors.Reset()
+			transport := NewElasticTransport(tt.next)
+
+			request := httptest.NewRequest(http.MethodPost, "/tenant1-batches/_search", nil)
+			_, err := transport.RoundTrip(request)
+			assert.Equal(t, tt.next.err, err)
+
+			if tt.errorStatus == "" {
+				assert.Equal(t, 0, testutil.CollectAndCount(elasticErrors))
+			} else {
+				assert.Equal(t, float64(1), testutil.ToFloat64(elasticErrors.WithLabelValues(http.MethodPost, "_search", tt.errorStatus)))
+			}
+		})
+	}
+	assert.Equal(t, http.DefaultTransport, NewElasticTransport(nil).(elasticTransport).next)
+}
@@ -0,0 +1,173 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const namespace = "hri"
+
+// Notification types, for the notification publish failures
+const (
+	BatchNotification  = "batch"
+	TenantNotification = "tenant"
+)
+
+// Kafka operations
+const (
+	KafkaWrite       = "write"
+	KafkaHealthcheck = "healthcheck"
+	KafkaConsumerLag = "consumer_lag"
+)
+
+// Rate limit scopes
+const (
+	RateLimitClient = "client"
+	RateLimitTenant = "tenant"
+)
+
+// Batch quotas
+const (
+	QuotaActiveBatches               = "max_active_batches"
+	QuotaStartedBatchesPerIntegrator = "max_started_batches_per_integrator"
+	QuotaBatchesPerDay               = "max_batches_per_day"
+)
+
+// the metrics are registered in their own registry, so that only the HRI, Go runtime and process metrics are exposed
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method, status and tenant.",
+	}, []string{"route", "method", "status", "tenant"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route, method, status and tenant.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status", "tenant"})
+
+	elasticRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "elastic_request_duration_seconds",
+		Help:      "Latency of the Elasticsearch requests by method and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "operation"})
+
+	elasticErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "elastic_errors_total",
+		Help:      "Number of Elasticsearch requests that failed or returned an error status, by method, operation and status.",
+	}, []string{"method", "operation", "status"})
+
+	kafkaDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_duration_seconds",
+		Help:      "Latency of the Kafka calls by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	kafkaErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_errors_total",
+		Help:      "Number of Kafka calls that failed, by operation.",
+	}, []string{"operation"})
+
+	notificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_publish_failures_total",
+		Help:      "Number of batch and tenant notifications that couldn't be published to Kafka.",
+	}, []string{"type"})
+
+	statusRevertAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batch_status_revert_attempts_total",
+		Help:      "Number of attempts to revert a batch's status after its notification couldn't be published, by result.",
+	}, []string{"result"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Number of requests rejected because a client or tenant exceeded its rate limit, by scope and tenant.",
+	}, []string{"scope", "tenant"})
+
+	quotaRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_rejections_total",
+		Help:      "Number of batches that weren't created because the tenant or integrator reached a quota, by quota and tenant.",
+	}, []string{"quota", "tenant"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		elasticRequestDuration,
+		elasticErrors,
+		kafkaDuration,
+		kafkaErrors,
+		notificationFailures,
+		statusRevertAttempts,
+		rateLimited,
+		quotaRejections,
+		batches,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// NewServer returns a server that only serves the metrics on /metrics, for exposing them on a separate admin port
+func NewServer(port int) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+}
+
+// ObserveKafka records the latency of a Kafka call that started at start, and whether it failed
+func ObserveKafka(operation string, start time.Time, err error) {
+	kafkaDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		kafkaErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// NotificationPublishFailed counts a notification of the given type that couldn't be published
+func NotificationPublishFailed(notificationType string) {
+	notificationFailures.WithLabelValues(notificationType).Inc()
+}
+
+// BatchStatusRevertAttempted counts an attempt to revert a batch's status
+func BatchStatusRevertAttempted(succeeded bool) {
+	result := "success"
+	if !succeeded {
+		result = "failure"
+	}
+	statusRevertAttempts.WithLabelValues(result).Inc()
+}
+
+// RateLimited counts a request rejected by the rate limit of the given scope
+func RateLimited(scope string, tenant string) {
+	rateLimited.WithLabelValues(scope, tenant).Inc()
+}
+
+// QuotaExceeded counts a batch that wasn't created because of the given quota
+func QuotaExceeded(quota string, tenant string) {
+	quotaRejections.WithLabelValues(quota, tenant).Inc()
+}
@@ -0,0 +1,83 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestObserveKafka(t *testing.T) {
+	errorsBefore := testutil.ToFloat64(kafkaErrors.WithLabelValues(KafkaWrite))
+
+	ObserveKafka(KafkaWrite, time.Now(), nil)
+	ObserveKafka(KafkaWrite, time.Now(), errors.New("kafka producer error"))
+
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(kafkaErrors.WithLabelValues(KafkaWrite)))
+	assert.Equal(t, 1, testutil.CollectAndCount(kafkaDuration))
+}
+
+func TestNotificationPublishFailed(t *testing.T) {
+	batchBefore := testutil.ToFloat64(notificationFailures.WithLabelValues(BatchNotification))
+	tenantBefore := testutil.ToFloat64(notificationFailures.WithLabelValues(TenantNotification))
+
+	NotificationPublishFailed(BatchNotification)
+
+	assert.Equal(t, batchBefore+1, testutil.ToFloat64(notificationFailures.WithLabelValues(BatchNotification)))
+	assert.Equal(t, tenantBefore, testutil.ToFloat64(notificationFailures.WithLabelValues(TenantNotification)))
+}
+
+func TestBatchStatusRevertAttempted(t *testing.T) {
+	successBefore := testutil.ToFloat64(statusRevertAttempts.WithLabelValues("success"))
+	failureBefore := testutil.ToFloat64(statusRevertAttempts.WithLabelValues("failure"))
+
+	BatchStatusRevertAttempted(false)
+	BatchStatusRevertAttempted(false)
+	BatchStatusRevertAttempted(true)
+
+	assert.Equal(t, successBefore+1, testutil.ToFloat64(statusRevertAttempts.WithLabelValues("success")))
+	assert.Equal(t, failureBefore+2, testutil.ToFloat64(statusRevertAttempts.WithLabelValues("failure")))
+}
+
+func TestRateLimited(t *testing.T) {
+	clientBefore := testutil.ToFloat64(rateLimited.WithLabelValues(RateLimitClient, "tenant1"))
+	tenantBefore := testutil.ToFloat64(rateLimited.WithLabelValues(RateLimitTenant, "tenant1"))
+
+	RateLimited(RateLimitClient, "tenant1")
+
+	assert.Equal(t, clientBefore+1, testutil.ToFloat64(rateLimited.WithLabelValues(RateLimitClient, "tenant1")))
+	assert.Equal(t, tenantBefore, testutil.ToFloat64(rateLimited.WithLabelValues(RateLimitTenant, "tenant1")))
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	before := testutil.ToFloat64(quotaRejections.WithLabelValues(QuotaBatchesPerDay, "tenant1"))
+
+	QuotaExceeded(QuotaBatchesPerDay, "tenant1")
+	QuotaExceeded(QuotaBatchesPerDay, "tenant1")
+
+	assert.Equal(t, before+2, testutil.ToFloat64(quotaRejections.WithLabelValues(QuotaBatchesPerDay, "tenant1")))
+}
+
+func TestNewServer(t *testing.T) {
+	server := NewServer(9090)
+	assert.Equal(t, ":9090", server.Addr)
+
+	NotificationPublishFailed(TenantNotification)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `hri_notification_publish_failures_total{type="tenant"}`)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hri/tenants", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,80 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UnknownTenant is the tenant label of the requests whose tenant wasn't verified. Their tenant ids come from the
+// caller, so they would create a series per id.
+const UnknownTenant = "unknown"
+
+type tenantAuthorizedKey struct{}
+
+// TenantAuthorized records that the request's caller was authorized for its tenant, so the tenant is used as the
+// request's label. It does nothing when the request isn't counted.
+func TenantAuthorized(ctx context.Context) {
+	if authorized, ok := ctx.Value(tenantAuthorizedKey{}).(*bool); ok {
+		*authorized = true
+	}
+}
+
+// Middleware counts every request and records its latency. The route is the path the request was matched to, so
+// batch and stream ids don't create a series per id. Requests that don't match any route are counted as 'unmatched'.
+// The tenant label is only set for matched requests whose caller was authorized for the tenant, or that succeeded,
+// and otherwise is UnknownTenant.
+func Middleware() echo.MiddlewareFunc {
+	var once sync.Once
+	routes := map[string]bool{}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// the routes are all configured before the server starts
+			once.Do(func() {
+				for _, route := range c.Echo().Routes() {
+					routes[route.Path] = true
+				}
+			})
+
+			authorized := false
+			request := c.Request()
+			c.SetRequest(request.WithContext(context.WithValue(request.Context(), tenantAuthorizedKey{}, &authorized)))
+
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// sets the response status of the error
+				c.Error(err)
+			}
+
+			// Echo sets the path of unmatched requests to the request's path
+			route := c.Path()
+			if !routes[route] {
+				route = "unmatched"
+			}
+			code := c.Response().Status
+			status := strconv.Itoa(code)
+			// the tenant id comes from the caller until it's authorized, and an admin may request a tenant that
+			// doesn't exist
+			tenant := c.Param(param.TenantId)
+			if tenant != "" && (route == "unmatched" || code == http.StatusNotFound || (!authorized && code >= 400)) {
+				tenant = UnknownTenant
+			}
+
+			httpRequests.WithLabelValues(route, c.Request().Method, status, tenant).Inc()
+			httpRequestDuration.WithLabelValues(route, c.Request().Method, status, tenant).
+				Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	batchRoute := fmt.Sprintf("/hri/tenants/:%s/batches/:%s", param.TenantId, param.BatchId)
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET(batchRoute, func(c echo.Context) error {
+		return c.String(http.StatusOK, "batch")
+	})
+	e.POST(batchRoute, func(c echo.Context) error {
+		TenantAuthorized(c.Request().Context())
+		return echo.NewHTTPError(http.StatusConflict, "conflict")
+	})
+	e.PUT(batchRoute, func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusUnauthorized, "missing token")
+	})
+	e.DELETE(batchRoute, func(c echo.Context) error {
+		TenantAuthorized(c.Request().Context())
+		return echo.NewHTTPError(http.StatusNotFound, "tenant not found")
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected []string
+	}{
+		{
+			name:     "success",
+			method:   http.MethodGet,
+			path:     "/hri/tenants/tenant1/batches/batch1",
+			expected: []string{batchRoute, http.MethodGet, "200", "tenant1"},
+		},
+		{
+			name:     "handler error",
+			method:   http.MethodPost,
+			path:     "/hri/tenants/tenant2/batches/batch1",
+			expected: []string{batchRoute, http.MethodPost, "409", "tenant2"},
+		},
+		{
+			name:     "unauthorized",
+			method:   http.MethodPut,
+			path:     "/hri/tenants/random1/batches/batch1",
+			expected: []string{batchRoute, http.MethodPut, "401", UnknownTenant},
+		},
+		{
+			name:     "tenant not found",
+			method:   http.MethodDelete,
+			path:     "/hri/tenants/random2/batches/batch1",
+			expected: []string{batchRoute, http.MethodDelete, "404", UnknownTenant},
+		},
+		{
+			name:     "unmatched route",
+			method:   http.MethodGet,
+			path:     "/unknown",
+			expected: []string{"unmatched", http.MethodGet, "404", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpRequests.Reset()
+			httpRequestDuration.Reset()
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, float64(1), testutil.ToFloat64(httpRequests.WithLabelValues(tt.expected...)))
+			assert.Equal(t, 1, testutil.CollectAndCount(httpRequestDuration))
+		})
+	}
+}
+
+func TestTenantAuthorizedWithoutMetrics(t *testing.T) {
+	// requests that aren't counted don't have a label to set
+	assert.NotPanics(t, func() { TenantAuthorized(context.Background()) })
+}
@@ -12,6 +12,7 @@ type CreateBatch struct {
 	DataType         string                 `json:"dataType" validate:"required,injection-check-validator"`
 	InvalidThreshold int                    `json:"invalidThreshold"`
 	Metadata         map[string]interface{} `json:"metadata"`
+	TenantConfig     TenantConfig           `json:"-" validate:"-"` // not part of the incoming request
 }
 
 type GetBatch struct {
@@ -22,6 +23,8 @@ type GetBatch struct {
 	LteDate  *string `query:"lteDate" validate:"omitempty,injection-check-validator"`
 	Size     *int    `query:"size"`
 	From     *int    `query:"from"`
+	// also return batches that were archived because they exceeded their retention
+	IncludeArchived bool `query:"includeArchived"`
 }
 
 type GetByIdBatch struct {
@@ -79,6 +82,147 @@ type DeleteStreamRequest struct {
 	StreamId string `param:"id" validate:"required,streamid-validator"`
 }
 
+type GetStreamLagRequest struct {
+	TenantId string `param:"tenantId" validate:"required"`
+	StreamId string `param:"id" validate:"required,streamid-validator"`
+}
+
 type CreateTenant struct {
 	TenantId string `param:"tenantId" validate:"required,tenantid-validator"`
 }
+
+type DeleteTenant struct {
+	TenantId string `param:"tenantId" validate:"required"`
+	Cascade  bool   `query:"cascade"`
+	DryRun   bool   `query:"dryRun"`
+	Force    bool   `query:"force"`
+}
+
+// TenantConfig is the per-tenant settings document. Unset fields fall back to the server defaults.
+type TenantConfig struct {
+	TenantId                string            `param:"tenantId" json:"-" validate:"required"`
+	DisplayName             string            `json:"displayName,omitempty" validate:"omitempty,injection-check-validator"`
+	Contact                 string            `json:"contact,omitempty" validate:"omitempty,injection-check-validator"`
+	DefaultInvalidThreshold *int              `json:"defaultInvalidThreshold,omitempty" validate:"omitempty,min=-1"`
+	AllowedDataTypes        []string          `json:"allowedDataTypes,omitempty" validate:"omitempty,dive,required,injection-check-validator"`
+	BatchTimeoutSeconds     *int              `json:"batchTimeoutSeconds,omitempty" validate:"omitempty,min=60"`
+	RetentionDays           *int              `json:"retentionDays,omitempty" validate:"omitempty,min=1"`
+	Quota                   *TenantQuota      `json:"quota,omitempty"`
+	Suspension              *TenantSuspension `json:"suspension,omitempty" validate:"-"` // only set by the suspend action
+	LegalHold               *LegalHold        `json:"legalHold,omitempty" validate:"-"`  // only set by the legal hold actions
+}
+
+type TenantQuota struct {
+	MaxActiveBatches *int `json:"maxActiveBatches,omitempty" validate:"omitempty,min=1"`
+	// started batches each integrator can have at once
+	MaxStartedBatchesPerIntegrator *int `json:"maxStartedBatchesPerIntegrator,omitempty" validate:"omitempty,min=1"`
+	// batches that can be created each day, counted from midnight UTC
+	MaxBatchesPerDay *int `json:"maxBatchesPerDay,omitempty" validate:"omitempty,min=1"`
+	// override the server's tenant rate limit
+	RequestsPerSecond *float64 `json:"requestsPerSecond,omitempty" validate:"omitempty,gt=0"`
+	RequestBurst      *int     `json:"requestBurst,omitempty" validate:"omitempty,min=1"`
+}
+
+type GetTenantConfig struct {
+	TenantId string `param:"tenantId" validate:"required"`
+}
+
+// TenantSuspension is present in a tenant's configuration while the tenant is suspended
+type TenantSuspension struct {
+	Reason      string `json:"reason,omitempty"`
+	SuspendDate string `json:"suspendDate"`
+}
+
+type SuspendTenant struct {
+	TenantId string `param:"tenantId" validate:"required"`
+	Reason   string `json:"reason" validate:"omitempty,injection-check-validator"`
+}
+
+type ResumeTenant struct {
+	TenantId string `param:"tenantId" validate:"required"`
+}
+
+type PurgeTenant struct {
+	TenantId      string `param:"tenantId" validate:"required"`
+	OlderThanDays *int   `json:"olderThanDays" validate:"omitempty,min=0"`
+}
+
+// LegalHold is present on a batch, or in a tenant's configuration, while it is under legal hold. Held batches, and all
+// the batches of a held tenant, can't be archived, purged or deleted.
+type LegalHold struct {
+	Reason   string `json:"reason"`
+	Actor    string `json:"actor"`
+	HoldDate string `json:"holdDate"`
+}
+
+type BatchLegalHold struct {
+	TenantId string `param:"tenantId" validate:"required"`
+	BatchId  string `param:"id" validate:"required"`
+	Reason   string `json:"reason" validate:"required,injection-check-validator"`
+}
+
+type TenantLegalHold struct {
+	TenantId string `param:"tenantId" validate:"required"`
+	Reason   string `json:"reason" validate:"required,injection-check-validator"`
+}
+
+type ExportTenant struct {
+	TenantId string `param:"tenantId" validate:"required"`
+}
+
+// ImportTenant options are query parameters, the request body is a tenant export
+type ImportTenant struct {
+	TenantId    string `param:"tenantId" validate:"required,tenantid-validator"`
+	PreserveIds bool   `query:"preserveIds"`
+	OnConflict  string `query:"onConflict" validate:"omitempty,oneof=fail skip overwrite"`
+}
+
+// StreamDefinition is a stream's settings in a tenant export. They are the same as in a CreateStreamsRequest.
+type StreamDefinition struct {
+	StreamId          string  `json:"streamId" validate:"required,streamid-validator"`
+	NumPartitions     *int64  `json:"numPartitions" validate:"required,min=1,max=99"`
+	RetentionMs       *int    `json:"retentionMs" validate:"required,min=3600000,max=2592000000"`
+	CleanupPolicy     *string `json:"cleanupPolicy,omitempty" validate:"omitempty,oneof=delete compact"`
+	RetentionBytes    *int    `json:"retentionBytes,omitempty" validate:"omitempty,min=10485760,max=1073741824"`
+	SegmentMs         *int    `json:"segmentMs,omitempty" validate:"omitempty,min=300000,max=2592000000"`
+	SegmentBytes      *int    `json:"segmentBytes,omitempty" validate:"omitempty,min=10485760,max=536870912"`
+	SegmentIndexBytes *int    `json:"segmentIndexBytes,omitempty" validate:"omitempty,min=102400,max=104857600"`
+}
+
+// CreateStreamsRequest returns the request that creates the stream for the tenant
+func (d StreamDefinition) CreateStreamsRequest(tenantId string) CreateStreamsRequest {
+	return CreateStreamsRequest{
+		TenantId:          tenantId,
+		StreamId:          d.StreamId,
+		NumPartitions:     d.NumPartitions,
+		RetentionMs:       d.RetentionMs,
+		CleanupPolicy:     d.CleanupPolicy,
+		RetentionBytes:    d.RetentionBytes,
+		SegmentMs:         d.SegmentMs,
+		SegmentBytes:      d.SegmentBytes,
+		SegmentIndexBytes: d.SegmentIndexBytes,
+	}
+}
+
+// ApiKey is an API key's document. Only the key's hash is stored, the key itself is returned once when it's created.
+type ApiKey struct {
+	KeyId       string   `json:"-"`
+	Name        string   `json:"name"`
+	Hash        string   `json:"hash"`
+	Scopes      []string `json:"scopes,omitempty"`
+	Tenants     []string `json:"tenants,omitempty"`
+	CreatedBy   string   `json:"createdBy"`
+	CreatedDate string   `json:"createdDate"`
+	RevokedBy   string   `json:"revokedBy,omitempty"`
+	RevokedDate string   `json:"revokedDate,omitempty"`
+}
+
+type CreateApiKey struct {
+	Name    string   `json:"name" validate:"required,injection-check-validator"`
+	Scopes  []string `json:"scopes" validate:"required,dive,oneof=hri_data_integrator hri_consumer hri_internal hri_admin"` // the HRI roles
+	Tenants []string `json:"tenants" validate:"omitempty,dive,required,tenantid-validator"`
+}
+
+type RevokeApiKey struct {
+	KeyId string `param:"keyId" validate:"required"`
+}
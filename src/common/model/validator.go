@@ -90,7 +90,9 @@ func getNameFromStructField(fld reflect.StructField) string {
 	}
 	for _, location := range possibleArgumentLocations {
 		name, exists := fld.Tag.Lookup(location[0])
-		if exists {
+		// drop tag options like ",omitempty" and skip fields excluded from this location with "-"
+		name = strings.SplitN(name, ",", 2)[0]
+		if exists && name != "-" {
 			return fmt.Sprintf("%s (%s)", name, location[1])
 		}
 	}
@@ -33,6 +33,11 @@ type TestRequiredWithoutBindStruct struct {
 	RequiredWithoutsFriend string `param:"thing2"`
 }
 
+type TestTagOptionsBindStruct struct {
+	PathOnly   string `param:"pathOnly" json:"-" validate:"required"`
+	OptionalGt *int   `json:"optionalGt,omitempty" validate:"omitempty,min=5"`
+}
+
 type TestEmbeddedStruct struct {
 	TestAlwaysRequiredBindStruct
 	AnotherElement int `json:"regularDegularInt"`
@@ -86,6 +91,11 @@ func TestValidate(t *testing.T) {
 			boundInput:    TestRequiredWithoutBindStruct{},
 			expectedError: errors.New("invalid request arguments:\n- thing1 (url path parameter) must be present if thing2 (url path parameter) is not present"),
 		},
+		{
+			name:          "tag options and skipped json fields",
+			boundInput:    TestTagOptionsBindStruct{OptionalGt: new(int)},
+			expectedError: errors.New("invalid request arguments:\n- optionalGt (json field in request body) must be 5 or greater\n- pathOnly (url path parameter) is a required field"),
+		},
 		{
 			name:          "embedded struct failures",
 			boundInput:    TestEmbeddedStruct{},
@@ -15,6 +15,7 @@ const (
 	IntegratorId        string = "integratorId"
 	Status              string = "status"
 	StartDate           string = "startDate"
+	EndDate             string = "endDate"
 	GteDate             string = "gteDate"
 	LteDate             string = "lteDate"
 	Topic               string = "topic"
@@ -24,6 +25,11 @@ const (
 	InvalidThreshold    string = "invalidThreshold"
 	InvalidRecordCount  string = "invalidRecordCount"
 	FailureMessage      string = "failureMessage"
+	Archived            string = "archived"
+	IncludeArchived     string = "includeArchived"
+	LegalHold           string = "legalHold"
+	ApiKeyId            string = "keyId"
+	ApiKey              string = "key"
 
 	Size string = "size"
 	From string = "from"
@@ -0,0 +1,237 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const RetryAfterHeader = "Retry-After"
+
+const (
+	msgClientRateLimited = "Client [%s] exceeded its rate limit, retry after %d seconds"
+	msgTenantRateLimited = "Tenant [%s] exceeded its rate limit, retry after %d seconds"
+)
+
+// how long a tenant's rate limit is used before its configuration is read again
+const tenantLimitTtl = time.Minute
+
+// how often the buckets of idle clients are removed
+const sweepInterval = 10 * time.Minute
+
+// QuotaGetter returns a tenant's quota, nil when the tenant doesn't have one
+type QuotaGetter func(ctx context.Context, tenantId string) (*model.TenantQuota, error)
+
+// Limiter throttles requests with a token bucket per client and per tenant. A tenant's bucket uses the rate and burst
+// of its quota when it has one, otherwise the server's.
+type Limiter struct {
+	clientLimit rate.Limit
+	clientBurst int
+	tenantLimit rate.Limit
+	tenantBurst int
+	getQuota    QuotaGetter
+	now         func() time.Time
+
+	mutex     sync.Mutex
+	clients   map[string]*clientBucket
+	tenants   map[string]*tenantBucket
+	lastSweep time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// tenantBucket's limiter is nil when the tenant isn't limited
+type tenantBucket struct {
+	limiter *rate.Limiter
+	expires time.Time
+}
+
+// Rejection is returned for a request that exceeded a rate limit
+type Rejection struct {
+	RetryAfter time.Duration
+	Detail     *response.ErrorDetail
+}
+
+// NewLimiter returns a limiter with the configured limits, the tenants' quotas are read from Elastic
+func NewLimiter(config config.Config) *Limiter {
+	getQuota := func(ctx context.Context, tenantId string) (*model.TenantQuota, error) {
+		client, err := elastic.ClientFromConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		tenantConfig, elasticErr := elastic.GetTenantConfig(ctx, tenantId, client)
+		if elasticErr != nil {
+			return nil, elasticErr
+		}
+		return tenantConfig.Quota, nil
+	}
+	return NewLimiterWithQuotas(config, getQuota)
+}
+
+// NewLimiterWithQuotas returns a limiter with the configured limits, that reads the tenants' quotas with getQuota
+func NewLimiterWithQuotas(config config.Config, getQuota QuotaGetter) *Limiter {
+	return newLimiter(config, getQuota, time.Now)
+}
+
+func newLimiter(config config.Config, getQuota QuotaGetter, now func() time.Time) *Limiter {
+	return &Limiter{
+		clientLimit: rate.Limit(config.RateLimitClientRps),
+		clientBurst: config.RateLimitClientBurst,
+		tenantLimit: rate.Limit(config.RateLimitTenantRps),
+		tenantBurst: config.RateLimitTenantBurst,
+		getQuota:    getQuota,
+		now:         now,
+		clients:     map[string]*clientBucket{},
+		tenants:     map[string]*tenantBucket{},
+		lastSweep:   now(),
+	}
+}
+
+// ClientKey identifies the caller of a request: the id of its API key when it used one, otherwise its subject
+func ClientKey(request *http.Request, claims auth.HriClaims) string {
+	if request.Header.Get(echo.HeaderAuthorization) == "" && request.Header.Get(auth.ApiKeyHeader) != "" {
+		return "apikey:" + claims.ClientId
+	}
+	return "sub:" + claims.Subject
+}
+
+// Check takes a token from the client's bucket and from the tenant's, and returns a Rejection when either is empty.
+// The client isn't limited when it's empty, e.g. when authorization is disabled. A nil Limiter doesn't limit anything.
+func (l *Limiter) Check(ctx context.Context, requestId string, client string, tenantId string) *Rejection {
+	if l == nil {
+		return nil
+	}
+	prefix := "ratelimit/check"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
+
+	// read the tenant's quota before locking, so other requests don't wait for Elastic
+	tenantLimiter := l.tenantLimiter(ctx, requestId, tenantId)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	var clientReservation *rate.Reservation
+	if client != "" && l.clientLimit > 0 {
+		bucket, ok := l.clients[client]
+		if !ok {
+			bucket = &clientBucket{limiter: rate.NewLimiter(l.clientLimit, l.clientBurst)}
+			l.clients[client] = bucket
+		}
+		bucket.lastUsed = now
+
+		clientReservation = bucket.limiter.ReserveN(now, 1)
+		if delay := clientReservation.DelayFrom(now); delay > 0 {
+			clientReservation.CancelAt(now)
+			metrics.RateLimited(metrics.RateLimitClient, tenantId)
+			msg := fmt.Sprintf(msgClientRateLimited, client, retryAfterSecs(delay))
+			logger.Errorln(msg)
+			return &Rejection{RetryAfter: delay, Detail: response.NewErrorDetail(requestId, msg)}
+		}
+	}
+
+	if tenantLimiter != nil {
+		tenantReservation := tenantLimiter.ReserveN(now, 1)
+		if delay := tenantReservation.DelayFrom(now); delay > 0 {
+			// the request isn't made, so it doesn't count against the client's limit
+			tenantReservation.CancelAt(now)
+			if clientReservation != nil {
+				clientReservation.CancelAt(now)
+			}
+			metrics.RateLimited(metrics.RateLimitTenant, tenantId)
+			msg := fmt.Sprintf(msgTenantRateLimited, tenantId, retryAfterSecs(delay))
+			logger.Errorln(msg)
+			return &Rejection{RetryAfter: delay, Detail: response.NewErrorDetail(requestId, msg)}
+		}
+	}
+	return nil
+}
+
+// tenantLimiter returns the tenant's bucket, after reading its quota when it expired. When the quota can't be read,
+// the server's limit is used until it's read again.
+func (l *Limiter) tenantLimiter(ctx context.Context, requestId string, tenantId string) *rate.Limiter {
+	l.mutex.Lock()
+	bucket, ok := l.tenants[tenantId]
+	l.mutex.Unlock()
+	if ok && l.now().Before(bucket.expires) {
+		return bucket.limiter
+	}
+
+	limit, burst := l.tenantLimit, l.tenantBurst
+	quota, err := l.getQuota(ctx, tenantId)
+	if err != nil {
+		logwrapper.GetMyTenantLogger(requestId, "ratelimit/tenantLimiter", tenantId).Errorf(
+			"Unable to read the tenant's quota, using the server's rate limit: %s", err.Error())
+	} else if quota != nil {
+		if quota.RequestsPerSecond != nil {
+			limit = rate.Limit(*quota.RequestsPerSecond)
+		}
+		if quota.RequestBurst != nil {
+			burst = *quota.RequestBurst
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if limit <= 0 || burst < 1 {
+		l.tenants[tenantId] = &tenantBucket{expires: l.now().Add(tenantLimitTtl)}
+		return nil
+	}
+	// the tokens left in the bucket are kept when the limit is refreshed
+	if ok && bucket.limiter != nil {
+		bucket.limiter.SetLimitAt(l.now(), limit)
+		bucket.limiter.SetBurstAt(l.now(), burst)
+	} else {
+		bucket = &tenantBucket{limiter: rate.NewLimiter(limit, burst)}
+	}
+	bucket.expires = l.now().Add(tenantLimitTtl)
+	l.tenants[tenantId] = bucket
+	return bucket.limiter
+}
+
+// sweep removes the buckets of clients that were idle long enough for their bucket to be full again, which is the
+// same as not having one. Must be called with the lock held.
+func (l *Limiter) sweep(now time.Time) {
+	if l.clientLimit <= 0 || now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(float64(l.clientBurst) / float64(l.clientLimit) * float64(time.Second))
+	for client, bucket := range l.clients {
+		if now.Sub(bucket.lastUsed) > refill {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// Respond sends the 429 response with the number of seconds to wait in the Retry-After header
+func (r *Rejection) Respond(c echo.Context) error {
+	c.Response().Header().Set(RetryAfterHeader, strconv.Itoa(retryAfterSecs(r.RetryAfter)))
+	return c.JSON(http.StatusTooManyRequests, r.Detail)
+}
+
+func retryAfterSecs(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
@@ -0,0 +1,184 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	requestId = "requestId"
+	tenant1   = "tenant1"
+	tenant2   = "tenant2"
+)
+
+// fakeClock only moves when it's advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.now = fc.now.Add(d)
+}
+
+func noQuota(context.Context, string) (*model.TenantQuota, error) {
+	return nil, nil
+}
+
+func TestLimiterClient(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	clock := &fakeClock{now: time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)}
+	limiter := newLimiter(config.Config{RateLimitClientRps: 0.5, RateLimitClientBurst: 2}, noQuota, clock.Now)
+
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant2))
+
+	rejection := limiter.Check(context.Background(), requestId, "sub:client1", tenant1)
+	if assert.NotNil(t, rejection) {
+		assert.Equal(t, 2*time.Second, rejection.RetryAfter)
+		assert.Equal(t, response.NewErrorDetail(requestId,
+			"Client [sub:client1] exceeded its rate limit, retry after 2 seconds"), rejection.Detail)
+	}
+
+	// other clients have their own bucket, and requests without a client aren't limited
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client2", tenant1))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, limiter.Check(context.Background(), requestId, "", tenant1))
+	}
+
+	clock.Advance(2 * time.Second)
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	assert.NotNil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+}
+
+func TestLimiterTenant(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	clock := &fakeClock{now: time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)}
+	reads := 0
+	getQuota := func(_ context.Context, tenantId string) (*model.TenantQuota, error) {
+		reads++
+		switch tenantId {
+		case tenant1:
+			requestsPerSecond, burst := 1.0, 3
+			return &model.TenantQuota{RequestsPerSecond: &requestsPerSecond, RequestBurst: &burst}, nil
+		case tenant2:
+			return nil, errors.New("elasticsearch client error: connection refused")
+		}
+		return nil, nil
+	}
+	limiter := newLimiter(config.Config{
+		RateLimitClientRps:   10,
+		RateLimitClientBurst: 10,
+		RateLimitTenantRps:   1,
+		RateLimitTenantBurst: 1,
+	}, getQuota, clock.Now)
+
+	// tenant1's quota overrides the server's burst
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	}
+	rejection := limiter.Check(context.Background(), requestId, "sub:client2", tenant1)
+	if assert.NotNil(t, rejection) {
+		assert.Equal(t, time.Second, rejection.RetryAfter)
+		assert.Equal(t, response.NewErrorDetail(requestId,
+			"Tenant [tenant1] exceeded its rate limit, retry after 1 seconds"), rejection.Detail)
+	}
+
+	// the server's limit is used when the quota can't be read
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant2))
+	assert.NotNil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant2))
+	assert.Equal(t, 2, reads)
+
+	// the quota is read again once it expired
+	clock.Advance(tenantLimitTtl)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	}
+	assert.NotNil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	assert.Equal(t, 3, reads)
+}
+
+func TestLimiterTenantRejectionKeepsClientTokens(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	clock := &fakeClock{now: time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)}
+	limiter := newLimiter(config.Config{
+		RateLimitClientRps:   1,
+		RateLimitClientBurst: 2,
+		RateLimitTenantRps:   1,
+		RateLimitTenantBurst: 1,
+	}, noQuota, clock.Now)
+
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	assert.NotNil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	// the request rejected by tenant1's limit didn't take client1's last token
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant2))
+	assert.NotNil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant2))
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	var limiter *Limiter
+	assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+
+	limiter = newLimiter(config.Config{}, noQuota, time.Now)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, limiter.Check(context.Background(), requestId, "sub:client1", tenant1))
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)}
+	limiter := newLimiter(config.Config{RateLimitClientRps: 1, RateLimitClientBurst: 5}, noQuota, clock.Now)
+
+	limiter.Check(context.Background(), requestId, "sub:client1", tenant1)
+	clock.Advance(sweepInterval - time.Second)
+	limiter.Check(context.Background(), requestId, "sub:client2", tenant1)
+	assert.Len(t, limiter.clients, 2)
+
+	clock.Advance(time.Second)
+	limiter.Check(context.Background(), requestId, "sub:client3", tenant1)
+	assert.Len(t, limiter.clients, 2)
+	assert.NotContains(t, limiter.clients, "sub:client1")
+}
+
+func TestClientKey(t *testing.T) {
+	claims := auth.HriClaims{Subject: "integrator", ClientId: "keyId"}
+
+	request := httptest.NewRequest(http.MethodGet, "/hri/tenants/tenant1/batches", nil)
+	request.Header.Set(auth.ApiKeyHeader, "hri_keyId_secret")
+	assert.Equal(t, "apikey:keyId", ClientKey(request, claims))
+
+	request.Header.Set(echo.HeaderAuthorization, "Bearer token")
+	assert.Equal(t, "sub:integrator", ClientKey(request, claims))
+}
+
+func TestRejectionRespond(t *testing.T) {
+	rejection := &Rejection{RetryAfter: 1500 * time.Millisecond, Detail: response.NewErrorDetail(requestId, "slow down")}
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/hri/tenants/tenant1/batches", nil), rec)
+	assert.NoError(t, rejection.Respond(c))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(RetryAfterHeader))
+	assert.JSONEq(t, `{"errorEventId":"requestId","errorDescription":"slow down"}`, rec.Body.String())
+}
@@ -0,0 +1,33 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package response
+
+import "net/http"
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 problem detail, the error body of the v2 API
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// the request's id, for finding it in the logs like the ErrorDetail's errorEventId
+	ErrorEventId string `json:"errorEventId,omitempty"`
+}
+
+// NewProblem returns a problem without a specific type, so its title is the status' text
+func NewProblem(status int, requestId string, detail string, instance string) *Problem {
+	return &Problem{
+		Type:         "about:blank",
+		Title:        http.StatusText(status),
+		Status:       status,
+		Detail:       detail,
+		Instance:     instance,
+		ErrorEventId: requestId,
+	}
+}
@@ -58,3 +58,17 @@ func TestNewErrorDetailResponse(t *testing.T) {
 		t.Errorf("expected [%v] but have [%v]", expectedErrorDetail, result)
 	}
 }
+
+func TestNewProblem(t *testing.T) {
+	result := NewProblem(http.StatusForbidden, "requestId", "Tenant 'tenant2' isn't included in the scopes",
+		"/hri/v2/tenants/tenant2/batches")
+
+	assert.Equal(t, &Problem{
+		Type:         "about:blank",
+		Title:        "Forbidden",
+		Status:       http.StatusForbidden,
+		Detail:       "Tenant 'tenant2' isn't included in the scopes",
+		Instance:     "/hri/v2/tenants/tenant2/batches",
+		ErrorEventId: "requestId",
+	}, result)
+}
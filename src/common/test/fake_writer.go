@@ -6,6 +6,7 @@
 package test
 
 import (
+	"context"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"reflect"
 	"testing"
@@ -19,7 +20,7 @@ type FakeWriter struct {
 	Error         error
 }
 
-func (fw FakeWriter) Write(topic string, key string, val map[string]interface{}) error {
+func (fw FakeWriter) Write(_ context.Context, topic string, key string, val map[string]interface{}) error {
 	if topic != fw.ExpectedTopic {
 		fw.T.Errorf("Unexpected topic. Expected: [%s], Actual: [%s]", fw.ExpectedTopic, topic)
 	}
@@ -0,0 +1,53 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tracing
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// elasticTransport starts a client span for every request to Elasticsearch, as a child of the span in the request's
+// context
+type elasticTransport struct {
+	next http.RoundTripper
+}
+
+// NewElasticTransport wraps the transport of an Elasticsearch client, or the default transport when it's nil
+func NewElasticTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return elasticTransport{next: next}
+}
+
+func (t elasticTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(request.Context(), "elasticsearch "+request.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemElasticsearch,
+			semconv.HTTPMethodKey.String(request.Method),
+			// the path has the index and document id, the query and body may have PHI so they aren't recorded
+			attribute.String("db.elasticsearch.path", request.URL.Path),
+		),
+	)
+	defer span.End()
+
+	response, err := t.next.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return response, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(response.StatusCode))
+	if response.StatusCode >= http.StatusBadRequest && response.StatusCode != http.StatusNotFound {
+		span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+	}
+	return response, nil
+}
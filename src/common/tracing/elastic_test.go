@@ -0,0 +1,65 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tracing
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeRoundTripper struct {
+	statusCode int
+	err        error
+}
+
+func (f fakeRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{StatusCode: f.statusCode}, nil
+}
+
+func TestElasticTransport(t *testing.T) {
+	tests := []struct {
+		name           string
+		next           fakeRoundTripper
+		expectedStatus codes.Code
+	}{
+		{name: "success", next: fakeRoundTripper{statusCode: http.StatusOK}, expectedStatus: codes.Unset},
+		{name: "not found", next: fakeRoundTripper{statusCode: http.StatusNotFound}, expectedStatus: codes.Unset},
+		{name: "error status", next: fakeRoundTripper{statusCode: http.StatusConflict}, expectedStatus: codes.Error},
+		{name: "transport error", next: fakeRoundTripper{err: errors.New("connection refused")}, expectedStatus: codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			ctx, parent := Tracer().Start(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "parent")
+
+			request := httptest.NewRequest(http.MethodPost, "/tenant1-batches/_doc/batch1/_update", nil).WithContext(ctx)
+			_, err := NewElasticTransport(tt.next).RoundTrip(request)
+			parent.End()
+			assert.Equal(t, tt.next.err, err)
+
+			spans := recorder.Ended()
+			if assert.Len(t, spans, 2) {
+				span := spans[0]
+				assert.Equal(t, "elasticsearch POST", span.Name())
+				assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+				assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+				assert.Contains(t, span.Attributes(), attribute.String("db.elasticsearch.path", "/tenant1-batches/_doc/batch1/_update"))
+				assert.Equal(t, tt.expectedStatus, span.Status().Code)
+			}
+		})
+	}
+	assert.Equal(t, http.DefaultTransport, NewElasticTransport(nil).(elasticTransport).next)
+}
@@ -0,0 +1,98 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"os"
+)
+
+const (
+	serviceName = "hri-mgmt-api"
+	tracerName  = "github.com/Alvearie/hri-mgmt-api"
+)
+
+// the probes and the metrics scrapes are called every few seconds, their traces would only be noise
+var skippedPaths = map[string]bool{
+	"/alive":           true,
+	"/ready":           true,
+	"/hri/healthcheck": true,
+	"/metrics":         true,
+}
+
+// Initialize sets up the configured trace exporter and the W3C trace context propagation. The returned function
+// flushes the spans that haven't been exported yet and stops the exporter. When tracing is disabled, the spans aren't
+// recorded, but the trace context of incoming requests is still propagated to Kafka.
+func Initialize(serverConfig config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch serverConfig.TracingExporter {
+	case "", config.TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case config.TracingExporterOtlp:
+		exporter, err = newOtlpExporter(serverConfig.TracingOtlpEndpoint)
+	default:
+		err = fmt.Errorf("unknown trace exporter '%s'", serverConfig.TracingExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newOtlpExporter returns an exporter that sends the spans to an OTLP/HTTP endpoint, e.g. http://collector:4318
+func newOtlpExporter(endpoint string) (sdktrace.SpanExporter, error) {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpointUrl.Host)}
+	if endpointUrl.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if endpointUrl.Path != "" && endpointUrl.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(endpointUrl.Path))
+	}
+	// the exporter connects when it sends the first spans, so a collector that isn't up yet doesn't stop the server
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+// Tracer returns the tracer for the HRI's own spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Middleware starts a server span for every request, continuing the trace of the caller's traceparent header. The
+// span is named after the request's route.
+func Middleware() echo.MiddlewareFunc {
+	return otelecho.Middleware(serviceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		return skippedPaths[c.Path()]
+	}))
+}
@@ -0,0 +1,109 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tracing
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordSpans sets a tracer provider that records the ended spans, until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestInitialize(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	tests := []struct {
+		name        string
+		config      config.Config
+		recording   bool
+		expectedErr string
+	}{
+		{
+			name:   "disabled",
+			config: config.Config{TracingExporter: config.TracingExporterNone},
+		},
+		{
+			name:      "stdout",
+			config:    config.Config{TracingExporter: config.TracingExporterStdout},
+			recording: true,
+		},
+		{
+			name:      "otlp",
+			config:    config.Config{TracingExporter: config.TracingExporterOtlp, TracingOtlpEndpoint: "http://localhost:4318/custom/v1/traces"},
+			recording: true,
+		},
+		{
+			name:        "unknown exporter",
+			config:      config.Config{TracingExporter: "jaeger"},
+			expectedErr: "unable to create the trace exporter: unknown trace exporter 'jaeger'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+			shutdown, err := Initialize(tt.config)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			_, span := Tracer().Start(context.Background(), "test")
+			assert.Equal(t, tt.recording, span.IsRecording())
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := recordSpans(t)
+	Initialize(config.Config{})
+
+	e := echo.New()
+	e.Use(Middleware())
+	var handlerSpan trace.SpanContext
+	e.GET("/hri/tenants/:tenantId/batches", func(c echo.Context) error {
+		handlerSpan = trace.SpanContextFromContext(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/alive", func(c echo.Context) error {
+		return c.String(http.StatusOK, "yes")
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/hri/tenants/tenant1/batches", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), request)
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/alive", nil))
+
+	// the probe isn't traced
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "/hri/tenants/:tenantId/batches", spans[0].Name())
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+		assert.Equal(t, spans[0].SpanContext(), handlerSpan)
+	}
+}
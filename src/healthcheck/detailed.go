@@ -0,0 +1,166 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+const (
+	dependencyElastic        = "elasticsearch"
+	dependencyKafka          = "kafka"
+	dependencyOidcKeys       = "oidcKeys"
+	dependencySchemaRegistry = "schemaRegistry"
+)
+
+// the fields of Elastic's cluster health that are reported
+var clusterHealthFields = []string{"cluster_name", "status", "number_of_nodes", "number_of_data_nodes",
+	"active_primary_shards", "active_shards", "relocating_shards", "initializing_shards", "unassigned_shards",
+	"active_shards_percent_as_number"}
+
+var schemaRegistryClient = &http.Client{Timeout: 5 * time.Second}
+
+// DetailedHealth is returned by the verbose healthcheck. The HRI is up when all of its dependencies are.
+type DetailedHealth struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
+
+type DependencyHealth struct {
+	Status    string                 `json:"status"`
+	LatencyMs int64                  `json:"latencyMs"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// GetDetailed checks each dependency, until ctx is done, and reports its health and how long the check took. The OIDC
+// keys are only checked when they're not nil, i.e. when authorization is enabled, and the schema registry when its URL
+// is set.
+func GetDetailed(ctx context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker,
+	keys *auth.KeyCacheState, schemaRegistryUrl string) (int, DetailedHealth) {
+	prefix := "healthcheck/getDetailed"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	logger.Debugln("Start detailed HealthCheck")
+
+	health := DetailedHealth{Status: StatusUp, Dependencies: map[string]DependencyHealth{
+		dependencyElastic: checkDependency(func() (map[string]interface{}, error) {
+			return checkElastic(ctx, client)
+		}),
+		dependencyKafka: checkDependency(func() (map[string]interface{}, error) {
+			brokers, err := healthChecker.BrokerCount(ctx)
+			return map[string]interface{}{"brokers": brokers}, err
+		}),
+	}}
+	if keys != nil {
+		health.Dependencies[dependencyOidcKeys] = checkDependency(func() (map[string]interface{}, error) {
+			return checkOidcKeys(*keys)
+		})
+	}
+	if schemaRegistryUrl != "" {
+		health.Dependencies[dependencySchemaRegistry] = checkDependency(func() (map[string]interface{}, error) {
+			return checkSchemaRegistry(ctx, schemaRegistryUrl)
+		})
+	}
+
+	for name, dependency := range health.Dependencies {
+		if dependency.Status != StatusUp {
+			logger.Errorf("%s is down: %s", name, dependency.Error)
+			health.Status = StatusDown
+		}
+	}
+	if health.Status != StatusUp {
+		return http.StatusServiceUnavailable, health
+	}
+	return http.StatusOK, health
+}
+
+func checkDependency(check func() (map[string]interface{}, error)) DependencyHealth {
+	start := time.Now()
+	details, err := check()
+	dependency := DependencyHealth{
+		Status:    StatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		dependency.Status = StatusDown
+		dependency.Error = err.Error()
+	}
+	return dependency
+}
+
+// checkElastic reports the cluster's status and shards. Like the healthcheck, only a green cluster is up.
+func checkElastic(ctx context.Context, client *elasticsearch.Client) (map[string]interface{}, error) {
+	body, elasticErr := elastic.DecodeBody(client.Cluster.Health(client.Cluster.Health.WithContext(ctx)))
+	if elasticErr != nil {
+		return nil, elasticErr
+	}
+
+	details := map[string]interface{}{}
+	for _, field := range clusterHealthFields {
+		if value, ok := body[field]; ok {
+			details[field] = value
+		}
+	}
+	if status := body["status"]; status != statusAllGood {
+		return details, fmt.Errorf("cluster status is %v", status)
+	}
+	return details, nil
+}
+
+// checkOidcKeys reports the state of the signing keys cache. Stale keys still validate tokens, so they're up.
+func checkOidcKeys(keys auth.KeyCacheState) (map[string]interface{}, error) {
+	details := map[string]interface{}{
+		"issuer": keys.Issuer,
+		"keys":   keys.Keys,
+		"stale":  keys.Stale,
+	}
+	if keys.LastRefresh != "" {
+		details["lastRefresh"] = keys.LastRefresh
+		if lastRefresh, err := time.Parse(time.RFC3339, keys.LastRefresh); err == nil {
+			details["ageSecs"] = int64(time.Since(lastRefresh).Seconds())
+		}
+	}
+	if keys.LastError != "" {
+		details["lastError"] = keys.LastError
+	}
+	if keys.Keys == 0 {
+		return details, fmt.Errorf("no OIDC signing keys from %s", keys.Issuer)
+	}
+	return details, nil
+}
+
+// checkSchemaRegistry only checks that the registry answers, any response but a server error is up
+func checkSchemaRegistry(ctx context.Context, url string) (map[string]interface{}, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry URL: %w", err)
+	}
+	resp, err := schemaRegistryClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("schema registry is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	details := map[string]interface{}{"statusCode": resp.StatusCode}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return details, fmt.Errorf("schema registry returned %s", resp.Status)
+	}
+	return details, nil
+}
@@ -0,0 +1,149 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const greenClusterHealth = `{"cluster_name":"hri","status":"green","timed_out":false,"number_of_nodes":3,
+	"number_of_data_nodes":3,"active_primary_shards":9,"active_shards":19,"relocating_shards":0,"initializing_shards":0,
+	"unassigned_shards":0,"active_shards_percent_as_number":100.0}`
+
+var greenClusterDetails = map[string]interface{}{"cluster_name": "hri", "status": "green", "number_of_nodes": 3.0,
+	"number_of_data_nodes": 3.0, "active_primary_shards": 9.0, "active_shards": 19.0, "relocating_shards": 0.0,
+	"initializing_shards": 0.0, "unassigned_shards": 0.0, "active_shards_percent_as_number": 100.0}
+
+func TestGetDetailed(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer registry.Close()
+
+	testCases := []struct {
+		name               string
+		transport          *test.FakeTransport
+		kafkaHealthChecker fakeKafkaHealthChecker
+		keys               *auth.KeyCacheState
+		schemaRegistryUrl  string
+		expectedCode       int
+		expectedHealth     DetailedHealth
+	}{
+		{
+			name: "all up",
+			transport: test.NewFakeTransport(t).AddCall(
+				"/_cluster/health", test.ElasticCall{ResponseBody: greenClusterHealth},
+			),
+			kafkaHealthChecker: fakeKafkaHealthChecker{brokers: 3},
+			keys:               &auth.KeyCacheState{Issuer: "https://issuer", Keys: 2, LastError: "timeout", Stale: true},
+			schemaRegistryUrl:  registry.URL,
+			expectedCode:       http.StatusOK,
+			expectedHealth: DetailedHealth{Status: StatusUp, Dependencies: map[string]DependencyHealth{
+				dependencyElastic: {Status: StatusUp, Details: greenClusterDetails},
+				dependencyKafka:   {Status: StatusUp, Details: map[string]interface{}{"brokers": 3}},
+				dependencyOidcKeys: {Status: StatusUp, Details: map[string]interface{}{
+					"issuer": "https://issuer", "keys": 2, "stale": true, "lastError": "timeout"}},
+				dependencySchemaRegistry: {Status: StatusUp, Details: map[string]interface{}{"statusCode": 200}},
+			}},
+		},
+		{
+			name: "auth disabled and no schema registry",
+			transport: test.NewFakeTransport(t).AddCall(
+				"/_cluster/health", test.ElasticCall{ResponseBody: greenClusterHealth},
+			),
+			kafkaHealthChecker: fakeKafkaHealthChecker{brokers: 1},
+			expectedCode:       http.StatusOK,
+			expectedHealth: DetailedHealth{Status: StatusUp, Dependencies: map[string]DependencyHealth{
+				dependencyElastic: {Status: StatusUp, Details: greenClusterDetails},
+				dependencyKafka:   {Status: StatusUp, Details: map[string]interface{}{"brokers": 1}},
+			}},
+		},
+		{
+			name: "all down",
+			transport: test.NewFakeTransport(t).AddCall(
+				"/_cluster/health", test.ElasticCall{ResponseBody: `{"cluster_name":"hri","status":"red","unassigned_shards":4}`},
+			),
+			kafkaHealthChecker: fakeKafkaHealthChecker{err: errors.New("error getting Kafka topics: timeout")},
+			keys:               &auth.KeyCacheState{Issuer: "https://issuer", LastError: "timeout"},
+			schemaRegistryUrl:  registry.URL + "/unavailable",
+			expectedCode:       http.StatusServiceUnavailable,
+			expectedHealth: DetailedHealth{Status: StatusDown, Dependencies: map[string]DependencyHealth{
+				dependencyElastic: {Status: StatusDown, Error: "cluster status is red",
+					Details: map[string]interface{}{"cluster_name": "hri", "status": "red", "unassigned_shards": 4.0}},
+				dependencyKafka: {Status: StatusDown, Error: "error getting Kafka topics: timeout",
+					Details: map[string]interface{}{"brokers": 0}},
+				dependencyOidcKeys: {Status: StatusDown, Error: "no OIDC signing keys from https://issuer",
+					Details: map[string]interface{}{"issuer": "https://issuer", "keys": 0, "stale": false, "lastError": "timeout"}},
+				dependencySchemaRegistry: {Status: StatusDown, Error: "schema registry returned 503 Service Unavailable",
+					Details: map[string]interface{}{"statusCode": 503}},
+			}},
+		},
+		{
+			name: "elastic error",
+			transport: test.NewFakeTransport(t).AddCall(
+				"/_cluster/health", test.ElasticCall{ResponseErr: errors.New("connection refused")},
+			),
+			kafkaHealthChecker: fakeKafkaHealthChecker{brokers: 1},
+			expectedCode:       http.StatusServiceUnavailable,
+			expectedHealth: DetailedHealth{Status: StatusDown, Dependencies: map[string]DependencyHealth{
+				dependencyElastic: {Status: StatusDown, Error: "elasticsearch client error: connection refused"},
+				dependencyKafka:   {Status: StatusUp, Details: map[string]interface{}{"brokers": 1}},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			actualCode, actualHealth := GetDetailed(context.Background(), requestId, client, tc.kafkaHealthChecker, tc.keys, tc.schemaRegistryUrl)
+			assert.Equal(t, tc.expectedCode, actualCode)
+
+			// the latency isn't predictable
+			for name, dependency := range actualHealth.Dependencies {
+				assert.GreaterOrEqual(t, dependency.LatencyMs, int64(0))
+				dependency.LatencyMs = 0
+				actualHealth.Dependencies[name] = dependency
+			}
+			assert.Equal(t, tc.expectedHealth, actualHealth)
+		})
+	}
+}
+
+func TestCheckOidcKeysAge(t *testing.T) {
+	details, err := checkOidcKeys(auth.KeyCacheState{Issuer: "https://issuer", Keys: 1, LastRefresh: "2021-02-24T00:00:00Z"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2021-02-24T00:00:00Z", details["lastRefresh"])
+	assert.Greater(t, details["ageSecs"], int64(0))
+}
+
+func TestCheckSchemaRegistryUnreachable(t *testing.T) {
+	registry := httptest.NewServer(http.NotFoundHandler())
+	registry.Close()
+
+	details, err := checkSchemaRegistry(context.Background(), registry.URL)
+	assert.Nil(t, details)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "schema registry is unreachable: ")
+	}
+}
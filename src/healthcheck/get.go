@@ -6,7 +6,9 @@
 package healthcheck
 
 import (
+	"context"
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
@@ -14,6 +16,7 @@ import (
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/elastic/go-elasticsearch/v7"
 	"net/http"
+	"strings"
 )
 
 const statusAllGood string = "green"
@@ -21,13 +24,17 @@ const serviceUnavailableMsg string = "HRI Service Temporarily Unavailable | erro
 const notReported string = "NotReported"
 const noStatusReported = "NONE/" + notReported
 
-func Get(requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker) (int, *response.ErrorDetail) {
+// Get checks Elasticsearch and Kafka, until ctx is done. When authorization is enabled, keys is the state of the cached OIDC signing keys,
+// and the check fails if there are none, because no token can be validated. Stale keys are still usable, so they don't
+// fail the check.
+func Get(ctx context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker,
+	keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
 	prefix := "healthcheck/get"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Infof("Prepare HealthCheck - ElasticSearch (No Input Params)")
 
 	//1. Do ElasticSearch healthCheck call
-	resp, err := client.Cat.Health(client.Cat.Health.WithFormat("json"))
+	resp, err := client.Cat.Health(client.Cat.Health.WithContext(ctx), client.Cat.Health.WithFormat("json"))
 	respBody, elasticErr := elastic.DecodeFirstArrayElement(resp, err)
 	if elasticErr != nil {
 		return http.StatusServiceUnavailable, elasticErr.LogAndBuildErrorDetail(
@@ -52,7 +59,7 @@ func Get(requestId string, client *elasticsearch.Client, healthChecker kafka.Hea
 	}
 
 	//2. Do Kafka healthCheck
-	err = healthChecker.Check()
+	err = healthChecker.Check(ctx)
 	logger.Infof("Kafka HealthCheck error: %v", err)
 	var kaErrMsg = ""
 	if err != nil {
@@ -61,17 +68,24 @@ func Get(requestId string, client *elasticsearch.Client, healthChecker kafka.Hea
 		logger.Errorln(kaErrMsg)
 	}
 
-	var errMessage string
+	//3. Check the OIDC signing keys were fetched
+	var keysErrMsg = ""
+	if keys != nil && keys.Keys == 0 {
+		isErr = true
+		keysErrMsg = fmt.Sprintf("No OIDC signing keys from %s: %s", keys.Issuer, keys.LastError)
+		logger.Errorln(keysErrMsg)
+	}
+
 	if isErr {
-		if len(esErrMsg) > 0 && len(kaErrMsg) > 0 {
-			errMessage = fmt.Sprintf(serviceUnavailableMsg, esErrMsg+" | "+kaErrMsg)
-		} else if len(kaErrMsg) > 0 {
-			errMessage = fmt.Sprintf(serviceUnavailableMsg, kaErrMsg)
-		} else {
-			errMessage = fmt.Sprintf(serviceUnavailableMsg, esErrMsg)
+		errMessages := make([]string, 0, 3)
+		for _, msg := range []string{esErrMsg, kaErrMsg, keysErrMsg} {
+			if len(msg) > 0 {
+				errMessages = append(errMessages, msg)
+			}
 		}
+		errMessage := fmt.Sprintf(serviceUnavailableMsg, strings.Join(errMessages, " | "))
 		return http.StatusServiceUnavailable, response.NewErrorDetail(requestId, errMessage)
-	} else { //All Good for BOTH ElasticSearch AND Kafka Healthcheck
+	} else { //All Good for ElasticSearch, Kafka and the signing keys
 		return http.StatusOK, nil
 	}
 }
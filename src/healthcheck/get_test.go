@@ -7,7 +7,9 @@ package healthcheck
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
@@ -22,6 +24,8 @@ import (
 
 const requestId string = "testRequestId"
 
+const greenHealthResponse = `[{"epoch":"1578512886","cluster":"8165307e-6130-4581-942d-20fcfc4e795d","status":"green"}]`
+
 func TestHealthcheck(t *testing.T) {
 	logwrapper.Initialize("error", os.Stdout)
 
@@ -29,6 +33,7 @@ func TestHealthcheck(t *testing.T) {
 		name               string
 		transport          *test.FakeTransport
 		kafkaHealthChecker kafka.HealthChecker
+		keys               *auth.KeyCacheState
 		expectedCode       int
 		expectedBody       *response.ErrorDetail
 	}{
@@ -60,6 +65,27 @@ func TestHealthcheck(t *testing.T) {
 			expectedCode:       http.StatusOK,
 			expectedBody:       nil,
 		},
+		{
+			name: "stale-oidc-keys",
+			transport: test.NewFakeTransport(t).AddCall(
+				"/_cat/health", test.ElasticCall{ResponseBody: greenHealthResponse},
+			),
+			kafkaHealthChecker: fakeKafkaHealthChecker{},
+			keys:               &auth.KeyCacheState{Issuer: "https://issuer", Keys: 1, LastError: "timeout", Stale: true},
+			expectedCode:       http.StatusOK,
+			expectedBody:       nil,
+		},
+		{
+			name: "no-oidc-keys",
+			transport: test.NewFakeTransport(t).AddCall(
+				"/_cat/health", test.ElasticCall{ResponseBody: greenHealthResponse},
+			),
+			kafkaHealthChecker: fakeKafkaHealthChecker{},
+			keys:               &auth.KeyCacheState{Issuer: "https://issuer", LastError: "timeout"},
+			expectedCode:       http.StatusServiceUnavailable,
+			expectedBody: response.NewErrorDetail(requestId,
+				"HRI Service Temporarily Unavailable | error Detail: No OIDC signing keys from https://issuer: timeout"),
+		},
 		{
 			name: "elastic-search-bad-status",
 			transport: test.NewFakeTransport(t).AddCall(
@@ -212,7 +238,7 @@ func TestHealthcheck(t *testing.T) {
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := Get(requestId, client, tc.kafkaHealthChecker)
+			actualCode, actualBody := Get(context.Background(), requestId, client, tc.kafkaHealthChecker, tc.keys)
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
 				//notify/print error event as test result
 				t.Errorf("HealthCheck-Get()\n   actual: %v,%v\n expected: %v,%v", actualCode, actualBody, tc.expectedCode, tc.expectedBody)
@@ -222,13 +248,18 @@ func TestHealthcheck(t *testing.T) {
 }
 
 type fakeKafkaHealthChecker struct {
-	err error
+	brokers int
+	err     error
 }
 
-func (fhc fakeKafkaHealthChecker) Check() error {
+func (fhc fakeKafkaHealthChecker) Check(context.Context) error {
 	return fhc.err
 }
 
+func (fhc fakeKafkaHealthChecker) BrokerCount(context.Context) (int, error) {
+	return fhc.brokers, fhc.err
+}
+
 func (fhc fakeKafkaHealthChecker) Close() {
 	return
 }
@@ -7,6 +7,8 @@
 package healthcheck
 
 import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
@@ -15,23 +17,44 @@ import (
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const verboseParam = "verbose"
+
+// the longest the checks of Elastic, Kafka and the schema registry can take together
+const checkTimeout = 5 * time.Second
+
+const (
+	cacheKeyHealthcheck = "healthcheck"
+	cacheKeyDetailed    = "detailed"
 )
 
 type Handler interface {
 	Healthcheck(echo.Context) error
+	Ready(echo.Context) error
 }
 
 // This struct is designed to make unit testing easier. It has function references for the calls to backend
 // logic and other methods that reach out to external services like creating the Kafka partition reader.
 type theHandler struct {
-	config      configPkg.Config
-	healthcheck func(string, *elasticsearch.Client, kafka.HealthChecker) (int, *response.ErrorDetail)
+	config              configPkg.Config
+	healthcheck         func(context.Context, string, *elasticsearch.Client, kafka.HealthChecker, *auth.KeyCacheState) (int, *response.ErrorDetail)
+	detailedHealthcheck func(context.Context, string, *elasticsearch.Client, kafka.HealthChecker, *auth.KeyCacheState, string) (int, DetailedHealth)
+	keyCacheState       func() *auth.KeyCacheState
+	clients             clients
+	cache               *resultCache
 }
 
 func NewHandler(config configPkg.Config) Handler {
 	return &theHandler{
-		config:      config,
-		healthcheck: Get,
+		config:              config,
+		healthcheck:         Get,
+		detailedHealthcheck: GetDetailed,
+		keyCacheState:       auth.GetKeyCacheState,
+		cache:               newResultCache(time.Duration(config.HealthcheckCacheSecs) * time.Second),
 	}
 }
 
@@ -42,22 +65,177 @@ func (h *theHandler) Healthcheck(c echo.Context) error {
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Debug("Start Healthcheck Handler")
 
-	esClient, err := elastic.ClientFromConfig(h.config)
-	if err != nil {
-		logger.Errorln(err.Error())
-		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	verbose := false
+	if value := c.QueryParam(verboseParam); value != "" {
+		var err error
+		if verbose, err = strconv.ParseBool(value); err != nil {
+			msg := "invalid value for the verbose parameter: " + value
+			logger.Errorln(msg)
+			return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
+		}
 	}
 
-	healthChecker, err := kafka.NewHealthChecker(h.config)
-	if err != nil {
-		logger.Errorln(err.Error())
-		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	if verbose {
+		code, body, errorDetail := h.cache.get(cacheKeyDetailed, func() (int, interface{}, *response.ErrorDetail) {
+			esClient, healthChecker, errorDetail := h.clients.get(requestId, h.config)
+			if errorDetail != nil {
+				return http.StatusInternalServerError, nil, errorDetail
+			}
+			ctx, cancel := checkContext()
+			defer cancel()
+			code, health := h.detailedHealthcheck(ctx, requestId, esClient, healthChecker, h.getKeyCacheState(),
+				h.config.SchemaRegistryUrl)
+			return code, health, nil
+		})
+		if errorDetail != nil {
+			return c.JSON(code, response.NewErrorDetail(requestId, errorDetail.ErrorDescription))
+		}
+		return c.JSON(code, body)
 	}
-	defer healthChecker.Close()
 
-	code, errorDetail := h.healthcheck(requestId, esClient, healthChecker)
+	code, body, errorDetail := h.check(requestId)
 	if errorDetail != nil {
-		return c.JSON(code, errorDetail)
+		return c.JSON(code, response.NewErrorDetail(requestId, errorDetail.ErrorDescription))
+	}
+	if body != nil {
+		return c.JSON(code, body)
 	}
 	return c.NoContent(code)
 }
+
+// Ready is the readiness probe, the HRI is ready when the healthcheck passes. Its result is shared with the healthcheck.
+func (h *theHandler) Ready(c echo.Context) error {
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	prefix := "healthcheck/handler/ready"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	logger.Debug("Start Ready Handler")
+
+	code, _, errorDetail := h.check(requestId)
+	if errorDetail != nil {
+		return c.JSON(code, response.NewErrorDetail(requestId, errorDetail.ErrorDescription))
+	}
+	return c.String(http.StatusOK, "ready")
+}
+
+// check runs the healthcheck, or returns its cached result
+func (h *theHandler) check(requestId string) (int, interface{}, *response.ErrorDetail) {
+	return h.cache.get(cacheKeyHealthcheck, func() (int, interface{}, *response.ErrorDetail) {
+		esClient, healthChecker, errorDetail := h.clients.get(requestId, h.config)
+		if errorDetail != nil {
+			return http.StatusInternalServerError, nil, errorDetail
+		}
+
+		ctx, cancel := checkContext()
+		defer cancel()
+		keys := h.getKeyCacheState()
+		code, errorDetail := h.healthcheck(ctx, requestId, esClient, healthChecker, keys)
+		if errorDetail != nil {
+			return code, nil, errorDetail
+		}
+		if keys != nil {
+			return code, map[string]interface{}{"oidcKeys": keys}, nil
+		}
+		return code, nil, nil
+	})
+}
+
+// checkContext bounds how long a check takes. It isn't derived from the request's context, because the result is
+// cached and shared with the other probes that wait for it.
+func checkContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), checkTimeout)
+}
+
+func (h *theHandler) getKeyCacheState() *auth.KeyCacheState {
+	if h.config.AuthDisabled {
+		return nil
+	}
+	return h.keyCacheState()
+}
+
+// clients are created by the first healthcheck and shared by the later ones. They're created again when it fails.
+type clients struct {
+	mu            sync.Mutex
+	esClient      *elasticsearch.Client
+	healthChecker kafka.HealthChecker
+}
+
+func (cl *clients) get(requestId string, config configPkg.Config) (*elasticsearch.Client, kafka.HealthChecker, *response.ErrorDetail) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	var logger = logwrapper.GetMyLogger(requestId, "healthcheck/handler/clients")
+
+	if cl.esClient == nil {
+		esClient, err := elastic.ClientFromConfig(config)
+		if err != nil {
+			logger.Errorln(err.Error())
+			return nil, nil, response.NewErrorDetail(requestId, err.Error())
+		}
+		cl.esClient = esClient
+	}
+	if cl.healthChecker == nil {
+		healthChecker, err := kafka.NewHealthChecker(config)
+		if err != nil {
+			logger.Errorln(err.Error())
+			return nil, nil, response.NewErrorDetail(requestId, err.Error())
+		}
+		cl.healthChecker = healthChecker
+	}
+	return cl.esClient, cl.healthChecker, nil
+}
+
+// resultCache keeps the results of the checks for a short time, so that frequent probes don't overload Elastic and
+// Kafka. Each check is run while holding the lock of its key, so concurrent probes wait for the same result, but a
+// slow healthcheck doesn't hold up the detailed one, or the other way around.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	mu     sync.Mutex
+	result *cachedResult
+}
+
+type cachedResult struct {
+	code        int
+	body        interface{}
+	errorDetail *response.ErrorDetail
+	expires     time.Time
+}
+
+func newResultCache(ttl time.Duration) *resultCache {
+	return &resultCache{ttl: ttl, now: time.Now, entries: map[string]*cacheEntry{}}
+}
+
+// get returns the cached result, or runs the check when it expired. Errors creating the clients aren't cached.
+func (rc *resultCache) get(key string, check func() (int, interface{}, *response.ErrorDetail)) (int, interface{}, *response.ErrorDetail) {
+	if rc == nil || rc.ttl <= 0 {
+		return check()
+	}
+
+	entry := rc.entry(key)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if result := entry.result; result != nil && rc.now().Before(result.expires) {
+		return result.code, result.body, result.errorDetail
+	}
+
+	code, body, errorDetail := check()
+	if code != http.StatusInternalServerError {
+		entry.result = &cachedResult{code: code, body: body, errorDetail: errorDetail, expires: rc.now().Add(rc.ttl)}
+	}
+	return code, body, errorDetail
+}
+
+func (rc *resultCache) entry(key string) *cacheEntry {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		rc.entries[key] = entry
+	}
+	return entry
+}
@@ -7,6 +7,8 @@
 package healthcheck
 
 import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
@@ -19,19 +21,22 @@ import (
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewHandler(t *testing.T) {
 	config := config.Config{
-		ConfigPath:      "",
-		OidcIssuer:      "",
-		JwtAudienceId:   "",
-		Validation:      false,
-		ElasticUrl:      "",
-		ElasticUsername: "",
-		ElasticPassword: "",
-		ElasticCert:     "",
+		ConfigPath:           "",
+		HealthcheckCacheSecs: 5,
+		OidcIssuer:           "",
+		JwtAudienceId:        "",
+		Validation:           false,
+		ElasticUrl:           "",
+		ElasticUsername:      "",
+		ElasticPassword:      "",
+		ElasticCert:          "",
 	}
 
 	handler := NewHandler(config).(*theHandler)
@@ -40,6 +45,9 @@ func TestNewHandler(t *testing.T) {
 	// Can't check partitionReaderFromConfig, because it's an anonymous function
 	// This asserts that they are the same function by memory address
 	assert.Equal(t, reflect.ValueOf(Get), reflect.ValueOf(handler.healthcheck))
+	assert.Equal(t, reflect.ValueOf(GetDetailed), reflect.ValueOf(handler.detailedHealthcheck))
+	assert.Equal(t, reflect.ValueOf(auth.GetKeyCacheState), reflect.ValueOf(handler.keyCacheState))
+	assert.Equal(t, 5*time.Second, handler.cache.ttl)
 }
 
 func TestHealthcheckHandler(t *testing.T) {
@@ -52,8 +60,8 @@ func TestHealthcheckHandler(t *testing.T) {
 		{
 			name: "Good healthcheck",
 			handler: &theHandler{
-				config: config.Config{},
-				healthcheck: func(requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker) (int, *response.ErrorDetail) {
+				config: config.Config{AuthDisabled: true},
+				healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
 					return http.StatusOK, nil
 				},
 			},
@@ -61,10 +69,27 @@ func TestHealthcheckHandler(t *testing.T) {
 			expectedBody: "",
 		},
 		{
-			name: "Bad healthcheck",
+			name: "Good healthcheck with OIDC keys",
 			handler: &theHandler{
 				config: config.Config{},
-				healthcheck: func(requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker) (int, *response.ErrorDetail) {
+				healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
+					assert.Equal(t, 2, keys.Keys)
+					return http.StatusOK, nil
+				},
+				keyCacheState: func() *auth.KeyCacheState {
+					return &auth.KeyCacheState{Issuer: "https://issuer", Keys: 2, LastRefresh: "2021-02-24T00:00:00Z",
+						LastAttempt: "2021-02-24T01:00:00Z", LastError: "issuer unavailable", Stale: true}
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"oidcKeys":{"issuer":"https://issuer","keys":2,"lastRefresh":"2021-02-24T00:00:00Z",` +
+				`"lastAttempt":"2021-02-24T01:00:00Z","lastError":"issuer unavailable","stale":true}}` + "\n",
+		},
+		{
+			name: "Bad healthcheck",
+			handler: &theHandler{
+				config: config.Config{AuthDisabled: true},
+				healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
 					return http.StatusServiceUnavailable, response.NewErrorDetail(requestId, "Elastic not available")
 				},
 			},
@@ -106,3 +131,213 @@ func TestHealthcheckHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestVerboseHealthcheckHandler(t *testing.T) {
+	upHealth := DetailedHealth{Status: StatusUp, Dependencies: map[string]DependencyHealth{
+		dependencyKafka: {Status: StatusUp, LatencyMs: 3, Details: map[string]interface{}{"brokers": 3}},
+	}}
+
+	tests := []struct {
+		name         string
+		query        string
+		handler      *theHandler
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:  "verbose healthcheck",
+			query: "?verbose=true",
+			handler: &theHandler{
+				config: config.Config{AuthDisabled: true, SchemaRegistryUrl: "https://registry"},
+				detailedHealthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState, schemaRegistryUrl string) (int, DetailedHealth) {
+					assert.Nil(t, keys)
+					assert.Equal(t, "https://registry", schemaRegistryUrl)
+					return http.StatusOK, upHealth
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status":"up","dependencies":{"kafka":{"status":"up","latencyMs":3,"details":{"brokers":3}}}}` + "\n",
+		},
+		{
+			name:  "verbose healthcheck down",
+			query: "?verbose=1",
+			handler: &theHandler{
+				config: config.Config{},
+				detailedHealthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState, schemaRegistryUrl string) (int, DetailedHealth) {
+					assert.Equal(t, 0, keys.Keys)
+					return http.StatusServiceUnavailable, DetailedHealth{Status: StatusDown, Dependencies: map[string]DependencyHealth{
+						dependencyOidcKeys: {Status: StatusDown, Error: "no OIDC signing keys from https://issuer"},
+					}}
+				},
+				keyCacheState: func() *auth.KeyCacheState {
+					return &auth.KeyCacheState{Issuer: "https://issuer"}
+				},
+			},
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: `{"status":"down","dependencies":{"oidcKeys":{"status":"down","latencyMs":0,"error":"no OIDC signing keys from https://issuer"}}}` + "\n",
+		},
+		{
+			name:  "not verbose",
+			query: "?verbose=false",
+			handler: &theHandler{
+				config: config.Config{AuthDisabled: true},
+				healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
+					return http.StatusOK, nil
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "",
+		},
+		{
+			name:         "invalid verbose",
+			query:        "?verbose=yes please",
+			handler:      &theHandler{config: config.Config{AuthDisabled: true}},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"invalid value for the verbose parameter: yes please\"}\n",
+		},
+		{
+			name:  "Elastic client error",
+			query: "?verbose=true",
+			handler: &theHandler{
+				config: config.Config{ElasticUrl: "https://an.invalid url.com/", ElasticCert: "Invalid Cert"},
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"cannot create client: cannot parse url: parse \\\"https://an.invalid url.com\\\": invalid character \\\" \\\" in host name\"}\n",
+		},
+	}
+
+	logwrapper.Initialize("error", os.Stdout)
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/healthcheck"+strings.ReplaceAll(tt.query, " ", "%20"), nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+
+			if assert.NoError(t, tt.handler.Healthcheck(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		handler      *theHandler
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "ready",
+			handler: &theHandler{
+				config: config.Config{AuthDisabled: true},
+				healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
+					return http.StatusOK, nil
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "ready",
+		},
+		{
+			name: "not ready",
+			handler: &theHandler{
+				config: config.Config{AuthDisabled: true},
+				healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
+					return http.StatusServiceUnavailable, response.NewErrorDetail(requestId, "Elastic not available")
+				},
+			},
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Elastic not available\"}\n",
+		},
+	}
+
+	logwrapper.Initialize("error", os.Stdout)
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/ready", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+
+			if assert.NoError(t, tt.handler.Ready(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestHealthcheckCache(t *testing.T) {
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+	checks := 0
+	handler := &theHandler{
+		config: config.Config{AuthDisabled: true},
+		healthcheck: func(_ context.Context, requestId string, client *elasticsearch.Client, healthChecker kafka.HealthChecker, keys *auth.KeyCacheState) (int, *response.ErrorDetail) {
+			checks++
+			return http.StatusServiceUnavailable, response.NewErrorDetail(requestId, "Elastic not available")
+		},
+		cache: newResultCache(5 * time.Second),
+	}
+	handler.cache.now = func() time.Time { return now }
+
+	logwrapper.Initialize("error", os.Stdout)
+	e := test.GetTestServer()
+	call := func(requestId string, handle func(echo.Context) error) string {
+		context, recorder := test.PrepareHeadersContextRecorder(httptest.NewRequest(http.MethodGet, "/ready", nil), e)
+		context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+		assert.NoError(t, handle(context))
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		return recorder.Body.String()
+	}
+
+	call("request1", handler.Ready)
+	// the cached result has the id of the request it's returned to
+	assert.Equal(t, "{\"errorEventId\":\"request2\",\"errorDescription\":\"Elastic not available\"}\n",
+		call("request2", handler.Healthcheck))
+	assert.Equal(t, 1, checks)
+
+	now = now.Add(5 * time.Second)
+	call("request3", handler.Ready)
+	assert.Equal(t, 2, checks)
+
+	// client errors aren't cached
+	handler.config = config.Config{ElasticUrl: "https://an.invalid url.com/"}
+	handler.clients = clients{}
+	handler.cache.entries = map[string]*cacheEntry{}
+	for _, requestId := range []string{"request4", "request5"} {
+		context, recorder := test.PrepareHeadersContextRecorder(httptest.NewRequest(http.MethodGet, "/ready", nil), e)
+		context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+		assert.NoError(t, handler.Ready(context))
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	}
+	assert.Nil(t, handler.cache.entries[cacheKeyHealthcheck].result)
+}
+
+func TestResultCacheLocksPerKey(t *testing.T) {
+	cache := newResultCache(5 * time.Second)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go cache.get(cacheKeyDetailed, func() (int, interface{}, *response.ErrorDetail) {
+		close(started)
+		<-release
+		return http.StatusOK, nil, nil
+	})
+	<-started
+	defer close(release)
+
+	done := make(chan int)
+	go func() {
+		code, _, _ := cache.get(cacheKeyHealthcheck, func() (int, interface{}, *response.ErrorDetail) {
+			return http.StatusNoContent, nil, nil
+		})
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		assert.Equal(t, http.StatusNoContent, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the healthcheck waited for the detailed healthcheck")
+	}
+}
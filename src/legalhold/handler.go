@@ -0,0 +1,148 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package legalhold
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const msgElasticErr string = "error getting Elastic client: %s"
+
+type Handler interface {
+	HoldBatch(echo.Context) error
+	ReleaseBatch(echo.Context) error
+	HoldTenant(echo.Context) error
+	ReleaseTenant(echo.Context) error
+}
+
+type theHandler struct {
+	config        config.Config
+	jwtValidator  auth.Validator
+	holdBatch     func(context.Context, string, model.BatchLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{})
+	releaseBatch  func(context.Context, string, model.BatchLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{})
+	holdTenant    func(context.Context, string, model.TenantLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{})
+	releaseTenant func(context.Context, string, model.TenantLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{})
+}
+
+// NewHandler This struct is designed to make unit testing easier. It has function references for the calls to backend
+// logic and other classes that reach out to external services like JWT token validation.
+func NewHandler(config config.Config) Handler {
+	if config.AuthDisabled {
+		return &theHandler{
+			config:        config,
+			holdBatch:     HoldBatchNoAuth,
+			releaseBatch:  ReleaseBatchNoAuth,
+			holdTenant:    HoldTenantNoAuth,
+			releaseTenant: ReleaseTenantNoAuth,
+		}
+	}
+
+	return &theHandler{
+		config:        config,
+		jwtValidator:  auth.NewValidator(config),
+		holdBatch:     HoldBatch,
+		releaseBatch:  ReleaseBatch,
+		holdTenant:    HoldTenant,
+		releaseTenant: ReleaseTenant,
+	}
+}
+
+func (h *theHandler) HoldBatch(c echo.Context) error {
+	return h.changeBatchHold(c, "legalhold/handler/holdBatch", h.holdBatch)
+}
+
+func (h *theHandler) ReleaseBatch(c echo.Context) error {
+	return h.changeBatchHold(c, "legalhold/handler/releaseBatch", h.releaseBatch)
+}
+
+func (h *theHandler) HoldTenant(c echo.Context) error {
+	return h.changeTenantHold(c, "legalhold/handler/holdTenant", h.holdTenant)
+}
+
+func (h *theHandler) ReleaseTenant(c echo.Context) error {
+	return h.changeTenantHold(c, "legalhold/handler/releaseTenant", h.releaseTenant)
+}
+
+func (h *theHandler) changeBatchHold(c echo.Context, prefix string,
+	fn func(context.Context, string, model.BatchLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{})) error {
+
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	// bind & validate request body
+	var request model.BatchLegalHold
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		msg := fmt.Sprintf(msgElasticErr, err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
+	}
+
+	claims, errResp := h.getClaims(c, requestId, request.TenantId)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
+	}
+	return c.JSON(fn(c.Request().Context(), requestId, request, claims, esClient))
+}
+
+func (h *theHandler) changeTenantHold(c echo.Context, prefix string,
+	fn func(context.Context, string, model.TenantLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{})) error {
+
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	// bind & validate request body
+	var request model.TenantLegalHold
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		msg := fmt.Sprintf(msgElasticErr, err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
+	}
+
+	claims, errResp := h.getClaims(c, requestId, request.TenantId)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
+	}
+	return c.JSON(fn(c.Request().Context(), requestId, request, claims, esClient))
+}
+
+// getClaims returns empty claims when auth is disabled, the NoAuth functions don't use them
+func (h *theHandler) getClaims(c echo.Context, requestId string, tenantId string) (auth.HriClaims, *response.ErrorDetailResponse) {
+	if h.config.AuthDisabled {
+		return auth.HriClaims{}, nil
+	}
+	return h.jwtValidator.GetValidatedClaims(requestId, c.Request(), tenantId)
+}
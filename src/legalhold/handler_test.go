@@ -0,0 +1,212 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package legalhold
+
+import (
+	"context"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	tenantId = "tenant123"
+	batchId  = "batch456"
+)
+
+func TestNewHandler(t *testing.T) {
+	testConfig := config.Config{ElasticUrl: "https://fake-elastic.com"}
+
+	handler := NewHandler(testConfig).(*theHandler)
+	assert.Equal(t, testConfig, handler.config)
+	assert.NotNil(t, handler.jwtValidator)
+	// This asserts that they are the same function by memory address
+	assert.Equal(t, reflect.ValueOf(HoldBatch), reflect.ValueOf(handler.holdBatch))
+	assert.Equal(t, reflect.ValueOf(ReleaseBatch), reflect.ValueOf(handler.releaseBatch))
+	assert.Equal(t, reflect.ValueOf(HoldTenant), reflect.ValueOf(handler.holdTenant))
+	assert.Equal(t, reflect.ValueOf(ReleaseTenant), reflect.ValueOf(handler.releaseTenant))
+
+	testConfig.AuthDisabled = true
+	handler = NewHandler(testConfig).(*theHandler)
+	assert.Nil(t, handler.jwtValidator)
+	assert.Equal(t, reflect.ValueOf(HoldBatchNoAuth), reflect.ValueOf(handler.holdBatch))
+	assert.Equal(t, reflect.ValueOf(ReleaseBatchNoAuth), reflect.ValueOf(handler.releaseBatch))
+	assert.Equal(t, reflect.ValueOf(HoldTenantNoAuth), reflect.ValueOf(handler.holdTenant))
+	assert.Equal(t, reflect.ValueOf(ReleaseTenantNoAuth), reflect.ValueOf(handler.releaseTenant))
+}
+
+// Fake for the auth.Validator interface; just returns the desired values
+type fakeAuthValidator struct {
+	claims  auth.HriClaims
+	errResp *response.ErrorDetailResponse
+}
+
+func (f fakeAuthValidator) GetValidatedClaims(_ string, _ *http.Request, _ string) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func (f fakeAuthValidator) GetValidatedAdminClaims(_ string, _ *http.Request) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func Test_theHandler_HoldBatch(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	adminClaims := auth.HriClaims{Scope: auth.HriAdmin + " " + auth.TenantScopePrefix + tenantId, Subject: "admin"}
+
+	testCases := []struct {
+		name         string
+		config       config.Config
+		validator    auth.Validator
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "success",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    fakeAuthValidator{claims: adminClaims},
+			requestBody:  `{"reason":"litigation"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"batch456","reason":"litigation","subject":"admin","tenantId":"tenant123"}` + "\n",
+		},
+		{
+			name:         "auth disabled",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com", AuthDisabled: true},
+			requestBody:  `{"reason":"litigation"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"batch456","reason":"litigation","subject":"","tenantId":"tenant123"}` + "\n",
+		},
+		{
+			name:         "missing reason",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    fakeAuthValidator{claims: adminClaims},
+			requestBody:  `{}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"","errorDescription":"invalid request arguments:\n- reason (json field in request body) is a required field"}` + "\n",
+		},
+		{
+			name:         "invalid reason",
+			config:       config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator:    fakeAuthValidator{claims: adminClaims},
+			requestBody:  `{"reason":"<script>"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"","errorDescription":"invalid request arguments:\n- reason (json field in request body) must not contain the following characters: \"=\u003c\u003e[]{}"}` + "\n",
+		},
+		{
+			name:         "bad elastic url",
+			config:       config.Config{ElasticUrl: "https:// a bad url.com"},
+			validator:    fakeAuthValidator{claims: adminClaims},
+			requestBody:  `{"reason":"litigation"}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"errorEventId":"","errorDescription":"error getting Elastic client: cannot create client: cannot parse url: parse \"https:// a bad url.com\": invalid character \" \" in host name"}` + "\n",
+		},
+		{
+			name:   "unauthorized tenant",
+			config: config.Config{ElasticUrl: "https://fake-elastic.com"},
+			validator: fakeAuthValidator{
+				errResp: response.NewErrorDetailResponse(http.StatusForbidden, "", "Unauthorized tenant access"),
+			},
+			requestBody:  `{"reason":"litigation"}`,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"errorEventId":"","errorDescription":"Unauthorized tenant access"}` + "\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := theHandler{
+				config:       tc.config,
+				jwtValidator: tc.validator,
+				holdBatch: func(_ context.Context, _ string, request model.BatchLegalHold, claims auth.HriClaims, _ *elasticsearch.Client) (int, interface{}) {
+					return http.StatusOK, map[string]interface{}{
+						param.TenantId: request.TenantId,
+						param.BatchId:  request.BatchId,
+						"reason":       request.Reason,
+						"subject":      claims.Subject,
+					}
+				},
+			}
+
+			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tc.requestBody))
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			context.SetPath("/hri/tenants/:" + param.TenantId + "/batches/:" + param.BatchId + "/action/legalHold")
+			context.SetParamNames(param.TenantId, param.BatchId)
+			context.SetParamValues(tenantId, batchId)
+
+			if assert.NoError(t, handler.HoldBatch(context)) {
+				assert.Equal(t, tc.expectedCode, recorder.Code)
+				assert.Equal(t, tc.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_theHandler_TenantActions(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	testConfig := config.Config{ElasticUrl: "https://fake-elastic.com"}
+	validator := fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin, Subject: "admin"}}
+	tenantFn := func(action string) func(context.Context, string, model.TenantLegalHold, auth.HriClaims, *elasticsearch.Client) (int, interface{}) {
+		return func(_ context.Context, _ string, request model.TenantLegalHold, _ auth.HriClaims, _ *elasticsearch.Client) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{param.TenantId: request.TenantId, "action": action}
+		}
+	}
+	handler := theHandler{
+		config:        testConfig,
+		jwtValidator:  validator,
+		holdTenant:    tenantFn(actionSet),
+		releaseTenant: tenantFn(actionRelease),
+		releaseBatch: func(_ context.Context, _ string, request model.BatchLegalHold, _ auth.HriClaims, _ *elasticsearch.Client) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{param.BatchId: request.BatchId, "action": actionRelease}
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tc := range []struct {
+		name         string
+		action       func(*theHandler) func(echo.Context) error
+		batch        bool
+		expectedBody string
+	}{
+		{"hold tenant", func(h *theHandler) func(echo.Context) error { return h.HoldTenant }, false,
+			`{"action":"set","tenantId":"tenant123"}`},
+		{"release tenant", func(h *theHandler) func(echo.Context) error { return h.ReleaseTenant }, false,
+			`{"action":"release","tenantId":"tenant123"}`},
+		{"release batch", func(h *theHandler) func(echo.Context) error { return h.ReleaseBatch }, true,
+			`{"action":"release","id":"batch456"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"reason":"litigation"}`))
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			if tc.batch {
+				context.SetParamNames(param.TenantId, param.BatchId)
+				context.SetParamValues(tenantId, batchId)
+			} else {
+				context.SetParamNames(param.TenantId)
+				context.SetParamValues(tenantId)
+			}
+
+			if assert.NoError(t, tc.action(&handler)(context)) {
+				assert.Equal(t, http.StatusOK, recorder.Code)
+				assert.Equal(t, tc.expectedBody+"\n", recorder.Body.String())
+			}
+		})
+	}
+}
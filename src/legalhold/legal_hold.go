@@ -0,0 +1,340 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package legalhold
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+const (
+	msgBatchNotFound  = "Batch [%s] of tenant [%s] was not found"
+	msgTenantNotFound = "Tenant: %s not found"
+	msgAlreadyHeld    = "%s is already under legal hold"
+	msgNotHeld        = "%s is not under legal hold"
+	msgAuditErr       = "error writing legal hold audit entry: %s"
+
+	actionSet     = "set"
+	actionRelease = "release"
+)
+
+// holdTarget is the batch, or the whole tenant when batchId is empty, that a legal hold is set on
+type holdTarget struct {
+	tenantId string
+	batchId  string
+}
+
+func (t holdTarget) String() string {
+	if t.batchId == "" {
+		return fmt.Sprintf("Tenant [%s]", t.tenantId)
+	}
+	return fmt.Sprintf("Batch [%s] of tenant [%s]", t.batchId, t.tenantId)
+}
+
+// the batch's document, or the tenant's configuration document
+func (t holdTarget) document() (string, string) {
+	if t.batchId == "" {
+		return elastic.TenantsIndex, t.tenantId
+	}
+	return elastic.IndexFromTenantId(t.tenantId), t.batchId
+}
+
+// HoldBatch puts the batch under legal hold, which keeps it from being archived or deleted until the hold is released
+func HoldBatch(ctx context.Context, requestId string, request model.BatchLegalHold, claims auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/HoldBatch"
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
+	logger.Debugln("Start Batch Legal Hold")
+
+	if code, errDetail := checkAdminScope(requestId, request.TenantId, actionSet, claims, logger); errDetail != nil {
+		return code, errDetail
+	}
+	return changeBatchHold(ctx, requestId, request, actionSet, claims.Subject, client, logger)
+}
+
+func HoldBatchNoAuth(ctx context.Context, requestId string, request model.BatchLegalHold, _ auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/HoldBatchNoAuth"
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
+	logger.Debugln("Start Batch Legal Hold (No Auth)")
+
+	return changeBatchHold(ctx, requestId, request, actionSet, auth.NoAuthFakeAdmin, client, logger)
+}
+
+// ReleaseBatch releases the batch's legal hold
+func ReleaseBatch(ctx context.Context, requestId string, request model.BatchLegalHold, claims auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/ReleaseBatch"
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
+	logger.Debugln("Start Batch Legal Hold Release")
+
+	if code, errDetail := checkAdminScope(requestId, request.TenantId, actionRelease, claims, logger); errDetail != nil {
+		return code, errDetail
+	}
+	return changeBatchHold(ctx, requestId, request, actionRelease, claims.Subject, client, logger)
+}
+
+func ReleaseBatchNoAuth(ctx context.Context, requestId string, request model.BatchLegalHold, _ auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/ReleaseBatchNoAuth"
+	var logger = logwrapper.GetMyBatchLogger(requestId, prefix, request.TenantId, request.BatchId)
+	logger.Debugln("Start Batch Legal Hold Release (No Auth)")
+
+	return changeBatchHold(ctx, requestId, request, actionRelease, auth.NoAuthFakeAdmin, client, logger)
+}
+
+// HoldTenant puts the whole tenant under legal hold. None of its batches are archived or purged, and the tenant
+// can't be deleted, until the hold is released.
+func HoldTenant(ctx context.Context, requestId string, request model.TenantLegalHold, claims auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/HoldTenant"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Legal Hold")
+
+	if code, errDetail := checkAdminScope(requestId, request.TenantId, actionSet, claims, logger); errDetail != nil {
+		return code, errDetail
+	}
+	return changeTenantHold(ctx, requestId, request, actionSet, claims.Subject, client, logger)
+}
+
+func HoldTenantNoAuth(ctx context.Context, requestId string, request model.TenantLegalHold, _ auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/HoldTenantNoAuth"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Legal Hold (No Auth)")
+
+	return changeTenantHold(ctx, requestId, request, actionSet, auth.NoAuthFakeAdmin, client, logger)
+}
+
+// ReleaseTenant releases the tenant's legal hold. Legal holds on its individual batches are kept.
+func ReleaseTenant(ctx context.Context, requestId string, request model.TenantLegalHold, claims auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/ReleaseTenant"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Legal Hold Release")
+
+	if code, errDetail := checkAdminScope(requestId, request.TenantId, actionRelease, claims, logger); errDetail != nil {
+		return code, errDetail
+	}
+	return changeTenantHold(ctx, requestId, request, actionRelease, claims.Subject, client, logger)
+}
+
+func ReleaseTenantNoAuth(ctx context.Context, requestId string, request model.TenantLegalHold, _ auth.HriClaims,
+	client *elasticsearch.Client) (int, interface{}) {
+
+	prefix := "legalhold/ReleaseTenantNoAuth"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Legal Hold Release (No Auth)")
+
+	return changeTenantHold(ctx, requestId, request, actionRelease, auth.NoAuthFakeAdmin, client, logger)
+}
+
+func checkAdminScope(requestId string, tenantId string, action string, claims auth.HriClaims,
+	logger logrus.FieldLogger) (int, *response.ErrorDetail) {
+
+	policyAction := auth.ActionSetLegalHold
+	if action == actionRelease {
+		policyAction = auth.ActionReleaseLegalHold
+	}
+	// by default, only admins can set and release legal holds
+	if !auth.Authorize(claims, tenantId, policyAction).Allowed {
+		msg := fmt.Sprintf(auth.MsgAdminRoleRequired, action)
+		logger.Errorln(msg)
+		return http.StatusForbidden, response.NewErrorDetail(requestId, msg)
+	}
+	if claims.Subject == "" {
+		logger.Errorln(auth.MsgSubClaimRequiredInJwt)
+		return http.StatusUnauthorized, response.NewErrorDetail(requestId, auth.MsgSubClaimRequiredInJwt)
+	}
+	return http.StatusOK, nil
+}
+
+func changeBatchHold(ctx context.Context, requestId string, request model.BatchLegalHold, action string, actor string,
+	client *elasticsearch.Client, logger logrus.FieldLogger) (int, interface{}) {
+
+	target := holdTarget{tenantId: request.TenantId, batchId: request.BatchId}
+	index, docId := target.document()
+
+	res, err := client.Get(index, docId, client.Get.WithContext(ctx))
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		if elasticErr.Code == http.StatusNotFound {
+			msg := fmt.Sprintf(msgBatchNotFound, request.BatchId, request.TenantId)
+			logger.Errorln(msg)
+			return http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+		}
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not %s the legal hold of %s", action, target))
+	}
+
+	source, _ := body["_source"].(map[string]interface{})
+	current, err := legalHoldFromSource(source[param.LegalHold])
+	if err != nil {
+		msg := fmt.Sprintf("Could not %s the legal hold of %s: %s", action, target, err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+
+	return changeHold(ctx, requestId, target, action, request.Reason, actor, current, client, logger)
+}
+
+func changeTenantHold(ctx context.Context, requestId string, request model.TenantLegalHold, action string, actor string,
+	client *elasticsearch.Client, logger logrus.FieldLogger) (int, interface{}) {
+
+	tenantId := request.TenantId
+	target := holdTarget{tenantId: tenantId}
+	exists, err := elastic.IndexExists(ctx, elastic.IndexFromTenantId(tenantId), client)
+	if err != nil {
+		msg := fmt.Sprintf("Could not %s the legal hold of %s: %s", action, target, err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+	if !exists {
+		msg := fmt.Sprintf(msgTenantNotFound, tenantId)
+		logger.Errorln(msg)
+		return http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+	}
+
+	tenantConfig, elasticErr := elastic.GetTenantConfig(ctx, tenantId, client)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not %s the legal hold of %s", action, target))
+	}
+
+	return changeHold(ctx, requestId, target, action, request.Reason, actor, tenantConfig.LegalHold, client, logger)
+}
+
+// changeHold stores the new legal hold, or removes the current one, and records the change in the audit index. If the
+// audit entry can't be written, the previous hold is restored so that every change is audited.
+func changeHold(ctx context.Context, requestId string, target holdTarget, action string, reason string, actor string,
+	current *model.LegalHold, client *elasticsearch.Client, logger logrus.FieldLogger) (int, interface{}) {
+
+	var legalHold *model.LegalHold
+	if action == actionSet {
+		if current != nil {
+			msg := fmt.Sprintf(msgAlreadyHeld, target)
+			logger.Errorln(msg)
+			return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+		}
+		legalHold = &model.LegalHold{
+			Reason:   reason,
+			Actor:    actor,
+			HoldDate: time.Now().UTC().Format(elastic.DateTimeFormat),
+		}
+	} else if current == nil {
+		msg := fmt.Sprintf(msgNotHeld, target)
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+
+	if elasticErr := setLegalHold(ctx, target, legalHold, client); elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not %s the legal hold of %s", action, target))
+	}
+
+	entry := elastic.LegalHoldAuditEntry{
+		TenantId:  target.tenantId,
+		BatchId:   target.batchId,
+		Action:    action,
+		Reason:    reason,
+		Actor:     actor,
+		Date:      time.Now().UTC().Format(elastic.DateTimeFormat),
+		RequestId: requestId,
+	}
+	if elasticErr := elastic.AddLegalHoldAuditEntry(ctx, entry, client); elasticErr != nil {
+		msg := fmt.Sprintf(msgAuditErr, elasticErr.Error())
+		logger.Errorln(msg)
+
+		if elasticErr := setLegalHold(ctx, target, current, client); elasticErr != nil {
+			logger.Errorf("Unable to revert the legal hold of %s: %s", target, elasticErr.Error())
+		}
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+
+	if legalHold != nil {
+		logger.Infof("%s was put under legal hold by %s: %s", target, actor, reason)
+	} else {
+		logger.Infof("The legal hold of %s was released by %s: %s", target, actor, reason)
+	}
+
+	body := map[string]interface{}{param.TenantId: target.tenantId}
+	if target.batchId != "" {
+		body[param.BatchId] = target.batchId
+	}
+	if legalHold != nil {
+		body[param.LegalHold] = legalHold
+	}
+	return http.StatusOK, body
+}
+
+func setLegalHold(ctx context.Context, target holdTarget, legalHold *model.LegalHold, client *elasticsearch.Client) *elastic.ResponseError {
+	var updateRequest map[string]interface{}
+	if legalHold == nil {
+		updateRequest = map[string]interface{}{
+			"script": map[string]interface{}{
+				"source": "ctx._source.remove('legalHold')",
+			},
+		}
+	} else {
+		updateRequest = map[string]interface{}{
+			"doc": map[string]interface{}{param.LegalHold: legalHold},
+		}
+		if target.batchId == "" {
+			// tenants without a configuration get one that only holds the legal hold
+			updateRequest["doc_as_upsert"] = true
+		}
+	}
+
+	encodedQuery, err := elastic.EncodeQueryBody(updateRequest)
+	if err != nil {
+		return &elastic.ResponseError{ErrorObj: fmt.Errorf("error encoding Elastic query: %w", err),
+			Code: http.StatusInternalServerError}
+	}
+
+	index, docId := target.document()
+	res, err := client.Update(
+		index,
+		docId,
+		encodedQuery,
+		client.Update.WithContext(ctx),
+		client.Update.WithRefresh("true"),
+	)
+	_, elasticErr := elastic.DecodeBody(res, err)
+	return elasticErr
+}
+
+func legalHoldFromSource(source interface{}) (*model.LegalHold, error) {
+	if source == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(source)
+	if err != nil {
+		return nil, err
+	}
+	legalHold := &model.LegalHold{}
+	if err := json.Unmarshal(encoded, legalHold); err != nil {
+		return nil, err
+	}
+	return legalHold, nil
+}
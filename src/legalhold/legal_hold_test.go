@@ -0,0 +1,400 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package legalhold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+const (
+	requestId     = "request_id_1"
+	holdDate      = "2021-02-24T18:08:36Z"
+	currentHold   = `{"reason":"litigation","actor":"admin","holdDate":"` + holdDate + `"}`
+	releaseScript = `{"script":{"source":"ctx\._source\.remove\('legalHold'\)"}}` + "\n"
+)
+
+var (
+	adminClaims   = auth.HriClaims{Scope: auth.HriAdmin, Subject: "admin"}
+	auditPath     = fmt.Sprintf("/%s/_doc", elastic.LegalHoldAuditIndex)
+	auditResponse = fmt.Sprintf(`{"_index":"%s","result":"created"}`, elastic.LegalHoldAuditIndex)
+	batchDocPath  = fmt.Sprintf("/%s-batches/_doc/%s", tenantId, batchId)
+	batchUpdPath  = fmt.Sprintf("/%s-batches/_doc/%s/_update", tenantId, batchId)
+	indexPath     = fmt.Sprintf("/%s-batches", tenantId)
+	configPath    = fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	configUpdPath = fmt.Sprintf("/%s/_doc/%s/_update", elastic.TenantsIndex, tenantId)
+)
+
+func auditBody(batchId string, action string, actor string) string {
+	batchField := ""
+	if batchId != "" {
+		batchField = `"batchId":"` + batchId + `",`
+	}
+	return `{"tenantId":"` + tenantId + `",` + batchField + `"action":"` + action + `","reason":"litigation","actor":"` +
+		actor + `","date":"` + test.DatePattern + `","requestId":"` + requestId + `"}`
+}
+
+func batchDoc(legalHold string) test.ElasticCall {
+	source := `{"name":"batch","status":"completed"}`
+	if legalHold != "" {
+		source = `{"name":"batch","status":"completed","legalHold":` + legalHold + `}`
+	}
+	return test.ElasticCall{
+		ResponseBody: fmt.Sprintf(`{"_index":"%s-batches","_id":"%s","found":true,"_source":%s}`, tenantId, batchId, source),
+	}
+}
+
+// withoutHoldDate clears the hold date, which is set to the current time, from a successful response
+func withoutHoldDate(body interface{}) interface{} {
+	if fields, ok := body.(map[string]interface{}); ok {
+		if legalHold, ok := fields[param.LegalHold].(*model.LegalHold); ok {
+			legalHold.HoldDate = ""
+		}
+	}
+	return body
+}
+
+func TestHoldBatch(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	request := model.BatchLegalHold{TenantId: tenantId, BatchId: batchId, Reason: "litigation"}
+	heldCall := test.ElasticCall{
+		RequestQuery: "refresh=true",
+		RequestBody:  `{"doc":{"legalHold":{"reason":"litigation","actor":"admin","holdDate":"` + test.DatePattern + `"}}}` + "\n",
+		ResponseBody: fmt.Sprintf(`{"_index":"%s-batches","_id":"%s","result":"updated"}`, tenantId, batchId),
+	}
+	heldBody := map[string]interface{}{
+		param.TenantId:  tenantId,
+		param.BatchId:   batchId,
+		param.LegalHold: &model.LegalHold{Reason: "litigation", Actor: "admin"},
+	}
+
+	testCases := []struct {
+		name         string
+		claims       auth.HriClaims
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name:         "missing-admin-scope",
+			claims:       auth.HriClaims{Scope: auth.HriConsumer, Subject: "consumer"},
+			transport:    test.NewFakeTransport(t),
+			expectedCode: http.StatusForbidden,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(auth.MsgAdminRoleRequired, "set")),
+		},
+		{
+			name:         "missing-subject",
+			claims:       auth.HriClaims{Scope: auth.HriAdmin},
+			transport:    test.NewFakeTransport(t),
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: response.NewErrorDetail(requestId, auth.MsgSubClaimRequiredInJwt),
+		},
+		{
+			name:   "batch-not-found",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).AddCall(batchDocPath, test.ElasticCall{
+				ResponseStatusCode: http.StatusNotFound,
+				ResponseBody:       fmt.Sprintf(`{"_index":"%s-batches","_id":"%s","found":false}`, tenantId, batchId),
+			}),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgBatchNotFound, batchId, tenantId)),
+		},
+		{
+			name:   "get-error",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).AddCall(batchDocPath, test.ElasticCall{
+				ResponseErr: errors.New("connection refused"),
+			}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not set the legal hold of Batch [batch456] of tenant [tenant123]: [500] elasticsearch client error: connection refused"),
+		},
+		{
+			name:         "already-held",
+			claims:       adminClaims,
+			transport:    test.NewFakeTransport(t).AddCall(batchDocPath, batchDoc(currentHold)),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgAlreadyHeld, "Batch [batch456] of tenant [tenant123]")),
+		},
+		{
+			name:   "update-error",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).
+				AddCall(batchDocPath, batchDoc("")).
+				AddCall(batchUpdPath, test.ElasticCall{ResponseErr: errors.New("connection refused")}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not set the legal hold of Batch [batch456] of tenant [tenant123]: [500] elasticsearch client error: connection refused"),
+		},
+		{
+			name:   "audit-error-reverts-hold",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).
+				AddCall(batchDocPath, batchDoc("")).
+				AddCall(batchUpdPath, heldCall).
+				AddCall(auditPath, test.ElasticCall{
+					RequestBody: auditBody(batchId, "set", "admin"),
+					ResponseErr: errors.New("connection refused"),
+				}).
+				AddCall(batchUpdPath, test.ElasticCall{
+					RequestBody:  releaseScript,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s-batches","_id":"%s","result":"updated"}`, tenantId, batchId),
+				}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				fmt.Sprintf(msgAuditErr, "elasticsearch client error: connection refused")),
+		},
+		{
+			name:   "good-request",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).
+				AddCall(batchDocPath, batchDoc("")).
+				AddCall(batchUpdPath, heldCall).
+				AddCall(auditPath, test.ElasticCall{
+					RequestBody:  auditBody(batchId, "set", "admin"),
+					ResponseBody: auditResponse,
+				}),
+			expectedCode: http.StatusOK,
+			expectedBody: heldBody,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := HoldBatch(context.Background(), requestId, request, tc.claims, client)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, withoutHoldDate(body))
+			tc.transport.VerifyCalls()
+		})
+	}
+
+	// without auth, the hold is set by a placeholder actor
+	transport := test.NewFakeTransport(t).
+		AddCall(batchDocPath, batchDoc("")).
+		AddCall(batchUpdPath, test.ElasticCall{ResponseBody: heldCall.ResponseBody}).
+		AddCall(auditPath, test.ElasticCall{
+			RequestBody:  auditBody(batchId, "set", auth.NoAuthFakeAdmin),
+			ResponseBody: auditResponse,
+		})
+	client, err := elastic.ClientFromTransport(transport)
+	assert.NoError(t, err)
+	code, body := HoldBatchNoAuth(context.Background(), requestId, request, auth.HriClaims{}, client)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{
+		param.TenantId:  tenantId,
+		param.BatchId:   batchId,
+		param.LegalHold: &model.LegalHold{Reason: "litigation", Actor: auth.NoAuthFakeAdmin},
+	}, withoutHoldDate(body))
+	transport.VerifyCalls()
+}
+
+func TestReleaseBatch(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	request := model.BatchLegalHold{TenantId: tenantId, BatchId: batchId, Reason: "litigation"}
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name:         "not-held",
+			transport:    test.NewFakeTransport(t).AddCall(batchDocPath, batchDoc("")),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgNotHeld, "Batch [batch456] of tenant [tenant123]")),
+		},
+		{
+			name: "audit-error-restores-hold",
+			transport: test.NewFakeTransport(t).
+				AddCall(batchDocPath, batchDoc(currentHold)).
+				AddCall(batchUpdPath, test.ElasticCall{RequestBody: releaseScript, ResponseBody: `{"result":"updated"}`}).
+				AddCall(auditPath, test.ElasticCall{ResponseErr: errors.New("connection refused")}).
+				AddCall(batchUpdPath, test.ElasticCall{
+					RequestBody:  `{"doc":{"legalHold":` + currentHold + `}}`,
+					ResponseBody: `{"result":"updated"}`,
+				}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				fmt.Sprintf(msgAuditErr, "elasticsearch client error: connection refused")),
+		},
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).
+				AddCall(batchDocPath, batchDoc(currentHold)).
+				AddCall(batchUpdPath, test.ElasticCall{
+					RequestQuery: "refresh=true",
+					RequestBody:  releaseScript,
+					ResponseBody: `{"result":"updated"}`,
+				}).
+				AddCall(auditPath, test.ElasticCall{
+					RequestBody:  auditBody(batchId, "release", "admin"),
+					ResponseBody: auditResponse,
+				}),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{param.TenantId: tenantId, param.BatchId: batchId},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := ReleaseBatch(context.Background(), requestId, request, adminClaims, client)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, body)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestHoldTenant(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	request := model.TenantLegalHold{TenantId: tenantId, Reason: "litigation"}
+	noConfigCall := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+	}
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name:         "tenant-not-found",
+			transport:    test.NewFakeTransport(t).AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusNotFound}),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantNotFound, tenantId)),
+		},
+		{
+			name: "already-held",
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"legalHold":%s}}`,
+						elastic.TenantsIndex, tenantId, currentHold),
+				}),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgAlreadyHeld, "Tenant [tenant123]")),
+		},
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, noConfigCall).
+				AddCall(configUpdPath, test.ElasticCall{
+					RequestQuery: "refresh=true",
+					RequestBody:  `{"doc":{"legalHold":{"reason":"litigation","actor":"admin","holdDate":"` + test.DatePattern + `"}},"doc_as_upsert":true}` + "\n",
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"created"}`, elastic.TenantsIndex, tenantId),
+				}).
+				AddCall(auditPath, test.ElasticCall{
+					RequestBody:  auditBody("", "set", "admin"),
+					ResponseBody: auditResponse,
+				}),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId:  tenantId,
+				param.LegalHold: &model.LegalHold{Reason: "litigation", Actor: "admin"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := HoldTenant(context.Background(), requestId, request, adminClaims, client)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, withoutHoldDate(body))
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestReleaseTenant(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	request := model.TenantLegalHold{TenantId: tenantId, Reason: "litigation"}
+
+	testCases := []struct {
+		name         string
+		claims       auth.HriClaims
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name:         "missing-admin-scope",
+			claims:       auth.HriClaims{Scope: auth.HriIntegrator, Subject: "integrator"},
+			transport:    test.NewFakeTransport(t),
+			expectedCode: http.StatusForbidden,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(auth.MsgAdminRoleRequired, "release")),
+		},
+		{
+			name:   "not-held",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"retentionDays":30}}`,
+						elastic.TenantsIndex, tenantId),
+				}),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgNotHeld, "Tenant [tenant123]")),
+		},
+		{
+			name:   "good-request",
+			claims: adminClaims,
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"legalHold":%s}}`,
+						elastic.TenantsIndex, tenantId, currentHold),
+				}).
+				AddCall(configUpdPath, test.ElasticCall{
+					RequestQuery: "refresh=true",
+					RequestBody:  releaseScript,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"updated"}`, elastic.TenantsIndex, tenantId),
+				}).
+				AddCall(auditPath, test.ElasticCall{
+					RequestBody:  auditBody("", "release", "admin"),
+					ResponseBody: auditResponse,
+				}),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{param.TenantId: tenantId},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := ReleaseTenant(context.Background(), requestId, request, tc.claims, client)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, body)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
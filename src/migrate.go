@@ -0,0 +1,56 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"os"
+)
+
+const migrateCommand = "migrate"
+
+// migrate installs the index templates and migrates every tenant's batches index to the current schema version.
+// It's run with 'hri-mgmt-api migrate' followed by the same arguments as the server.
+func migrate(args []string) int {
+	configPath := "./config.yml"
+	config, err := config.GetConfig(configPath, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR CREATING CONFIG: %v\n", err)
+		return 1
+	}
+
+	if _, err := logwrapper.InitializeFromConfig(config); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Could NOT initialize Logger: %v\n", err)
+		return 3 //special return code for logging problems
+	}
+	defer logwrapper.Close()
+	logger := logwrapper.GetMyLogger("", "MIGRATE")
+
+	esClient, err := elastic.ClientFromConfig(config)
+	if err != nil {
+		logger.Errorf("ERROR CREATING ELASTIC CLIENT: %v", err)
+		return 1
+	}
+
+	installed, err := elastic.InstallIndexTemplates(esClient, logger)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return 1
+	}
+	logger.Infof("Installed %d index templates", len(installed))
+
+	migrated, errs := elastic.MigrateTenantIndices(esClient, logger)
+	logger.Infof("Migrated %d tenant indices", migrated)
+	if len(errs) > 0 {
+		logger.Errorf("%d tenant indices could not be migrated", len(errs))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/apiv2"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/labstack/echo/v4"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const msgInvalidRequest = "the request doesn't match the API: %s"
+
+// Middleware validates the requests and responses against the OpenAPI document. It's meant for development and test
+// environments, to find where the API and the document drifted apart. Invalid requests are rejected with a 400
+// before the handler is called. Invalid responses are logged, they're still sent as they are.
+func Middleware() echo.MiddlewareFunc {
+	return middlewareFor(spec)
+}
+
+func middlewareFor(doc *Document) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+			var logger = logwrapper.GetMyLogger(requestId, "openapi/middleware")
+
+			operation := doc.Operation(c.Request().Method, c.Path())
+			if operation == nil {
+				// unmatched requests are answered by Echo, routes that aren't documented are found by the tests
+				return next(c)
+			}
+
+			// the bodies of the streamed routes aren't read or copied, only their parameters and status are validated
+			streamed := logwrapper.IsStreamed(c)
+			if err := doc.validateRequest(operation, c, streamed); err != nil {
+				msg := fmt.Sprintf(msgInvalidRequest, err.Error())
+				logger.Errorln(msg)
+				if apiv2.IsV2(c.Request().URL.Path) {
+					return apiv2.Problem(c, http.StatusBadRequest, msg)
+				}
+				return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
+			}
+
+			body := new(bytes.Buffer)
+			if !streamed {
+				writer := &bodyWriter{Writer: io.MultiWriter(c.Response().Writer, body), ResponseWriter: c.Response().Writer}
+				c.Response().Writer = writer
+			}
+
+			err := next(c)
+			if err != nil {
+				// sets the response of the error, so it's validated too
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			if validationErr := doc.validateResponse(operation, status, c.Response().Header(), body.Bytes()); validationErr != nil {
+				logger.Errorf("The %d response of %s %s doesn't match the API: %s", status, c.Request().Method,
+					c.Path(), validationErr.Error())
+			}
+			return err
+		}
+	}
+}
+
+// validateRequest checks the query parameters and, unless skipBody is set, that a JSON body matches its schema
+func (d *Document) validateRequest(operation *Operation, c echo.Context, skipBody bool) error {
+	for _, parameter := range operation.Parameters {
+		if parameter.In != "query" {
+			continue
+		}
+		value := c.QueryParam(parameter.Name)
+		if value == "" {
+			if parameter.Required {
+				return fmt.Errorf("the %s query parameter is required", parameter.Name)
+			}
+			continue
+		}
+		parsed, err := parseParameter(parameter.Schema, value)
+		if err != nil {
+			return fmt.Errorf("%s must be %s %s", parameter.Name, article(parameter.Schema.Type), parameter.Schema.Type)
+		}
+		if err := d.Validate(parameter.Schema, parsed, parameter.Name); err != nil {
+			return err
+		}
+	}
+
+	if operation.RequestBody == nil || skipBody {
+		return nil
+	}
+	request := c.Request()
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read the request body: %w", err)
+	}
+	// the handler reads the body again
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			return fmt.Errorf("the request body is required")
+		}
+		return nil
+	}
+	mediaType, ok := operation.RequestBody.Content[echo.MIMEApplicationJSON]
+	if !ok || !strings.HasPrefix(request.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("the request body isn't valid JSON: %w", err)
+	}
+	return d.Validate(mediaType.Schema, value, "body")
+}
+
+// validateResponse checks that the status is documented and that a JSON body matches its schema
+func (d *Document) validateResponse(operation *Operation, status int, header http.Header, body []byte) error {
+	resp, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		if resp, ok = operation.Responses["default"]; !ok {
+			return fmt.Errorf("the status isn't documented")
+		}
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 || !strings.HasPrefix(header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return nil
+	}
+	mediaType, ok := resp.Content[echo.MIMEApplicationJSON]
+	if !ok {
+		return fmt.Errorf("a JSON body isn't documented")
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("the body isn't valid JSON: %w", err)
+	}
+	return d.Validate(mediaType.Schema, value, "body")
+}
+
+// bodyWriter copies the response body, like Echo's BodyDump middleware
+type bodyWriter struct {
+	io.Writer
+	http.ResponseWriter
+}
+
+func (w *bodyWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	return w.Writer.Write(b)
+}
+
+func (w *bodyWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *bodyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
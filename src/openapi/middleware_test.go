@@ -0,0 +1,226 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package openapi
+
+import (
+	"bytes"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const requestId = "requestId"
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		handler          echo.HandlerFunc
+		expectedCode     int
+		expectedBody     string
+		expectedLog      string
+		handlerNotCalled bool
+	}{
+		{
+			name:         "valid request and response",
+			method:       http.MethodPost,
+			path:         "/hri/tenants/tenant1/batches",
+			body:         `{"name":"batch1","topic":"ingest.tenant1.stream1.in","dataType":"claims"}`,
+			handler:      respond(http.StatusCreated, map[string]interface{}{"id": "batch1"}),
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":"batch1"}`,
+		},
+		{
+			name:             "invalid request body",
+			method:           http.MethodPost,
+			path:             "/hri/tenants/tenant1/batches",
+			body:             `{"name":"batch1","dataType":"claims"}`,
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"the request doesn't match the API: body.topic is required"}`,
+			expectedLog:      "the request doesn't match the API: body.topic is required",
+			handlerNotCalled: true,
+		},
+		{
+			name:             "missing request body",
+			method:           http.MethodPost,
+			path:             "/hri/tenants/tenant1/batches",
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"the request doesn't match the API: the request body is required"}`,
+			expectedLog:      "the request doesn't match the API: the request body is required",
+			handlerNotCalled: true,
+		},
+		{
+			name:             "invalid query parameter",
+			method:           http.MethodGet,
+			path:             "/hri/tenants/tenant1/batches?size=ten",
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"the request doesn't match the API: size must be an integer"}`,
+			expectedLog:      "the request doesn't match the API: size must be an integer",
+			handlerNotCalled: true,
+		},
+		{
+			name:             "query parameter not in the enum",
+			method:           http.MethodGet,
+			path:             "/hri/tenants/tenant1/batches?status=paused",
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"errorEventId":"requestId","errorDescription":"the request doesn't match the API: status must be one of [started sendCompleted completed failed terminated]"}`,
+			expectedLog:      "the request doesn't match the API: status must be one of [started sendCompleted completed failed terminated]",
+			handlerNotCalled: true,
+		},
+		{
+			name:             "invalid v2 request",
+			method:           http.MethodGet,
+			path:             "/hri/v2/tenants/tenant1/batches?size=ten",
+			expectedCode:     http.StatusBadRequest,
+			expectedBody:     `{"type":"about:blank","title":"Bad Request","status":400,"detail":"the request doesn't match the API: size must be an integer","instance":"/hri/v2/tenants/tenant1/batches","errorEventId":"requestId"}`,
+			expectedLog:      "the request doesn't match the API: size must be an integer",
+			handlerNotCalled: true,
+		},
+		{
+			name:         "invalid response is logged and sent",
+			method:       http.MethodPost,
+			path:         "/hri/tenants/tenant1/batches",
+			body:         `{"name":"batch1","topic":"ingest.tenant1.stream1.in","dataType":"claims"}`,
+			handler:      respond(http.StatusCreated, map[string]interface{}{"id": 5}),
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":5}`,
+			expectedLog:  "The 201 response of POST /hri/tenants/:tenantId/batches doesn't match the API: body.id must be a string, not a number",
+		},
+		{
+			name:   "error response is validated",
+			method: http.MethodGet,
+			path:   "/hri/tenants/tenant1/batches",
+			handler: func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusNotFound, "no such tenant")
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"message":"no such tenant"}`,
+			expectedLog:  "The 404 response of GET /hri/tenants/:tenantId/batches doesn't match the API: body.errorEventId is required",
+		},
+		{
+			name:         "undocumented route",
+			method:       http.MethodGet,
+			path:         "/undocumented",
+			handler:      respond(http.StatusOK, map[string]interface{}{"any": true}),
+			expectedCode: http.StatusOK,
+			expectedBody: `{"any":true}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := new(bytes.Buffer)
+			logwrapper.Initialize("info", logs)
+
+			called := false
+			handler := func(c echo.Context) error {
+				called = true
+				return tc.handler(c)
+			}
+			e := echo.New()
+			e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+					return next(c)
+				}
+			})
+			e.Use(middlewareFor(spec))
+			e.GET("/hri/tenants/:tenantId/batches", handler)
+			e.POST("/hri/tenants/:tenantId/batches", handler)
+			e.GET("/hri/v2/tenants/:tenantId/batches", handler)
+			e.GET("/undocumented", handler)
+
+			request := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, request)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.JSONEq(t, tc.expectedBody, rec.Body.String())
+			assert.Equal(t, !tc.handlerNotCalled, called)
+			if tc.expectedLog == "" {
+				assert.NotContains(t, logs.String(), "doesn't match the API")
+			} else {
+				assert.Contains(t, logs.String(), tc.expectedLog)
+			}
+		})
+	}
+}
+
+func TestMiddlewareHandlerReadsBody(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	var bound struct {
+		Name string `json:"name"`
+	}
+	e.POST("/hri/tenants/:tenantId/batches", func(c echo.Context) error {
+		if err := c.Bind(&bound); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, map[string]interface{}{"id": "batch1"})
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/hri/tenants/tenant1/batches",
+		strings.NewReader(`{"name":"batch1","topic":"ingest.tenant1.stream1.in","dataType":"claims"}`))
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, request)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "batch1", bound.Name)
+}
+
+func TestMiddlewareStreamedRoutes(t *testing.T) {
+	e := echo.New()
+	e.Use(Middleware())
+	var handlerBody io.ReadCloser
+	e.POST("/hri/tenants/:tenantId/import", func(c echo.Context) error {
+		handlerBody = c.Request().Body
+		return c.JSON(http.StatusOK, map[string]interface{}{})
+	})
+
+	// the handler gets the body as it was received, it isn't read into memory first
+	requestBody := ioutil.NopCloser(strings.NewReader(`{"type":"tenant"}` + "\n"))
+	request := httptest.NewRequest(http.MethodPost, "/hri/tenants/tenant1/import", nil)
+	request.Body = requestBody
+	request.Header.Set(echo.HeaderContentType, "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, requestBody, handlerBody)
+
+	// the query parameters are still validated
+	request = httptest.NewRequest(http.MethodPost, "/hri/tenants/tenant1/import?onConflict=merge", strings.NewReader(""))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "onConflict must be one of [fail skip overwrite]")
+}
+
+func TestValidateResponseStatus(t *testing.T) {
+	operation := &Operation{Responses: map[string]Response{"200": {}}}
+	header := http.Header{echo.HeaderContentType: []string{echo.MIMEApplicationJSON}}
+
+	assert.NoError(t, spec.validateResponse(operation, http.StatusOK, header, nil))
+	assert.EqualError(t, spec.validateResponse(operation, http.StatusTeapot, header, nil), "the status isn't documented")
+	assert.EqualError(t, spec.validateResponse(operation, http.StatusOK, header, []byte(`{}`)),
+		"a JSON body isn't documented")
+}
+
+func respond(code int, body interface{}) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(code, body)
+	}
+}
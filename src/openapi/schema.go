@@ -0,0 +1,145 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Validate checks a decoded JSON value against the schema. The name is used in the error messages, e.g. 'body'.
+// Properties the schema doesn't list are allowed, like they are by the request binding.
+func (d *Document) Validate(schema *Schema, value interface{}, name string) error {
+	schema, err := d.resolve(schema)
+	if err != nil {
+		return err
+	}
+	for _, subSchema := range schema.AllOf {
+		if err := d.Validate(subSchema, value, name); err != nil {
+			return err
+		}
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s must not be null", name)
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(name, schema.Type, value)
+		}
+		for _, property := range schema.Required {
+			if _, ok := object[property]; !ok {
+				return fmt.Errorf("%s.%s is required", name, property)
+			}
+		}
+		for property, propertySchema := range schema.Properties {
+			if propertyValue, ok := object[property]; ok {
+				if err := d.Validate(propertySchema, propertyValue, name+"."+property); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return typeError(name, schema.Type, value)
+		}
+		if schema.Items != nil {
+			for i, item := range array {
+				if err := d.Validate(schema.Items, item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return typeError(name, schema.Type, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(name, schema.Type, value)
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || (schema.Type == "integer" && number != math.Trunc(number)) {
+			return typeError(name, schema.Type, value)
+		}
+		if err := checkRange(schema, number, name); err != nil {
+			return err
+		}
+	}
+
+	if len(schema.Enum) > 0 && !contains(schema.Enum, value) {
+		return fmt.Errorf("%s must be one of %v", name, schema.Enum)
+	}
+	return nil
+}
+
+// parseParameter converts a query parameter to the type of its schema, so it can be validated like JSON
+func parseParameter(schema *Schema, value string) (interface{}, error) {
+	switch schema.Type {
+	case "integer", "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	}
+	return value, nil
+}
+
+func checkRange(schema *Schema, number float64, name string) error {
+	if schema.Minimum != nil {
+		if schema.ExclusiveMinimum && number <= *schema.Minimum {
+			return fmt.Errorf("%s must be greater than %v", name, *schema.Minimum)
+		} else if number < *schema.Minimum {
+			return fmt.Errorf("%s must be %v or greater", name, *schema.Minimum)
+		}
+	}
+	if schema.Maximum != nil && number > *schema.Maximum {
+		return fmt.Errorf("%s must be %v or less", name, *schema.Maximum)
+	}
+	return nil
+}
+
+func typeError(name string, expected string, value interface{}) error {
+	actual := "a number"
+	switch value.(type) {
+	case map[string]interface{}:
+		actual = "an object"
+	case []interface{}:
+		actual = "an array"
+	case string:
+		actual = "a string"
+	case bool:
+		actual = "a boolean"
+	}
+	return fmt.Errorf("%s must be %s %s, not %s", name, article(expected), expected, actual)
+}
+
+func article(word string) string {
+	switch word[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an"
+	}
+	return "a"
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		schema        string
+		value         string
+		expectedError string
+	}{
+		{
+			name:   "valid batch",
+			schema: "CreateBatch",
+			value:  `{"name":"batch1","topic":"ingest.tenant1.stream1.in","dataType":"claims","invalidThreshold":5,"metadata":{"any":1}}`,
+		},
+		{
+			name:          "missing required property",
+			schema:        "CreateBatch",
+			value:         `{"name":"batch1","dataType":"claims"}`,
+			expectedError: "body.topic is required",
+		},
+		{
+			name:          "wrong property type",
+			schema:        "CreateBatch",
+			value:         `{"name":"batch1","topic":"topic1","dataType":"claims","invalidThreshold":"5"}`,
+			expectedError: "body.invalidThreshold must be an integer, not a string",
+		},
+		{
+			name:          "not an object",
+			schema:        "CreateBatch",
+			value:         `["batch1"]`,
+			expectedError: "body must be an object, not an array",
+		},
+		{
+			name:   "unknown properties are allowed",
+			schema: "LegalHoldRequest",
+			value:  `{"reason":"litigation","other":true}`,
+		},
+		{
+			name:          "integer with a fraction",
+			schema:        "ProcessingCompleteRequest",
+			value:         `{"actualRecordCount":1.5,"invalidRecordCount":0}`,
+			expectedError: "body.actualRecordCount must be an integer, not a number",
+		},
+		{
+			name:          "below the minimum",
+			schema:        "ProcessingCompleteRequest",
+			value:         `{"actualRecordCount":10,"invalidRecordCount":-1}`,
+			expectedError: "body.invalidRecordCount must be 0 or greater",
+		},
+		{
+			name:          "above the maximum",
+			schema:        "CreateStreamsRequest",
+			value:         `{"numPartitions":100,"retentionMs":3600000}`,
+			expectedError: "body.numPartitions must be 99 or less",
+		},
+		{
+			name:          "not in the enum",
+			schema:        "CreateStreamsRequest",
+			value:         `{"numPartitions":1,"retentionMs":3600000,"cleanupPolicy":"keep"}`,
+			expectedError: "body.cleanupPolicy must be one of [delete compact]",
+		},
+		{
+			name:          "array items",
+			schema:        "CreateApiKey",
+			value:         `{"name":"key1","scopes":["hri_data_integrator",5]}`,
+			expectedError: "body.scopes[1] must be a string, not a number",
+		},
+		{
+			name:          "referenced schema",
+			schema:        "TenantConfig",
+			value:         `{"quota":{"maxActiveBatches":0}}`,
+			expectedError: "body.quota.maxActiveBatches must be 1 or greater",
+		},
+		{
+			name:          "null isn't allowed",
+			schema:        "LegalHoldRequest",
+			value:         `{"reason":null}`,
+			expectedError: "body.reason must not be null",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tc.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := spec.Validate(&Schema{Ref: schemaRefPrefix + tc.schema}, value, "body")
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateExclusiveMinimum(t *testing.T) {
+	minimum := 0.0
+	schema := &Schema{Type: "number", Minimum: &minimum, ExclusiveMinimum: true}
+
+	assert.NoError(t, spec.Validate(schema, 0.5, "requestsPerSecond"))
+	assert.EqualError(t, spec.Validate(schema, 0.0, "requestsPerSecond"), "requestsPerSecond must be greater than 0")
+}
+
+func TestValidateUnknownReference(t *testing.T) {
+	err := spec.Validate(&Schema{Ref: schemaRefPrefix + "Missing"}, "value", "body")
+	assert.EqualError(t, err, "unknown schema reference '#/components/schemas/Missing'")
+}
+
+func TestParseParameter(t *testing.T) {
+	value, err := parseParameter(&Schema{Type: "integer"}, "10")
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, value)
+
+	value, err = parseParameter(&Schema{Type: "boolean"}, "true")
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+
+	value, err = parseParameter(&Schema{Type: "string"}, "started")
+	assert.NoError(t, err)
+	assert.Equal(t, "started", value)
+
+	_, err = parseParameter(&Schema{Type: "integer"}, "ten")
+	assert.Error(t, err)
+}
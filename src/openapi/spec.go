@@ -0,0 +1,133 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// The API's OpenAPI 3 document. The tests check that it describes every route of the server, and that its schemas
+// have the same fields as the request models, so keep it up to date when they change.
+//
+//go:embed openapi.json
+var document []byte
+
+var spec = mustParse(document)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// Document is the part of an OpenAPI document the validation uses
+type Document struct {
+	OpenApi    string              `json:"openapi"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// PathItem has the operations of a path by lower case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationId string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters"`
+	RequestBody *RequestBody        `json:"requestBody"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema supports the subset of JSON Schema the document uses
+type Schema struct {
+	Ref              string             `json:"$ref"`
+	Type             string             `json:"type"`
+	Nullable         bool               `json:"nullable"`
+	ReadOnly         bool               `json:"readOnly"`
+	Enum             []interface{}      `json:"enum"`
+	Minimum          *float64           `json:"minimum"`
+	Maximum          *float64           `json:"maximum"`
+	ExclusiveMinimum bool               `json:"exclusiveMinimum"`
+	Required         []string           `json:"required"`
+	Properties       map[string]*Schema `json:"properties"`
+	Items            *Schema            `json:"items"`
+	AllOf            []*Schema          `json:"allOf"`
+}
+
+func mustParse(data []byte) *Document {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		panic(fmt.Sprintf("invalid OpenAPI document: %s", err.Error()))
+	}
+	return &doc
+}
+
+// Spec returns the parsed OpenAPI document
+func Spec() *Document {
+	return spec
+}
+
+// Handler serves the OpenAPI document
+func Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, document)
+	}
+}
+
+// Operation returns the operation of an Echo route, nil when the document doesn't describe it
+func (d *Document) Operation(method string, route string) *Operation {
+	pathItem, ok := d.Paths[PathFromRoute(route)]
+	if !ok {
+		return nil
+	}
+	return pathItem[strings.ToLower(method)]
+}
+
+// PathFromRoute converts an Echo route, e.g. '/hri/tenants/:tenantId', to an OpenAPI path, '/hri/tenants/{tenantId}'
+func PathFromRoute(route string) string {
+	segments := strings.Split(strings.TrimPrefix(route, "/"), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + strings.TrimPrefix(segment, ":") + "}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// resolve follows the schema's reference to the document's components
+func (d *Document) resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRefPrefix)]
+	if !ok || !strings.HasPrefix(schema.Ref, schemaRefPrefix) {
+		return nil, fmt.Errorf("unknown schema reference '%s'", schema.Ref)
+	}
+	return resolved, nil
+}
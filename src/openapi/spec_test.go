@@ -0,0 +1,220 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package openapi
+
+import (
+	"encoding/json"
+	"github.com/Alvearie/hri-mgmt-api/apiv2"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSchemasMatchRequestModels(t *testing.T) {
+	tests := []struct {
+		schema string
+		model  interface{}
+	}{
+		{schema: "CreateBatch", model: model.CreateBatch{}},
+		{schema: "SendCompleteRequest", model: model.SendCompleteRequest{}},
+		{schema: "TerminateRequest", model: model.TerminateRequest{}},
+		{schema: "ProcessingCompleteRequest", model: model.ProcessingCompleteRequest{}},
+		{schema: "FailRequest", model: model.FailRequest{}},
+		{schema: "CreateStreamsRequest", model: model.CreateStreamsRequest{}},
+		{schema: "TenantConfig", model: model.TenantConfig{}},
+		{schema: "TenantQuota", model: model.TenantQuota{}},
+		{schema: "SuspendTenant", model: model.SuspendTenant{}},
+		{schema: "PurgeTenant", model: model.PurgeTenant{}},
+		{schema: "LegalHoldRequest", model: model.TenantLegalHold{}},
+		{schema: "CreateApiKey", model: model.CreateApiKey{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.schema, func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[tc.schema]
+			if !assert.True(t, ok, "the %s schema is missing", tc.schema) {
+				return
+			}
+			fields := tagNames(reflect.TypeOf(tc.model), "json")
+			var properties []string
+			for name, property := range schema.Properties {
+				// read only properties like the tenant's id are returned, but they aren't fields of the request
+				if !property.ReadOnly || contains(toInterfaces(fields), name) {
+					properties = append(properties, name)
+				}
+			}
+			sort.Strings(properties)
+			assert.Equal(t, fields, properties)
+		})
+	}
+}
+
+func TestSchemasMatchV2Models(t *testing.T) {
+	tests := []struct {
+		schema string
+		model  interface{}
+	}{
+		{schema: "TenantId", model: apiv2.TenantId{}},
+		{schema: "Tenant", model: apiv2.Tenant{}},
+		{schema: "TenantConfig", model: apiv2.TenantConfig{}},
+		{schema: "TenantStatus", model: apiv2.TenantStatus{}},
+		{schema: "TenantDeletion", model: apiv2.TenantDeletion{}},
+		{schema: "PurgeResult", model: apiv2.PurgeResult{}},
+		{schema: "ImportResult", model: apiv2.ImportResult{}},
+		{schema: "BatchId", model: apiv2.BatchId{}},
+		{schema: "BatchV2", model: apiv2.Batch{}},
+		{schema: "BatchListV2", model: apiv2.BatchList{}},
+		{schema: "LegalHoldResult", model: apiv2.LegalHoldResult{}},
+		{schema: "ApiKey", model: apiv2.ApiKey{}},
+		{schema: "ApiKeyList", model: apiv2.ApiKeyList{}},
+		{schema: "StreamId", model: apiv2.StreamId{}},
+		{schema: "StreamList", model: apiv2.StreamList{}},
+		{schema: "StreamLag", model: apiv2.StreamLag{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.schema, func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[tc.schema]
+			if !assert.True(t, ok, "the %s schema is missing", tc.schema) {
+				return
+			}
+			var properties []string
+			for name := range schema.Properties {
+				properties = append(properties, name)
+			}
+			sort.Strings(properties)
+			assert.Equal(t, tagNames(reflect.TypeOf(tc.model), "json"), properties)
+		})
+	}
+}
+
+func TestQueryParametersMatchRequestModels(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		model  interface{}
+	}{
+		{method: http.MethodGet, path: "/hri/tenants/{tenantId}/batches", model: model.GetBatch{}},
+		{method: http.MethodDelete, path: "/hri/tenants/{tenantId}", model: model.DeleteTenant{}},
+		{method: http.MethodPost, path: "/hri/tenants/{tenantId}/import", model: model.ImportTenant{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			operation := spec.Operation(tc.method, tc.path)
+			if !assert.NotNil(t, operation) {
+				return
+			}
+			var parameters []string
+			for _, parameter := range operation.Parameters {
+				if parameter.In == "query" {
+					parameters = append(parameters, parameter.Name)
+				}
+			}
+			sort.Strings(parameters)
+			assert.Equal(t, tagNames(reflect.TypeOf(tc.model), "query"), parameters)
+		})
+	}
+}
+
+func TestSchemaReferencesResolve(t *testing.T) {
+	var raw interface{}
+	if err := json.Unmarshal(document, &raw); err != nil {
+		t.Fatal(err)
+	}
+	var refs []string
+	collectRefs(raw, &refs)
+	assert.NotEmpty(t, refs)
+	for _, ref := range refs {
+		_, err := spec.resolve(&Schema{Ref: ref})
+		assert.NoError(t, err)
+	}
+}
+
+func TestEveryOperationDocumentsErrors(t *testing.T) {
+	for path, pathItem := range spec.Paths {
+		for method, operation := range pathItem {
+			assert.NotEmpty(t, operation.OperationId, "%s %s has no operationId", method, path)
+			assert.Contains(t, operation.Responses, "default", "%s %s has no default response", method, path)
+		}
+	}
+}
+
+func TestPathFromRoute(t *testing.T) {
+	assert.Equal(t, "/hri/tenants", PathFromRoute("/hri/tenants"))
+	assert.Equal(t, "/hri/tenants/{tenantId}/batches/{id}", PathFromRoute("/hri/tenants/:tenantId/batches/:id"))
+	// some routes are registered without the leading slash
+	assert.Equal(t, "/hri/tenants/{tenantId}/streams/{id}", PathFromRoute("hri/tenants/:tenantId/streams/:id"))
+}
+
+func TestOperation(t *testing.T) {
+	operation := spec.Operation(http.MethodGet, "/hri/tenants/:tenantId/batches/:id")
+	if assert.NotNil(t, operation) {
+		assert.Equal(t, "getBatch", operation.OperationId)
+	}
+	assert.Nil(t, spec.Operation(http.MethodPatch, "/hri/tenants/:tenantId/batches/:id"))
+	assert.Nil(t, spec.Operation(http.MethodGet, "/hri/unknown"))
+}
+
+func TestHandler(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/hri/openapi.json", nil), rec)
+
+	assert.NoError(t, Handler()(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, document, rec.Body.Bytes())
+}
+
+// tagNames returns the sorted names of a struct's tag, including the embedded structs'
+func tagNames(structType reflect.Type, tag string) []string {
+	var names []string
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous {
+			names = append(names, tagNames(field.Type, tag)...)
+			continue
+		}
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+func collectRefs(value interface{}, refs *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				*refs = append(*refs, ref)
+			}
+			collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectRefs(child, refs)
+		}
+	}
+}
@@ -7,13 +7,26 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/apikeys"
+	"github.com/Alvearie/hri-mgmt-api/apiv2"
 	"github.com/Alvearie/hri-mgmt-api/batches"
+	"github.com/Alvearie/hri-mgmt-api/common/audit"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/tracing"
 	"github.com/Alvearie/hri-mgmt-api/healthcheck"
+	"github.com/Alvearie/hri-mgmt-api/legalhold"
+	"github.com/Alvearie/hri-mgmt-api/openapi"
 	"github.com/Alvearie/hri-mgmt-api/streams"
 	"github.com/Alvearie/hri-mgmt-api/tenants"
 	"github.com/labstack/echo/v4"
@@ -21,11 +34,24 @@ import (
 	"github.com/newrelic/go-agent/v3/integrations/nrecho-v4"
 	"github.com/newrelic/go-agent/v3/newrelic"
 	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
+// how long to wait before retrying to install the index templates when Elastic can't be reached at startup
+const indexTemplateRetryInterval = 30 * time.Second
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == migrateCommand {
+		os.Exit(migrate(os.Args[2:]))
+	}
+
 	e := echo.New()
 	retCode, startServer, _ := configureMgmtServer(e, os.Args[1:])
 	if retCode != 0 {
@@ -44,8 +70,8 @@ func configureMgmtServer(e *echo.Echo, args []string) (int, func(), error) {
 		return 1, nil, err
 	}
 
-	//Initialize Logging - NOTE: we are hard-coding the log output location to Stdout
-	logCfg, err := logwrapper.Initialize(config.LogLevel, os.Stdout)
+	//Initialize Logging with the configured format and output
+	logCfg, err := logwrapper.InitializeFromConfig(config)
 	if err != nil {
 		msg := "ERROR: Could NOT initialize Logger: %w"
 		fmt.Fprintf(os.Stderr, fmt.Errorf(msg, err).Error()+"\n")
@@ -90,22 +116,50 @@ func configureMgmtServer(e *echo.Echo, args []string) (int, func(), error) {
 	e.Use(
 		middleware.RequestID(), // Generate a request id on the HTTP response headers
 	)
+
+	// Trace every request, the spans are exported to the configured exporter
+	tracingShutdown, err := tracing.Initialize(config)
+	if err != nil {
+		logger.Errorf("ERROR INITIALIZING TRACING: %v\n", err)
+		return 1, nil, err
+	}
+	e.Use(tracing.Middleware())
 	if logLvlInfoOrLess(logCfg) {
 		e.Use(
-			middleware.Logger(), // Log every request/response to stdout
+			logwrapper.RequestLogger(), // Log every request/response with the shared logger
 		)
 	}
 	if logLvlDebugOrLess(logCfg) {
 		e.Use(
-			middleware.BodyDump(func(c echo.Context, reqBody, resBody []byte) {
-				logger.Debugf("%s %s '%v' -> %d '%v'",
-					c.Request().Method, c.Request().URL, string(reqBody), c.Response().Status, string(resBody))
-			}),
+			logwrapper.BodyDump(), // the metadata and failure messages may have PHI, so they're redacted
 		)
 	}
 
 	e.Use(ServerHeader)
 
+	// Count every request and record its latency for Prometheus
+	e.Use(metrics.Middleware())
+	metrics.SetBatchCounter(batches.NewBatchCounter(config))
+
+	// Record every request in the audit log
+	auditLogger, err := audit.NewLoggerFromConfig(config)
+	if err != nil {
+		logger.Errorf("ERROR INITIALIZING THE AUDIT LOG: %v\n", err)
+		return 1, nil, err
+	}
+	if auditLogger != nil {
+		e.Use(audit.Middleware(auditLogger))
+	}
+
+	// Check the API against its OpenAPI document, outside of production
+	if config.OpenApiValidation {
+		e.Use(openapi.Middleware())
+	}
+
+	// The v1 routes keep answering authorization failures with a 401, and the errors of v2 requests are problems
+	e.Use(apiv2.V1Compatibility())
+	e.HTTPErrorHandler = apiv2.ErrorHandler(e.HTTPErrorHandler)
+
 	// Set custom binder
 	customBinder, err := model.GetBinder()
 	if err != nil {
@@ -122,20 +176,94 @@ func configureMgmtServer(e *echo.Echo, args []string) (int, func(), error) {
 	}
 	e.Validator = customValidator
 
+	// Set the stream topic naming convention
+	err = eventstreams.InitializeTopicNaming(config.TopicNameTemplate, config.TopicTypeNames)
+	if err != nil {
+		logger.Errorf("ERROR INITIALIZING TOPIC NAMING: %v\n", err)
+		return 1, nil, err
+	}
+
+	// Load the authorization policy
+	err = auth.InitializePolicy(config.PolicyFile)
+	if err != nil {
+		logger.Errorf("ERROR LOADING THE AUTHORIZATION POLICY: %v\n", err)
+		return 1, nil, err
+	}
+
+	// Load the identities of the clients that authenticate with a certificate
+	err = auth.InitializeCertIdentities(config.CertIdentityFile)
+	if err != nil {
+		logger.Errorf("ERROR LOADING THE CERTIFICATE IDENTITIES: %v\n", err)
+		return 1, nil, err
+	}
+	clientAuthTlsConfig, err := newClientAuthTlsConfig(config)
+	if err != nil {
+		logger.Errorf("ERROR CONFIGURING TLS CLIENT AUTHENTICATION: %v\n", err)
+		return 1, nil, err
+	}
+
 	// Prepare the server start function
 	startFunc := func() {
+		// the background workers are stopped on shutdown, once the requests in progress finished
+		stopWorkers := []func(){startIndexTemplateInstall(config, logger)}
+		if config.ValidatesTokens() {
+			stopWorkers = append(stopWorkers, auth.StartKeyRefresh(config))
+		}
+		if config.BatchTimeoutCheckSecs > 0 {
+			stopWorkers = append(stopWorkers, batches.StartTimeoutMonitor(config))
+		}
+		if config.ArchiveStore != "" && config.RetentionCheckSecs > 0 {
+			stopWorkers = append(stopWorkers, batches.StartRetentionMonitor(config))
+		}
+		shutdownTasks := []shutdownTask{{name: "BACKGROUND WORKERS", run: func(context.Context) error {
+			for _, stop := range stopWorkers {
+				stop()
+			}
+			return nil
+		}}}
+
+		if config.MetricsPort > 0 {
+			metricsServer := metrics.NewServer(config.MetricsPort)
+			go func() {
+				if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logger.Errorf("ERROR SERVING THE METRICS: %v\n", err)
+				}
+			}()
+			shutdownTasks = append(shutdownTasks, shutdownTask{name: "METRICS SERVER", run: metricsServer.Shutdown})
+		}
+		if auditLogger != nil {
+			// flushes the audit entries that haven't been written to Kafka yet
+			shutdownTasks = append(shutdownTasks, shutdownTask{name: "AUDIT LOG", run: func(context.Context) error {
+				auditLogger.Close()
+				return nil
+			}})
+		}
+		// export the spans of the last requests
+		shutdownTasks = append(shutdownTasks, shutdownTask{name: "TRACING", run: tracingShutdown})
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		shutdownDone := shutdownOnSignal(signals, e, time.Duration(config.ShutdownTimeoutSecs)*time.Second, logger,
+			shutdownTasks...)
+
+		address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
 		err := error(nil)
-		if config.TlsEnabled {
-			err = e.StartTLS(":1323", config.TlsCertPath, config.TlsKeyPath)
+		if clientAuthTlsConfig != nil {
+			err = e.StartServer(&http.Server{Addr: address, TLSConfig: clientAuthTlsConfig})
+		} else if config.TlsEnabled {
+			err = e.StartTLS(address, config.TlsCertPath, config.TlsKeyPath)
 
 		} else {
-			err = e.Start(":1323")
+			err = e.Start(address)
 		}
 
-		if err != nil {
+		if err != http.ErrServerClosed {
 			e.Logger.Fatal(err)
 			os.Exit(2)
 		}
+		// the server stops accepting requests as soon as the shutdown starts, wait for it to finish
+		<-shutdownDone
+		logwrapper.Close()
 	}
 
 	// Configure the endpoint routes
@@ -147,14 +275,30 @@ func configureMgmtServer(e *echo.Echo, args []string) (int, func(), error) {
 
 	// Healthcheck routing
 	healthcheckHandler := healthcheck.NewHandler(config)
+	e.GET("/ready", healthcheckHandler.Ready)
 	e.GET("/hri/healthcheck", healthcheckHandler.Healthcheck)
 
+	// The API's OpenAPI document
+	e.GET("/hri/openapi.json", openapi.Handler())
+
+	// Prometheus metrics, unless they are served on a separate admin port
+	if config.MetricsPort == 0 {
+		e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	}
+
 	// Tenants routing
 	tenantsHandler := tenants.NewHandler(config)
 	e.GET("/hri/tenants", tenantsHandler.Get)
 	e.GET(fmt.Sprintf("/hri/tenants/:%s", param.TenantId), tenantsHandler.GetById)
 	e.POST(fmt.Sprintf("/hri/tenants/:%s", param.TenantId), tenantsHandler.Create)
 	e.DELETE(fmt.Sprintf("/hri/tenants/:%s", param.TenantId), tenantsHandler.Delete)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/config", param.TenantId), tenantsHandler.PutConfig)
+	e.GET(fmt.Sprintf("/hri/tenants/:%s/config", param.TenantId), tenantsHandler.GetConfig)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/action/suspend", param.TenantId), tenantsHandler.Suspend)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/action/resume", param.TenantId), tenantsHandler.Resume)
+	e.POST(fmt.Sprintf("/hri/tenants/:%s/action/purge", param.TenantId), tenantsHandler.Purge)
+	e.GET(fmt.Sprintf("/hri/tenants/:%s/export", param.TenantId), tenantsHandler.Export)
+	e.POST(fmt.Sprintf("/hri/tenants/:%s/import", param.TenantId), tenantsHandler.Import)
 
 	// Batches routing
 	batchesHandler := batches.NewHandler(config)
@@ -170,15 +314,155 @@ func configureMgmtServer(e *echo.Echo, args []string) (int, func(), error) {
 	e.PUT(fmt.Sprintf("/hri/tenants/:%s/batches/:%s/action/fail",
 		param.TenantId, param.BatchId), batchesHandler.Fail)
 
+	// Legal hold routing
+	legalHoldHandler := legalhold.NewHandler(config)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/batches/:%s/action/legalHold",
+		param.TenantId, param.BatchId), legalHoldHandler.HoldBatch)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/batches/:%s/action/releaseLegalHold",
+		param.TenantId, param.BatchId), legalHoldHandler.ReleaseBatch)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/action/legalHold", param.TenantId), legalHoldHandler.HoldTenant)
+	e.PUT(fmt.Sprintf("/hri/tenants/:%s/action/releaseLegalHold", param.TenantId), legalHoldHandler.ReleaseTenant)
+
+	// API key routing
+	apiKeysHandler := apikeys.NewHandler(config)
+	e.POST("/hri/apikeys", apiKeysHandler.Create)
+	e.GET("/hri/apikeys", apiKeysHandler.Get)
+	e.PUT(fmt.Sprintf("/hri/apikeys/:%s/action/revoke", param.ApiKeyId), apiKeysHandler.Revoke)
+
 	// Streams routing
 	streamsHandler := streams.NewHandler(config)
 	e.POST(fmt.Sprintf("hri/tenants/:%s/streams/:%s", param.TenantId, param.StreamId), streamsHandler.Create)
 	e.DELETE(fmt.Sprintf("hri/tenants/:%s/streams/:%s", param.TenantId, param.StreamId), streamsHandler.Delete)
 	e.GET(fmt.Sprintf("/hri/tenants/:%s/streams", param.TenantId), streamsHandler.Get)
+	e.GET(fmt.Sprintf("/hri/tenants/:%s/streams/:%s/lag", param.TenantId, param.StreamId), streamsHandler.GetLag)
+
+	// Version 2 routes, served by the same handlers with typed responses and RFC 7807 problems, see apiv2
+	v2 := apiv2.NewRoutes(e)
+	v2.GET("/tenants", tenantsHandler.Get, apiv2.TenantList{})
+	v2.GET(fmt.Sprintf("/tenants/:%s", param.TenantId), tenantsHandler.GetById, apiv2.Tenant{})
+	v2.POST(fmt.Sprintf("/tenants/:%s", param.TenantId), tenantsHandler.Create, apiv2.TenantId{})
+	v2.DELETE(fmt.Sprintf("/tenants/:%s", param.TenantId), tenantsHandler.Delete, apiv2.TenantDeletion{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/config", param.TenantId), tenantsHandler.PutConfig, apiv2.TenantConfig{})
+	v2.GET(fmt.Sprintf("/tenants/:%s/config", param.TenantId), tenantsHandler.GetConfig, apiv2.TenantConfig{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/action/suspend", param.TenantId), tenantsHandler.Suspend, apiv2.TenantStatus{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/action/resume", param.TenantId), tenantsHandler.Resume, apiv2.TenantStatus{})
+	v2.POST(fmt.Sprintf("/tenants/:%s/action/purge", param.TenantId), tenantsHandler.Purge, apiv2.PurgeResult{})
+	v2.GET(fmt.Sprintf("/tenants/:%s/export", param.TenantId), tenantsHandler.Export, nil)
+	v2.POST(fmt.Sprintf("/tenants/:%s/import", param.TenantId), tenantsHandler.Import, apiv2.ImportResult{})
+
+	v2.GET(fmt.Sprintf("/tenants/:%s/batches/:%s", param.TenantId, param.BatchId), batchesHandler.GetById, apiv2.Batch{})
+	v2.POST(fmt.Sprintf("/tenants/:%s/batches", param.TenantId), batchesHandler.Create, apiv2.BatchId{})
+	v2.GET(fmt.Sprintf("/tenants/:%s/batches", param.TenantId), batchesHandler.Get, apiv2.BatchList{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/batches/:%s/action/sendComplete", param.TenantId, param.BatchId),
+		apiv2.SendComplete(batchesHandler.SendComplete), nil)
+	v2.PUT(fmt.Sprintf("/tenants/:%s/batches/:%s/action/terminate", param.TenantId, param.BatchId),
+		batchesHandler.Terminate, nil)
+	v2.PUT(fmt.Sprintf("/tenants/:%s/batches/:%s/action/processingComplete", param.TenantId, param.BatchId),
+		batchesHandler.ProcessingComplete, nil)
+	v2.PUT(fmt.Sprintf("/tenants/:%s/batches/:%s/action/fail", param.TenantId, param.BatchId),
+		batchesHandler.Fail, nil)
+
+	v2.PUT(fmt.Sprintf("/tenants/:%s/batches/:%s/action/legalHold", param.TenantId, param.BatchId),
+		legalHoldHandler.HoldBatch, apiv2.LegalHoldResult{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/batches/:%s/action/releaseLegalHold", param.TenantId, param.BatchId),
+		legalHoldHandler.ReleaseBatch, apiv2.LegalHoldResult{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/action/legalHold", param.TenantId), legalHoldHandler.HoldTenant, apiv2.LegalHoldResult{})
+	v2.PUT(fmt.Sprintf("/tenants/:%s/action/releaseLegalHold", param.TenantId), legalHoldHandler.ReleaseTenant,
+		apiv2.LegalHoldResult{})
+
+	v2.POST("/apikeys", apiKeysHandler.Create, apiv2.ApiKey{})
+	v2.GET("/apikeys", apiKeysHandler.Get, apiv2.ApiKeyList{})
+	v2.PUT(fmt.Sprintf("/apikeys/:%s/action/revoke", param.ApiKeyId), apiKeysHandler.Revoke, apiv2.ApiKey{})
+
+	v2.POST(fmt.Sprintf("/tenants/:%s/streams/:%s", param.TenantId, param.StreamId), streamsHandler.Create, apiv2.StreamId{})
+	v2.DELETE(fmt.Sprintf("/tenants/:%s/streams/:%s", param.TenantId, param.StreamId), streamsHandler.Delete, nil)
+	v2.GET(fmt.Sprintf("/tenants/:%s/streams", param.TenantId), streamsHandler.Get, apiv2.StreamList{})
+	v2.GET(fmt.Sprintf("/tenants/:%s/streams/:%s/lag", param.TenantId, param.StreamId), streamsHandler.GetLag,
+		apiv2.StreamLag{})
 
 	return 0, startFunc, nil
 }
 
+// startIndexTemplateInstall keeps the index templates in Elastic up to date. The server still starts when Elastic can't
+// be reached, because the templates are only needed when tenants are created, and they're installed in the background
+// as soon as it can be. The returned function stops retrying.
+func startIndexTemplateInstall(config config.Config, logger logrus.FieldLogger) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for !installIndexTemplates(config, logger) {
+			logger.Infof("Retrying to install the index templates in %v", indexTemplateRetryInterval)
+			select {
+			case <-done:
+				return
+			case <-time.After(indexTemplateRetryInterval):
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// installIndexTemplates installs the index templates and returns whether they're up to date
+func installIndexTemplates(config config.Config, logger logrus.FieldLogger) bool {
+	esClient, err := elastic.ClientFromConfig(config)
+	if err != nil {
+		logger.Errorf("ERROR INSTALLING INDEX TEMPLATES: %v", err)
+		return false
+	}
+	if _, err := elastic.InstallIndexTemplates(esClient, logger); err != nil {
+		logger.Errorf("ERROR INSTALLING INDEX TEMPLATES: %v", err)
+		return false
+	}
+
+	outdated, err := elastic.OutdatedTenantIndices(esClient)
+	if err != nil {
+		logger.Warnf("Unable to check the schema version of the tenant indices: %v", err)
+	} else if len(outdated) > 0 {
+		logger.Warnf("Tenant indices %v have an outdated schema, run '%s' to migrate them", outdated, migrateCommand)
+	}
+	return true
+}
+
+// newClientAuthTlsConfig returns the TLS configuration of a server that verifies client certificates, nil when client
+// certificates aren't accepted
+func newClientAuthTlsConfig(serverConfig config.Config) (*tls.Config, error) {
+	var clientAuth tls.ClientAuthType
+	switch serverConfig.TlsClientAuth {
+	case config.TlsClientAuthOptional:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case config.TlsClientAuthRequired:
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, nil
+	}
+
+	serverCert, err := tls.LoadX509KeyPair(serverConfig.TlsCertPath, serverConfig.TlsKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the server's TLS certificate: %w", err)
+	}
+	caCerts, err := ioutil.ReadFile(serverConfig.TlsClientCaPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the client CA certificates: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caCerts) {
+		return nil, fmt.Errorf("the client CA file %s has no PEM encoded certificates", serverConfig.TlsClientCaPath)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   clientAuth,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
 func logLvlInfoOrLess(logCfg *logwrapper.LogConfig) bool {
 	return logCfg.Level == logrus.InfoLevel || logCfg.Level == logrus.DebugLevel ||
 		logCfg.Level == logrus.TraceLevel
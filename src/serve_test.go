@@ -6,17 +6,32 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
+	"github.com/Alvearie/hri-mgmt-api/apiv2"
+	"github.com/Alvearie/hri-mgmt-api/common/config"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/Alvearie/hri-mgmt-api/openapi"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfigureMgmtServerErrors(t *testing.T) {
@@ -47,6 +62,36 @@ func TestConfigureMgmtServerErrors(t *testing.T) {
 			args:               []string{"--new-relic-license-key=notLongEnough"},
 			expectedError:      errors.New("ERROR CONFIGURING NEW RELIC: license length is not 40"),
 		},
+		{
+			name:               "Bad Topic Name Template",
+			expectedReturnCode: 1,
+			args:               []string{"--topic-name-template=ingest.{tenantId}.{topicType}"},
+			expectedError:      errors.New("Topic naming errors:\n\tThe topic name template must contain {streamId} exactly once, found 0"),
+		},
+		{
+			name:               "Bad Audit Log File",
+			expectedReturnCode: 1,
+			args:               []string{"--audit-log-file=./missing-dir/audit.log", "--audit-hmac-key=secret"},
+			expectedError:      errors.New("unable to open the audit log file: open ./missing-dir/audit.log: no such file or directory"),
+		},
+		{
+			name:               "Missing Policy File",
+			expectedReturnCode: 1,
+			args:               []string{"--policy-file=./missing-policy.yml"},
+			expectedError:      errors.New("unable to read the policy file: open ./missing-policy.yml: no such file or directory"),
+		},
+		{
+			name:               "Missing Certificate Identity File",
+			expectedReturnCode: 1,
+			args:               []string{"--tls-client-auth=optional", "--tls-client-ca-path=./client-ca.pem", "--cert-identity-file=./missing-identities.yml"},
+			expectedError:      errors.New("unable to read the certificate identity file: open ./missing-identities.yml: no such file or directory"),
+		},
+		{
+			name:               "Missing Client CA File",
+			expectedReturnCode: 1,
+			args:               []string{"--tls-client-auth=required", "--tls-client-ca-path=./missing-client-ca.pem"},
+			expectedError:      errors.New("unable to load the server's TLS certificate: open ./server-cert.pem: no such file or directory"),
+		},
 	}
 
 	for _, tc := range tests {
@@ -105,12 +150,26 @@ func TestMgmtServerRoutes(t *testing.T) {
 	routeTests := make([]routeTestType, 0)
 
 	// Healthcheck Routing
-	routeTests = append(routeTests, routeTestType{
-		name:                    "healthcheck",
-		method:                  http.MethodGet,
-		routePath:               "/hri/healthcheck",
-		expectedHandlerFilePath: "healthcheck/handler",
-	})
+	routeTests = append(routeTests, []routeTestType{
+		{
+			name:                    "ready",
+			method:                  http.MethodGet,
+			routePath:               "/ready",
+			expectedHandlerFilePath: "healthcheck/handler",
+		},
+		{
+			name:                    "healthcheck",
+			method:                  http.MethodGet,
+			routePath:               "/hri/healthcheck",
+			expectedHandlerFilePath: "healthcheck/handler",
+		},
+		{
+			name:                    "openapi document",
+			method:                  http.MethodGet,
+			routePath:               "/hri/openapi.json",
+			expectedHandlerFilePath: "openapi/spec",
+		},
+	}...)
 
 	// Tenants routing
 	tenantsHandlerPath := "tenants/handler"
@@ -148,6 +207,69 @@ func TestMgmtServerRoutes(t *testing.T) {
 				param.TenantId: "testTenant",
 			},
 		},
+		{
+			name:                    "tenants - put config",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/config",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "tenants - get config",
+			method:                  http.MethodGet,
+			routePath:               "/hri/tenants/testTenant/config",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "tenants - suspend",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/action/suspend",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "tenants - resume",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/action/resume",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "tenants - purge",
+			method:                  http.MethodPost,
+			routePath:               "/hri/tenants/testTenant/action/purge",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "tenants - export",
+			method:                  http.MethodGet,
+			routePath:               "/hri/tenants/testTenant/export",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "tenants - import",
+			method:                  http.MethodPost,
+			routePath:               "/hri/tenants/testTenant/import",
+			expectedHandlerFilePath: tenantsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
 	}...)
 
 	// Batches routing
@@ -223,6 +345,75 @@ func TestMgmtServerRoutes(t *testing.T) {
 		},
 	}...)
 
+	// Legal hold routing
+	legalHoldHandlerPath := "legalhold/handler"
+	routeTests = append(routeTests, []routeTestType{
+		{
+			name:                    "legal hold - hold batch",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/batches/testBatch/action/legalHold",
+			expectedHandlerFilePath: legalHoldHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+				param.BatchId:  "testBatch",
+			},
+		},
+		{
+			name:                    "legal hold - release batch",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/batches/testBatch/action/releaseLegalHold",
+			expectedHandlerFilePath: legalHoldHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+				param.BatchId:  "testBatch",
+			},
+		},
+		{
+			name:                    "legal hold - hold tenant",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/action/legalHold",
+			expectedHandlerFilePath: legalHoldHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+		{
+			name:                    "legal hold - release tenant",
+			method:                  http.MethodPut,
+			routePath:               "/hri/tenants/testTenant/action/releaseLegalHold",
+			expectedHandlerFilePath: legalHoldHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+			},
+		},
+	}...)
+
+	// API key routing
+	apiKeysHandlerPath := "apikeys/handler"
+	routeTests = append(routeTests, []routeTestType{
+		{
+			name:                    "api keys - create",
+			method:                  http.MethodPost,
+			routePath:               "/hri/apikeys",
+			expectedHandlerFilePath: apiKeysHandlerPath,
+		},
+		{
+			name:                    "api keys - get",
+			method:                  http.MethodGet,
+			routePath:               "/hri/apikeys",
+			expectedHandlerFilePath: apiKeysHandlerPath,
+		},
+		{
+			name:                    "api keys - revoke",
+			method:                  http.MethodPut,
+			routePath:               "/hri/apikeys/testKey/action/revoke",
+			expectedHandlerFilePath: apiKeysHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.ApiKeyId: "testKey",
+			},
+		},
+	}...)
+
 	// Streams routing
 	streamsHandlerPath := "streams/handler"
 	routeTests = append(routeTests, []routeTestType{
@@ -255,8 +446,32 @@ func TestMgmtServerRoutes(t *testing.T) {
 				param.StreamId: "testStream",
 			},
 		},
+		{
+			name:                    "streams - get lag",
+			method:                  http.MethodGet,
+			routePath:               "/hri/tenants/testTenant/streams/testStream/lag",
+			expectedHandlerFilePath: streamsHandlerPath,
+			expectedPathParameters: map[string]string{
+				param.TenantId: "testTenant",
+				param.StreamId: "testStream",
+			},
+		},
 	}...)
 
+	// v2 routing, the same routes served by the v1 handlers through the v2 adapter
+	for _, tc := range routeTests {
+		if !strings.HasPrefix(tc.routePath, "/hri/tenants") && !strings.HasPrefix(tc.routePath, "/hri/apikeys") {
+			continue
+		}
+		tc.name = "v2 " + tc.name
+		tc.routePath = apiv2.Prefix + strings.TrimPrefix(tc.routePath, "/hri")
+		if strings.HasSuffix(tc.routePath, "/action/sendComplete") {
+			// the request body is checked before the handler is called
+			tc.expectedHandlerFilePath = "apiv2/requests"
+		}
+		routeTests = append(routeTests, tc)
+	}
+
 	for _, tc := range routeTests {
 		t.Run(tc.name, func(t *testing.T) {
 			context = e.NewContext(nil, nil)
@@ -269,8 +484,113 @@ func TestMgmtServerRoutes(t *testing.T) {
 		})
 	}
 
-	// The number of routes (including ready/liveness endpoint) should match the number in the echo struct
-	assert.Equal(t, len(routeTests)+1, len(e.Routes()))
+	// The number of routes (including the ready/liveness and metrics endpoints) should match the number in the echo struct
+	assert.Equal(t, len(routeTests)+2, len(e.Routes()))
+}
+
+func TestMetricsRoute(t *testing.T) {
+	configPath := test.FindConfigPath(t)
+
+	e := echo.New()
+	configureMgmtServer(e, []string{"--config-path=" + configPath})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/alive", nil))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `hri_http_requests_total{method="GET",route="/alive",status="200",tenant=""}`)
+
+	// served on the admin port instead
+	e = echo.New()
+	configureMgmtServer(e, []string{"--config-path=" + configPath, "--metrics-port=9090"})
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestOpenApiDocumentsEveryRoute(t *testing.T) {
+	configPath := test.FindConfigPath(t)
+	e := echo.New()
+	configureMgmtServer(e, []string{"--config-path=" + configPath})
+	spec := openapi.Spec()
+
+	documented := map[string]bool{}
+	for _, route := range e.Routes() {
+		path := openapi.PathFromRoute(route.Path)
+		assert.NotNil(t, spec.Operation(route.Method, route.Path), "%s %s isn't in the OpenAPI document", route.Method, path)
+		documented[route.Method+" "+path] = true
+	}
+
+	for path, pathItem := range spec.Paths {
+		for method := range pathItem {
+			assert.True(t, documented[strings.ToUpper(method)+" "+path],
+				"%s %s is in the OpenAPI document, but isn't a route of the server", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestOpenApiRoute(t *testing.T) {
+	configPath := test.FindConfigPath(t)
+	e := echo.New()
+	configureMgmtServer(e, []string{"--config-path=" + configPath})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hri/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), `"openapi": "3.0.3"`)
+}
+
+func TestApiV2Errors(t *testing.T) {
+	configPath := test.FindConfigPath(t)
+	e := echo.New()
+	configureMgmtServer(e, []string{"--config-path=" + configPath})
+
+	tests := []struct {
+		name                string
+		method              string
+		path                string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "v1 not found",
+			method:              http.MethodGet,
+			path:                "/hri/missing",
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: echo.MIMEApplicationJSONCharsetUTF8,
+			expectedBody:        `{"message":"Not Found"}`,
+		},
+		{
+			name:                "v2 not found",
+			method:              http.MethodGet,
+			path:                "/hri/v2/missing",
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Not Found","status":404,"detail":"Not Found","instance":"/hri/v2/missing","errorEventId":"testRequestId"}`,
+		},
+		{
+			name:                "v2 method not allowed",
+			method:              http.MethodPatch,
+			path:                "/hri/v2/tenants",
+			expectedCode:        http.StatusMethodNotAllowed,
+			expectedContentType: response.MIMEApplicationProblemJSON,
+			expectedBody:        `{"type":"about:blank","title":"Method Not Allowed","status":405,"detail":"Method Not Allowed","instance":"/hri/v2/tenants","errorEventId":"testRequestId"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, tc.path, nil)
+			request.Header.Set(echo.HeaderXRequestID, "testRequestId")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, request)
+			assert.Equal(t, tc.expectedCode, rec.Code)
+			assert.Equal(t, tc.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+			assert.JSONEq(t, tc.expectedBody, rec.Body.String())
+		})
+	}
 }
 
 func assertRouteHandlerIsValid(t *testing.T, context echo.Context, path string) {
@@ -294,3 +614,73 @@ func assertRouteHandlerIsValid(t *testing.T, context echo.Context, path string)
 	}()
 	err = context.Handler()(context)
 }
+
+func TestNewClientAuthTlsConfig(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hri-test-ca"},
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDer, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeFile := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	certPath := writeFile("cert.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}))
+	keyPath := writeFile("key.pem", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	emptyPath := writeFile("empty.pem", []byte("no certificates"))
+
+	serverConfig := config.Config{TlsEnabled: true, TlsCertPath: certPath, TlsKeyPath: keyPath, TlsClientCaPath: certPath}
+
+	tlsConfig, err := newClientAuthTlsConfig(serverConfig)
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	serverConfig.TlsClientAuth = config.TlsClientAuthOptional
+	tlsConfig, err = newClientAuthTlsConfig(serverConfig)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+		assert.Len(t, tlsConfig.Certificates, 1)
+		assert.Len(t, tlsConfig.ClientCAs.Subjects(), 1)
+	}
+
+	serverConfig.TlsClientAuth = config.TlsClientAuthRequired
+	tlsConfig, err = newClientAuthTlsConfig(serverConfig)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	}
+
+	serverConfig.TlsClientCaPath = emptyPath
+	_, err = newClientAuthTlsConfig(serverConfig)
+	assert.EqualError(t, err, "the client CA file "+emptyPath+" has no PEM encoded certificates")
+}
+
+func TestStartIndexTemplateInstallStops(t *testing.T) {
+	logger := logwrapper.GetMyLogger("", "main/TestStartIndexTemplateInstallStops")
+	// nothing listens on the port, so the install keeps failing until it's stopped
+	stop := startIndexTemplateInstall(config.Config{ElasticUrl: "http://127.0.0.1:1"}, logger)
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the index template install didn't stop")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+// shutdownTask is a step of the shutdown, run after the requests in progress finished
+type shutdownTask struct {
+	name string
+	run  func(context.Context) error
+}
+
+// shutdownOnSignal waits for a signal, then stops the server from accepting requests and waits up to the timeout for
+// the requests in progress, e.g. a sendComplete between its Elastic update and Kafka notification. The tasks are then
+// run in order, each with the same timeout. The returned channel is closed when the shutdown is done.
+func shutdownOnSignal(signals <-chan os.Signal, e *echo.Echo, timeout time.Duration, logger logrus.FieldLogger,
+	tasks ...shutdownTask) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		signal := <-signals
+		logger.Infof("Received %v, shutting down", signal)
+
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), timeout)
+		defer cancelDrain()
+		if err := e.Shutdown(drainCtx); err != nil {
+			logger.Errorf("ERROR DRAINING THE REQUESTS IN PROGRESS: %v\n", err)
+		}
+
+		for _, task := range tasks {
+			taskCtx, cancelTask := context.WithTimeout(context.Background(), timeout)
+			if err := task.run(taskCtx); err != nil {
+				logger.Errorf("ERROR STOPPING THE %s: %v\n", task.name, err)
+			}
+			cancelTask()
+		}
+		logger.Infoln("HRI serve Shutdown complete")
+	}()
+
+	return done
+}
@@ -0,0 +1,99 @@
+/*
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownOnSignal(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	logger := logwrapper.GetMyLogger("", "SERVE")
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Listener = listener
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e.GET("/slow", func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.String(http.StatusOK, "done")
+	})
+
+	var ran []string
+	signals := make(chan os.Signal, 1)
+	done := shutdownOnSignal(signals, e, 5*time.Second, logger,
+		shutdownTask{name: "FIRST", run: func(context.Context) error {
+			ran = append(ran, "first")
+			return nil
+		}},
+		shutdownTask{name: "SECOND", run: func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline)
+			ran = append(ran, "second")
+			return errors.New("already stopped")
+		}},
+		shutdownTask{name: "THIRD", run: func(context.Context) error {
+			ran = append(ran, "third")
+			return nil
+		}},
+	)
+
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- e.Start("") }()
+
+	// a request that is in progress when the signal arrives
+	responses := make(chan string, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		responses <- string(body)
+	}()
+	<-started
+
+	signals <- syscall.SIGTERM
+	assert.Equal(t, http.ErrServerClosed, <-serverErr)
+	select {
+	case <-done:
+		t.Fatal("the shutdown didn't wait for the request in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Empty(t, ran)
+
+	close(release)
+	assert.Equal(t, "done", <-responses)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shutdown didn't finish")
+	}
+	// a task's error doesn't stop the next ones
+	assert.Equal(t, []string{"first", "second", "third"}, ran)
+}
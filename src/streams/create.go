@@ -24,6 +24,7 @@ const (
 )
 
 func Create(
+	ctx context.Context,
 	request model.CreateStreamsRequest,
 	tenantId string,
 	streamId string,
@@ -32,7 +33,7 @@ func Create(
 	service eventstreams.Service) ([]string, int, error) {
 
 	prefix := "streams/create"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
 	logger.Debugln("Start Streams Create")
 
 	inTopicName, notificationTopicName, outTopicName, invalidTopicName := eventstreams.CreateTopicNames(
@@ -57,7 +58,7 @@ func Create(
 	createdTopics := make([]string, 0, 4)
 
 	// create the input and notification topics for the given tenant and stream pairing
-	_, inResponse, inErr := service.CreateTopic(context.Background(), inTopicRequest)
+	_, inResponse, inErr := service.CreateTopic(ctx, inTopicRequest)
 	if inErr != nil {
 		logger.Errorf("Unable to create new topic [%s]. %s", inTopicName, inErr.Error())
 		responseCode, errorMessage := getResponseCodeAndErrorMessage(inResponse, service.HandleModelError(inErr))
@@ -65,7 +66,7 @@ func Create(
 	}
 	createdTopics = append(createdTopics, inTopicName)
 
-	_, notificationResponse, notificationErr := service.CreateTopic(context.Background(), notificationTopicRequest)
+	_, notificationResponse, notificationErr := service.CreateTopic(ctx, notificationTopicRequest)
 	if notificationErr != nil {
 		logger.Errorf("Unable to create new topic [%s]. %s", notificationTopicName, notificationErr.Error())
 		responseCode, errorMessage := getResponseCodeAndErrorMessage(notificationResponse, service.HandleModelError(notificationErr))
@@ -88,7 +89,7 @@ func Create(
 			Configs:        topicConfigs,
 		}
 
-		_, outResponse, outErr := service.CreateTopic(context.Background(), outTopicRequest)
+		_, outResponse, outErr := service.CreateTopic(ctx, outTopicRequest)
 		if outErr != nil {
 			logger.Errorf("Unable to create new topic [%s]. %s", outTopicName, outErr.Error())
 			responseCode, errorMessage := getResponseCodeAndErrorMessage(outResponse, service.HandleModelError(outErr))
@@ -96,7 +97,7 @@ func Create(
 		}
 		createdTopics = append(createdTopics, outTopicName)
 
-		_, invalidResponse, invalidErr := service.CreateTopic(context.Background(), invalidTopicRequest)
+		_, invalidResponse, invalidErr := service.CreateTopic(ctx, invalidTopicRequest)
 		if invalidErr != nil {
 			logger.Errorf("Unable to create new topic [%s]. %s", invalidTopicName, invalidErr.Error())
 			responseCode, errorMessage := getResponseCodeAndErrorMessage(invalidResponse, service.HandleModelError(invalidErr))
@@ -162,7 +163,7 @@ func getResponseCodeAndErrorMessage(resp *http.Response, err *es.ModelError) (in
 	//EventStreams Admin API gives us status 403 when provided bearer token is unauthorized
 	//and status 401 when Authorization isn't provided or is nil
 	if resp.StatusCode == http.StatusForbidden {
-		return http.StatusUnauthorized, eventstreams.UnauthorizedMsg
+		return http.StatusForbidden, eventstreams.UnauthorizedMsg
 	} else if resp.StatusCode == http.StatusUnauthorized {
 		return http.StatusUnauthorized, eventstreams.MissingHeaderMsg
 	} else if resp.StatusCode == http.StatusUnprocessableEntity && err.ErrorCode == topicAlreadyExists {
@@ -102,7 +102,7 @@ func TestCreate(t *testing.T) {
 			modelInError:       &es.ModelError{},
 			mockResponse:       &StatusForbidden,
 			expectedError:      fmt.Errorf(eventstreams.UnauthorizedMsg),
-			expectedReturnCode: http.StatusUnauthorized,
+			expectedReturnCode: http.StatusForbidden,
 			expectedTopic:      baseTopicName,
 		},
 		{
@@ -137,7 +137,7 @@ func TestCreate(t *testing.T) {
 			expectedError:          fmt.Errorf(topicAlreadyExistsMessage),
 			expectedReturnCode:     http.StatusConflict,
 			expectedTopic:          baseTopicName,
-			expectedTopics:         []string{eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.InSuffix},
+			expectedTopics:         []string{"ingest." + tenantId + "." + streamId1 + ".in"},
 		},
 		{
 			name:               "out-topic-already-exists",
@@ -151,8 +151,8 @@ func TestCreate(t *testing.T) {
 			expectedReturnCode: http.StatusConflict,
 			expectedTopic:      baseTopicName,
 			expectedTopics: []string{
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.InSuffix,
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.NotificationSuffix,
+				"ingest." + tenantId + "." + streamId1 + ".in",
+				"ingest." + tenantId + "." + streamId1 + ".notification",
 			},
 		},
 		{
@@ -167,9 +167,9 @@ func TestCreate(t *testing.T) {
 			expectedReturnCode: http.StatusConflict,
 			expectedTopic:      baseTopicName,
 			expectedTopics: []string{
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.InSuffix,
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.NotificationSuffix,
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.OutSuffix,
+				"ingest." + tenantId + "." + streamId1 + ".in",
+				"ingest." + tenantId + "." + streamId1 + ".notification",
+				"ingest." + tenantId + "." + streamId1 + ".out",
 			},
 		},
 		{
@@ -203,10 +203,10 @@ func TestCreate(t *testing.T) {
 			expectedReturnCode: http.StatusCreated,
 			expectedTopic:      baseTopicName,
 			expectedTopics: []string{
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.InSuffix,
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.NotificationSuffix,
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.OutSuffix,
-				eventstreams.TopicPrefix + tenantId + "." + streamId1 + eventstreams.InvalidSuffix,
+				"ingest." + tenantId + "." + streamId1 + ".in",
+				"ingest." + tenantId + "." + streamId1 + ".notification",
+				"ingest." + tenantId + "." + streamId1 + ".out",
+				"ingest." + tenantId + "." + streamId1 + ".invalid",
 			},
 		},
 	}
@@ -235,25 +235,25 @@ func TestCreate(t *testing.T) {
 
 		mockService.
 			EXPECT().
-			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, eventstreams.InSuffix)).
+			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, ".in")).
 			Return(nil, tc.mockResponse, mockInErr).
 			MaxTimes(1)
 
 		mockService.
 			EXPECT().
-			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, eventstreams.NotificationSuffix)).
+			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, ".notification")).
 			Return(nil, tc.mockResponse, mockNotificationErr).
 			MaxTimes(1)
 
 		mockService.
 			EXPECT().
-			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, eventstreams.OutSuffix)).
+			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, ".out")).
 			Return(nil, tc.mockResponse, mockOutErr).
 			MaxTimes(1)
 
 		mockService.
 			EXPECT().
-			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, eventstreams.InvalidSuffix)).
+			CreateTopic(context.Background(), getTestTopicRequest(tc.expectedTopic, ".invalid")).
 			Return(nil, tc.mockResponse, mockInvalidErr).
 			MaxTimes(1)
 
@@ -282,7 +282,7 @@ func TestCreate(t *testing.T) {
 			MaxTimes(1)
 
 		t.Run(tc.name, func(t *testing.T) {
-			topicsCreated, returnCode, err := Create(tc.streamsRequest, tc.tenantId, tc.streamId, tc.validationEnabled, requestId, mockService)
+			topicsCreated, returnCode, err := Create(context.Background(), tc.streamsRequest, tc.tenantId, tc.streamId, tc.validationEnabled, requestId, mockService)
 
 			if tc.expectedTopics == nil {
 				tc.expectedTopics = make([]string, 0)
@@ -349,7 +349,7 @@ func TestSetUpTopicConfigs(t *testing.T) {
 
 func getTestTopicRequest(streamName string, topicSuffix string) es.TopicCreateRequest {
 	return es.TopicCreateRequest{
-		Name:           eventstreams.TopicPrefix + streamName + topicSuffix,
+		Name:           "ingest." + streamName + topicSuffix,
 		PartitionCount: numPartitions,
 		Configs: []es.ConfigCreate{{
 			Name:  "retention.ms",
@@ -17,7 +17,7 @@ import (
 
 const deleteErrMessageTemplate = "Unable to delete topic \"%s\": %s"
 
-func Delete(requestId string, topics []string, service eventstreams.Service) (int, error) {
+func Delete(ctx context.Context, requestId string, topics []string, service eventstreams.Service) (int, error) {
 	prefix := "streams/Delete"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Debugln("Start Streams Delete")
@@ -27,7 +27,7 @@ func Delete(requestId string, topics []string, service eventstreams.Service) (in
 
 	for _, topic := range topics {
 		logger.Debugln("Delete Stream: " + topic)
-		_, deleteResp, err := service.DeleteTopic(context.Background(), topic)
+		_, deleteResp, err := service.DeleteTopic(ctx, topic)
 		if err != nil {
 			deleteReturnCode, deleteErrMessage := getDeleteResponseError(deleteResp, service.HandleModelError(err))
 			if returnCode == http.StatusOK {
@@ -53,7 +53,7 @@ func getDeleteResponseError(resp *http.Response, err *es.ModelError) (int, strin
 	//EventStreams Admin API gives us status 403 when provided bearer token is unauthorized
 	//and status 401 when Authorization isn't provided or is nil
 	if resp.StatusCode == http.StatusForbidden {
-		return http.StatusUnauthorized, eventstreams.UnauthorizedMsg
+		return http.StatusForbidden, eventstreams.UnauthorizedMsg
 	} else if resp.StatusCode == http.StatusUnauthorized {
 		return http.StatusUnauthorized, eventstreams.MissingHeaderMsg
 	} else if resp.StatusCode == http.StatusNotFound {
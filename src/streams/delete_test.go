@@ -48,7 +48,7 @@ func TestDelete(t *testing.T) {
 			topics:              []string{"in"},
 			deleteErrors:        map[string]*es.ModelError{"in": {}},
 			mockDeleteResponses: map[string]*http.Response{"in": &StatusForbidden},
-			expectedReturnCode:  http.StatusUnauthorized,
+			expectedReturnCode:  http.StatusForbidden,
 			expectedError:       fmt.Errorf(`Unable to delete topic "in": ` + eventstreams.UnauthorizedMsg),
 		},
 		{
@@ -124,7 +124,7 @@ func TestDelete(t *testing.T) {
 			}
 		}
 
-		actualCode, actualErrMsg := Delete(requestId, tc.topics, mockService)
+		actualCode, actualErrMsg := Delete(context.Background(), requestId, tc.topics, mockService)
 
 		t.Run(tc.name, func(t *testing.T) {
 			if actualCode != tc.expectedReturnCode || !reflect.DeepEqual(tc.expectedError, actualErrMsg) {
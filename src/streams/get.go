@@ -10,24 +10,25 @@ import (
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
 	"net/http"
-	"strings"
+	"strconv"
 )
 
 const msgStreamsNotFound = "Unable to get stream names for tenant [%s]. %s"
 
 func Get(
-	requestId string, tenantId string,
+	ctx context.Context, requestId string, tenantId string,
 	service eventstreams.Service) (int, interface{}) {
 	prefix := "streams/Get"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
 	logger.Debugln("List streams for: " + tenantId)
 
 	// get all topics for the kafka connection, then take only the streams for the given tenantId
-	topicDetails, resp, err := service.ListTopics(context.Background(), &es.ListTopicsOpts{})
+	topicDetails, resp, err := service.ListTopics(ctx, &es.ListTopicsOpts{})
 	if err != nil {
 		msg := fmt.Sprintf(msgStreamsNotFound, tenantId, err.Error())
 		logger.Errorln(msg)
@@ -45,7 +46,7 @@ func getResponseError(requestId string, resp *http.Response, err error) (int, *r
 	//EventStreams Admin API gives us status 403 when provided bearer token is unauthorized
 	//and status 401 when Authorization isn't provided or is nil
 	if resp.StatusCode == http.StatusForbidden {
-		returnCode = http.StatusUnauthorized
+		returnCode = http.StatusForbidden
 		returnError = response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg)
 	} else if resp.StatusCode == http.StatusUnauthorized {
 		returnCode = http.StatusUnauthorized
@@ -55,31 +56,102 @@ func getResponseError(requestId string, resp *http.Response, err error) (int, *r
 }
 
 func GetStreamNames(topics []es.TopicDetail, tenantId string) []map[string]interface{} {
+	naming := eventstreams.GetTopicNaming()
 	streamNames := []map[string]interface{}{}
 	seenStreamIds := make(map[string]bool)
 	for _, topic := range topics {
-		topicName := topic.Name
-		splits := strings.Split(topicName, ".")
-		if len(splits) >= 4 && validTopicName(topicName) && splits[1] == tenantId {
-			//streamId is between tenantId and suffix, and it includes the dataIntegratorId and optional qualifier (delimited by '.')
-			streamId := strings.TrimPrefix(topicName, eventstreams.TopicPrefix+tenantId+".")
-			streamId = strings.TrimSuffix(streamId, eventstreams.InSuffix)
-			streamId = strings.TrimSuffix(streamId, eventstreams.NotificationSuffix)
-			streamId = strings.TrimSuffix(streamId, eventstreams.OutSuffix)
-			streamId = strings.TrimSuffix(streamId, eventstreams.InvalidSuffix)
-
-			//take unique stream names, we don't want duplicates due to a stream's multiple topics (in/notification)
-			if _, seen := seenStreamIds[streamId]; !seen {
-				streamNames = append(streamNames, map[string]interface{}{param.StreamId: streamId})
-				seenStreamIds[streamId] = true
-			}
+		//streamId includes the dataIntegratorId and optional qualifier (delimited by '.')
+		streamId, _, ok := naming.ParseTopicName(topic.Name, tenantId)
+		if !ok {
+			continue
+		}
+
+		//take unique stream names, we don't want duplicates due to a stream's multiple topics (in/notification)
+		if _, seen := seenStreamIds[streamId]; !seen {
+			streamNames = append(streamNames, map[string]interface{}{param.StreamId: streamId})
+			seenStreamIds[streamId] = true
 		}
 	}
 	return streamNames
 }
 
-func validTopicName(topicName string) bool {
-	return strings.HasPrefix(topicName, eventstreams.TopicPrefix) &&
-		(strings.HasSuffix(topicName, eventstreams.InSuffix) || strings.HasSuffix(topicName, eventstreams.NotificationSuffix) ||
-			strings.HasSuffix(topicName, eventstreams.OutSuffix) || strings.HasSuffix(topicName, eventstreams.InvalidSuffix))
+// GetTenantTopics returns the names of all of the tenant's stream topics
+func GetTenantTopics(ctx context.Context, requestId string, tenantId string, service eventstreams.Service) ([]string, int, *response.ErrorDetail) {
+	prefix := "streams/GetTenantTopics"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
+
+	topicDetails, resp, err := service.ListTopics(ctx, &es.ListTopicsOpts{})
+	if err != nil {
+		msg := fmt.Sprintf(msgStreamsNotFound, tenantId, err.Error())
+		logger.Errorln(msg)
+		code, errDetail := getResponseError(requestId, resp, err)
+		return nil, code, errDetail
+	}
+
+	naming := eventstreams.GetTopicNaming()
+	topics := []string{}
+	for _, topic := range topicDetails {
+		if _, _, ok := naming.ParseTopicName(topic.Name, tenantId); ok {
+			topics = append(topics, topic.Name)
+		}
+	}
+	return topics, http.StatusOK, nil
+}
+
+// GetTenantStreams returns the settings of each of the tenant's streams. They are taken from the stream's input topic,
+// the other topics are created from the same settings.
+func GetTenantStreams(ctx context.Context, requestId string, tenantId string, service eventstreams.Service) ([]model.StreamDefinition, int, *response.ErrorDetail) {
+	prefix := "streams/GetTenantStreams"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
+
+	topicDetails, resp, err := service.ListTopics(ctx, &es.ListTopicsOpts{})
+	if err != nil {
+		msg := fmt.Sprintf(msgStreamsNotFound, tenantId, err.Error())
+		logger.Errorln(msg)
+		code, errDetail := getResponseError(requestId, resp, err)
+		return nil, code, errDetail
+	}
+
+	naming := eventstreams.GetTopicNaming()
+	definitions := []model.StreamDefinition{}
+	for _, topic := range topicDetails {
+		if streamId, topicType, ok := naming.ParseTopicName(topic.Name, tenantId); ok && topicType == eventstreams.InTopic {
+			definitions = append(definitions, streamDefinition(streamId, topic))
+		}
+	}
+	return definitions, http.StatusOK, nil
+}
+
+func streamDefinition(streamId string, topic es.TopicDetail) model.StreamDefinition {
+	numPartitions := int64(topic.Partitions)
+	definition := model.StreamDefinition{
+		StreamId:          streamId,
+		NumPartitions:     &numPartitions,
+		RetentionMs:       configValue(topic.Configs.RetentionMs),
+		RetentionBytes:    configValue(topic.Configs.RetentionBytes),
+		SegmentMs:         configValue(topic.Configs.SegmentMs),
+		SegmentBytes:      configValue(topic.Configs.SegmentBytes),
+		SegmentIndexBytes: configValue(topic.Configs.SegmentIndexBytes),
+	}
+	if definition.RetentionMs == nil && topic.RetentionMs > 0 {
+		retentionMs := int(topic.RetentionMs)
+		definition.RetentionMs = &retentionMs
+	}
+	cleanupPolicy := topic.Configs.CleanupPolicy
+	if cleanupPolicy == "" {
+		cleanupPolicy = topic.CleanupPolicy
+	}
+	if cleanupPolicy != "" {
+		definition.CleanupPolicy = &cleanupPolicy
+	}
+	return definition
+}
+
+// configValue returns nil when the topic config isn't set or isn't a number
+func configValue(value string) *int {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return nil
+	}
+	return &number
 }
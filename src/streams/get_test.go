@@ -6,10 +6,12 @@
 package streams
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/Alvearie/hri-mgmt-api/common/test"
@@ -66,7 +68,7 @@ func TestGet(t *testing.T) {
 			tenantId:     validTenant1,
 			mockError:    errors.New(forbiddenMessage),
 			mockResponse: &StatusForbidden,
-			expectedCode: http.StatusUnauthorized,
+			expectedCode: http.StatusForbidden,
 			expectedBody: response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg),
 		},
 		{
@@ -100,10 +102,10 @@ func TestGet(t *testing.T) {
 
 		//Mock return topics for all tenants, GetById should return only the unique stream names for the specified tenant
 		topicDetails := []es.TopicDetail{
-			{Name: eventstreams.TopicPrefix + validTenant1WithQualifier + eventstreams.InSuffix},
-			{Name: eventstreams.TopicPrefix + validTenant1WithQualifier + eventstreams.NotificationSuffix},
-			{Name: eventstreams.TopicPrefix + validTenant1NoQualifier + eventstreams.InSuffix},
-			{Name: eventstreams.TopicPrefix + validTenant1NoQualifier + eventstreams.NotificationSuffix},
+			{Name: "ingest." + validTenant1WithQualifier + ".in"},
+			{Name: "ingest." + validTenant1WithQualifier + ".notification"},
+			{Name: "ingest." + validTenant1NoQualifier + ".in"},
+			{Name: "ingest." + validTenant1NoQualifier + ".notification"},
 		}
 		mockService.
 			EXPECT().
@@ -112,7 +114,7 @@ func TestGet(t *testing.T) {
 			MaxTimes(1)
 
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := Get(requestId, tc.tenantId, mockService)
+			actualCode, actualBody := Get(context.Background(), requestId, tc.tenantId, mockService)
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
 				t.Errorf("Streams-Get() \n actual: %v,%v\n expected: %v,%v",
 					actualCode, actualBody, tc.expectedCode, tc.expectedBody)
@@ -149,22 +151,22 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "with-optional-qualifier",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.InvalidSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".in"},
+				{Name: "ingest." + tenant1WithQualifier + ".notification"},
+				{Name: "ingest." + tenant1WithQualifier + ".out"},
+				{Name: "ingest." + tenant1WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant1NoQualifier + ".in"},
+				{Name: "ingest." + tenant1NoQualifier + ".notification"},
+				{Name: "ingest." + tenant1NoQualifier + ".out"},
+				{Name: "ingest." + tenant1NoQualifier + ".invalid"},
+				{Name: "ingest." + tenant2WithQualifier + ".in"},
+				{Name: "ingest." + tenant2WithQualifier + ".notification"},
+				{Name: "ingest." + tenant2WithQualifier + ".out"},
+				{Name: "ingest." + tenant2WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant2NoQualifier + ".in"},
+				{Name: "ingest." + tenant2NoQualifier + ".notification"},
+				{Name: "ingest." + tenant2NoQualifier + ".out"},
+				{Name: "ingest." + tenant2NoQualifier + ".invalid"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{
@@ -175,10 +177,10 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "with-optional-qualifier-in-only",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.InSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".in"},
+				{Name: "ingest." + tenant1NoQualifier + ".in"},
+				{Name: "ingest." + tenant2WithQualifier + ".in"},
+				{Name: "ingest." + tenant2NoQualifier + ".in"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{
@@ -189,10 +191,10 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "with-optional-qualifier-out-only",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.OutSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".out"},
+				{Name: "ingest." + tenant1NoQualifier + ".out"},
+				{Name: "ingest." + tenant2WithQualifier + ".out"},
+				{Name: "ingest." + tenant2NoQualifier + ".out"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{
@@ -203,10 +205,10 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "with-optional-qualifier-invalid-only",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.InvalidSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant1NoQualifier + ".invalid"},
+				{Name: "ingest." + tenant2WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant2NoQualifier + ".invalid"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{
@@ -217,10 +219,10 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "with-optional-qualifier-notification-only",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.NotificationSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".notification"},
+				{Name: "ingest." + tenant1NoQualifier + ".notification"},
+				{Name: "ingest." + tenant2WithQualifier + ".notification"},
+				{Name: "ingest." + tenant2NoQualifier + ".notification"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{
@@ -232,18 +234,18 @@ func TestGetStreamNames(t *testing.T) {
 			//"tenant" and "tenant1" should be treated uniquely
 			name: "similar-tenant-names",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.InvalidSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".in"},
+				{Name: "ingest." + tenant1WithQualifier + ".notification"},
+				{Name: "ingest." + tenant1WithQualifier + ".out"},
+				{Name: "ingest." + tenant1WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant1NoQualifier + ".in"},
+				{Name: "ingest." + tenant1NoQualifier + ".notification"},
+				{Name: "ingest." + tenant1NoQualifier + ".out"},
+				{Name: "ingest." + tenant1NoQualifier + ".invalid"},
+				{Name: "ingest." + tenant0WithQualifier + ".in"},
+				{Name: "ingest." + tenant0WithQualifier + ".notification"},
+				{Name: "ingest." + tenant0WithQualifier + ".out"},
+				{Name: "ingest." + tenant0WithQualifier + ".invalid"},
 			},
 			tenantId: tenantId0,
 			expected: []map[string]interface{}{{param.StreamId: streamId}},
@@ -251,14 +253,14 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "qualifier-with-extra-period",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1ExtraPeriod + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1ExtraPeriod + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1ExtraPeriod + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1ExtraPeriod + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant0WithQualifier + eventstreams.InvalidSuffix},
+				{Name: "ingest." + tenant1ExtraPeriod + ".in"},
+				{Name: "ingest." + tenant1ExtraPeriod + ".notification"},
+				{Name: "ingest." + tenant1ExtraPeriod + ".out"},
+				{Name: "ingest." + tenant1ExtraPeriod + ".invalid"},
+				{Name: "ingest." + tenant0WithQualifier + ".in"},
+				{Name: "ingest." + tenant0WithQualifier + ".notification"},
+				{Name: "ingest." + tenant0WithQualifier + ".out"},
+				{Name: "ingest." + tenant0WithQualifier + ".invalid"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{{param.StreamId: streamIdExtraPeriod}},
@@ -266,22 +268,22 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "tenant-not-found",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant2NoQualifier + eventstreams.InvalidSuffix},
+				{Name: "ingest." + tenant1WithQualifier + ".in"},
+				{Name: "ingest." + tenant1WithQualifier + ".notification"},
+				{Name: "ingest." + tenant1WithQualifier + ".out"},
+				{Name: "ingest." + tenant1WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant1NoQualifier + ".in"},
+				{Name: "ingest." + tenant1NoQualifier + ".notification"},
+				{Name: "ingest." + tenant1NoQualifier + ".out"},
+				{Name: "ingest." + tenant1NoQualifier + ".invalid"},
+				{Name: "ingest." + tenant2WithQualifier + ".in"},
+				{Name: "ingest." + tenant2WithQualifier + ".notification"},
+				{Name: "ingest." + tenant2WithQualifier + ".out"},
+				{Name: "ingest." + tenant2WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant2NoQualifier + ".in"},
+				{Name: "ingest." + tenant2NoQualifier + ".notification"},
+				{Name: "ingest." + tenant2NoQualifier + ".out"},
+				{Name: "ingest." + tenant2NoQualifier + ".invalid"},
 			},
 			tenantId: tenantId0,
 			expected: []map[string]interface{}{},
@@ -289,18 +291,18 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "ignore-invalid-prefix",
 			topics: []es.TopicDetail{
-				{Name: tenant1WithQualifier + eventstreams.InSuffix},
-				{Name: tenant1WithQualifier + eventstreams.NotificationSuffix},
-				{Name: tenant1WithQualifier + eventstreams.OutSuffix},
-				{Name: tenant1WithQualifier + eventstreams.InvalidSuffix},
-				{Name: "bad-prefix" + tenant1WithQualifier + eventstreams.InSuffix},
-				{Name: "bad-prefix" + tenant1WithQualifier + eventstreams.NotificationSuffix},
-				{Name: "bad-prefix" + tenant1WithQualifier + eventstreams.OutSuffix},
-				{Name: "bad-prefix" + tenant1WithQualifier + eventstreams.InvalidSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InvalidSuffix},
+				{Name: tenant1WithQualifier + ".in"},
+				{Name: tenant1WithQualifier + ".notification"},
+				{Name: tenant1WithQualifier + ".out"},
+				{Name: tenant1WithQualifier + ".invalid"},
+				{Name: "bad-prefix" + tenant1WithQualifier + ".in"},
+				{Name: "bad-prefix" + tenant1WithQualifier + ".notification"},
+				{Name: "bad-prefix" + tenant1WithQualifier + ".out"},
+				{Name: "bad-prefix" + tenant1WithQualifier + ".invalid"},
+				{Name: "ingest." + tenant1NoQualifier + ".in"},
+				{Name: "ingest." + tenant1NoQualifier + ".notification"},
+				{Name: "ingest." + tenant1NoQualifier + ".out"},
+				{Name: "ingest." + tenant1NoQualifier + ".invalid"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{{param.StreamId: streamIdNoQualifier}},
@@ -308,14 +310,14 @@ func TestGetStreamNames(t *testing.T) {
 		{
 			name: "ignore-invalid-suffix",
 			topics: []es.TopicDetail{
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + "bad-suffix"},
-				{Name: eventstreams.TopicPrefix + tenant1WithQualifier + "bad-suffix"},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.NotificationSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.OutSuffix},
-				{Name: eventstreams.TopicPrefix + tenant1NoQualifier + eventstreams.InvalidSuffix},
+				{Name: "ingest." + tenant1WithQualifier},
+				{Name: "ingest." + tenant1WithQualifier},
+				{Name: "ingest." + tenant1WithQualifier + "bad-suffix"},
+				{Name: "ingest." + tenant1WithQualifier + "bad-suffix"},
+				{Name: "ingest." + tenant1NoQualifier + ".in"},
+				{Name: "ingest." + tenant1NoQualifier + ".notification"},
+				{Name: "ingest." + tenant1NoQualifier + ".out"},
+				{Name: "ingest." + tenant1NoQualifier + ".invalid"},
 			},
 			tenantId: tenantId1,
 			expected: []map[string]interface{}{{param.StreamId: streamIdNoQualifier}},
@@ -330,3 +332,131 @@ func TestGetStreamNames(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTenantTopics(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	var requestId = "reqYq3Ff9pL1"
+
+	topicDetails := []es.TopicDetail{
+		{Name: "ingest." + tenant1WithQualifier + ".in"},
+		{Name: "ingest." + tenant1NoQualifier + ".out"},
+		{Name: "ingest." + tenant2WithQualifier + ".in"},
+		{Name: "ingest." + tenant1WithQualifier + "bad-suffix"},
+	}
+
+	testCases := []struct {
+		name           string
+		mockError      error
+		mockResponse   *http.Response
+		expectedTopics []string
+		expectedCode   int
+		expectedErr    *response.ErrorDetail
+	}{
+		{
+			name:         "not-authorized",
+			mockError:    errors.New(forbiddenMessage),
+			mockResponse: &StatusForbidden,
+			expectedCode: http.StatusForbidden,
+			expectedErr:  response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg),
+		},
+		{
+			name:         "happy-path",
+			mockResponse: &http.Response{StatusCode: 200},
+			expectedTopics: []string{
+				"ingest." + tenant1WithQualifier + ".in",
+				"ingest." + tenant1NoQualifier + ".out",
+			},
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mockService := test.NewMockService(controller)
+			mockService.
+				EXPECT().
+				ListTopics(gomock.Any(), &es.ListTopicsOpts{}).
+				Return(topicDetails, tc.mockResponse, tc.mockError)
+
+			actualTopics, actualCode, actualErr := GetTenantTopics(context.Background(), requestId, tenantId1, mockService)
+			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedTopics, actualTopics) ||
+				!reflect.DeepEqual(tc.expectedErr, actualErr) {
+				t.Errorf("Streams-GetTenantTopics() \n actual: %v,%v,%v\n expected: %v,%v,%v",
+					actualTopics, actualCode, actualErr, tc.expectedTopics, tc.expectedCode, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestGetTenantStreams(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+	var requestId = "reqYq3Ff9pL2"
+
+	topicDetails := []es.TopicDetail{
+		{
+			Name:       "ingest." + tenant1WithQualifier + ".in",
+			Partitions: 2,
+			Configs:    es.TopicConfigs{RetentionMs: "86400000", CleanupPolicy: "delete", SegmentBytes: "10485760"},
+		},
+		{Name: "ingest." + tenant1WithQualifier + ".notification", Partitions: 1},
+		{Name: "ingest." + tenant1NoQualifier + ".in", Partitions: 1, RetentionMs: 3600000},
+		{Name: "ingest." + tenant2WithQualifier + ".in", Partitions: 1},
+	}
+
+	var twoPartitions, onePartition int64 = 2, 1
+	oneDay, oneHour, tenMB := 86400000, 3600000, 10485760
+	deletePolicy := "delete"
+
+	testCases := []struct {
+		name                string
+		mockError           error
+		mockResponse        *http.Response
+		expectedDefinitions []model.StreamDefinition
+		expectedCode        int
+		expectedErr         *response.ErrorDetail
+	}{
+		{
+			name:         "not-authorized",
+			mockError:    errors.New(forbiddenMessage),
+			mockResponse: &StatusForbidden,
+			expectedCode: http.StatusForbidden,
+			expectedErr:  response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg),
+		},
+		{
+			name:         "happy-path",
+			mockResponse: &http.Response{StatusCode: 200},
+			expectedDefinitions: []model.StreamDefinition{
+				{
+					StreamId:      streamId,
+					NumPartitions: &twoPartitions,
+					RetentionMs:   &oneDay,
+					CleanupPolicy: &deletePolicy,
+					SegmentBytes:  &tenMB,
+				},
+				{StreamId: streamIdNoQualifier, NumPartitions: &onePartition, RetentionMs: &oneHour},
+			},
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mockService := test.NewMockService(controller)
+			mockService.
+				EXPECT().
+				ListTopics(gomock.Any(), &es.ListTopicsOpts{}).
+				Return(topicDetails, tc.mockResponse, tc.mockError)
+
+			actualDefinitions, actualCode, actualErr := GetTenantStreams(context.Background(), requestId, tenantId1, mockService)
+			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedDefinitions, actualDefinitions) ||
+				!reflect.DeepEqual(tc.expectedErr, actualErr) {
+				t.Errorf("Streams-GetTenantStreams() \n actual: %v,%v,%v\n expected: %v,%v,%v",
+					actualDefinitions, actualCode, actualErr, tc.expectedDefinitions, tc.expectedCode, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -7,15 +7,19 @@
 package streams
 
 import (
+	"context"
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	configPkg "github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"sync"
 )
 
 const (
@@ -26,24 +30,59 @@ type Handler interface {
 	Create(echo.Context) error
 	Get(echo.Context) error
 	Delete(echo.Context) error
+	GetLag(echo.Context) error
 }
 
 // This struct is designed to make unit testing easier. It has function references for the calls to backend
 // logic and other methods that reach out to external services like JWT token validation.
 type theHandler struct {
-	config configPkg.Config
-	create func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error)
-	delete func(string, []string, eventstreams.Service) (int, error)
-	get    func(string, string, eventstreams.Service) (int, interface{})
+	config       configPkg.Config
+	jwtValidator auth.Validator // only set in the jwt admin auth mode
+	create       func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error)
+	delete       func(context.Context, string, []string, eventstreams.Service) (int, error)
+	get          func(context.Context, string, string, eventstreams.Service) (int, interface{})
+	getLag       func(context.Context, string, string, string, eventstreams.Service, kafka.LagReader) (int, interface{})
+	lagReader    *sharedLagReader
 }
 
 func NewHandler(config configPkg.Config) Handler {
+	var jwtValidator auth.Validator
+	if config.AdminAuthMode == configPkg.AdminAuthModeJwt {
+		jwtValidator = auth.NewValidator(config)
+	}
 	return &theHandler{
-		config: config,
-		create: Create,
-		get:    Get,
-		delete: Delete,
+		config:       config,
+		jwtValidator: jwtValidator,
+		create:       Create,
+		get:          Get,
+		delete:       Delete,
+		getLag:       GetLag,
+		lagReader:    &sharedLagReader{},
+	}
+}
+
+// authorize checks the caller's credentials with the configured admin auth mode, and returns the Event Streams service
+// to manage the topics with. In the iam mode the caller's IAM token is passed on to Event Streams, which authorizes it;
+// in the jwt mode it must be an HRI token with the hri_admin scope.
+func (h *theHandler) authorize(requestId string, c echo.Context) (eventstreams.Service, *response.ErrorDetailResponse) {
+	prefix := "streams/handler/authorize"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	bearerTokens := c.Request().Header[echo.HeaderAuthorization]
+	if h.config.AdminAuthMode != configPkg.AdminAuthModeJwt {
+		if len(bearerTokens) == 0 {
+			msg := eventstreams.MissingHeaderMsg
+			logger.Errorln(msg)
+			return nil, response.NewErrorDetailResponse(http.StatusUnauthorized, requestId, msg)
+		}
+		return eventstreams.CreateServiceForCaller(h.config, bearerTokens[0]), nil
+	}
+
+	// auth-disabled only applies to the batch endpoints, the admin endpoints are always authorized
+	if _, errResp := h.jwtValidator.GetValidatedAdminClaims(requestId, c.Request()); errResp != nil {
+		return nil, errResp
 	}
+	return eventstreams.CreateServiceForCaller(h.config, ""), nil
 }
 
 func (h *theHandler) Create(c echo.Context) error {
@@ -52,11 +91,10 @@ func (h *theHandler) Create(c echo.Context) error {
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Debug("Start Handler-Create")
 
-	bearerTokens := c.Request().Header[echo.HeaderAuthorization]
-	if len(bearerTokens) == 0 {
-		return c.JSON(http.StatusUnauthorized, response.NewErrorDetail(requestId, eventstreams.MissingHeaderMsg))
+	service, errResp := h.authorize(requestId, c)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
 	}
-	service := eventstreams.CreateServiceFromConfig(h.config, bearerTokens[0])
 
 	// bind & validate request body
 	var request model.CreateStreamsRequest
@@ -69,9 +107,9 @@ func (h *theHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
 	}
 
-	createdTopics, returnCode, createError := h.create(request, request.TenantId, request.StreamId, h.config.Validation, requestId, service)
+	createdTopics, returnCode, createError := h.create(c.Request().Context(), request, request.TenantId, request.StreamId, h.config.Validation, requestId, service)
 	if returnCode != http.StatusCreated {
-		_, deleteError := h.delete(requestId, createdTopics, service)
+		_, deleteError := h.delete(c.Request().Context(), requestId, createdTopics, service)
 		if deleteError != nil {
 			msg := fmt.Sprintf("%s\n%s", createError.Error(), deleteError)
 			return c.JSON(returnCode, response.NewErrorDetail(requestId, msg))
@@ -90,11 +128,10 @@ func (h *theHandler) Delete(c echo.Context) error {
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Debug("Start Handler Delete")
 
-	bearerTokens := c.Request().Header[echo.HeaderAuthorization]
-	if len(bearerTokens) == 0 {
-		return c.JSON(http.StatusUnauthorized, response.NewErrorDetail(requestId, eventstreams.MissingHeaderMsg))
+	service, errResp := h.authorize(requestId, c)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
 	}
-	service := eventstreams.CreateServiceFromConfig(h.config, bearerTokens[0])
 
 	// bind & validate request body
 	var request model.DeleteStreamRequest
@@ -113,7 +150,7 @@ func (h *theHandler) Delete(c echo.Context) error {
 		streamNames = append(streamNames, outTopicName, invalidTopicName)
 	}
 
-	returnCode, err := h.delete(requestId, streamNames, service)
+	returnCode, err := h.delete(c.Request().Context(), requestId, streamNames, service)
 	if err != nil {
 		return c.JSON(returnCode, response.NewErrorDetail(requestId, err.Error()))
 	}
@@ -127,13 +164,10 @@ func (h *theHandler) Get(c echo.Context) error {
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Debug("Start Handler List Streams")
 
-	bearerTokens := c.Request().Header[echo.HeaderAuthorization]
-	if len(bearerTokens) == 0 {
-		msg := eventstreams.MissingHeaderMsg
-		logger.Errorln(msg)
-		return c.JSON(http.StatusUnauthorized, response.NewErrorDetail(requestId, msg))
+	service, errResp := h.authorize(requestId, c)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
 	}
-	service := eventstreams.CreateServiceFromConfig(h.config, bearerTokens[0])
 
 	// bind & validate request body
 	var request model.GetStreamRequest
@@ -146,5 +180,56 @@ func (h *theHandler) Get(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
 	}
 
-	return c.JSON(h.get(requestId, request.TenantId, service))
+	return c.JSON(h.get(c.Request().Context(), requestId, request.TenantId, service))
+}
+
+func (h *theHandler) GetLag(c echo.Context) error {
+	requestId := c.Response().Header().Get(echo.HeaderXRequestID)
+	prefix := "streams/lag/handler"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	logger.Debug("Start Handler Get Stream Lag")
+
+	service, errResp := h.authorize(requestId, c)
+	if errResp != nil {
+		return c.JSON(errResp.Code, errResp.Body)
+	}
+
+	// bind & validate request body
+	var request model.GetStreamLagRequest
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	lagReader, err := h.lagReader.get(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	return c.JSON(h.getLag(c.Request().Context(), requestId, request.TenantId, request.StreamId, service, lagReader))
+}
+
+// sharedLagReader is created by the first lag request and shared by the later ones, so they reuse its Kafka
+// connection. It's created again by the next request when creating it fails.
+type sharedLagReader struct {
+	mu     sync.Mutex
+	reader kafka.LagReader
+}
+
+func (s *sharedLagReader) get(config configPkg.Config) (kafka.LagReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reader == nil {
+		reader, err := kafka.NewLagReader(config)
+		if err != nil {
+			return nil, err
+		}
+		s.reader = reader
+	}
+	return s.reader, nil
 }
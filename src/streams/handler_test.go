@@ -7,9 +7,12 @@
 package streams
 
 import (
+	"context"
 	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
@@ -45,6 +48,26 @@ func TestNewHandler(t *testing.T) {
 	assert.Equal(t, reflect.ValueOf(Create), reflect.ValueOf(handler.create))
 	assert.Equal(t, reflect.ValueOf(Delete), reflect.ValueOf(handler.delete))
 	assert.Equal(t, reflect.ValueOf(Get), reflect.ValueOf(handler.get))
+	assert.Equal(t, reflect.ValueOf(GetLag), reflect.ValueOf(handler.getLag))
+	assert.Nil(t, handler.jwtValidator)
+
+	config.AdminAuthMode = "jwt"
+	handler = NewHandler(config).(*theHandler)
+	assert.NotNil(t, handler.jwtValidator)
+}
+
+// Fake for the auth.Validator interface; just returns the desired values
+type fakeAuthValidator struct {
+	claims  auth.HriClaims
+	errResp *response.ErrorDetailResponse
+}
+
+func (f fakeAuthValidator) GetValidatedClaims(_ string, _ *http.Request, _ string) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func (f fakeAuthValidator) GetValidatedAdminClaims(_ string, _ *http.Request) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
 }
 
 func TestHandlerCreate(t *testing.T) {
@@ -72,7 +95,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "happy path",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
 				},
 			},
@@ -86,7 +109,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "failed event streams service create with bad auth token",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
 				},
 			},
@@ -96,11 +119,51 @@ func TestHandlerCreate(t *testing.T) {
 			expectedCode: http.StatusUnauthorized,
 			expectedBody: `{"errorEventId":"test-request-id","errorDescription":"missing header 'Authorization'"}`,
 		},
+		{
+			name: "jwt admin auth mode",
+			handler: theHandler{
+				config:       config.Config{AdminAuthMode: config.AdminAuthModeJwt},
+				jwtValidator: fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin}},
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
+				},
+			},
+			tenantId:     "tenant_id",
+			streamId:     "stream_id",
+			bearerTokens: []string{"Bearer hri-token"},
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":"stream_id"}`,
+		},
+		{
+			name: "jwt admin auth mode without the hri_admin scope",
+			handler: theHandler{
+				config: config.Config{AdminAuthMode: config.AdminAuthModeJwt},
+				jwtValidator: fakeAuthValidator{errResp: response.NewErrorDetailResponse(http.StatusForbidden,
+					"test-request-id", "Unauthorized admin access. 'hri_admin' is not included in the authorized scopes: hri_consumer.")},
+			},
+			tenantId:     "tenant_id",
+			streamId:     "stream_id",
+			bearerTokens: []string{"Bearer hri-token"},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"errorEventId":"test-request-id","errorDescription":"Unauthorized admin access. 'hri_admin' is not included in the authorized scopes: hri_consumer."}`,
+		},
+		{
+			name: "jwt admin auth mode with auth disabled",
+			handler: theHandler{
+				config: config.Config{AdminAuthMode: config.AdminAuthModeJwt, AuthDisabled: true},
+				jwtValidator: fakeAuthValidator{errResp: response.NewErrorDetailResponse(http.StatusUnauthorized,
+					"test-request-id", "Azure AD authentication returned 401")},
+			},
+			tenantId:     "tenant_id",
+			streamId:     "stream_id",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"errorEventId":"test-request-id","errorDescription":"Azure AD authentication returned 401"}`,
+		},
 		{
 			name: "failed with bad tenant id",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
 				},
 			},
@@ -114,7 +177,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "failed with bad stream id",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
 				},
 			},
@@ -128,7 +191,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "failed with invalid request fields",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
 				},
 			},
@@ -143,7 +206,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "failed with invalid json",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					return []string{"in", "out", "invalid", "notification"}, http.StatusCreated, nil
 				},
 			},
@@ -158,7 +221,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "create fails and topic deletion succeeds",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					message := "create failure message"
 					return []string{"in", "out"}, http.StatusInternalServerError, fmt.Errorf(message)
 				},
@@ -175,7 +238,7 @@ func TestHandlerCreate(t *testing.T) {
 			name: "create fails and topic deletion fails",
 			handler: theHandler{
 				config: config.Config{},
-				create: func(model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
+				create: func(context.Context, model.CreateStreamsRequest, string, string, bool, string, eventstreams.Service) ([]string, int, error) {
 					message := "create failure message"
 					return []string{"in", "out"}, http.StatusInternalServerError, fmt.Errorf(message)
 				},
@@ -198,18 +261,8 @@ func TestHandlerCreate(t *testing.T) {
 			if tt.request != "" {
 				requestBody = tt.request
 			}
-			request := httptest.NewRequest(http.MethodPost, "/hri/tenant/test/streams/streamId", strings.NewReader(requestBody))
-			context, recorder := test.PrepareHeadersContextRecorder(request, e)
-			for _, token := range tt.bearerTokens {
-				request.Header.Add(echo.HeaderAuthorization, token)
-			}
-			context.SetPath("/tenants/:tenantId/streams/:id")
-			context.SetParamNames(param.TenantId, param.StreamId)
-			context.SetParamValues(tt.tenantId, tt.streamId)
-			context.Response().Header().Add(echo.HeaderXRequestID, "test-request-id")
-
 			if tt.deleteReturnCode != 0 {
-				tt.handler.delete = func(requestId string, topicsToDelete []string, service eventstreams.Service) (int, error) {
+				tt.handler.delete = func(_ context.Context, requestId string, topicsToDelete []string, service eventstreams.Service) (int, error) {
 					if !reflect.DeepEqual(topicsToDelete, tt.expectedDeleteTopics) {
 						t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tt.expectedDeleteTopics, topicsToDelete))
 					}
@@ -222,6 +275,16 @@ func TestHandlerCreate(t *testing.T) {
 				}
 			}
 
+			request := httptest.NewRequest(http.MethodPost, "/hri/tenant/test/streams/streamId", strings.NewReader(requestBody))
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			for _, token := range tt.bearerTokens {
+				request.Header.Add(echo.HeaderAuthorization, token)
+			}
+			context.SetPath("/tenants/:tenantId/streams/:id")
+			context.SetParamNames(param.TenantId, param.StreamId)
+			context.SetParamValues(tt.tenantId, tt.streamId)
+			context.Response().Header().Add(echo.HeaderXRequestID, "test-request-id")
+
 			if assert.NoError(t, tt.handler.Create(context)) {
 				assert.Equal(t, tt.expectedCode, recorder.Code)
 				assert.Equal(t, tt.expectedBody, strings.Trim(recorder.Body.String(), "\n"))
@@ -325,7 +388,7 @@ func TestHandlerDelete(t *testing.T) {
 				config: config.Config{
 					Validation: true,
 				},
-				delete: func(string, []string, eventstreams.Service) (int, error) {
+				delete: func(context.Context, string, []string, eventstreams.Service) (int, error) {
 					message := "delete failure message"
 					return http.StatusInternalServerError, fmt.Errorf(message)
 				},
@@ -341,20 +404,10 @@ func TestHandlerDelete(t *testing.T) {
 	e := test.GetTestServer()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			request := httptest.NewRequest(http.MethodDelete, "/hri/tenant/test/streams/streamId", nil)
-			context, recorder := test.PrepareHeadersContextRecorder(request, e)
-			for _, token := range tt.bearerTokens {
-				request.Header.Add(echo.HeaderAuthorization, token)
-			}
-			context.SetPath("/tenants/:tenantId/streams/:id")
-			context.SetParamNames(param.TenantId, param.StreamId)
-			context.SetParamValues(tt.tenantId, tt.streamId)
-			context.Response().Header().Add(echo.HeaderXRequestID, "test-request-id")
-
 			if tt.handler.delete == nil {
 				// The handler wasn't mocked in the test case. Assert that the proper arguments were sent to
 				// the delete handler and return a 200.
-				tt.handler.delete = func(requestId string, actualStreamNames []string, service eventstreams.Service) (int, error) {
+				tt.handler.delete = func(_ context.Context, requestId string, actualStreamNames []string, service eventstreams.Service) (int, error) {
 					assert.NotNil(t, service)
 					if !reflect.DeepEqual(actualStreamNames, tt.expectedStreamNames) {
 						t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tt.expectedStreamNames, actualStreamNames))
@@ -364,6 +417,16 @@ func TestHandlerDelete(t *testing.T) {
 				}
 			}
 
+			request := httptest.NewRequest(http.MethodDelete, "/hri/tenant/test/streams/streamId", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			for _, token := range tt.bearerTokens {
+				request.Header.Add(echo.HeaderAuthorization, token)
+			}
+			context.SetPath("/tenants/:tenantId/streams/:id")
+			context.SetParamNames(param.TenantId, param.StreamId)
+			context.SetParamValues(tt.tenantId, tt.streamId)
+			context.Response().Header().Add(echo.HeaderXRequestID, "test-request-id")
+
 			if assert.NoError(t, tt.handler.Delete(context)) {
 				assert.Equal(t, tt.expectedCode, recorder.Code)
 				assert.Equal(t, tt.expectedBody, strings.Trim(recorder.Body.String(), "\n"))
@@ -398,7 +461,7 @@ func TestHandlerGet(t *testing.T) {
 			name: "happy path",
 			handler: theHandler{
 				config: config.Config{},
-				get: func(string, string, eventstreams.Service) (int, interface{}) {
+				get: func(context.Context, string, string, eventstreams.Service) (int, interface{}) {
 					return http.StatusOK, goodRequestStreams
 				},
 			},
@@ -411,7 +474,7 @@ func TestHandlerGet(t *testing.T) {
 			name: "list streams returns no results",
 			handler: theHandler{
 				config: config.Config{},
-				get: func(string, string, eventstreams.Service) (int, interface{}) {
+				get: func(context.Context, string, string, eventstreams.Service) (int, interface{}) {
 					return http.StatusOK, emptyStreamsResults
 				},
 			},
@@ -424,7 +487,7 @@ func TestHandlerGet(t *testing.T) {
 			name: "List streams fails with bad auth token",
 			handler: theHandler{
 				config: config.Config{},
-				get: func(string, string, eventstreams.Service) (int, interface{}) {
+				get: func(context.Context, string, string, eventstreams.Service) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -437,7 +500,7 @@ func TestHandlerGet(t *testing.T) {
 			name: "Return Bad Request for missing TenantId Param ",
 			handler: theHandler{
 				config: config.Config{},
-				get: func(string, string, eventstreams.Service) (int, interface{}) {
+				get: func(context.Context, string, string, eventstreams.Service) (int, interface{}) {
 					return http.StatusForbidden, map[string]interface{}{"NO_CALL": "This Function Should Never Get Called"}
 				},
 			},
@@ -449,7 +512,7 @@ func TestHandlerGet(t *testing.T) {
 			name: "List streams function call fails",
 			handler: theHandler{
 				config: config.Config{},
-				get: func(string, string, eventstreams.Service) (int, interface{}) {
+				get: func(context.Context, string, string, eventstreams.Service) (int, interface{}) {
 					return http.StatusInternalServerError,
 						response.NewErrorDetail(requestId, "Error List Streams: Unable to connect to Kafka")
 				},
@@ -481,3 +544,121 @@ func TestHandlerGet(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerGetLag(t *testing.T) {
+	var requestId = "req43"
+	var validTenantId = "tenant_id"
+	var validStreamId = "data_integrator.qualifier"
+	logwrapper.Initialize("error", os.Stdout)
+
+	tests := []struct {
+		name         string
+		handler      theHandler
+		tenantId     string
+		streamId     string
+		bearerTokens []string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config:    config.Config{},
+				lagReader: &sharedLagReader{},
+				getLag: func(_ context.Context, _ string, tenantId string, streamId string, _ eventstreams.Service, _ kafka.LagReader) (int, interface{}) {
+					assert.Equal(t, validTenantId, tenantId)
+					assert.Equal(t, validStreamId, streamId)
+					return http.StatusOK, map[string]interface{}{"results": []kafka.ConsumerGroupLag{}}
+				},
+			},
+			tenantId:     validTenantId,
+			streamId:     validStreamId,
+			bearerTokens: []string{"valid-token1"},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"results":[]}`,
+		},
+		{
+			name: "missing auth header",
+			handler: theHandler{
+				config: config.Config{},
+			},
+			tenantId:     validTenantId,
+			streamId:     validStreamId,
+			bearerTokens: []string{},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"errorEventId":"req43","errorDescription":"missing header 'Authorization'"}`,
+		},
+		{
+			name: "bad stream id",
+			handler: theHandler{
+				config: config.Config{},
+			},
+			tenantId:     validTenantId,
+			streamId:     "INVALID",
+			bearerTokens: []string{"valid-token1"},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"errorEventId":"req43","errorDescription":"invalid request arguments:\n- id (url path parameter) may only contain lower-case alpha-numeric characters, no more than one '.', and the following 2 special chars: '-', '_'"}`,
+		},
+		{
+			name: "kafka client error",
+			handler: theHandler{
+				config:    config.Config{KafkaProperties: config.StringMap{"message.max.bytes": "bad_value"}},
+				lagReader: &sharedLagReader{},
+			},
+			tenantId:     validTenantId,
+			streamId:     validStreamId,
+			bearerTokens: []string{"valid-token1"},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"errorEventId":"req43","errorDescription":"error constructing Kafka admin client: Invalid value for configuration property \"message.max.bytes\""}`,
+		},
+		{
+			name: "get lag function call fails",
+			handler: theHandler{
+				config:    config.Config{},
+				lagReader: &sharedLagReader{},
+				getLag: func(context.Context, string, string, string, eventstreams.Service, kafka.LagReader) (int, interface{}) {
+					return http.StatusNotFound, response.NewErrorDetail(requestId, "Stream [data_integrator.qualifier] not found for tenant [tenant_id]")
+				},
+			},
+			tenantId:     validTenantId,
+			streamId:     validStreamId,
+			bearerTokens: []string{"valid-token1"},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"errorEventId":"req43","errorDescription":"Stream [data_integrator.qualifier] not found for tenant [tenant_id]"}`,
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			for _, token := range tt.bearerTokens {
+				request.Header.Add(echo.HeaderAuthorization, token)
+			}
+			context.SetPath("/tenants/:tenantId/streams/:id/lag")
+			context.SetParamNames(param.TenantId, param.StreamId)
+			context.SetParamValues(tt.tenantId, tt.streamId)
+			context.Response().Header().Add(echo.HeaderXRequestID, requestId)
+
+			if assert.NoError(t, tt.handler.GetLag(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, strings.Trim(recorder.Body.String(), "\n"))
+			}
+		})
+	}
+}
+
+func TestSharedLagReader(t *testing.T) {
+	shared := &sharedLagReader{}
+	_, err := shared.get(config.Config{KafkaProperties: config.StringMap{"message.max.bytes": "bad_value"}})
+	assert.Error(t, err)
+
+	// a failure isn't kept, the reader is created by the next call and then reused
+	first, err := shared.get(config.Config{})
+	assert.NoError(t, err)
+	second, err := shared.get(config.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	first.Close()
+}
@@ -0,0 +1,80 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package streams
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
+	"net/http"
+)
+
+const msgStreamNotFound = "Stream [%s] not found for tenant [%s]"
+const msgLagErr = "Unable to get consumer lag for stream [%s]. %s"
+
+func GetLag(
+	ctx context.Context,
+	requestId string,
+	tenantId string,
+	streamId string,
+	service eventstreams.Service,
+	lagReader kafka.LagReader) (int, interface{}) {
+
+	prefix := "streams/GetLag"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
+	logger.Debugf("Get consumer lag for stream: %s, tenant: %s", streamId, tenantId)
+
+	// The topics are listed through the Event Streams Admin API, which authorizes the caller's bearer token before
+	// the HRI's own Kafka credentials are used to read offsets.
+	topicDetails, resp, err := service.ListTopics(ctx, &es.ListTopicsOpts{})
+	if err != nil {
+		msg := fmt.Sprintf(msgStreamsNotFound, tenantId, err.Error())
+		logger.Errorln(msg)
+		return getResponseError(requestId, resp, err)
+	}
+
+	topics := getStreamTopics(topicDetails, tenantId, streamId)
+	if len(topics) == 0 {
+		msg := fmt.Sprintf(msgStreamNotFound, streamId, tenantId)
+		logger.Errorln(msg)
+		return http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+	}
+
+	groups, err := lagReader.GetConsumerLag(ctx, topics)
+	if err != nil {
+		msg := fmt.Sprintf(msgLagErr, streamId, err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		param.StreamId: streamId,
+		"topics":       topics,
+		"results":      groups,
+	}
+}
+
+// getStreamTopics returns the names of the stream's topics that exist, in in/notification/out/invalid order
+func getStreamTopics(topicDetails []es.TopicDetail, tenantId string, streamId string) []string {
+	existing := make(map[string]bool)
+	for _, topic := range topicDetails {
+		existing[topic.Name] = true
+	}
+
+	inTopicName, notificationTopicName, outTopicName, invalidTopicName := eventstreams.CreateTopicNames(tenantId, streamId)
+	topics := []string{}
+	for _, topic := range []string{inTopicName, notificationTopicName, outTopicName, invalidTopicName} {
+		if existing[topic] {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
@@ -0,0 +1,135 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package streams
+
+import (
+	"context"
+	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+type fakeLagReader struct {
+	t              *testing.T
+	expectedTopics []string
+	groups         []kafka.ConsumerGroupLag
+	err            error
+}
+
+func (flr fakeLagReader) GetConsumerLag(_ context.Context, topics []string) ([]kafka.ConsumerGroupLag, error) {
+	assert.Equal(flr.t, flr.expectedTopics, topics)
+	return flr.groups, flr.err
+}
+
+func (flr fakeLagReader) Close() {}
+
+func TestGetLag(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "reqLag1"
+	inTopic := "ingest." + tenant1WithQualifier + ".in"
+	notificationTopic := "ingest." + tenant1WithQualifier + ".notification"
+	outTopic := "ingest." + tenant1WithQualifier + ".out"
+	groups := []kafka.ConsumerGroupLag{
+		{
+			GroupId:  "validation",
+			State:    "Stable",
+			TotalLag: 5,
+			Partitions: []kafka.PartitionLag{
+				{Topic: inTopic, Partition: 0, CommittedOffset: 10, EndOffset: 15, Lag: 5},
+			},
+		},
+	}
+	topicDetails := []es.TopicDetail{
+		{Name: outTopic},
+		{Name: inTopic},
+		{Name: notificationTopic},
+		{Name: "ingest." + tenant1NoQualifier + ".in"},
+		{Name: "ingest." + tenant2WithQualifier + ".in"},
+	}
+
+	testCases := []struct {
+		name         string
+		streamId     string
+		mockError    error
+		mockResponse *http.Response
+		lagReader    fakeLagReader
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name:         "not-authorized",
+			streamId:     streamId,
+			mockError:    errors.New(forbiddenMessage),
+			mockResponse: &StatusForbidden,
+			expectedCode: http.StatusForbidden,
+			expectedBody: response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg),
+		},
+		{
+			name:         "stream-not-found",
+			streamId:     "unknownIntegrator",
+			mockResponse: &http.Response{StatusCode: 200},
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, "Stream [unknownIntegrator] not found for tenant [tenant1]"),
+		},
+		{
+			name:         "lag-reader-error",
+			streamId:     streamId,
+			mockResponse: &http.Response{StatusCode: 200},
+			lagReader: fakeLagReader{
+				t:              t,
+				expectedTopics: []string{inTopic, notificationTopic, outTopic},
+				err:            errors.New("error listing Kafka consumer groups: timed out"),
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Unable to get consumer lag for stream [dataIntegrator1.qualifier123]. error listing Kafka consumer groups: timed out"),
+		},
+		{
+			name:         "happy-path",
+			streamId:     streamId,
+			mockResponse: &http.Response{StatusCode: 200},
+			lagReader: fakeLagReader{
+				t:              t,
+				expectedTopics: []string{inTopic, notificationTopic, outTopic},
+				groups:         groups,
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.StreamId: streamId,
+				"topics":       []string{inTopic, notificationTopic, outTopic},
+				"results":      groups,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mockService := test.NewMockService(controller)
+			mockService.
+				EXPECT().
+				ListTopics(gomock.Any(), &es.ListTopicsOpts{}).
+				Return(topicDetails, tc.mockResponse, tc.mockError).
+				MaxTimes(1)
+
+			actualCode, actualBody := GetLag(context.Background(), requestId, tenantId1, tc.streamId, mockService, tc.lagReader)
+			assert.Equal(t, tc.expectedCode, actualCode)
+			assert.Equal(t, tc.expectedBody, actualBody)
+		})
+	}
+}
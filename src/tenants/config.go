@@ -0,0 +1,139 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+)
+
+const msgTenantNotFound = "Tenant: %s not found"
+const msgConfigNotFound = "No configuration found for tenant [%s]"
+
+// the number of times Elastic retries the configuration update when the document changes while it's applied
+const updateRetries = 3
+
+// replaceConfigScript replaces the configuration document with the new one, but keeps its suspension and legal hold
+const replaceConfigScript = "def suspension = ctx._source.suspension; def legalHold = ctx._source.legalHold; " +
+	"ctx._source = params.config; " +
+	"if (suspension != null) { ctx._source.suspension = suspension } " +
+	"if (legalHold != null) { ctx._source.legalHold = legalHold }"
+
+// PutConfig replaces the tenant's configuration document. The tenant must already exist.
+func PutConfig(ctx context.Context, requestId string, tenantConfig model.TenantConfig, client *elasticsearch.Client) (int, interface{}) {
+	prefix := "tenants/PutConfig"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantConfig.TenantId)
+	logger.Debugln("Start Tenant Put Config")
+
+	tenantId := tenantConfig.TenantId
+	exists, err := tenantExists(ctx, tenantId, client)
+	if err != nil {
+		msg := fmt.Sprintf("Could not update the configuration of tenant [%s]: %s", tenantId, err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+	if !exists {
+		msg := fmt.Sprintf(msgTenantNotFound, tenantId)
+		logger.Errorln(msg)
+		return http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+	}
+
+	// the suspension and legal hold can't be changed through the configuration, so the update keeps the stored ones.
+	// It's applied by a script, so a suspension or legal hold set concurrently isn't overwritten.
+	tenantConfig.Suspension = nil
+	tenantConfig.LegalHold = nil
+	configDoc, err := toDocument(tenantConfig)
+	if err != nil {
+		//NOTE: This should Never happen because the config is a statically-typed struct
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error())
+	}
+
+	updateRequest := map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": replaceConfigScript,
+			"params": map[string]interface{}{"config": configDoc},
+		},
+		// tenants without a configuration get the new one as is
+		"upsert": configDoc,
+	}
+	encodedQuery, err := elastic.EncodeQueryBody(updateRequest)
+	if err != nil {
+		msg := fmt.Sprintf("error encoding Elastic query: %s", err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+
+	updateRes, err := client.Update(
+		elastic.TenantsIndex,
+		tenantId,
+		encodedQuery,
+		client.Update.WithContext(ctx),
+		client.Update.WithRefresh("true"),
+		client.Update.WithRetryOnConflict(updateRetries),
+		client.Update.WithSource("true"), // return the updated configuration in the response
+	)
+
+	body, elasticErr := elastic.DecodeBody(updateRes, err)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not update the configuration of tenant [%s]", tenantId))
+	}
+
+	updatedConfig, err := param.ExtractValues(body, "get", "_source")
+	if err != nil {
+		msg := fmt.Sprintf("updated configuration not returned in Elastic response: %s", err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+	updatedConfig[param.TenantId] = tenantId
+	return http.StatusOK, updatedConfig
+}
+
+func GetConfig(ctx context.Context, requestId string, tenantId string, client *elasticsearch.Client) (int, interface{}) {
+	prefix := "tenants/GetConfig"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
+	logger.Debugln("Start Tenant Get Config")
+
+	res, err := client.Get(elastic.TenantsIndex, tenantId, client.Get.WithContext(ctx))
+
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		if elasticErr.Code == http.StatusNotFound {
+			msg := fmt.Sprintf(msgConfigNotFound, tenantId)
+			logger.Errorln(msg)
+			return http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+		}
+		return elasticErr.Code, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not retrieve the configuration of tenant [%s]", tenantId))
+	}
+
+	source := body["_source"].(map[string]interface{})
+	source[param.TenantId] = tenantId
+	return http.StatusOK, source
+}
+
+func tenantExists(ctx context.Context, tenantId string, client *elasticsearch.Client) (bool, error) {
+	return elastic.IndexExists(ctx, elastic.IndexFromTenantId(tenantId), client)
+}
+
+// toDocument round trips the configuration through JSON, so it has the same shape as the stored document
+func toDocument(tenantConfig model.TenantConfig) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(tenantConfig)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	err = json.Unmarshal(encoded, &doc)
+	return doc, err
+}
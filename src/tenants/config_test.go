@@ -0,0 +1,262 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPutConfig(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+	elasticErrMsg := "elasticErrMsg"
+
+	invalidThreshold := 10
+	maxActiveBatches := 5
+	tenantConfig := model.TenantConfig{
+		TenantId:                tenantId,
+		DisplayName:             "Tenant 123",
+		DefaultInvalidThreshold: &invalidThreshold,
+		AllowedDataTypes:        []string{"claims"},
+		Quota:                   &model.TenantQuota{MaxActiveBatches: &maxActiveBatches},
+	}
+	// the suspension and legal hold in the request are ignored
+	tenantConfig.Suspension = &model.TenantSuspension{SuspendDate: "2021-02-24T18:08:36Z"}
+	storedConfig := `{"displayName":"Tenant 123","defaultInvalidThreshold":10,"allowedDataTypes":["claims"],"quota":{"maxActiveBatches":5}}`
+	// the documents are encoded from maps, so their keys are sorted
+	configBody := regexp.QuoteMeta(`{"allowedDataTypes":["claims"],"defaultInvalidThreshold":10,"displayName":"Tenant 123","quota":{"maxActiveBatches":5}}`)
+	updateBody := fmt.Sprintf(`{"script":{"params":{"config":%s},"source":"%s"},"upsert":%s}`,
+		configBody, regexp.QuoteMeta(replaceConfigScript), configBody)
+
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	updatePath := fmt.Sprintf("/%s/_doc/%s/_update", elastic.TenantsIndex, tenantId)
+	updateQuery := "_source=true&refresh=true&retry_on_conflict=3"
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "tenant-not-found",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusNotFound},
+			),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantNotFound, tenantId)),
+		},
+		{
+			name: "tenant-check-error",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseErr: errors.New(elasticErrMsg)},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				fmt.Sprintf("Could not update the configuration of tenant [%s]: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name: "tenant-check-unexpected-status",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusForbidden},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(
+				"Could not update the configuration of tenant [%s]: unable to check if index %s-batches exists: 403 Forbidden",
+				tenantId, tenantId)),
+		},
+		{
+			name: "update-error",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				updatePath,
+				test.ElasticCall{
+					RequestBody: updateBody,
+					ResponseErr: errors.New(elasticErrMsg),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(
+				"Could not update the configuration of tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name: "missing-source",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				updatePath,
+				test.ElasticCall{
+					RequestQuery: updateQuery,
+					RequestBody:  updateBody,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"updated"}`, elastic.TenantsIndex, tenantId),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"updated configuration not returned in Elastic response: error extracting the get section of the JSON"),
+		},
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				updatePath,
+				test.ElasticCall{
+					RequestQuery: updateQuery,
+					RequestBody:  updateBody,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"created","get":{"_source":%s}}`,
+						elastic.TenantsIndex, tenantId, storedConfig),
+				},
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId:            tenantId,
+				"displayName":             "Tenant 123",
+				"defaultInvalidThreshold": float64(10),
+				"allowedDataTypes":        []interface{}{"claims"},
+				"quota":                   map[string]interface{}{"maxActiveBatches": float64(5)},
+			},
+		},
+		{
+			name: "keeps-suspension-and-legal-hold",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				updatePath,
+				test.ElasticCall{
+					RequestQuery: updateQuery,
+					RequestBody:  updateBody,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"updated","get":{"_source":%s}}`,
+						elastic.TenantsIndex, tenantId, strings.TrimSuffix(storedConfig, "}")+
+							`,"suspension":{"suspendDate":"2021-02-24T18:08:36Z"},"legalHold":{"reason":"litigation","actor":"admin","holdDate":"2021-02-24T18:08:36Z"}}`),
+				},
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId:            tenantId,
+				"displayName":             "Tenant 123",
+				"defaultInvalidThreshold": float64(10),
+				"allowedDataTypes":        []interface{}{"claims"},
+				"quota":                   map[string]interface{}{"maxActiveBatches": float64(5)},
+				"suspension":              map[string]interface{}{"suspendDate": "2021-02-24T18:08:36Z"},
+				"legalHold":               map[string]interface{}{"reason": "litigation", "actor": "admin", "holdDate": "2021-02-24T18:08:36Z"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := PutConfig(context.Background(), requestId, tenantConfig, client)
+			if code != tc.expectedCode {
+				t.Error(fmt.Sprintf("Incorrect HTTP code returned. Expected: [%v], actual: [%v]", tc.expectedCode, code))
+			} else if !reflect.DeepEqual(tc.expectedBody, body) {
+				t.Error(fmt.Sprintf("Incorrect HTTP response body returned. Expected: [%v], actual: [%v]", tc.expectedBody, body))
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+	elasticErrMsg := "elasticErrMsg"
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "bad-response",
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath,
+				test.ElasticCall{ResponseErr: errors.New(elasticErrMsg)},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(
+				"Could not retrieve the configuration of tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name: "not-found",
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+				},
+			),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgConfigNotFound, tenantId)),
+		},
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"contact":"ops@tenant123.com","retentionDays":30}}`,
+						elastic.TenantsIndex, tenantId),
+				},
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId:  tenantId,
+				"contact":       "ops@tenant123.com",
+				"retentionDays": float64(30),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := GetConfig(context.Background(), requestId, tenantId, client)
+			if code != tc.expectedCode {
+				t.Error(fmt.Sprintf("Incorrect HTTP code returned. Expected: [%v], actual: [%v]", tc.expectedCode, code))
+			} else if !reflect.DeepEqual(tc.expectedBody, body) {
+				t.Error(fmt.Sprintf("Incorrect HTTP response body returned. Expected: [%v], actual: [%v]", tc.expectedBody, body))
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
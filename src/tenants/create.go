@@ -6,6 +6,7 @@
 package tenants
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
@@ -15,15 +16,17 @@ import (
 )
 
 func Create(
+	ctx context.Context,
 	requestId string,
 	tenantId string,
 	esClient *elasticsearch.Client) (int, interface{}) {
 
 	prefix := "tenants/Create"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
 
 	//create new index
-	indexRes, err := esClient.Indices.Create(elastic.IndexFromTenantId(tenantId))
+	indexRes, err := esClient.Indices.Create(elastic.IndexFromTenantId(tenantId),
+		esClient.Indices.Create.WithContext(ctx))
 
 	// parse the response
 	_, elasticErr := elastic.DecodeBody(indexRes, err)
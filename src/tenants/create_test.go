@@ -6,6 +6,7 @@
 package tenants
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
@@ -59,7 +60,7 @@ func TestCreate(t *testing.T) {
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
-			if _, actual := Create(requestId, tenantId, client); !reflect.DeepEqual(tc.expected, actual) {
+			if _, actual := Create(context.Background(), requestId, tenantId, client); !reflect.DeepEqual(tc.expected, actual) {
 				t.Error(fmt.Sprintf("Expected: [%v], actual: [%v]", tc.expected, actual))
 			}
 			tc.transport.VerifyCalls()
@@ -6,30 +6,187 @@
 package tenants
 
 import (
+	"context"
 	"fmt"
-	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
-
-	// "fmt"
+	"github.com/Alvearie/hri-mgmt-api/batches/status"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/streams"
 	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
 	"net/http"
 )
 
-func Delete(requestId string, tenantId string, client *elasticsearch.Client) (int, interface{}) {
+const (
+	msgNonTerminalBatches = "Tenant [%s] has %d batches that are not in a terminal state; use force=true to delete it anyway"
+	msgTenantOnLegalHold  = "Tenant [%s] is under legal hold and can't be %s"
+	msgBatchesOnLegalHold = "Tenant [%s] has %d batches under legal hold and can't be deleted"
+)
+
+// Delete removes the tenant's batches index and configuration. With cascade, all the tenant's stream topics are deleted first, so a
+// failure leaves the tenant in place to retry. With dryRun, nothing is deleted and everything that would be is returned.
+// Unless forced, tenants with batches that are still started or sendCompleted are not deleted. Tenants that are, or
+// have batches, under legal hold are never deleted.
+func Delete(ctx context.Context, requestId string, request model.DeleteTenant, client *elasticsearch.Client, service eventstreams.Service) (int, interface{}) {
 	prefix := "tenants/Delete"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
 	logger.Debugln("Start Tenant Delete")
 
-	index := []string{elastic.IndexFromTenantId(tenantId)}
+	tenantId := request.TenantId
+	index := elastic.IndexFromTenantId(tenantId)
+
+	tenantConfig, elasticErr := elastic.GetTenantConfig(ctx, tenantId, client)
+	if elasticErr != nil {
+		return elasticErr.Code, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not delete tenant [%s]", tenantId))
+	}
+
+	counts, code, errDetail := countBatches(ctx, requestId, tenantId, client, logger)
+	if errDetail != nil {
+		// the index is already gone when an earlier delete failed to remove the configuration, so that's retried
+		if code == http.StatusNotFound && !request.DryRun {
+			if configErr := deleteConfig(ctx, requestId, tenantId, client, logger); configErr != nil {
+				return http.StatusInternalServerError, configErr
+			}
+		}
+		return code, errDetail
+	}
+
+	topics := []string{}
+	if request.Cascade {
+		topics, code, errDetail = streams.GetTenantTopics(ctx, requestId, tenantId, service)
+		if errDetail != nil {
+			return code, errDetail
+		}
+	}
+
+	if request.DryRun {
+		return http.StatusOK, map[string]interface{}{
+			param.TenantId:       tenantId,
+			"index":              index,
+			"docCount":           counts.total,
+			"nonTerminalBatches": counts.nonTerminal,
+			"legalHold":          tenantConfig.LegalHold != nil,
+			"legalHoldBatches":   counts.legalHold,
+			"topics":             topics,
+		}
+	}
+
+	if tenantConfig.LegalHold != nil {
+		msg := fmt.Sprintf(msgTenantOnLegalHold, tenantId, "deleted")
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+	if counts.legalHold > 0 {
+		msg := fmt.Sprintf(msgBatchesOnLegalHold, tenantId, counts.legalHold)
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+	if counts.nonTerminal > 0 && !request.Force {
+		msg := fmt.Sprintf(msgNonTerminalBatches, tenantId, counts.nonTerminal)
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+
+	if len(topics) > 0 {
+		code, err := streams.Delete(ctx, requestId, topics, service)
+		if err != nil {
+			return code, response.NewErrorDetail(requestId,
+				fmt.Sprintf("Could not delete the streams of tenant [%s]: %s", tenantId, err.Error()))
+		}
+	}
 
 	//make call to elastic to delete tenant
-	res, err2 := client.Indices.Delete(index)
+	res, err := client.Indices.Delete([]string{index}, client.Indices.Delete.WithContext(ctx))
 
-	_, elasticErr := elastic.DecodeBody(res, err2)
+	_, elasticErr = elastic.DecodeBody(res, err)
 	if elasticErr != nil {
 		return elasticErr.Code, elasticErr.LogAndBuildErrorDetail(requestId,
 			logger, fmt.Sprintf("Could not delete tenant [%s]", tenantId))
 	}
 
+	// the configuration is removed last, so a failure to delete the index keeps the tenant's suspension and legal hold
+	if errDetail := deleteConfig(ctx, requestId, tenantId, client, logger); errDetail != nil {
+		return http.StatusInternalServerError, errDetail
+	}
+
 	return http.StatusOK, nil
 }
+
+// deleteConfig removes the tenant's configuration document, if it has one
+func deleteConfig(ctx context.Context, requestId string, tenantId string, client *elasticsearch.Client,
+	logger logrus.FieldLogger) *response.ErrorDetail {
+
+	res, err := client.Delete(elastic.TenantsIndex, tenantId, client.Delete.WithContext(ctx))
+	_, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil && elasticErr.Code != http.StatusNotFound {
+		return elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not delete the configuration of tenant [%s]", tenantId))
+	}
+	return nil
+}
+
+type batchCounts struct {
+	total       int
+	nonTerminal int
+	legalHold   int
+}
+
+// countBatches returns the total number of batches in the tenant's index, how many are not in a terminal state and
+// how many are under legal hold
+func countBatches(ctx context.Context, requestId string, tenantId string, client *elasticsearch.Client,
+	logger logrus.FieldLogger) (batchCounts, int, *response.ErrorDetail) {
+
+	query := map[string]interface{}{
+		"aggs": map[string]interface{}{
+			"nonTerminal": map[string]interface{}{
+				"filter": map[string]interface{}{
+					"terms": map[string]interface{}{
+						param.Status: []string{status.Started.String(), status.SendCompleted.String()},
+					},
+				},
+			},
+			param.LegalHold: map[string]interface{}{
+				"filter": map[string]interface{}{
+					"exists": map[string]interface{}{"field": param.LegalHold},
+				},
+			},
+		},
+	}
+	buf, err := elastic.EncodeQueryBody(query)
+	if err != nil {
+		msg := fmt.Sprintf("Error encoding Elastic query: %s", err.Error())
+		logger.Errorln(msg)
+		return batchCounts{}, http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+
+	res, err := client.Search(
+		client.Search.WithContext(ctx),
+		client.Search.WithIndex(elastic.IndexFromTenantId(tenantId)),
+		client.Search.WithBody(buf),
+		client.Search.WithSize(0),
+		client.Search.WithTrackTotalHits(true),
+	)
+
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return batchCounts{}, elasticErr.Code, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not delete tenant [%s]", tenantId))
+	}
+
+	aggregations := body["aggregations"].(map[string]interface{})
+	docCount := func(name string) int {
+		count, _ := aggregations[name].(map[string]interface{})["doc_count"].(float64)
+		return int(count)
+	}
+	total := body["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)
+	return batchCounts{
+		total:       int(total),
+		nonTerminal: docCount("nonTerminal"),
+		legalHold:   docCount(param.LegalHold),
+	}, http.StatusOK, nil
+}
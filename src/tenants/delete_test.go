@@ -6,12 +6,18 @@
 package tenants
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/Alvearie/hri-mgmt-api/common/test"
+	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
+	"github.com/golang/mock/gomock"
 	"net/http"
 	"os"
 	"reflect"
@@ -25,18 +31,50 @@ func TestDelete(t *testing.T) {
 	tenantId := "tenant123"
 
 	elasticErrMsg := "elasticErrMsg"
+	searchPath := fmt.Sprintf("/%s-batches/_search", tenantId)
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	countQuery := `{"aggs":{"legalHold":{"filter":{"exists":{"field":"legalHold"}}},"nonTerminal":{"filter":{"terms":{"status":\["started","sendCompleted"\]}}}}}`
+	countResponse := func(total int, nonTerminal int, legalHold int) string {
+		return fmt.Sprintf(`{"hits":{"total":{"value":%d,"relation":"eq"},"hits":[]},"aggregations":{"nonTerminal":{"doc_count":%d},"legalHold":{"doc_count":%d}}}`,
+			total, nonTerminal, legalHold)
+	}
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	noConfigCall := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"not_found"}`, elastic.TenantsIndex, tenantId),
+	}
+	missingIndexResponse := fmt.Sprintf(`{"error":{"type":"index_not_found_exception","reason":"no such index [%s-batches]"},"status":404}`, tenantId)
+	deleteResponse := fmt.Sprintf(`{"acknowledged":true,"shards_acknowledged":true,"index":"%s-batches"}`, tenantId)
+
+	inTopic := "ingest." + tenantId + ".dataIntegrator1.in"
+	notificationTopic := "ingest." + tenantId + ".dataIntegrator1.notification"
+	tenantTopics := []es.TopicDetail{
+		{Name: inTopic},
+		{Name: notificationTopic},
+		{Name: "ingest." + tenantId + "0.dataIntegrator1.in"},
+		{Name: "ingest.otherTenant.dataIntegrator1.in"},
+	}
 
 	testCases := []struct {
-		name              string
-		validatorResponse map[string]interface{}
-		transport         *test.FakeTransport
-		expectedCode      int
-		expectedBody      interface{}
+		name             string
+		request          model.DeleteTenant
+		transport        *test.FakeTransport
+		listTopicsResp   *http.Response
+		listTopicsErr    error
+		deleteTopicsResp *http.Response
+		deleteTopicsErr  *es.ModelError
+		expectListTopics bool
+		expectDeletes    []string
+		expectedCode     int
+		expectedBody     interface{}
 	}{
 		{
-			name: "bad-response",
+			name:    "bad-response",
+			request: model.DeleteTenant{TenantId: tenantId},
 			transport: test.NewFakeTransport(t).AddCall(
-				fmt.Sprintf("/%s-batches", tenantId),
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
 				test.ElasticCall{
 					ResponseErr: errors.New(elasticErrMsg),
 				},
@@ -45,16 +83,286 @@ func TestDelete(t *testing.T) {
 			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not delete tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
 		},
 		{
-			name: "good-request",
+			name:    "bad-delete-response",
+			request: model.DeleteTenant{TenantId: tenantId},
 			transport: test.NewFakeTransport(t).AddCall(
-				fmt.Sprintf("/%s-batches", tenantId),
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
 				test.ElasticCall{
-					ResponseBody: fmt.Sprintf(`{"acknowledged":true,"shards_acknowledged":true,"index":"%s-batches"}`, tenantId),
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			).AddCall(
+				indexPath,
+				test.ElasticCall{
+					ResponseErr: errors.New(elasticErrMsg),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not delete tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name:    "good-request",
+			request: model.DeleteTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				indexPath,
+				test.ElasticCall{
+					ResponseBody: deleteResponse,
 				},
 			),
 			expectedCode: http.StatusOK,
 			expectedBody: nil,
 		},
+		{
+			name:    "non-terminal-batches",
+			request: model.DeleteTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 2, 0),
+				},
+			),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgNonTerminalBatches, tenantId, 2)),
+		},
+		{
+			name:    "force-ignores-non-terminal-batches",
+			request: model.DeleteTenant{TenantId: tenantId, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 2, 0),
+				},
+			).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				indexPath,
+				test.ElasticCall{
+					ResponseBody: deleteResponse,
+				},
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: nil,
+		},
+		{
+			name:    "tenant-legal-hold",
+			request: model.DeleteTenant{TenantId: tenantId, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"legalHold":{"reason":"litigation","actor":"admin","holdDate":"2021-02-24T18:08:36Z"}}}`,
+						elastic.TenantsIndex, tenantId),
+				},
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantOnLegalHold, tenantId, "deleted")),
+		},
+		{
+			name:    "batches-legal-hold",
+			request: model.DeleteTenant{TenantId: tenantId, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 1),
+				},
+			),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgBatchesOnLegalHold, tenantId, 1)),
+		},
+		{
+			name:    "config-get-error",
+			request: model.DeleteTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseErr: errors.New(elasticErrMsg),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not delete tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name:    "config-delete-error",
+			request: model.DeleteTenant{TenantId: tenantId, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			).AddCall(
+				indexPath,
+				test.ElasticCall{
+					ResponseBody: deleteResponse,
+				},
+			).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseErr: errors.New(elasticErrMsg),
+				},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not delete the configuration of tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name:    "missing-index-deletes-leftover-config",
+			request: model.DeleteTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:        countQuery,
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       missingIndexResponse,
+				},
+			).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"deleted"}`, elastic.TenantsIndex, tenantId),
+				},
+			),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not delete tenant [%s]: [404] index_not_found_exception: no such index [%s-batches]", tenantId, tenantId)),
+		},
+		{
+			name:    "dry-run",
+			request: model.DeleteTenant{TenantId: tenantId, DryRun: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 2, 0),
+				},
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId:       tenantId,
+				"index":              tenantId + "-batches",
+				"docCount":           3,
+				"nonTerminalBatches": 2,
+				"legalHold":          false,
+				"legalHoldBatches":   0,
+				"topics":             []string{},
+			},
+		},
+		{
+			name:    "dry-run-cascade",
+			request: model.DeleteTenant{TenantId: tenantId, DryRun: true, Cascade: true, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			),
+			expectListTopics: true,
+			expectedCode:     http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId:       tenantId,
+				"index":              tenantId + "-batches",
+				"docCount":           3,
+				"nonTerminalBatches": 0,
+				"legalHold":          false,
+				"legalHoldBatches":   0,
+				"topics":             []string{inTopic, notificationTopic},
+			},
+		},
+		{
+			name:    "cascade",
+			request: model.DeleteTenant{TenantId: tenantId, Cascade: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				indexPath,
+				test.ElasticCall{
+					ResponseBody: deleteResponse,
+				},
+			),
+			expectListTopics: true,
+			expectDeletes:    []string{inTopic, notificationTopic},
+			expectedCode:     http.StatusOK,
+			expectedBody:     nil,
+		},
+		{
+			name:    "cascade-list-topics-unauthorized",
+			request: model.DeleteTenant{TenantId: tenantId, Cascade: true, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			),
+			expectListTopics: true,
+			listTopicsResp:   &http.Response{StatusCode: http.StatusForbidden},
+			listTopicsErr:    errors.New("forbidden"),
+			expectedCode:     http.StatusForbidden,
+			expectedBody:     response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg),
+		},
+		{
+			name:    "cascade-delete-topic-error",
+			request: model.DeleteTenant{TenantId: tenantId, Cascade: true, Force: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestBody:  countQuery,
+					ResponseBody: countResponse(3, 0, 0),
+				},
+			),
+			expectListTopics: true,
+			expectDeletes:    []string{inTopic, notificationTopic},
+			deleteTopicsResp: &http.Response{StatusCode: http.StatusNotFound},
+			deleteTopicsErr:  &es.ModelError{Message: "Topic not found"},
+			expectedCode:     http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not delete the streams of tenant [%s]: "+
+				"Unable to delete topic \"%s\": Topic not found\nUnable to delete topic \"%s\": Topic not found",
+				tenantId, inTopic, notificationTopic)),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -64,7 +372,27 @@ func TestDelete(t *testing.T) {
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
-			code, body := Delete(requestId, tenantId, client)
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mockService := test.NewMockService(controller)
+
+			if tc.expectListTopics {
+				mockService.
+					EXPECT().
+					ListTopics(context.Background(), &es.ListTopicsOpts{}).
+					Return(tenantTopics, tc.listTopicsResp, tc.listTopicsErr)
+			}
+			for _, topic := range tc.expectDeletes {
+				if tc.deleteTopicsErr == nil {
+					mockService.EXPECT().DeleteTopic(context.Background(), topic).Return(nil, nil, nil)
+				} else {
+					mockErr := errors.New(tc.deleteTopicsErr.Message)
+					mockService.EXPECT().DeleteTopic(context.Background(), topic).Return(nil, tc.deleteTopicsResp, mockErr)
+					mockService.EXPECT().HandleModelError(mockErr).Return(tc.deleteTopicsErr)
+				}
+			}
+
+			code, body := Delete(context.Background(), requestId, tc.request, client, mockService)
 			if code != tc.expectedCode {
 				t.Error(fmt.Sprintf("Incorrect HTTP code returned. Expected: [%v], actual: [%v]", tc.expectedCode, code))
 			} else if !reflect.DeepEqual(tc.expectedBody, body) {
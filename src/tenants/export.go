@@ -0,0 +1,253 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/param/esparam"
+	"github.com/Alvearie/hri-mgmt-api/streams"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"io"
+	"net/http"
+	"time"
+)
+
+// MIMEApplicationNDJSON is the content type of tenant exports, one JSON record per line
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+// types of the records in a tenant export
+const (
+	recordConfig = "config"
+	recordStream = "stream"
+	recordBatch  = "batch"
+	recordError  = "error"
+)
+
+const (
+	exportPageSize = 500
+	exportScroll   = time.Minute
+	// the longest line an import accepts, batches with large metadata can be big
+	maxImportLineBytes = 10 * 1024 * 1024
+)
+
+const msgImportTooLarge = "the tenant export is larger than the import limit of %d MB"
+
+var errImportTooLarge = errors.New("the import is too large")
+
+// limitedReader fails with errImportTooLarge once more than remaining bytes are read, so a large import isn't buffered
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// one more byte than remaining tells whether the body is larger
+	if r.remaining < int64(len(p)) {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	if int64(n) > r.remaining {
+		n, r.remaining, r.exceeded = int(r.remaining), 0, true
+		return n, errImportTooLarge
+	}
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// exportRecord is one line of a tenant export. Only the field named by the type is set.
+type exportRecord struct {
+	Type   string                  `json:"type"`
+	Config *model.TenantConfig     `json:"config,omitempty"`
+	Stream *model.StreamDefinition `json:"stream,omitempty"`
+	Batch  map[string]interface{}  `json:"batch,omitempty"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+// tenantExport is the content of a tenant export, as read by an import
+type tenantExport struct {
+	Config  *model.TenantConfig
+	Streams []model.StreamDefinition
+	Batches []map[string]interface{}
+}
+
+// Export writes the tenant's configuration, stream definitions and batches to w as NDJSON. Archived batches aren't
+// included. An error body is only returned when nothing was written yet; if reading the batches fails part way
+// through, an error record is written as the last line instead.
+func Export(ctx context.Context, requestId string, request model.ExportTenant, client *elasticsearch.Client, service eventstreams.Service,
+	w io.Writer) (int, interface{}) {
+
+	prefix := "tenants/Export"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Export")
+
+	tenantId := request.TenantId
+	tenantConfig, code, errDetail := getExistingTenantConfig(ctx, requestId, tenantId, "export", client, logger)
+	if errDetail != nil {
+		return code, errDetail
+	}
+
+	definitions, code, errDetail := streams.GetTenantStreams(ctx, requestId, tenantId, service)
+	if errDetail != nil {
+		return code, errDetail
+	}
+
+	// get the first page before writing anything, so the usual error response can still be returned
+	hits, scrollId, elasticErr := searchBatches(ctx, tenantId, client)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not export tenant [%s]", tenantId))
+	}
+	defer func() { clearScroll(scrollId, client) }()
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(exportRecord{Type: recordConfig, Config: &tenantConfig}); err != nil {
+		logger.Errorf("Unable to write the export of tenant [%s]: %s", tenantId, err.Error())
+		return http.StatusOK, nil
+	}
+	for i := range definitions {
+		if err := encoder.Encode(exportRecord{Type: recordStream, Stream: &definitions[i]}); err != nil {
+			logger.Errorf("Unable to write the export of tenant [%s]: %s", tenantId, err.Error())
+			return http.StatusOK, nil
+		}
+	}
+
+	exported := 0
+	for len(hits) > 0 {
+		for _, hit := range hits {
+			esDoc := hit.(map[string]interface{})
+			batch := esDoc["_source"].(map[string]interface{})
+			batch[param.BatchId] = esDoc[esparam.EsDocId]
+			if err := encoder.Encode(exportRecord{Type: recordBatch, Batch: batch}); err != nil {
+				logger.Errorf("Unable to write the export of tenant [%s]: %s", tenantId, err.Error())
+				return http.StatusOK, nil
+			}
+		}
+		exported += len(hits)
+
+		var nextScrollId string
+		hits, nextScrollId, elasticErr = scrollBatches(ctx, scrollId, client)
+		if elasticErr != nil {
+			msg := fmt.Sprintf("Export of tenant [%s] is incomplete, %d batches were exported: %s",
+				tenantId, exported, elasticErr.Error())
+			logger.Errorln(msg)
+			_ = encoder.Encode(exportRecord{Type: recordError, Error: msg})
+			return http.StatusOK, nil
+		}
+		scrollId = nextScrollId
+	}
+
+	logger.Infof("Exported tenant [%s] with %d streams and %d batches", tenantId, len(definitions), exported)
+	return http.StatusOK, nil
+}
+
+func searchBatches(ctx context.Context, tenantId string, client *elasticsearch.Client) ([]interface{}, string, *elastic.ResponseError) {
+	buf, err := elastic.EncodeQueryBody(map[string]interface{}{"sort": []string{"_doc"}})
+	if err != nil {
+		return nil, "", &elastic.ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Search(
+		client.Search.WithContext(ctx),
+		client.Search.WithIndex(elastic.IndexFromTenantId(tenantId)),
+		client.Search.WithBody(buf),
+		client.Search.WithSize(exportPageSize),
+		client.Search.WithScroll(exportScroll),
+	)
+	return scrollPage(res, err)
+}
+
+func scrollBatches(ctx context.Context, scrollId string, client *elasticsearch.Client) ([]interface{}, string, *elastic.ResponseError) {
+	// scroll ids can be long, so they are sent in the body instead of the url
+	buf, err := elastic.EncodeQueryBody(map[string]interface{}{"scroll": exportScroll.String(), "scroll_id": scrollId})
+	if err != nil {
+		return nil, "", &elastic.ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Scroll(
+		client.Scroll.WithContext(ctx),
+		client.Scroll.WithBody(buf),
+	)
+	return scrollPage(res, err)
+}
+
+func scrollPage(res *esapi.Response, err error) ([]interface{}, string, *elastic.ResponseError) {
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return nil, "", elasticErr
+	}
+	scrollId, _ := body["_scroll_id"].(string)
+	hits := body["hits"].(map[string]interface{})["hits"].([]interface{})
+	return hits, scrollId, nil
+}
+
+// clearScroll releases the search context right away, instead of waiting for it to expire
+func clearScroll(scrollId string, client *elasticsearch.Client) {
+	if scrollId == "" {
+		return
+	}
+	buf, err := elastic.EncodeQueryBody(map[string]interface{}{"scroll_id": []string{scrollId}})
+	if err != nil {
+		return
+	}
+	res, err := client.ClearScroll(client.ClearScroll.WithBody(buf))
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+// readExport reads the records of a tenant export. Error records mean the export is incomplete, so they are rejected.
+func readExport(r io.Reader) (tenantExport, error) {
+	data := tenantExport{Streams: []model.StreamDefinition{}, Batches: []map[string]interface{}{}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record exportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return tenantExport{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case record.Type == recordConfig && record.Config != nil:
+			if data.Config != nil {
+				return tenantExport{}, fmt.Errorf("line %d: the export has more than one config", line)
+			}
+			data.Config = record.Config
+		case record.Type == recordStream && record.Stream != nil:
+			data.Streams = append(data.Streams, *record.Stream)
+		case record.Type == recordBatch && record.Batch != nil:
+			data.Batches = append(data.Batches, record.Batch)
+		case record.Type == recordError:
+			return tenantExport{}, fmt.Errorf("line %d: the export is incomplete: %s", line, record.Error)
+		default:
+			return tenantExport{}, fmt.Errorf("line %d: unknown record type '%s' or missing '%s' field",
+				line, record.Type, record.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return tenantExport{}, fmt.Errorf("unable to read the export: %w", err)
+	}
+	if line == 0 {
+		return tenantExport{}, errors.New("the export is empty")
+	}
+	return data, nil
+}
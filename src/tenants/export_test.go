@@ -0,0 +1,260 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	searchPath := fmt.Sprintf("/%s-batches/_search", tenantId)
+	scrollPath := "/_search/scroll"
+	configCall := test.ElasticCall{
+		ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"displayName":"Tenant 123","retentionDays":30}}`,
+			elastic.TenantsIndex, tenantId),
+	}
+	page := func(scrollId string, ids ...string) string {
+		hits := make([]string, 0, len(ids))
+		for _, id := range ids {
+			hits = append(hits, fmt.Sprintf(`{"_id":"%s","_source":{"name":"batch-%s","status":"completed"}}`, id, id))
+		}
+		return fmt.Sprintf(`{"_scroll_id":"%s","hits":{"hits":[%s]}}`, scrollId, strings.Join(hits, ","))
+	}
+
+	tenantTopics := []es.TopicDetail{
+		{Name: "ingest." + tenantId + ".dataIntegrator1.in", Partitions: 2, Configs: es.TopicConfigs{RetentionMs: "86400000"}},
+		{Name: "ingest." + tenantId + ".dataIntegrator1.notification", Partitions: 1},
+		{Name: "ingest.otherTenant.dataIntegrator1.in", Partitions: 1},
+	}
+
+	configLine := `{"type":"config","config":{"displayName":"Tenant 123","retentionDays":30}}` + "\n"
+	streamLine := `{"type":"stream","stream":{"streamId":"dataIntegrator1","numPartitions":2,"retentionMs":86400000}}` + "\n"
+	batchLine := func(id string) string {
+		return fmt.Sprintf(`{"type":"batch","batch":{"id":"%s","name":"batch-%s","status":"completed"}}`, id, id) + "\n"
+	}
+
+	testCases := []struct {
+		name             string
+		transport        *test.FakeTransport
+		listTopicsResp   *http.Response
+		listTopicsErr    error
+		expectListTopics bool
+		expectedCode     int
+		expectedBody     interface{}
+		expectedOutput   string
+	}{
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, configCall,
+			).AddCall(
+				searchPath,
+				test.ElasticCall{
+					RequestQuery: "scroll=60000ms&size=500",
+					RequestBody:  `{"sort":\["_doc"\]}`,
+					ResponseBody: page("scroll1", "batch1", "batch2"),
+				},
+			).AddCall(
+				scrollPath,
+				test.ElasticCall{
+					RequestBody:  `{"scroll":"1m0s","scroll_id":"scroll1"}`,
+					ResponseBody: page("scroll2", "batch3"),
+				},
+			).AddCall(
+				scrollPath,
+				test.ElasticCall{
+					RequestBody:  `{"scroll":"1m0s","scroll_id":"scroll2"}`,
+					ResponseBody: page("scroll2"),
+				},
+			).AddCall(
+				scrollPath,
+				test.ElasticCall{
+					RequestBody:  `{"scroll_id":\["scroll2"\]}`,
+					ResponseBody: `{"succeeded":true}`,
+				},
+			),
+			listTopicsResp:   &http.Response{StatusCode: http.StatusOK},
+			expectListTopics: true,
+			expectedCode:     http.StatusOK,
+			expectedOutput:   configLine + streamLine + batchLine("batch1") + batchLine("batch2") + batchLine("batch3"),
+		},
+		{
+			name: "tenant-not-found",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusNotFound},
+			),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantNotFound, tenantId)),
+		},
+		{
+			name: "list-topics-unauthorized",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, configCall,
+			),
+			listTopicsResp:   &http.Response{StatusCode: http.StatusForbidden},
+			listTopicsErr:    errors.New("forbidden"),
+			expectListTopics: true,
+			expectedCode:     http.StatusForbidden,
+			expectedBody:     response.NewErrorDetail(requestId, eventstreams.UnauthorizedMsg),
+		},
+		{
+			name: "search-error",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, configCall,
+			).AddCall(
+				searchPath, test.ElasticCall{ResponseErr: errors.New("elasticErrMsg")},
+			),
+			listTopicsResp:   &http.Response{StatusCode: http.StatusOK},
+			expectListTopics: true,
+			expectedCode:     http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(
+				"Could not export tenant [%s]: [500] elasticsearch client error: elasticErrMsg", tenantId)),
+		},
+		{
+			name: "scroll-error",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, configCall,
+			).AddCall(
+				searchPath, test.ElasticCall{ResponseBody: page("scroll1", "batch1")},
+			).AddCall(
+				scrollPath,
+				test.ElasticCall{
+					ResponseStatusCode: http.StatusNotFound,
+					ResponseBody:       `{"error":{"type":"search_context_missing_exception","reason":"No search context found"},"status":404}`,
+				},
+			).AddCall(
+				scrollPath,
+				test.ElasticCall{
+					RequestBody:  `{"scroll_id":\["scroll1"\]}`,
+					ResponseBody: `{"succeeded":true}`,
+				},
+			),
+			listTopicsResp:   &http.Response{StatusCode: http.StatusOK},
+			expectListTopics: true,
+			expectedCode:     http.StatusOK,
+			expectedOutput: configLine + streamLine + batchLine("batch1") +
+				`{"type":"error","error":"Export of tenant [tenant123] is incomplete, 1 batches were exported: ` +
+				`search_context_missing_exception: No search context found"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mockService := test.NewMockService(controller)
+			if tc.expectListTopics {
+				mockService.
+					EXPECT().
+					ListTopics(gomock.Any(), &es.ListTopicsOpts{}).
+					Return(tenantTopics, tc.listTopicsResp, tc.listTopicsErr)
+			}
+
+			var output bytes.Buffer
+			code, body := Export(context.Background(), requestId, model.ExportTenant{TenantId: tenantId}, client, mockService, &output)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, body)
+			assert.Equal(t, tc.expectedOutput, output.String())
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestReadExport(t *testing.T) {
+	retentionDays := 30
+	numPartitions := int64(2)
+	retentionMs := 86400000
+
+	testCases := []struct {
+		name        string
+		input       string
+		expected    tenantExport
+		expectedErr string
+	}{
+		{
+			name: "all-record-types",
+			input: `{"type":"config","config":{"retentionDays":30}}` + "\n\n" +
+				`{"type":"stream","stream":{"streamId":"dataIntegrator1","numPartitions":2,"retentionMs":86400000}}` + "\n" +
+				`{"type":"batch","batch":{"id":"batch1"}}`,
+			expected: tenantExport{
+				Config: &model.TenantConfig{RetentionDays: &retentionDays},
+				Streams: []model.StreamDefinition{
+					{StreamId: "dataIntegrator1", NumPartitions: &numPartitions, RetentionMs: &retentionMs},
+				},
+				Batches: []map[string]interface{}{{param.BatchId: "batch1"}},
+			},
+		},
+		{
+			name:        "empty",
+			input:       "",
+			expectedErr: "the export is empty",
+		},
+		{
+			name:        "two-configs",
+			input:       `{"type":"config","config":{}}` + "\n" + `{"type":"config","config":{}}`,
+			expectedErr: "line 2: the export has more than one config",
+		},
+		{
+			name:        "missing-field",
+			input:       `{"type":"batch"}`,
+			expectedErr: "line 1: unknown record type 'batch' or missing 'batch' field",
+		},
+		{
+			name:        "unknown-type",
+			input:       `{"type":"tenant","config":{}}`,
+			expectedErr: "line 1: unknown record type 'tenant' or missing 'tenant' field",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := readExport(strings.NewReader(tc.input))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
@@ -6,19 +6,21 @@
 package tenants
 
 import (
+	"context"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/elastic/go-elasticsearch/v7"
 	"net/http"
 )
 
-func Get(requestId string, client *elasticsearch.Client) (int, interface{}) {
+func Get(ctx context.Context, requestId string, client *elasticsearch.Client) (int, interface{}) {
 	prefix := "tenants/Get"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 	logger.Debugln("Start Tenants Get (All)")
 
 	//Use elastic to return the list of indices
-	res, err := client.Cat.Indices(client.Cat.Indices.WithH("index"), client.Cat.Indices.WithFormat("json"))
+	res, err := client.Cat.Indices(client.Cat.Indices.WithContext(ctx), client.Cat.Indices.WithH("index"),
+		client.Cat.Indices.WithFormat("json"))
 
 	body, elasticErr := elastic.DecodeBodyFromJsonArray(res, err)
 	if elasticErr != nil {
@@ -6,6 +6,7 @@
 package tenants
 
 import (
+	"context"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 
@@ -15,14 +16,14 @@ import (
 	"net/http"
 )
 
-func GetById(requestId string, tenantId string, client *elasticsearch.Client) (int, interface{}) {
+func GetById(ctx context.Context, requestId string, tenantId string, client *elasticsearch.Client) (int, interface{}) {
 	prefix := "tenant/GetById"
-	var logger = logwrapper.GetMyLogger(requestId, prefix)
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, tenantId)
 	logger.Debugln("Start Tenants Get By ID")
 
 	// Query elastic for information on the tenant
 	index := elastic.IndexFromTenantId(tenantId)
-	var res, err2 = client.Cat.Indices(client.Cat.Indices.WithIndex(index),
+	var res, err2 = client.Cat.Indices(client.Cat.Indices.WithContext(ctx), client.Cat.Indices.WithIndex(index),
 		client.Cat.Indices.WithFormat("json"))
 
 	resultBody, elasticErr := elastic.DecodeBodyFromJsonArray(res, err2)
@@ -6,6 +6,7 @@
 package tenants
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
@@ -135,7 +136,7 @@ func TestGetById(t *testing.T) {
 		}
 
 		t.Run(tc.name, func(t *testing.T) {
-			actualCode, actualBody := GetById(tc.requestId, tc.tenantId, client)
+			actualCode, actualBody := GetById(context.Background(), tc.requestId, tc.tenantId, client)
 
 			tc.transport.VerifyCalls()
 			if actualCode != tc.expectedCode || !reflect.DeepEqual(tc.expectedBody, actualBody) {
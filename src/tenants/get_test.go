@@ -6,6 +6,7 @@
 package tenants
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
@@ -73,7 +74,7 @@ func TestGet(t *testing.T) {
 				t.Error(err)
 			}
 
-			code, body := Get(requestId, esClient)
+			code, body := Get(context.Background(), requestId, esClient)
 			if code != tt.expectedCode {
 				t.Errorf("Get() = %d, expected %d", code, tt.expectedCode)
 			}
@@ -7,14 +7,23 @@
 package tenants
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
 	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
 	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/labstack/echo/v4"
+	"io"
+	"math"
 	"net/http"
 )
 
@@ -23,35 +32,76 @@ type Handler interface {
 	Get(echo.Context) error
 	GetById(echo.Context) error
 	Delete(echo.Context) error
+	PutConfig(echo.Context) error
+	GetConfig(echo.Context) error
+	Suspend(echo.Context) error
+	Resume(echo.Context) error
+	Purge(echo.Context) error
+	Export(echo.Context) error
+	Import(echo.Context) error
 }
 
 // This struct is designed to make unit testing easier. It has function references for the calls to backend
 // logic and other methods that reach out to external services like checking Elastic IAM credentials.
 type theHandler struct {
 	config          config.Config
-	checkElasticIAM func(string, string, elastic.ResourceControllerService) (int, error)
+	checkElasticIAM func(context.Context, string, string, elastic.ResourceControllerService) (int, error)
+	jwtValidator    auth.Validator // only set in the jwt admin auth mode
 	// The Elastic Client creation doesn't have a method reference, because it does not reach out to the Elastic
 	// cluster until it's used. So, we don't need to mock it for unit testing.
-	create  func(string, string, *elasticsearch.Client) (int, interface{})
-	get     func(string, *elasticsearch.Client) (int, interface{})
-	getById func(string, string, *elasticsearch.Client) (int, interface{})
-	delete  func(string, string, *elasticsearch.Client) (int, interface{})
+	create    func(context.Context, string, string, *elasticsearch.Client) (int, interface{})
+	get       func(context.Context, string, *elasticsearch.Client) (int, interface{})
+	getById   func(context.Context, string, string, *elasticsearch.Client) (int, interface{})
+	delete    func(context.Context, string, model.DeleteTenant, *elasticsearch.Client, eventstreams.Service) (int, interface{})
+	putConfig func(context.Context, string, model.TenantConfig, *elasticsearch.Client) (int, interface{})
+	getConfig func(context.Context, string, string, *elasticsearch.Client) (int, interface{})
+	suspend   func(context.Context, string, model.SuspendTenant, string, *elasticsearch.Client, kafka.Writer) (int, interface{})
+	resume    func(context.Context, string, model.ResumeTenant, string, *elasticsearch.Client, kafka.Writer) (int, interface{})
+	purge     func(context.Context, string, model.PurgeTenant, int, *elasticsearch.Client, archive.Store) (int, interface{})
+	export    func(context.Context, string, model.ExportTenant, *elasticsearch.Client, eventstreams.Service, io.Writer) (int, interface{})
+	importFn  func(context.Context, string, model.ImportTenant, tenantExport, bool, *elasticsearch.Client, eventstreams.Service) (int, interface{})
 }
 
-func NewHandler(config config.Config) Handler {
+func NewHandler(conf config.Config) Handler {
+	var jwtValidator auth.Validator
+	if conf.AdminAuthMode == config.AdminAuthModeJwt {
+		jwtValidator = auth.NewValidator(conf)
+	}
 	return &theHandler{
-		config:          config,
+		config:          conf,
 		checkElasticIAM: elastic.CheckElasticIAM,
+		jwtValidator:    jwtValidator,
 		create:          Create,
 		get:             Get,
 		getById:         GetById,
 		delete:          Delete,
+		putConfig:       PutConfig,
+		getConfig:       GetConfig,
+		suspend:         Suspend,
+		resume:          Resume,
+		purge:           Purge,
+		export:          Export,
+		importFn:        Import,
+	}
+}
+
+// checkAdmin authorizes the caller with the configured admin auth mode. In the iam mode the caller's IAM token must
+// have access to the Elasticsearch service instance; in the jwt mode it must be an HRI token, or client certificate,
+// with the hri_admin scope.
+func (h *theHandler) checkAdmin(requestId string, request *http.Request) (int, error) {
+	if h.config.AdminAuthMode != config.AdminAuthModeJwt {
+		service := elastic.CreateResourceControllerService()
+		return h.checkElasticIAM(request.Context(), h.config.ElasticServiceCrn, request.Header.Get(echo.HeaderAuthorization), service)
 	}
+	// auth-disabled only applies to the batch endpoints, the admin endpoints are always authorized
+	if _, errResp := h.jwtValidator.GetValidatedAdminClaims(requestId, request); errResp != nil {
+		return errResp.Code, errors.New(errResp.Body.ErrorDescription)
+	}
+	return http.StatusOK, nil
 }
 
 func (h *theHandler) Create(c echo.Context) error {
 	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
-	authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
 	prefix := "tenants/handler/create"
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
@@ -67,8 +117,7 @@ func (h *theHandler) Create(c echo.Context) error {
 	}
 
 	// check bearer token
-	service := elastic.CreateResourceControllerService()
-	code, err := h.checkElasticIAM(h.config.ElasticServiceCrn, authHeader, service)
+	code, err := h.checkAdmin(requestId, c.Request())
 	if err != nil {
 		logger.Errorln(err.Error())
 		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
@@ -80,7 +129,7 @@ func (h *theHandler) Create(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
 	}
 
-	return c.JSON(h.create(requestId, request.TenantId, esClient))
+	return c.JSON(h.create(c.Request().Context(), requestId, request.TenantId, esClient))
 }
 
 func (h *theHandler) Get(c echo.Context) error {
@@ -89,11 +138,9 @@ func (h *theHandler) Get(c echo.Context) error {
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
 	logger.Debugln("Start Tenant_Get Handler")
-	authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
 
 	// check bearer token
-	service := elastic.CreateResourceControllerService()
-	code, err := h.checkElasticIAM(h.config.ElasticServiceCrn, authHeader, service)
+	code, err := h.checkAdmin(requestId, c.Request())
 	if err != nil {
 		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
 	}
@@ -105,7 +152,7 @@ func (h *theHandler) Get(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
 	}
 
-	return c.JSON(h.get(requestId, esClient))
+	return c.JSON(h.get(c.Request().Context(), requestId, esClient))
 }
 
 func (h *theHandler) GetById(c echo.Context) error {
@@ -114,11 +161,9 @@ func (h *theHandler) GetById(c echo.Context) error {
 	var logger = logwrapper.GetMyLogger(requestId, prefix)
 
 	logger.Debugln("Start Tenant_GetById Handler")
-	authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
 
 	// check bearer token
-	service := elastic.CreateResourceControllerService()
-	code, err := h.checkElasticIAM(h.config.ElasticServiceCrn, authHeader, service)
+	code, err := h.checkAdmin(requestId, c.Request())
 	if err != nil {
 		msg := err.Error()
 		logger.Errorln(msg)
@@ -133,7 +178,7 @@ func (h *theHandler) GetById(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, msg))
 	}
 
-	return c.JSON(h.getById(requestId, tenantId, esClient))
+	return c.JSON(h.getById(c.Request().Context(), requestId, tenantId, esClient))
 }
 
 func (h *theHandler) Delete(c echo.Context) error {
@@ -144,12 +189,19 @@ func (h *theHandler) Delete(c echo.Context) error {
 	authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
 	logger.Debugln("Start Tenant_Delete Handler")
 
-	// Extract tenantId param
-	tenantId := c.Param(param.TenantId)
+	// bind & validate request params
+	var request model.DeleteTenant
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
 
 	// check bearer token
-	service := elastic.CreateResourceControllerService()
-	code, err := h.checkElasticIAM(h.config.ElasticServiceCrn, authHeader, service)
+	code, err := h.checkAdmin(requestId, c.Request())
 	if err != nil {
 		msg := err.Error()
 		logger.Errorln(msg)
@@ -161,10 +213,326 @@ func (h *theHandler) Delete(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
 	}
 
-	code, body := h.delete(requestId, tenantId, esClient)
+	// in the iam admin auth mode the tenant's streams are deleted with the caller's bearer token, which Event Streams
+	// authorizes separately
+	streamsService := eventstreams.CreateServiceForCaller(h.config, authHeader)
+
+	code, body := h.delete(c.Request().Context(), requestId, request, esClient, streamsService)
 	if body == nil {
 		return c.NoContent(code)
 	} else {
 		return c.JSON(code, body)
 	}
 }
+
+func (h *theHandler) PutConfig(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	prefix := "tenants/handler/putConfig"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	// bind & validate request body
+	var request model.TenantConfig
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	return c.JSON(h.putConfig(c.Request().Context(), requestId, request, esClient))
+}
+
+func (h *theHandler) GetConfig(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	prefix := "tenants/handler/getConfig"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	var request model.GetTenantConfig
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	return c.JSON(h.getConfig(c.Request().Context(), requestId, request.TenantId, esClient))
+}
+
+func (h *theHandler) Suspend(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	prefix := "tenants/handler/suspend"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	var request model.SuspendTenant
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	kafkaWriter, err := kafka.NewWriterFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+	defer kafkaWriter.Close()
+
+	return c.JSON(h.suspend(c.Request().Context(), requestId, request, h.config.TenantNotificationTopic, esClient, kafkaWriter))
+}
+
+func (h *theHandler) Resume(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	prefix := "tenants/handler/resume"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	var request model.ResumeTenant
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	kafkaWriter, err := kafka.NewWriterFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+	defer kafkaWriter.Close()
+
+	return c.JSON(h.resume(c.Request().Context(), requestId, request, h.config.TenantNotificationTopic, esClient, kafkaWriter))
+}
+
+func (h *theHandler) Purge(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	prefix := "tenants/handler/purge"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	var request model.PurgeTenant
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	store, err := archive.NewStoreFromConfig(h.config)
+	if err != nil {
+		msg := fmt.Sprintf("Batches can't be purged: %s", err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	return c.JSON(h.purge(c.Request().Context(), requestId, request, h.config.BatchRetentionDays, esClient, store))
+}
+
+func (h *theHandler) Export(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
+	prefix := "tenants/handler/export"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	var request model.ExportTenant
+	if err := c.Bind(&request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// in the iam admin auth mode the stream definitions are read with the caller's bearer token, which Event Streams
+	// authorizes separately
+	streamsService := eventstreams.CreateServiceForCaller(h.config, authHeader)
+
+	// the export is written straight to the response, a body is only returned if it failed before anything was written
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	code, body := h.export(c.Request().Context(), requestId, request, esClient, streamsService, c.Response())
+	if body != nil {
+		c.Response().Header().Del(echo.HeaderContentType)
+		return c.JSON(code, body)
+	}
+	return nil
+}
+
+func (h *theHandler) Import(c echo.Context) error {
+	requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+	authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
+	prefix := "tenants/handler/import"
+	var logger = logwrapper.GetMyLogger(requestId, prefix)
+
+	// the body is NDJSON, which the binder doesn't support, so only the path and query parameters are bound
+	var request model.ImportTenant
+	binder := &echo.DefaultBinder{}
+	err := binder.BindPathParams(c, &request)
+	if err == nil {
+		err = binder.BindQueryParams(c, &request)
+	}
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+	if err := c.Validate(request); err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// check bearer token, before the body is read
+	code, err := h.checkAdmin(requestId, c.Request())
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(code, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	limit := int64(h.config.ImportMaxMegabytes) * 1024 * 1024
+	if limit == 0 {
+		limit = math.MaxInt64
+	}
+	body := &limitedReader{reader: c.Request().Body, remaining: limit}
+	var data tenantExport
+	if c.Request().ContentLength > limit {
+		body.exceeded = true
+	} else {
+		data, err = readExport(body)
+	}
+	// the end of the export is cut off when it's too large, which is reported instead of the invalid last line
+	if body.exceeded {
+		msg := fmt.Sprintf(msgImportTooLarge, h.config.ImportMaxMegabytes)
+		logger.Errorln(msg)
+		return c.JSON(http.StatusRequestEntityTooLarge, response.NewErrorDetail(requestId, msg))
+	} else if err != nil {
+		msg := fmt.Sprintf("Invalid tenant export: %s", err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
+	}
+	if err := validateExport(data, request, c); err != nil {
+		msg := fmt.Sprintf("Invalid tenant export: %s", err.Error())
+		logger.Errorln(msg)
+		return c.JSON(http.StatusBadRequest, response.NewErrorDetail(requestId, msg))
+	}
+
+	esClient, err := elastic.ClientFromConfig(h.config)
+	if err != nil {
+		logger.Errorln(err.Error())
+		return c.JSON(http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error()))
+	}
+
+	// in the iam admin auth mode the streams are created with the caller's bearer token, which Event Streams authorizes
+	// separately
+	streamsService := eventstreams.CreateServiceForCaller(h.config, authHeader)
+
+	return c.JSON(h.importFn(c.Request().Context(), requestId, request, data, h.config.Validation, esClient, streamsService))
+}
+
+// validateExport applies the same validation to the imported configuration and streams as their own endpoints
+func validateExport(data tenantExport, request model.ImportTenant, c echo.Context) error {
+	if data.Config != nil {
+		data.Config.TenantId = request.TenantId
+		if err := c.Validate(*data.Config); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	for _, definition := range data.Streams {
+		if err := c.Validate(definition); err != nil {
+			return fmt.Errorf("stream [%s]: %w", definition.StreamId, err)
+		}
+	}
+	if request.PreserveIds {
+		for i, batch := range data.Batches {
+			if id, ok := batch[param.BatchId].(string); !ok || id == "" {
+				return fmt.Errorf("batch %d has no id, it's required to preserve the ids", i+1)
+			}
+		}
+	}
+	return nil
+}
@@ -7,19 +7,28 @@
 package tenants
 
 import (
+	"context"
 	"errors"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
+	"github.com/Alvearie/hri-mgmt-api/common/auth"
 	"github.com/Alvearie/hri-mgmt-api/common/config"
 	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
 	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
 	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
 	"github.com/Alvearie/hri-mgmt-api/common/test"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -34,6 +43,32 @@ func TestNewHandler(t *testing.T) {
 	assert.Equal(t, reflect.ValueOf(Get), reflect.ValueOf(handler.get))
 	assert.Equal(t, reflect.ValueOf(Delete), reflect.ValueOf(handler.delete))
 	assert.Equal(t, reflect.ValueOf(GetById), reflect.ValueOf(handler.getById))
+	assert.Equal(t, reflect.ValueOf(PutConfig), reflect.ValueOf(handler.putConfig))
+	assert.Equal(t, reflect.ValueOf(GetConfig), reflect.ValueOf(handler.getConfig))
+	assert.Equal(t, reflect.ValueOf(Suspend), reflect.ValueOf(handler.suspend))
+	assert.Equal(t, reflect.ValueOf(Resume), reflect.ValueOf(handler.resume))
+	assert.Equal(t, reflect.ValueOf(Purge), reflect.ValueOf(handler.purge))
+	assert.Equal(t, reflect.ValueOf(Export), reflect.ValueOf(handler.export))
+	assert.Equal(t, reflect.ValueOf(Import), reflect.ValueOf(handler.importFn))
+	assert.Nil(t, handler.jwtValidator)
+
+	config.AdminAuthMode = "jwt"
+	handler = NewHandler(config).(*theHandler)
+	assert.NotNil(t, handler.jwtValidator)
+}
+
+// Fake for the auth.Validator interface; just returns the desired values
+type fakeAuthValidator struct {
+	claims  auth.HriClaims
+	errResp *response.ErrorDetailResponse
+}
+
+func (f fakeAuthValidator) GetValidatedClaims(_ string, _ *http.Request, _ string) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
+}
+
+func (f fakeAuthValidator) GetValidatedAdminClaims(_ string, _ *http.Request) (auth.HriClaims, *response.ErrorDetailResponse) {
+	return f.claims, f.errResp
 }
 
 func Test_myHandler_Create(t *testing.T) {
@@ -63,10 +98,10 @@ func Test_myHandler_Create(t *testing.T) {
 			name: "happy path",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				create: func(string, string, *elasticsearch.Client) (int, interface{}) {
+				create: func(context.Context, string, string, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusCreated, map[string]interface{}{"tenantId": "1_a-tenant-id"}
 				},
 			},
@@ -96,10 +131,10 @@ func Test_myHandler_Create(t *testing.T) {
 			name: "400 on create",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				create: func(string, string, *elasticsearch.Client) (int, interface{}) {
+				create: func(context.Context, string, string, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusBadRequest, map[string]interface{}{"errorEventId": "test-request-id", "errorDescription": "Unable to create tenant"}
 				},
 			},
@@ -111,7 +146,7 @@ func Test_myHandler_Create(t *testing.T) {
 			name: "401 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 401, errors.New("unauthorized")
 				},
 			},
@@ -123,7 +158,7 @@ func Test_myHandler_Create(t *testing.T) {
 			name: "500 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 500, errors.New("500 internal server error")
 				},
 			},
@@ -135,7 +170,7 @@ func Test_myHandler_Create(t *testing.T) {
 			name: "500 on bad config invalid elastic url",
 			handler: theHandler{
 				config: badConf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
 			},
@@ -181,6 +216,13 @@ func Test_myHandler_Get(t *testing.T) {
 		ElasticCert:       "bXlFbGFzdGljQ2VydA==", // myElasticCert
 		ElasticServiceCrn: "myElasticCrn",
 	}
+	jwtConf := config.Config{
+		ElasticUrl:      "https://elastic.url",
+		ElasticUsername: "myElasticUser",
+		ElasticPassword: "myElasticPassword",
+		ElasticCert:     "bXlFbGFzdGljQ2VydA==", // myElasticCert
+		AdminAuthMode:   config.AdminAuthModeJwt,
+	}
 
 	id1 := make(map[string]interface{})
 	id2 := make(map[string]interface{})
@@ -203,10 +245,10 @@ func Test_myHandler_Get(t *testing.T) {
 			name: "happy path",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				get: func(string, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusOK, map[string]interface{}{"results": indices}
 				},
 			},
@@ -217,7 +259,7 @@ func Test_myHandler_Get(t *testing.T) {
 			name: "401 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 401, errors.New("unauthorized")
 				},
 			},
@@ -228,7 +270,7 @@ func Test_myHandler_Get(t *testing.T) {
 			name: "500 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 500, errors.New("500 internal server error")
 				},
 			},
@@ -239,21 +281,43 @@ func Test_myHandler_Get(t *testing.T) {
 			name: "500 on bad config invalid elastic url",
 			handler: theHandler{
 				config: badConf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
 			},
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: "{\"errorEventId\":\"test-request-id\",\"errorDescription\":\"cannot create client: cannot parse url: parse \\\"https://elastic.invalid  .url\\\": invalid character \\\" \\\" in host name\"}\n",
 		},
+		{
+			name: "jwt admin auth mode",
+			handler: theHandler{
+				config:       jwtConf,
+				jwtValidator: fakeAuthValidator{claims: auth.HriClaims{Scope: auth.HriAdmin}},
+				get: func(context.Context, string, *elasticsearch.Client) (int, interface{}) {
+					return http.StatusOK, map[string]interface{}{"results": indices}
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"results\":[{\"id\":\"pi001\"},{\"id\":\"pi002\"},{\"id\":\"qatenant\"}]}\n",
+		},
+		{
+			name: "403 in jwt admin auth mode without the hri_admin scope",
+			handler: theHandler{
+				config: jwtConf,
+				jwtValidator: fakeAuthValidator{errResp: response.NewErrorDetailResponse(http.StatusForbidden,
+					"test-request-id", "Unauthorized admin access. 'hri_admin' is not included in the authorized scopes: hri_consumer.")},
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: "{\"errorEventId\":\"test-request-id\",\"errorDescription\":\"Unauthorized admin access. 'hri_admin' is not included in the authorized scopes: hri_consumer.\"}\n",
+		},
 		{
 			name: "500 on get",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				get: func(string, *elasticsearch.Client) (int, interface{}) {
+				get: func(context.Context, string, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusInternalServerError, map[string]interface{}{"errorEventId": "test-request-id", "errorDescription": "Could not retrieve tenants"}
 				},
 			},
@@ -301,6 +365,7 @@ func Test_myHandler_Delete(t *testing.T) {
 		name         string
 		handler      theHandler
 		tenantId     string
+		query        string
 		expectedCode int
 		expectedBody interface{}
 	}{
@@ -308,10 +373,10 @@ func Test_myHandler_Delete(t *testing.T) {
 			name: "happy path",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				delete: func(string, string, *elasticsearch.Client) (int, interface{}) {
+				delete: func(context.Context, string, model.DeleteTenant, *elasticsearch.Client, eventstreams.Service) (int, interface{}) {
 					return http.StatusOK, nil
 				},
 			},
@@ -319,11 +384,38 @@ func Test_myHandler_Delete(t *testing.T) {
 			expectedCode: http.StatusOK,
 			expectedBody: "",
 		},
+		{
+			name: "cascade dry run",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				delete: func(_ context.Context, _ string, request model.DeleteTenant, _ *elasticsearch.Client, _ eventstreams.Service) (int, interface{}) {
+					assert.Equal(t, model.DeleteTenant{TenantId: "1_a-tenant-id", Cascade: true, DryRun: true}, request)
+					return http.StatusOK, map[string]interface{}{"docCount": 1}
+				},
+			},
+			tenantId:     "1_a-tenant-id",
+			query:        "?cascade=true&dryRun=true",
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"docCount\":1}\n",
+		},
+		{
+			name: "bad force param",
+			handler: theHandler{
+				config: conf,
+			},
+			tenantId:     "1_a-tenant-id",
+			query:        "?force=notABool",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"test-request-id\",\"errorDescription\":\"code=400, message=strconv.ParseBool: parsing \\\"notABool\\\": invalid syntax, internal=strconv.ParseBool: parsing \\\"notABool\\\": invalid syntax\"}\n",
+		},
 		{
 			name: "Unauthorized error 401 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 401, errors.New("unauthorized")
 				},
 			},
@@ -335,7 +427,7 @@ func Test_myHandler_Delete(t *testing.T) {
 			name: "500 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 500, errors.New("500 internal server error")
 				},
 			},
@@ -347,7 +439,7 @@ func Test_myHandler_Delete(t *testing.T) {
 			name: "500 on bad config invalid elastic url",
 			handler: theHandler{
 				config: badConf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
 			},
@@ -359,10 +451,10 @@ func Test_myHandler_Delete(t *testing.T) {
 			name: "Unable to Delete error 400 on delete",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				delete: func(string, string, *elasticsearch.Client) (int, interface{}) {
+				delete: func(context.Context, string, model.DeleteTenant, *elasticsearch.Client, eventstreams.Service) (int, interface{}) {
 					return http.StatusBadRequest, map[string]interface{}{"errorEventId": "test-request-id", "errorDescription": "Unable to delete tenant"}
 				},
 			},
@@ -375,7 +467,7 @@ func Test_myHandler_Delete(t *testing.T) {
 	e := test.GetTestServer()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			request := httptest.NewRequest(http.MethodDelete, "/hri/tenants/"+tt.tenantId, nil)
+			request := httptest.NewRequest(http.MethodDelete, "/hri/tenants/"+tt.tenantId+tt.query, nil)
 			context, recorder := test.PrepareHeadersContextRecorder(request, e)
 			request.Header.Set(echo.HeaderXRequestID, "test-request-id")
 			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
@@ -427,10 +519,10 @@ func Test_myHandler_GetById(t *testing.T) {
 			name: "happy path",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
-				getById: func(string, string, *elasticsearch.Client) (int, interface{}) {
+				getById: func(context.Context, string, string, *elasticsearch.Client) (int, interface{}) {
 					return http.StatusOK, map[string]interface{}{"results": indices}
 				},
 			},
@@ -442,7 +534,7 @@ func Test_myHandler_GetById(t *testing.T) {
 			name: "unauthorized error 401 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 401, errors.New("elastic IAM authentication returned 401")
 				},
 			},
@@ -454,7 +546,7 @@ func Test_myHandler_GetById(t *testing.T) {
 			name: "Internal Server error 500 on iam check",
 			handler: theHandler{
 				config: conf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 500, errors.New("500 internal server error")
 				},
 			},
@@ -466,7 +558,7 @@ func Test_myHandler_GetById(t *testing.T) {
 			name: "Bad config invalid elastic url returns 500 Internal Server Error",
 			handler: theHandler{
 				config: badConf,
-				checkElasticIAM: func(string, string, elastic.ResourceControllerService) (int, error) {
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
 					return 200, nil
 				},
 			},
@@ -495,3 +587,667 @@ func Test_myHandler_GetById(t *testing.T) {
 		})
 	}
 }
+
+func Test_myHandler_PutConfig(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:        "https://elastic.url",
+		ElasticUsername:   "myElasticUser",
+		ElasticPassword:   "myElasticPassword",
+		ElasticCert:       "bXlFbGFzdGljQ2VydA==", // myElasticCert
+		ElasticServiceCrn: "myElasticCrn",
+	}
+
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-130"
+
+	tests := []struct {
+		name         string
+		handler      theHandler
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				putConfig: func(_ context.Context, _ string, tenantConfig model.TenantConfig, _ *elasticsearch.Client) (int, interface{}) {
+					assert.Equal(t, validTenantId, tenantConfig.TenantId)
+					assert.Equal(t, []string{"claims"}, tenantConfig.AllowedDataTypes)
+					assert.Equal(t, 3600, *tenantConfig.BatchTimeoutSeconds)
+					return http.StatusOK, map[string]interface{}{param.TenantId: validTenantId}
+				},
+			},
+			requestBody:  `{"allowedDataTypes":["claims"],"batchTimeoutSeconds":3600}`,
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+		{
+			name:         "bad request body",
+			handler:      theHandler{config: conf},
+			requestBody:  `{"allowedDataTypes":"claims"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"invalid request param \\\"allowedDataTypes\\\": expected type []string, but received type string\"}\n",
+		},
+		{
+			name:         "batch timeout too short",
+			handler:      theHandler{config: conf},
+			requestBody:  `{"batchTimeoutSeconds":10}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"invalid request arguments:\\n- batchTimeoutSeconds (json field in request body) must be 60 or greater\"}\n",
+		},
+		{
+			name: "unauthorized error 401 on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 401, errors.New("elastic IAM authentication returned 401")
+				},
+			},
+			requestBody:  `{}`,
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"elastic IAM authentication returned 401\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/hri/tenants/"+validTenantId+"/config", strings.NewReader(tt.requestBody))
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/config")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(validTenantId)
+
+			if assert.NoError(t, tt.handler.PutConfig(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_myHandler_GetConfig(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:        "https://elastic.url",
+		ElasticUsername:   "myElasticUser",
+		ElasticPassword:   "myElasticPassword",
+		ElasticCert:       "bXlFbGFzdGljQ2VydA==", // myElasticCert
+		ElasticServiceCrn: "myElasticCrn",
+	}
+
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-131"
+
+	tests := []struct {
+		name         string
+		handler      theHandler
+		tenantId     string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				getConfig: func(_ context.Context, _ string, tenantId string, _ *elasticsearch.Client) (int, interface{}) {
+					return http.StatusOK, map[string]interface{}{param.TenantId: tenantId, "retentionDays": 30}
+				},
+			},
+			tenantId:     validTenantId,
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"retentionDays\":30,\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+		{
+			name: "config not found",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				getConfig: func(_ context.Context, requestId string, tenantId string, _ *elasticsearch.Client) (int, interface{}) {
+					return http.StatusNotFound, map[string]interface{}{"errorEventId": requestId, "errorDescription": "No configuration found for tenant [" + tenantId + "]"}
+				},
+			},
+			tenantId:     validTenantId,
+			expectedCode: http.StatusNotFound,
+			expectedBody: "{\"errorDescription\":\"No configuration found for tenant [" + validTenantId + "]\",\"errorEventId\":\"" + requestId + "\"}\n",
+		},
+		{
+			name: "Internal Server error 500 on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 500, errors.New("500 internal server error")
+				},
+			},
+			tenantId:     validTenantId,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"500 internal server error\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/hri/tenants/"+tt.tenantId+"/config", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/config")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(tt.tenantId)
+
+			if assert.NoError(t, tt.handler.GetConfig(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_myHandler_Suspend(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:              "https://elastic.url",
+		ElasticUsername:         "myElasticUser",
+		ElasticPassword:         "myElasticPassword",
+		ElasticCert:             "bXlFbGFzdGljQ2VydA==", // myElasticCert
+		ElasticServiceCrn:       "myElasticCrn",
+		TenantNotificationTopic: "hri.tenants.notification",
+	}
+
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-132"
+
+	tests := []struct {
+		name         string
+		handler      theHandler
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				suspend: func(_ context.Context, _ string, request model.SuspendTenant, topic string, _ *elasticsearch.Client, _ kafka.Writer) (int, interface{}) {
+					assert.Equal(t, model.SuspendTenant{TenantId: validTenantId, Reason: "contract expired"}, request)
+					assert.Equal(t, conf.TenantNotificationTopic, topic)
+					return http.StatusOK, map[string]interface{}{param.TenantId: validTenantId, "status": "suspended"}
+				},
+			},
+			requestBody:  `{"reason":"contract expired"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"status\":\"suspended\",\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+		{
+			name:         "invalid reason",
+			handler:      theHandler{config: conf},
+			requestBody:  `{"reason":"{bad}"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"invalid request arguments:\\n- reason (json field in request body) must not contain the following characters: \\\"=\\u003c\\u003e[]{}\"}\n",
+		},
+		{
+			name: "unauthorized error 401 on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 401, errors.New("elastic IAM authentication returned 401")
+				},
+			},
+			requestBody:  `{}`,
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"elastic IAM authentication returned 401\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/hri/tenants/"+validTenantId+"/action/suspend", strings.NewReader(tt.requestBody))
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/action/suspend")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(validTenantId)
+
+			if assert.NoError(t, tt.handler.Suspend(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_myHandler_Resume(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:              "https://elastic.url",
+		ElasticUsername:         "myElasticUser",
+		ElasticPassword:         "myElasticPassword",
+		ElasticCert:             "bXlFbGFzdGljQ2VydA==", // myElasticCert
+		ElasticServiceCrn:       "myElasticCrn",
+		TenantNotificationTopic: "hri.tenants.notification",
+	}
+
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-133"
+
+	tests := []struct {
+		name         string
+		handler      theHandler
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				resume: func(_ context.Context, _ string, request model.ResumeTenant, topic string, _ *elasticsearch.Client, _ kafka.Writer) (int, interface{}) {
+					assert.Equal(t, validTenantId, request.TenantId)
+					assert.Equal(t, conf.TenantNotificationTopic, topic)
+					return http.StatusOK, map[string]interface{}{param.TenantId: validTenantId, "status": "active"}
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"status\":\"active\",\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+		{
+			name: "Internal Server error 500 on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 500, errors.New("500 internal server error")
+				},
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"500 internal server error\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/hri/tenants/"+validTenantId+"/action/resume", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/action/resume")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(validTenantId)
+
+			if assert.NoError(t, tt.handler.Resume(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_myHandler_Purge(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:         "https://elastic.url",
+		ElasticUsername:    "myElasticUser",
+		ElasticPassword:    "myElasticPassword",
+		ElasticCert:        "bXlFbGFzdGljQ2VydA==", // myElasticCert
+		ElasticServiceCrn:  "myElasticCrn",
+		BatchRetentionDays: 30,
+		ArchiveStore:       config.ArchiveStoreLocal,
+		ArchiveDir:         t.TempDir(),
+	}
+	noArchiveConf := conf
+	noArchiveConf.ArchiveStore = ""
+
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-134"
+
+	tests := []struct {
+		name         string
+		handler      theHandler
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+				purge: func(_ context.Context, _ string, request model.PurgeTenant, retentionDays int, _ *elasticsearch.Client, store archive.Store) (int, interface{}) {
+					assert.Equal(t, validTenantId, request.TenantId)
+					assert.Equal(t, 7, *request.OlderThanDays)
+					assert.Equal(t, conf.BatchRetentionDays, retentionDays)
+					assert.NotNil(t, store)
+					return http.StatusOK, map[string]interface{}{param.TenantId: validTenantId, "archived": 3, "olderThanDays": 7}
+				},
+			},
+			requestBody:  `{"olderThanDays":7}`,
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"archived\":3,\"olderThanDays\":7,\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+		{
+			name: "invalid olderThanDays",
+			handler: theHandler{
+				config: conf,
+			},
+			requestBody:  `{"olderThanDays":-1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"invalid request arguments:\\n- olderThanDays (json field in request body) must be 0 or greater\"}\n",
+		},
+		{
+			name: "no archive store",
+			handler: theHandler{
+				config: noArchiveConf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 200, nil
+				},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Batches can't be purged: no archive store is configured\"}\n",
+		},
+		{
+			name: "Unauthorized on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 401, errors.New("401 unauthorized")
+				},
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"401 unauthorized\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/hri/tenants/"+validTenantId+"/action/purge", strings.NewReader(tt.requestBody))
+			if tt.requestBody != "" {
+				request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/action/purge")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(validTenantId)
+
+			if assert.NoError(t, tt.handler.Purge(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_myHandler_Export(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:        "https://elastic.url",
+		ElasticServiceCrn: "myElasticCrn",
+	}
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-135"
+	authorized := func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+		return 200, nil
+	}
+
+	tests := []struct {
+		name                string
+		handler             theHandler
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config:          conf,
+				checkElasticIAM: authorized,
+				export: func(_ context.Context, _ string, request model.ExportTenant, _ *elasticsearch.Client, service eventstreams.Service, w io.Writer) (int, interface{}) {
+					assert.Equal(t, validTenantId, request.TenantId)
+					assert.NotNil(t, service)
+					_, _ = w.Write([]byte("{\"type\":\"config\",\"config\":{}}\n"))
+					return http.StatusOK, nil
+				},
+			},
+			expectedCode:        http.StatusOK,
+			expectedContentType: MIMEApplicationNDJSON,
+			expectedBody:        "{\"type\":\"config\",\"config\":{}}\n",
+		},
+		{
+			name: "export error",
+			handler: theHandler{
+				config:          conf,
+				checkElasticIAM: authorized,
+				export: func(context.Context, string, model.ExportTenant, *elasticsearch.Client, eventstreams.Service, io.Writer) (int, interface{}) {
+					return http.StatusNotFound, map[string]interface{}{"errorEventId": requestId, "errorDescription": "Tenant: " + validTenantId + " not found"}
+				},
+			},
+			expectedCode:        http.StatusNotFound,
+			expectedContentType: echo.MIMEApplicationJSONCharsetUTF8,
+			expectedBody:        "{\"errorDescription\":\"Tenant: " + validTenantId + " not found\",\"errorEventId\":\"" + requestId + "\"}\n",
+		},
+		{
+			name: "Unauthorized on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 401, errors.New("401 unauthorized")
+				},
+			},
+			expectedCode:        http.StatusUnauthorized,
+			expectedContentType: echo.MIMEApplicationJSONCharsetUTF8,
+			expectedBody:        "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"401 unauthorized\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/hri/tenants/"+validTenantId+"/export", nil)
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/export")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(validTenantId)
+
+			if assert.NoError(t, tt.handler.Export(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedContentType, recorder.Header().Get(echo.HeaderContentType))
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func Test_myHandler_Import(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	conf := config.Config{
+		ElasticUrl:        "https://elastic.url",
+		ElasticServiceCrn: "myElasticCrn",
+		Validation:        true,
+	}
+	limitedConf := conf
+	limitedConf.ImportMaxMegabytes = 1
+	validTenantId := "valid-tenant-id"
+	requestId := "req-id-136"
+	authorized := func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+		return 200, nil
+	}
+	export := `{"type":"config","config":{"displayName":"Tenant","retentionDays":30}}
+{"type":"stream","stream":{"streamId":"data-integrator1","numPartitions":2,"retentionMs":86400000}}
+{"type":"batch","batch":{"id":"batch1","name":"batch","status":"completed"}}
+`
+
+	tests := []struct {
+		name          string
+		handler       theHandler
+		query         string
+		requestBody   string
+		unknownLength bool
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name: "happy path",
+			handler: theHandler{
+				config:          conf,
+				checkElasticIAM: authorized,
+				importFn: func(_ context.Context, _ string, request model.ImportTenant, data tenantExport, validationEnabled bool, _ *elasticsearch.Client, _ eventstreams.Service) (int, interface{}) {
+					assert.Equal(t, model.ImportTenant{TenantId: validTenantId, PreserveIds: true, OnConflict: onConflictSkip}, request)
+					assert.Equal(t, "Tenant", data.Config.DisplayName)
+					assert.Equal(t, "data-integrator1", data.Streams[0].StreamId)
+					assert.Equal(t, "batch1", data.Batches[0][param.BatchId])
+					assert.True(t, validationEnabled)
+					return http.StatusOK, map[string]interface{}{param.TenantId: validTenantId}
+				},
+			},
+			query:        "?preserveIds=true&onConflict=skip",
+			requestBody:  export,
+			expectedCode: http.StatusOK,
+			expectedBody: "{\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+		{
+			name:         "invalid onConflict",
+			handler:      theHandler{config: conf},
+			query:        "?onConflict=replace",
+			requestBody:  export,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"invalid request arguments:\\n- onConflict (request query parameter) must be one of [fail skip overwrite]\"}\n",
+		},
+		{
+			name:         "invalid export line",
+			handler:      theHandler{config: conf, checkElasticIAM: authorized},
+			requestBody:  "{\"type\":\"config\",\"config\":{}}\nnot json\n",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Invalid tenant export: line 2: invalid character 'o' in literal null (expecting 'u')\"}\n",
+		},
+		{
+			name:         "incomplete export",
+			handler:      theHandler{config: conf, checkElasticIAM: authorized},
+			requestBody:  "{\"type\":\"error\",\"error\":\"scroll expired\"}\n",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Invalid tenant export: line 1: the export is incomplete: scroll expired\"}\n",
+		},
+		{
+			name:         "invalid stream",
+			handler:      theHandler{config: conf, checkElasticIAM: authorized},
+			requestBody:  `{"type":"stream","stream":{"streamId":"data-integrator1","numPartitions":200,"retentionMs":86400000}}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Invalid tenant export: stream [data-integrator1]: invalid request arguments:\\n- numPartitions (json field in request body) must be 99 or less\"}\n",
+		},
+		{
+			name:         "missing batch id",
+			handler:      theHandler{config: conf, checkElasticIAM: authorized},
+			query:        "?preserveIds=true",
+			requestBody:  `{"type":"batch","batch":{"name":"batch"}}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"Invalid tenant export: batch 1 has no id, it's required to preserve the ids\"}\n",
+		},
+		{
+			name: "Unauthorized on iam check",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 401, errors.New("401 unauthorized")
+				},
+			},
+			requestBody:  export,
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"401 unauthorized\"}\n",
+		},
+		{
+			name: "Unauthorized before the export is read",
+			handler: theHandler{
+				config: conf,
+				checkElasticIAM: func(context.Context, string, string, elastic.ResourceControllerService) (int, error) {
+					return 401, errors.New("401 unauthorized")
+				},
+			},
+			requestBody:  "not json\n",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"401 unauthorized\"}\n",
+		},
+		{
+			name:         "export larger than the limit",
+			handler:      theHandler{config: limitedConf, checkElasticIAM: authorized},
+			requestBody:  export + strings.Repeat(" ", 1024*1024),
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"the tenant export is larger than the import limit of 1 MB\"}\n",
+		},
+		{
+			name:          "export larger than the limit without a content length",
+			handler:       theHandler{config: limitedConf, checkElasticIAM: authorized},
+			requestBody:   export + strings.Repeat(" ", 1024*1024),
+			unknownLength: true,
+			expectedCode:  http.StatusRequestEntityTooLarge,
+			expectedBody:  "{\"errorEventId\":\"" + requestId + "\",\"errorDescription\":\"the tenant export is larger than the import limit of 1 MB\"}\n",
+		},
+		{
+			name: "export within the limit",
+			handler: theHandler{
+				config:          limitedConf,
+				checkElasticIAM: authorized,
+				importFn: func(context.Context, string, model.ImportTenant, tenantExport, bool, *elasticsearch.Client, eventstreams.Service) (int, interface{}) {
+					return http.StatusOK, map[string]interface{}{param.TenantId: validTenantId}
+				},
+			},
+			requestBody:   export,
+			unknownLength: true,
+			expectedCode:  http.StatusOK,
+			expectedBody:  "{\"tenantId\":\"" + validTenantId + "\"}\n",
+		},
+	}
+
+	e := test.GetTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/hri/tenants/"+validTenantId+"/import"+tt.query, strings.NewReader(tt.requestBody))
+			request.Header.Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+			if tt.unknownLength {
+				request.ContentLength = -1
+			}
+			context, recorder := test.PrepareHeadersContextRecorder(request, e)
+			request.Header.Set(echo.HeaderXRequestID, requestId)
+			request.Header.Set(echo.HeaderAuthorization, "Bearer 123456789")
+			context.SetPath("/hri/tenants/:tenantId/import")
+			context.SetParamNames(param.TenantId)
+			context.SetParamValues(validTenantId)
+
+			if assert.NoError(t, tt.handler.Import(context)) {
+				assert.Equal(t, tt.expectedCode, recorder.Code)
+				assert.Equal(t, tt.expectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/eventstreams"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/streams"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+// the ways an import handles a configuration, stream or batch that already exists
+const (
+	onConflictFail      = "fail"
+	onConflictSkip      = "skip"
+	onConflictOverwrite = "overwrite"
+)
+
+const importBulkSize = 500
+
+const msgImportConflict = "Import into tenant [%s] conflicts with existing data:%s"
+
+// importResult counts what an import did with each kind of record
+type importResult struct {
+	created  int
+	replaced int
+	skipped  int
+}
+
+func (r importResult) toMap() map[string]interface{} {
+	return map[string]interface{}{"created": r.created, "replaced": r.replaced, "skipped": r.skipped}
+}
+
+// Import recreates a tenant from an export. The tenant is created when it doesn't exist. Without preserveIds, the
+// batches get new ids and never conflict. With onConflict 'fail', the default, nothing is imported when the tenant
+// already has a configuration, or any of the streams or batch ids. With 'skip' the existing ones are kept, and with
+// 'overwrite' they are replaced, except for streams, which would lose their messages, so they are always kept. The
+// suspension and legal hold of the tenant are never imported; they can only be set with their actions.
+func Import(ctx context.Context, requestId string, request model.ImportTenant, data tenantExport, validationEnabled bool,
+	client *elasticsearch.Client, service eventstreams.Service) (int, interface{}) {
+
+	prefix := "tenants/Import"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Import")
+
+	tenantId := request.TenantId
+	onConflict := request.OnConflict
+	if onConflict == "" {
+		onConflict = onConflictFail
+	}
+
+	// find the conflicts before changing anything, so a failed import leaves the tenant as it was
+	exists, err := tenantExists(ctx, tenantId, client)
+	if err != nil {
+		msg := fmt.Sprintf("Could not import tenant [%s]: %s", tenantId, err.Error())
+		logger.Errorln(msg)
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+
+	configFound := false
+	if data.Config != nil {
+		if configFound, err = configExists(ctx, tenantId, client); err != nil {
+			msg := fmt.Sprintf("Could not import tenant [%s]: %s", tenantId, err.Error())
+			logger.Errorln(msg)
+			return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+		}
+	}
+
+	existingStreams := map[string]bool{}
+	if len(data.Streams) > 0 {
+		definitions, code, errDetail := streams.GetTenantStreams(ctx, requestId, tenantId, service)
+		if errDetail != nil {
+			return code, errDetail
+		}
+		for _, definition := range definitions {
+			existingStreams[definition.StreamId] = true
+		}
+	}
+
+	existingBatches := map[string]bool{}
+	if exists && request.PreserveIds && len(data.Batches) > 0 {
+		ids := make([]string, 0, len(data.Batches))
+		for _, batch := range data.Batches {
+			if id, ok := batch[param.BatchId].(string); ok {
+				ids = append(ids, id)
+			}
+		}
+		if existingBatches, err = existingDocIds(ctx, elastic.IndexFromTenantId(tenantId), ids, client); err != nil {
+			msg := fmt.Sprintf("Could not import tenant [%s]: %s", tenantId, err.Error())
+			logger.Errorln(msg)
+			return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+		}
+	}
+
+	if onConflict == onConflictFail {
+		if conflicts := describeConflicts(configFound, data.Streams, existingStreams, existingBatches); conflicts != "" {
+			msg := fmt.Sprintf(msgImportConflict, tenantId, conflicts)
+			logger.Errorln(msg)
+			return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+		}
+	}
+
+	if !exists {
+		if code, body := Create(ctx, requestId, tenantId, client); code != http.StatusCreated {
+			return code, body
+		}
+	}
+
+	respBody := map[string]interface{}{param.TenantId: tenantId}
+
+	if data.Config != nil {
+		if configFound && onConflict == onConflictSkip {
+			respBody["config"] = "skipped"
+		} else {
+			if code, errDetail := importConfig(ctx, requestId, tenantId, *data.Config, client, logger); errDetail != nil {
+				return code, errDetail
+			}
+			respBody["config"] = "created"
+			if configFound {
+				respBody["config"] = "replaced"
+			}
+		}
+	}
+
+	streamResult := importResult{}
+	for _, definition := range data.Streams {
+		if existingStreams[definition.StreamId] {
+			streamResult.skipped++
+			continue
+		}
+		code, errDetail := importStream(ctx, requestId, tenantId, definition, validationEnabled, service, logger)
+		if errDetail != nil {
+			return code, errDetail
+		}
+		streamResult.created++
+	}
+	respBody["streams"] = streamResult.toMap()
+
+	batchResult, elasticErr := importBatches(ctx, tenantId, data.Batches, request.PreserveIds, onConflict, existingBatches, client)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId, logger,
+			fmt.Sprintf("Could not import the batches of tenant [%s] after importing %d", tenantId,
+				batchResult.created+batchResult.replaced))
+	}
+	respBody["batches"] = batchResult.toMap()
+
+	logger.Infof("Imported tenant [%s]: %v", tenantId, respBody)
+	return http.StatusOK, respBody
+}
+
+func describeConflicts(configFound bool, definitions []model.StreamDefinition, existingStreams map[string]bool,
+	existingBatches map[string]bool) string {
+
+	var builder strings.Builder
+	if configFound {
+		builder.WriteString(" the configuration exists;")
+	}
+	streamIds := []string{}
+	for _, definition := range definitions {
+		if existingStreams[definition.StreamId] {
+			streamIds = append(streamIds, definition.StreamId)
+		}
+	}
+	if len(streamIds) > 0 {
+		builder.WriteString(fmt.Sprintf(" streams %v exist;", streamIds))
+	}
+	if len(existingBatches) > 0 {
+		builder.WriteString(fmt.Sprintf(" %d batch ids exist;", len(existingBatches)))
+	}
+	return strings.TrimSuffix(builder.String(), ";")
+}
+
+func configExists(ctx context.Context, tenantId string, client *elasticsearch.Client) (bool, error) {
+	res, err := client.Exists(elastic.TenantsIndex, tenantId, client.Exists.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	return res.StatusCode == http.StatusOK, nil
+}
+
+// importConfig stores the imported configuration, but keeps the tenant's current suspension and legal hold
+func importConfig(ctx context.Context, requestId string, tenantId string, tenantConfig model.TenantConfig, client *elasticsearch.Client,
+	logger logrus.FieldLogger) (int, *response.ErrorDetail) {
+
+	currentConfig, elasticErr := elastic.GetTenantConfig(ctx, tenantId, client)
+	if elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not import the configuration of tenant [%s]", tenantId))
+	}
+	tenantConfig.TenantId = tenantId
+	tenantConfig.Suspension = currentConfig.Suspension
+	tenantConfig.LegalHold = currentConfig.LegalHold
+
+	jsonConfig, err := json.Marshal(tenantConfig)
+	if err != nil {
+		//NOTE: This should Never happen because the config is a statically-typed struct
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, err.Error())
+	}
+
+	indexRes, err := client.Index(
+		elastic.TenantsIndex,
+		bytes.NewReader(jsonConfig),
+		client.Index.WithContext(ctx),
+		client.Index.WithDocumentID(tenantId),
+		client.Index.WithRefresh("true"),
+	)
+	if _, elasticErr = elastic.DecodeBody(indexRes, err); elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not import the configuration of tenant [%s]", tenantId))
+	}
+	return http.StatusOK, nil
+}
+
+// importStream creates the stream's topics, and removes the ones that were created if any of them fail
+func importStream(ctx context.Context, requestId string, tenantId string, definition model.StreamDefinition, validationEnabled bool,
+	service eventstreams.Service, logger logrus.FieldLogger) (int, *response.ErrorDetail) {
+
+	createdTopics, code, err := streams.Create(ctx, definition.CreateStreamsRequest(tenantId), tenantId,
+		definition.StreamId, validationEnabled, requestId, service)
+	if code == http.StatusCreated {
+		return code, nil
+	}
+
+	msg := fmt.Sprintf("Could not import stream [%s] of tenant [%s]: %s", definition.StreamId, tenantId, err.Error())
+	if _, deleteErr := streams.Delete(ctx, requestId, createdTopics, service); deleteErr != nil {
+		msg = fmt.Sprintf("%s\n%s", msg, deleteErr.Error())
+	}
+	logger.Errorln(msg)
+	return code, response.NewErrorDetail(requestId, msg)
+}
+
+// existingDocIds returns which of the ids are already in the index
+func existingDocIds(ctx context.Context, index string, ids []string, client *elasticsearch.Client) (map[string]bool, error) {
+	existing := map[string]bool{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	buf, err := elastic.EncodeQueryBody(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Mget(
+		buf,
+		client.Mget.WithContext(ctx),
+		client.Mget.WithIndex(index),
+		client.Mget.WithSource("false"),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return nil, elasticErr
+	}
+
+	docs, _ := body["docs"].([]interface{})
+	for _, doc := range docs {
+		esDoc := doc.(map[string]interface{})
+		if found, _ := esDoc["found"].(bool); found {
+			existing[esDoc["_id"].(string)] = true
+		}
+	}
+	return existing, nil
+}
+
+// importBatches bulk indexes the batches. Batches that exist are skipped or replaced, depending on onConflict.
+// Batches created since the conflicts were checked are always skipped, unless onConflict is 'overwrite'.
+func importBatches(ctx context.Context, tenantId string, batches []map[string]interface{}, preserveIds bool, onConflict string,
+	existing map[string]bool, client *elasticsearch.Client) (importResult, *elastic.ResponseError) {
+
+	result := importResult{}
+	var buf bytes.Buffer
+	pending := 0
+	for i, batch := range batches {
+		id, _ := batch[param.BatchId].(string)
+		if preserveIds && existing[id] && onConflict != onConflictOverwrite {
+			result.skipped++
+		} else {
+			if err := appendBulkAction(&buf, batch, preserveIds, onConflict); err != nil {
+				return result, &elastic.ResponseError{ErrorObj: err, Code: http.StatusInternalServerError}
+			}
+			pending++
+		}
+
+		if pending == importBulkSize || (pending > 0 && i == len(batches)-1) {
+			if elasticErr := bulkIndex(ctx, tenantId, &buf, &result, client); elasticErr != nil {
+				return result, elasticErr
+			}
+			buf.Reset()
+			pending = 0
+		}
+	}
+	return result, nil
+}
+
+func appendBulkAction(buf *bytes.Buffer, batch map[string]interface{}, preserveIds bool, onConflict string) error {
+	source := make(map[string]interface{}, len(batch))
+	for key, value := range batch {
+		if key != param.BatchId {
+			source[key] = value
+		}
+	}
+
+	metadata := map[string]interface{}{}
+	action := "index"
+	if preserveIds {
+		metadata["_id"] = batch[param.BatchId]
+		if onConflict != onConflictOverwrite {
+			action = "create"
+		}
+	}
+
+	encoder := json.NewEncoder(buf)
+	if err := encoder.Encode(map[string]interface{}{action: metadata}); err != nil {
+		return err
+	}
+	return encoder.Encode(source)
+}
+
+func bulkIndex(ctx context.Context, tenantId string, buf *bytes.Buffer, result *importResult, client *elasticsearch.Client) *elastic.ResponseError {
+	res, err := client.Bulk(
+		buf,
+		client.Bulk.WithContext(ctx),
+		client.Bulk.WithIndex(elastic.IndexFromTenantId(tenantId)),
+		client.Bulk.WithRefresh("true"),
+	)
+	body, elasticErr := elastic.DecodeBody(res, err)
+	if elasticErr != nil {
+		return elasticErr
+	}
+
+	items, _ := body["items"].([]interface{})
+	for _, item := range items {
+		for _, value := range item.(map[string]interface{}) {
+			itemResult := value.(map[string]interface{})
+			status, _ := itemResult["status"].(float64)
+			switch {
+			case itemResult["result"] == "updated":
+				result.replaced++
+			case status == http.StatusCreated || status == http.StatusOK:
+				result.created++
+			case status == http.StatusConflict:
+				result.skipped++
+			default:
+				return &elastic.ResponseError{
+					ErrorObj: fmt.Errorf("batch [%v] failed: %v", itemResult["_id"], itemResult["error"]),
+					Code:     int(status),
+				}
+			}
+		}
+	}
+	return nil
+}
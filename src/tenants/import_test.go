@@ -0,0 +1,255 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	es "github.com/IBM/event-streams-go-sdk-generator/build/generated"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	mgetPath := fmt.Sprintf("/%s-batches/_mget", tenantId)
+	bulkPath := fmt.Sprintf("/%s-batches/_bulk", tenantId)
+	noConfigCall := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+	}
+	suspendedConfigCall := test.ElasticCall{
+		ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"suspension":{"suspendDate":"2021-02-24T00:00:00Z"}}}`,
+			elastic.TenantsIndex, tenantId),
+	}
+
+	retentionDays := 30
+	numPartitions := int64(2)
+	retentionMs := 86400000
+	data := tenantExport{
+		Config: &model.TenantConfig{RetentionDays: &retentionDays},
+		Streams: []model.StreamDefinition{
+			{StreamId: "dataIntegrator1", NumPartitions: &numPartitions, RetentionMs: &retentionMs},
+		},
+		Batches: []map[string]interface{}{
+			{param.BatchId: "batch1", param.Name: "first"},
+			{param.BatchId: "batch2", param.Name: "second"},
+		},
+	}
+	existingTopics := []es.TopicDetail{
+		{Name: "ingest." + tenantId + ".dataIntegrator1.in", Partitions: 2},
+		{Name: "ingest." + tenantId + ".dataIntegrator1.notification", Partitions: 1},
+	}
+	mgetResponse := `{"docs":[{"_id":"batch1","found":true},{"_id":"batch2","found":false}]}`
+
+	testCases := []struct {
+		name             string
+		request          model.ImportTenant
+		transport        *test.FakeTransport
+		expectListTopics bool
+		topics           []es.TopicDetail
+		expectCreates    []string
+		expectedCode     int
+		expectedBody     interface{}
+	}{
+		{
+			name:    "new-tenant",
+			request: model.ImportTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusNotFound},
+			).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				indexPath, test.ElasticCall{ResponseBody: `{"acknowledged":true}`},
+			).AddCall(
+				configPath, noConfigCall,
+			).AddCall(
+				configPath,
+				test.ElasticCall{
+					RequestBody:  `{"retentionDays":30}`,
+					ResponseBody: `{"result":"created"}`,
+				},
+			).AddCall(
+				bulkPath,
+				test.ElasticCall{
+					RequestQuery: "refresh=true",
+					RequestBody:  `{"index":{}}\n{"name":"first"}\n{"index":{}}\n{"name":"second"}\n`,
+					ResponseBody: `{"errors":false,"items":[{"index":{"_id":"a","result":"created","status":201}},{"index":{"_id":"b","result":"created","status":201}}]}`,
+				},
+			),
+			expectListTopics: true,
+			expectCreates:    []string{"ingest." + tenantId + ".dataIntegrator1.in", "ingest." + tenantId + ".dataIntegrator1.notification"},
+			expectedCode:     http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId: tenantId,
+				"config":       "created",
+				"streams":      map[string]interface{}{"created": 1, "replaced": 0, "skipped": 0},
+				"batches":      map[string]interface{}{"created": 2, "replaced": 0, "skipped": 0},
+			},
+		},
+		{
+			name:    "conflicts-fail",
+			request: model.ImportTenant{TenantId: tenantId, PreserveIds: true},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				mgetPath,
+				test.ElasticCall{
+					RequestQuery: "_source=false",
+					RequestBody:  `{"ids":\["batch1","batch2"\]}`,
+					ResponseBody: mgetResponse,
+				},
+			),
+			expectListTopics: true,
+			topics:           existingTopics,
+			expectedCode:     http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgImportConflict, tenantId,
+				" the configuration exists; streams [dataIntegrator1] exist; 1 batch ids exist")),
+		},
+		{
+			name:    "conflicts-skip",
+			request: model.ImportTenant{TenantId: tenantId, PreserveIds: true, OnConflict: onConflictSkip},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				mgetPath, test.ElasticCall{ResponseBody: mgetResponse},
+			).AddCall(
+				bulkPath,
+				test.ElasticCall{
+					RequestBody:  `^{"create":{"_id":"batch2"}}\n{"name":"second"}\n$`,
+					ResponseBody: `{"errors":false,"items":[{"create":{"_id":"batch2","result":"created","status":201}}]}`,
+				},
+			),
+			expectListTopics: true,
+			topics:           existingTopics,
+			expectedCode:     http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId: tenantId,
+				"config":       "skipped",
+				"streams":      map[string]interface{}{"created": 0, "replaced": 0, "skipped": 1},
+				"batches":      map[string]interface{}{"created": 1, "replaced": 0, "skipped": 1},
+			},
+		},
+		{
+			name:    "conflicts-overwrite",
+			request: model.ImportTenant{TenantId: tenantId, PreserveIds: true, OnConflict: onConflictOverwrite},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				mgetPath, test.ElasticCall{ResponseBody: mgetResponse},
+			).AddCall(
+				configPath, suspendedConfigCall,
+			).AddCall(
+				configPath,
+				test.ElasticCall{
+					// the suspension is kept
+					RequestBody:  `{"retentionDays":30,"suspension":{"suspendDate":"2021-02-24T00:00:00Z"}}`,
+					ResponseBody: `{"result":"updated"}`,
+				},
+			).AddCall(
+				bulkPath,
+				test.ElasticCall{
+					RequestBody:  `{"index":{"_id":"batch1"}}\n{"name":"first"}\n{"index":{"_id":"batch2"}}\n{"name":"second"}\n`,
+					ResponseBody: `{"errors":false,"items":[{"index":{"_id":"batch1","result":"updated","status":200}},{"index":{"_id":"batch2","result":"created","status":201}}]}`,
+				},
+			),
+			expectListTopics: true,
+			topics:           existingTopics,
+			expectedCode:     http.StatusOK,
+			expectedBody: map[string]interface{}{
+				param.TenantId: tenantId,
+				"config":       "replaced",
+				"streams":      map[string]interface{}{"created": 0, "replaced": 0, "skipped": 1},
+				"batches":      map[string]interface{}{"created": 1, "replaced": 1, "skipped": 0},
+			},
+		},
+		{
+			name:    "bulk-item-error",
+			request: model.ImportTenant{TenantId: tenantId, OnConflict: onConflictSkip},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath, test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				bulkPath,
+				test.ElasticCall{
+					ResponseBody: `{"errors":true,"items":[{"index":{"_id":"a","result":"created","status":201}},` +
+						`{"index":{"_id":"b","status":400,"error":{"type":"mapper_parsing_exception"}}}]}`,
+				},
+			),
+			expectListTopics: true,
+			topics:           existingTopics,
+			expectedCode:     http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(
+				"Could not import the batches of tenant [%s] after importing 1: [400] batch [b] failed: map[type:mapper_parsing_exception]", tenantId)),
+		},
+		{
+			name:    "index-exists-error",
+			request: model.ImportTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath, test.ElasticCall{ResponseErr: errors.New("elasticErrMsg")},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not import tenant [%s]: elasticErrMsg", tenantId)),
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+			mockService := test.NewMockService(controller)
+			if tc.expectListTopics {
+				mockService.
+					EXPECT().
+					ListTopics(gomock.Any(), &es.ListTopicsOpts{}).
+					Return(tc.topics, &http.Response{StatusCode: http.StatusOK}, nil)
+			}
+			for _, topic := range tc.expectCreates {
+				topic := topic
+				mockService.
+					EXPECT().
+					CreateTopic(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ interface{}, request es.TopicCreateRequest) (map[string]interface{}, *http.Response, error) {
+						assert.Equal(t, topic, request.Name)
+						return nil, &http.Response{StatusCode: http.StatusCreated}, nil
+					})
+			}
+
+			code, body := Import(context.Background(), requestId, tc.request, data, false, client, mockService)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, body)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
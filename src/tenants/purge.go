@@ -0,0 +1,75 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/batches"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"net/http"
+)
+
+const msgNoRetention = "Tenant [%s] has no retention, olderThanDays must be specified"
+
+// Purge archives and removes all of the tenant's completed, failed and terminated batches that ended more than
+// olderThanDays days ago. When olderThanDays isn't in the request, the tenant's retentionDays or else the server's
+// batch retention days are used. Tenants under legal hold can't be purged, and held batches are skipped.
+func Purge(ctx context.Context, requestId string, request model.PurgeTenant, defaultRetentionDays int, client *elasticsearch.Client,
+	store archive.Store) (int, interface{}) {
+
+	prefix := "tenants/Purge"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Purge")
+
+	tenantId := request.TenantId
+	tenantConfig, code, errDetail := getExistingTenantConfig(ctx, requestId, tenantId, "purge", client, logger)
+	if errDetail != nil {
+		return code, errDetail
+	}
+	if tenantConfig.LegalHold != nil {
+		msg := fmt.Sprintf(msgTenantOnLegalHold, tenantId, "purged")
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+
+	olderThanDays := defaultRetentionDays
+	if request.OlderThanDays != nil {
+		olderThanDays = *request.OlderThanDays
+	} else if tenantConfig.RetentionDays != nil {
+		olderThanDays = *tenantConfig.RetentionDays
+	} else if olderThanDays == 0 {
+		msg := fmt.Sprintf(msgNoRetention, tenantId)
+		logger.Errorln(msg)
+		return http.StatusBadRequest, response.NewErrorDetail(requestId, msg)
+	}
+
+	archived := 0
+	for {
+		count, err := batches.ArchiveBatches(ctx, tenantId, olderThanDays, client, store)
+		archived += count
+		if err != nil {
+			msg := fmt.Sprintf("Could not purge tenant [%s] after archiving %d batches: %s", tenantId, archived, err.Error())
+			logger.Errorln(msg)
+			return http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+		}
+		if count < batches.MaxArchivedBatches {
+			break
+		}
+	}
+	logger.Infof("Archived %d batches of tenant [%s] that ended more than %d days ago", archived, tenantId, olderThanDays)
+
+	return http.StatusOK, map[string]interface{}{
+		param.TenantId:  tenantId,
+		"olderThanDays": olderThanDays,
+		"archived":      archived,
+	}
+}
@@ -0,0 +1,152 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/archive"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestPurge(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+	sevenDays := 7
+
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	searchPath := fmt.Sprintf("/%s-batches/_search", tenantId)
+	deletePath := fmt.Sprintf("/%s-batches/_bulk", tenantId)
+	noConfigCall := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+	}
+	retentionConfigCall := test.ElasticCall{
+		ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"retentionDays":90}}`, elastic.TenantsIndex, tenantId),
+	}
+	searchCall := func(days int) test.ElasticCall {
+		return test.ElasticCall{
+			RequestBody:  fmt.Sprintf(`{"range":{"endDate":{"lt":"now-%dd"}}}`, days),
+			ResponseBody: `{"hits":{"hits":[{"_id":"batch1","_seq_no":5,"_primary_term":1,"_source":{"name":"first","status":"completed"}}]}}`,
+		}
+	}
+
+	deleteCall := test.ElasticCall{
+		RequestBody:  `{"delete":{"_id":"batch1","if_primary_term":1,"if_seq_no":5}}` + "\n",
+		ResponseBody: `{"items":[{"delete":{"_id":"batch1","status":200}}]}`,
+	}
+
+	testCases := []struct {
+		name                 string
+		request              model.PurgeTenant
+		defaultRetentionDays int
+		transport            *test.FakeTransport
+		expectedCode         int
+		expectedBody         interface{}
+	}{
+		{
+			name:    "tenant-not-found",
+			request: model.PurgeTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusNotFound},
+			),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantNotFound, tenantId)),
+		},
+		{
+			name:    "olderThanDays-from-request",
+			request: model.PurgeTenant{TenantId: tenantId, OlderThanDays: &sevenDays},
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, retentionConfigCall).
+				AddCall(searchPath, searchCall(7)).
+				AddCall(deletePath, deleteCall),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{param.TenantId: tenantId, "olderThanDays": 7, "archived": 1},
+		},
+		{
+			name:                 "tenant-retention",
+			request:              model.PurgeTenant{TenantId: tenantId},
+			defaultRetentionDays: 30,
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, retentionConfigCall).
+				AddCall(searchPath, searchCall(90)).
+				AddCall(deletePath, deleteCall),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{param.TenantId: tenantId, "olderThanDays": 90, "archived": 1},
+		},
+		{
+			name:                 "default-retention",
+			request:              model.PurgeTenant{TenantId: tenantId},
+			defaultRetentionDays: 30,
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, noConfigCall).
+				AddCall(searchPath, test.ElasticCall{ResponseBody: `{"hits":{"hits":[]}}`}),
+			expectedCode: http.StatusOK,
+			expectedBody: map[string]interface{}{param.TenantId: tenantId, "olderThanDays": 30, "archived": 0},
+		},
+		{
+			name:    "no-retention",
+			request: model.PurgeTenant{TenantId: tenantId},
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, noConfigCall),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgNoRetention, tenantId)),
+		},
+		{
+			name:    "legal-hold",
+			request: model.PurgeTenant{TenantId: tenantId, OlderThanDays: &sevenDays},
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"legalHold":{"reason":"litigation","actor":"admin","holdDate":"2021-02-24T18:08:36Z"}}}`,
+						elastic.TenantsIndex, tenantId),
+				}),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantOnLegalHold, tenantId, "purged")),
+		},
+		{
+			name:    "archive-error",
+			request: model.PurgeTenant{TenantId: tenantId, OlderThanDays: &sevenDays},
+			transport: test.NewFakeTransport(t).
+				AddCall(indexPath, test.ElasticCall{ResponseStatusCode: http.StatusOK}).
+				AddCall(configPath, noConfigCall).
+				AddCall(searchPath, test.ElasticCall{ResponseErr: errors.New("connection refused")}),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId,
+				"Could not purge tenant [tenant123] after archiving 0 batches: elasticsearch client error: connection refused"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := elastic.ClientFromTransport(tc.transport)
+			assert.NoError(t, err)
+
+			code, body := Purge(context.Background(), requestId, tc.request, tc.defaultRetentionDays, client, archive.NewLocalStore(t.TempDir()))
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedBody, body)
+			tc.transport.VerifyCalls()
+		})
+	}
+}
@@ -0,0 +1,178 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/kafka"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/metrics"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+const (
+	msgAlreadySuspended   = "Tenant [%s] is already suspended"
+	msgNotSuspended       = "Tenant [%s] is not suspended"
+	tenantStatusSuspended = "suspended"
+	tenantStatusActive    = "active"
+)
+
+// Suspend puts the tenant in read-only mode: batches can't be created, sent complete or processing completed until
+// the tenant is resumed. The tenant's new status is published to the tenant notification topic.
+func Suspend(ctx context.Context, requestId string, request model.SuspendTenant, notificationTopic string, client *elasticsearch.Client,
+	writer kafka.Writer) (int, interface{}) {
+
+	prefix := "tenants/Suspend"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Suspend")
+
+	tenantId := request.TenantId
+	tenantConfig, code, errDetail := getExistingTenantConfig(ctx, requestId, tenantId, "suspend", client, logger)
+	if errDetail != nil {
+		return code, errDetail
+	}
+	if tenantConfig.Suspension != nil {
+		msg := fmt.Sprintf(msgAlreadySuspended, tenantId)
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+
+	suspension := &model.TenantSuspension{
+		Reason:      request.Reason,
+		SuspendDate: time.Now().UTC().Format(elastic.DateTimeFormat),
+	}
+	return changeSuspension(ctx, requestId, tenantId, "suspend", nil, suspension, notificationTopic, client, writer,
+		logger)
+}
+
+// Resume ends the tenant's suspension and publishes the tenant's new status to the tenant notification topic
+func Resume(ctx context.Context, requestId string, request model.ResumeTenant, notificationTopic string, client *elasticsearch.Client,
+	writer kafka.Writer) (int, interface{}) {
+
+	prefix := "tenants/Resume"
+	var logger = logwrapper.GetMyTenantLogger(requestId, prefix, request.TenantId)
+	logger.Debugln("Start Tenant Resume")
+
+	tenantId := request.TenantId
+	tenantConfig, code, errDetail := getExistingTenantConfig(ctx, requestId, tenantId, "resume", client, logger)
+	if errDetail != nil {
+		return code, errDetail
+	}
+	if tenantConfig.Suspension == nil {
+		msg := fmt.Sprintf(msgNotSuspended, tenantId)
+		logger.Errorln(msg)
+		return http.StatusConflict, response.NewErrorDetail(requestId, msg)
+	}
+
+	return changeSuspension(ctx, requestId, tenantId, "resume", tenantConfig.Suspension, nil, notificationTopic, client,
+		writer, logger)
+}
+
+func getExistingTenantConfig(ctx context.Context, requestId string, tenantId string, action string, client *elasticsearch.Client,
+	logger logrus.FieldLogger) (model.TenantConfig, int, *response.ErrorDetail) {
+
+	exists, err := tenantExists(ctx, tenantId, client)
+	if err != nil {
+		msg := fmt.Sprintf("Could not %s tenant [%s]: %s", action, tenantId, err.Error())
+		logger.Errorln(msg)
+		return model.TenantConfig{}, http.StatusInternalServerError, response.NewErrorDetail(requestId, msg)
+	}
+	if !exists {
+		msg := fmt.Sprintf(msgTenantNotFound, tenantId)
+		logger.Errorln(msg)
+		return model.TenantConfig{}, http.StatusNotFound, response.NewErrorDetail(requestId, msg)
+	}
+
+	tenantConfig, elasticErr := elastic.GetTenantConfig(ctx, tenantId, client)
+	if elasticErr != nil {
+		return model.TenantConfig{}, http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not %s tenant [%s]", action, tenantId))
+	}
+	return tenantConfig, http.StatusOK, nil
+}
+
+// changeSuspension stores the new suspension (nil to resume) and publishes the notification. If the notification
+// can't be written, the previous suspension is restored so the tenant's state and the notifications stay in sync.
+func changeSuspension(ctx context.Context, requestId string, tenantId string, action string,
+	previous *model.TenantSuspension, suspension *model.TenantSuspension, notificationTopic string,
+	client *elasticsearch.Client, writer kafka.Writer, logger logrus.FieldLogger) (int, interface{}) {
+
+	if elasticErr := setSuspension(ctx, tenantId, suspension, client); elasticErr != nil {
+		return http.StatusInternalServerError, elasticErr.LogAndBuildErrorDetail(requestId,
+			logger, fmt.Sprintf("Could not %s tenant [%s]", action, tenantId))
+	}
+
+	notification := tenantNotification(tenantId, suspension)
+	if err := writer.Write(ctx, notificationTopic, tenantId, notification); err != nil {
+		metrics.NotificationPublishFailed(metrics.TenantNotification)
+		kafkaErrMsg := fmt.Sprintf("error writing tenant notification to kafka: %s", err.Error())
+		logger.Errorln(kafkaErrMsg)
+
+		if elasticErr := setSuspension(ctx, tenantId, previous, client); elasticErr != nil {
+			logger.Errorf("Unable to revert the suspension of tenant [%s]: %s", tenantId, elasticErr.Error())
+		}
+		return http.StatusInternalServerError, response.NewErrorDetail(requestId, kafkaErrMsg)
+	}
+
+	logger.Infof("Tenant [%s] is now %s", tenantId, notification[param.Status])
+	return http.StatusOK, notification
+}
+
+func setSuspension(ctx context.Context, tenantId string, suspension *model.TenantSuspension, client *elasticsearch.Client) *elastic.ResponseError {
+	var updateRequest map[string]interface{}
+	if suspension == nil {
+		updateRequest = map[string]interface{}{
+			"script": map[string]interface{}{
+				"source": "ctx._source.remove('suspension')",
+			},
+		}
+	} else {
+		// tenants without a configuration get one that only holds the suspension
+		updateRequest = map[string]interface{}{
+			"doc":           map[string]interface{}{"suspension": suspension},
+			"doc_as_upsert": true,
+		}
+	}
+
+	encodedQuery, err := elastic.EncodeQueryBody(updateRequest)
+	if err != nil {
+		return &elastic.ResponseError{ErrorObj: fmt.Errorf("error encoding Elastic query: %w", err),
+			Code: http.StatusInternalServerError}
+	}
+
+	res, err := client.Update(
+		elastic.TenantsIndex,
+		tenantId,
+		encodedQuery,
+		client.Update.WithContext(ctx),
+		client.Update.WithRefresh("true"),
+	)
+	_, elasticErr := elastic.DecodeBody(res, err)
+	return elasticErr
+}
+
+func tenantNotification(tenantId string, suspension *model.TenantSuspension) map[string]interface{} {
+	notification := map[string]interface{}{
+		param.TenantId: tenantId,
+		param.Status:   tenantStatusActive,
+	}
+	if suspension != nil {
+		notification[param.Status] = tenantStatusSuspended
+		notification["suspendDate"] = suspension.SuspendDate
+		if suspension.Reason != "" {
+			notification["reason"] = suspension.Reason
+		}
+	}
+	return notification
+}
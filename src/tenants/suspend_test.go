@@ -0,0 +1,313 @@
+/**
+ * (C) Copyright IBM Corp. 2021
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Alvearie/hri-mgmt-api/common/elastic"
+	"github.com/Alvearie/hri-mgmt-api/common/logwrapper"
+	"github.com/Alvearie/hri-mgmt-api/common/model"
+	"github.com/Alvearie/hri-mgmt-api/common/param"
+	"github.com/Alvearie/hri-mgmt-api/common/response"
+	"github.com/Alvearie/hri-mgmt-api/common/test"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+const (
+	notificationTopic = "hri.tenants.notification"
+	suspendDate       = "2021-02-24T18:08:36Z"
+	suspendScript     = `{"doc":{"suspension":{"reason":"contract expired","suspendDate":"` + test.DatePattern + `"}},"doc_as_upsert":true}` + "\n"
+	resumeScript      = `{"script":{"source":"ctx\._source\.remove\('suspension'\)"}}` + "\n"
+)
+
+func TestSuspend(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+	elasticErrMsg := "elasticErrMsg"
+	request := model.SuspendTenant{TenantId: tenantId, Reason: "contract expired"}
+
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	updatePath := fmt.Sprintf("/%s/_doc/%s/_update", elastic.TenantsIndex, tenantId)
+	noConfigCall := test.ElasticCall{
+		ResponseStatusCode: http.StatusNotFound,
+		ResponseBody:       fmt.Sprintf(`{"_index":"%s","_id":"%s","found":false}`, elastic.TenantsIndex, tenantId),
+	}
+	updatedCall := test.ElasticCall{
+		RequestQuery: "refresh=true",
+		RequestBody:  suspendScript,
+		ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"created"}`, elastic.TenantsIndex, tenantId),
+	}
+
+	suspendedNotification := map[string]interface{}{
+		param.TenantId: tenantId,
+		param.Status:   tenantStatusSuspended,
+		"reason":       "contract expired",
+	}
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		writerError  error
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "tenant-not-found",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusNotFound},
+			),
+			expectedCode: http.StatusNotFound,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgTenantNotFound, tenantId)),
+		},
+		{
+			name: "already-suspended",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"suspension":{"suspendDate":"%s"}}}`,
+						elastic.TenantsIndex, tenantId, suspendDate),
+				},
+			),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgAlreadySuspended, tenantId)),
+		},
+		{
+			name: "update-error",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				noConfigCall,
+			).AddCall(
+				updatePath,
+				test.ElasticCall{ResponseErr: errors.New(elasticErrMsg)},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(
+				"Could not suspend tenant [%s]: [500] elasticsearch client error: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name: "kafka-error-reverts-suspension",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				noConfigCall,
+			).AddCall(
+				updatePath,
+				updatedCall,
+			).AddCall(
+				updatePath,
+				test.ElasticCall{
+					RequestBody:  resumeScript,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"updated"}`, elastic.TenantsIndex, tenantId),
+				},
+			),
+			writerError:  errors.New("unable to write to Kafka"),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, "error writing tenant notification to kafka: unable to write to Kafka"),
+		},
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				noConfigCall,
+			).AddCall(
+				updatePath,
+				updatedCall,
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: suspendedNotification,
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+		writer := suspensionWriter{
+			FakeWriter: test.FakeWriter{
+				T:             t,
+				ExpectedTopic: notificationTopic,
+				ExpectedKey:   tenantId,
+				ExpectedValue: suspendedNotification,
+				Error:         tc.writerError,
+			},
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := Suspend(context.Background(), requestId, request, notificationTopic, client, writer)
+			if code != tc.expectedCode {
+				t.Error(fmt.Sprintf("Incorrect HTTP code returned. Expected: [%v], actual: [%v]", tc.expectedCode, code))
+			} else if !reflect.DeepEqual(tc.expectedBody, withoutSuspendDate(body)) {
+				t.Error(fmt.Sprintf("Incorrect HTTP response body returned. Expected: [%v], actual: [%v]", tc.expectedBody, body))
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	logwrapper.Initialize("error", os.Stdout)
+
+	requestId := "request_id_1"
+	tenantId := "tenant123"
+	elasticErrMsg := "elasticErrMsg"
+	request := model.ResumeTenant{TenantId: tenantId}
+
+	indexPath := fmt.Sprintf("/%s-batches", tenantId)
+	configPath := fmt.Sprintf("/%s/_doc/%s", elastic.TenantsIndex, tenantId)
+	updatePath := fmt.Sprintf("/%s/_doc/%s/_update", elastic.TenantsIndex, tenantId)
+	suspendedConfigCall := test.ElasticCall{
+		ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"suspension":{"reason":"contract expired","suspendDate":"%s"}}}`,
+			elastic.TenantsIndex, tenantId, suspendDate),
+	}
+	resumedCall := test.ElasticCall{
+		RequestQuery: "refresh=true",
+		RequestBody:  resumeScript,
+		ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"updated"}`, elastic.TenantsIndex, tenantId),
+	}
+
+	activeNotification := map[string]interface{}{
+		param.TenantId: tenantId,
+		param.Status:   tenantStatusActive,
+	}
+
+	testCases := []struct {
+		name         string
+		transport    *test.FakeTransport
+		writerError  error
+		expectedCode int
+		expectedBody interface{}
+	}{
+		{
+			name: "tenant-check-error",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseErr: errors.New(elasticErrMsg)},
+			),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf("Could not resume tenant [%s]: %s", tenantId, elasticErrMsg)),
+		},
+		{
+			name: "not-suspended",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				test.ElasticCall{
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","found":true,"_source":{"retentionDays":30}}`,
+						elastic.TenantsIndex, tenantId),
+				},
+			),
+			expectedCode: http.StatusConflict,
+			expectedBody: response.NewErrorDetail(requestId, fmt.Sprintf(msgNotSuspended, tenantId)),
+		},
+		{
+			name: "kafka-error-restores-suspension",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				suspendedConfigCall,
+			).AddCall(
+				updatePath,
+				resumedCall,
+			).AddCall(
+				updatePath,
+				test.ElasticCall{
+					RequestBody:  `{"doc":{"suspension":{"reason":"contract expired","suspendDate":"` + suspendDate + `"}},"doc_as_upsert":true}`,
+					ResponseBody: fmt.Sprintf(`{"_index":"%s","_id":"%s","result":"updated"}`, elastic.TenantsIndex, tenantId),
+				},
+			),
+			writerError:  errors.New("unable to write to Kafka"),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: response.NewErrorDetail(requestId, "error writing tenant notification to kafka: unable to write to Kafka"),
+		},
+		{
+			name: "good-request",
+			transport: test.NewFakeTransport(t).AddCall(
+				indexPath,
+				test.ElasticCall{ResponseStatusCode: http.StatusOK},
+			).AddCall(
+				configPath,
+				suspendedConfigCall,
+			).AddCall(
+				updatePath,
+				resumedCall,
+			),
+			expectedCode: http.StatusOK,
+			expectedBody: activeNotification,
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := elastic.ClientFromTransport(tc.transport)
+		if err != nil {
+			t.Error(err)
+		}
+		writer := test.FakeWriter{
+			T:             t,
+			ExpectedTopic: notificationTopic,
+			ExpectedKey:   tenantId,
+			ExpectedValue: activeNotification,
+			Error:         tc.writerError,
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := Resume(context.Background(), requestId, request, notificationTopic, client, writer)
+			if code != tc.expectedCode {
+				t.Error(fmt.Sprintf("Incorrect HTTP code returned. Expected: [%v], actual: [%v]", tc.expectedCode, code))
+			} else if !reflect.DeepEqual(tc.expectedBody, body) {
+				t.Error(fmt.Sprintf("Incorrect HTTP response body returned. Expected: [%v], actual: [%v]", tc.expectedBody, body))
+			}
+			tc.transport.VerifyCalls()
+		})
+	}
+}
+
+// suspensionWriter ignores the suspend date, which is set to the current time, when comparing notifications
+type suspensionWriter struct {
+	test.FakeWriter
+}
+
+func (w suspensionWriter) Write(ctx context.Context, topic string, key string, val map[string]interface{}) error {
+	return w.FakeWriter.Write(ctx, topic, key, withoutSuspendDate(val).(map[string]interface{}))
+}
+
+func withoutSuspendDate(body interface{}) interface{} {
+	notification, ok := body.(map[string]interface{})
+	if !ok {
+		return body
+	}
+	copied := map[string]interface{}{}
+	for key, value := range notification {
+		if key != "suspendDate" {
+			copied[key] = value
+		}
+	}
+	return copied
+}